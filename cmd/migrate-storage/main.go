@@ -0,0 +1,220 @@
+// Command migrate-storage moves stored media to a new bucket or endpoint.
+//
+// Objects are read from the bucket in the regular S3_* settings and written under the same
+// key to the DEST_S3_* bucket. Each row's URL is rewritten right after its object is copied,
+// in batches, and progress is checkpointed per column so an interrupted run resumes where it
+// stopped. Rows whose URL already points elsewhere (migrated, or external like GIFs) are skipped.
+// IDs are random, so rows created while the tool runs can land behind a checkpoint: after
+// switching the server's S3_* settings to the new bucket, run again with -rescan to pick
+// them up (rows already pointing at the new bucket are skipped without copying).
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/user/bla-back/internal/config"
+	"github.com/user/bla-back/internal/database"
+	"github.com/user/bla-back/internal/storage"
+)
+
+// column is a table column holding URLs of files stored in the bucket
+type column struct {
+	table  string
+	column string
+	where  string
+}
+
+var columns = []column{
+	{table: "users", column: "avatar_url", where: "TRUE"},
+	{table: "conversations", column: "avatar_url", where: "TRUE"},
+	{table: "attachments", column: "url", where: "type != 'gif'"}, // GIFs live on the provider's CDN
+	{table: "sticker_packs", column: "cover_url", where: "TRUE"},
+	{table: "stickers", column: "file_url", where: "TRUE"},
+}
+
+func (c column) name() string {
+	return c.table + "." + c.column
+}
+
+type migrator struct {
+	db          *pgxpool.Pool
+	src         *storage.S3Storage
+	dst         *storage.S3Storage
+	destination string
+	batchSize   int
+}
+
+func main() {
+	cfg := config.Load()
+
+	dest := storage.Config{}
+	flag.StringVar(&dest.Endpoint, "endpoint", os.Getenv("DEST_S3_ENDPOINT"), "destination S3 endpoint")
+	flag.StringVar(&dest.Region, "region", os.Getenv("DEST_S3_REGION"), "destination S3 region")
+	flag.StringVar(&dest.Bucket, "bucket", os.Getenv("DEST_S3_BUCKET"), "destination bucket")
+	flag.StringVar(&dest.CDNURL, "cdn-url", os.Getenv("DEST_S3_CDN_URL"), "public URL prefix of the destination (default endpoint/bucket)")
+	batchSize := flag.Int("batch", 100, "rows per batch (a checkpoint is saved after each)")
+	rescan := flag.Bool("rescan", false, "start over from the first row instead of resuming")
+	flag.Parse()
+	dest.AccessKeyID = os.Getenv("DEST_S3_ACCESS_KEY_ID")
+	dest.SecretAccessKey = os.Getenv("DEST_S3_SECRET_ACCESS_KEY")
+
+	if dest.Endpoint == "" || dest.Bucket == "" {
+		log.Fatal("Destination endpoint and bucket are required (-endpoint, -bucket or DEST_S3_*)")
+	}
+	if dest.Endpoint == cfg.S3Endpoint && dest.Bucket == cfg.S3Bucket {
+		log.Fatal("Destination is the source bucket")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db, err := database.New(cfg.DatabaseURL, 0)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+	if err := db.Migrate(ctx); err != nil {
+		log.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	src, err := storage.NewS3Storage(storage.Config{
+		Endpoint:        cfg.S3Endpoint,
+		Region:          cfg.S3Region,
+		Bucket:          cfg.S3Bucket,
+		AccessKeyID:     cfg.S3AccessKeyID,
+		SecretAccessKey: cfg.S3SecretAccessKey,
+		CDNURL:          cfg.S3CDNURL,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create source storage: %v", err)
+	}
+	dst, err := storage.NewS3Storage(dest)
+	if err != nil {
+		log.Fatalf("Failed to create destination storage: %v", err)
+	}
+
+	m := &migrator{
+		db:          db.Pool,
+		src:         src,
+		dst:         dst,
+		destination: dest.Endpoint + "/" + dest.Bucket,
+		batchSize:   *batchSize,
+	}
+	if *rescan {
+		if _, err := db.Pool.Exec(ctx, `
+			UPDATE storage_migration_checkpoints SET last_id = $2, done = FALSE WHERE destination = $1
+		`, m.destination, uuid.Nil); err != nil {
+			log.Fatalf("Failed to reset checkpoints: %v", err)
+		}
+	}
+	for _, c := range columns {
+		if err := m.migrateColumn(ctx, c); err != nil {
+			log.Fatalf("Migrating %s stopped (rerun to resume): %v", c.name(), err)
+		}
+	}
+	log.Println("Storage migration complete")
+}
+
+// migrateColumn copies the objects referenced by one column, resuming from its checkpoint
+func (m *migrator) migrateColumn(ctx context.Context, c column) error {
+	lastID, done, err := m.loadCheckpoint(ctx, c)
+	if err != nil {
+		return err
+	}
+	if done {
+		log.Printf("%s: already migrated", c.name())
+		return nil
+	}
+
+	for {
+		rows, err := m.db.Query(ctx, `
+			SELECT id, `+c.column+` FROM `+c.table+`
+			WHERE id > $1 AND `+c.column+` IS NOT NULL AND `+c.where+`
+			ORDER BY id
+			LIMIT $2
+		`, lastID, m.batchSize)
+		if err != nil {
+			return err
+		}
+		type row struct {
+			id  uuid.UUID
+			url string
+		}
+		var batch []row
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.id, &r.url); err != nil {
+				rows.Close()
+				return err
+			}
+			batch = append(batch, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if len(batch) == 0 {
+			return m.saveCheckpoint(ctx, c, lastID, 0, 0, true)
+		}
+
+		copied, missing := 0, 0
+		for _, r := range batch {
+			if !m.src.Owns(r.url) {
+				continue
+			}
+
+			newURL, err := m.src.Copy(ctx, m.dst, r.url)
+			if errors.Is(err, storage.ErrObjectNotFound) {
+				// Already broken before the migration; leave the URL for the media checker to report
+				log.Printf("%s: object missing for %s (%s)", c.name(), r.id, r.url)
+				missing++
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			// Only rewrite if the row wasn't changed meanwhile (e.g. a new avatar was uploaded)
+			if _, err := m.db.Exec(ctx, `
+				UPDATE `+c.table+` SET `+c.column+` = $3 WHERE id = $1 AND `+c.column+` = $2
+			`, r.id, r.url, newURL); err != nil {
+				return err
+			}
+			copied++
+		}
+
+		lastID = batch[len(batch)-1].id
+		if err := m.saveCheckpoint(ctx, c, lastID, copied, missing, false); err != nil {
+			return err
+		}
+		log.Printf("%s: %d copied, %d missing (up to %s)", c.name(), copied, missing, lastID)
+	}
+}
+
+func (m *migrator) loadCheckpoint(ctx context.Context, c column) (lastID uuid.UUID, done bool, err error) {
+	err = m.db.QueryRow(ctx, `
+		INSERT INTO storage_migration_checkpoints (destination, source, last_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (destination, source) DO UPDATE SET updated_at = storage_migration_checkpoints.updated_at
+		RETURNING last_id, done
+	`, m.destination, c.name(), uuid.Nil).Scan(&lastID, &done)
+	return lastID, done, err
+}
+
+func (m *migrator) saveCheckpoint(ctx context.Context, c column, lastID uuid.UUID, copied, missing int, done bool) error {
+	_, err := m.db.Exec(ctx, `
+		UPDATE storage_migration_checkpoints
+		SET last_id = $3, copied = copied + $4, missing = missing + $5, done = $6, updated_at = NOW()
+		WHERE destination = $1 AND source = $2
+	`, m.destination, c.name(), lastID, copied, missing, done)
+	return err
+}
@@ -4,40 +4,98 @@ import (
 	"context"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/user/bla-back/internal/auth"
+	"github.com/user/bla-back/internal/avatars"
 	"github.com/user/bla-back/internal/cache"
 	"github.com/user/bla-back/internal/calls"
+	"github.com/user/bla-back/internal/captcha"
 	"github.com/user/bla-back/internal/config"
 	"github.com/user/bla-back/internal/database"
 	"github.com/user/bla-back/internal/friends"
+	"github.com/user/bla-back/internal/gifs"
+	"github.com/user/bla-back/internal/graph"
 	"github.com/user/bla-back/internal/handlers"
+	"github.com/user/bla-back/internal/health"
+	"github.com/user/bla-back/internal/mediacheck"
 	"github.com/user/bla-back/internal/messages"
+	"github.com/user/bla-back/internal/metrics"
 	"github.com/user/bla-back/internal/middleware"
+	"github.com/user/bla-back/internal/notifications"
+	"github.com/user/bla-back/internal/oauth"
+	"github.com/user/bla-back/internal/outbound"
+	"github.com/user/bla-back/internal/passkeys"
+	"github.com/user/bla-back/internal/push"
 	"github.com/user/bla-back/internal/realtime"
+	"github.com/user/bla-back/internal/stats"
 	"github.com/user/bla-back/internal/stickers"
 	"github.com/user/bla-back/internal/storage"
+	"github.com/user/bla-back/internal/syncfeed"
+	"github.com/user/bla-back/internal/transcribe"
+	"github.com/user/bla-back/internal/translate"
+	"github.com/user/bla-back/internal/unfurl"
+	"github.com/user/bla-back/internal/widget"
 )
 
 func main() {
 	cfg := config.Load()
 
+	readiness := health.NewReadiness("database")
+	startupBackoff := health.Backoff{
+		Initial:  time.Second,
+		Max:      30 * time.Second,
+		Attempts: cfg.StartupRetryAttempts,
+	}
+
 	// Database
-	db, err := database.New(cfg.DatabaseURL)
+	db, err := database.New(cfg.DatabaseURL, cfg.DBSlowQueryThreshold)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 	defer db.Close()
+	metrics.RegisterPgxPool(db.Pool)
+
+	// Connect and run migrations, retrying transient failures
+	connectDB := func(ctx context.Context) error {
+		if err := db.Ping(ctx); err != nil {
+			return err
+		}
+		if err := db.Migrate(ctx); err != nil {
+			return err
+		}
 
-	// Run migrations
-	if err := db.Migrate(context.Background()); err != nil {
-		log.Fatalf("Failed to run migrations: %v", err)
+		// Index builds and backfills on large tables run without blocking startup
+		go func() {
+			if err := db.MigrateOnline(context.Background()); err != nil {
+				log.Printf("Online migrations failed: %v", err)
+			}
+		}()
+		return nil
+	}
+	if err := health.Retry(context.Background(), "Database", startupBackoff, connectDB); err != nil {
+		if !cfg.StartDegraded {
+			log.Fatalf("Failed to connect to database: %v", err)
+		}
+
+		// Degraded mode: serve (and report not ready) until Postgres recovers
+		log.Printf("Warning: database not available, starting in degraded mode: %v", err)
+		go func() {
+			recovery := startupBackoff
+			recovery.Attempts = 0
+			health.Retry(context.Background(), "Database", recovery, connectDB)
+			readiness.SetReady("database", true)
+			log.Println("Database recovered, migrations completed")
+		}()
+	} else {
+		readiness.SetReady("database", true)
+		log.Println("Database migrations completed")
 	}
-	log.Println("Database migrations completed")
 
 	// Services
 	tokenService := auth.NewTokenService(
@@ -46,19 +104,82 @@ func main() {
 		cfg.AccessTokenTTL,
 		cfg.RefreshTokenTTL,
 	)
+	if len(cfg.JWTKeys) > 0 {
+		keys, err := auth.ParseKeyset(cfg.JWTKeys)
+		if err != nil {
+			log.Fatalf("Failed to load JWT keys: %v", err)
+		}
+		if err := tokenService.SetKeyset(keys, cfg.JWTSigningKID); err != nil {
+			log.Fatalf("Failed to load JWT keys: %v", err)
+		}
+	}
+	if cfg.JWTPrivateKeyFile != "" {
+		data, err := os.ReadFile(cfg.JWTPrivateKeyFile)
+		if err != nil {
+			log.Fatalf("Failed to read JWT private key: %v", err)
+		}
+		key, err := auth.ParsePrivateKeyPEM(data)
+		if err != nil {
+			log.Fatalf("Failed to load JWT private key: %v", err)
+		}
+		if err := tokenService.SetSigningKey(key); err != nil {
+			log.Fatalf("Failed to load JWT private key: %v", err)
+		}
+	}
+	for _, file := range cfg.JWTPublicKeyFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			log.Fatalf("Failed to read JWT public key %s: %v", file, err)
+		}
+		key, err := auth.ParsePublicKeyPEM(data)
+		if err != nil {
+			log.Fatalf("Failed to load JWT public key %s: %v", file, err)
+		}
+		if _, err := tokenService.AddPublicKey(key); err != nil {
+			log.Fatalf("Failed to load JWT public key %s: %v", file, err)
+		}
+	}
 
 	// Repositories
 	authRepo := auth.NewRepository(db.Pool)
+	tokenService.SetTokenVersions(authRepo)
 	friendsRepo := friends.NewRepository(db.Pool)
-	messagesRepo := messages.NewRepository(db.Pool)
-	callsRepo := calls.NewRepository(db.Pool)
+	messagesRepo := messages.NewRepository(db.Pool, cfg.MaxGroupSize)
+	messagesRepo.SetUserLookup(authRepo)
+	callsRepo := calls.NewRepository(db.Pool, cfg.MaxCallParticipants)
 	stickersRepo := stickers.NewRepository(db.Pool)
+	notificationsRepo := notifications.NewRepository(db.Pool)
+	widgetRepo := widget.NewRepository(db.Pool)
+	mediaCheckRepo := mediacheck.NewRepository(db.Pool)
+	graphRepo := graph.NewRepository(db.Pool)
+	syncRepo := syncfeed.NewRepository(db.Pool)
+	statsRepo := stats.NewRepository(db.Pool)
+	pushRepo := push.NewRepository(db.Pool)
+	passkeyRepo := passkeys.NewRepository(db.Pool)
 
 	// Voice service (custom SFU)
 	voiceService := calls.NewVoiceService(calls.VoiceConfig{
-		Host:      cfg.VoiceHost,
-		JWTSecret: cfg.VoiceJWTSecret,
+		Host:          cfg.VoiceHost,
+		JWTSecret:     cfg.VoiceJWTSecret,
+		APIURL:        cfg.VoiceAPIURL,
+		TokenTTL:      cfg.VoiceTokenTTL,
+		ClockSkew:     cfg.VoiceClockSkew,
+		WebhookSecret: cfg.VoiceWebhookSecret,
 	})
+	voiceService.SetRoomVerifier(callsRepo.VerifyVoiceRoom)
+
+	// LiveKit is available as a second backend when its keys are configured
+	var otherVoiceBackends []calls.VoiceBackend
+	if cfg.LiveKitAPIKey != "" && cfg.LiveKitAPISecret != "" {
+		otherVoiceBackends = append(otherVoiceBackends, calls.NewLiveKitService(calls.LiveKitConfig{
+			Host:      cfg.LiveKitHost,
+			APIKey:    cfg.LiveKitAPIKey,
+			APISecret: cfg.LiveKitAPISecret,
+			TokenTTL:  cfg.VoiceTokenTTL,
+		}))
+	}
+	voiceBackends := calls.NewVoiceBackends(cfg.VoiceBackend, voiceService, otherVoiceBackends...)
+	voiceBackends.SetCanary(cfg.VoiceCanaryBackend, cfg.VoiceCanaryPercent)
 
 	// S3 Storage
 	s3Storage, err := storage.NewS3Storage(storage.Config{
@@ -73,16 +194,22 @@ func main() {
 		log.Fatalf("Failed to create S3 storage: %v", err)
 	}
 	log.Println("S3 storage initialized")
+	messagesRepo.SetFileDeleter(s3Storage)
 
 	// Redis Cache (optional)
 	var redisCache *cache.RedisCache
 	if cfg.RedisAddr != "" && cfg.RedisAddr != "disabled" {
-		redisCache, err = cache.NewRedisCache(cfg.RedisAddr)
+		err = health.Retry(context.Background(), "Redis", startupBackoff, func(ctx context.Context) error {
+			var err error
+			redisCache, err = cache.NewRedisCache(cfg.RedisAddr)
+			return err
+		})
 		if err != nil {
 			log.Printf("Warning: Redis not available, running without cache: %v", err)
 			redisCache = nil
 		} else {
 			defer redisCache.Close()
+			metrics.RegisterRedis(redisCache.Client())
 			log.Println("Redis cache initialized")
 		}
 	} else {
@@ -92,21 +219,205 @@ func main() {
 	// Realtime data provider
 	rtProvider := realtime.NewProvider(authRepo, friendsRepo, messagesRepo, callsRepo)
 
-	// Centrifuge realtime node
-	rtNode, err := realtime.NewNode(tokenService, rtProvider, friendsRepo)
+	// Centrifuge realtime node; with Redis, nodes share a broker
+	rtRedisAddr := ""
+	if redisCache != nil {
+		rtRedisAddr = cfg.RedisAddr
+	}
+	rtNode, err := realtime.NewNode(tokenService, rtProvider, friendsRepo, rtProvider, realtime.NodeConfig{
+		FlushWindow:         cfg.RealtimeFlushWindow,
+		ClientQueueMaxSize:  cfg.RealtimeClientQueueMaxBytes,
+		SlowClientThreshold: cfg.RealtimeSlowClientThreshold,
+		RedisAddr:           rtRedisAddr,
+	})
 	if err != nil {
 		log.Fatalf("Failed to create realtime node: %v", err)
 	}
 
+	// Route events for offline users to the Redis offline queue
+	if redisCache != nil {
+		rtNode.SetOfflineQueue(realtime.NewRedisOfflineQueue(redisCache))
+	}
+
+	// Single-use voice tokens (without Redis they can be reused until they expire)
+	if redisCache != nil {
+		voiceService.SetReplayGuard(calls.NewRedisReplayGuard(redisCache))
+	}
+
+	// Auto-translation of incoming messages
+	translator := translate.NewClient(cfg.TranslateProvider, cfg.TranslateAPIKey, cfg.TranslateURL)
+	if redisCache != nil {
+		translator.SetCache(redisCache)
+	}
+
+	// VoIP pushes wake mobile devices for incoming calls (each platform only if configured)
+	var apnsClient *push.APNsClient
+	if cfg.APNsKeyFile != "" {
+		apnsClient, err = push.NewAPNsClient(push.APNsConfig{
+			KeyFile:    cfg.APNsKeyFile,
+			KeyID:      cfg.APNsKeyID,
+			TeamID:     cfg.APNsTeamID,
+			BundleID:   cfg.APNsBundleID,
+			Production: cfg.APNsProduction,
+		})
+		if err != nil {
+			log.Fatalf("Failed to load APNs key: %v", err)
+		}
+	}
+	var fcmClient *push.FCMClient
+	if cfg.FCMCredentialsFile != "" {
+		fcmClient, err = push.NewFCMClient(cfg.FCMCredentialsFile)
+		if err != nil {
+			log.Fatalf("Failed to load FCM credentials: %v", err)
+		}
+	}
+	callPusher := push.NewCallPusher(pushRepo, apnsClient, fcmClient)
+
+	// Fallback avatars for users without an uploaded one
+	avatarGenerator := avatars.NewGenerator()
+	if redisCache != nil {
+		avatarGenerator.SetCache(redisCache)
+	}
+
 	// Realtime notifier for handlers
 	rtNotifier := realtime.NewNotifier(rtNode)
 
+	// Server-side fetches of user-influenced URLs can't reach the internal network,
+	// except our own bucket, which may live there
+	outboundAllow := []string{cfg.OutboundAllowlist}
+	for _, raw := range []string{cfg.S3Endpoint, cfg.S3CDNURL} {
+		if u, err := url.Parse(raw); err == nil {
+			outboundAllow = append(outboundAllow, u.Hostname())
+		}
+	}
+	outboundPolicy := outbound.NewPolicy(outboundAllow...)
+
+	// Offline notifications (email/webhook) for mentions
+	var notifyChannels []notifications.Channel
+	var emailChannel *notifications.EmailChannel
+	if cfg.SMTPAddr != "" {
+		emailChannel = notifications.NewEmailChannel(notifications.EmailConfig{
+			Addr:     cfg.SMTPAddr,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SMTPFrom,
+		})
+		notifyChannels = append(notifyChannels, emailChannel)
+	}
+	if cfg.NotifyWebhookURL != "" {
+		notifyChannels = append(notifyChannels, notifications.NewWebhookChannel(cfg.NotifyWebhookURL, outboundPolicy))
+	}
+	notifyEngine := notifications.NewEngine(notificationsRepo, cfg.AppURL, notifyChannels,
+		notifications.OfflineLongerThan(rtNode, cfg.NotifyOfflineAfter),
+		notifications.OutsideQuietHours,
+		notifications.NotDoNotDisturb,
+	)
+
+	// Background jobs
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	// Disappearing messages
+	go messages.NewExpiryWorker(messagesRepo, rtNode, 30*time.Second).Run(jobsCtx)
+	go messages.NewTombstonePurger(messagesRepo, cfg.MessageTombstoneRetention, time.Hour).Run(jobsCtx)
+	go messages.NewGroupPurger(messagesRepo, s3Storage, cfg.GroupRestoreWindow, time.Hour).Run(jobsCtx)
+	go auth.NewAccountPurger(authRepo, s3Storage, cfg.AccountDeletionGrace, time.Hour).Run(jobsCtx)
+	go stats.NewAggregator(db.Pool, statsRepo, 15*time.Minute).Run(jobsCtx)
+
+	// Stored media that no longer resolves in the bucket
+	go mediacheck.NewChecker(db.Pool, mediaCheckRepo, s3Storage, cfg.MediaCheckSample, cfg.MediaCheckInterval).Run(jobsCtx)
+
+	// Anonymized friendship/conversation graphs for the admin analytics endpoints
+	go graph.NewBuilder(db.Pool, graphRepo, cfg.GraphSnapshotInterval).Run(jobsCtx)
+
+	// Conversation events pushed to external sync endpoints
+	go syncfeed.NewDeliverer(db.Pool, syncRepo, outboundPolicy, cfg.SyncDeliveryInterval).Run(jobsCtx)
+
+	// Scheduled calls: reminders and opening calls at start time
+	go calls.NewScheduler(callsRepo, messagesRepo, rtNode, cfg.AppURL, 30*time.Second).Run(jobsCtx)
+	go calls.NewRingSweeper(callsRepo, messagesRepo, rtNode, 5*time.Second).Run(jobsCtx)
+
+	// Link previews
+	unfurlWorker := unfurl.NewWorker(messagesRepo, rtNode, unfurl.NewFetcher(outboundPolicy))
+	unfurlWorker.Run(jobsCtx)
+
 	// Handlers
-	authHandler := handlers.NewAuthHandler(authRepo, tokenService, s3Storage)
+	authHandler := handlers.NewAuthHandler(authRepo, tokenService, s3Storage, cfg.InviteOnly, auth.AgePolicy{
+		MinAge:       cfg.MinAge,
+		RegionMinAge: auth.ParseRegionAges(cfg.MinAgeByRegion),
+		AdultAge:     cfg.AdultAge,
+	})
+	authHandler.SetDeletionGrace(cfg.AccountDeletionGrace)
+	authHandler.SetLoginAlerts(rtNode, emailChannel)
+	captchaClient := captcha.NewClient(cfg.CaptchaProvider, cfg.CaptchaSecret, cfg.CaptchaSiteKey)
+	authHandler.SetCaptcha(captchaClient)
+	if redisCache != nil {
+		authHandler.SetRateLimiter(redisCache)
+		authHandler.SetMagicLinks(redisCache, cfg.AppURL)
+
+		// Passkey ceremonies keep their challenges in Redis
+		origins := cfg.WebAuthnOrigins
+		if len(origins) == 0 {
+			origins = []string{strings.TrimRight(cfg.AppURL, "/")}
+		}
+		authHandler.SetPasskeys(passkeys.NewService(passkeys.Config{
+			RPID:    cfg.WebAuthnRPID,
+			RPName:  cfg.WebAuthnRPName,
+			Origins: origins,
+		}, passkeyRepo, redisCache), passkeyRepo)
+
+		// So does sign-in with external accounts
+		oauthProviders := oauth.NewRegistry(redisCache)
+		for provider, client := range map[string]oauth.Client{
+			oauth.ProviderGoogle:  {ClientID: cfg.GoogleClientID, ClientSecret: cfg.GoogleClientSecret},
+			oauth.ProviderGitHub:  {ClientID: cfg.GitHubClientID, ClientSecret: cfg.GitHubClientSecret},
+			oauth.ProviderDiscord: {ClientID: cfg.DiscordClientID, ClientSecret: cfg.DiscordClientSecret},
+		} {
+			if client.ClientID == "" {
+				continue
+			}
+			client.RedirectURL = strings.TrimRight(cfg.OAuthCallbackBaseURL, "/") + "/api/auth/oauth/" + provider + "/callback"
+			if err := oauthProviders.Register(provider, client); err != nil {
+				log.Fatalf("Failed to configure %s sign-in: %v", provider, err)
+			}
+		}
+		authHandler.SetOAuth(oauthProviders, cfg.AppURL)
+	}
+	adminHandler := handlers.NewAdminHandler(authRepo, callsRepo, mediaCheckRepo, graphRepo)
+	permalinkHandler := handlers.NewPermalinkHandler(messagesRepo, tokenService, cfg.AppURL)
+	widgetHandler := handlers.NewWidgetHandler(widgetRepo, widget.NewService(widgetRepo, rtNode))
 	friendsHandler := handlers.NewFriendsHandler(friendsRepo, rtNode)
-	messagesHandler := handlers.NewMessagesHandler(messagesRepo, rtNode, s3Storage)
-	callsHandler := handlers.NewCallsHandler(callsRepo, voiceService, authRepo, rtNotifier, messagesRepo, messagesRepo)
-	stickersHandler := handlers.NewStickersHandler(stickersRepo, s3Storage, redisCache)
+	friendsHandler.SetCaptcha(captchaClient)
+	messagesHandler := handlers.NewMessagesHandler(messagesRepo, rtNode, s3Storage, notifyEngine, unfurlWorker, translator, syncRepo, cfg.GroupRestoreWindow)
+	callsHandler := handlers.NewCallsHandler(callsRepo, voiceBackends, voiceService, authRepo, rtNotifier, messagesRepo, messagesRepo, s3Storage, cfg.CallRingTimeout)
+	// Unsigned SFU webhooks are rejected, so syncing calls from the SFU needs the secret
+	if cfg.VoiceWebhookSecret == "" {
+		if cfg.VoiceWebhookSync && (cfg.VoiceBackend == "sfu" || cfg.VoiceCanaryBackend == "sfu") {
+			log.Fatal("VOICE_WEBHOOK_SYNC with the SFU backend requires VOICE_WEBHOOK_SECRET")
+		}
+		log.Println("VOICE_WEBHOOK_SECRET not set, SFU webhooks will be rejected")
+	}
+	callsHandler.SetWebhookSync(cfg.VoiceWebhookSync)
+	callsHandler.SetReconnectGrace(cfg.CallReconnectGrace)
+	callsHandler.SetDoNotDisturb(notificationsRepo)
+	callsHandler.SetVoIPPush(callPusher)
+	callsHandler.SetTranscriber(transcribe.NewClient(cfg.TranscribeProvider, cfg.TranscribeAPIKey, cfg.TranscribeURL))
+	callsHandler.SetTURN(calls.TURNConfig{
+		URIs:   cfg.TURNURIs,
+		Secret: cfg.TURNSecret,
+		TTL:    cfg.TURNTTL,
+	})
+	if cfg.CallReconnectGrace > 0 {
+		// Participants who dropped and didn't reconnect in time leave their call
+		go calls.NewReconnectSweeper(callsRepo, cfg.CallReconnectGrace, callsHandler.HandleDrop, 5*time.Second).Run(jobsCtx)
+	}
+	scheduledCallsHandler := handlers.NewScheduledCallsHandler(callsRepo, rtNotifier, messagesRepo, authRepo, cfg.AppURL)
+	stickersHandler := handlers.NewStickersHandler(stickersRepo, authRepo, s3Storage, redisCache, cfg.AdultAge, outboundPolicy)
+	gifsHandler := handlers.NewGIFsHandler(gifs.NewClient(cfg.GIFProvider, cfg.GIFAPIKey), messagesRepo, redisCache)
+	notificationsHandler := handlers.NewNotificationsHandler(notificationsRepo)
+	pushHandler := handlers.NewPushHandler(pushRepo)
+	statsHandler := handlers.NewStatsHandler(statsRepo)
+	avatarsHandler := handlers.NewAvatarsHandler(authRepo, avatarGenerator)
 
 	// Router
 	mux := http.NewServeMux()
@@ -116,12 +427,43 @@ func main() {
 	mux.HandleFunc("POST /api/auth/login", authHandler.Login)
 	mux.HandleFunc("POST /api/auth/refresh", authHandler.Refresh)
 	mux.HandleFunc("POST /api/auth/logout", authHandler.Logout)
+	mux.HandleFunc("POST /api/auth/magic-link", authHandler.RequestMagicLink)
+	mux.HandleFunc("POST /api/auth/magic-link/login", authHandler.MagicLinkLogin)
+	mux.HandleFunc("GET /api/auth/registration", authHandler.RegistrationInfo)
+	mux.HandleFunc("GET /.well-known/jwks.json", authHandler.JWKS)
+	mux.HandleFunc("POST /api/waitlist", authHandler.JoinWaitlist)
+	mux.HandleFunc("POST /api/auth/passkeys/login/begin", authHandler.BeginPasskeyLogin)
+	mux.HandleFunc("POST /api/auth/passkeys/login/finish", authHandler.FinishPasskeyLogin)
+	mux.HandleFunc("GET /api/auth/oauth/providers", authHandler.OAuthProviders)
+	mux.HandleFunc("GET /api/auth/oauth/{provider}", authHandler.OAuthStart)
+	mux.HandleFunc("GET /api/auth/oauth/{provider}/callback", authHandler.OAuthCallback)
 
 	// Protected routes - Auth
 	authMiddleware := middleware.Auth(tokenService)
+
+	// Personal access tokens only reach the routes their scopes cover
+	readMessages := middleware.AuthWithScope(tokenService, authRepo, auth.ScopeReadMessages)
+	sendMessages := middleware.AuthWithScope(tokenService, authRepo, auth.ScopeSendMessages)
+	manageStickers := middleware.AuthWithScope(tokenService, authRepo, auth.ScopeManageStickers)
 	mux.Handle("GET /api/auth/me", authMiddleware(http.HandlerFunc(authHandler.Me)))
+	mux.Handle("POST /api/auth/logout-all", authMiddleware(http.HandlerFunc(authHandler.LogoutAll)))
+	mux.Handle("DELETE /api/auth/account", authMiddleware(http.HandlerFunc(authHandler.DeleteAccount)))
 	mux.Handle("POST /api/auth/username", authMiddleware(http.HandlerFunc(authHandler.SetUsername)))
 	mux.Handle("POST /api/auth/avatar", authMiddleware(http.HandlerFunc(authHandler.UploadAvatar)))
+	mux.HandleFunc("GET /api/avatars/generated/{userId}", avatarsHandler.Generated) // Public, no auth for caching
+	mux.Handle("PUT /api/auth/birthdate", authMiddleware(http.HandlerFunc(authHandler.SetBirthdate)))
+	mux.Handle("GET /api/auth/timezone", authMiddleware(http.HandlerFunc(authHandler.GetTimeZone)))
+	mux.Handle("PUT /api/auth/timezone", authMiddleware(http.HandlerFunc(authHandler.SetTimeZone)))
+	mux.Handle("GET /api/auth/tokens", authMiddleware(http.HandlerFunc(authHandler.ListAccessTokens)))
+	mux.Handle("POST /api/auth/tokens", authMiddleware(http.HandlerFunc(authHandler.CreateAccessToken)))
+	mux.Handle("DELETE /api/auth/tokens/{id}", authMiddleware(http.HandlerFunc(authHandler.RevokeAccessToken)))
+	mux.Handle("GET /api/auth/passkeys", authMiddleware(http.HandlerFunc(authHandler.ListPasskeys)))
+	mux.Handle("POST /api/auth/passkeys/register/begin", authMiddleware(http.HandlerFunc(authHandler.BeginPasskeyRegistration)))
+	mux.Handle("POST /api/auth/passkeys/register/finish", authMiddleware(http.HandlerFunc(authHandler.FinishPasskeyRegistration)))
+	mux.Handle("DELETE /api/auth/passkeys/{id}", authMiddleware(http.HandlerFunc(authHandler.DeletePasskey)))
+	mux.Handle("GET /api/auth/identities", authMiddleware(http.HandlerFunc(authHandler.ListOAuthIdentities)))
+	mux.Handle("POST /api/auth/identities/{provider}", authMiddleware(http.HandlerFunc(authHandler.LinkOAuthIdentity)))
+	mux.Handle("DELETE /api/auth/identities/{provider}", authMiddleware(http.HandlerFunc(authHandler.UnlinkOAuthIdentity)))
 
 	// Protected routes - Friends
 	mux.Handle("GET /api/friends", authMiddleware(http.HandlerFunc(friendsHandler.GetFriends)))
@@ -142,20 +484,85 @@ func main() {
 	mux.Handle("DELETE /api/blocks/{id}", authMiddleware(http.HandlerFunc(friendsHandler.Unblock)))
 
 	// Messages & Conversations
-	mux.Handle("GET /api/conversations", authMiddleware(http.HandlerFunc(messagesHandler.GetConversations)))
+	mux.Handle("GET /api/conversations", readMessages(http.HandlerFunc(messagesHandler.GetConversations)))
+	mux.Handle("PUT /api/conversations/order", authMiddleware(http.HandlerFunc(messagesHandler.ReorderConversations)))
 	mux.Handle("POST /api/conversations/dm", authMiddleware(http.HandlerFunc(messagesHandler.GetOrCreateDM)))
+	mux.Handle("GET /api/message-requests", authMiddleware(http.HandlerFunc(messagesHandler.GetMessageRequests)))
+	mux.Handle("POST /api/message-requests/{id}/accept", authMiddleware(http.HandlerFunc(messagesHandler.AcceptMessageRequest)))
+	mux.Handle("POST /api/message-requests/{id}/decline", authMiddleware(http.HandlerFunc(messagesHandler.DeclineMessageRequest)))
+	mux.Handle("GET /api/group-invites", authMiddleware(http.HandlerFunc(messagesHandler.GetGroupInvites)))
+	mux.Handle("POST /api/group-invites/{id}/accept", authMiddleware(http.HandlerFunc(messagesHandler.AcceptGroupInvite)))
+	mux.Handle("POST /api/group-invites/{id}/decline", authMiddleware(http.HandlerFunc(messagesHandler.DeclineGroupInvite)))
 	mux.Handle("POST /api/conversations/group", authMiddleware(http.HandlerFunc(messagesHandler.CreateGroup)))
-	mux.Handle("GET /api/conversations/{id}", authMiddleware(http.HandlerFunc(messagesHandler.GetConversation)))
-	mux.Handle("GET /api/conversations/{id}/messages", authMiddleware(http.HandlerFunc(messagesHandler.GetMessages)))
-	mux.Handle("POST /api/conversations/{id}/messages", authMiddleware(http.HandlerFunc(messagesHandler.SendMessage)))
+	mux.Handle("GET /api/conversations/{id}", readMessages(http.HandlerFunc(messagesHandler.GetConversation)))
+	mux.Handle("DELETE /api/conversations/{id}", authMiddleware(http.HandlerFunc(messagesHandler.DeleteGroup)))
+	mux.Handle("GET /api/conversations/deleted", authMiddleware(http.HandlerFunc(messagesHandler.GetDeletedGroups)))
+	mux.Handle("POST /api/conversations/{id}/restore", authMiddleware(http.HandlerFunc(messagesHandler.RestoreGroup)))
+	mux.Handle("GET /api/conversations/{id}/audit", authMiddleware(http.HandlerFunc(messagesHandler.GetAuditLog)))
+	mux.Handle("GET /api/conversations/{id}/messages", readMessages(http.HandlerFunc(messagesHandler.GetMessages)))
+	mux.Handle("POST /api/conversations/{id}/messages", sendMessages(http.HandlerFunc(messagesHandler.SendMessage)))
+	mux.Handle("DELETE /api/conversations/{id}/messages", authMiddleware(http.HandlerFunc(messagesHandler.ClearHistory)))
 	mux.Handle("DELETE /api/conversations/{id}/messages/{messageId}", authMiddleware(http.HandlerFunc(messagesHandler.DeleteMessage)))
+	mux.Handle("POST /api/conversations/{id}/delivered", authMiddleware(http.HandlerFunc(messagesHandler.MarkDelivered)))
+	mux.Handle("GET /api/conversations/{id}/messages/{messageId}/delivery", authMiddleware(http.HandlerFunc(messagesHandler.GetDeliveryStatus)))
+	mux.Handle("GET /api/conversations/{id}/messages/{messageId}/context", readMessages(http.HandlerFunc(messagesHandler.GetMessageContext)))
+	mux.Handle("GET /api/conversations/{id}/messages/{messageId}/thread", readMessages(http.HandlerFunc(messagesHandler.GetThread)))
+	mux.Handle("POST /api/conversations/{id}/messages/{messageId}/thread", sendMessages(http.HandlerFunc(messagesHandler.SendThreadReply)))
 	mux.Handle("POST /api/conversations/{id}/messages/{messageId}/reactions", authMiddleware(http.HandlerFunc(messagesHandler.AddReaction)))
 	mux.Handle("DELETE /api/conversations/{id}/messages/{messageId}/reactions/{emoji}", authMiddleware(http.HandlerFunc(messagesHandler.RemoveReaction)))
+
+	// Starred messages
+	mux.Handle("GET /api/messages/starred", authMiddleware(http.HandlerFunc(messagesHandler.GetStarredMessages)))
+	mux.Handle("POST /api/messages/{id}/star", authMiddleware(http.HandlerFunc(messagesHandler.StarMessage)))
+	mux.Handle("DELETE /api/messages/{id}/star", authMiddleware(http.HandlerFunc(messagesHandler.UnstarMessage)))
+
+	// Polls
+	mux.Handle("POST /api/conversations/{id}/polls", authMiddleware(http.HandlerFunc(messagesHandler.CreatePoll)))
+	mux.Handle("POST /api/conversations/{id}/messages/{messageId}/poll/votes", authMiddleware(http.HandlerFunc(messagesHandler.VotePoll)))
+	mux.Handle("DELETE /api/conversations/{id}/messages/{messageId}/poll/votes/{optionId}", authMiddleware(http.HandlerFunc(messagesHandler.UnvotePoll)))
+	mux.Handle("POST /api/conversations/{id}/messages/{messageId}/poll/close", authMiddleware(http.HandlerFunc(messagesHandler.ClosePoll)))
+	mux.Handle("POST /api/conversations/{id}/typing", authMiddleware(http.HandlerFunc(messagesHandler.Typing)))
+	mux.Handle("POST /api/conversations/{id}/read", authMiddleware(http.HandlerFunc(messagesHandler.MarkRead)))
 	mux.Handle("POST /api/conversations/{id}/participants", authMiddleware(http.HandlerFunc(messagesHandler.AddParticipants)))
+	mux.Handle("POST /api/conversations/{id}/user-invites", authMiddleware(http.HandlerFunc(messagesHandler.InviteToGroup)))
+	mux.Handle("GET /api/conversations/{id}/invites", authMiddleware(http.HandlerFunc(messagesHandler.GetInviteLinks)))
+	mux.Handle("POST /api/conversations/{id}/invites", authMiddleware(http.HandlerFunc(messagesHandler.CreateInviteLink)))
+	mux.Handle("DELETE /api/conversations/{id}/invites/{code}", authMiddleware(http.HandlerFunc(messagesHandler.RevokeInviteLink)))
+	mux.Handle("POST /api/invites/{code}/join", authMiddleware(http.HandlerFunc(messagesHandler.JoinByInviteLink)))
+	mux.Handle("GET /api/system-messages/schemas", authMiddleware(http.HandlerFunc(messagesHandler.GetSystemSchemas)))
+	mux.Handle("GET /api/discover/groups", authMiddleware(http.HandlerFunc(messagesHandler.DiscoverGroups)))
+	mux.Handle("POST /api/discover/groups/{id}/join", authMiddleware(http.HandlerFunc(messagesHandler.JoinPublicGroup)))
+	mux.Handle("GET /api/conversations/{id}/join-requests", authMiddleware(http.HandlerFunc(messagesHandler.GetJoinRequests)))
+	mux.Handle("POST /api/conversations/{id}/join-requests/{userId}/approve", authMiddleware(http.HandlerFunc(messagesHandler.ApproveJoinRequest)))
+	mux.Handle("POST /api/conversations/{id}/join-requests/{userId}/deny", authMiddleware(http.HandlerFunc(messagesHandler.DenyJoinRequest)))
+	mux.Handle("DELETE /api/conversations/{id}/participants/{userId}", authMiddleware(http.HandlerFunc(messagesHandler.RemoveParticipant)))
+	mux.Handle("GET /api/conversations/{id}/bans", authMiddleware(http.HandlerFunc(messagesHandler.GetBans)))
+	mux.Handle("DELETE /api/conversations/{id}/bans/{userId}", authMiddleware(http.HandlerFunc(messagesHandler.Unban)))
+	mux.Handle("GET /api/conversations/{id}/sync-endpoints", authMiddleware(http.HandlerFunc(messagesHandler.GetSyncEndpoints)))
+	mux.Handle("POST /api/conversations/{id}/sync-endpoints", authMiddleware(http.HandlerFunc(messagesHandler.CreateSyncEndpoint)))
+	mux.Handle("DELETE /api/conversations/{id}/sync-endpoints/{endpointId}", authMiddleware(http.HandlerFunc(messagesHandler.DeleteSyncEndpoint)))
+	mux.Handle("PUT /api/conversations/{id}/participants/{userId}/role", authMiddleware(http.HandlerFunc(messagesHandler.SetParticipantRole)))
+	mux.Handle("PUT /api/conversations/{id}/participants/{userId}/nickname", authMiddleware(http.HandlerFunc(messagesHandler.SetNickname)))
 	mux.Handle("POST /api/conversations/{id}/avatar", authMiddleware(http.HandlerFunc(messagesHandler.UploadGroupAvatar)))
 	mux.Handle("PATCH /api/conversations/{id}", authMiddleware(http.HandlerFunc(messagesHandler.UpdateGroup)))
+	mux.Handle("PUT /api/conversations/{id}/pin", authMiddleware(http.HandlerFunc(messagesHandler.PinConversation)))
+	mux.Handle("DELETE /api/conversations/{id}/pin", authMiddleware(http.HandlerFunc(messagesHandler.UnpinConversation)))
+	mux.Handle("PUT /api/conversations/{id}/ttl", authMiddleware(http.HandlerFunc(messagesHandler.SetMessageTTL)))
+	mux.Handle("GET /api/conversations/{id}/translation", authMiddleware(http.HandlerFunc(messagesHandler.GetTranslation)))
+	mux.Handle("PUT /api/conversations/{id}/translation", authMiddleware(http.HandlerFunc(messagesHandler.SetTranslation)))
+	mux.Handle("GET /api/conversations/{id}/ignored", authMiddleware(http.HandlerFunc(messagesHandler.GetIgnoredUsers)))
+	mux.Handle("PUT /api/conversations/{id}/ignored/{userId}", authMiddleware(http.HandlerFunc(messagesHandler.IgnoreUser)))
+	mux.Handle("DELETE /api/conversations/{id}/ignored/{userId}", authMiddleware(http.HandlerFunc(messagesHandler.UnignoreUser)))
+	mux.Handle("GET /api/conversations/{id}/draft", authMiddleware(http.HandlerFunc(messagesHandler.GetDraft)))
+	mux.Handle("PUT /api/conversations/{id}/draft", authMiddleware(http.HandlerFunc(messagesHandler.SaveDraft)))
 	mux.Handle("DELETE /api/conversations/{id}/leave", authMiddleware(http.HandlerFunc(messagesHandler.LeaveGroup)))
 
+	// Watch together
+	mux.Handle("GET /api/conversations/{id}/watch", authMiddleware(http.HandlerFunc(messagesHandler.GetWatchSession)))
+	mux.Handle("POST /api/conversations/{id}/watch", authMiddleware(http.HandlerFunc(messagesHandler.StartWatchSession)))
+	mux.Handle("POST /api/conversations/{id}/watch/control", authMiddleware(http.HandlerFunc(messagesHandler.ControlWatchSession)))
+	mux.Handle("DELETE /api/conversations/{id}/watch", authMiddleware(http.HandlerFunc(messagesHandler.EndWatchSession)))
+
 	// Attachments
 	mux.Handle("POST /api/attachments", authMiddleware(http.HandlerFunc(messagesHandler.UploadAttachment)))
 
@@ -163,23 +570,96 @@ func main() {
 	mux.Handle("POST /api/calls/start", authMiddleware(http.HandlerFunc(callsHandler.StartCall)))
 	mux.Handle("POST /api/calls/join", authMiddleware(http.HandlerFunc(callsHandler.JoinCall)))
 	mux.Handle("POST /api/calls/leave", authMiddleware(http.HandlerFunc(callsHandler.LeaveCall)))
+	mux.Handle("POST /api/calls/decline", authMiddleware(http.HandlerFunc(callsHandler.DeclineCall)))
+	mux.Handle("POST /api/calls/missed/ack", authMiddleware(http.HandlerFunc(callsHandler.AckMissedCalls)))
+	mux.Handle("GET /api/calls/turn-credentials", authMiddleware(http.HandlerFunc(callsHandler.GetTURNCredentials)))
+	mux.Handle("POST /api/calls/{id}/chat", authMiddleware(http.HandlerFunc(callsHandler.SendCallChat)))
+	mux.Handle("PUT /api/calls/{id}/chat/transcript", authMiddleware(http.HandlerFunc(callsHandler.SetCallChatTranscript)))
+	mux.Handle("PUT /api/calls/{id}/participants/{userId}/mute", authMiddleware(http.HandlerFunc(callsHandler.ServerMuteParticipant)))
+	mux.Handle("DELETE /api/calls/{id}/participants/{userId}/mute", authMiddleware(http.HandlerFunc(callsHandler.ServerUnmuteParticipant)))
+	mux.Handle("POST /api/calls/{id}/participants/{userId}/kick", authMiddleware(http.HandlerFunc(callsHandler.KickParticipant)))
+	mux.Handle("PUT /api/calls/{id}/push-to-talk", authMiddleware(http.HandlerFunc(callsHandler.SetPushToTalk)))
+	mux.Handle("PUT /api/calls/{id}/media", authMiddleware(http.HandlerFunc(callsHandler.UpdateMediaState)))
+	mux.Handle("POST /api/calls/{id}/hold", authMiddleware(http.HandlerFunc(callsHandler.HoldCall)))
+	mux.Handle("POST /api/calls/{id}/resume", authMiddleware(http.HandlerFunc(callsHandler.ResumeCall)))
+	mux.Handle("POST /api/calls/{id}/voicemail", authMiddleware(http.HandlerFunc(callsHandler.LeaveVoicemail)))
+	mux.Handle("GET /api/calls/{id}/transcript", authMiddleware(http.HandlerFunc(callsHandler.GetCallTranscript)))
+	mux.Handle("POST /api/calls/{id}/feedback", authMiddleware(http.HandlerFunc(callsHandler.SubmitFeedback)))
+	// Called by the SFU (control token auth) to redeem participant tokens
+	mux.HandleFunc("POST /api/voice/verify", callsHandler.VerifyVoiceToken)
+	// ...and to post participants' audio for live captions
+	mux.HandleFunc("POST /api/voice/captions", callsHandler.ReceiveCaptionAudio)
+	// Room events from the voice backends (each authenticates its own webhooks);
+	// /api/voice/webhook is the custom SFU's HMAC-signed participant feed
+	mux.HandleFunc("POST /api/voice/webhooks/{backend}", callsHandler.VoiceWebhook)
+	mux.HandleFunc("POST /api/voice/webhook", callsHandler.SFUWebhook)
 	mux.Handle("GET /api/conversations/{id}/call", authMiddleware(http.HandlerFunc(callsHandler.GetActiveCall)))
+	mux.Handle("POST /api/conversations/{id}/scheduled-calls", authMiddleware(http.HandlerFunc(scheduledCallsHandler.ScheduleCall)))
+	mux.Handle("GET /api/conversations/{id}/scheduled-calls", authMiddleware(http.HandlerFunc(scheduledCallsHandler.GetScheduledCalls)))
+	mux.Handle("DELETE /api/scheduled-calls/{id}", authMiddleware(http.HandlerFunc(scheduledCallsHandler.CancelScheduledCall)))
 
 	// Stickers
-	mux.Handle("GET /api/stickers", authMiddleware(http.HandlerFunc(stickersHandler.GetPacks)))
-	mux.Handle("GET /api/stickers/{id}", authMiddleware(http.HandlerFunc(stickersHandler.GetPack)))
+	mux.Handle("GET /api/stickers", manageStickers(http.HandlerFunc(stickersHandler.GetPacks)))
+	mux.Handle("GET /api/stickers/{id}", manageStickers(http.HandlerFunc(stickersHandler.GetPack)))
 	mux.HandleFunc("GET /api/stickers/file/{stickerId}", stickersHandler.ProxySticker) // Public, no auth for caching
-	mux.Handle("POST /api/stickers", authMiddleware(http.HandlerFunc(stickersHandler.CreatePack)))
-	mux.Handle("POST /api/stickers/{id}/stickers", authMiddleware(http.HandlerFunc(stickersHandler.UploadSticker)))
-	mux.Handle("POST /api/stickers/{id}/add", authMiddleware(http.HandlerFunc(stickersHandler.AddPackToCollection)))
-	mux.Handle("DELETE /api/stickers/{id}/remove", authMiddleware(http.HandlerFunc(stickersHandler.RemovePackFromCollection)))
-	mux.Handle("DELETE /api/stickers/{id}", authMiddleware(http.HandlerFunc(stickersHandler.DeletePack)))
+	mux.Handle("POST /api/stickers", manageStickers(http.HandlerFunc(stickersHandler.CreatePack)))
+	mux.Handle("POST /api/stickers/{id}/stickers", manageStickers(http.HandlerFunc(stickersHandler.UploadSticker)))
+	mux.Handle("POST /api/stickers/{id}/add", manageStickers(http.HandlerFunc(stickersHandler.AddPackToCollection)))
+	mux.Handle("DELETE /api/stickers/{id}/remove", manageStickers(http.HandlerFunc(stickersHandler.RemovePackFromCollection)))
+	mux.Handle("DELETE /api/stickers/{id}", manageStickers(http.HandlerFunc(stickersHandler.DeletePack)))
+
+	// GIFs
+	mux.Handle("GET /api/gifs/search", authMiddleware(http.HandlerFunc(gifsHandler.Search)))
+	mux.Handle("POST /api/gifs/{id}/attachment", authMiddleware(http.HandlerFunc(gifsHandler.CreateAttachment)))
+
+	// Notification settings
+	mux.Handle("GET /api/notifications/settings", authMiddleware(http.HandlerFunc(notificationsHandler.GetSettings)))
+	mux.Handle("PATCH /api/notifications/settings", authMiddleware(http.HandlerFunc(notificationsHandler.UpdateSettings)))
+	mux.Handle("PUT /api/notifications/dnd", authMiddleware(http.HandlerFunc(notificationsHandler.SetDoNotDisturb)))
+
+	// Push devices (woken for incoming calls)
+	mux.Handle("POST /api/push/devices", authMiddleware(http.HandlerFunc(pushHandler.RegisterDevice)))
+	mux.Handle("DELETE /api/push/devices/{token}", authMiddleware(http.HandlerFunc(pushHandler.UnregisterDevice)))
+
+	// Account activity stats
+	mux.Handle("GET /api/account/stats", authMiddleware(http.HandlerFunc(statsHandler.GetAccountStats)))
+	mux.Handle("GET /api/conversations/{id}/stats", authMiddleware(http.HandlerFunc(statsHandler.GetConversationStats)))
+
+	// Embeddable chat widgets: owner-managed keys, public read-only feed
+	mux.Handle("GET /api/conversations/{id}/widget-keys", authMiddleware(http.HandlerFunc(widgetHandler.ListKeys)))
+	mux.Handle("POST /api/conversations/{id}/widget-keys", authMiddleware(http.HandlerFunc(widgetHandler.CreateKey)))
+	mux.Handle("DELETE /api/conversations/{id}/widget-keys/{key}", authMiddleware(http.HandlerFunc(widgetHandler.RevokeKey)))
+	mux.HandleFunc("GET /api/public/widget/{key}/messages", widgetHandler.Messages)
+	mux.HandleFunc("GET /api/public/widget/{key}/room", widgetHandler.Room)
+
+	// Message permalinks
+	mux.HandleFunc("GET /m/{conversationId}/{messageId}", permalinkHandler.Resolve)
+
+	// Admin (X-Admin-Token)
+	adminMiddleware := middleware.Admin(cfg.AdminToken)
+	mux.Handle("POST /api/admin/invites", adminMiddleware(http.HandlerFunc(adminHandler.CreateInviteCode)))
+	mux.Handle("GET /api/admin/invites", adminMiddleware(http.HandlerFunc(adminHandler.ListInviteCodes)))
+	mux.Handle("GET /api/admin/calls/feedback", adminMiddleware(http.HandlerFunc(adminHandler.CallFeedbackSummary)))
+	mux.Handle("GET /api/admin/media/broken", adminMiddleware(http.HandlerFunc(adminHandler.BrokenMediaReport)))
+	mux.Handle("GET /api/admin/graphs", adminMiddleware(http.HandlerFunc(adminHandler.ListGraphSnapshots)))
+	mux.Handle("GET /api/admin/graphs/{kind}", adminMiddleware(http.HandlerFunc(adminHandler.GetGraphSnapshot)))
+
+	// API version discovery
+	mux.HandleFunc("GET /api/versions", handlers.Versions)
+
+	// Health checks
+	mux.HandleFunc("GET /healthz", health.Live)
+	mux.Handle("GET /readyz", readiness.Handler())
 
 	// Centrifuge WebSocket endpoint
 	mux.Handle("GET /api/ws", rtNode.WebsocketHandler())
 
-	// Apply CORS
-	handler := middleware.CORS(mux)
+	// Resolve client addresses, apply API versioning and CORS
+	trustedProxies, err := middleware.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+	handler := middleware.RealIP(trustedProxies)(middleware.CORS(middleware.APIVersion(mux)))
 
 	// Server
 	server := &http.Server{
@@ -190,6 +670,24 @@ func main() {
 		IdleTimeout:  60 * time.Second,
 	}
 
+	// Prometheus metrics, on their own listener so they aren't public
+	var metricsServer *http.Server
+	if cfg.MetricsAddr != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("GET /metrics", metrics.Handler())
+		metricsServer = &http.Server{
+			Addr:        cfg.MetricsAddr,
+			Handler:     metricsMux,
+			ReadTimeout: 15 * time.Second,
+		}
+		go func() {
+			log.Printf("Metrics listening on %s", cfg.MetricsAddr)
+			if err := metricsServer.ListenAndServe(); err != http.ErrServerClosed {
+				log.Printf("Metrics server failed: %v", err)
+			}
+		}()
+	}
+
 	// Graceful shutdown
 	go func() {
 		sigChan := make(chan os.Signal, 1)
@@ -197,6 +695,8 @@ func main() {
 		<-sigChan
 
 		log.Println("Shutting down server...")
+		stopJobs()
+
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
@@ -204,6 +704,9 @@ func main() {
 			log.Printf("Centrifuge shutdown error: %v", err)
 		}
 
+		if metricsServer != nil {
+			metricsServer.Shutdown(ctx)
+		}
 		if err := server.Shutdown(ctx); err != nil {
 			log.Fatalf("Server shutdown failed: %v", err)
 		}
@@ -0,0 +1,166 @@
+// Package apiversion negotiates HTTP API and realtime event versions and converts
+// payloads between them.
+//
+// Version 1 is the original API. Every later version is described by changes that turn
+// a version N-1 payload into version N. They are applied to the JSON encoded from the
+// models, so the models keep a shape every version can be derived from and clients that
+// never ask for a version keep getting what they always got.
+package apiversion
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+const (
+	Oldest = 1
+	Latest = 2
+)
+
+// Header carries the requested version on requests and the served version on responses
+const Header = "API-Version"
+
+var ErrUnsupported = errors.New("unsupported API version")
+
+// Change is a breaking change introduced in Version, applied to every JSON object
+// that has Key
+type Change struct {
+	Version     int    `json:"version"`
+	Key         string `json:"key"`
+	Description string `json:"description"`
+	apply       func(value any) any
+}
+
+var changes = []Change{
+	{Version: 2, Key: "reactions", Description: "Message reactions are grouped by emoji", apply: groupReactions},
+}
+
+// Changes lists the breaking changes of every version, oldest first
+func Changes() []Change {
+	return changes
+}
+
+func Supported(version int) bool {
+	return version >= Oldest && version <= Latest
+}
+
+type ctxKey struct{}
+
+// WithVersion stores the negotiated version in the context
+func WithVersion(ctx context.Context, version int) context.Context {
+	return context.WithValue(ctx, ctxKey{}, version)
+}
+
+// FromContext returns the negotiated version, or Oldest if none was negotiated
+func FromContext(ctx context.Context) int {
+	if v, ok := ctx.Value(ctxKey{}).(int); ok {
+		return v
+	}
+	return Oldest
+}
+
+var pathVersion = regexp.MustCompile(`^/api/v(\d+)(/.*)?$`)
+
+// Negotiate picks the version of a request: a /api/vN/ path prefix, then the API-Version
+// header, then Oldest. It also returns the path with the version prefix removed.
+func Negotiate(r *http.Request) (int, string, error) {
+	if m := pathVersion.FindStringSubmatch(r.URL.Path); m != nil {
+		version, err := strconv.Atoi(m[1])
+		if err != nil || !Supported(version) {
+			return 0, "", ErrUnsupported
+		}
+		return version, "/api" + m[2], nil
+	}
+
+	if h := r.Header.Get(Header); h != "" {
+		version, err := strconv.Atoi(h)
+		if err != nil || !Supported(version) {
+			return 0, "", ErrUnsupported
+		}
+		return version, r.URL.Path, nil
+	}
+
+	return Oldest, r.URL.Path, nil
+}
+
+// Transform converts a version 1 JSON payload to the given version
+func Transform(raw []byte, version int) ([]byte, error) {
+	var pending []Change
+	for _, c := range changes {
+		if c.Version <= version && bytes.Contains(raw, []byte(`"`+c.Key+`"`)) {
+			pending = append(pending, c)
+		}
+	}
+	if len(pending) == 0 {
+		return raw, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	for _, c := range pending {
+		doc = walk(doc, c)
+	}
+	return json.Marshal(doc)
+}
+
+// walk applies the change to every object in the document that has its key
+func walk(node any, c Change) any {
+	switch v := node.(type) {
+	case map[string]any:
+		for k, child := range v {
+			v[k] = walk(child, c)
+		}
+		if value, ok := v[c.Key]; ok {
+			v[c.Key] = c.apply(value)
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = walk(child, c)
+		}
+	}
+	return node
+}
+
+// groupReactions turns a list of individual reactions into one entry per emoji,
+// in order of each emoji's first use (the shape of models.ReactionGroup)
+func groupReactions(value any) any {
+	list, ok := value.([]any)
+	if !ok {
+		return value
+	}
+
+	var groups []any
+	byEmoji := make(map[string]map[string]any)
+	for _, item := range list {
+		reaction, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		emoji, _ := reaction["emoji"].(string)
+
+		group, ok := byEmoji[emoji]
+		if !ok {
+			group = map[string]any{"emoji": emoji, "count": 0, "user_ids": []any{}, "users": []any{}}
+			byEmoji[emoji] = group
+			groups = append(groups, group)
+		}
+		group["count"] = group["count"].(int) + 1
+		group["user_ids"] = append(group["user_ids"].([]any), reaction["user_id"])
+		if user, ok := reaction["user"]; ok {
+			group["users"] = append(group["users"].([]any), user)
+		}
+	}
+	if groups == nil {
+		return []any{}
+	}
+	return groups
+}
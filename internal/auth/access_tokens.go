@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/user/bla-back/internal/models"
+)
+
+// Personal access token scopes
+const (
+	ScopeReadMessages   = "read-messages"
+	ScopeSendMessages   = "send-messages"
+	ScopeManageStickers = "manage-stickers"
+)
+
+// AccessTokenPrefix marks personal access tokens so they're told apart from session JWTs
+const AccessTokenPrefix = "blapat_"
+
+// Active tokens a user may have at once
+const maxAccessTokens = 20
+
+var (
+	ErrTooManyAccessTokens = errors.New("too many active access tokens")
+	ErrAccessTokenNotFound = errors.New("access token not found")
+)
+
+// IsAccessToken reports whether a bearer token is a personal access token
+func IsAccessToken(token string) bool {
+	return strings.HasPrefix(token, AccessTokenPrefix)
+}
+
+func hashAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAccessToken issues a personal access token. The returned token carries the
+// plaintext, which isn't stored and can't be shown again.
+func (r *Repository) CreateAccessToken(ctx context.Context, userID uuid.UUID, name string, scopes []string, expiresAt *time.Time) (*models.PersonalAccessToken, error) {
+	var active int
+	err := r.db.QueryRow(ctx, `
+		SELECT COUNT(*) FROM personal_access_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+	`, userID).Scan(&active)
+	if err != nil {
+		return nil, err
+	}
+	if active >= maxAccessTokens {
+		return nil, ErrTooManyAccessTokens
+	}
+
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return nil, err
+	}
+	plain := AccessTokenPrefix + base64.RawURLEncoding.EncodeToString(bytes)
+
+	t := &models.PersonalAccessToken{Token: plain}
+	err = r.db.QueryRow(ctx, `
+		INSERT INTO personal_access_tokens (user_id, name, token_hash, prefix, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, name, prefix, scopes, use_count, last_used_at, expires_at, created_at
+	`, userID, name, hashAccessToken(plain), plain[:len(AccessTokenPrefix)+4], scopes, expiresAt).Scan(
+		&t.ID, &t.Name, &t.Prefix, &t.Scopes, &t.UseCount, &t.LastUsedAt, &t.ExpiresAt, &t.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// ListAccessTokens returns a user's active tokens with their usage, newest first
+func (r *Repository) ListAccessTokens(ctx context.Context, userID uuid.UUID) ([]*models.PersonalAccessToken, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, name, prefix, scopes, use_count, last_used_at, expires_at, created_at
+		FROM personal_access_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*models.PersonalAccessToken{}
+	for rows.Next() {
+		t := &models.PersonalAccessToken{}
+		if err := rows.Scan(&t.ID, &t.Name, &t.Prefix, &t.Scopes, &t.UseCount, &t.LastUsedAt, &t.ExpiresAt, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// RevokeAccessToken disables one of the user's tokens
+func (r *Repository) RevokeAccessToken(ctx context.Context, userID, tokenID uuid.UUID) error {
+	result, err := r.db.Exec(ctx, `
+		UPDATE personal_access_tokens SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`, tokenID, userID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrAccessTokenNotFound
+	}
+	return nil
+}
+
+// UseAccessToken resolves a personal access token to its user and scopes, counting the use
+func (r *Repository) UseAccessToken(ctx context.Context, token string) (uuid.UUID, []string, error) {
+	var userID uuid.UUID
+	var scopes []string
+	err := r.db.QueryRow(ctx, `
+		UPDATE personal_access_tokens SET use_count = use_count + 1, last_used_at = NOW()
+		WHERE token_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+		RETURNING user_id, scopes
+	`, hashAccessToken(token)).Scan(&userID, &scopes)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, nil, ErrInvalidToken
+	}
+	return userID, scopes, err
+}
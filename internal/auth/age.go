@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUnderMinimumAge  = errors.New("user is below the minimum age")
+	ErrInvalidBirthdate = errors.New("invalid birthdate")
+	ErrBirthdateSet     = errors.New("birthdate already set")
+)
+
+// AgePolicy holds minimum-age rules for registration and the age at which NSFW content is allowed
+type AgePolicy struct {
+	MinAge       int            // default minimum age
+	RegionMinAge map[string]int // ISO 3166-1 alpha-2 region -> minimum age
+	AdultAge     int
+}
+
+// ParseRegionAges parses "DE=16,KR=14" into a region -> age map, skipping malformed entries
+func ParseRegionAges(s string) map[string]int {
+	ages := make(map[string]int)
+	for _, pair := range strings.Split(s, ",") {
+		region, age, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(age))
+		if err != nil {
+			continue
+		}
+		ages[strings.ToUpper(strings.TrimSpace(region))] = n
+	}
+	return ages
+}
+
+// MinAgeFor returns the minimum age for a region (default when unknown)
+func (p AgePolicy) MinAgeFor(region string) int {
+	if age, ok := p.RegionMinAge[strings.ToUpper(region)]; ok {
+		return age
+	}
+	return p.MinAge
+}
+
+// Check validates a birthdate against the minimum age for region
+func (p AgePolicy) Check(birthdate time.Time, region string) error {
+	now := time.Now()
+	if birthdate.After(now) || Age(birthdate, now) > 130 {
+		return ErrInvalidBirthdate
+	}
+	if Age(birthdate, now) < p.MinAgeFor(region) {
+		return ErrUnderMinimumAge
+	}
+	return nil
+}
+
+// Age returns full years between birthdate and now
+func Age(birthdate, now time.Time) int {
+	age := now.Year() - birthdate.Year()
+	if now.Month() < birthdate.Month() || (now.Month() == birthdate.Month() && now.Day() < birthdate.Day()) {
+		age--
+	}
+	return age
+}
+
+// SetBirthdate stores a birthdate for users who registered without one. It cannot be changed later.
+func (r *Repository) SetBirthdate(ctx context.Context, userID uuid.UUID, birthdate time.Time) error {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE users SET birthdate = $2, updated_at = NOW()
+		WHERE id = $1 AND birthdate IS NULL
+	`, userID, birthdate)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrBirthdateSet
+	}
+	return nil
+}
+
+// IsAdult reports whether the user's birthdate shows they are at least adultAge.
+// Users without a birthdate are treated as not adult.
+func (r *Repository) IsAdult(ctx context.Context, userID uuid.UUID, adultAge int) (bool, error) {
+	var adult bool
+	err := r.db.QueryRow(ctx, `
+		SELECT COALESCE(birthdate <= CURRENT_DATE - make_interval(years => $2), false)
+		FROM users WHERE id = $1
+	`, userID, adultAge).Scan(&adult)
+	return adult, err
+}
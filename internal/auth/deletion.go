@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/purge"
+)
+
+const accountPurgeBatchSize = 20
+
+// RequestDeletion marks the account for deletion and signs it out everywhere. Signing
+// back in before the grace period ends cancels it. Returns when deletion was requested.
+func (r *Repository) RequestDeletion(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	var requestedAt time.Time
+	err := r.db.QueryRow(ctx, `
+		UPDATE users SET deletion_requested_at = COALESCE(deletion_requested_at, NOW())
+		WHERE id = $1
+		RETURNING deletion_requested_at
+	`, userID).Scan(&requestedAt)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if err := r.RevokeAllTokens(ctx, userID); err != nil {
+		return time.Time{}, err
+	}
+	return requestedAt, nil
+}
+
+// CancelDeletion clears a pending deletion request. Reports whether there was one.
+func (r *Repository) CancelDeletion(ctx context.Context, userID uuid.UUID) (bool, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE users SET deletion_requested_at = NULL
+		WHERE id = $1 AND deletion_requested_at IS NOT NULL
+	`, userID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// AnonymizeDeletedUsers anonymizes up to limit accounts whose deletion was requested
+// before the cutoff. Messages stay in their conversations under the anonymized account;
+// credentials, contacts and personal lists are removed, as are the attachments the user
+// uploaded. Returns the stored files (avatars and attachments) that the caller should
+// remove from storage.
+func (r *Repository) AnonymizeDeletedUsers(ctx context.Context, before time.Time, limit int) (anonymized int, fileURLs []string, err error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT id, avatar_url FROM users
+		WHERE deletion_requested_at IS NOT NULL AND deletion_requested_at < $1
+		ORDER BY deletion_requested_at
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	`, before, limit)
+	if err != nil {
+		return 0, nil, err
+	}
+	var userIDs []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		var avatarURL *string
+		if err := rows.Scan(&id, &avatarURL); err != nil {
+			rows.Close()
+			return 0, nil, err
+		}
+		userIDs = append(userIDs, id)
+		if avatarURL != nil {
+			fileURLs = append(fileURLs, *avatarURL)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+	if len(userIDs) == 0 {
+		return 0, nil, nil
+	}
+
+	// GIFs point at the provider's CDN, not our bucket
+	rows, err = tx.Query(ctx, `
+		DELETE FROM attachments WHERE uploader_id = ANY($1)
+		RETURNING url, type
+	`, userIDs)
+	if err != nil {
+		return 0, nil, err
+	}
+	for rows.Next() {
+		var url, attachmentType string
+		if err := rows.Scan(&url, &attachmentType); err != nil {
+			rows.Close()
+			return 0, nil, err
+		}
+		if attachmentType != "gif" {
+			fileURLs = append(fileURLs, url)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	for _, table := range []string{
+		"refresh_tokens", "personal_access_tokens", "passkeys", "oauth_identities",
+		"push_devices", "message_drafts", "login_devices",
+	} {
+		if _, err := tx.Exec(ctx, `DELETE FROM `+table+` WHERE user_id = ANY($1)`, userIDs); err != nil {
+			return 0, nil, err
+		}
+	}
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM friend_requests WHERE from_user_id = ANY($1) OR to_user_id = ANY($1)
+	`, userIDs); err != nil {
+		return 0, nil, err
+	}
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM blocks WHERE blocker_id = ANY($1) OR blocked_id = ANY($1)
+	`, userIDs); err != nil {
+		return 0, nil, err
+	}
+
+	// The email is replaced by a unique placeholder nobody can sign in with
+	if _, err := tx.Exec(ctx, `
+		UPDATE users SET
+			email = 'deleted-' || id || '@deleted.invalid',
+			password_hash = '',
+			username = NULL,
+			avatar_url = NULL,
+			birthdate = NULL,
+			time_zone = NULL,
+			time_zone_explicit = FALSE,
+			status = 'offline',
+			token_version = token_version + 1,
+			deletion_requested_at = NULL,
+			deleted_at = NOW(),
+			updated_at = NOW()
+		WHERE id = ANY($1)
+	`, userIDs); err != nil {
+		return 0, nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, nil, err
+	}
+	for _, id := range userIDs {
+		r.users.Delete(id)
+		r.tokenVersions.Delete(id)
+	}
+	return len(userIDs), fileURLs, nil
+}
+
+// NewAccountPurger periodically anonymizes accounts whose deletion grace period has passed
+func NewAccountPurger(repo *Repository, files purge.FileDeleter, grace, interval time.Duration) *purge.Runner {
+	return purge.NewRunner("deleted accounts", repo.AnonymizeDeletedUsers, accountPurgeBatchSize, files, grace, interval)
+}
@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// RecordLoginDevice remembers the device and IP a session was issued to. It reports
+// whether the sign-in came from a new device: one the user hasn't signed in from before,
+// on an account that already has sign-ins on record. A known IP doesn't make the device
+// known, since an attacker can sign in from behind the user's IP. With perIP, the device
+// is only known from the IPs it signed in from before, for keys anyone can reproduce
+// such as a user agent hash.
+func (r *Repository) RecordLoginDevice(ctx context.Context, userID uuid.UUID, deviceKey, ip, userAgent string, perIP bool) (bool, error) {
+	var hasDevices, known bool
+	err := r.db.QueryRow(ctx, `
+		SELECT
+			EXISTS (SELECT 1 FROM login_devices WHERE user_id = $1),
+			EXISTS (
+				SELECT 1 FROM login_devices
+				WHERE user_id = $1 AND device_key = $2 AND (NOT $4 OR ip = $3)
+			)
+	`, userID, deviceKey, ip, perIP).Scan(&hasDevices, &known)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = r.db.Exec(ctx, `
+		INSERT INTO login_devices (user_id, device_key, ip, user_agent)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, device_key, ip) DO UPDATE
+		SET user_agent = EXCLUDED.user_agent, last_seen_at = NOW()
+	`, userID, deviceKey, ip, userAgent)
+	if err != nil {
+		return false, err
+	}
+
+	return hasDevices && !known, nil
+}
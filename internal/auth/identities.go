@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/user/bla-back/internal/models"
+)
+
+var (
+	ErrIdentityLinked   = errors.New("this account is linked to another user")
+	ErrProviderLinked   = errors.New("another account from this provider is already linked")
+	ErrIdentityNotFound = errors.New("no linked account from this provider")
+	ErrLastSignInMethod = errors.New("cannot unlink the only way to sign in")
+)
+
+// GetUserByIdentity returns the user linked to an account at an external identity provider
+func (r *Repository) GetUserByIdentity(ctx context.Context, provider, subject string) (*models.User, error) {
+	user := &models.User{}
+
+	err := r.db.QueryRow(ctx, `
+		SELECT u.id, u.email, u.password_hash, u.username, u.avatar_url, u.status, u.created_at, u.updated_at
+		FROM oauth_identities i
+		JOIN users u ON u.id = i.user_id
+		WHERE i.provider = $1 AND i.subject = $2
+	`, provider, subject).Scan(
+		&user.ID,
+		&user.Email,
+		&user.PasswordHash,
+		&user.Username,
+		&user.AvatarURL,
+		&user.Status,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+
+	return user, err
+}
+
+// LinkIdentity links an external account to a user. Each user links at most one account
+// per provider; linking the same account again is a no-op.
+func (r *Repository) LinkIdentity(ctx context.Context, userID uuid.UUID, provider, subject, email string) error {
+	tag, err := r.db.Exec(ctx, `
+		INSERT INTO oauth_identities (provider, subject, user_id, email)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING
+	`, provider, subject, userID, email)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+
+	var owner uuid.UUID
+	err = r.db.QueryRow(ctx, `
+		SELECT user_id FROM oauth_identities WHERE provider = $1 AND subject = $2
+	`, provider, subject).Scan(&owner)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrProviderLinked
+	}
+	if err != nil {
+		return err
+	}
+	if owner != userID {
+		return ErrIdentityLinked
+	}
+	return nil
+}
+
+// CreateUserWithIdentity creates a user who signs in through an external account.
+// They have no password until they set one.
+func (r *Repository) CreateUserWithIdentity(ctx context.Context, email, provider, subject string) (*models.User, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	user := &models.User{}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO users (email, password_hash)
+		VALUES ($1, '')
+		RETURNING id, email, password_hash, username, avatar_url, status, created_at, updated_at
+	`, email).Scan(
+		&user.ID,
+		&user.Email,
+		&user.PasswordHash,
+		&user.Username,
+		&user.AvatarURL,
+		&user.Status,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if err != nil {
+		if err.Error() == `ERROR: duplicate key value violates unique constraint "users_email_key" (SQLSTATE 23505)` {
+			return nil, ErrUserExists
+		}
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO oauth_identities (provider, subject, user_id, email)
+		VALUES ($1, $2, $3, $4)
+	`, provider, subject, user.ID, email); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ListIdentities returns the external accounts linked to the user
+func (r *Repository) ListIdentities(ctx context.Context, userID uuid.UUID) ([]models.OAuthIdentity, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT provider, email, created_at FROM oauth_identities
+		WHERE user_id = $1
+		ORDER BY created_at
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.OAuthIdentity, error) {
+		var i models.OAuthIdentity
+		err := row.Scan(&i.Provider, &i.Email, &i.CreatedAt)
+		return i, err
+	})
+}
+
+// UnlinkIdentity removes the user's account from a provider, unless it's the only way
+// left to sign in (no password, passkey or other linked account)
+func (r *Repository) UnlinkIdentity(ctx context.Context, userID uuid.UUID, provider string) error {
+	tag, err := r.db.Exec(ctx, `
+		DELETE FROM oauth_identities
+		WHERE user_id = $1 AND provider = $2
+		AND (
+			EXISTS (SELECT 1 FROM users WHERE id = $1 AND password_hash <> '')
+			OR EXISTS (SELECT 1 FROM passkeys WHERE user_id = $1)
+			OR EXISTS (SELECT 1 FROM oauth_identities WHERE user_id = $1 AND provider <> $2)
+		)
+	`, userID, provider)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+
+	var linked bool
+	err = r.db.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM oauth_identities WHERE user_id = $1 AND provider = $2)
+	`, userID, provider).Scan(&linked)
+	if err != nil {
+		return err
+	}
+	if linked {
+		return ErrLastSignInMethod
+	}
+	return ErrIdentityNotFound
+}
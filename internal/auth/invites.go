@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"time"
+
+	"github.com/user/bla-back/internal/models"
+)
+
+var ErrInvalidInviteCode = errors.New("invalid or exhausted invite code")
+
+// generateInviteCode returns a short, human-typeable code
+func generateInviteCode() (string, error) {
+	bytes := make([]byte, 6)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(bytes), nil
+}
+
+// CreateInviteCode generates a new registration invite code
+func (r *Repository) CreateInviteCode(ctx context.Context, maxUses int, expiresAt *time.Time, note string) (*models.InviteCode, error) {
+	code, err := generateInviteCode()
+	if err != nil {
+		return nil, err
+	}
+
+	invite := &models.InviteCode{}
+	err = r.db.QueryRow(ctx, `
+		INSERT INTO invite_codes (code, max_uses, expires_at, note)
+		VALUES ($1, $2, $3, $4)
+		RETURNING code, max_uses, uses, expires_at, note, created_at
+	`, code, maxUses, expiresAt, note).Scan(
+		&invite.Code, &invite.MaxUses, &invite.Uses, &invite.ExpiresAt, &invite.Note, &invite.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return invite, nil
+}
+
+// ListInviteCodes returns all invite codes, newest first
+func (r *Repository) ListInviteCodes(ctx context.Context) ([]*models.InviteCode, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT code, max_uses, uses, expires_at, note, created_at
+		FROM invite_codes ORDER BY created_at DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invites []*models.InviteCode
+	for rows.Next() {
+		invite := &models.InviteCode{}
+		if err := rows.Scan(&invite.Code, &invite.MaxUses, &invite.Uses, &invite.ExpiresAt, &invite.Note, &invite.CreatedAt); err != nil {
+			return nil, err
+		}
+		invites = append(invites, invite)
+	}
+	return invites, rows.Err()
+}
+
+// CreateUserWithInvite redeems an invite code and creates the user atomically
+func (r *Repository) CreateUserWithInvite(ctx context.Context, email, passwordHash string, birthdate *time.Time, code string) (*models.User, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE invite_codes SET uses = uses + 1
+		WHERE code = UPPER($1) AND uses < max_uses AND (expires_at IS NULL OR expires_at > NOW())
+	`, code)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, ErrInvalidInviteCode
+	}
+
+	user := &models.User{}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO users (email, password_hash, birthdate)
+		VALUES ($1, $2, $3)
+		RETURNING id, email, password_hash, username, avatar_url, status, created_at, updated_at
+	`, email, passwordHash, birthdate).Scan(
+		&user.ID,
+		&user.Email,
+		&user.PasswordHash,
+		&user.Username,
+		&user.AvatarURL,
+		&user.Status,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if err != nil {
+		if err.Error() == `ERROR: duplicate key value violates unique constraint "users_email_key" (SQLSTATE 23505)` {
+			return nil, ErrUserExists
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// JoinWaitlist records an email to contact when registration opens
+func (r *Repository) JoinWaitlist(ctx context.Context, email string) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO waitlist (email) VALUES (LOWER($1))
+		ON CONFLICT (email) DO NOTHING
+	`, email)
+	return err
+}
@@ -1,9 +1,15 @@
 package auth
 
 import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
 	"crypto/rand"
+	"crypto/rsa"
 	"encoding/hex"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -11,20 +17,39 @@ import (
 )
 
 var (
-	ErrInvalidToken = errors.New("invalid token")
-	ErrExpiredToken = errors.New("token has expired")
+	ErrInvalidKeyset = errors.New("invalid JWT keyset")
+	ErrInvalidToken  = errors.New("invalid token")
+	ErrExpiredToken  = errors.New("token has expired")
+	ErrRevokedToken  = errors.New("token has been revoked")
 )
 
 type Claims struct {
 	UserID uuid.UUID `json:"user_id"`
+	// TokenVersion is the user's token version at issue; logging out everywhere bumps it
+	TokenVersion int `json:"ver,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// TokenVersions looks up users' current token versions (implemented by Repository).
+// TokenVersion returns ErrUserNotFound for users that no longer exist.
+type TokenVersions interface {
+	TokenVersion(ctx context.Context, userID uuid.UUID) (int, error)
+}
+
 type TokenService struct {
-	jwtSecret       []byte
+	jwtSecret       []byte            // signs and validates tokens (without a kid) until a keyset or signing key is set
+	keys            map[string][]byte // by kid, see SetKeyset
+	signingKID      string
 	refreshSecret   []byte
 	accessTokenTTL  time.Duration
 	refreshTokenTTL time.Duration
+	versions        TokenVersions // nil = access tokens aren't revocable
+
+	// Asymmetric signing takes precedence over the HMAC keys (see SetSigningKey)
+	signingMethod jwt.SigningMethod
+	signingKey    crypto.Signer
+	signingKeyID  string
+	publicKeys    map[string]crypto.PublicKey // by kid
 }
 
 func NewTokenService(jwtSecret, refreshSecret string, accessTTL, refreshTTL time.Duration) *TokenService {
@@ -36,9 +61,44 @@ func NewTokenService(jwtSecret, refreshSecret string, accessTTL, refreshTTL time
 	}
 }
 
-func (s *TokenService) GenerateAccessToken(userID uuid.UUID) (string, error) {
+// ParseKeyset parses signing keys given as "kid:secret" pairs
+func ParseKeyset(pairs []string) (map[string][]byte, error) {
+	keys := make(map[string][]byte, len(pairs))
+	for _, pair := range pairs {
+		kid, secret, ok := strings.Cut(pair, ":")
+		if !ok || kid == "" || secret == "" {
+			return nil, fmt.Errorf("%w: %q is not kid:secret", ErrInvalidKeyset, pair)
+		}
+		if _, dup := keys[kid]; dup {
+			return nil, fmt.Errorf("%w: duplicate kid %q", ErrInvalidKeyset, kid)
+		}
+		keys[kid] = []byte(secret)
+	}
+	return keys, nil
+}
+
+// SetKeyset signs access tokens with the signingKID key, naming it in the kid header, and
+// validates tokens against whichever key their kid names. Old keys stay in the set until
+// the tokens they signed have expired, so rotating doesn't sign anyone out. Tokens without
+// a kid stop validating; their sessions get new tokens on the next refresh.
+func (s *TokenService) SetKeyset(keys map[string][]byte, signingKID string) error {
+	if _, ok := keys[signingKID]; !ok {
+		return fmt.Errorf("%w: signing kid %q is not in the keyset", ErrInvalidKeyset, signingKID)
+	}
+	s.keys = keys
+	s.signingKID = signingKID
+	return nil
+}
+
+// SetTokenVersions makes access tokens stop validating once the user's token version moves past theirs
+func (s *TokenService) SetTokenVersions(versions TokenVersions) {
+	s.versions = versions
+}
+
+func (s *TokenService) GenerateAccessToken(userID uuid.UUID, version int) (string, error) {
 	claims := &Claims{
-		UserID: userID,
+		UserID:       userID,
+		TokenVersion: version,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.accessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -46,7 +106,17 @@ func (s *TokenService) GenerateAccessToken(userID uuid.UUID) (string, error) {
 		},
 	}
 
+	if s.signingKey != nil {
+		token := jwt.NewWithClaims(s.signingMethod, claims)
+		token.Header["kid"] = s.signingKeyID
+		return token.SignedString(s.signingKey)
+	}
+
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	if s.signingKID != "" {
+		token.Header["kid"] = s.signingKID
+		return token.SignedString(s.keys[s.signingKID])
+	}
 	return token.SignedString(s.jwtSecret)
 }
 
@@ -62,13 +132,16 @@ func (s *TokenService) GenerateRefreshToken() (string, time.Time, error) {
 	return token, expiresAt, nil
 }
 
-func (s *TokenService) ValidateAccessToken(tokenString string) (*Claims, error) {
+func (s *TokenService) ValidateAccessToken(ctx context.Context, tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		// Each key only verifies the one algorithm it signs with, so a token can't get a
+		// public key used as an HMAC secret or pick a weaker variant
+		key, alg := s.verificationKey(token.Header["kid"])
+		if key == nil || token.Method.Alg() != alg {
 			return nil, ErrInvalidToken
 		}
-		return s.jwtSecret, nil
-	})
+		return key, nil
+	}, jwt.WithValidMethods(validMethods))
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
@@ -82,9 +155,49 @@ func (s *TokenService) ValidateAccessToken(tokenString string) (*Claims, error)
 		return nil, ErrInvalidToken
 	}
 
+	// Access tokens are short-lived, so a failed lookup lets them through rather than
+	// signing everyone out while the database is unreachable. Tokens of deleted users
+	// are revoked.
+	if s.versions != nil {
+		version, err := s.versions.TokenVersion(ctx, claims.UserID)
+		if errors.Is(err, ErrUserNotFound) || err == nil && claims.TokenVersion < version {
+			return nil, ErrRevokedToken
+		}
+	}
+
 	return claims, nil
 }
 
+// validMethods are the algorithms access tokens can be signed with
+var validMethods = []string{
+	jwt.SigningMethodHS256.Alg(),
+	jwt.SigningMethodRS256.Alg(),
+	jwt.SigningMethodEdDSA.Alg(),
+}
+
+// verificationKey returns the key a token's kid names and the algorithm that key signs
+// with. Tokens without a kid were signed with jwtSecret, which stops validating once a
+// keyset or signing key replaces it, so the legacy secret can be retired.
+func (s *TokenService) verificationKey(kidHeader interface{}) (interface{}, string) {
+	kid, hasKID := kidHeader.(string)
+	if !hasKID {
+		if s.keys != nil || s.signingKey != nil {
+			return nil, ""
+		}
+		return s.jwtSecret, jwt.SigningMethodHS256.Alg()
+	}
+	if key, ok := s.keys[kid]; ok {
+		return key, jwt.SigningMethodHS256.Alg()
+	}
+	switch key := s.publicKeys[kid].(type) {
+	case *rsa.PublicKey:
+		return key, jwt.SigningMethodRS256.Alg()
+	case ed25519.PublicKey:
+		return key, jwt.SigningMethodEdDSA.Alg()
+	}
+	return nil, ""
+}
+
 func (s *TokenService) GetRefreshTokenTTL() time.Duration {
 	return s.refreshTokenTTL
 }
@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const minRSAKeyBits = 2048
+
+// ParsePrivateKeyPEM parses an RSA or Ed25519 private key (PKCS#8, or PKCS#1 for RSA)
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%w: no PEM block", ErrInvalidKeyset)
+	}
+
+	var key interface{}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidKeyset, err)
+		}
+	}
+
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("%w: RSA keys need at least %d bits", ErrInvalidKeyset, minRSAKeyBits)
+		}
+		return key, nil
+	case ed25519.PrivateKey:
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: only RSA and Ed25519 keys are supported", ErrInvalidKeyset)
+}
+
+// ParsePublicKeyPEM parses an RSA or Ed25519 public key (PKIX)
+func ParsePublicKeyPEM(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%w: no PEM block", ErrInvalidKeyset)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKeyset, err)
+	}
+
+	switch key.(type) {
+	case *rsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: only RSA and Ed25519 keys are supported", ErrInvalidKeyset)
+}
+
+// publicKeyID derives a kid from the public key, so it never has to be configured and
+// stays the same on every instance
+func publicKeyID(key crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return base64.RawURLEncoding.EncodeToString(sum[:12]), nil
+}
+
+// SetSigningKey signs access tokens with an RSA (RS256) or Ed25519 (EdDSA) key, so other
+// services can validate them with the public key alone (see JWKS). HMAC-signed tokens
+// issued before keep validating until they expire if their kid is in the keyset; tokens
+// without a kid don't.
+func (s *TokenService) SetSigningKey(key crypto.Signer) error {
+	switch key.(type) {
+	case *rsa.PrivateKey:
+		s.signingMethod = jwt.SigningMethodRS256
+	case ed25519.PrivateKey:
+		s.signingMethod = jwt.SigningMethodEdDSA
+	default:
+		return fmt.Errorf("%w: only RSA and Ed25519 keys are supported", ErrInvalidKeyset)
+	}
+
+	kid, err := s.AddPublicKey(key.Public())
+	if err != nil {
+		return err
+	}
+	s.signingKey = key
+	s.signingKeyID = kid
+	return nil
+}
+
+// AddPublicKey accepts tokens signed with the key's private half, such as a key rotated
+// out whose tokens haven't expired yet. Returns the key's kid.
+func (s *TokenService) AddPublicKey(key crypto.PublicKey) (string, error) {
+	kid, err := publicKeyID(key)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidKeyset, err)
+	}
+	if s.publicKeys == nil {
+		s.publicKeys = make(map[string]crypto.PublicKey)
+	}
+	s.publicKeys[kid] = key
+	return kid, nil
+}
+
+// JWK is a public key in JSON Web Key form (RFC 7517)
+type JWK struct {
+	KeyID     string `json:"kid"`
+	KeyType   string `json:"kty"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	N         string `json:"n,omitempty"`   // RSA modulus
+	E         string `json:"e,omitempty"`   // RSA exponent
+	Curve     string `json:"crv,omitempty"` // OKP curve
+	X         string `json:"x,omitempty"`   // OKP public key
+}
+
+// JWKS returns the public keys access tokens can be validated with
+func (s *TokenService) JWKS() []JWK {
+	keys := make([]JWK, 0, len(s.publicKeys))
+	for kid, key := range s.publicKeys {
+		switch key := key.(type) {
+		case *rsa.PublicKey:
+			keys = append(keys, JWK{
+				KeyID:     kid,
+				KeyType:   "RSA",
+				Algorithm: jwt.SigningMethodRS256.Alg(),
+				Use:       "sig",
+				N:         base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			keys = append(keys, JWK{
+				KeyID:     kid,
+				KeyType:   "OKP",
+				Algorithm: jwt.SigningMethodEdDSA.Alg(),
+				Use:       "sig",
+				Curve:     "Ed25519",
+				X:         base64.RawURLEncoding.EncodeToString(key),
+			})
+		}
+	}
+	return keys
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/user/bla-back/internal/cache"
 	"github.com/user/bla-back/internal/models"
 )
 
@@ -19,21 +20,27 @@ var (
 )
 
 type Repository struct {
-	db *pgxpool.Pool
+	db            *pgxpool.Pool
+	users         *cache.LRU[uuid.UUID, models.User]
+	tokenVersions *cache.LRU[uuid.UUID, int]
 }
 
 func NewRepository(db *pgxpool.Pool) *Repository {
-	return &Repository{db: db}
+	return &Repository{
+		db:            db,
+		users:         cache.NewLRU[uuid.UUID, models.User](userCacheSize, userCacheTTL),
+		tokenVersions: cache.NewLRU[uuid.UUID, int](userCacheSize, userCacheTTL),
+	}
 }
 
-func (r *Repository) CreateUser(ctx context.Context, email, passwordHash string) (*models.User, error) {
+func (r *Repository) CreateUser(ctx context.Context, email, passwordHash string, birthdate *time.Time) (*models.User, error) {
 	user := &models.User{}
 
 	err := r.db.QueryRow(ctx, `
-		INSERT INTO users (email, password_hash)
-		VALUES ($1, $2)
+		INSERT INTO users (email, password_hash, birthdate)
+		VALUES ($1, $2, $3)
 		RETURNING id, email, password_hash, username, avatar_url, status, created_at, updated_at
-	`, email, passwordHash).Scan(
+	`, email, passwordHash, birthdate).Scan(
 		&user.ID,
 		&user.Email,
 		&user.PasswordHash,
@@ -79,6 +86,10 @@ func (r *Repository) GetUserByEmail(ctx context.Context, email string) (*models.
 }
 
 func (r *Repository) GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	if user, ok := r.cachedUser(id); ok {
+		return user, nil
+	}
+
 	user := &models.User{}
 
 	err := r.db.QueryRow(ctx, `
@@ -98,8 +109,12 @@ func (r *Repository) GetUserByID(ctx context.Context, id uuid.UUID) (*models.Use
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, ErrUserNotFound
 	}
+	if err != nil {
+		return nil, err
+	}
 
-	return user, err
+	r.cacheUser(user)
+	return user, nil
 }
 
 func (r *Repository) SetUsername(ctx context.Context, userID uuid.UUID, username string) (*models.User, error) {
@@ -128,6 +143,7 @@ func (r *Repository) SetUsername(ctx context.Context, userID uuid.UUID, username
 		return nil, err
 	}
 
+	r.cacheUser(user)
 	return user, nil
 }
 
@@ -194,6 +210,10 @@ func (r *Repository) SetAvatarURL(ctx context.Context, userID uuid.UUID, avatarU
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, ErrUserNotFound
 	}
+	if err != nil {
+		return nil, err
+	}
 
-	return user, err
+	r.cacheUser(user)
+	return user, nil
 }
@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// GetTimeZone returns the user's IANA time zone ("" if unknown) and whether they set it explicitly
+func (r *Repository) GetTimeZone(ctx context.Context, userID uuid.UUID) (zone string, explicit bool, err error) {
+	err = r.db.QueryRow(ctx, `
+		SELECT COALESCE(time_zone, ''), time_zone_explicit FROM users WHERE id = $1
+	`, userID).Scan(&zone, &explicit)
+	return zone, explicit, err
+}
+
+// SetTimeZone stores a zone the user picked. An empty zone goes back to inferring it from clients.
+func (r *Repository) SetTimeZone(ctx context.Context, userID uuid.UUID, zone string) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE users SET time_zone = NULLIF($2, ''), time_zone_explicit = ($2 != ''), updated_at = NOW()
+		WHERE id = $1
+	`, userID, zone)
+	return err
+}
+
+// InferTimeZone stores the zone a client reports, unless the user picked one explicitly
+func (r *Repository) InferTimeZone(ctx context.Context, userID uuid.UUID, zone string) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE users SET time_zone = $2
+		WHERE id = $1 AND NOT time_zone_explicit AND time_zone IS DISTINCT FROM $2
+	`, userID, zone)
+	return err
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// TokenVersion returns the user's current token version. It's checked for every
+// authenticated request, so it's cached like users; the TTL bounds how long another
+// instance keeps accepting tokens after the user logs out everywhere. Returns
+// ErrUserNotFound once the user is deleted.
+func (r *Repository) TokenVersion(ctx context.Context, userID uuid.UUID) (int, error) {
+	if version, ok := r.tokenVersions.Get(userID); ok {
+		return version, nil
+	}
+
+	var version int
+	err := r.db.QueryRow(ctx, `SELECT token_version FROM users WHERE id = $1`, userID).Scan(&version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrUserNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	r.tokenVersions.Set(userID, version)
+	return version, nil
+}
+
+// RevokeAllTokens deletes all the user's refresh tokens, revokes their personal access
+// tokens and bumps their token version, so access tokens issued before stop validating
+func (r *Repository) RevokeAllTokens(ctx context.Context, userID uuid.UUID) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM refresh_tokens WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE personal_access_tokens SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID); err != nil {
+		return err
+	}
+
+	var version int
+	err = tx.QueryRow(ctx, `
+		UPDATE users SET token_version = token_version + 1 WHERE id = $1
+		RETURNING token_version
+	`, userID).Scan(&version)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	r.tokenVersions.Set(userID, version)
+	return nil
+}
@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/models"
+)
+
+// GetUserByID runs for every message sent, reaction, call join and realtime connect, so
+// user rows are cached in process. Writes through this repository refresh the entry; the
+// TTL bounds how stale a row changed by another instance can be.
+const (
+	userCacheSize = 10000
+	userCacheTTL  = 30 * time.Second
+)
+
+// cachedUser returns a copy of a cached user, so callers can modify it freely
+func (r *Repository) cachedUser(id uuid.UUID) (*models.User, bool) {
+	user, ok := r.users.Get(id)
+	if !ok {
+		return nil, false
+	}
+	return &user, true
+}
+
+// cacheUser stores a copy of a user read from or written to the database
+func (r *Repository) cacheUser(user *models.User) {
+	r.users.Set(user.ID, *user)
+}
@@ -0,0 +1,170 @@
+// Package avatars renders fallback avatars for users who haven't uploaded one.
+package avatars
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultSize = 128
+	MinSize     = 32
+	MaxSize     = 512
+
+	cacheKeyPrefix = "avatar:generated:"
+	cacheTTL       = 7 * 24 * time.Hour
+)
+
+// palette holds the background colors; a user always gets the same one
+var palette = []color.RGBA{
+	{0xE5, 0x73, 0x73, 0xFF}, {0xF0, 0x62, 0x92, 0xFF}, {0xBA, 0x68, 0xC8, 0xFF},
+	{0x95, 0x75, 0xCD, 0xFF}, {0x79, 0x86, 0xCB, 0xFF}, {0x64, 0xB5, 0xF6, 0xFF},
+	{0x4F, 0xC3, 0xF7, 0xFF}, {0x4D, 0xD0, 0xE1, 0xFF}, {0x4D, 0xB6, 0xAC, 0xFF},
+	{0x81, 0xC7, 0x84, 0xFF}, {0xAE, 0xD5, 0x81, 0xFF}, {0xFF, 0xB7, 0x4D, 0xFF},
+	{0xFF, 0x8A, 0x65, 0xFF}, {0xA1, 0x88, 0x7F, 0xFF}, {0x90, 0xA4, 0xAE, 0xFF},
+}
+
+var foreground = color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+
+// Cache stores rendered PNGs (implemented by cache.RedisCache)
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// Generator renders deterministic initials avatars, or an identicon when the name has no
+// drawable initials. Output only depends on the user ID, name and size.
+type Generator struct {
+	cache Cache
+}
+
+func NewGenerator() *Generator {
+	return &Generator{}
+}
+
+// SetCache enables caching of rendered images
+func (g *Generator) SetCache(c Cache) {
+	g.cache = c
+}
+
+// Initials returns up to two drawable initials of a name ("jane_doe" -> "JD"), or "" if
+// it has none (e.g. non-Latin names, which get an identicon)
+func Initials(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var initials []rune
+	for _, w := range words {
+		r := unicode.ToUpper([]rune(w)[0])
+		if _, ok := glyphs[r]; !ok {
+			continue
+		}
+		initials = append(initials, r)
+		if len(initials) == 2 {
+			break
+		}
+	}
+	return string(initials)
+}
+
+// ETag identifies the image PNG returns for these arguments
+func ETag(userID uuid.UUID, name string, size int) string {
+	return `"` + userID.String() + "-" + Initials(name) + "-" + strconv.Itoa(size) + `"`
+}
+
+// PNG returns the avatar of a user as a size x size PNG
+func (g *Generator) PNG(ctx context.Context, userID uuid.UUID, name string, size int) ([]byte, error) {
+	initials := Initials(name)
+	key := cacheKeyPrefix + userID.String() + ":" + initials + ":" + strconv.Itoa(size)
+	if g.cache != nil {
+		if data, err := g.cache.Get(ctx, key); err == nil {
+			return data, nil
+		}
+	}
+
+	seed := sha256.Sum256(userID[:])
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	fill(img, img.Bounds(), palette[int(seed[0])%len(palette)])
+	if initials != "" {
+		drawInitials(img, initials)
+	} else {
+		drawIdenticon(img, seed)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	data := buf.Bytes()
+
+	if g.cache != nil {
+		_ = g.cache.Set(ctx, key, data, cacheTTL)
+	}
+	return data, nil
+}
+
+// drawInitials draws the glyphs centered, scaled so two initials take about half the width
+func drawInitials(img *image.RGBA, initials string) {
+	size := img.Bounds().Dx()
+	scale := max(size/(2*(2*glyphWidth+1)), 1)
+
+	runes := []rune(initials)
+	width := (len(runes)*(glyphWidth+1) - 1) * scale
+	x0 := (size - width) / 2
+	y0 := (size - glyphHeight*scale) / 2
+
+	for i, r := range runes {
+		glyph := glyphs[r]
+		gx := x0 + i*(glyphWidth+1)*scale
+		for row, line := range glyph {
+			for col, px := range line {
+				if px != '#' {
+					continue
+				}
+				x, y := gx+col*scale, y0+row*scale
+				fill(img, image.Rect(x, y, x+scale, y+scale), foreground)
+			}
+		}
+	}
+}
+
+// drawIdenticon draws a horizontally mirrored 5x5 pattern taken from the seed bits
+func drawIdenticon(img *image.RGBA, seed [sha256.Size]byte) {
+	const cells = 5
+	size := img.Bounds().Dx()
+	cell := size * 2 / 3 / cells
+	offset := (size - cell*cells) / 2
+
+	for row := 0; row < cells; row++ {
+		for col := 0; col < (cells+1)/2; col++ {
+			bit := row*3 + col
+			if seed[1+bit/8]>>(bit%8)&1 == 0 {
+				continue
+			}
+			y := offset + row*cell
+			for _, c := range []int{col, cells - 1 - col} {
+				x := offset + c*cell
+				fill(img, image.Rect(x, y, x+cell, y+cell), foreground)
+			}
+		}
+	}
+}
+
+func fill(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
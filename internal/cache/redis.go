@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"encoding/json"
+	"strconv"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -24,12 +25,18 @@ func NewRedisCache(addr string) (*RedisCache, error) {
 	defer cancel()
 
 	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
 		return nil, err
 	}
 
 	return &RedisCache{client: client}, nil
 }
 
+// Client exposes the underlying client for instrumentation
+func (c *RedisCache) Client() *redis.Client {
+	return c.client
+}
+
 func (c *RedisCache) Close() error {
 	return c.client.Close()
 }
@@ -48,6 +55,11 @@ func (c *RedisCache) Delete(ctx context.Context, key string) error {
 	return c.client.Del(ctx, key).Err()
 }
 
+// Take gets the value and deletes the key, so only one caller gets it
+func (c *RedisCache) Take(ctx context.Context, key string) ([]byte, error) {
+	return c.client.GetDel(ctx, key).Bytes()
+}
+
 // JSON helpers
 
 func (c *RedisCache) GetJSON(ctx context.Context, key string, dest interface{}) error {
@@ -136,3 +148,120 @@ func (c *RedisCache) CheckRateLimit(ctx context.Context, key string, limit int,
 
 	return current <= int64(limit), nil
 }
+
+// TTL returns how long until the key expires (zero or negative if it doesn't exist or never expires)
+func (c *RedisCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return c.client.TTL(ctx, key).Result()
+}
+
+// Voice token IDs already redeemed (replay protection)
+const VoiceTokenKeyPrefix = "voice:jti:"
+
+func VoiceTokenKey(jti string) string {
+	return VoiceTokenKeyPrefix + jti
+}
+
+// ClaimOnce sets the key if it doesn't exist, reporting whether this call set it
+func (c *RedisCache) ClaimOnce(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return c.client.SetNX(ctx, key, "1", ttl).Result()
+}
+
+// Offline event queue (events for users without realtime connections)
+const (
+	OfflineEventsKeyPrefix = "offline:events:"
+	OfflineEventsMax       = 200
+	OfflineEventsTTL       = 7 * 24 * time.Hour
+)
+
+func OfflineEventsKey(userID string) string {
+	return OfflineEventsKeyPrefix + userID
+}
+
+// PushOfflineEvent appends an event to each user's offline queue in one round trip,
+// keeping only the newest OfflineEventsMax per user
+func (c *RedisCache) PushOfflineEvent(ctx context.Context, userIDs []string, payload []byte) error {
+	pipe := c.client.Pipeline()
+	for _, userID := range userIDs {
+		key := OfflineEventsKey(userID)
+		pipe.RPush(ctx, key, payload)
+		pipe.LTrim(ctx, key, -OfflineEventsMax, -1)
+		pipe.Expire(ctx, key, OfflineEventsTTL)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// PopOfflineEvents removes and returns all queued events for a user (oldest first)
+func (c *RedisCache) PopOfflineEvents(ctx context.Context, userID string) ([][]byte, error) {
+	key := OfflineEventsKey(userID)
+	pipe := c.client.TxPipeline()
+	get := pipe.LRange(ctx, key, 0, -1)
+	pipe.Del(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	vals := get.Val()
+	events := make([][]byte, len(vals))
+	for i, v := range vals {
+		events[i] = []byte(v)
+	}
+	return events, nil
+}
+
+// Realtime connections across nodes. Each connection holds a lease its node renews, so
+// connections of a node that crashed stop counting once their lease runs out.
+const (
+	RealtimeConnectionsKeyPrefix = "realtime:connections:"
+	RealtimeConnectionLease      = 2 * time.Minute
+)
+
+func RealtimeConnectionsKey(userID string) string {
+	return RealtimeConnectionsKeyPrefix + userID
+}
+
+// AddConnections records (or renews the leases of) connections, given as userID -> clientIDs
+func (c *RedisCache) AddConnections(ctx context.Context, connections map[string][]string) error {
+	if len(connections) == 0 {
+		return nil
+	}
+	expiresAt := float64(time.Now().Add(RealtimeConnectionLease).Unix())
+	pipe := c.client.Pipeline()
+	for userID, clientIDs := range connections {
+		key := RealtimeConnectionsKey(userID)
+		for _, clientID := range clientIDs {
+			pipe.ZAdd(ctx, key, redis.Z{Score: expiresAt, Member: clientID})
+		}
+		pipe.ZRemRangeByScore(ctx, key, "-inf", "("+strconv.FormatInt(time.Now().Unix(), 10))
+		pipe.Expire(ctx, key, RealtimeConnectionLease)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (c *RedisCache) RemoveConnection(ctx context.Context, userID, clientID string) error {
+	return c.client.ZRem(ctx, RealtimeConnectionsKey(userID), clientID).Err()
+}
+
+// ConnectedUsers reports which of the users have a connection with an unexpired lease
+func (c *RedisCache) ConnectedUsers(ctx context.Context, userIDs []string) (map[string]bool, error) {
+	result := make(map[string]bool, len(userIDs))
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	pipe := c.client.Pipeline()
+	counts := make([]*redis.IntCmd, len(userIDs))
+	for i, userID := range userIDs {
+		counts[i] = pipe.ZCount(ctx, RealtimeConnectionsKey(userID), now, "+inf")
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	for i, userID := range userIDs {
+		result[userID] = counts[i].Val() > 0
+	}
+	return result, nil
+}
@@ -0,0 +1,133 @@
+package calls
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Voice backend names, as configured in VOICE_BACKEND and stored on calls
+const (
+	BackendSFU     = "sfu"
+	BackendLiveKit = "livekit"
+)
+
+// Voice events reported by backend webhooks
+const (
+	VoiceEventParticipantJoined = "participant_joined"
+	VoiceEventParticipantLeft   = "participant_left"
+	VoiceEventRoomFinished      = "room_finished"
+	// Reported by the custom SFU only; LiveKit clients get active speakers from the room
+	VoiceEventSpeakingChanged = "speaking_changed"
+)
+
+// VoiceEvent is a room lifecycle event from either backend, in a common shape.
+// Types other than the ones above are passed through and can be ignored.
+type VoiceEvent struct {
+	Type     string
+	RoomName string
+	UserID   string // empty for room events
+	Speaking bool   // for VoiceEventSpeakingChanged
+}
+
+// VoiceBackend is a media server that hosts call rooms
+type VoiceBackend interface {
+	Name() string
+	GenerateToken(roomName, userID, username string, perms VoicePermissions) (string, error)
+	GetWebSocketURL() string
+	// SetParticipantMuted stops (or resumes) forwarding a connected participant's audio
+	SetParticipantMuted(ctx context.Context, roomName, userID string, muted bool) error
+	// RemoveParticipant disconnects a participant from the room
+	RemoveParticipant(ctx context.Context, roomName, userID string) error
+	// CloseRoom disconnects everyone left in a room once its call has ended
+	CloseRoom(ctx context.Context, roomName string) error
+	// ReceiveWebhook authenticates a webhook request from the backend and decodes its event
+	ReceiveWebhook(r *http.Request) (*VoiceEvent, error)
+}
+
+// VoiceBackends picks the backend for each call. New calls go to the primary backend,
+// except for a canary share routed to another one. Backends that aren't configured
+// fall back to the first registered one, so a call always gets a usable backend.
+type VoiceBackends struct {
+	backends      map[string]VoiceBackend
+	fallback      VoiceBackend
+	primary       string
+	canary        string
+	canaryPercent int
+}
+
+// NewVoiceBackends registers the configured backends; the first is the fallback
+func NewVoiceBackends(primary string, fallback VoiceBackend, others ...VoiceBackend) *VoiceBackends {
+	b := &VoiceBackends{
+		backends: map[string]VoiceBackend{fallback.Name(): fallback},
+		fallback: fallback,
+		primary:  primary,
+	}
+	for _, backend := range others {
+		b.backends[backend.Name()] = backend
+	}
+	if _, ok := b.backends[primary]; !ok {
+		log.Printf("Voice backend %q is not configured, using %q", primary, fallback.Name())
+		b.primary = fallback.Name()
+	}
+	return b
+}
+
+// SetCanary routes percent (0-100) of new calls to another backend
+func (b *VoiceBackends) SetCanary(name string, percent int) {
+	if name == "" || percent <= 0 {
+		return
+	}
+	if _, ok := b.backends[name]; !ok {
+		log.Printf("Voice canary backend %q is not configured, ignoring", name)
+		return
+	}
+	b.canary = name
+	b.canaryPercent = min(percent, 100)
+}
+
+// Pick returns the backend a new call should use. Calls are bucketed by ID,
+// so the choice is stable for a call.
+func (b *VoiceBackends) Pick(callID uuid.UUID) VoiceBackend {
+	if b.canary != "" {
+		h := fnv.New32a()
+		h.Write(callID[:])
+		if int(h.Sum32()%100) < b.canaryPercent {
+			return b.backends[b.canary]
+		}
+	}
+	return b.backends[b.primary]
+}
+
+// Get returns a backend by name, or the fallback if it's no longer configured
+func (b *VoiceBackends) Get(name string) VoiceBackend {
+	if backend, ok := b.backends[name]; ok {
+		return backend
+	}
+	return b.fallback
+}
+
+// Lookup returns a backend by name, for routing its webhooks
+func (b *VoiceBackends) Lookup(name string) (VoiceBackend, bool) {
+	backend, ok := b.backends[name]
+	return backend, ok
+}
+
+// AssignVoiceBackend records the backend for a call unless it already has one,
+// and returns the call's backend
+func (r *Repository) AssignVoiceBackend(ctx context.Context, callID uuid.UUID, name string) (string, error) {
+	var backend string
+	err := r.db.QueryRow(ctx, `
+		UPDATE calls SET voice_backend = COALESCE(voice_backend, $2) WHERE id = $1
+		RETURNING voice_backend
+	`, callID, name).Scan(&backend)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrCallNotFound
+	}
+	return backend, err
+}
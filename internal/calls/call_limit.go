@@ -0,0 +1,57 @@
+package calls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrCallFull = errors.New("call is full")
+
+// CallFullError reports a join that would take a call past the participant limit.
+// It matches ErrCallFull.
+type CallFullError struct {
+	Max     int
+	Current int
+}
+
+func (e *CallFullError) Error() string {
+	return fmt.Sprintf("call has %d of %d participants", e.Current, e.Max)
+}
+
+func (e *CallFullError) Is(target error) bool {
+	return target == ErrCallFull
+}
+
+// reserveCallSeat locks the call and returns a *CallFullError if it has no room for
+// the user. A user who is already in the call doesn't need a seat.
+func (r *Repository) reserveCallSeat(ctx context.Context, tx pgx.Tx, callID, userID uuid.UUID) error {
+	if r.maxParticipants <= 0 {
+		return nil
+	}
+
+	// Concurrent joins wait here, so they can't both take the last seat
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM calls WHERE id = $1 FOR UPDATE`, callID); err != nil {
+		return err
+	}
+
+	var current int
+	var present bool
+	err := tx.QueryRow(ctx, `
+		SELECT
+			COUNT(DISTINCT user_id),
+			COALESCE(BOOL_OR(user_id = $2), FALSE)
+		FROM call_participants
+		WHERE call_id = $1 AND left_at IS NULL
+	`, callID, userID).Scan(&current, &present)
+	if err != nil {
+		return err
+	}
+	if !present && current >= r.maxParticipants {
+		return &CallFullError{Max: r.maxParticipants, Current: current}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package calls
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/models"
+)
+
+// AddCaption stores a transcribed line spoken by a participant of the call
+func (r *Repository) AddCaption(ctx context.Context, callID, userID uuid.UUID, text string) (*models.CallCaption, error) {
+	caption := &models.CallCaption{}
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO call_captions (call_id, user_id, text)
+		VALUES ($1, $2, $3)
+		RETURNING id, call_id, user_id, text, created_at
+	`, callID, userID, text).Scan(&caption.ID, &caption.CallID, &caption.UserID, &caption.Text, &caption.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return caption, nil
+}
+
+// GetCaptions returns the transcript of a call, oldest line first. Only users who took
+// part in the call can read it.
+func (r *Repository) GetCaptions(ctx context.Context, callID, userID uuid.UUID) ([]models.CallCaption, error) {
+	var joined bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM call_participants WHERE call_id = $1 AND user_id = $2)
+	`, callID, userID).Scan(&joined)
+	if err != nil {
+		return nil, err
+	}
+	if !joined {
+		return nil, ErrNotCallParticipant
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT id, call_id, user_id, text, created_at
+		FROM call_captions
+		WHERE call_id = $1
+		ORDER BY created_at
+	`, callID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	captions := []models.CallCaption{}
+	for rows.Next() {
+		var c models.CallCaption
+		if err := rows.Scan(&c.ID, &c.CallID, &c.UserID, &c.Text, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		captions = append(captions, c)
+	}
+	return captions, rows.Err()
+}
@@ -0,0 +1,110 @@
+package calls
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/models"
+)
+
+var ErrNotInCall = errors.New("not an active participant of this call")
+
+// IsActiveParticipant reports whether the user is currently in the (ongoing) call
+func (r *Repository) IsActiveParticipant(ctx context.Context, callID, userID uuid.UUID) (bool, error) {
+	var ok bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM call_participants cp
+			JOIN calls c ON c.id = cp.call_id
+			WHERE cp.call_id = $1 AND cp.user_id = $2 AND cp.left_at IS NULL AND c.ended_at IS NULL
+		)
+	`, callID, userID).Scan(&ok)
+	return ok, err
+}
+
+// AddChatMessage buffers an in-call chat line until the call ends
+func (r *Repository) AddChatMessage(ctx context.Context, callID, userID uuid.UUID, content string) (*models.CallChatMessage, error) {
+	ok, err := r.IsActiveParticipant(ctx, callID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotInCall
+	}
+
+	msg := &models.CallChatMessage{}
+	err = r.db.QueryRow(ctx, `
+		INSERT INTO call_chat_messages (call_id, user_id, content)
+		VALUES ($1, $2, $3)
+		RETURNING id, call_id, user_id, content, created_at
+	`, callID, userID, content).Scan(&msg.ID, &msg.CallID, &msg.UserID, &msg.Content, &msg.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// SetChatTranscript turns saving the chat as a transcript message on or off for the call
+func (r *Repository) SetChatTranscript(ctx context.Context, callID, userID uuid.UUID, enabled bool) error {
+	ok, err := r.IsActiveParticipant(ctx, callID, userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotInCall
+	}
+
+	_, err = r.db.Exec(ctx, `UPDATE calls SET chat_transcript = $2 WHERE id = $1`, callID, enabled)
+	return err
+}
+
+// TakeChatTranscript removes the buffered chat of an ended call. The lines are returned
+// only if a participant enabled the transcript; otherwise the chat is discarded.
+func (r *Repository) TakeChatTranscript(ctx context.Context, callID uuid.UUID) ([]models.CallChatMessage, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var enabled bool
+	if err := tx.QueryRow(ctx, `SELECT chat_transcript FROM calls WHERE id = $1`, callID).Scan(&enabled); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(ctx, `
+		DELETE FROM call_chat_messages WHERE call_id = $1
+		RETURNING id, call_id, user_id, content, created_at
+	`, callID)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []models.CallChatMessage
+	for rows.Next() {
+		var m models.CallChatMessage
+		if err := rows.Scan(&m.ID, &m.CallID, &m.UserID, &m.Content, &m.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		lines = append(lines, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	if !enabled {
+		return nil, nil
+	}
+
+	// DELETE ... RETURNING doesn't keep order
+	sort.Slice(lines, func(i, j int) bool { return lines[i].CreatedAt.Before(lines[j].CreatedAt) })
+	return lines, nil
+}
@@ -0,0 +1,147 @@
+package calls
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/models"
+)
+
+var ErrNotCallParticipant = errors.New("user did not take part in this call")
+
+// FeedbackIssues are the issue tags clients may report
+var FeedbackIssues = map[string]bool{
+	"no_audio":          true,
+	"choppy_audio":      true,
+	"echo":              true,
+	"video_frozen":      true,
+	"video_blurry":      true,
+	"screen_share":      true,
+	"dropped":           true,
+	"connection_failed": true,
+	"other":             true,
+}
+
+// SaveFeedback stores (or replaces) a participant's rating and client stats for a call
+func (r *Repository) SaveFeedback(ctx context.Context, callID, userID uuid.UUID, req *models.CallFeedbackRequest) (*models.CallFeedback, error) {
+	var joined bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM call_participants WHERE call_id = $1 AND user_id = $2)
+	`, callID, userID).Scan(&joined)
+	if err != nil {
+		return nil, err
+	}
+	if !joined {
+		return nil, ErrNotCallParticipant
+	}
+
+	issues := req.Issues
+	if issues == nil {
+		issues = []string{}
+	}
+
+	fb := &models.CallFeedback{}
+	err = r.db.QueryRow(ctx, `
+		INSERT INTO call_feedback (call_id, user_id, rating, issues, comment, rtt_ms, packet_loss_pct, jitter_ms, platform)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (call_id, user_id) DO UPDATE SET
+			rating = EXCLUDED.rating,
+			issues = EXCLUDED.issues,
+			comment = EXCLUDED.comment,
+			rtt_ms = EXCLUDED.rtt_ms,
+			packet_loss_pct = EXCLUDED.packet_loss_pct,
+			jitter_ms = EXCLUDED.jitter_ms,
+			platform = EXCLUDED.platform,
+			created_at = NOW()
+		RETURNING call_id, user_id, rating, issues, comment, rtt_ms, packet_loss_pct, jitter_ms, platform, created_at
+	`, callID, userID, req.Rating, issues, req.Comment, req.Stats.RTTMs, req.Stats.PacketLossPct, req.Stats.JitterMs, req.Platform).Scan(
+		&fb.CallID, &fb.UserID, &fb.Rating, &fb.Issues, &fb.Comment,
+		&fb.Stats.RTTMs, &fb.Stats.PacketLossPct, &fb.Stats.JitterMs, &fb.Platform, &fb.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return fb, nil
+}
+
+// FeedbackSummary aggregates call feedback submitted since the given time
+func (r *Repository) FeedbackSummary(ctx context.Context, since time.Time) (*models.CallFeedbackSummary, error) {
+	summary := &models.CallFeedbackSummary{
+		Since:    since,
+		Issues:   map[string]int{},
+		Days:     []models.CallFeedbackDay{},
+		Platform: map[string]float64{},
+	}
+
+	err := r.db.QueryRow(ctx, `
+		SELECT COUNT(*), COALESCE(AVG(rating), 0),
+			   AVG(rtt_ms), PERCENTILE_CONT(0.95) WITHIN GROUP (ORDER BY rtt_ms),
+			   AVG(packet_loss_pct), PERCENTILE_CONT(0.95) WITHIN GROUP (ORDER BY packet_loss_pct)
+		FROM call_feedback WHERE created_at >= $1
+	`, since).Scan(&summary.Responses, &summary.AvgRating,
+		&summary.AvgRTTMs, &summary.P95RTTMs, &summary.AvgPacketLossPct, &summary.P95PacketLossPct)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT issue, COUNT(*) FROM call_feedback, UNNEST(issues) AS issue
+		WHERE created_at >= $1
+		GROUP BY issue
+	`, since)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var issue string
+		var count int
+		if err := rows.Scan(&issue, &count); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		summary.Issues[issue] = count
+	}
+	rows.Close()
+
+	rows, err = r.db.Query(ctx, `
+		SELECT COALESCE(platform, 'unknown'), AVG(rating) FROM call_feedback
+		WHERE created_at >= $1
+		GROUP BY 1
+	`, since)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var platform string
+		var avg float64
+		if err := rows.Scan(&platform, &avg); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		summary.Platform[platform] = avg
+	}
+	rows.Close()
+
+	rows, err = r.db.Query(ctx, `
+		SELECT created_at::date, COUNT(*), AVG(rating), AVG(rtt_ms), AVG(packet_loss_pct)
+		FROM call_feedback WHERE created_at >= $1
+		GROUP BY 1 ORDER BY 1
+	`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d models.CallFeedbackDay
+		var day time.Time
+		if err := rows.Scan(&day, &d.Responses, &d.AvgRating, &d.AvgRTTMs, &d.AvgPacketLossPct); err != nil {
+			return nil, err
+		}
+		d.Date = day.Format(time.DateOnly)
+		summary.Days = append(summary.Days, d)
+	}
+
+	return summary, rows.Err()
+}
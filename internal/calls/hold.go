@@ -0,0 +1,69 @@
+package calls
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// A participant can put a call on hold to take another one. They stay in the held call
+// (it doesn't end without them) but don't count as busy in it, and can resume it later.
+
+// HoldCall puts the user's participation in the call on hold. Returns false if they
+// aren't in it.
+func (r *Repository) HoldCall(ctx context.Context, callID, userID uuid.UUID) (bool, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE call_participants SET on_hold = TRUE
+		WHERE call_id = $1 AND user_id = $2 AND left_at IS NULL
+	`, callID, userID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ResumeCall takes the user's participation in the call off hold. Returns false if
+// they aren't in it.
+func (r *Repository) ResumeCall(ctx context.Context, callID, userID uuid.UUID) (bool, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE call_participants SET on_hold = FALSE
+		WHERE call_id = $1 AND user_id = $2 AND left_at IS NULL
+	`, callID, userID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetOnHold returns the call's participants who have it on hold
+func (r *Repository) GetOnHold(ctx context.Context, callID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT DISTINCT user_id FROM call_participants
+		WHERE call_id = $1 AND left_at IS NULL AND on_hold
+	`, callID)
+	if err != nil {
+		return nil, err
+	}
+	userIDs, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		return nil, err
+	}
+	if userIDs == nil {
+		userIDs = []uuid.UUID{}
+	}
+	return userIDs, nil
+}
+
+// FilterBusy returns which of the users are in an ongoing call they don't have on hold
+func (r *Repository) FilterBusy(ctx context.Context, userIDs []uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT DISTINCT cp.user_id FROM call_participants cp
+		JOIN calls c ON c.id = cp.call_id
+		WHERE cp.user_id = ANY($1) AND cp.left_at IS NULL AND NOT cp.on_hold AND c.ended_at IS NULL
+	`, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+}
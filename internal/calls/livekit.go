@@ -1,35 +1,66 @@
 package calls
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/webhook"
+	"github.com/twitchtv/twirp"
 )
 
 type LiveKitConfig struct {
 	Host      string
 	APIKey    string
 	APISecret string
+	// How long a participant token can be used to connect (default 10m)
+	TokenTTL time.Duration
 }
 
 type LiveKitService struct {
 	config LiveKitConfig
+	rooms  livekit.RoomService
+	keys   auth.KeyProvider
 }
 
 func NewLiveKitService(config LiveKitConfig) *LiveKitService {
-	return &LiveKitService{config: config}
+	if config.TokenTTL <= 0 {
+		config.TokenTTL = defaultVoiceTokenTTL
+	}
+	// The room service API is served over HTTP on the same host as the signalling WebSocket
+	apiURL := strings.Replace(config.Host, "ws", "http", 1)
+	return &LiveKitService{
+		config: config,
+		rooms:  livekit.NewRoomServiceProtobufClient(apiURL, &http.Client{Timeout: 5 * time.Second}),
+		keys:   auth.NewSimpleKeyProvider(config.APIKey, config.APISecret),
+	}
 }
 
-func (s *LiveKitService) GenerateToken(roomName, userID, username string) (string, error) {
+func (s *LiveKitService) Name() string {
+	return BackendLiveKit
+}
+
+func (s *LiveKitService) GenerateToken(roomName, userID, username string, perms VoicePermissions) (string, error) {
 	at := auth.NewAccessToken(s.config.APIKey, s.config.APISecret)
 
-	at.SetVideoGrant(&auth.VideoGrant{
+	grant := &auth.VideoGrant{
 		RoomJoin: true,
 		Room:     roomName,
-	}).
+	}
+	grant.SetCanPublish(perms.CanSpeak)
+	grant.SetCanSubscribe(true)
+
+	// LiveKit has no push-to-talk mode, clients read it from the participant attributes
+	at.SetVideoGrant(grant).
 		SetIdentity(userID).
 		SetName(username).
-		SetValidFor(24 * time.Hour)
+		SetAttributes(map[string]string{"push_to_talk": strconv.FormatBool(perms.PushToTalk)}).
+		SetValidFor(s.config.TokenTTL)
 
 	return at.ToJWT()
 }
@@ -37,3 +68,92 @@ func (s *LiveKitService) GenerateToken(roomName, userID, username string) (strin
 func (s *LiveKitService) GetWebSocketURL() string {
 	return s.config.Host
 }
+
+// SetParticipantMuted revokes (or restores) the participant's permission to publish
+func (s *LiveKitService) SetParticipantMuted(ctx context.Context, roomName, userID string, muted bool) error {
+	ctx, err := s.adminContext(ctx, roomName)
+	if err != nil {
+		return err
+	}
+	_, err = s.rooms.UpdateParticipant(ctx, &livekit.UpdateParticipantRequest{
+		Room:     roomName,
+		Identity: userID,
+		Permission: &livekit.ParticipantPermission{
+			CanSubscribe:   true,
+			CanPublish:     !muted,
+			CanPublishData: true,
+		},
+	})
+	// Not connected right now; the permission applies through their next token
+	if isTwirpNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// RemoveParticipant disconnects the participant from the room
+func (s *LiveKitService) RemoveParticipant(ctx context.Context, roomName, userID string) error {
+	ctx, err := s.adminContext(ctx, roomName)
+	if err != nil {
+		return err
+	}
+	_, err = s.rooms.RemoveParticipant(ctx, &livekit.RoomParticipantIdentity{
+		Room:     roomName,
+		Identity: userID,
+	})
+	if isTwirpNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// CloseRoom deletes the room, disconnecting everyone in it
+func (s *LiveKitService) CloseRoom(ctx context.Context, roomName string) error {
+	ctx, err := s.adminContext(ctx, roomName)
+	if err != nil {
+		return err
+	}
+	_, err = s.rooms.DeleteRoom(ctx, &livekit.DeleteRoomRequest{Room: roomName})
+	if isTwirpNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// ReceiveWebhook verifies a LiveKit webhook, signed with the API key pair
+func (s *LiveKitService) ReceiveWebhook(r *http.Request) (*VoiceEvent, error) {
+	event, err := webhook.ReceiveWebhookEvent(r, s.keys)
+	if err != nil {
+		return nil, err
+	}
+
+	// LiveKit's event names match the VoiceEvent constants
+	e := &VoiceEvent{Type: event.Event}
+	if event.Room != nil {
+		e.RoomName = event.Room.Name
+	}
+	if event.Participant != nil {
+		e.UserID = event.Participant.Identity
+	}
+	return e, nil
+}
+
+// adminContext authorizes room service calls for a room
+func (s *LiveKitService) adminContext(ctx context.Context, roomName string) (context.Context, error) {
+	token, err := auth.NewAccessToken(s.config.APIKey, s.config.APISecret).
+		SetVideoGrant(&auth.VideoGrant{RoomAdmin: true, Room: roomName}).
+		SetValidFor(time.Minute).
+		ToJWT()
+	if err != nil {
+		return nil, err
+	}
+
+	header := make(http.Header)
+	header.Set("Authorization", "Bearer "+token)
+	return twirp.WithHTTPRequestHeaders(ctx, header)
+}
+
+func isTwirpNotFound(err error) bool {
+	var twerr twirp.Error
+	return errors.As(err, &twerr) && twerr.Code() == twirp.NotFound
+}
@@ -0,0 +1,53 @@
+package calls
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/models"
+)
+
+// SetMediaState updates the user's self-reported mute, deafen and camera state in the
+// call. Nil fields keep their value; the state resets when they rejoin.
+func (r *Repository) SetMediaState(ctx context.Context, callID, userID uuid.UUID, update *models.UpdateCallMediaRequest) error {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE call_participants SET
+			self_muted = COALESCE($3, self_muted),
+			self_deafened = COALESCE($4, self_deafened),
+			camera_on = COALESCE($5, camera_on)
+		WHERE call_id = $1 AND user_id = $2 AND left_at IS NULL
+	`, callID, userID, update.Muted, update.Deafened, update.Camera)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotInCall
+	}
+	return nil
+}
+
+// GetMediaStates returns the media state of the call's active participants. A user
+// in the call from several sessions counts as muted or deafened only if all of them are.
+func (r *Repository) GetMediaStates(ctx context.Context, callID uuid.UUID) ([]models.CallMediaState, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT user_id, BOOL_AND(self_muted), BOOL_AND(self_deafened), BOOL_OR(camera_on)
+		FROM call_participants
+		WHERE call_id = $1 AND left_at IS NULL
+		GROUP BY user_id
+		ORDER BY MIN(joined_at)
+	`, callID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	states := []models.CallMediaState{}
+	for rows.Next() {
+		var s models.CallMediaState
+		if err := rows.Scan(&s.UserID, &s.Muted, &s.Deafened, &s.Camera); err != nil {
+			return nil, err
+		}
+		states = append(states, s)
+	}
+	return states, rows.Err()
+}
@@ -0,0 +1,56 @@
+package calls
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// TrackMisses records the users a call rings, so those who never answer it can be told
+// they missed it once it ends. Users on Do Not Disturb are tracked even though they
+// aren't rung.
+func (r *Repository) TrackMisses(ctx context.Context, callID uuid.UUID, userIDs []uuid.UUID) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO call_misses (call_id, user_id)
+		SELECT $1, unnest($2::uuid[])
+		ON CONFLICT (call_id, user_id) DO NOTHING
+	`, callID, userIDs)
+	return err
+}
+
+// RecordMisses marks the call as missed by the rung users who never joined or declined
+// it, and returns them. Called once the call has ended.
+func (r *Repository) RecordMisses(ctx context.Context, callID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := r.db.Query(ctx, `
+		UPDATE call_misses m SET missed_at = $2
+		WHERE m.call_id = $1 AND m.missed_at IS NULL
+		  AND NOT EXISTS(SELECT 1 FROM call_participants p WHERE p.call_id = m.call_id AND p.user_id = m.user_id)
+		  AND NOT EXISTS(SELECT 1 FROM call_declines d WHERE d.call_id = m.call_id AND d.user_id = m.user_id)
+		RETURNING m.user_id
+	`, callID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+}
+
+// GetMissedCallCount returns how many missed calls the user hasn't seen yet
+func (r *Repository) GetMissedCallCount(ctx context.Context, userID uuid.UUID) (int, error) {
+	var count int
+	err := r.db.QueryRow(ctx, `
+		SELECT COUNT(*) FROM call_misses
+		WHERE user_id = $1 AND missed_at IS NOT NULL AND seen_at IS NULL
+	`, userID).Scan(&count)
+	return count, err
+}
+
+// MarkMissedCallsSeen clears the user's missed-calls badge
+func (r *Repository) MarkMissedCallsSeen(ctx context.Context, userID uuid.UUID) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE call_misses SET seen_at = NOW()
+		WHERE user_id = $1 AND missed_at IS NOT NULL AND seen_at IS NULL
+	`, userID)
+	return err
+}
@@ -0,0 +1,147 @@
+package calls
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrCallNotFound     = errors.New("call not found")
+	ErrNotCallModerator = errors.New("only the call starter or group owners and admins can moderate the call")
+)
+
+// VoicePermissions are embedded in voice tokens and enforced by the SFU
+type VoicePermissions struct {
+	// CanSpeak is false for server-muted participants
+	CanSpeak bool
+	// PushToTalk requires clients to transmit only while the talk key is held
+	PushToTalk bool
+}
+
+// IsModerator reports whether the user may moderate the call: its starter or a group
+// owner or admin
+func (r *Repository) IsModerator(ctx context.Context, callID, userID uuid.UUID) (bool, error) {
+	var ok bool
+	err := r.db.QueryRow(ctx, `
+		SELECT c.started_by = $2 OR conv.owner_id = $2 OR EXISTS(
+			SELECT 1 FROM conversation_participants cp
+			WHERE cp.conversation_id = c.conversation_id AND cp.user_id = $2 AND cp.role IN ('owner', 'admin')
+		)
+		FROM calls c
+		JOIN conversations conv ON conv.id = c.conversation_id
+		WHERE c.id = $1 AND c.ended_at IS NULL
+	`, callID, userID).Scan(&ok)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, ErrCallNotFound
+	}
+	return ok, err
+}
+
+// SetServerMuted mutes or unmutes a participant for the rest of the call (survives rejoining)
+func (r *Repository) SetServerMuted(ctx context.Context, callID, moderatorID, userID uuid.UUID, muted bool) error {
+	ok, err := r.IsModerator(ctx, callID, moderatorID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotCallModerator
+	}
+
+	tag, err := r.db.Exec(ctx, `
+		UPDATE call_participants SET server_muted = $3
+		WHERE call_id = $1 AND user_id = $2
+	`, callID, userID, muted)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotInCall
+	}
+	return nil
+}
+
+// KickParticipant removes a participant from the call, from all their sessions. They
+// can join again.
+func (r *Repository) KickParticipant(ctx context.Context, callID, moderatorID, userID uuid.UUID) error {
+	ok, err := r.IsModerator(ctx, callID, moderatorID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotCallModerator
+	}
+
+	tag, err := r.db.Exec(ctx, `
+		UPDATE call_participants SET left_at = NOW()
+		WHERE call_id = $1 AND user_id = $2 AND left_at IS NULL
+	`, callID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotInCall
+	}
+	return nil
+}
+
+// SetPushToTalk switches push-to-talk mode for the whole call
+func (r *Repository) SetPushToTalk(ctx context.Context, callID, moderatorID uuid.UUID, enabled bool) error {
+	ok, err := r.IsModerator(ctx, callID, moderatorID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotCallModerator
+	}
+
+	_, err = r.db.Exec(ctx, `UPDATE calls SET push_to_talk = $2 WHERE id = $1`, callID, enabled)
+	return err
+}
+
+// GetVoicePermissions returns what a participant may do in the call, for their voice token
+func (r *Repository) GetVoicePermissions(ctx context.Context, callID, userID uuid.UUID) (VoicePermissions, error) {
+	perms := VoicePermissions{CanSpeak: true}
+	var muted bool
+	err := r.db.QueryRow(ctx, `
+		SELECT c.push_to_talk,
+			   COALESCE((SELECT server_muted FROM call_participants
+						 WHERE call_id = c.id AND user_id = $2
+						 ORDER BY joined_at DESC LIMIT 1), FALSE)
+		FROM calls c WHERE c.id = $1
+	`, callID, userID).Scan(&perms.PushToTalk, &muted)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return perms, ErrCallNotFound
+	}
+	perms.CanSpeak = !muted
+	return perms, err
+}
+
+// GetModerationState returns the call's push-to-talk mode and its server-muted active participants
+func (r *Repository) GetModerationState(ctx context.Context, callID uuid.UUID) (bool, []uuid.UUID, error) {
+	var pushToTalk bool
+	if err := r.db.QueryRow(ctx, `SELECT push_to_talk FROM calls WHERE id = $1`, callID).Scan(&pushToTalk); err != nil {
+		return false, nil, err
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT DISTINCT user_id FROM call_participants
+		WHERE call_id = $1 AND left_at IS NULL AND server_muted
+	`, callID)
+	if err != nil {
+		return false, nil, err
+	}
+	defer rows.Close()
+
+	muted := []uuid.UUID{}
+	for rows.Next() {
+		var userID uuid.UUID
+		if err := rows.Scan(&userID); err != nil {
+			return false, nil, err
+		}
+		muted = append(muted, userID)
+	}
+	return pushToTalk, muted, rows.Err()
+}
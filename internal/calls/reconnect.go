@@ -0,0 +1,154 @@
+package calls
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const reconnectSweepBatchSize = 100
+
+// Drop is a participant session that didn't reconnect within the grace period and has
+// now left
+type Drop struct {
+	CallID         uuid.UUID
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	SessionID      string
+}
+
+// DropHandler finishes a participant's departure after their reconnection grace ran out,
+// e.g. ending the call if they were the last one in it
+type DropHandler func(ctx context.Context, drop *Drop)
+
+// MarkReconnecting keeps a participant session whose connection dropped in the call
+// while it reconnects. Returns false if it isn't in the call.
+func (r *Repository) MarkReconnecting(ctx context.Context, callID, userID uuid.UUID, sessionID string) (bool, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE call_participants SET reconnecting_since = COALESCE(reconnecting_since, NOW())
+		WHERE call_id = $1 AND user_id = $2 AND session_id = $3 AND left_at IS NULL
+	`, callID, userID, sessionID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ClearReconnecting records that a reconnecting participant session is back. Returns
+// false if it wasn't reconnecting.
+func (r *Repository) ClearReconnecting(ctx context.Context, callID, userID uuid.UUID, sessionID string) (bool, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE call_participants SET reconnecting_since = NULL
+		WHERE call_id = $1 AND user_id = $2 AND session_id = $3 AND left_at IS NULL AND reconnecting_since IS NOT NULL
+	`, callID, userID, sessionID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetReconnecting returns the call's participants who are reconnecting in all their sessions
+func (r *Repository) GetReconnecting(ctx context.Context, callID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT user_id FROM call_participants
+		WHERE call_id = $1 AND left_at IS NULL
+		GROUP BY user_id
+		HAVING BOOL_AND(reconnecting_since IS NOT NULL)
+	`, callID)
+	if err != nil {
+		return nil, err
+	}
+	userIDs, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		return nil, err
+	}
+	if userIDs == nil {
+		userIDs = []uuid.UUID{}
+	}
+	return userIDs, nil
+}
+
+// ClaimExpiredReconnects marks up to limit participants who have been reconnecting for
+// longer than grace as having left when they dropped, and returns them
+func (r *Repository) ClaimExpiredReconnects(ctx context.Context, grace time.Duration, limit int) ([]*Drop, error) {
+	rows, err := r.db.Query(ctx, `
+		WITH expired AS (
+			SELECT call_id, user_id, joined_at FROM call_participants
+			WHERE left_at IS NULL AND reconnecting_since < $1
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE call_participants cp SET left_at = cp.reconnecting_since, reconnecting_since = NULL
+		FROM expired e, calls c
+		WHERE cp.call_id = e.call_id AND cp.user_id = e.user_id AND cp.joined_at = e.joined_at
+		  AND c.id = cp.call_id
+		RETURNING cp.call_id, c.conversation_id, cp.user_id, cp.session_id
+	`, time.Now().Add(-grace), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var drops []*Drop
+	for rows.Next() {
+		d := &Drop{}
+		if err := rows.Scan(&d.CallID, &d.ConversationID, &d.UserID, &d.SessionID); err != nil {
+			return nil, err
+		}
+		drops = append(drops, d)
+	}
+	return drops, rows.Err()
+}
+
+// ReconnectSweeper removes participants from calls once their reconnection grace runs out
+type ReconnectSweeper struct {
+	repo     *Repository
+	grace    time.Duration
+	onDrop   DropHandler
+	interval time.Duration
+}
+
+func NewReconnectSweeper(repo *Repository, grace time.Duration, onDrop DropHandler, interval time.Duration) *ReconnectSweeper {
+	return &ReconnectSweeper{
+		repo:     repo,
+		grace:    grace,
+		onDrop:   onDrop,
+		interval: interval,
+	}
+}
+
+// Run removes participants whose grace expired every interval until ctx is cancelled
+func (s *ReconnectSweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.sweep(ctx)
+		}
+	}
+}
+
+func (s *ReconnectSweeper) sweep(ctx context.Context) {
+	for {
+		drops, err := s.repo.ClaimExpiredReconnects(ctx, s.grace, reconnectSweepBatchSize)
+		if err != nil {
+			log.Printf("Failed to claim expired call reconnects: %v", err)
+			return
+		}
+
+		for _, drop := range drops {
+			s.onDrop(ctx, drop)
+		}
+
+		if len(drops) < reconnectSweepBatchSize {
+			return
+		}
+	}
+}
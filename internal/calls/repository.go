@@ -2,11 +2,13 @@ package calls
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/user/bla-back/internal/models"
 )
 
 type Call struct {
@@ -25,11 +27,12 @@ type Participant struct {
 }
 
 type Repository struct {
-	db *pgxpool.Pool
+	db              *pgxpool.Pool
+	maxParticipants int // 0 = unlimited
 }
 
-func NewRepository(db *pgxpool.Pool) *Repository {
-	return &Repository{db: db}
+func NewRepository(db *pgxpool.Pool, maxParticipants int) *Repository {
+	return &Repository{db: db, maxParticipants: maxParticipants}
 }
 
 // GetActiveCallForConversation returns the active call in a conversation (if any)
@@ -63,12 +66,13 @@ func (r *Repository) GetCallWithParticipants(ctx context.Context, callID uuid.UU
 		return nil, err
 	}
 
-	// Get active participants
+	// Get active participants, once per user however many sessions they have
 	rows, err := r.db.Query(ctx, `
-		SELECT user_id, joined_at, left_at
+		SELECT user_id, MIN(joined_at), NULL::timestamptz
 		FROM call_participants
 		WHERE call_id = $1 AND left_at IS NULL
-		ORDER BY joined_at
+		GROUP BY user_id
+		ORDER BY MIN(joined_at)
 	`, callID)
 	if err != nil {
 		return nil, err
@@ -87,7 +91,7 @@ func (r *Repository) GetCallWithParticipants(ctx context.Context, callID uuid.UU
 }
 
 // StartCall creates a new call in a conversation and adds the starter as first participant
-func (r *Repository) StartCall(ctx context.Context, conversationID, userID uuid.UUID) (*Call, error) {
+func (r *Repository) StartCall(ctx context.Context, conversationID, userID uuid.UUID, sessionID string) (*Call, error) {
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
 		return nil, err
@@ -111,9 +115,9 @@ func (r *Repository) StartCall(ctx context.Context, conversationID, userID uuid.
 
 	// Add starter as first participant
 	_, err = tx.Exec(ctx, `
-		INSERT INTO call_participants (call_id, user_id, joined_at)
-		VALUES ($1, $2, $3)
-	`, call.ID, userID, call.StartedAt)
+		INSERT INTO call_participants (call_id, user_id, joined_at, session_id)
+		VALUES ($1, $2, $3, $4)
+	`, call.ID, userID, call.StartedAt, sessionID)
 	if err != nil {
 		return nil, err
 	}
@@ -126,16 +130,53 @@ func (r *Repository) StartCall(ctx context.Context, conversationID, userID uuid.
 	return call, nil
 }
 
-// JoinCall adds a user to an existing call
-func (r *Repository) JoinCall(ctx context.Context, callID, userID uuid.UUID) error {
-	_, err := r.db.Exec(ctx, `
-		INSERT INTO call_participants (call_id, user_id, joined_at)
-		VALUES ($1, $2, $3)
-	`, callID, userID, time.Now())
-	return err
+// JoinCall adds a user's session to an existing call. It returns a *CallFullError if the
+// call has reached the participant limit; further sessions of a user don't take a seat.
+func (r *Repository) JoinCall(ctx context.Context, callID, userID uuid.UUID, sessionID string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := r.reserveCallSeat(ctx, tx, callID, userID); err != nil {
+		return err
+	}
+
+	// Joining answers the call if it rang them
+	if _, err := tx.Exec(ctx, `DELETE FROM call_misses WHERE call_id = $1 AND user_id = $2`, callID, userID); err != nil {
+		return err
+	}
+
+	// Rejoining from a session that's still in the call (e.g. after its connection
+	// dropped) picks it back up
+	tag, err := tx.Exec(ctx, `
+		UPDATE call_participants SET reconnecting_since = NULL, on_hold = FALSE
+		WHERE call_id = $1 AND user_id = $2 AND session_id = $3 AND left_at IS NULL
+	`, callID, userID, sessionID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		return tx.Commit(ctx)
+	}
+
+	// A server mute carries over when the user rejoins
+	_, err = tx.Exec(ctx, `
+		INSERT INTO call_participants (call_id, user_id, joined_at, session_id, server_muted)
+		VALUES ($1, $2, $3, $4, COALESCE((
+			SELECT server_muted FROM call_participants
+			WHERE call_id = $1 AND user_id = $2
+			ORDER BY joined_at DESC LIMIT 1
+		), FALSE))
+	`, callID, userID, time.Now(), sessionID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
 
-// LeaveCall marks a user as left from the call
+// LeaveCall marks a user as left from the call, from all their sessions
 func (r *Repository) LeaveCall(ctx context.Context, callID, userID uuid.UUID) error {
 	_, err := r.db.Exec(ctx, `
 		UPDATE call_participants
@@ -156,6 +197,32 @@ type CallEndInfo struct {
 	Participants   []uuid.UUID // all users who joined the call
 }
 
+// Status is "missed" when only the caller joined and hung up quickly, else "completed"
+func (i *CallEndInfo) Status() string {
+	if i.Duration < 5 && len(i.Participants) == 1 {
+		return "missed"
+	}
+	return "completed"
+}
+
+// Event returns the CALL_ENDED summary of the call
+func (i *CallEndInfo) Event() *models.CallEndedEvent {
+	participants := i.Participants
+	if participants == nil {
+		participants = []uuid.UUID{}
+	}
+	return &models.CallEndedEvent{
+		CallID:         i.CallID,
+		ConversationID: i.ConversationID,
+		StartedBy:      i.StartedBy,
+		StartedAt:      i.StartedAt,
+		EndedAt:        i.EndedAt,
+		Duration:       i.Duration,
+		Participants:   participants,
+		Status:         i.Status(),
+	}
+}
+
 // EndCall marks the call as ended and returns call info
 // Returns nil if call was already ended (race condition protection)
 func (r *Repository) EndCall(ctx context.Context, callID uuid.UUID) (*CallEndInfo, error) {
@@ -178,10 +245,13 @@ func (r *Repository) EndCall(ctx context.Context, callID uuid.UUID) (*CallEndInf
 		WHERE id = $1 AND ended_at IS NULL
 		FOR UPDATE
 	`, callID).Scan(&info.ConversationID, &info.StartedBy, &info.StartedAt)
-	if err != nil {
+	if errors.Is(err, pgx.ErrNoRows) {
 		// Call already ended or not found - this is ok, just return nil
 		return nil, nil
 	}
+	if err != nil {
+		return nil, err
+	}
 	info.Duration = int(now.Sub(info.StartedAt).Seconds())
 
 	// Get all participants who ever joined (not just active ones)
@@ -236,14 +306,14 @@ func (r *Repository) GetActiveParticipantCount(ctx context.Context, callID uuid.
 	return count, err
 }
 
-// IsUserInCall checks if a user is currently in any active call
+// IsUserInCall checks if a user is currently in any active call they don't have on hold
 func (r *Repository) IsUserInCall(ctx context.Context, userID uuid.UUID) (*Call, error) {
 	call := &Call{}
 	err := r.db.QueryRow(ctx, `
 		SELECT c.id, c.conversation_id, c.started_by, c.started_at, c.ended_at
 		FROM calls c
 		JOIN call_participants cp ON c.id = cp.call_id
-		WHERE cp.user_id = $1 AND cp.left_at IS NULL AND c.ended_at IS NULL
+		WHERE cp.user_id = $1 AND cp.left_at IS NULL AND NOT cp.on_hold AND c.ended_at IS NULL
 		LIMIT 1
 	`, userID).Scan(
 		&call.ID, &call.ConversationID, &call.StartedBy, &call.StartedAt, &call.EndedAt,
@@ -260,7 +330,7 @@ func (r *Repository) IsUserInCall(ctx context.Context, userID uuid.UUID) (*Call,
 // GetActiveParticipants returns list of user IDs currently in the call
 func (r *Repository) GetActiveParticipants(ctx context.Context, callID uuid.UUID) ([]uuid.UUID, error) {
 	rows, err := r.db.Query(ctx, `
-		SELECT user_id FROM call_participants
+		SELECT DISTINCT user_id FROM call_participants
 		WHERE call_id = $1 AND left_at IS NULL
 	`, callID)
 	if err != nil {
@@ -304,9 +374,10 @@ func (r *Repository) GetActiveCallsForConversations(ctx context.Context, convers
 
 		// Get participants for this call
 		participantRows, err := r.db.Query(ctx, `
-			SELECT user_id, joined_at, left_at
+			SELECT user_id, MIN(joined_at), NULL::timestamptz
 			FROM call_participants
 			WHERE call_id = $1 AND left_at IS NULL
+			GROUP BY user_id
 		`, call.ID)
 		if err != nil {
 			return nil, err
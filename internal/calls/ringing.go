@@ -0,0 +1,219 @@
+package calls
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/user/bla-back/internal/models"
+)
+
+// Ring stop reasons sent with CALL_RING_STOP
+const (
+	RingAnswered = "answered"
+	RingDeclined = "declined"
+	RingTimeout  = "timeout"
+	RingEnded    = "ended"
+)
+
+const ringSweepBatchSize = 100
+
+var (
+	ErrNotRinging = errors.New("call is not ringing")
+	ErrNotRingee  = errors.New("only participants the call rang can decline it")
+)
+
+// Ring identifies a call whose ringing stopped
+type Ring struct {
+	CallID         uuid.UUID
+	ConversationID uuid.UUID
+	StartedBy      uuid.UUID
+}
+
+// pendingRingee matches conversation participants still being rung for the call c:
+// everyone but the caller (or only the ring targets) who hasn't joined or declined
+const pendingRingee = `
+	SELECT 1 FROM conversation_participants cp
+	WHERE cp.conversation_id = c.conversation_id AND cp.user_id <> c.started_by
+	  AND (c.ring_targets IS NULL OR cp.user_id = ANY(c.ring_targets))
+	  AND NOT EXISTS(SELECT 1 FROM call_declines d WHERE d.call_id = c.id AND d.user_id = cp.user_id)
+	  AND NOT EXISTS(SELECT 1 FROM call_participants p WHERE p.call_id = c.id AND p.user_id = cp.user_id)`
+
+// StartRinging marks a new call as ringing until the timeout passes. With targets only
+// those participants are rung; nil rings everyone. Returns when ringing stops.
+func (r *Repository) StartRinging(ctx context.Context, callID uuid.UUID, targets []uuid.UUID, timeout time.Duration) (time.Time, error) {
+	var until time.Time
+	err := r.db.QueryRow(ctx, `
+		UPDATE calls SET ringing_until = $2, ring_targets = $3
+		WHERE id = $1 AND ended_at IS NULL
+		RETURNING ringing_until
+	`, callID, time.Now().Add(timeout), targets).Scan(&until)
+	return until, err
+}
+
+// IsRinging reports whether the call is still ringing
+func (r *Repository) IsRinging(ctx context.Context, callID uuid.UUID) (bool, error) {
+	var ringing bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM calls WHERE id = $1 AND ended_at IS NULL AND ringing_until > NOW())
+	`, callID).Scan(&ringing)
+	return ringing, err
+}
+
+// DeclineCall records that a rung participant won't answer the call
+func (r *Repository) DeclineCall(ctx context.Context, callID, userID uuid.UUID) (*Call, error) {
+	call := &Call{}
+	var ringing, ringee bool
+	err := r.db.QueryRow(ctx, `
+		SELECT c.id, c.conversation_id, c.started_by, c.started_at, c.ended_at,
+			   c.ended_at IS NULL AND c.ringing_until > NOW(),
+			   c.started_by <> $2 AND (c.ring_targets IS NULL OR $2 = ANY(c.ring_targets)) AND EXISTS(
+				   SELECT 1 FROM conversation_participants WHERE conversation_id = c.conversation_id AND user_id = $2
+			   )
+		FROM calls c WHERE c.id = $1
+	`, callID, userID).Scan(&call.ID, &call.ConversationID, &call.StartedBy, &call.StartedAt, &call.EndedAt, &ringing, &ringee)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrCallNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !ringee {
+		return nil, ErrNotRingee
+	}
+	if !ringing {
+		return nil, ErrNotRinging
+	}
+
+	_, err = r.db.Exec(ctx, `
+		INSERT INTO call_declines (call_id, user_id) VALUES ($1, $2)
+		ON CONFLICT (call_id, user_id) DO NOTHING
+	`, callID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return call, nil
+}
+
+// StopRingingIfSettled stops ringing once nobody is left to answer. answered reports
+// whether anyone besides the caller joined.
+func (r *Repository) StopRingingIfSettled(ctx context.Context, callID uuid.UUID) (stopped, answered bool, err error) {
+	err = r.db.QueryRow(ctx, `
+		UPDATE calls c SET ringing_until = NULL
+		WHERE c.id = $1 AND c.ringing_until IS NOT NULL AND NOT EXISTS(`+pendingRingee+`)
+		RETURNING EXISTS(SELECT 1 FROM call_participants p WHERE p.call_id = c.id AND p.user_id <> c.started_by)
+	`, callID).Scan(&answered)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	return true, answered, nil
+}
+
+// StopRinging stops ringing for an ending call. Returns false if it wasn't ringing.
+func (r *Repository) StopRinging(ctx context.Context, callID uuid.UUID) (bool, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE calls SET ringing_until = NULL WHERE id = $1 AND ringing_until IS NOT NULL
+	`, callID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ClaimExpiredRings stops ringing for up to limit calls whose ring timed out and returns them
+func (r *Repository) ClaimExpiredRings(ctx context.Context, limit int) ([]*Ring, error) {
+	rows, err := r.db.Query(ctx, `
+		UPDATE calls SET ringing_until = NULL
+		WHERE id IN (
+			SELECT id FROM calls WHERE ringing_until <= NOW()
+			ORDER BY ringing_until
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, conversation_id, started_by
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rings []*Ring
+	for rows.Next() {
+		ring := &Ring{}
+		if err := rows.Scan(&ring.CallID, &ring.ConversationID, &ring.StartedBy); err != nil {
+			return nil, err
+		}
+		rings = append(rings, ring)
+	}
+	return rings, rows.Err()
+}
+
+// ParticipantLister resolves a conversation's participants
+type ParticipantLister interface {
+	GetConversationParticipantIDs(ctx context.Context, convID uuid.UUID) ([]uuid.UUID, error)
+}
+
+// RingSweeper stops calls from ringing once their ring timeout passes
+type RingSweeper struct {
+	repo         *Repository
+	participants ParticipantLister
+	publisher    Publisher
+	interval     time.Duration
+}
+
+func NewRingSweeper(repo *Repository, participants ParticipantLister, publisher Publisher, interval time.Duration) *RingSweeper {
+	return &RingSweeper{
+		repo:         repo,
+		participants: participants,
+		publisher:    publisher,
+		interval:     interval,
+	}
+}
+
+// Run stops timed-out rings every interval until ctx is cancelled
+func (s *RingSweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.sweep(ctx)
+		}
+	}
+}
+
+func (s *RingSweeper) sweep(ctx context.Context) {
+	for {
+		rings, err := s.repo.ClaimExpiredRings(ctx, ringSweepBatchSize)
+		if err != nil {
+			log.Printf("Failed to claim expired call rings: %v", err)
+			return
+		}
+
+		for _, ring := range rings {
+			participantIDs, err := s.participants.GetConversationParticipantIDs(ctx, ring.ConversationID)
+			if err != nil {
+				log.Printf("Failed to get participants for call %s: %v", ring.CallID, err)
+				continue
+			}
+			s.publisher.PublishToUsers(participantIDs, "CALL_RING_STOP", &models.CallRingStopEvent{
+				CallID:         ring.CallID,
+				ConversationID: ring.ConversationID,
+				Reason:         RingTimeout,
+			})
+		}
+
+		if len(rings) < ringSweepBatchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package calls
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/user/bla-back/internal/models"
+)
+
+var (
+	ErrScheduledCallNotFound      = errors.New("scheduled call not found")
+	ErrNotConversationParticipant = errors.New("not a participant of this conversation")
+	ErrCannotCancelScheduledCall  = errors.New("only the organizer or group owner can cancel a scheduled call")
+)
+
+// ScheduledCallJoinURL is the web link that opens the conversation and joins its call
+func ScheduledCallJoinURL(appURL string, sc *models.ScheduledCall) string {
+	return appURL + "/conversations/" + sc.ConversationID.String() + "?scheduled_call=" + sc.ID.String()
+}
+
+const scheduledCallColumns = `id, conversation_id, created_by, title, starts_at, call_id, created_at`
+
+func scanScheduledCall(row pgx.Row) (*models.ScheduledCall, error) {
+	sc := &models.ScheduledCall{}
+	err := row.Scan(&sc.ID, &sc.ConversationID, &sc.CreatedBy, &sc.Title, &sc.StartsAt, &sc.CallID, &sc.CreatedAt)
+	return sc, err
+}
+
+// ScheduleCall plans a call in a conversation the user belongs to
+func (r *Repository) ScheduleCall(ctx context.Context, conversationID, userID uuid.UUID, title string, startsAt time.Time) (*models.ScheduledCall, error) {
+	sc, err := scanScheduledCall(r.db.QueryRow(ctx, `
+		INSERT INTO scheduled_calls (conversation_id, created_by, title, starts_at)
+		SELECT $1, $2, $3, $4
+		WHERE EXISTS(SELECT 1 FROM conversation_participants WHERE conversation_id = $1 AND user_id = $2)
+		RETURNING `+scheduledCallColumns,
+		conversationID, userID, title, startsAt))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotConversationParticipant
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
+// GetScheduledCalls returns the conversation's calls that haven't started yet, soonest first
+func (r *Repository) GetScheduledCalls(ctx context.Context, conversationID, userID uuid.UUID) ([]*models.ScheduledCall, error) {
+	var isParticipant bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM conversation_participants WHERE conversation_id = $1 AND user_id = $2)
+	`, conversationID, userID).Scan(&isParticipant)
+	if err != nil {
+		return nil, err
+	}
+	if !isParticipant {
+		return nil, ErrNotConversationParticipant
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT `+scheduledCallColumns+`
+		FROM scheduled_calls
+		WHERE conversation_id = $1 AND started_at IS NULL
+		ORDER BY starts_at
+	`, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scheduled := []*models.ScheduledCall{}
+	for rows.Next() {
+		sc, err := scanScheduledCall(rows)
+		if err != nil {
+			return nil, err
+		}
+		scheduled = append(scheduled, sc)
+	}
+	return scheduled, rows.Err()
+}
+
+// CancelScheduledCall removes a call that hasn't started; allowed for its organizer and the group owner
+func (r *Repository) CancelScheduledCall(ctx context.Context, id, userID uuid.UUID) (*models.ScheduledCall, error) {
+	var allowed bool
+	err := r.db.QueryRow(ctx, `
+		SELECT sc.created_by = $2 OR c.owner_id = $2
+		FROM scheduled_calls sc
+		JOIN conversations c ON c.id = sc.conversation_id
+		WHERE sc.id = $1 AND sc.started_at IS NULL
+	`, id, userID).Scan(&allowed)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrScheduledCallNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, ErrCannotCancelScheduledCall
+	}
+
+	sc, err := scanScheduledCall(r.db.QueryRow(ctx, `
+		DELETE FROM scheduled_calls WHERE id = $1 AND started_at IS NULL
+		RETURNING `+scheduledCallColumns, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Started in the meantime
+		return nil, ErrScheduledCallNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
+// ClaimDueReminders marks and returns scheduled calls starting within lead that haven't been announced.
+// SKIP LOCKED lets several instances run the scheduler without double reminders.
+func (r *Repository) ClaimDueReminders(ctx context.Context, lead time.Duration, limit int) ([]*models.ScheduledCall, error) {
+	rows, err := r.db.Query(ctx, `
+		UPDATE scheduled_calls SET reminded_at = NOW()
+		WHERE id IN (
+			SELECT id FROM scheduled_calls
+			WHERE reminded_at IS NULL AND started_at IS NULL AND starts_at <= $1
+			  AND conversation_id NOT IN (SELECT id FROM conversations WHERE deleted_at IS NOT NULL)
+			ORDER BY starts_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+scheduledCallColumns,
+		time.Now().Add(lead), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []*models.ScheduledCall
+	for rows.Next() {
+		sc, err := scanScheduledCall(rows)
+		if err != nil {
+			return nil, err
+		}
+		due = append(due, sc)
+	}
+	return due, rows.Err()
+}
+
+// StartNextDueCall opens the call for one scheduled call whose start time has passed.
+// If the conversation already has an active call, the scheduled call is linked to it.
+// Returns nil when nothing is due.
+func (r *Repository) StartNextDueCall(ctx context.Context) (*models.ScheduledCall, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	sc, err := scanScheduledCall(tx.QueryRow(ctx, `
+		SELECT `+scheduledCallColumns+`
+		FROM scheduled_calls
+		WHERE started_at IS NULL AND starts_at <= NOW()
+		  AND conversation_id NOT IN (SELECT id FROM conversations WHERE deleted_at IS NOT NULL)
+		ORDER BY starts_at
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED
+	`))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var callID uuid.UUID
+	err = tx.QueryRow(ctx, `
+		SELECT id FROM calls
+		WHERE conversation_id = $1 AND ended_at IS NULL
+		ORDER BY started_at DESC
+		LIMIT 1
+	`, sc.ConversationID).Scan(&callID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Nobody is in the call yet; the organizer is recorded as its starter
+		callID = uuid.New()
+		_, err = tx.Exec(ctx, `
+			INSERT INTO calls (id, conversation_id, started_by, started_at)
+			VALUES ($1, $2, $3, NOW())
+		`, callID, sc.ConversationID, sc.CreatedBy)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE scheduled_calls SET started_at = NOW(), call_id = $2 WHERE id = $1
+	`, sc.ID, callID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	sc.CallID = &callID
+	return sc, nil
+}
+
+// EndAbandonedScheduledCalls ends scheduled calls nobody joined within window of opening
+func (r *Repository) EndAbandonedScheduledCalls(ctx context.Context, window time.Duration) ([]*CallEndInfo, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT c.id
+		FROM scheduled_calls sc
+		JOIN calls c ON c.id = sc.call_id
+		WHERE c.ended_at IS NULL AND sc.started_at < $1
+		  AND NOT EXISTS(SELECT 1 FROM call_participants WHERE call_id = c.id)
+	`, time.Now().Add(-window))
+	if err != nil {
+		return nil, err
+	}
+	callIDs, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		return nil, err
+	}
+
+	var ended []*CallEndInfo
+	for _, callID := range callIDs {
+		// Someone may have joined since the query
+		if count, err := r.GetActiveParticipantCount(ctx, callID); err != nil || count > 0 {
+			continue
+		}
+		info, err := r.EndCall(ctx, callID)
+		if err != nil {
+			return ended, err
+		}
+		if info != nil {
+			ended = append(ended, info)
+		}
+	}
+	return ended, nil
+}
@@ -0,0 +1,164 @@
+package calls
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/models"
+)
+
+const (
+	// ScheduledCallReminderLead is how long before the start the reminder message is posted
+	ScheduledCallReminderLead = 15 * time.Minute
+	// scheduledCallJoinWindow is how long an opened scheduled call waits for someone to join
+	scheduledCallJoinWindow = 30 * time.Minute
+	schedulerBatchSize      = 100
+)
+
+// Publisher sends realtime events to users
+type Publisher interface {
+	PublishToUsers(userIDs []uuid.UUID, eventType string, data interface{})
+}
+
+// ConversationMessenger posts system messages and resolves conversation participants
+type ConversationMessenger interface {
+	GetConversationParticipantIDs(ctx context.Context, convID uuid.UUID) ([]uuid.UUID, error)
+	CreateCallReminderMessage(ctx context.Context, convID, senderID uuid.UUID, content string) (*models.Message, error)
+}
+
+// Scheduler posts reminders for scheduled calls and opens them at their start time
+type Scheduler struct {
+	repo      *Repository
+	messages  ConversationMessenger
+	publisher Publisher
+	appURL    string
+	interval  time.Duration
+}
+
+func NewScheduler(repo *Repository, messages ConversationMessenger, publisher Publisher, appURL string, interval time.Duration) *Scheduler {
+	return &Scheduler{
+		repo:      repo,
+		messages:  messages,
+		publisher: publisher,
+		appURL:    appURL,
+		interval:  interval,
+	}
+}
+
+// Run checks for due scheduled calls every interval until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.sendReminders(ctx)
+			s.startDueCalls(ctx)
+			s.endAbandonedCalls(ctx)
+		}
+	}
+}
+
+func (s *Scheduler) sendReminders(ctx context.Context) {
+	due, err := s.repo.ClaimDueReminders(ctx, ScheduledCallReminderLead, schedulerBatchSize)
+	if err != nil {
+		log.Printf("Failed to claim scheduled call reminders: %v", err)
+		return
+	}
+
+	for _, sc := range due {
+		content, err := json.Marshal(models.CallReminderContent{
+			ScheduledCallID: sc.ID.String(),
+			Title:           sc.Title,
+			StartsAt:        sc.StartsAt,
+			JoinURL:         ScheduledCallJoinURL(s.appURL, sc),
+		})
+		if err != nil {
+			continue
+		}
+
+		msg, err := s.messages.CreateCallReminderMessage(ctx, sc.ConversationID, sc.CreatedBy, string(content))
+		if err != nil {
+			log.Printf("Failed to post reminder for scheduled call %s: %v", sc.ID, err)
+			continue
+		}
+
+		participantIDs, _ := s.messages.GetConversationParticipantIDs(ctx, sc.ConversationID)
+		s.publisher.PublishToUsers(participantIDs, "MESSAGE_CREATE", &models.MessageCreateEvent{
+			Message:        msg,
+			ConversationID: sc.ConversationID,
+		})
+	}
+}
+
+func (s *Scheduler) startDueCalls(ctx context.Context) {
+	for i := 0; i < schedulerBatchSize; i++ {
+		sc, err := s.repo.StartNextDueCall(ctx)
+		if err != nil {
+			log.Printf("Failed to start scheduled call: %v", err)
+			return
+		}
+		if sc == nil {
+			return
+		}
+
+		participants, _ := s.repo.GetActiveParticipants(ctx, *sc.CallID)
+		if participants == nil {
+			participants = []uuid.UUID{}
+		}
+		event := models.CallStateEvent{
+			ConversationID:  sc.ConversationID,
+			CallID:          sc.CallID,
+			Participants:    participants,
+			ServerMuted:     []uuid.UUID{},
+			ScheduledCallID: &sc.ID,
+			Media:           []models.CallMediaState{},
+			Reconnecting:    []uuid.UUID{},
+			Devices:         map[uuid.UUID]int{},
+			OnHold:          []uuid.UUID{},
+		}
+		if pushToTalk, muted, err := s.repo.GetModerationState(ctx, *sc.CallID); err == nil {
+			event.PushToTalk = pushToTalk
+			event.ServerMuted = muted
+		}
+		if media, err := s.repo.GetMediaStates(ctx, *sc.CallID); err == nil {
+			event.Media = media
+		}
+		if devices, err := s.repo.GetDeviceCounts(ctx, *sc.CallID); err == nil {
+			event.Devices = devices
+		}
+		if onHold, err := s.repo.GetOnHold(ctx, *sc.CallID); err == nil {
+			event.OnHold = onHold
+		}
+
+		participantIDs, _ := s.messages.GetConversationParticipantIDs(ctx, sc.ConversationID)
+		s.publisher.PublishToUsers(participantIDs, "CALL_STATE", event)
+	}
+}
+
+func (s *Scheduler) endAbandonedCalls(ctx context.Context) {
+	ended, err := s.repo.EndAbandonedScheduledCalls(ctx, scheduledCallJoinWindow)
+	if err != nil {
+		log.Printf("Failed to end abandoned scheduled calls: %v", err)
+	}
+
+	for _, info := range ended {
+		participantIDs, _ := s.messages.GetConversationParticipantIDs(ctx, info.ConversationID)
+		s.publisher.PublishToUsers(participantIDs, "CALL_ENDED", info.Event())
+		s.publisher.PublishToUsers(participantIDs, "CALL_STATE", models.CallStateEvent{
+			ConversationID: info.ConversationID,
+			Participants:   []uuid.UUID{},
+			ServerMuted:    []uuid.UUID{},
+			Media:          []models.CallMediaState{},
+			Reconnecting:   []uuid.UUID{},
+			Devices:        map[uuid.UUID]int{},
+			OnHold:         []uuid.UUID{},
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package calls
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// A user can be in a call from several devices at once. Each device joins with its own
+// session ID and gets its own call_participants row and voice identity. Clients that
+// don't send one share the empty session, which behaves like a single device.
+
+var ErrInvalidSession = errors.New("session_id must be 1-64 letters, digits, '-' or '_'")
+
+var sessionIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ValidSessionID reports whether a client-chosen session ID is usable ("" is)
+func ValidSessionID(sessionID string) bool {
+	return sessionID == "" || sessionIDPattern.MatchString(sessionID)
+}
+
+// SessionIdentity is the participant identity of a session on the voice backend:
+// the user ID, followed by ":<session>" for named sessions
+func SessionIdentity(userID uuid.UUID, sessionID string) string {
+	if sessionID == "" {
+		return userID.String()
+	}
+	return userID.String() + ":" + sessionID
+}
+
+// ParseIdentity splits a voice backend identity into the user and session
+func ParseIdentity(identity string) (uuid.UUID, string, error) {
+	user, session, _ := strings.Cut(identity, ":")
+	userID, err := uuid.Parse(user)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	return userID, session, nil
+}
+
+// IsActiveSession reports whether the user is in the (ongoing) call from the session
+func (r *Repository) IsActiveSession(ctx context.Context, callID, userID uuid.UUID, sessionID string) (bool, error) {
+	var ok bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM call_participants cp
+			JOIN calls c ON c.id = cp.call_id
+			WHERE cp.call_id = $1 AND cp.user_id = $2 AND cp.session_id = $3
+			  AND cp.left_at IS NULL AND c.ended_at IS NULL
+		)
+	`, callID, userID, sessionID).Scan(&ok)
+	return ok, err
+}
+
+// LeaveSession marks one of the user's sessions as left from the call
+func (r *Repository) LeaveSession(ctx context.Context, callID, userID uuid.UUID, sessionID string) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE call_participants SET left_at = NOW()
+		WHERE call_id = $1 AND user_id = $2 AND session_id = $3 AND left_at IS NULL
+	`, callID, userID, sessionID)
+	return err
+}
+
+// GetActiveSessions returns the sessions the user is in the call from
+func (r *Repository) GetActiveSessions(ctx context.Context, callID, userID uuid.UUID) ([]string, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT session_id FROM call_participants
+		WHERE call_id = $1 AND user_id = $2 AND left_at IS NULL
+	`, callID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// HandOff moves the user's participation to one session: every other session of theirs
+// leaves the call. Returns the sessions that left.
+func (r *Repository) HandOff(ctx context.Context, callID, userID uuid.UUID, sessionID string) ([]string, error) {
+	rows, err := r.db.Query(ctx, `
+		UPDATE call_participants SET left_at = NOW(), reconnecting_since = NULL
+		WHERE call_id = $1 AND user_id = $2 AND session_id <> $3 AND left_at IS NULL
+		RETURNING session_id
+	`, callID, userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// GetDeviceCounts returns how many sessions each active participant is in the call from
+func (r *Repository) GetDeviceCounts(ctx context.Context, callID uuid.UUID) (map[uuid.UUID]int, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT user_id, COUNT(*) FROM call_participants
+		WHERE call_id = $1 AND left_at IS NULL
+		GROUP BY user_id
+	`, callID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[uuid.UUID]int{}
+	for rows.Next() {
+		var userID uuid.UUID
+		var count int
+		if err := rows.Scan(&userID, &count); err != nil {
+			return nil, err
+		}
+		counts[userID] = count
+	}
+	return counts, rows.Err()
+}
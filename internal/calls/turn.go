@@ -0,0 +1,59 @@
+package calls
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TURNConfig describes the TURN servers clients can relay media through. Credentials
+// follow the coturn REST API scheme (use-auth-secret / static-auth-secret).
+type TURNConfig struct {
+	// turn:/turns: URIs handed to clients
+	URIs []string
+	// Shared secret, must match coturn's static-auth-secret
+	Secret string
+	// How long issued credentials stay valid (default 1h)
+	TTL time.Duration
+}
+
+// TURNCredentials are short-lived credentials for the configured TURN servers
+type TURNCredentials struct {
+	Username  string    `json:"username"`
+	Password  string    `json:"password"`
+	TTL       int       `json:"ttl"` // seconds
+	ExpiresAt time.Time `json:"expires_at"`
+	URIs      []string  `json:"uris"`
+}
+
+// Enabled reports whether TURN credentials can be issued
+func (c TURNConfig) Enabled() bool {
+	return c.Secret != "" && len(c.URIs) > 0
+}
+
+// Credentials issues TURN credentials for the user. The username is
+// "<expiry unix time>:<user id>" and the password is the base64 HMAC-SHA1 of the
+// username keyed with the shared secret, so coturn can check them without a database.
+func (c TURNConfig) Credentials(userID uuid.UUID, now time.Time) *TURNCredentials {
+	ttl := c.TTL
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	expiresAt := now.Add(ttl).Truncate(time.Second)
+	username := strconv.FormatInt(expiresAt.Unix(), 10) + ":" + userID.String()
+
+	mac := hmac.New(sha1.New, []byte(c.Secret))
+	mac.Write([]byte(username))
+
+	return &TURNCredentials{
+		Username:  username,
+		Password:  base64.StdEncoding.EncodeToString(mac.Sum(nil)),
+		TTL:       int(ttl.Seconds()),
+		ExpiresAt: expiresAt,
+		URIs:      c.URIs,
+	}
+}
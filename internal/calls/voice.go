@@ -1,9 +1,17 @@
 package calls
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 type VoiceConfig struct {
@@ -11,32 +19,66 @@ type VoiceConfig struct {
 	Host string
 	// JWT secret (must match SFU's secret)
 	JWTSecret string
+	// HTTP base URL of the SFU's control API (empty = moderation only takes
+	// effect through newly issued tokens)
+	APIURL string
+	// How long a participant token can be used to connect (default 10m)
+	TokenTTL time.Duration
+	// Tolerated clock difference between this server and the SFU
+	ClockSkew time.Duration
+	// Secret the SFU signs webhooks with, separate from JWTSecret (empty = webhooks are rejected)
+	WebhookSecret string
 }
 
 type VoiceService struct {
 	config VoiceConfig
+	client *http.Client
+
+	// Optional verification hooks, see voice_tokens.go
+	replay       ReplayGuard
+	roomVerifier RoomVerifier
 }
 
 // VoiceClaims represents the JWT claims for voice authentication
 type VoiceClaims struct {
-	RoomID   string `json:"room_id"`
-	UserID   string `json:"user_id"`
-	Username string `json:"username"`
+	RoomID     string `json:"room_id"`
+	UserID     string `json:"user_id"`
+	Username   string `json:"username"`
+	CanSpeak   bool   `json:"can_speak"`
+	PushToTalk bool   `json:"push_to_talk"`
+	jwt.RegisteredClaims
+}
+
+// controlClaims authenticate server-to-SFU control requests
+type controlClaims struct {
+	Admin bool `json:"admin"`
 	jwt.RegisteredClaims
 }
 
 func NewVoiceService(config VoiceConfig) *VoiceService {
-	return &VoiceService{config: config}
+	if config.TokenTTL <= 0 {
+		config.TokenTTL = defaultVoiceTokenTTL
+	}
+	return &VoiceService{
+		config: config,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
 }
 
-func (s *VoiceService) GenerateToken(roomName, userID, username string) (string, error) {
+func (s *VoiceService) GenerateToken(roomName, userID, username string, perms VoicePermissions) (string, error) {
+	now := time.Now()
 	claims := VoiceClaims{
-		RoomID:   roomName,
-		UserID:   userID,
-		Username: username,
+		RoomID:     roomName,
+		UserID:     userID,
+		Username:   username,
+		CanSpeak:   perms.CanSpeak,
+		PushToTalk: perms.PushToTalk,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        uuid.NewString(),
+			Subject:   userID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.config.TokenTTL)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
@@ -47,3 +89,92 @@ func (s *VoiceService) GenerateToken(roomName, userID, username string) (string,
 func (s *VoiceService) GetWebSocketURL() string {
 	return s.config.Host
 }
+
+func (s *VoiceService) Name() string {
+	return BackendSFU
+}
+
+// SetParticipantMuted tells the SFU to stop (or resume) forwarding a participant's audio
+func (s *VoiceService) SetParticipantMuted(ctx context.Context, roomName, userID string, muted bool) error {
+	body, _ := json.Marshal(map[string]bool{"muted": muted})
+	return s.control(ctx, http.MethodPost,
+		fmt.Sprintf("/rooms/%s/participants/%s/mute", url.PathEscape(roomName), url.PathEscape(userID)), body)
+}
+
+// RemoveParticipant tells the SFU to disconnect a participant
+func (s *VoiceService) RemoveParticipant(ctx context.Context, roomName, userID string) error {
+	return s.control(ctx, http.MethodDelete,
+		fmt.Sprintf("/rooms/%s/participants/%s", url.PathEscape(roomName), url.PathEscape(userID)), nil)
+}
+
+// CloseRoom tells the SFU to disconnect everyone in the room
+func (s *VoiceService) CloseRoom(ctx context.Context, roomName string) error {
+	return s.control(ctx, http.MethodDelete, "/rooms/"+url.PathEscape(roomName), nil)
+}
+
+// ReceiveWebhook decodes an event the SFU posts, signed as described in voice_webhooks.go
+func (s *VoiceService) ReceiveWebhook(r *http.Request) (*VoiceEvent, error) {
+	raw, err := s.verifyWebhook(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var body struct {
+		Event    string `json:"event"`
+		Room     string `json:"room"`
+		UserID   string `json:"user_id"`
+		Speaking bool   `json:"speaking"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil, err
+	}
+
+	// A dropped connection ends the participant's presence like a clean leave
+	if body.Event == "participant_disconnected" {
+		body.Event = VoiceEventParticipantLeft
+	}
+	return &VoiceEvent{Type: body.Event, RoomName: body.Room, UserID: body.UserID, Speaking: body.Speaking}, nil
+}
+
+// control sends a request to the SFU's control API. Without an API URL it does nothing,
+// and a 404 (room or participant not connected) isn't an error.
+func (s *VoiceService) control(ctx context.Context, method, path string, body []byte) error {
+	if s.config.APIURL == "" {
+		return nil
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, controlClaims{
+		Admin: true,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}).SignedString([]byte(s.config.JWTSecret))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(s.config.APIURL, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	// Not connected to the SFU right now; a mute flag still applies on the next token
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("voice API: unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
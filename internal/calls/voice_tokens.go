@@ -0,0 +1,143 @@
+package calls
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/cache"
+)
+
+const defaultVoiceTokenTTL = 10 * time.Minute
+
+var (
+	ErrInvalidVoiceToken  = errors.New("invalid voice token")
+	ErrVoiceTokenRoom     = errors.New("voice token was issued for a different room")
+	ErrVoiceTokenReplayed = errors.New("voice token has already been used")
+	ErrNotVoiceControl    = errors.New("not a voice control token")
+)
+
+// ReplayGuard records token IDs so each voice token can only be redeemed once.
+// Claim reports false if the ID was already claimed; it must be remembered until at least `until`.
+type ReplayGuard interface {
+	Claim(ctx context.Context, jti string, until time.Time) (bool, error)
+}
+
+// RedisReplayGuard shares redeemed token IDs across server instances
+type RedisReplayGuard struct {
+	cache *cache.RedisCache
+}
+
+func NewRedisReplayGuard(cache *cache.RedisCache) *RedisReplayGuard {
+	return &RedisReplayGuard{cache: cache}
+}
+
+func (g *RedisReplayGuard) Claim(ctx context.Context, jti string, until time.Time) (bool, error) {
+	ttl := time.Until(until)
+	if ttl <= 0 {
+		// Already past expiry, so it can't be accepted again anyway
+		return true, nil
+	}
+	return g.cache.ClaimOnce(ctx, cache.VoiceTokenKey(jti), ttl)
+}
+
+// RoomVerifier checks that a user may still join a room when their token is redeemed,
+// e.g. that they haven't left the call or been removed from the conversation since it was issued
+type RoomVerifier func(ctx context.Context, roomName, userID string) error
+
+// SetReplayGuard enables single-use tokens. Without one, tokens can be reused until they expire.
+func (s *VoiceService) SetReplayGuard(guard ReplayGuard) {
+	s.replay = guard
+}
+
+// SetRoomVerifier adds a check run against the room and user on every token redemption
+func (s *VoiceService) SetRoomVerifier(verify RoomVerifier) {
+	s.roomVerifier = verify
+}
+
+// VerifyToken validates a participant token the SFU received for roomName and redeems it.
+// Expiry and not-before are checked with ClockSkew leeway.
+func (s *VoiceService) VerifyToken(ctx context.Context, tokenString, roomName string) (*VoiceClaims, error) {
+	claims := &VoiceClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return []byte(s.config.JWTSecret), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithLeeway(s.config.ClockSkew),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	// Tokens issued before IDs were added have no jti and can't be tracked
+	if err != nil || claims.ID == "" || claims.NotBefore == nil {
+		return nil, ErrInvalidVoiceToken
+	}
+
+	if claims.RoomID != roomName {
+		return nil, ErrVoiceTokenRoom
+	}
+	if s.roomVerifier != nil {
+		if err := s.roomVerifier(ctx, roomName, claims.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.replay != nil {
+		// Keep the ID past expiry by the skew, since the token is still accepted until then
+		ok, err := s.replay.Claim(ctx, claims.ID, claims.ExpiresAt.Add(s.config.ClockSkew))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrVoiceTokenReplayed
+		}
+	}
+
+	return claims, nil
+}
+
+// VerifyControl authenticates a request from the SFU, which signs the same admin
+// claims this server uses for control API calls
+func (s *VoiceService) VerifyControl(authHeader string) error {
+	tokenString, ok := strings.CutPrefix(authHeader, "Bearer ")
+	if !ok {
+		return ErrNotVoiceControl
+	}
+
+	claims := &controlClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return []byte(s.config.JWTSecret), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithLeeway(s.config.ClockSkew),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || !claims.Admin {
+		return ErrNotVoiceControl
+	}
+	return nil
+}
+
+// VerifyVoiceRoom is the RoomVerifier for call rooms ("call-<id>"): the session named
+// by the participant identity must still be in the ongoing call
+func (r *Repository) VerifyVoiceRoom(ctx context.Context, roomName, userID string) error {
+	callID, err := uuid.Parse(strings.TrimPrefix(roomName, "call-"))
+	if err != nil {
+		return ErrCallNotFound
+	}
+	uid, sessionID, err := ParseIdentity(userID)
+	if err != nil {
+		return ErrNotInCall
+	}
+
+	ok, err := r.IsActiveSession(ctx, callID, uid, sessionID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotInCall
+	}
+	return nil
+}
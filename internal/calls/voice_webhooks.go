@@ -0,0 +1,67 @@
+package calls
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// SFU webhooks are signed with HMAC-SHA256 over "<timestamp>.<body>" using the webhook
+// secret. The timestamp (Unix seconds) and hex signature travel in these headers.
+const (
+	VoiceTimestampHeader = "X-Voice-Timestamp"
+	VoiceSignatureHeader = "X-Voice-Signature"
+)
+
+const (
+	// voiceWebhookTolerance is how old a signed webhook may be before it's rejected as a replay
+	voiceWebhookTolerance = 5 * time.Minute
+	maxVoiceWebhookSize   = 64 << 10
+)
+
+var (
+	ErrInvalidWebhookSignature = errors.New("invalid voice webhook signature")
+	ErrWebhookSecretUnset      = errors.New("voice webhooks are disabled: no webhook secret is configured")
+)
+
+// SignVoiceWebhook returns the signature of a webhook body sent at the given time
+func SignVoiceWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyWebhook reads the body of a webhook from the SFU and checks its signature and age.
+// Without a webhook secret every webhook is rejected, since an empty key signs nothing.
+func (s *VoiceService) verifyWebhook(r *http.Request) ([]byte, error) {
+	if s.config.WebhookSecret == "" {
+		return nil, ErrWebhookSecretUnset
+	}
+
+	timestamp, err := strconv.ParseInt(r.Header.Get(VoiceTimestampHeader), 10, 64)
+	if err != nil {
+		return nil, ErrInvalidWebhookSignature
+	}
+	age := time.Since(time.Unix(timestamp, 0))
+	if age > voiceWebhookTolerance+s.config.ClockSkew || age < -s.config.ClockSkew {
+		return nil, ErrInvalidWebhookSignature
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxVoiceWebhookSize))
+	if err != nil {
+		return nil, err
+	}
+
+	expected := SignVoiceWebhook(s.config.WebhookSecret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(r.Header.Get(VoiceSignatureHeader))) {
+		return nil, ErrInvalidWebhookSignature
+	}
+	return body, nil
+}
@@ -0,0 +1,51 @@
+package calls
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// VoicemailWindow is how long after an unanswered DM call the caller may leave a voicemail
+const VoicemailWindow = 10 * time.Minute
+
+var ErrVoicemailNotAllowed = errors.New("voicemail is only available to the caller of a recently missed DM call")
+
+// VoicemailConversation checks the user can leave a voicemail for the call and returns its conversation.
+// Allowed for the caller of an ended DM call nobody else joined, once, within VoicemailWindow.
+func (r *Repository) VoicemailConversation(ctx context.Context, callID, userID uuid.UUID) (uuid.UUID, error) {
+	var conversationID uuid.UUID
+	var allowed bool
+	err := r.db.QueryRow(ctx, `
+		SELECT c.conversation_id,
+			   c.started_by = $2
+			   AND conv.type = 'dm'
+			   AND c.ended_at IS NOT NULL AND c.ended_at > $3
+			   AND c.voicemail_message_id IS NULL
+			   AND NOT EXISTS(SELECT 1 FROM call_participants WHERE call_id = c.id AND user_id <> c.started_by)
+		FROM calls c
+		JOIN conversations conv ON conv.id = c.conversation_id
+		WHERE c.id = $1
+	`, callID, userID, time.Now().Add(-VoicemailWindow)).Scan(&conversationID, &allowed)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, ErrCallNotFound
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if !allowed {
+		return uuid.Nil, ErrVoicemailNotAllowed
+	}
+	return conversationID, nil
+}
+
+// SetVoicemailMessage links the delivered voicemail to the call so only one can be left
+func (r *Repository) SetVoicemailMessage(ctx context.Context, callID, messageID uuid.UUID) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE calls SET voicemail_message_id = $2 WHERE id = $1 AND voicemail_message_id IS NULL
+	`, callID, messageID)
+	return err
+}
@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -13,6 +15,19 @@ type Config struct {
 	AccessTokenTTL  time.Duration
 	RefreshTokenTTL time.Duration
 
+	// Access token signing keys for rotation, as "kid:secret" pairs, and the kid new tokens
+	// are signed with. JWTSecret only validates tokens (without a kid) while neither keys nor
+	// a private key are set. To rotate, add the new key, switch JWT_SIGNING_KID to it, and
+	// drop the old one once its tokens expire.
+	JWTKeys       []string
+	JWTSigningKID string
+
+	// Asymmetric access token signing: a PEM RSA or Ed25519 private key (empty = HMAC), and
+	// public keys of retired private keys whose tokens are still accepted. Other services
+	// validate tokens with the keys served at /.well-known/jwks.json.
+	JWTPrivateKeyFile string
+	JWTPublicKeyFiles []string
+
 	// S3 Storage
 	S3Endpoint        string
 	S3Region          string
@@ -24,9 +39,156 @@ type Config struct {
 	// Voice SFU
 	VoiceHost      string
 	VoiceJWTSecret string
+	VoiceAPIURL    string
+	// Participant token lifetime and tolerated SFU clock skew
+	VoiceTokenTTL  time.Duration
+	VoiceClockSkew time.Duration
+
+	// Secret the SFU signs webhooks with (empty = webhooks are rejected), and whether
+	// webhooks decide who is in a call (requires the secret)
+	VoiceWebhookSecret string
+	VoiceWebhookSync   bool
+
+	// CallRingTimeout is how long a new call rings before it stops on its own
+	CallRingTimeout time.Duration
+
+	// How long a participant whose connection dropped stays in a call while they
+	// reconnect (0 = they leave immediately)
+	CallReconnectGrace time.Duration
+
+	// Most active participants a call can have (0 = unlimited)
+	MaxCallParticipants int
+
+	// TURN servers for clients behind strict NATs, with the coturn shared secret
+	// and credential lifetime
+	TURNURIs   []string
+	TURNSecret string
+	TURNTTL    time.Duration
+
+	// Voice backend for new calls ("sfu" or "livekit"), and an optional canary
+	// backend that gets a percentage of new calls
+	VoiceBackend       string
+	VoiceCanaryBackend string
+	VoiceCanaryPercent int
+
+	// LiveKit (enabled as a backend when the key and secret are set)
+	LiveKitHost      string
+	LiveKitAPIKey    string
+	LiveKitAPISecret string
+
+	// Database query logging (0 = disabled)
+	DBSlowQueryThreshold time.Duration
+
+	// Startup: how many times to retry Postgres/Redis, and whether to
+	// keep serving without Postgres instead of exiting
+	StartupRetryAttempts int
+	StartDegraded        bool
+
+	// Registration: require an invite code (soft launch); admin API token (empty = disabled)
+	InviteOnly bool
+	AdminToken string
+
+	// Passkeys (WebAuthn): the relying party ID is the domain passkeys are bound to, and
+	// origins are the web app URLs allowed to use them (empty = APP_URL)
+	WebAuthnRPID    string
+	WebAuthnRPName  string
+	WebAuthnOrigins []string
+
+	// Sign-in with external accounts: OAuth clients per provider (empty ID = disabled), and
+	// the public API URL their callbacks are registered under
+	// ("{OAUTH_CALLBACK_BASE_URL}/api/auth/oauth/{provider}/callback")
+	OAuthCallbackBaseURL string
+	GoogleClientID       string
+	GoogleClientSecret   string
+	GitHubClientID       string
+	GitHubClientSecret   string
+	DiscordClientID      string
+	DiscordClientSecret  string
+
+	// Age gate: minimum registration age (optionally per region, "DE=16,KR=14")
+	// and the age from which NSFW content is shown
+	MinAge         int
+	MinAgeByRegion string
+	AdultAge       int
+
+	// GIF search proxy: "tenor" or "giphy" (empty key = disabled)
+	GIFProvider string
+	GIFAPIKey   string
+
+	// CAPTCHA on register, login and friend requests by username: "hcaptcha" or
+	// "turnstile" (empty secret = disabled); the site key is handed to clients
+	CaptchaProvider string
+	CaptchaSecret   string
+	CaptchaSiteKey  string
+
+	// Auto-translation provider: "deepl" (needs key) or "libretranslate" (needs URL)
+	TranslateProvider string
+	TranslateAPIKey   string
+	TranslateURL      string
+
+	// Live call captions: "whisper" (OpenAI-compatible, URL optional) or "deepgram" (empty key = disabled)
+	TranscribeProvider string
+	TranscribeAPIKey   string
+	TranscribeURL      string
+
+	// VoIP pushes for incoming calls: APNs PushKit with a .p8 key, FCM with a service account file
+	APNsKeyFile        string
+	APNsKeyID          string
+	APNsTeamID         string
+	APNsBundleID       string
+	APNsProduction     bool
+	FCMCredentialsFile string
+
+	// Deleted messages are kept as tombstones for this long before being purged
+	MessageTombstoneRetention time.Duration
+
+	// Deleted groups can be restored by their owner for this long before being purged
+	GroupRestoreWindow time.Duration
+
+	// Accounts marked for deletion are anonymized after this long, unless the user signs back in
+	AccountDeletionGrace time.Duration
+
+	// Most participants a group can have (0 = unlimited)
+	MaxGroupSize int
+
+	// Dead media link checker: records sampled per media kind on each run
+	MediaCheckInterval time.Duration
+	MediaCheckSample   int
+
+	// Anonymized social graph snapshots for the admin analytics endpoints
+	GraphSnapshotInterval time.Duration
+
+	// How often pending conversation sync events are pushed to their endpoints
+	SyncDeliveryInterval time.Duration
 
 	// Redis
 	RedisAddr string
+
+	// Realtime
+	RealtimeFlushWindow         time.Duration
+	RealtimeClientQueueMaxBytes int
+	RealtimeSlowClientThreshold int
+
+	// Address of the internal listener serving Prometheus metrics (empty = not served);
+	// keep it off the public network
+	MetricsAddr string
+
+	// Offline notifications
+	AppURL             string
+	NotifyOfflineAfter time.Duration
+	NotifyWebhookURL   string
+	SMTPAddr           string
+	SMTPUsername       string
+	SMTPPassword       string
+	SMTPFrom           string
+
+	// Comma-separated CIDRs, IPs and host names server-side fetches may reach even though
+	// they're private (the S3 endpoint and CDN are always allowed)
+	OutboundAllowlist string
+
+	// Comma-separated CIDRs of reverse proxies whose CF-Connecting-IP header is trusted as
+	// the client address ("cloudflare" = Cloudflare's edge ranges, empty = none)
+	TrustedProxies string
 }
 
 func Load() *Config {
@@ -38,6 +200,14 @@ func Load() *Config {
 		AccessTokenTTL:  15 * time.Minute,
 		RefreshTokenTTL: 7 * 24 * time.Hour,
 
+		// Access token key rotation
+		JWTKeys:       getEnvList("JWT_KEYS"),
+		JWTSigningKID: getEnv("JWT_SIGNING_KID", ""),
+
+		// Asymmetric access token signing
+		JWTPrivateKeyFile: getEnv("JWT_PRIVATE_KEY_FILE", ""),
+		JWTPublicKeyFiles: getEnvList("JWT_PUBLIC_KEY_FILES"),
+
 		// S3 Storage - Timeweb
 		S3Endpoint:        getEnv("S3_ENDPOINT", "https://s3.twcstorage.ru"),
 		S3Region:          getEnv("S3_REGION", "ru-1"),
@@ -49,9 +219,140 @@ func Load() *Config {
 		// Voice SFU
 		VoiceHost:      getEnv("VOICE_HOST", "ws://localhost:7880"),
 		VoiceJWTSecret: getEnv("VOICE_JWT_SECRET", "voice-super-secret-key-change-in-production"),
+		VoiceAPIURL:    getEnv("VOICE_API_URL", ""),
+		VoiceTokenTTL:  getEnvDuration("VOICE_TOKEN_TTL", 10*time.Minute),
+		VoiceClockSkew: getEnvDuration("VOICE_CLOCK_SKEW", 30*time.Second),
+
+		// Voice webhooks
+		VoiceWebhookSecret: getEnv("VOICE_WEBHOOK_SECRET", ""),
+		VoiceWebhookSync:   getEnv("VOICE_WEBHOOK_SYNC", "false") == "true",
+
+		// Call ringing
+		CallRingTimeout: getEnvDuration("CALL_RING_TIMEOUT", 45*time.Second),
+
+		// Call reconnection
+		CallReconnectGrace: getEnvDuration("CALL_RECONNECT_GRACE", 20*time.Second),
+
+		// Call limits
+		MaxCallParticipants: getEnvInt("MAX_CALL_PARTICIPANTS", 25),
+
+		// TURN
+		TURNURIs:   getEnvList("TURN_URIS"),
+		TURNSecret: getEnv("TURN_SECRET", ""),
+		TURNTTL:    getEnvDuration("TURN_TTL", time.Hour),
+
+		// Voice backend selection
+		VoiceBackend:       getEnv("VOICE_BACKEND", "sfu"),
+		VoiceCanaryBackend: getEnv("VOICE_CANARY_BACKEND", ""),
+		VoiceCanaryPercent: getEnvInt("VOICE_CANARY_PERCENT", 0),
+
+		// LiveKit
+		LiveKitHost:      getEnv("LIVEKIT_HOST", "ws://localhost:7881"),
+		LiveKitAPIKey:    getEnv("LIVEKIT_API_KEY", ""),
+		LiveKitAPISecret: getEnv("LIVEKIT_API_SECRET", ""),
+
+		// Queries slower than this are logged
+		DBSlowQueryThreshold: getEnvDuration("DB_SLOW_QUERY_THRESHOLD", 200*time.Millisecond),
+
+		// Startup dependency retries
+		StartupRetryAttempts: getEnvInt("STARTUP_RETRY_ATTEMPTS", 5),
+		StartDegraded:        getEnv("START_DEGRADED", "false") == "true",
+
+		// Soft launch
+		InviteOnly: getEnv("REGISTRATION_INVITE_ONLY", "false") == "true",
+		AdminToken: getEnv("ADMIN_TOKEN", ""),
+
+		// Passkeys
+		WebAuthnRPID:    getEnv("WEBAUTHN_RP_ID", "joinbla.ru"),
+		WebAuthnRPName:  getEnv("WEBAUTHN_RP_NAME", "bla"),
+		WebAuthnOrigins: getEnvList("WEBAUTHN_ORIGINS"),
+
+		// External sign-in
+		OAuthCallbackBaseURL: getEnv("OAUTH_CALLBACK_BASE_URL", "https://api.joinbla.ru"),
+		GoogleClientID:       getEnv("GOOGLE_CLIENT_ID", ""),
+		GoogleClientSecret:   getEnv("GOOGLE_CLIENT_SECRET", ""),
+		GitHubClientID:       getEnv("GITHUB_CLIENT_ID", ""),
+		GitHubClientSecret:   getEnv("GITHUB_CLIENT_SECRET", ""),
+		DiscordClientID:      getEnv("DISCORD_CLIENT_ID", ""),
+		DiscordClientSecret:  getEnv("DISCORD_CLIENT_SECRET", ""),
+
+		// Age gate
+		MinAge:         getEnvInt("MIN_AGE", 13),
+		MinAgeByRegion: getEnv("MIN_AGE_BY_REGION", ""),
+		AdultAge:       getEnvInt("ADULT_AGE", 18),
+
+		// GIF search
+		GIFProvider: getEnv("GIF_PROVIDER", "tenor"),
+		GIFAPIKey:   getEnv("GIF_API_KEY", ""),
+
+		// CAPTCHA
+		CaptchaProvider: getEnv("CAPTCHA_PROVIDER", "turnstile"),
+		CaptchaSecret:   getEnv("CAPTCHA_SECRET", ""),
+		CaptchaSiteKey:  getEnv("CAPTCHA_SITE_KEY", ""),
+
+		// Translation
+		TranslateProvider: getEnv("TRANSLATE_PROVIDER", "deepl"),
+		TranslateAPIKey:   getEnv("TRANSLATE_API_KEY", ""),
+		TranslateURL:      getEnv("TRANSLATE_URL", ""),
+
+		// Call captions
+		TranscribeProvider: getEnv("TRANSCRIBE_PROVIDER", "whisper"),
+		TranscribeAPIKey:   getEnv("TRANSCRIBE_API_KEY", ""),
+		TranscribeURL:      getEnv("TRANSCRIBE_URL", ""),
+
+		// VoIP push
+		APNsKeyFile:        getEnv("APNS_KEY_FILE", ""),
+		APNsKeyID:          getEnv("APNS_KEY_ID", ""),
+		APNsTeamID:         getEnv("APNS_TEAM_ID", ""),
+		APNsBundleID:       getEnv("APNS_BUNDLE_ID", ""),
+		APNsProduction:     getEnv("APNS_PRODUCTION", "false") == "true",
+		FCMCredentialsFile: getEnv("FCM_CREDENTIALS_FILE", ""),
+
+		// Message deletion
+		MessageTombstoneRetention: getEnvDuration("MESSAGE_TOMBSTONE_RETENTION", 30*24*time.Hour),
+		GroupRestoreWindow:        getEnvDuration("GROUP_RESTORE_WINDOW", 14*24*time.Hour),
+
+		// Account deletion
+		AccountDeletionGrace: getEnvDuration("ACCOUNT_DELETION_GRACE", 30*24*time.Hour),
+
+		// Group limits
+		MaxGroupSize: getEnvInt("MAX_GROUP_SIZE", 500),
+
+		// Dead media link checker
+		MediaCheckInterval: getEnvDuration("MEDIA_CHECK_INTERVAL", 24*time.Hour),
+		MediaCheckSample:   getEnvInt("MEDIA_CHECK_SAMPLE", 200),
+
+		// Graph snapshots
+		GraphSnapshotInterval: getEnvDuration("GRAPH_SNAPSHOT_INTERVAL", 24*time.Hour),
+
+		// Conversation sync endpoints
+		SyncDeliveryInterval: getEnvDuration("SYNC_DELIVERY_INTERVAL", 5*time.Second),
 
 		// Redis (empty = disabled)
 		RedisAddr: getEnv("REDIS_ADDR", ""),
+
+		// Realtime delivery tuning
+		RealtimeFlushWindow:         getEnvDuration("REALTIME_FLUSH_WINDOW", 300*time.Millisecond),
+		RealtimeClientQueueMaxBytes: getEnvInt("REALTIME_CLIENT_QUEUE_MAX_BYTES", 4<<20),
+		RealtimeSlowClientThreshold: getEnvInt("REALTIME_SLOW_CLIENT_THRESHOLD", 64),
+
+		// Metrics
+		MetricsAddr: getEnv("METRICS_ADDR", ":9090"),
+
+		// Offline notifications (empty SMTP/webhook = channel disabled)
+		AppURL:             getEnv("APP_URL", "https://web.joinbla.ru"),
+		NotifyOfflineAfter: getEnvDuration("NOTIFY_OFFLINE_AFTER", 10*time.Minute),
+		NotifyWebhookURL:   getEnv("NOTIFY_WEBHOOK_URL", ""),
+		SMTPAddr:           getEnv("SMTP_ADDR", ""),
+		SMTPUsername:       getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:       getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:           getEnv("SMTP_FROM", "noreply@joinbla.ru"),
+
+		// Server-side fetches
+		OutboundAllowlist: getEnv("OUTBOUND_ALLOWLIST", ""),
+
+		// Client addresses
+		TrustedProxies: getEnv("TRUSTED_PROXIES", "cloudflare"),
 	}
 }
 
@@ -61,3 +362,32 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+	}
+	return fallback
+}
+
+// getEnvList reads a comma-separated list, skipping empty entries
+func getEnvList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -3,6 +3,7 @@ package database
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -11,19 +12,32 @@ type DB struct {
 	Pool *pgxpool.Pool
 }
 
-func New(databaseURL string) (*DB, error) {
-	pool, err := pgxpool.New(context.Background(), databaseURL)
+func New(databaseURL string, slowQueryThreshold time.Duration) (*DB, error) {
+	poolConfig, err := pgxpool.ParseConfig(databaseURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+		return nil, fmt.Errorf("invalid database url: %w", err)
+	}
+	if slowQueryThreshold > 0 {
+		poolConfig.ConnConfig.Tracer = NewSlowQueryTracer(slowQueryThreshold)
 	}
 
-	if err := pool.Ping(context.Background()); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+	// Connections are established lazily, so this succeeds even if Postgres is down
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database pool: %w", err)
 	}
 
 	return &DB{Pool: pool}, nil
 }
 
+// Ping checks that the database is reachable
+func (db *DB) Ping(ctx context.Context) error {
+	if err := db.Pool.Ping(ctx); err != nil {
+		return fmt.Errorf("failed to ping database: %w", err)
+	}
+	return nil
+}
+
 func (db *DB) Close() {
 	db.Pool.Close()
 }
@@ -221,6 +235,762 @@ func (db *DB) Migrate(ctx context.Context) error {
 		END $$;
 
 		CREATE INDEX IF NOT EXISTS idx_messages_type ON messages(type);
+
+		-- Threads: replies reference their parent message
+		DO $$ BEGIN
+			ALTER TABLE messages ADD COLUMN IF NOT EXISTS parent_id UUID REFERENCES messages(id) ON DELETE CASCADE;
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+
+		-- Read receipts: last message each participant has read
+		DO $$ BEGIN
+			ALTER TABLE conversation_participants ADD COLUMN IF NOT EXISTS last_read_message_id UUID REFERENCES messages(id) ON DELETE SET NULL;
+			ALTER TABLE conversation_participants ADD COLUMN IF NOT EXISTS last_read_at TIMESTAMP WITH TIME ZONE;
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+
+		-- Delivery receipts: last message each participant's device has received
+		DO $$ BEGIN
+			ALTER TABLE conversation_participants ADD COLUMN IF NOT EXISTS last_delivered_message_id UUID REFERENCES messages(id) ON DELETE SET NULL;
+			ALTER TABLE conversation_participants ADD COLUMN IF NOT EXISTS last_delivered_at TIMESTAMP WITH TIME ZONE;
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+
+		-- Per-user "clear history" cutoff (DMs)
+		DO $$ BEGIN
+			ALTER TABLE conversation_participants ADD COLUMN IF NOT EXISTS cleared_before TIMESTAMP WITH TIME ZONE;
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+
+		-- Soft-deleted messages stay as tombstones until the retention job purges them
+		DO $$ BEGIN
+			ALTER TABLE messages ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+
+		-- Client-generated nonce making sends idempotent per sender
+		DO $$ BEGIN
+			ALTER TABLE messages ADD COLUMN IF NOT EXISTS client_nonce VARCHAR(64);
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+
+		-- Sends rely on the unique index, so it's built here rather than online. It replaces
+		-- an invalid one left by a failed concurrent build, and retries stored before it
+		-- existed lose their nonce to the oldest message with it.
+		DO $$ BEGIN
+			IF NOT EXISTS (
+				SELECT 1 FROM pg_index i JOIN pg_class c ON c.oid = i.indexrelid
+				WHERE c.relname = 'idx_messages_sender_nonce' AND i.indisvalid
+			) THEN
+				DROP INDEX IF EXISTS idx_messages_sender_nonce;
+				UPDATE messages m SET client_nonce = NULL
+				WHERE m.client_nonce IS NOT NULL AND EXISTS (
+					SELECT 1 FROM messages o
+					WHERE o.sender_id = m.sender_id AND o.client_nonce = m.client_nonce
+					  AND (o.created_at, o.id) < (m.created_at, m.id)
+				);
+				CREATE UNIQUE INDEX idx_messages_sender_nonce ON messages(sender_id, client_nonce) WHERE client_nonce IS NOT NULL;
+			END IF;
+		END $$;
+
+		-- User time zone (IANA name), set explicitly or inferred from the client's X-Time-Zone header
+		DO $$ BEGIN
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS time_zone VARCHAR(64);
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS time_zone_explicit BOOLEAN NOT NULL DEFAULT FALSE;
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+
+		-- Detected language of a message (ISO 639-1), NULL when it couldn't be told
+		DO $$ BEGIN
+			ALTER TABLE messages ADD COLUMN IF NOT EXISTS language VARCHAR(8);
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+
+		-- Sticker messages reference the sticker instead of carrying its URL in content
+		DO $$ BEGIN
+			ALTER TABLE messages ADD COLUMN IF NOT EXISTS sticker_id UUID REFERENCES stickers(id) ON DELETE SET NULL;
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+
+		-- Disappearing messages: per-conversation TTL, stamped on each message at send time
+		DO $$ BEGIN
+			ALTER TABLE conversations ADD COLUMN IF NOT EXISTS message_ttl_seconds INT;
+			ALTER TABLE messages ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP WITH TIME ZONE;
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+
+		-- @mentions
+		CREATE TABLE IF NOT EXISTS message_mentions (
+			message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			PRIMARY KEY (message_id, user_id)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_message_mentions_user ON message_mentions(user_id, created_at DESC);
+
+		-- Link previews (unfurled by a background worker)
+		CREATE TABLE IF NOT EXISTS message_embeds (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+			url TEXT NOT NULL,
+			title TEXT NOT NULL DEFAULT '',
+			description TEXT NOT NULL DEFAULT '',
+			site_name TEXT NOT NULL DEFAULT '',
+			image_url TEXT,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_message_embeds_message ON message_embeds(message_id);
+
+		-- Polls (message type 'poll')
+		CREATE TABLE IF NOT EXISTS polls (
+			message_id UUID PRIMARY KEY REFERENCES messages(id) ON DELETE CASCADE,
+			question VARCHAR(300) NOT NULL,
+			multiple_choice BOOLEAN NOT NULL DEFAULT FALSE,
+			closed_at TIMESTAMP WITH TIME ZONE
+		);
+
+		CREATE TABLE IF NOT EXISTS poll_options (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			message_id UUID NOT NULL REFERENCES polls(message_id) ON DELETE CASCADE,
+			position INT NOT NULL,
+			text VARCHAR(100) NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_poll_options_message ON poll_options(message_id, position);
+
+		CREATE TABLE IF NOT EXISTS poll_votes (
+			message_id UUID NOT NULL REFERENCES polls(message_id) ON DELETE CASCADE,
+			option_id UUID NOT NULL REFERENCES poll_options(id) ON DELETE CASCADE,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			PRIMARY KEY (option_id, user_id)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_poll_votes_message_user ON poll_votes(message_id, user_id);
+
+		-- Single-choice polls hold one vote per user, enforced by a partial unique index
+		DO $$ BEGIN
+			ALTER TABLE poll_votes ADD COLUMN IF NOT EXISTS single_choice BOOLEAN NOT NULL DEFAULT FALSE;
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+		UPDATE poll_votes v SET single_choice = TRUE
+		FROM polls p
+		WHERE p.message_id = v.message_id AND NOT p.multiple_choice AND NOT v.single_choice;
+		-- Votes left doubled by concurrent voting keep the latest one
+		DELETE FROM poll_votes v
+		USING poll_votes newer
+		WHERE v.single_choice AND newer.single_choice
+			AND newer.message_id = v.message_id AND newer.user_id = v.user_id
+			AND (newer.created_at, newer.option_id) > (v.created_at, v.option_id);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_poll_votes_single_choice ON poll_votes(message_id, user_id) WHERE single_choice;
+
+		-- Age gate: optional birthdate, NSFW flag on sticker packs
+		DO $$ BEGIN
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS birthdate DATE;
+			ALTER TABLE sticker_packs ADD COLUMN IF NOT EXISTS nsfw BOOLEAN NOT NULL DEFAULT FALSE;
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+
+		-- Per-user conversation list: pinning and custom order
+		DO $$ BEGIN
+			ALTER TABLE conversation_participants ADD COLUMN IF NOT EXISTS pinned_at TIMESTAMP WITH TIME ZONE;
+			ALTER TABLE conversation_participants ADD COLUMN IF NOT EXISTS sort_order INT;
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+
+		-- Per-user drafts of unsent messages
+		CREATE TABLE IF NOT EXISTS message_drafts (
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+			content TEXT NOT NULL,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			PRIMARY KEY (user_id, conversation_id)
+		);
+
+		-- Starred (bookmarked) messages
+		CREATE TABLE IF NOT EXISTS starred_messages (
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			PRIMARY KEY (user_id, message_id)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_starred_messages_user ON starred_messages(user_id, created_at DESC);
+
+		-- Local ignore: hide one participant's messages from one user in a conversation
+		CREATE TABLE IF NOT EXISTS conversation_ignores (
+			conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			ignored_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			PRIMARY KEY (conversation_id, user_id, ignored_user_id)
+		);
+
+		-- Read-only public widget API keys
+		CREATE TABLE IF NOT EXISTS widget_keys (
+			key VARCHAR(64) PRIMARY KEY,
+			conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+			created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			revoked_at TIMESTAMP WITH TIME ZONE
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_widget_keys_conversation ON widget_keys(conversation_id);
+
+		-- In-call chat, buffered until the call ends (then saved as a transcript or dropped)
+		CREATE TABLE IF NOT EXISTS call_chat_messages (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			call_id UUID NOT NULL REFERENCES calls(id) ON DELETE CASCADE,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			content TEXT NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_call_chat_messages_call ON call_chat_messages(call_id, created_at);
+
+		DO $$ BEGIN
+			ALTER TABLE calls ADD COLUMN IF NOT EXISTS chat_transcript BOOLEAN NOT NULL DEFAULT FALSE;
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+
+		-- Call moderation: server-muted participants and push-to-talk mode
+		DO $$ BEGIN
+			ALTER TABLE call_participants ADD COLUMN IF NOT EXISTS server_muted BOOLEAN NOT NULL DEFAULT FALSE;
+			ALTER TABLE calls ADD COLUMN IF NOT EXISTS push_to_talk BOOLEAN NOT NULL DEFAULT FALSE;
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+
+		-- Watch-together: one synchronized playback session per conversation
+		CREATE TABLE IF NOT EXISTS watch_sessions (
+			conversation_id UUID PRIMARY KEY REFERENCES conversations(id) ON DELETE CASCADE,
+			media_url TEXT NOT NULL,
+			started_by UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			playing BOOLEAN NOT NULL DEFAULT FALSE,
+			position_ms BIGINT NOT NULL DEFAULT 0,
+			updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+			updated_by UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE
+		);
+
+		-- Voicemail left after an unanswered DM call (one per call)
+		DO $$ BEGIN
+			ALTER TABLE calls ADD COLUMN IF NOT EXISTS voicemail_message_id UUID REFERENCES messages(id) ON DELETE SET NULL;
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+
+		-- Groups deleted by their owner can be restored until the purge job removes them
+		DO $$ BEGIN
+			ALTER TABLE conversations ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+		CREATE INDEX IF NOT EXISTS idx_conversations_deleted ON conversations(deleted_at) WHERE deleted_at IS NOT NULL;
+
+		-- DMs started by a non-friend are message requests ('pending' or 'declined') until the recipient accepts
+		DO $$ BEGIN
+			ALTER TABLE conversations ADD COLUMN IF NOT EXISTS request_status VARCHAR(16);
+			ALTER TABLE conversations ADD COLUMN IF NOT EXISTS requested_by UUID REFERENCES users(id) ON DELETE SET NULL;
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+
+		-- Group roles ('owner', 'admin' or 'member'); see messages.rolePermissions
+		DO $$ BEGIN
+			ALTER TABLE conversation_participants ADD COLUMN IF NOT EXISTS role VARCHAR(16) NOT NULL DEFAULT 'member';
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+		-- Groups left without an owner (legacy, or the owner's account was deleted) pass to their longest-standing member
+		UPDATE conversations c SET owner_id = (
+			SELECT user_id FROM conversation_participants WHERE conversation_id = c.id ORDER BY joined_at LIMIT 1
+		) WHERE c.type = 'group' AND c.owner_id IS NULL;
+		UPDATE conversation_participants cp SET role = 'owner'
+		FROM conversations c
+		WHERE c.id = cp.conversation_id AND c.owner_id = cp.user_id AND cp.role != 'owner';
+
+		-- Calls planned for a future time; the scheduler reminds and then opens the call
+		CREATE TABLE IF NOT EXISTS scheduled_calls (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+			created_by UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			title VARCHAR(100) NOT NULL DEFAULT '',
+			starts_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			reminded_at TIMESTAMP WITH TIME ZONE,
+			started_at TIMESTAMP WITH TIME ZONE,
+			call_id UUID REFERENCES calls(id) ON DELETE SET NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_scheduled_calls_pending ON scheduled_calls(starts_at) WHERE started_at IS NULL;
+		CREATE INDEX IF NOT EXISTS idx_scheduled_calls_conversation ON scheduled_calls(conversation_id, starts_at);
+		CREATE INDEX IF NOT EXISTS idx_scheduled_calls_call ON scheduled_calls(call_id) WHERE call_id IS NOT NULL;
+
+		-- Post-call ratings and client WebRTC stats (SFU/TURN diagnostics)
+		CREATE TABLE IF NOT EXISTS call_feedback (
+			call_id UUID NOT NULL REFERENCES calls(id) ON DELETE CASCADE,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			rating SMALLINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+			issues TEXT[] NOT NULL DEFAULT '{}',
+			comment TEXT,
+			rtt_ms REAL,
+			packet_loss_pct REAL,
+			jitter_ms REAL,
+			platform VARCHAR(32),
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			PRIMARY KEY (call_id, user_id)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_call_feedback_created ON call_feedback(created_at);
+
+		-- Stored media the link checker found missing from the bucket (cleared once it resolves again)
+		CREATE TABLE IF NOT EXISTS broken_media (
+			kind VARCHAR(20) NOT NULL,
+			record_id UUID NOT NULL,
+			url TEXT NOT NULL,
+			first_seen_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+			last_checked_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (kind, record_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS media_check_runs (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			finished_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+			checked INT NOT NULL DEFAULT 0,
+			broken INT NOT NULL DEFAULT 0,
+			repaired INT NOT NULL DEFAULT 0
+		);
+
+		-- Progress of cmd/migrate-storage per destination bucket and URL column, so it can resume
+		CREATE TABLE IF NOT EXISTS storage_migration_checkpoints (
+			destination TEXT NOT NULL,
+			source VARCHAR(64) NOT NULL,
+			last_id UUID NOT NULL,
+			copied INT NOT NULL DEFAULT 0,
+			missing INT NOT NULL DEFAULT 0,
+			done BOOLEAN NOT NULL DEFAULT FALSE,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			PRIMARY KEY (destination, source)
+		);
+
+		-- Per-user auto-translation of a conversation's incoming messages
+		CREATE TABLE IF NOT EXISTS conversation_translations (
+			conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			target_language VARCHAR(8) NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			PRIMARY KEY (conversation_id, user_id)
+		);
+
+		-- Users who left a group and opted out of being re-added; only an invite they accept brings them back
+		CREATE TABLE IF NOT EXISTS group_readd_blocks (
+			conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			PRIMARY KEY (conversation_id, user_id)
+		);
+
+		-- Pending invites to join a group (one per user and group)
+		CREATE TABLE IF NOT EXISTS group_invites (
+			conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			invited_by UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			PRIMARY KEY (conversation_id, user_id)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_group_invites_user ON group_invites(user_id);
+
+		-- Shareable links to join a group, optionally expiring or limited in uses
+		CREATE TABLE IF NOT EXISTS group_invite_links (
+			code VARCHAR(16) PRIMARY KEY,
+			conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+			created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			max_uses INT,
+			uses INT NOT NULL DEFAULT 0,
+			expires_at TIMESTAMP WITH TIME ZONE,
+			revoked_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_group_invite_links_conversation ON group_invite_links(conversation_id);
+
+		-- Who joined through which invite link
+		CREATE TABLE IF NOT EXISTS group_invite_link_uses (
+			code VARCHAR(16) NOT NULL REFERENCES group_invite_links(code) ON DELETE CASCADE,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			joined_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			PRIMARY KEY (code, user_id)
+		);
+
+		-- Groups where joining through an invite link needs an owner's or admin's approval
+		DO $$ BEGIN
+			ALTER TABLE conversations ADD COLUMN IF NOT EXISTS join_approval BOOLEAN NOT NULL DEFAULT FALSE;
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+
+		CREATE TABLE IF NOT EXISTS group_join_requests (
+			conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			invite_code VARCHAR(16) REFERENCES group_invite_links(code) ON DELETE SET NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			PRIMARY KEY (conversation_id, user_id)
+		);
+
+		-- Public groups are listed in discovery and open to anyone
+		DO $$ BEGIN
+			ALTER TABLE conversations ADD COLUMN IF NOT EXISTS is_public BOOLEAN NOT NULL DEFAULT FALSE;
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+
+		CREATE INDEX IF NOT EXISTS idx_conversations_public ON conversations(created_at) WHERE is_public AND deleted_at IS NULL;
+
+		-- Announcement groups: only owners and admins can post
+		DO $$ BEGIN
+			ALTER TABLE conversations ADD COLUMN IF NOT EXISTS announcement_only BOOLEAN NOT NULL DEFAULT FALSE;
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+
+		-- Who can start calls in a group: "everyone" or "admins" (owners and admins)
+		DO $$ BEGIN
+			ALTER TABLE conversations ADD COLUMN IF NOT EXISTS call_permission VARCHAR(16) NOT NULL DEFAULT 'everyone';
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+
+		-- Users removed from a group and banned from coming back until unbanned
+		CREATE TABLE IF NOT EXISTS conversation_bans (
+			conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			banned_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			PRIMARY KEY (conversation_id, user_id)
+		);
+
+		-- Personal access tokens for scripting against the API (stored hashed, scoped, separate from sessions)
+		CREATE TABLE IF NOT EXISTS personal_access_tokens (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			name VARCHAR(100) NOT NULL,
+			token_hash VARCHAR(64) UNIQUE NOT NULL,
+			prefix VARCHAR(16) NOT NULL,
+			scopes TEXT[] NOT NULL,
+			use_count BIGINT NOT NULL DEFAULT 0,
+			last_used_at TIMESTAMP WITH TIME ZONE,
+			expires_at TIMESTAMP WITH TIME ZONE,
+			revoked_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_personal_access_tokens_user ON personal_access_tokens(user_id);
+
+		-- Group description (topic line)
+		DO $$ BEGIN
+			ALTER TABLE conversations ADD COLUMN IF NOT EXISTS description VARCHAR(500);
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+
+		-- Sync endpoints: external read models (support desks, CRMs) mirroring a conversation.
+		-- acked_seq is the last event the endpoint acknowledged.
+		CREATE TABLE IF NOT EXISTS conversation_sync_endpoints (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+			created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			url TEXT NOT NULL,
+			secret VARCHAR(64) NOT NULL,
+			acked_seq BIGINT NOT NULL DEFAULT 0,
+			failures INT NOT NULL DEFAULT 0,
+			last_error TEXT,
+			next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+			last_delivered_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_conversation_sync_endpoints_conversation ON conversation_sync_endpoints(conversation_id);
+
+		-- Sync feed: events recorded for conversations with sync endpoints, pruned once acknowledged
+		CREATE TABLE IF NOT EXISTS conversation_sync_events (
+			seq BIGSERIAL PRIMARY KEY,
+			conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+			type VARCHAR(40) NOT NULL,
+			payload JSONB NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_conversation_sync_events_conversation ON conversation_sync_events(conversation_id, seq);
+
+		-- Voice backend ('sfu' or 'livekit') hosting the call, assigned when the first token is issued
+		DO $$ BEGIN
+			ALTER TABLE calls ADD COLUMN IF NOT EXISTS voice_backend VARCHAR(20);
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+
+		-- Per-conversation display nicknames, visible to everyone in the conversation
+		DO $$ BEGIN
+			ALTER TABLE conversation_participants ADD COLUMN IF NOT EXISTS nickname VARCHAR(32);
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+
+		-- New calls ring the conversation until this time; NULL once answered, declined or timed out
+		DO $$ BEGIN
+			ALTER TABLE calls ADD COLUMN IF NOT EXISTS ringing_until TIMESTAMP WITH TIME ZONE;
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+		CREATE INDEX IF NOT EXISTS idx_calls_ringing ON calls(ringing_until) WHERE ringing_until IS NOT NULL;
+
+		-- Participants a group call rings when the caller picked them; NULL rings everyone
+		DO $$ BEGIN
+			ALTER TABLE calls ADD COLUMN IF NOT EXISTS ring_targets UUID[];
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+
+		CREATE TABLE IF NOT EXISTS call_declines (
+			call_id UUID NOT NULL REFERENCES calls(id) ON DELETE CASCADE,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			declined_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (call_id, user_id)
+		);
+
+		-- Self-reported media state of call participants, relayed in CALL_STATE
+		DO $$ BEGIN
+			ALTER TABLE call_participants ADD COLUMN IF NOT EXISTS self_muted BOOLEAN NOT NULL DEFAULT FALSE;
+			ALTER TABLE call_participants ADD COLUMN IF NOT EXISTS self_deafened BOOLEAN NOT NULL DEFAULT FALSE;
+			ALTER TABLE call_participants ADD COLUMN IF NOT EXISTS camera_on BOOLEAN NOT NULL DEFAULT FALSE;
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+
+		-- Participants whose connection dropped stay in the call while they reconnect
+		DO $$ BEGIN
+			ALTER TABLE call_participants ADD COLUMN IF NOT EXISTS reconnecting_since TIMESTAMP WITH TIME ZONE;
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+		CREATE INDEX IF NOT EXISTS idx_call_participants_reconnecting ON call_participants(reconnecting_since)
+			WHERE reconnecting_since IS NOT NULL AND left_at IS NULL;
+
+		-- Users a call rang; missed_at is set for those who never answered by the time it ended
+		CREATE TABLE IF NOT EXISTS call_misses (
+			call_id UUID NOT NULL REFERENCES calls(id) ON DELETE CASCADE,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			missed_at TIMESTAMP WITH TIME ZONE,
+			seen_at TIMESTAMP WITH TIME ZONE,
+			PRIMARY KEY (call_id, user_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_call_misses_unseen ON call_misses(user_id)
+			WHERE missed_at IS NOT NULL AND seen_at IS NULL;
+
+		-- Each device a user is in a call from has its own row, named by the client's session ID
+		DO $$ BEGIN
+			ALTER TABLE call_participants ADD COLUMN IF NOT EXISTS session_id TEXT NOT NULL DEFAULT '';
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+		CREATE INDEX IF NOT EXISTS idx_call_participants_active_sessions ON call_participants(call_id, user_id, session_id)
+			WHERE left_at IS NULL;
+
+		-- Participants can hold a call to take another one
+		DO $$ BEGIN
+			ALTER TABLE call_participants ADD COLUMN IF NOT EXISTS on_hold BOOLEAN NOT NULL DEFAULT FALSE;
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+
+		-- Live captions of calls, transcribed from audio chunks posted by the voice server
+		CREATE TABLE IF NOT EXISTS call_captions (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			call_id UUID NOT NULL REFERENCES calls(id) ON DELETE CASCADE,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			text TEXT NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_call_captions_call ON call_captions(call_id, created_at);
+
+		-- Mobile devices woken by VoIP pushes (APNs PushKit, FCM) for incoming calls
+		CREATE TABLE IF NOT EXISTS push_devices (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			platform VARCHAR(16) NOT NULL,
+			token TEXT NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			UNIQUE (platform, token)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_push_devices_user ON push_devices(user_id);
+
+		-- WebAuthn passkeys users sign in with instead of their password
+		CREATE TABLE IF NOT EXISTS passkeys (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			name VARCHAR(100) NOT NULL,
+			credential_id BYTEA NOT NULL UNIQUE,
+			public_key BYTEA NOT NULL,
+			algorithm INTEGER NOT NULL,
+			sign_count BIGINT NOT NULL DEFAULT 0,
+			last_used_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_passkeys_user ON passkeys(user_id);
+
+		-- Bumped to revoke the user's outstanding access tokens (logout everywhere)
+		DO $$ BEGIN
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS token_version INTEGER NOT NULL DEFAULT 0;
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+
+		-- Account deletion: requested (cancelled by signing back in), then anonymized by the
+		-- purge job once the grace period passes
+		DO $$ BEGIN
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_requested_at TIMESTAMP WITH TIME ZONE;
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+
+		CREATE INDEX IF NOT EXISTS idx_users_deletion_requested ON users(deletion_requested_at) WHERE deletion_requested_at IS NOT NULL;
+
+		-- Devices and IPs sessions were issued to, for new-device sign-in alerts
+		CREATE TABLE IF NOT EXISTS login_devices (
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			device_key VARCHAR(128) NOT NULL,
+			ip VARCHAR(64) NOT NULL,
+			user_agent TEXT NOT NULL DEFAULT '',
+			first_seen_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			last_seen_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			PRIMARY KEY (user_id, device_key, ip)
+		);
+
+		-- Accounts at external identity providers (Google, GitHub, Discord) linked to users,
+		-- at most one per provider
+		DO $$ BEGIN
+			ALTER TABLE user_identities RENAME TO oauth_identities;
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+
+		CREATE TABLE IF NOT EXISTS oauth_identities (
+			provider VARCHAR(20) NOT NULL,
+			subject VARCHAR(255) NOT NULL,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			email VARCHAR(255) NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			PRIMARY KEY (provider, subject)
+		);
+
+		DROP INDEX IF EXISTS idx_user_identities_user;
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_oauth_identities_user_provider ON oauth_identities(user_id, provider);
+
+		-- Administrative actions in groups (membership, roles, bans, settings), read by owners and admins
+		CREATE TABLE IF NOT EXISTS conversation_audit (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+			actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+			action VARCHAR(32) NOT NULL,
+			target_id UUID REFERENCES users(id) ON DELETE SET NULL,
+			details JSONB NOT NULL DEFAULT '{}',
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_conversation_audit_conv ON conversation_audit(conversation_id, created_at DESC);
+
+		-- Anonymized friendship/conversation graphs computed by the graph builder
+		CREATE TABLE IF NOT EXISTS graph_snapshots (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			kind VARCHAR(16) NOT NULL,
+			computed_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			stats JSONB NOT NULL,
+			graph JSONB NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_graph_snapshots_kind ON graph_snapshots(kind, computed_at DESC);
+
+		-- Per-user daily activity, rolled up by the stats aggregator
+		CREATE TABLE IF NOT EXISTS user_activity_daily (
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			day DATE NOT NULL,
+			conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+			messages_sent INT NOT NULL DEFAULT 0,
+			call_seconds INT NOT NULL DEFAULT 0,
+			PRIMARY KEY (user_id, day, conversation_id)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_user_activity_daily_day ON user_activity_daily(day);
+
+		CREATE TABLE IF NOT EXISTS conversation_activity_hourly (
+			conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+			day DATE NOT NULL,
+			hour SMALLINT NOT NULL,
+			messages INT NOT NULL DEFAULT 0,
+			PRIMARY KEY (conversation_id, day, hour)
+		);
+
+		CREATE TABLE IF NOT EXISTS conversation_reactions_daily (
+			conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+			day DATE NOT NULL,
+			emoji VARCHAR(32) NOT NULL,
+			count INT NOT NULL DEFAULT 0,
+			PRIMARY KEY (conversation_id, day, emoji)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_conversation_activity_hourly_day ON conversation_activity_hourly(day);
+		CREATE INDEX IF NOT EXISTS idx_conversation_reactions_daily_day ON conversation_reactions_daily(day);
+
+		CREATE TABLE IF NOT EXISTS stats_aggregation_state (
+			id INT PRIMARY KEY,
+			aggregated_through DATE NOT NULL,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		-- Invite-only registration
+		CREATE TABLE IF NOT EXISTS invite_codes (
+			code VARCHAR(32) PRIMARY KEY,
+			max_uses INT NOT NULL DEFAULT 1,
+			uses INT NOT NULL DEFAULT 0,
+			expires_at TIMESTAMP WITH TIME ZONE,
+			note VARCHAR(200) NOT NULL DEFAULT '',
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE TABLE IF NOT EXISTS waitlist (
+			email VARCHAR(255) PRIMARY KEY,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		-- Online (non-blocking) schema changes, see online.go
+		CREATE TABLE IF NOT EXISTS schema_online_migrations (
+			name VARCHAR(255) PRIMARY KEY,
+			cursor TEXT,
+			rows_done BIGINT NOT NULL DEFAULT 0,
+			completed_at TIMESTAMP WITH TIME ZONE,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE TABLE IF NOT EXISTS schema_dual_writes (
+			name VARCHAR(255) PRIMARY KEY,
+			enabled BOOLEAN NOT NULL DEFAULT FALSE,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		-- Notification settings for offline delivery (email/webhook)
+		CREATE TABLE IF NOT EXISTS notification_settings (
+			user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			email_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		-- Quiet hours: minutes since local midnight, evaluated in the user's time zone
+		DO $$ BEGIN
+			ALTER TABLE notification_settings ADD COLUMN IF NOT EXISTS quiet_start SMALLINT;
+			ALTER TABLE notification_settings ADD COLUMN IF NOT EXISTS quiet_end SMALLINT;
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+
+		-- Do Not Disturb: no call rings or notifications until dnd_until (NULL = until turned off)
+		DO $$ BEGIN
+			ALTER TABLE notification_settings ADD COLUMN IF NOT EXISTS dnd BOOLEAN NOT NULL DEFAULT FALSE;
+			ALTER TABLE notification_settings ADD COLUMN IF NOT EXISTS dnd_until TIMESTAMP WITH TIME ZONE;
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
+
+		-- Webhook notifications have their own switch, separate from email
+		DO $$ BEGIN
+			ALTER TABLE notification_settings ADD COLUMN IF NOT EXISTS webhook_enabled BOOLEAN NOT NULL DEFAULT TRUE;
+		EXCEPTION WHEN others THEN NULL;
+		END $$;
 	`
 
 	_, err := db.Pool.Exec(ctx, schema)
@@ -0,0 +1,220 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// onlineMigrationLock keeps concurrent instances from running the same online step twice
+const onlineMigrationLock = 7342001
+
+// OnlineStep is a schema change that must not block writes (e.g. index builds on large tables).
+// Steps run in order after Migrate, outside a transaction, and are recorded in schema_online_migrations.
+type OnlineStep struct {
+	Name string
+	Run  func(ctx context.Context, conn *pgxpool.Conn, progress *Progress) error
+}
+
+// onlineSteps are applied by MigrateOnline. Append only; never rename a step that has shipped.
+var onlineSteps = []OnlineStep{
+	CreateIndexConcurrently("idx_messages_parent", "CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_messages_parent ON messages(parent_id, created_at)"),
+	CreateIndexConcurrently("idx_messages_expires", "CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_messages_expires ON messages(expires_at) WHERE expires_at IS NOT NULL"),
+	CreateIndexConcurrently("idx_messages_deleted", "CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_messages_deleted ON messages(deleted_at) WHERE deleted_at IS NOT NULL"),
+}
+
+// MigrateOnline runs pending online steps. Safe to call from every instance; only one runs them.
+func (db *DB) MigrateOnline(ctx context.Context) error {
+	conn, err := db.Pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	var locked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, onlineMigrationLock).Scan(&locked); err != nil {
+		return err
+	}
+	if !locked {
+		return nil
+	}
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, onlineMigrationLock)
+
+	for _, step := range onlineSteps {
+		progress, err := loadProgress(ctx, conn, step.Name)
+		if err != nil {
+			return err
+		}
+		if progress.Done {
+			continue
+		}
+
+		start := time.Now()
+		log.Printf("Online migration %s: starting", step.Name)
+		if err := step.Run(ctx, conn, progress); err != nil {
+			return fmt.Errorf("online migration %s: %w", step.Name, err)
+		}
+		if err := progress.complete(ctx); err != nil {
+			return err
+		}
+		log.Printf("Online migration %s: completed in %s", step.Name, time.Since(start))
+	}
+	return nil
+}
+
+// Progress is the persisted state of one online step, so a restarted backfill resumes where it stopped
+type Progress struct {
+	conn   *pgxpool.Conn
+	name   string
+	Cursor *string
+	Rows   int64
+	Done   bool
+}
+
+func loadProgress(ctx context.Context, conn *pgxpool.Conn, name string) (*Progress, error) {
+	p := &Progress{conn: conn, name: name}
+	err := conn.QueryRow(ctx, `
+		SELECT cursor, rows_done, completed_at IS NOT NULL
+		FROM schema_online_migrations WHERE name = $1
+	`, name).Scan(&p.Cursor, &p.Rows, &p.Done)
+	if errors.Is(err, pgx.ErrNoRows) {
+		_, err = conn.Exec(ctx, `INSERT INTO schema_online_migrations (name) VALUES ($1)`, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Save records the cursor of the last processed row and the total rows done
+func (p *Progress) Save(ctx context.Context, cursor string, rows int64) error {
+	p.Cursor = &cursor
+	p.Rows = rows
+	_, err := p.conn.Exec(ctx, `
+		UPDATE schema_online_migrations SET cursor = $2, rows_done = $3, updated_at = NOW()
+		WHERE name = $1
+	`, p.name, cursor, rows)
+	return err
+}
+
+func (p *Progress) complete(ctx context.Context) error {
+	p.Done = true
+	_, err := p.conn.Exec(ctx, `
+		UPDATE schema_online_migrations SET completed_at = NOW(), updated_at = NOW()
+		WHERE name = $1
+	`, p.name)
+	return err
+}
+
+// CreateIndexConcurrently builds an index without taking a write lock on the table.
+// ddl must use CREATE INDEX CONCURRENTLY IF NOT EXISTS. A leftover invalid index from an
+// interrupted build is dropped and rebuilt.
+func CreateIndexConcurrently(index, ddl string) OnlineStep {
+	return OnlineStep{
+		Name: "index:" + index,
+		Run: func(ctx context.Context, conn *pgxpool.Conn, _ *Progress) error {
+			var valid *bool
+			err := conn.QueryRow(ctx, `
+				SELECT i.indisvalid FROM pg_index i
+				JOIN pg_class c ON c.oid = i.indexrelid
+				WHERE c.relname = $1
+			`, index).Scan(&valid)
+			if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+				return err
+			}
+			if valid != nil && !*valid {
+				if _, err := conn.Exec(ctx, "DROP INDEX CONCURRENTLY IF EXISTS "+pgx.Identifier{index}.Sanitize()); err != nil {
+					return err
+				}
+			}
+
+			_, err = conn.Exec(ctx, ddl)
+			return err
+		},
+	}
+}
+
+// Backfill updates a table in small batches keyed by its UUID id column, pausing between
+// batches so replication and regular traffic keep up. update is the SET clause and where
+// selects rows that still need it (e.g. "search_text IS NULL"). Progress survives restarts.
+func Backfill(name, table, update, where string, batchSize int, pause time.Duration) OnlineStep {
+	return OnlineStep{
+		Name: "backfill:" + name,
+		Run: func(ctx context.Context, conn *pgxpool.Conn, progress *Progress) error {
+			tbl := pgx.Identifier{table}.Sanitize()
+			query := fmt.Sprintf(`
+				WITH batch AS (
+					SELECT id FROM %s
+					WHERE id > $1::uuid AND (%s)
+					ORDER BY id
+					LIMIT $2
+				)
+				UPDATE %s t SET %s
+				FROM batch WHERE t.id = batch.id
+				RETURNING t.id::text
+			`, tbl, where, tbl, update)
+
+			cursor := "00000000-0000-0000-0000-000000000000"
+			if progress.Cursor != nil {
+				cursor = *progress.Cursor
+			}
+			rowsDone := progress.Rows
+
+			for {
+				rows, err := conn.Query(ctx, query, cursor, batchSize)
+				if err != nil {
+					return err
+				}
+				ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+				if err != nil {
+					return err
+				}
+				if len(ids) == 0 {
+					return nil
+				}
+
+				for _, id := range ids {
+					if id > cursor {
+						cursor = id
+					}
+				}
+				rowsDone += int64(len(ids))
+				if err := progress.Save(ctx, cursor, rowsDone); err != nil {
+					return err
+				}
+				log.Printf("Online migration %s: %d rows backfilled", name, rowsDone)
+
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(pause):
+				}
+			}
+		},
+	}
+}
+
+// DualWriteEnabled reports whether writes should go to both the old and new schema for a
+// change in progress. Toggle with: UPDATE schema_dual_writes SET enabled = ... WHERE name = ...
+func (db *DB) DualWriteEnabled(ctx context.Context, name string) (bool, error) {
+	var enabled bool
+	err := db.Pool.QueryRow(ctx, `SELECT enabled FROM schema_dual_writes WHERE name = $1`, name).Scan(&enabled)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return enabled, err
+}
+
+// SetDualWrite turns a dual-write toggle on or off
+func (db *DB) SetDualWrite(ctx context.Context, name string, enabled bool) error {
+	_, err := db.Pool.Exec(ctx, `
+		INSERT INTO schema_dual_writes (name, enabled) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET enabled = EXCLUDED.enabled, updated_at = NOW()
+	`, name, enabled)
+	return err
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"log"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/user/bla-back/internal/metrics"
+)
+
+const (
+	modulePrefix  = "github.com/user/bla-back/"
+	maxLoggedSQL  = 300
+	unknownCaller = "unknown"
+)
+
+type queryTraceKey struct{}
+
+type queryTrace struct {
+	sql   string
+	start time.Time
+}
+
+// SlowQueryTracer logs and counts queries that take longer than a threshold
+type SlowQueryTracer struct {
+	threshold time.Duration
+}
+
+func NewSlowQueryTracer(threshold time.Duration) *SlowQueryTracer {
+	return &SlowQueryTracer{threshold: threshold}
+}
+
+func (t *SlowQueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryTraceKey{}, queryTrace{sql: data.SQL, start: time.Now()})
+}
+
+func (t *SlowQueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	trace, ok := ctx.Value(queryTraceKey{}).(queryTrace)
+	if !ok {
+		return
+	}
+
+	elapsed := time.Since(trace.start)
+	if elapsed < t.threshold {
+		return
+	}
+
+	// Rows are closed (and the query ends) inside the repository method, so it is still on the stack
+	caller := queryCaller()
+	metrics.DBSlowQueries.WithLabelValues(caller).Inc()
+
+	if data.Err != nil {
+		log.Printf("Slow query (%s) in %s: %s: %v", elapsed, caller, compactSQL(trace.sql), data.Err)
+		return
+	}
+	log.Printf("Slow query (%s) in %s: %s", elapsed, caller, compactSQL(trace.sql))
+}
+
+// queryCaller returns the first function on the stack that belongs to this module, e.g. "messages.(*Repository).GetMessages"
+func queryCaller() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	for {
+		frame, more := frames.Next()
+		if strings.HasPrefix(frame.Function, modulePrefix) && !strings.HasPrefix(frame.Function, modulePrefix+"internal/database.") {
+			name := strings.TrimPrefix(frame.Function, modulePrefix)
+			return name[strings.LastIndex(name, "/")+1:]
+		}
+		if !more {
+			return unknownCaller
+		}
+	}
+}
+
+// compactSQL collapses whitespace in a statement so it fits on one log line
+func compactSQL(sql string) string {
+	sql = strings.Join(strings.Fields(sql), " ")
+	if len(sql) > maxLoggedSQL {
+		sql = sql[:maxLoggedSQL] + "..."
+	}
+	return sql
+}
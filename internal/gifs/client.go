@@ -0,0 +1,258 @@
+package gifs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/user/bla-back/internal/models"
+)
+
+const (
+	ProviderTenor = "tenor"
+	ProviderGiphy = "giphy"
+
+	requestTimeout = 5 * time.Second
+	MaxLimit       = 50
+)
+
+var (
+	ErrDisabled    = errors.New("gif search is not configured")
+	ErrGIFNotFound = errors.New("gif not found")
+)
+
+// Client searches a GIF provider (Tenor or Giphy) so the API key stays on the server
+type Client struct {
+	provider string
+	apiKey   string
+	http     *http.Client
+}
+
+// NewClient returns a client for provider; an empty apiKey disables GIF search
+func NewClient(provider, apiKey string) *Client {
+	return &Client{
+		provider: provider,
+		apiKey:   apiKey,
+		http:     &http.Client{Timeout: requestTimeout},
+	}
+}
+
+func (c *Client) Enabled() bool {
+	return c.apiKey != "" && (c.provider == ProviderTenor || c.provider == ProviderGiphy)
+}
+
+func (c *Client) Provider() string {
+	return c.provider
+}
+
+// Search returns a page of GIFs for query. pos is the opaque cursor from the previous page.
+func (c *Client) Search(ctx context.Context, query string, limit int, pos string) (*models.GIFSearchResult, error) {
+	if !c.Enabled() {
+		return nil, ErrDisabled
+	}
+	if c.provider == ProviderGiphy {
+		return c.searchGiphy(ctx, query, limit, pos)
+	}
+	return c.searchTenor(ctx, query, limit, pos)
+}
+
+// Get looks up a single GIF by its provider ID
+func (c *Client) Get(ctx context.Context, id string) (*models.GIF, error) {
+	if !c.Enabled() {
+		return nil, ErrDisabled
+	}
+	if c.provider == ProviderGiphy {
+		return c.getGiphy(ctx, id)
+	}
+	return c.getTenor(ctx, id)
+}
+
+func (c *Client) getJSON(ctx context.Context, endpoint string, params url.Values, dest interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrGIFNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected status %d", c.provider, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(dest)
+}
+
+// Tenor v2
+
+type tenorMedia struct {
+	URL  string `json:"url"`
+	Dims []int  `json:"dims"`
+	Size int64  `json:"size"`
+}
+
+type tenorResult struct {
+	ID           string                `json:"id"`
+	Description  string                `json:"content_description"`
+	MediaFormats map[string]tenorMedia `json:"media_formats"`
+}
+
+type tenorResponse struct {
+	Results []tenorResult `json:"results"`
+	Next    string        `json:"next"`
+}
+
+func (t tenorResult) toGIF() *models.GIF {
+	full := t.MediaFormats["gif"]
+	preview, ok := t.MediaFormats["tinygif"]
+	if !ok {
+		preview = full
+	}
+	gif := &models.GIF{
+		ID:         t.ID,
+		Title:      t.Description,
+		URL:        full.URL,
+		PreviewURL: preview.URL,
+		Size:       full.Size,
+	}
+	if len(full.Dims) == 2 {
+		gif.Width, gif.Height = full.Dims[0], full.Dims[1]
+	}
+	return gif
+}
+
+func (c *Client) tenorParams() url.Values {
+	params := url.Values{}
+	params.Set("key", c.apiKey)
+	params.Set("client_key", "bla")
+	params.Set("media_filter", "gif,tinygif")
+	params.Set("contentfilter", "medium")
+	return params
+}
+
+func (c *Client) searchTenor(ctx context.Context, query string, limit int, pos string) (*models.GIFSearchResult, error) {
+	params := c.tenorParams()
+	params.Set("q", query)
+	params.Set("limit", strconv.Itoa(limit))
+	if pos != "" {
+		params.Set("pos", pos)
+	}
+
+	var resp tenorResponse
+	if err := c.getJSON(ctx, "https://tenor.googleapis.com/v2/search", params, &resp); err != nil {
+		return nil, err
+	}
+
+	result := &models.GIFSearchResult{Results: make([]*models.GIF, 0, len(resp.Results)), Next: resp.Next}
+	for _, r := range resp.Results {
+		result.Results = append(result.Results, r.toGIF())
+	}
+	return result, nil
+}
+
+func (c *Client) getTenor(ctx context.Context, id string) (*models.GIF, error) {
+	params := c.tenorParams()
+	params.Set("ids", id)
+
+	var resp tenorResponse
+	if err := c.getJSON(ctx, "https://tenor.googleapis.com/v2/posts", params, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Results) == 0 {
+		return nil, ErrGIFNotFound
+	}
+	return resp.Results[0].toGIF(), nil
+}
+
+// Giphy v1
+
+type giphyImage struct {
+	URL    string `json:"url"`
+	Width  string `json:"width"`
+	Height string `json:"height"`
+	Size   string `json:"size"`
+}
+
+type giphyResult struct {
+	ID     string                `json:"id"`
+	Title  string                `json:"title"`
+	Images map[string]giphyImage `json:"images"`
+}
+
+func (g giphyResult) toGIF() *models.GIF {
+	full := g.Images["original"]
+	preview, ok := g.Images["fixed_width_small"]
+	if !ok {
+		preview = full
+	}
+	width, _ := strconv.Atoi(full.Width)
+	height, _ := strconv.Atoi(full.Height)
+	size, _ := strconv.ParseInt(full.Size, 10, 64)
+	return &models.GIF{
+		ID:         g.ID,
+		Title:      g.Title,
+		URL:        full.URL,
+		PreviewURL: preview.URL,
+		Width:      width,
+		Height:     height,
+		Size:       size,
+	}
+}
+
+func (c *Client) searchGiphy(ctx context.Context, query string, limit int, pos string) (*models.GIFSearchResult, error) {
+	offset, _ := strconv.Atoi(pos)
+
+	params := url.Values{}
+	params.Set("api_key", c.apiKey)
+	params.Set("q", query)
+	params.Set("limit", strconv.Itoa(limit))
+	params.Set("offset", strconv.Itoa(offset))
+	params.Set("rating", "pg-13")
+
+	var resp struct {
+		Data       []giphyResult `json:"data"`
+		Pagination struct {
+			Offset     int `json:"offset"`
+			Count      int `json:"count"`
+			TotalCount int `json:"total_count"`
+		} `json:"pagination"`
+	}
+	if err := c.getJSON(ctx, "https://api.giphy.com/v1/gifs/search", params, &resp); err != nil {
+		return nil, err
+	}
+
+	result := &models.GIFSearchResult{Results: make([]*models.GIF, 0, len(resp.Data))}
+	for _, r := range resp.Data {
+		result.Results = append(result.Results, r.toGIF())
+	}
+	if next := resp.Pagination.Offset + resp.Pagination.Count; resp.Pagination.Count > 0 && next < resp.Pagination.TotalCount {
+		result.Next = strconv.Itoa(next)
+	}
+	return result, nil
+}
+
+func (c *Client) getGiphy(ctx context.Context, id string) (*models.GIF, error) {
+	params := url.Values{}
+	params.Set("api_key", c.apiKey)
+
+	var resp struct {
+		Data giphyResult `json:"data"`
+	}
+	if err := c.getJSON(ctx, "https://api.giphy.com/v1/gifs/"+url.PathEscape(id), params, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Data.ID == "" {
+		return nil, ErrGIFNotFound
+	}
+	return resp.Data.toGIF(), nil
+}
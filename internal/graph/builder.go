@@ -0,0 +1,225 @@
+package graph
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/user/bla-back/internal/models"
+)
+
+// builderLock keeps a single instance computing snapshots at a time
+const builderLock = 7342004
+
+// histogramCap is the degree above which nodes share one histogram bucket
+const histogramCap = 100
+
+// Builder periodically computes anonymized graph snapshots for the admin analytics
+// endpoints, so they never run graph queries on demand
+type Builder struct {
+	db       *pgxpool.Pool
+	repo     *Repository
+	interval time.Duration
+}
+
+func NewBuilder(db *pgxpool.Pool, repo *Repository, interval time.Duration) *Builder {
+	return &Builder{
+		db:       db,
+		repo:     repo,
+		interval: interval,
+	}
+}
+
+// Run builds snapshots that are missing or older than interval right away, then every
+// interval until ctx is cancelled
+func (b *Builder) Run(ctx context.Context) {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := b.build(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to build graph snapshots: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (b *Builder) build(ctx context.Context) error {
+	conn, err := b.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	var locked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, builderLock).Scan(&locked); err != nil {
+		return err
+	}
+	if !locked {
+		return nil
+	}
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, builderLock)
+
+	users, err := b.repo.users(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, kind := range Kinds {
+		// Another instance may have built it moments ago
+		last, err := b.repo.lastComputed(ctx, kind)
+		if err != nil {
+			return err
+		}
+		if last != nil && time.Since(*last) < b.interval/2 {
+			continue
+		}
+
+		edges, err := b.repo.edges(ctx, kind)
+		if err != nil {
+			return err
+		}
+		snapshot := anonymize(kind, users, edges)
+		if err := b.repo.save(ctx, snapshot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// anonymize replaces user IDs with node numbers handed out in random order and computes
+// the graph's statistics
+func anonymize(kind string, users []uuid.UUID, edges []userEdge) *models.GraphSnapshot {
+	order := rand.Perm(len(users))
+	index := make(map[uuid.UUID]int, len(users))
+	for i, id := range users {
+		index[id] = order[i]
+	}
+
+	snapshot := &models.GraphSnapshot{
+		Kind:       kind,
+		ComputedAt: time.Now(),
+		Nodes:      make([]models.GraphNode, len(users)),
+		Edges:      make([]models.GraphEdge, 0, len(edges)),
+	}
+	for i := range snapshot.Nodes {
+		snapshot.Nodes[i].ID = i
+	}
+
+	components := newUnionFind(len(users))
+	for _, e := range edges {
+		a, okA := index[e.a]
+		bIdx, okB := index[e.b]
+		if !okA || !okB {
+			continue // user deleted while loading
+		}
+		snapshot.Edges = append(snapshot.Edges, models.GraphEdge{Source: a, Target: bIdx, Weight: e.weight})
+		snapshot.Nodes[a].Degree++
+		snapshot.Nodes[bIdx].Degree++
+		components.union(a, bIdx)
+	}
+	// Don't leak the load order through the edge order either
+	slices.SortFunc(snapshot.Edges, func(x, y models.GraphEdge) int {
+		if x.Source != y.Source {
+			return x.Source - y.Source
+		}
+		return x.Target - y.Target
+	})
+
+	snapshot.Stats = computeStats(snapshot.Nodes, len(snapshot.Edges), components)
+	return snapshot
+}
+
+func computeStats(nodes []models.GraphNode, edges int, components *unionFind) models.GraphStats {
+	stats := models.GraphStats{
+		Nodes:           len(nodes),
+		Edges:           edges,
+		DegreeHistogram: []models.DegreeCount{},
+	}
+	if len(nodes) == 0 {
+		return stats
+	}
+
+	degrees := make([]int, len(nodes))
+	histogram := map[int]int{}
+	sum := 0
+	for i, n := range nodes {
+		degrees[i] = n.Degree
+		sum += n.Degree
+		if n.Degree == 0 {
+			stats.Isolated++
+		}
+		histogram[min(n.Degree, histogramCap)]++
+	}
+	slices.Sort(degrees)
+
+	stats.Degree = models.DegreeStats{
+		Min:    degrees[0],
+		Max:    degrees[len(degrees)-1],
+		Mean:   float64(sum) / float64(len(degrees)),
+		Median: degrees[len(degrees)/2],
+		P90:    degrees[len(degrees)*9/10],
+	}
+	for degree, count := range histogram {
+		stats.DegreeHistogram = append(stats.DegreeHistogram, models.DegreeCount{Degree: degree, Nodes: count})
+	}
+	slices.SortFunc(stats.DegreeHistogram, func(x, y models.DegreeCount) int { return x.Degree - y.Degree })
+
+	stats.Components, stats.LargestComponent = components.sizes()
+	return stats
+}
+
+// unionFind tracks connected components
+type unionFind struct {
+	parent []int
+	size   []int
+}
+
+func newUnionFind(n int) *unionFind {
+	u := &unionFind{parent: make([]int, n), size: make([]int, n)}
+	for i := range u.parent {
+		u.parent[i] = i
+		u.size[i] = 1
+	}
+	return u
+}
+
+func (u *unionFind) find(x int) int {
+	for u.parent[x] != x {
+		u.parent[x] = u.parent[u.parent[x]]
+		x = u.parent[x]
+	}
+	return x
+}
+
+func (u *unionFind) union(a, b int) {
+	ra, rb := u.find(a), u.find(b)
+	if ra == rb {
+		return
+	}
+	if u.size[ra] < u.size[rb] {
+		ra, rb = rb, ra
+	}
+	u.parent[rb] = ra
+	u.size[ra] += u.size[rb]
+}
+
+// sizes returns the number of components and the size of the largest
+func (u *unionFind) sizes() (count, largest int) {
+	for i := range u.parent {
+		if u.find(i) == i {
+			count++
+			largest = max(largest, u.size[i])
+		}
+	}
+	return count, largest
+}
@@ -0,0 +1,213 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/user/bla-back/internal/models"
+)
+
+const (
+	KindFriendship   = "friendship"
+	KindConversation = "conversation"
+
+	// Larger groups are left out of the conversation graph: sharing a big group says
+	// little about two users, and it would add members^2 edges
+	maxGroupSize = 100
+
+	// Snapshots kept per kind
+	keepSnapshots = 7
+)
+
+var Kinds = []string{KindFriendship, KindConversation}
+
+var ErrSnapshotNotFound = errors.New("no graph snapshot computed yet")
+
+type Repository struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{db: db}
+}
+
+// userEdge is an undirected edge between two users, a < b
+type userEdge struct {
+	a, b   uuid.UUID
+	weight int
+}
+
+// users returns every active user, so users without edges show up as isolated nodes
+func (r *Repository) users(ctx context.Context) ([]uuid.UUID, error) {
+	rows, err := r.db.Query(ctx, `SELECT id FROM users`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *Repository) edges(ctx context.Context, kind string) ([]userEdge, error) {
+	var query string
+	switch kind {
+	case KindFriendship:
+		query = `
+			SELECT LEAST(from_user_id, to_user_id), GREATEST(from_user_id, to_user_id), 1
+			FROM friend_requests
+			WHERE status = 'accepted'
+		`
+	case KindConversation:
+		// Message requests that weren't accepted don't connect anyone
+		query = `
+			WITH small AS (
+				SELECT c.id FROM conversations c
+				JOIN conversation_participants cp ON cp.conversation_id = c.id
+				WHERE c.deleted_at IS NULL AND c.request_status IS NULL
+				GROUP BY c.id
+				HAVING COUNT(*) <= $1
+			)
+			SELECT a.user_id, b.user_id, COUNT(*)
+			FROM small
+			JOIN conversation_participants a ON a.conversation_id = small.id
+			JOIN conversation_participants b ON b.conversation_id = small.id AND a.user_id < b.user_id
+			GROUP BY a.user_id, b.user_id
+		`
+	default:
+		return nil, ErrSnapshotNotFound
+	}
+
+	var args []any
+	if kind == KindConversation {
+		args = append(args, maxGroupSize)
+	}
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var edges []userEdge
+	for rows.Next() {
+		var e userEdge
+		var weight int64
+		if err := rows.Scan(&e.a, &e.b, &weight); err != nil {
+			return nil, err
+		}
+		e.weight = int(weight)
+		edges = append(edges, e)
+	}
+	return edges, rows.Err()
+}
+
+// save stores a snapshot and drops the oldest ones of its kind
+func (r *Repository) save(ctx context.Context, snapshot *models.GraphSnapshot) error {
+	stats, err := json.Marshal(snapshot.Stats)
+	if err != nil {
+		return err
+	}
+	graph, err := json.Marshal(struct {
+		Nodes []models.GraphNode `json:"nodes"`
+		Edges []models.GraphEdge `json:"edges"`
+	}{snapshot.Nodes, snapshot.Edges})
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO graph_snapshots (kind, computed_at, stats, graph)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, snapshot.Kind, snapshot.ComputedAt, stats, graph).Scan(&snapshot.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		DELETE FROM graph_snapshots
+		WHERE kind = $1 AND id NOT IN (
+			SELECT id FROM graph_snapshots WHERE kind = $1 ORDER BY computed_at DESC LIMIT $2
+		)
+	`, snapshot.Kind, keepSnapshots)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ListSnapshots returns the stored snapshots without their nodes and edges, newest first
+func (r *Repository) ListSnapshots(ctx context.Context) ([]*models.GraphSnapshot, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, kind, computed_at, stats FROM graph_snapshots ORDER BY computed_at DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := []*models.GraphSnapshot{}
+	for rows.Next() {
+		s := &models.GraphSnapshot{}
+		var stats []byte
+		if err := rows.Scan(&s.ID, &s.Kind, &s.ComputedAt, &stats); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(stats, &s.Stats); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, rows.Err()
+}
+
+// GetLatestSnapshot returns the newest full snapshot of a kind
+func (r *Repository) GetLatestSnapshot(ctx context.Context, kind string) (*models.GraphSnapshot, error) {
+	s := &models.GraphSnapshot{}
+	var stats, graph []byte
+	err := r.db.QueryRow(ctx, `
+		SELECT id, kind, computed_at, stats, graph FROM graph_snapshots
+		WHERE kind = $1
+		ORDER BY computed_at DESC
+		LIMIT 1
+	`, kind).Scan(&s.ID, &s.Kind, &s.ComputedAt, &stats, &graph)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSnapshotNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(stats, &s.Stats); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(graph, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// lastComputed returns when the newest snapshot of a kind was computed (nil if none)
+func (r *Repository) lastComputed(ctx context.Context, kind string) (*time.Time, error) {
+	var t *time.Time
+	err := r.db.QueryRow(ctx, `SELECT MAX(computed_at) FROM graph_snapshots WHERE kind = $1`, kind).Scan(&t)
+	return t, err
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/auth"
+	"github.com/user/bla-back/internal/models"
+)
+
+// CreateAccessToken issues a scoped personal access token. The token is only shown in
+// this response.
+func (h *AuthHandler) CreateAccessToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.CreateAccessTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+		return
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresInDays > 0 {
+		t := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		expiresAt = &t
+	}
+
+	token, err := h.repo.CreateAccessToken(r.Context(), userID, req.Name, req.Scopes, expiresAt)
+	if err != nil {
+		if errors.Is(err, auth.ErrTooManyAccessTokens) {
+			respondError(w, http.StatusConflict, "Too many active access tokens, revoke one first")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to create access token")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, token)
+}
+
+// ListAccessTokens lists the user's active personal access tokens with their usage
+func (h *AuthHandler) ListAccessTokens(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	tokens, err := h.repo.ListAccessTokens(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to list access tokens")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, tokens)
+}
+
+// RevokeAccessToken disables a personal access token
+func (h *AuthHandler) RevokeAccessToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	tokenID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid token ID")
+		return
+	}
+
+	if err := h.repo.RevokeAccessToken(r.Context(), userID, tokenID); err != nil {
+		if errors.Is(err, auth.ErrAccessTokenNotFound) {
+			respondError(w, http.StatusNotFound, "Access token not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to revoke access token")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Access token revoked"})
+}
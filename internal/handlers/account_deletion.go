@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/models"
+)
+
+// SetDeletionGrace sets how long a deleted account can be recovered by signing back in
+func (h *AuthHandler) SetDeletionGrace(grace time.Duration) {
+	h.deletionGrace = grace
+}
+
+// DeleteAccount marks the user's account for deletion and signs it out everywhere. The
+// purge job anonymizes it once the grace period passes; signing in before then cancels it.
+func (h *AuthHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	requestedAt, err := h.repo.RequestDeletion(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to delete account")
+		return
+	}
+	h.disconnectRealtime(userID)
+
+	respondJSON(w, http.StatusAccepted, models.AccountDeletionResponse{
+		DeleteAfter: requestedAt.Add(h.deletionGrace),
+	})
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/user/bla-back/internal/auth"
+	"github.com/user/bla-back/internal/calls"
+	"github.com/user/bla-back/internal/graph"
+	"github.com/user/bla-back/internal/mediacheck"
+	"github.com/user/bla-back/internal/models"
+)
+
+// AdminHandler serves operator-only endpoints (behind middleware.Admin)
+type AdminHandler struct {
+	authRepo  *auth.Repository
+	callsRepo *calls.Repository
+	mediaRepo *mediacheck.Repository
+	graphRepo *graph.Repository
+	validator *validator.Validate
+}
+
+func NewAdminHandler(authRepo *auth.Repository, callsRepo *calls.Repository, mediaRepo *mediacheck.Repository, graphRepo *graph.Repository) *AdminHandler {
+	return &AdminHandler{
+		authRepo:  authRepo,
+		callsRepo: callsRepo,
+		mediaRepo: mediaRepo,
+		graphRepo: graphRepo,
+		validator: validator.New(),
+	}
+}
+
+// CreateInviteCode generates a registration invite code
+func (h *AdminHandler) CreateInviteCode(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateInviteCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+		return
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresInHours > 0 {
+		t := time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour)
+		expiresAt = &t
+	}
+
+	invite, err := h.authRepo.CreateInviteCode(r.Context(), req.MaxUses, expiresAt, req.Note)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to create invite code")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, invite)
+}
+
+// ListInviteCodes returns all invite codes with usage
+func (h *AdminHandler) ListInviteCodes(w http.ResponseWriter, r *http.Request) {
+	invites, err := h.authRepo.ListInviteCodes(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get invite codes")
+		return
+	}
+
+	if invites == nil {
+		invites = []*models.InviteCode{}
+	}
+
+	respondJSON(w, http.StatusOK, invites)
+}
+
+// CallFeedbackSummary aggregates call ratings, reported issues and WebRTC stats
+// over the last ?days= days (default 7, max 90)
+func (h *AdminHandler) CallFeedbackSummary(w http.ResponseWriter, r *http.Request) {
+	days := 7
+	if d := r.URL.Query().Get("days"); d != "" {
+		if parsed, err := strconv.Atoi(d); err == nil && parsed > 0 && parsed <= 90 {
+			days = parsed
+		}
+	}
+
+	summary, err := h.callsRepo.FeedbackSummary(r.Context(), time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get call feedback")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, summary)
+}
+
+// BrokenMediaReport lists stored files the media checker could not find in the bucket,
+// grouped by kind, with the last run's totals
+func (h *AdminHandler) BrokenMediaReport(w http.ResponseWriter, r *http.Request) {
+	report, err := h.mediaRepo.GetRepairReport(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get media report")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, report)
+}
+
+// ListGraphSnapshots lists the stored friendship/conversation graph snapshots with their
+// stats, without nodes and edges
+func (h *AdminHandler) ListGraphSnapshots(w http.ResponseWriter, r *http.Request) {
+	snapshots, err := h.graphRepo.ListSnapshots(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to list graph snapshots")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, snapshots)
+}
+
+// GetGraphSnapshot exports the latest anonymized graph of a kind (friendship or
+// conversation) as nodes and weighted edges
+func (h *AdminHandler) GetGraphSnapshot(w http.ResponseWriter, r *http.Request) {
+	kind := r.PathValue("kind")
+	if !slices.Contains(graph.Kinds, kind) {
+		respondError(w, http.StatusNotFound, "Unknown graph kind")
+		return
+	}
+
+	snapshot, err := h.graphRepo.GetLatestSnapshot(r.Context(), kind)
+	if err != nil {
+		if errors.Is(err, graph.ErrSnapshotNotFound) {
+			respondError(w, http.StatusNotFound, "Graph not computed yet")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to get graph snapshot")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, snapshot)
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/messages"
+)
+
+// recordAudit adds an action to the group's audit log. The action has already happened,
+// so a failure is only logged.
+func (h *MessagesHandler) recordAudit(ctx context.Context, convID, actorID uuid.UUID, action string, targetID *uuid.UUID, details interface{}) {
+	if err := h.repo.RecordAudit(ctx, convID, actorID, action, targetID, details); err != nil {
+		log.Printf("Failed to record %s in conversation %s audit log: %v", action, convID, err)
+	}
+}
+
+// auditMembership records members added or removed by someone else. Joins and leaves
+// users do themselves aren't administrative actions.
+func (h *MessagesHandler) auditMembership(ctx context.Context, convID uuid.UUID, eventType string, userIDs []uuid.UUID, actorID *uuid.UUID, reason string) {
+	if actorID == nil || reason == "left" {
+		return
+	}
+
+	action := messages.AuditMemberAdd
+	if eventType == "MEMBERS_REMOVE" {
+		action = messages.AuditMemberRemove
+		if reason == "banned" {
+			action = messages.AuditMemberBan
+		}
+	}
+	for _, id := range userIDs {
+		h.recordAudit(ctx, convID, *actorID, action, &id, map[string]string{"reason": reason})
+	}
+}
+
+// GetAuditLog lists a group's administrative actions, newest first (owners and admins).
+// Supports ?action=, ?before= (RFC 3339) and ?limit= (default 50, max 100).
+func (h *MessagesHandler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	limit := 50
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		}
+	}
+
+	before := time.Now()
+	if b := r.URL.Query().Get("before"); b != "" {
+		before, err = time.Parse(time.RFC3339Nano, b)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid before timestamp")
+			return
+		}
+	}
+
+	entries, err := h.repo.GetAuditLog(r.Context(), convID, userID, r.URL.Query().Get("action"), before, limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, messages.ErrNotParticipant):
+			respondError(w, http.StatusForbidden, "Not a participant")
+		case errors.Is(err, messages.ErrPermissionDenied):
+			respondError(w, http.StatusForbidden, "Only group owners and admins can view the audit log")
+		default:
+			respondError(w, http.StatusInternalServerError, "Failed to get audit log")
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusOK, entries)
+}
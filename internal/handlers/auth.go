@@ -3,28 +3,67 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"github.com/user/bla-back/internal/auth"
+	"github.com/user/bla-back/internal/cache"
+	"github.com/user/bla-back/internal/captcha"
 	"github.com/user/bla-back/internal/models"
+	"github.com/user/bla-back/internal/notifications"
+	"github.com/user/bla-back/internal/oauth"
+	"github.com/user/bla-back/internal/passkeys"
+	"github.com/user/bla-back/internal/realtime"
+	"github.com/user/bla-back/internal/schedule"
 	"github.com/user/bla-back/internal/storage"
 )
 
 type AuthHandler struct {
-	repo      *auth.Repository
-	tokens    *auth.TokenService
-	storage   *storage.S3Storage
-	validator *validator.Validate
+	repo       *auth.Repository
+	tokens     *auth.TokenService
+	storage    *storage.S3Storage
+	validator  *validator.Validate
+	inviteOnly bool
+	ages       auth.AgePolicy
+
+	// passkeys signs users in with WebAuthn (see SetPasskeys)
+	passkeys    *passkeys.Service
+	passkeyRepo *passkeys.Repository
+
+	// oauth signs users in with external accounts (see SetOAuth)
+	oauth  *oauth.Registry
+	appURL string
+
+	// deletionGrace is how long a deleted account can be recovered by signing back in
+	deletionGrace time.Duration
+
+	// limiter throttles logins and registrations (nil = no limits, see SetRateLimiter)
+	limiter *cache.RedisCache
+
+	// magicLinks holds emailed sign-in links until they're used (see SetMagicLinks)
+	magicLinks *cache.RedisCache
+
+	// captcha guards registration and login against bots (see SetCaptcha)
+	captcha *captcha.Client
+
+	// rt and mailer alert users to sign-ins from new devices (see SetLoginAlerts); rt also
+	// closes the connections of users signed out everywhere
+	rt     *realtime.Node
+	mailer *notifications.EmailChannel
 }
 
-func NewAuthHandler(repo *auth.Repository, tokens *auth.TokenService, storage *storage.S3Storage) *AuthHandler {
+func NewAuthHandler(repo *auth.Repository, tokens *auth.TokenService, storage *storage.S3Storage, inviteOnly bool, ages auth.AgePolicy) *AuthHandler {
 	return &AuthHandler{
-		repo:      repo,
-		tokens:    tokens,
-		storage:   storage,
-		validator: validator.New(),
+		repo:       repo,
+		tokens:     tokens,
+		storage:    storage,
+		validator:  validator.New(),
+		inviteOnly: inviteOnly,
+		ages:       ages,
 	}
 }
 
@@ -40,23 +79,49 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !h.allowAuthAttempt(w, r, "register", req.Email) {
+		return
+	}
+	if !checkCaptcha(w, r, h.captcha) {
+		return
+	}
+
+	// Birthdate is optional; when given it must meet the regional minimum age
+	var birthdate *time.Time
+	if req.Birthdate != "" {
+		t, _ := time.Parse("2006-01-02", req.Birthdate)
+		if !h.checkAge(w, r, t, req.Region) {
+			return
+		}
+		birthdate = &t
+	}
+
 	passwordHash, err := auth.HashPassword(req.Password)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to process password")
 		return
 	}
 
-	user, err := h.repo.CreateUser(r.Context(), req.Email, passwordHash)
+	var user *models.User
+	if h.inviteOnly {
+		user, err = h.repo.CreateUserWithInvite(r.Context(), req.Email, passwordHash, birthdate, req.InviteCode)
+	} else {
+		user, err = h.repo.CreateUser(r.Context(), req.Email, passwordHash, birthdate)
+	}
 	if err != nil {
 		if errors.Is(err, auth.ErrUserExists) {
 			respondError(w, http.StatusConflict, "User with this email already exists")
 			return
 		}
+		if errors.Is(err, auth.ErrInvalidInviteCode) {
+			respondError(w, http.StatusForbidden, "Registration requires a valid invite code")
+			return
+		}
 		respondError(w, http.StatusInternalServerError, "Failed to create user")
 		return
 	}
 
-	tokens, err := h.generateTokens(r, user.ID)
+	tokens, err := h.signIn(r, user.ID)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to generate tokens")
 		return
@@ -69,6 +134,99 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// checkAge enforces the age policy, writing an error response when it fails
+func (h *AuthHandler) checkAge(w http.ResponseWriter, r *http.Request, birthdate time.Time, region string) bool {
+	if region == "" {
+		region = r.Header.Get("CF-IPCountry")
+	}
+
+	err := h.ages.Check(birthdate, region)
+	if errors.Is(err, auth.ErrInvalidBirthdate) {
+		respondError(w, http.StatusBadRequest, "Invalid birthdate")
+		return false
+	}
+	if errors.Is(err, auth.ErrUnderMinimumAge) {
+		respondError(w, http.StatusForbidden, fmt.Sprintf("You must be at least %d years old", h.ages.MinAgeFor(region)))
+		return false
+	}
+	return true
+}
+
+// SetBirthdate lets users who registered without a birthdate add one (once)
+func (h *AuthHandler) SetBirthdate(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.SetBirthdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+		return
+	}
+
+	birthdate, _ := time.Parse("2006-01-02", req.Birthdate)
+	if !h.checkAge(w, r, birthdate, req.Region) {
+		return
+	}
+
+	if err := h.repo.SetBirthdate(r.Context(), userID, birthdate); err != nil {
+		if errors.Is(err, auth.ErrBirthdateSet) {
+			respondError(w, http.StatusConflict, "Birthdate already set")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to set birthdate")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Birthdate set"})
+}
+
+// RegistrationInfo tells clients whether registration needs an invite code, and which
+// CAPTCHA widget to show when one is required
+func (h *AuthHandler) RegistrationInfo(w http.ResponseWriter, r *http.Request) {
+	info := models.RegistrationInfo{InviteOnly: h.inviteOnly}
+	if h.captcha != nil && h.captcha.Enabled() {
+		info.CaptchaProvider = h.captcha.Provider()
+		info.CaptchaSiteKey = h.captcha.SiteKey()
+	}
+	respondJSON(w, http.StatusOK, info)
+}
+
+// JWKS serves the public keys access tokens are signed with, for other services that
+// validate them (empty while tokens are HMAC-signed)
+func (h *AuthHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondJSON(w, http.StatusOK, map[string]interface{}{"keys": h.tokens.JWKS()})
+}
+
+// JoinWaitlist captures an email while registration is invite-only
+func (h *AuthHandler) JoinWaitlist(w http.ResponseWriter, r *http.Request) {
+	var req models.WaitlistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+		return
+	}
+
+	if err := h.repo.JoinWaitlist(r.Context(), req.Email); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to join waitlist")
+		return
+	}
+
+	respondJSON(w, http.StatusAccepted, map[string]string{"status": "ok"})
+}
+
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req models.LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -81,9 +239,17 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !h.allowAuthAttempt(w, r, "login", req.Email) {
+		return
+	}
+	if !checkCaptcha(w, r, h.captcha) {
+		return
+	}
+
 	user, err := h.repo.GetUserByEmail(r.Context(), req.Email)
 	if err != nil {
 		if errors.Is(err, auth.ErrUserNotFound) {
+			h.recordLoginFailure(r.Context(), req.Email)
 			respondError(w, http.StatusUnauthorized, "Invalid credentials")
 			return
 		}
@@ -92,11 +258,13 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !auth.CheckPassword(req.Password, user.PasswordHash) {
+		h.recordLoginFailure(r.Context(), req.Email)
 		respondError(w, http.StatusUnauthorized, "Invalid credentials")
 		return
 	}
+	h.clearLoginFailures(r.Context(), req.Email)
 
-	tokens, err := h.generateTokens(r, user.ID)
+	tokens, err := h.signIn(r, user.ID)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to generate tokens")
 		return
@@ -201,8 +369,63 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, map[string]string{"message": "Logged out successfully"})
 }
 
+// LogoutAll signs the user out on every device: refresh and personal access tokens are
+// revoked, access tokens issued so far stop validating and realtime connections are closed
+func (h *AuthHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.repo.RevokeAllTokens(r.Context(), userID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to log out")
+		return
+	}
+	h.disconnectRealtime(userID)
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Logged out on all devices"})
+}
+
+// disconnectRealtime closes the user's realtime connections, which only checked the
+// access token when they connected
+func (h *AuthHandler) disconnectRealtime(userID uuid.UUID) {
+	if h.rt != nil {
+		h.rt.DisconnectUser(userID)
+	}
+}
+
+// signIn issues tokens for a sign-in (as opposed to a refresh), checking where it came from
+func (h *AuthHandler) signIn(r *http.Request, userID uuid.UUID) (*models.TokenResponse, error) {
+	tokens, err := h.generateTokens(r, userID)
+	if err != nil {
+		return nil, err
+	}
+	h.checkLoginDevice(r, userID)
+	return tokens, nil
+}
+
 func (h *AuthHandler) generateTokens(r *http.Request, userID uuid.UUID) (*models.TokenResponse, error) {
-	accessToken, err := h.tokens.GenerateAccessToken(userID)
+	// Clients report their zone when signing in; it's kept unless the user picked one
+	if zone := r.Header.Get("X-Time-Zone"); schedule.ValidZone(zone) {
+		if err := h.repo.InferTimeZone(r.Context(), userID, zone); err != nil {
+			log.Printf("Failed to store time zone for user %s: %v", userID, err)
+		}
+	}
+
+	// Signing back in recovers an account marked for deletion
+	if cancelled, err := h.repo.CancelDeletion(r.Context(), userID); err != nil {
+		return nil, err
+	} else if cancelled {
+		log.Printf("User %s signed back in, account deletion cancelled", userID)
+	}
+
+	version, err := h.repo.TokenVersion(r.Context(), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := h.tokens.GenerateAccessToken(userID, version)
 	if err != nil {
 		return nil, err
 	}
@@ -275,3 +498,51 @@ func (h *AuthHandler) UploadAvatar(w http.ResponseWriter, r *http.Request) {
 
 	respondJSON(w, http.StatusOK, user)
 }
+
+// GetTimeZone returns the time zone scheduling features use for the user
+func (h *AuthHandler) GetTimeZone(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	zone, explicit, err := h.repo.GetTimeZone(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get time zone")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.TimeZoneSettings{TimeZone: zone, Explicit: explicit})
+}
+
+// SetTimeZone pins the user's time zone; an empty zone returns to inferring it from clients
+func (h *AuthHandler) SetTimeZone(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.SetTimeZoneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+		return
+	}
+	if req.TimeZone != "" && !schedule.ValidZone(req.TimeZone) {
+		respondError(w, http.StatusBadRequest, "Unknown time zone")
+		return
+	}
+
+	if err := h.repo.SetTimeZone(r.Context(), userID, req.TimeZone); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to set time zone")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.TimeZoneSettings{TimeZone: req.TimeZone, Explicit: req.TimeZone != ""})
+}
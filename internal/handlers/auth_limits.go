@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/bla-back/internal/cache"
+)
+
+const (
+	authLimitWindow   = 15 * time.Minute
+	authIPLimit       = 30 // login or registration attempts per IP per window
+	authEmailLimit    = 10 // login or registration attempts per email per window
+	loginFailureLimit = 5  // failed logins per email per window before the lockout
+	loginLockout      = 15 * time.Minute
+)
+
+// SetRateLimiter throttles login and registration attempts
+func (h *AuthHandler) SetRateLimiter(limiter *cache.RedisCache) {
+	h.limiter = limiter
+}
+
+// clientIP is the address the request came from (middleware.RealIP resolves it behind
+// trusted proxies)
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func loginLockoutKey(email string) string {
+	return "auth:lockout:" + normalizeEmail(email)
+}
+
+func loginFailuresKey(email string) string {
+	return "auth:failures:" + normalizeEmail(email)
+}
+
+// respondTooManyAttempts responds 429, telling the client when to try again
+func respondTooManyAttempts(w http.ResponseWriter, retryAfter time.Duration) {
+	if retryAfter <= 0 {
+		retryAfter = authLimitWindow
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	respondError(w, http.StatusTooManyRequests, "Too many attempts, try again later")
+}
+
+// allowAuthAttempt counts a login or registration attempt against the per-IP and
+// per-email limits, and refuses logins to a locked-out email. It responds 429 when the
+// attempt isn't allowed. Redis errors let attempts through.
+func (h *AuthHandler) allowAuthAttempt(w http.ResponseWriter, r *http.Request, action, email string) bool {
+	if h.limiter == nil {
+		return true
+	}
+	ctx := r.Context()
+
+	if action == "login" {
+		if ttl, err := h.limiter.TTL(ctx, loginLockoutKey(email)); err == nil && ttl > 0 {
+			respondTooManyAttempts(w, ttl)
+			return false
+		}
+	}
+
+	for _, limit := range []struct {
+		key   string
+		limit int
+	}{
+		{"ratelimit:auth:" + action + ":ip:" + clientIP(r), authIPLimit},
+		{"ratelimit:auth:" + action + ":email:" + normalizeEmail(email), authEmailLimit},
+	} {
+		allowed, err := h.limiter.CheckRateLimit(ctx, limit.key, limit.limit, authLimitWindow)
+		if err == nil && !allowed {
+			ttl, _ := h.limiter.TTL(ctx, limit.key)
+			respondTooManyAttempts(w, ttl)
+			return false
+		}
+	}
+	return true
+}
+
+// recordLoginFailure counts a failed login, locking the email out once there are too many
+func (h *AuthHandler) recordLoginFailure(ctx context.Context, email string) {
+	if h.limiter == nil {
+		return
+	}
+
+	allowed, err := h.limiter.CheckRateLimit(ctx, loginFailuresKey(email), loginFailureLimit-1, authLimitWindow)
+	if err != nil || allowed {
+		return
+	}
+	if err := h.limiter.Set(ctx, loginLockoutKey(email), []byte("1"), loginLockout); err != nil {
+		log.Printf("Failed to lock out logins: %v", err)
+		return
+	}
+	h.limiter.Delete(ctx, loginFailuresKey(email))
+}
+
+// clearLoginFailures forgets failed logins once the user gets their password right
+func (h *AuthHandler) clearLoginFailures(ctx context.Context, email string) {
+	if h.limiter == nil {
+		return
+	}
+	h.limiter.Delete(ctx, loginFailuresKey(email))
+}
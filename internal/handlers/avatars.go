@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/auth"
+	"github.com/user/bla-back/internal/avatars"
+)
+
+type AvatarsHandler struct {
+	users     UsersRepository
+	generator *avatars.Generator
+}
+
+func NewAvatarsHandler(users UsersRepository, generator *avatars.Generator) *AvatarsHandler {
+	return &AvatarsHandler{
+		users:     users,
+		generator: generator,
+	}
+}
+
+// Generated serves the initials avatar of a user, for clients to show when avatar_url is empty
+func (h *AvatarsHandler) Generated(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	size := avatars.DefaultSize
+	if s := r.URL.Query().Get("size"); s != "" {
+		parsed, err := strconv.Atoi(s)
+		if err != nil || parsed < avatars.MinSize || parsed > avatars.MaxSize {
+			respondError(w, http.StatusBadRequest, "size must be between 32 and 512")
+			return
+		}
+		size = parsed
+	}
+
+	user, err := h.users.GetUserByID(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, auth.ErrUserNotFound) {
+			respondError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to get user")
+		return
+	}
+
+	// Users who haven't picked a username yet get the initials of their email
+	name, _, _ := strings.Cut(user.Email, "@")
+	if user.Username != nil {
+		name = *user.Username
+	}
+
+	// The image changes with the username, so revalidate instead of caching forever
+	etag := avatars.ETag(userID, name, size)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	data, err := h.generator.PNG(r.Context(), userID, name, size)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to render avatar")
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
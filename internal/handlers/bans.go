@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/messages"
+	"github.com/user/bla-back/internal/models"
+)
+
+// RemoveParticipant removes a participant ranked below the caller from a group.
+// ?ban=true also bans them from being added back or rejoining.
+func (h *MessagesHandler) RemoveParticipant(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	targetID, err := uuid.Parse(r.PathValue("userId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	ban := r.URL.Query().Get("ban") == "true"
+
+	// Everyone who was in the group, including the removed user, hears about it
+	participantIDs, _ := h.repo.GetConversationParticipantIDs(r.Context(), convID)
+
+	if err := h.repo.RemoveParticipant(r.Context(), convID, userID, targetID, ban); err != nil {
+		switch {
+		case errors.Is(err, messages.ErrConversationNotFound):
+			respondError(w, http.StatusNotFound, "Conversation not found")
+		case errors.Is(err, messages.ErrNotParticipant):
+			respondError(w, http.StatusForbidden, "Not a participant")
+		case errors.Is(err, messages.ErrPermissionDenied):
+			respondError(w, http.StatusForbidden, "You can only remove participants ranked below you")
+		case errors.Is(err, messages.ErrRemoveSelf):
+			respondError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, messages.ErrParticipantNotFound):
+			respondError(w, http.StatusNotFound, "User is not a participant")
+		default:
+			respondError(w, http.StatusInternalServerError, "Failed to remove participant")
+		}
+		return
+	}
+
+	// Stop relaying the group's canvas and events to the removed user's devices
+	h.rt.RevokeCanvas(convID, targetID)
+	h.rt.RevokeConversation(convID, targetID)
+
+	h.rt.PublishToUsers(participantIDs, "PARTICIPANT_REMOVE", &models.ParticipantRemoveEvent{
+		ConversationID: convID,
+		UserID:         targetID,
+		RemovedBy:      userID,
+		Banned:         ban,
+	})
+	reason := "removed"
+	if ban {
+		reason = "banned"
+	}
+	h.recordMembership(r.Context(), convID, "MEMBERS_REMOVE", []uuid.UUID{targetID}, &userID, reason)
+
+	conv, err := h.repo.GetConversation(r.Context(), convID, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get conversation")
+		return
+	}
+	remainingIDs, _ := h.repo.GetConversationParticipantIDs(r.Context(), convID)
+	h.rt.PublishToUsers(remainingIDs, "CONVERSATION_UPDATE", conv)
+
+	respondJSON(w, http.StatusOK, conv)
+}
+
+// GetBans lists users banned from a group (owner only)
+func (h *MessagesHandler) GetBans(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	bans, err := h.repo.GetBans(r.Context(), convID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, messages.ErrNotParticipant):
+			respondError(w, http.StatusForbidden, "Not a participant")
+		case errors.Is(err, messages.ErrPermissionDenied):
+			respondError(w, http.StatusForbidden, "Only the group owner can manage bans")
+		default:
+			respondError(w, http.StatusInternalServerError, "Failed to get bans")
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusOK, bans)
+}
+
+// Unban lifts a user's ban from a group (owner only)
+func (h *MessagesHandler) Unban(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	targetID, err := uuid.Parse(r.PathValue("userId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if err := h.repo.Unban(r.Context(), convID, userID, targetID); err != nil {
+		switch {
+		case errors.Is(err, messages.ErrNotParticipant):
+			respondError(w, http.StatusForbidden, "Not a participant")
+		case errors.Is(err, messages.ErrPermissionDenied):
+			respondError(w, http.StatusForbidden, "Only the group owner can manage bans")
+		case errors.Is(err, messages.ErrBanNotFound):
+			respondError(w, http.StatusNotFound, "User is not banned")
+		default:
+			respondError(w, http.StatusInternalServerError, "Failed to unban user")
+		}
+		return
+	}
+
+	h.recordAudit(r.Context(), convID, userID, messages.AuditMemberUnban, &targetID, nil)
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "User unbanned"})
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/calls"
+	"github.com/user/bla-back/internal/transcribe"
+)
+
+// Caption chunks are a few seconds of one participant's audio
+const maxCaptionChunkSize = 1 << 20
+
+// SetTranscriber turns on live captions of call audio
+func (h *CallsHandler) SetTranscriber(transcriber *transcribe.Client) {
+	h.transcriber = transcriber
+}
+
+// ReceiveCaptionAudio lets the SFU post a chunk of one participant's audio
+// (?room=call-{id}&identity={participant identity}). The chunk is transcribed, stored in
+// the call's transcript and relayed to the call as CALL_CAPTION. The SFU authenticates
+// with an admin control token signed with the shared voice secret.
+func (h *CallsHandler) ReceiveCaptionAudio(w http.ResponseWriter, r *http.Request) {
+	if err := h.sfu.VerifyControl(r.Header.Get("Authorization")); err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if h.transcriber == nil || !h.transcriber.Enabled() {
+		http.Error(w, "Transcription is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	roomID, isCall := strings.CutPrefix(r.URL.Query().Get("room"), "call-")
+	callID, err := uuid.Parse(roomID)
+	if !isCall || err != nil {
+		http.Error(w, "Invalid room", http.StatusBadRequest)
+		return
+	}
+	userID, sessionID, err := calls.ParseIdentity(r.URL.Query().Get("identity"))
+	if err != nil {
+		http.Error(w, "Invalid identity", http.StatusBadRequest)
+		return
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "audio/") {
+		http.Error(w, "Body must be audio", http.StatusBadRequest)
+		return
+	}
+	audio, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCaptionChunkSize))
+	if err != nil {
+		http.Error(w, "Audio chunk too large (max 1MB)", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// Audio still buffered in the SFU after a participant left isn't captioned
+	inCall, err := h.callsRepo.IsActiveSession(r.Context(), callID, userID, sessionID)
+	if err != nil {
+		log.Printf("IsActiveSession error: %v", err)
+		http.Error(w, "Failed to check call", http.StatusInternalServerError)
+		return
+	}
+	if !inCall {
+		http.Error(w, "Not in this call", http.StatusForbidden)
+		return
+	}
+
+	text, err := h.transcriber.Transcribe(r.Context(), audio, contentType)
+	if err != nil {
+		log.Printf("Transcribe error: %v", err)
+		http.Error(w, "Failed to transcribe audio", http.StatusBadGateway)
+		return
+	}
+	if text == "" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	caption, err := h.callsRepo.AddCaption(r.Context(), callID, userID, text)
+	if err != nil {
+		log.Printf("AddCaption error: %v", err)
+		http.Error(w, "Failed to save caption", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.notifier.NotifyCall(callID, "CALL_CAPTION", caption); err != nil {
+		log.Printf("Failed to publish call caption: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(caption)
+}
+
+// GetCallTranscript returns the captions of a call to the users who took part in it
+func (h *CallsHandler) GetCallTranscript(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	callID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid call_id", http.StatusBadRequest)
+		return
+	}
+
+	captions, err := h.callsRepo.GetCaptions(r.Context(), callID, userID)
+	if err != nil {
+		if errors.Is(err, calls.ErrNotCallParticipant) {
+			http.Error(w, "Not a participant of this call", http.StatusForbidden)
+			return
+		}
+		log.Printf("GetCaptions error: %v", err)
+		http.Error(w, "Failed to get transcript", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"call_id":  callID,
+		"captions": captions,
+	})
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/calls"
+	"github.com/user/bla-back/internal/models"
+)
+
+const maxCallChatLength = 2000
+
+// SendCallChat posts a line to the in-call chat overlay (call participants only)
+func (h *CallsHandler) SendCallChat(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	callID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid call_id", http.StatusBadRequest)
+		return
+	}
+
+	var req models.CallChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Content = strings.TrimSpace(req.Content)
+	if req.Content == "" || utf8.RuneCountInString(req.Content) > maxCallChatLength {
+		http.Error(w, "Message must be 1-2000 characters", http.StatusBadRequest)
+		return
+	}
+
+	msg, err := h.callsRepo.AddChatMessage(r.Context(), callID, userID, req.Content)
+	if err != nil {
+		if errors.Is(err, calls.ErrNotInCall) {
+			http.Error(w, "Not in this call", http.StatusForbidden)
+			return
+		}
+		log.Printf("AddChatMessage error: %v", err)
+		http.Error(w, "Failed to send message", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.notifier.NotifyCall(callID, "CALL_CHAT_MESSAGE", msg); err != nil {
+		log.Printf("Failed to publish call chat: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(msg)
+}
+
+// SetCallChatTranscript chooses whether the call's chat is saved to the conversation when it ends
+func (h *CallsHandler) SetCallChatTranscript(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	callID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid call_id", http.StatusBadRequest)
+		return
+	}
+
+	var req models.CallChatTranscriptRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.callsRepo.SetChatTranscript(r.Context(), callID, userID, req.Enabled); err != nil {
+		if errors.Is(err, calls.ErrNotInCall) {
+			http.Error(w, "Not in this call", http.StatusForbidden)
+			return
+		}
+		log.Printf("SetChatTranscript error: %v", err)
+		http.Error(w, "Failed to update transcript setting", http.StatusInternalServerError)
+		return
+	}
+
+	// Everyone in the call should know whether the chat is being kept
+	event := &models.CallChatTranscriptEvent{CallID: callID, Enabled: req.Enabled, UserID: userID}
+	if err := h.notifier.NotifyCall(callID, "CALL_CHAT_TRANSCRIPT", event); err != nil {
+		log.Printf("Failed to publish call chat transcript setting: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(event)
+}
+
+// saveChatTranscript stores the chat of an ended call as a transcript message, if enabled,
+// and closes the call's chat channel
+func (h *CallsHandler) saveChatTranscript(ctx context.Context, info *calls.CallEndInfo) {
+	for _, userID := range info.Participants {
+		h.notifier.RevokeCall(info.CallID, userID)
+	}
+
+	lines, err := h.callsRepo.TakeChatTranscript(ctx, info.CallID)
+	if err != nil {
+		log.Printf("Failed to take call chat transcript: %v", err)
+		return
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	contentJSON, err := json.Marshal(models.CallTranscriptContent{
+		CallID: info.CallID.String(),
+		Lines:  lines,
+	})
+	if err != nil {
+		log.Printf("Failed to marshal call transcript: %v", err)
+		return
+	}
+
+	msg, err := h.msgRepo.CreateCallTranscriptMessage(ctx, info.ConversationID, info.StartedBy, string(contentJSON))
+	if err != nil {
+		log.Printf("Failed to create call transcript message: %v", err)
+		return
+	}
+
+	participantIDs, err := h.convRepo.GetParticipantIDs(ctx, info.ConversationID)
+	if err != nil {
+		log.Printf("Failed to get participant IDs: %v", err)
+		return
+	}
+
+	h.notifier.NotifyConversation(info.ConversationID, participantIDs, "MESSAGE_CREATE", map[string]interface{}{
+		"message":         msg,
+		"conversation_id": info.ConversationID,
+	})
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/calls"
+	"github.com/user/bla-back/internal/models"
+)
+
+// SubmitFeedback records a participant's rating, issue tags and client WebRTC stats for a call
+func (h *CallsHandler) SubmitFeedback(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	callID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid call_id", http.StatusBadRequest)
+		return
+	}
+
+	var req models.CallFeedbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		http.Error(w, "Validation failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, issue := range req.Issues {
+		if !calls.FeedbackIssues[issue] {
+			http.Error(w, "Unknown issue: "+issue, http.StatusBadRequest)
+			return
+		}
+	}
+
+	feedback, err := h.callsRepo.SaveFeedback(r.Context(), callID, userID, &req)
+	if err != nil {
+		if errors.Is(err, calls.ErrNotCallParticipant) {
+			http.Error(w, "Not a participant of this call", http.StatusForbidden)
+			return
+		}
+		log.Printf("SaveFeedback error: %v", err)
+		http.Error(w, "Failed to save feedback", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(feedback)
+}
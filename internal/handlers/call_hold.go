@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/calls"
+)
+
+// What to do with the call a user is in when they start or join another one
+const (
+	otherCallHold  = "hold"
+	otherCallLeave = "leave"
+)
+
+// callSwitchRequest is embedded in start and join requests
+type callSwitchRequest struct {
+	// "hold" or "leave" the call the user is already in; without it joining another
+	// call is refused with a 409 that offers both
+	OtherCall string `json:"other_call,omitempty"`
+}
+
+// checkOtherCall finds the call the user is busy in besides the conversation's, and
+// refuses with a 409 when they didn't say what to do with it. It returns the call to
+// switch away from (nil if none) and false if it already responded.
+func (h *CallsHandler) checkOtherCall(ctx context.Context, w http.ResponseWriter, userID, conversationID uuid.UUID, option string) (*calls.Call, bool) {
+	if option != "" && option != otherCallHold && option != otherCallLeave {
+		http.Error(w, `other_call must be "hold" or "leave"`, http.StatusBadRequest)
+		return nil, false
+	}
+
+	other, err := h.callsRepo.IsUserInCall(ctx, userID)
+	if err != nil {
+		log.Printf("IsUserInCall error: %v", err)
+		http.Error(w, "Failed to check call status", http.StatusInternalServerError)
+		return nil, false
+	}
+	if other == nil || other.ConversationID == conversationID {
+		return nil, true
+	}
+
+	if option == "" {
+		respondJSON(w, http.StatusConflict, map[string]interface{}{
+			"error":           "Already in another call",
+			"call_id":         other.ID,
+			"conversation_id": other.ConversationID,
+			"options":         []string{otherCallHold, otherCallLeave},
+		})
+		return nil, false
+	}
+	return other, true
+}
+
+// switchFromCall holds or leaves the user's other call once they're in the new one
+func (h *CallsHandler) switchFromCall(ctx context.Context, other *calls.Call, userID uuid.UUID, option string) {
+	if other == nil {
+		return
+	}
+
+	if option == otherCallLeave {
+		if err := h.leaveCall(ctx, other, userID, nil, !h.webhookSync); err != nil {
+			log.Printf("Failed to leave call %s: %v", other.ID, err)
+		}
+		return
+	}
+
+	if _, err := h.callsRepo.HoldCall(ctx, other.ID, userID); err != nil {
+		log.Printf("HoldCall error: %v", err)
+		return
+	}
+	h.broadcastCallState(ctx, other.ConversationID)
+}
+
+// HoldCall puts a call the user is in on hold
+func (h *CallsHandler) HoldCall(w http.ResponseWriter, r *http.Request) {
+	h.setOnHold(w, r, true)
+}
+
+// ResumeCall takes a held call off hold, putting the user's current call on hold instead
+func (h *CallsHandler) ResumeCall(w http.ResponseWriter, r *http.Request) {
+	h.setOnHold(w, r, false)
+}
+
+func (h *CallsHandler) setOnHold(w http.ResponseWriter, r *http.Request, hold bool) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	callID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid call_id", http.StatusBadRequest)
+		return
+	}
+
+	call, err := h.callsRepo.GetCallWithParticipants(r.Context(), callID)
+	if err != nil || call.EndedAt != nil {
+		http.Error(w, "Call not found", http.StatusNotFound)
+		return
+	}
+
+	// Only one call can be live at a time
+	var current *calls.Call
+	if !hold {
+		current, err = h.callsRepo.IsUserInCall(r.Context(), userID)
+		if err != nil {
+			log.Printf("IsUserInCall error: %v", err)
+			http.Error(w, "Failed to check call status", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	var changed bool
+	if hold {
+		changed, err = h.callsRepo.HoldCall(r.Context(), callID, userID)
+	} else {
+		changed, err = h.callsRepo.ResumeCall(r.Context(), callID, userID)
+	}
+	if err != nil {
+		log.Printf("Failed to update hold state: %v", err)
+		http.Error(w, "Failed to update call", http.StatusInternalServerError)
+		return
+	}
+	if !changed {
+		http.Error(w, calls.ErrNotInCall.Error(), http.StatusForbidden)
+		return
+	}
+
+	if current != nil && current.ID != callID {
+		h.switchFromCall(r.Context(), current, userID, otherCallHold)
+	}
+	h.broadcastCallState(r.Context(), call.ConversationID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
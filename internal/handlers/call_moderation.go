@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/calls"
+	"github.com/user/bla-back/internal/models"
+)
+
+// ServerMuteParticipant mutes a participant for everyone (call moderators only)
+func (h *CallsHandler) ServerMuteParticipant(w http.ResponseWriter, r *http.Request) {
+	h.setServerMuted(w, r, true)
+}
+
+// ServerUnmuteParticipant lifts a server mute
+func (h *CallsHandler) ServerUnmuteParticipant(w http.ResponseWriter, r *http.Request) {
+	h.setServerMuted(w, r, false)
+}
+
+func (h *CallsHandler) setServerMuted(w http.ResponseWriter, r *http.Request, muted bool) {
+	moderatorID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	callID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid call_id", http.StatusBadRequest)
+		return
+	}
+
+	targetID, err := uuid.Parse(r.PathValue("userId"))
+	if err != nil {
+		http.Error(w, "Invalid user_id", http.StatusBadRequest)
+		return
+	}
+
+	call, err := h.callsRepo.GetCallWithParticipants(r.Context(), callID)
+	if err != nil {
+		http.Error(w, "Call not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.callsRepo.SetServerMuted(r.Context(), callID, moderatorID, targetID, muted); err != nil {
+		h.respondModerationError(w, err)
+		return
+	}
+
+	// Enforce immediately on the SFU, in every session; the token claim covers reconnects
+	sessions, err := h.callsRepo.GetActiveSessions(r.Context(), callID, targetID)
+	if err != nil {
+		log.Printf("GetActiveSessions error: %v", err)
+	}
+	backend := h.backendFor(r.Context(), callID)
+	for _, sessionID := range sessions {
+		identity := calls.SessionIdentity(targetID, sessionID)
+		if err := backend.SetParticipantMuted(r.Context(), "call-"+callID.String(), identity, muted); err != nil {
+			log.Printf("SetParticipantMuted error: %v", err)
+		}
+	}
+
+	event := &models.CallParticipantUpdateEvent{
+		CallID:         callID,
+		ConversationID: call.ConversationID,
+		UserID:         targetID,
+		ServerMuted:    muted,
+	}
+	participantIDs, _ := h.convRepo.GetParticipantIDs(r.Context(), call.ConversationID)
+	h.notifier.NotifyUsers(participantIDs, "CALL_PARTICIPANT_UPDATE", event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(event)
+}
+
+// KickParticipant removes a participant from the call and its media room (call moderators only)
+func (h *CallsHandler) KickParticipant(w http.ResponseWriter, r *http.Request) {
+	moderatorID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	callID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid call_id", http.StatusBadRequest)
+		return
+	}
+
+	targetID, err := uuid.Parse(r.PathValue("userId"))
+	if err != nil {
+		http.Error(w, "Invalid user_id", http.StatusBadRequest)
+		return
+	}
+	if targetID == moderatorID {
+		http.Error(w, "Leave the call instead", http.StatusBadRequest)
+		return
+	}
+
+	call, err := h.callsRepo.GetCallWithParticipants(r.Context(), callID)
+	if err != nil {
+		http.Error(w, "Call not found", http.StatusNotFound)
+		return
+	}
+
+	// The sessions to disconnect, before the kick ends them
+	sessions, err := h.callsRepo.GetActiveSessions(r.Context(), callID, targetID)
+	if err != nil {
+		log.Printf("GetActiveSessions error: %v", err)
+	}
+
+	if err := h.callsRepo.KickParticipant(r.Context(), callID, moderatorID, targetID); err != nil {
+		h.respondModerationError(w, err)
+		return
+	}
+	h.notifier.RevokeCall(callID, targetID)
+
+	backend := h.backendFor(r.Context(), callID)
+	for _, sessionID := range sessions {
+		if err := backend.RemoveParticipant(r.Context(), "call-"+callID.String(), calls.SessionIdentity(targetID, sessionID)); err != nil {
+			log.Printf("RemoveParticipant error: %v", err)
+		}
+		h.notifier.NotifyUser(targetID, "CALL_SESSION_END", &models.CallSessionEndEvent{
+			CallID:         callID,
+			ConversationID: call.ConversationID,
+			SessionID:      sessionID,
+			Reason:         "kicked",
+		})
+	}
+
+	// A moderator outside the call may have removed its last participant
+	if count, err := h.callsRepo.GetActiveParticipantCount(r.Context(), callID); err == nil && count == 0 {
+		h.endCall(r.Context(), callID)
+	}
+	h.broadcastCallState(r.Context(), call.ConversationID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetPushToTalk turns push-to-talk mode on or off for a call (call moderators only).
+// Clients enforce the talk key; tokens issued afterwards carry the flag.
+func (h *CallsHandler) SetPushToTalk(w http.ResponseWriter, r *http.Request) {
+	moderatorID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	callID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid call_id", http.StatusBadRequest)
+		return
+	}
+
+	var req models.PushToTalkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	call, err := h.callsRepo.GetCallWithParticipants(r.Context(), callID)
+	if err != nil {
+		http.Error(w, "Call not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.callsRepo.SetPushToTalk(r.Context(), callID, moderatorID, req.Enabled); err != nil {
+		h.respondModerationError(w, err)
+		return
+	}
+
+	h.broadcastCallState(r.Context(), call.ConversationID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *CallsHandler) respondModerationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, calls.ErrCallNotFound):
+		http.Error(w, "Call not found", http.StatusNotFound)
+	case errors.Is(err, calls.ErrNotCallModerator):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, calls.ErrNotInCall):
+		http.Error(w, "User is not in this call", http.StatusNotFound)
+	default:
+		log.Printf("Call moderation error: %v", err)
+		http.Error(w, "Failed to update call", http.StatusInternalServerError)
+	}
+}
+
+// UpdateMediaState records the caller's own mute, deafen and camera state in the call
+// and relays it to the conversation with CALL_STATE
+func (h *CallsHandler) UpdateMediaState(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	callID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid call_id", http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateCallMediaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Muted == nil && req.Deafened == nil && req.Camera == nil {
+		http.Error(w, "Nothing to update", http.StatusBadRequest)
+		return
+	}
+
+	call, err := h.callsRepo.GetCallWithParticipants(r.Context(), callID)
+	if err != nil {
+		http.Error(w, "Call not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.callsRepo.SetMediaState(r.Context(), callID, userID, &req); err != nil {
+		if errors.Is(err, calls.ErrNotInCall) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		log.Printf("SetMediaState error: %v", err)
+		http.Error(w, "Failed to update media state", http.StatusInternalServerError)
+		return
+	}
+
+	h.broadcastCallState(r.Context(), call.ConversationID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
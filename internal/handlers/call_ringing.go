@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/calls"
+	"github.com/user/bla-back/internal/models"
+)
+
+var errRingNonParticipant = errors.New("can only ring participants of the conversation")
+
+// ringTargets checks the participants a caller picked to ring, dropping duplicates and
+// the caller. It returns nil when none were picked, which rings everyone.
+func (h *CallsHandler) ringTargets(ctx context.Context, conversationID, callerID uuid.UUID, picked []uuid.UUID) ([]uuid.UUID, error) {
+	if len(picked) == 0 {
+		return nil, nil
+	}
+
+	participantIDs, err := h.convRepo.GetParticipantIDs(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	targets := []uuid.UUID{}
+	for _, id := range picked {
+		if !slices.Contains(participantIDs, id) {
+			return nil, errRingNonParticipant
+		}
+		if id != callerID && !slices.Contains(targets, id) {
+			targets = append(targets, id)
+		}
+	}
+	return targets, nil
+}
+
+// DoNotDisturbFilter returns which of the users have Do Not Disturb on
+type DoNotDisturbFilter interface {
+	FilterDoNotDisturb(ctx context.Context, userIDs []uuid.UUID) ([]uuid.UUID, error)
+}
+
+// SetDoNotDisturb keeps calls from ringing users who have Do Not Disturb on
+func (h *CallsHandler) SetDoNotDisturb(dnd DoNotDisturbFilter) {
+	h.dnd = dnd
+}
+
+// VoIPPusher wakes the mobile devices of rung users with VoIP pushes
+type VoIPPusher interface {
+	PushCall(ctx context.Context, userIDs []uuid.UUID, ring *models.CallRingEvent)
+}
+
+// SetVoIPPush wakes offline mobile devices for incoming calls
+func (h *CallsHandler) SetVoIPPush(pusher VoIPPusher) {
+	h.voipPush = pusher
+}
+
+// ringConversation rings the targets (or everyone in the conversation but the caller
+// when targets is nil) for a new call. The rest, and anyone on Do Not Disturb, only
+// see the call in CALL_STATE and get the missed call message.
+func (h *CallsHandler) ringConversation(ctx context.Context, call *calls.Call, caller *models.User, targets []uuid.UUID) {
+	ringees := targets
+	if ringees == nil {
+		participantIDs, err := h.convRepo.GetParticipantIDs(ctx, call.ConversationID)
+		if err != nil {
+			log.Printf("Failed to get conversation participants: %v", err)
+			return
+		}
+		ringees = make([]uuid.UUID, 0, len(participantIDs))
+		for _, id := range participantIDs {
+			if id != call.StartedBy {
+				ringees = append(ringees, id)
+			}
+		}
+	}
+
+	if err := h.callsRepo.TrackMisses(ctx, call.ID, ringees); err != nil {
+		log.Printf("TrackMisses error: %v", err)
+	}
+
+	// Users on Do Not Disturb aren't rung, so the call doesn't wait on them either
+	if h.dnd != nil && len(ringees) > 0 {
+		if dnd, err := h.dnd.FilterDoNotDisturb(ctx, ringees); err != nil {
+			log.Printf("FilterDoNotDisturb error: %v", err)
+		} else if len(dnd) > 0 {
+			ringees = slices.DeleteFunc(slices.Clone(ringees), func(id uuid.UUID) bool {
+				return slices.Contains(dnd, id)
+			})
+			targets = ringees
+		}
+	}
+
+	until, err := h.callsRepo.StartRinging(ctx, call.ID, targets, h.ringTimeout)
+	if err != nil {
+		log.Printf("StartRinging error: %v", err)
+		return
+	}
+	if len(ringees) == 0 {
+		return
+	}
+
+	// Users busy in another call hear a call-waiting tone instead of a ring
+	busy, err := h.callsRepo.FilterBusy(ctx, ringees)
+	if err != nil {
+		log.Printf("FilterBusy error: %v", err)
+	}
+	idle := slices.DeleteFunc(slices.Clone(ringees), func(id uuid.UUID) bool {
+		return slices.Contains(busy, id)
+	})
+
+	// Only the public part of the caller goes out
+	event := models.CallRingEvent{
+		CallID:         call.ID,
+		ConversationID: call.ConversationID,
+		Caller:         &models.User{ID: caller.ID, Username: caller.Username, AvatarURL: caller.AvatarURL},
+		ExpiresAt:      until,
+	}
+	if len(idle) > 0 {
+		h.notifier.NotifyUsers(idle, "CALL_RING", &event)
+		// Mobile apps in the background aren't connected to hear CALL_RING
+		if h.voipPush != nil {
+			go h.voipPush.PushCall(context.Background(), idle, &event)
+		}
+	}
+	if len(busy) > 0 {
+		waiting := event
+		waiting.CallWaiting = true
+		h.notifier.NotifyUsers(busy, "CALL_RING", &waiting)
+	}
+}
+
+// settleRing handles a rung user answering or declining: their other devices stop
+// ringing, and once nobody is left to answer the call stops ringing for everyone
+func (h *CallsHandler) settleRing(ctx context.Context, call *calls.Call, userID uuid.UUID, reason string) {
+	ringing, err := h.callsRepo.IsRinging(ctx, call.ID)
+	if err != nil {
+		log.Printf("IsRinging error: %v", err)
+		return
+	}
+	if !ringing {
+		return
+	}
+
+	h.notifier.NotifyUser(userID, "CALL_RING_STOP", &models.CallRingStopEvent{
+		CallID:         call.ID,
+		ConversationID: call.ConversationID,
+		Reason:         reason,
+	})
+
+	stopped, answered, err := h.callsRepo.StopRingingIfSettled(ctx, call.ID)
+	if err != nil {
+		log.Printf("StopRingingIfSettled error: %v", err)
+		return
+	}
+	if !stopped {
+		return
+	}
+
+	reason = calls.RingDeclined
+	if answered {
+		reason = calls.RingAnswered
+	}
+	h.stopRinging(ctx, call.ID, call.ConversationID, reason)
+}
+
+// stopRinging tells the whole conversation a call stopped ringing
+func (h *CallsHandler) stopRinging(ctx context.Context, callID, conversationID uuid.UUID, reason string) {
+	participantIDs, err := h.convRepo.GetParticipantIDs(ctx, conversationID)
+	if err != nil {
+		log.Printf("Failed to get conversation participants: %v", err)
+		return
+	}
+	h.notifier.NotifyUsers(participantIDs, "CALL_RING_STOP", &models.CallRingStopEvent{
+		CallID:         callID,
+		ConversationID: conversationID,
+		Reason:         reason,
+	})
+}
+
+// DeclineCall declines a ringing call. The people in the call hear about it, and the
+// call stops ringing once everyone has answered or declined.
+func (h *CallsHandler) DeclineCall(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		CallID string `json:"call_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	callID, err := uuid.Parse(req.CallID)
+	if err != nil {
+		http.Error(w, "Invalid call_id", http.StatusBadRequest)
+		return
+	}
+
+	call, err := h.callsRepo.DeclineCall(r.Context(), callID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, calls.ErrCallNotFound):
+			http.Error(w, "Call not found", http.StatusNotFound)
+		case errors.Is(err, calls.ErrNotRingee):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, calls.ErrNotRinging):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Printf("DeclineCall error: %v", err)
+			http.Error(w, "Failed to decline call", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	inCall, _ := h.callsRepo.GetActiveParticipants(r.Context(), call.ID)
+	h.notifier.NotifyUsers(inCall, "CALL_DECLINE", &models.CallDeclineEvent{
+		CallID:         call.ID,
+		ConversationID: call.ConversationID,
+		UserID:         userID,
+	})
+	h.settleRing(r.Context(), call, userID, calls.RingDeclined)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// notifyMissedCalls tells the rung users who never answered an ended call that they
+// missed it
+func (h *CallsHandler) notifyMissedCalls(ctx context.Context, info *calls.CallEndInfo) {
+	missed, err := h.callsRepo.RecordMisses(ctx, info.CallID)
+	if err != nil {
+		log.Printf("RecordMisses error: %v", err)
+		return
+	}
+	for _, userID := range missed {
+		count, _ := h.callsRepo.GetMissedCallCount(ctx, userID)
+		h.notifier.NotifyUser(userID, "CALL_MISSED", &models.CallMissedEvent{
+			CallID:         info.CallID,
+			ConversationID: info.ConversationID,
+			CallerID:       info.StartedBy,
+			StartedAt:      info.StartedAt,
+			MissedCalls:    count,
+		})
+	}
+}
+
+// AckMissedCalls clears the caller's missed-calls badge
+func (h *CallsHandler) AckMissedCalls(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.callsRepo.MarkMissedCallsSeen(r.Context(), userID); err != nil {
+		log.Printf("MarkMissedCallsSeen error: %v", err)
+		http.Error(w, "Failed to update missed calls", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
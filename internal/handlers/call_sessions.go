@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"context"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/calls"
+	"github.com/user/bla-back/internal/models"
+)
+
+// callSessionRequest is embedded in start and join requests to name the device's session
+type callSessionRequest struct {
+	// Identifies this device's connection, so a user can be in a call from several
+	// devices; empty for clients that only ever use one
+	SessionID string `json:"session_id,omitempty"`
+	// Hand the call over to this session, dropping the user's other sessions
+	Handoff bool `json:"handoff,omitempty"`
+}
+
+// handOff drops the user's other sessions from the call and tells those devices to
+// disconnect
+func (h *CallsHandler) handOff(ctx context.Context, call *calls.Call, userID uuid.UUID, sessionID string) {
+	ended, err := h.callsRepo.HandOff(ctx, call.ID, userID, sessionID)
+	if err != nil {
+		log.Printf("HandOff error: %v", err)
+		return
+	}
+	for _, s := range ended {
+		h.notifier.NotifyUser(userID, "CALL_SESSION_END", &models.CallSessionEndEvent{
+			CallID:         call.ID,
+			ConversationID: call.ConversationID,
+			SessionID:      s,
+			Reason:         "handoff",
+		})
+	}
+}
+
+// revokeIfGone revokes the user's call channels once none of their sessions is left in the call
+func (h *CallsHandler) revokeIfGone(ctx context.Context, callID, userID uuid.UUID) {
+	if inCall, err := h.callsRepo.IsActiveParticipant(ctx, callID, userID); err == nil && !inCall {
+		h.notifier.RevokeCall(callID, userID)
+	}
+}
@@ -3,23 +3,47 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"time"
 
+	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/user/bla-back/internal/calls"
+	"github.com/user/bla-back/internal/messages"
 	"github.com/user/bla-back/internal/models"
 	"github.com/user/bla-back/internal/realtime"
+	"github.com/user/bla-back/internal/storage"
+	"github.com/user/bla-back/internal/transcribe"
 )
 
 type CallsHandler struct {
 	callsRepo *calls.Repository
-	voice     *calls.VoiceService
+	voice     *calls.VoiceBackends
+	sfu       *calls.VoiceService // redeems custom SFU tokens
 	usersRepo UsersRepository
 	notifier  *realtime.Notifier
 	convRepo  ConversationRepository
 	msgRepo   MessagesRepository
+	storage   *storage.S3Storage
+	validator *validator.Validate
+
+	// ringTimeout is how long a new call rings before it stops on its own
+	ringTimeout time.Duration
+	// webhookSync leaves ending calls to voice backend webhooks (see SetWebhookSync)
+	webhookSync bool
+	// reconnectGrace keeps dropped participants in calls while they reconnect (see SetReconnectGrace)
+	reconnectGrace time.Duration
+	// turn issues TURN credentials (see SetTURN)
+	turn calls.TURNConfig
+	// dnd finds users who don't want calls to ring (see SetDoNotDisturb)
+	dnd DoNotDisturbFilter
+	// transcriber captions call audio posted by the voice server (see SetTranscriber)
+	transcriber *transcribe.Client
+	// voipPush wakes mobile devices for incoming calls (see SetVoIPPush)
+	voipPush VoIPPusher
 }
 
 type UsersRepository interface {
@@ -28,35 +52,61 @@ type UsersRepository interface {
 
 type ConversationRepository interface {
 	GetParticipantIDs(ctx context.Context, conversationID uuid.UUID) ([]uuid.UUID, error)
+	CheckPermission(ctx context.Context, convID, userID uuid.UUID, perm messages.Permission) error
+	CheckStartCall(ctx context.Context, convID, userID uuid.UUID) error
+	CheckDMBlock(ctx context.Context, convID, userID uuid.UUID) error
+	CheckJoinCall(ctx context.Context, convID, userID uuid.UUID) error
 }
 
 type MessagesRepository interface {
 	CreateCallMessage(ctx context.Context, convID, senderID uuid.UUID, content string) (*models.Message, error)
+	CreateCallTranscriptMessage(ctx context.Context, convID, senderID uuid.UUID, content string) (*models.Message, error)
+	CreateAttachment(ctx context.Context, uploaderID uuid.UUID, attachType, url, filename string, size int64) (*models.Attachment, error)
+	SendMessageWithAttachments(ctx context.Context, convID, senderID uuid.UUID, content string, attachmentIDs []uuid.UUID) (*models.Message, error)
 }
 
 func NewCallsHandler(
 	callsRepo *calls.Repository,
-	voice *calls.VoiceService,
+	voice *calls.VoiceBackends,
+	sfu *calls.VoiceService,
 	usersRepo UsersRepository,
 	notifier *realtime.Notifier,
 	convRepo ConversationRepository,
 	msgRepo MessagesRepository,
+	storage *storage.S3Storage,
+	ringTimeout time.Duration,
 ) *CallsHandler {
 	return &CallsHandler{
-		callsRepo: callsRepo,
-		voice:     voice,
-		usersRepo: usersRepo,
-		notifier:  notifier,
-		convRepo:  convRepo,
-		msgRepo:   msgRepo,
+		callsRepo:   callsRepo,
+		voice:       voice,
+		sfu:         sfu,
+		usersRepo:   usersRepo,
+		notifier:    notifier,
+		convRepo:    convRepo,
+		msgRepo:     msgRepo,
+		storage:     storage,
+		validator:   validator.New(),
+		ringTimeout: ringTimeout,
 	}
 }
 
 // Response types
 type CallResponse struct {
-	CallID     string `json:"call_id"`
-	Token      string `json:"token"`
-	LiveKitURL string `json:"livekit_url"`
+	CallID       string `json:"call_id"`
+	Token        string `json:"token"`
+	LiveKitURL   string `json:"livekit_url"`
+	VoiceBackend string `json:"voice_backend"` // "sfu" or "livekit", tells the client which SDK to connect with
+}
+
+// backendFor returns the voice backend hosting the call, assigning one on first use
+func (h *CallsHandler) backendFor(ctx context.Context, callID uuid.UUID) calls.VoiceBackend {
+	picked := h.voice.Pick(callID)
+	name, err := h.callsRepo.AssignVoiceBackend(ctx, callID, picked.Name())
+	if err != nil {
+		log.Printf("AssignVoiceBackend error: %v", err)
+		return picked
+	}
+	return h.voice.Get(name)
 }
 
 // broadcastCallState sends current call state to all conversation participants
@@ -74,6 +124,11 @@ func (h *CallsHandler) broadcastCallState(ctx context.Context, conversationID uu
 		ConversationID: conversationID,
 		CallID:         nil,
 		Participants:   []uuid.UUID{},
+		ServerMuted:    []uuid.UUID{},
+		Media:          []models.CallMediaState{},
+		Reconnecting:   []uuid.UUID{},
+		Devices:        map[uuid.UUID]int{},
+		OnHold:         []uuid.UUID{},
 	}
 
 	if err == nil && call != nil {
@@ -81,6 +136,22 @@ func (h *CallsHandler) broadcastCallState(ctx context.Context, conversationID uu
 		// Get active participants
 		participants, _ := h.callsRepo.GetActiveParticipants(ctx, call.ID)
 		event.Participants = participants
+		if pushToTalk, muted, err := h.callsRepo.GetModerationState(ctx, call.ID); err == nil {
+			event.PushToTalk = pushToTalk
+			event.ServerMuted = muted
+		}
+		if media, err := h.callsRepo.GetMediaStates(ctx, call.ID); err == nil {
+			event.Media = media
+		}
+		if reconnecting, err := h.callsRepo.GetReconnecting(ctx, call.ID); err == nil {
+			event.Reconnecting = reconnecting
+		}
+		if devices, err := h.callsRepo.GetDeviceCounts(ctx, call.ID); err == nil {
+			event.Devices = devices
+		}
+		if onHold, err := h.callsRepo.GetOnHold(ctx, call.ID); err == nil {
+			event.OnHold = onHold
+		}
 	}
 
 	h.notifier.NotifyUsers(participantIDs, "CALL_STATE", event)
@@ -96,11 +167,19 @@ func (h *CallsHandler) StartCall(w http.ResponseWriter, r *http.Request) {
 
 	var req struct {
 		ConversationID string `json:"conversation_id"`
+		// Ring only these participants when starting a call; everyone if empty
+		Ring []uuid.UUID `json:"ring,omitempty"`
+		callSessionRequest
+		callSwitchRequest
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if !calls.ValidSessionID(req.SessionID) {
+		http.Error(w, calls.ErrInvalidSession.Error(), http.StatusBadRequest)
+		return
+	}
 
 	conversationID, err := uuid.Parse(req.ConversationID)
 	if err != nil {
@@ -108,15 +187,13 @@ func (h *CallsHandler) StartCall(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if user is already in another call
-	existingCall, err := h.callsRepo.IsUserInCall(r.Context(), userID)
-	if err != nil {
-		log.Printf("IsUserInCall error: %v", err)
-		http.Error(w, "Failed to check call status", http.StatusInternalServerError)
+	if !h.checkCallBlock(r.Context(), w, conversationID, userID) {
 		return
 	}
-	if existingCall != nil && existingCall.ConversationID != conversationID {
-		http.Error(w, "Already in another call", http.StatusConflict)
+
+	// Check if user is already in another call
+	otherCall, ok := h.checkOtherCall(r.Context(), w, userID, conversationID, req.OtherCall)
+	if !ok {
 		return
 	}
 
@@ -128,22 +205,62 @@ func (h *CallsHandler) StartCall(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if call == nil {
+	startedCall := call == nil
+	var ringTargets []uuid.UUID
+	if startedCall {
+		// Starting a call depends on the user's role and the group's call permission
+		if err := h.convRepo.CheckStartCall(r.Context(), conversationID, userID); err != nil {
+			switch {
+			case errors.Is(err, messages.ErrNotParticipant):
+				http.Error(w, "Not a participant", http.StatusForbidden)
+			case errors.Is(err, messages.ErrPermissionDenied):
+				http.Error(w, "Your role doesn't allow starting calls", http.StatusForbidden)
+			case errors.Is(err, messages.ErrCallsAdminsOnly):
+				http.Error(w, err.Error(), http.StatusForbidden)
+			default:
+				log.Printf("CheckStartCall error: %v", err)
+				http.Error(w, "Failed to check permissions", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		ringTargets, err = h.ringTargets(r.Context(), conversationID, userID, req.Ring)
+		if err != nil {
+			if errors.Is(err, errRingNonParticipant) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			log.Printf("Failed to get conversation participants: %v", err)
+			http.Error(w, "Failed to start call", http.StatusInternalServerError)
+			return
+		}
+
 		// Start new call
-		call, err = h.callsRepo.StartCall(r.Context(), conversationID, userID)
+		call, err = h.callsRepo.StartCall(r.Context(), conversationID, userID, req.SessionID)
 		if err != nil {
 			log.Printf("StartCall error: %v", err)
 			http.Error(w, "Failed to start call", http.StatusInternalServerError)
 			return
 		}
 	} else {
-		// Join existing call (if not already in it)
-		if err := h.callsRepo.JoinCall(r.Context(), call.ID, userID); err != nil {
+		// Join existing call (if not already in it), with the same checks as JoinCall
+		if err := h.convRepo.CheckJoinCall(r.Context(), conversationID, userID); err != nil {
+			respondJoinRefused(w, err)
+			return
+		}
+		if err := h.callsRepo.JoinCall(r.Context(), call.ID, userID, req.SessionID); err != nil {
+			if respondCallFull(w, err) {
+				return
+			}
 			log.Printf("JoinCall error: %v", err)
 			http.Error(w, "Failed to join call", http.StatusInternalServerError)
 			return
 		}
+		if req.Handoff {
+			h.handOff(r.Context(), call, userID, req.SessionID)
+		}
 	}
+	h.switchFromCall(r.Context(), otherCall, userID, req.OtherCall)
 
 	// Get username for LiveKit
 	user, err := h.usersRepo.GetUserByID(r.Context(), userID)
@@ -159,22 +276,35 @@ func (h *CallsHandler) StartCall(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Generate voice token
+	perms, err := h.callsRepo.GetVoicePermissions(r.Context(), call.ID, userID)
+	if err != nil {
+		log.Printf("GetVoicePermissions error: %v", err)
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
 	roomName := "call-" + call.ID.String()
-	token, err := h.voice.GenerateToken(roomName, userID.String(), username)
+	backend := h.backendFor(r.Context(), call.ID)
+	token, err := backend.GenerateToken(roomName, calls.SessionIdentity(userID, req.SessionID), username, perms)
 	if err != nil {
 		log.Printf("GenerateToken error: %v", err)
 		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
 		return
 	}
 
-	// Broadcast updated call state to all conversation participants
+	// Broadcast updated call state to all conversation participants, and ring them for a new call
 	h.broadcastCallState(r.Context(), conversationID)
+	if startedCall {
+		h.ringConversation(r.Context(), call, user, ringTargets)
+	} else {
+		h.settleRing(r.Context(), call, userID, calls.RingAnswered)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(CallResponse{
-		CallID:     call.ID.String(),
-		Token:      token,
-		LiveKitURL: h.voice.GetWebSocketURL(),
+		CallID:       call.ID.String(),
+		Token:        token,
+		LiveKitURL:   backend.GetWebSocketURL(),
+		VoiceBackend: backend.Name(),
 	})
 }
 
@@ -188,11 +318,17 @@ func (h *CallsHandler) JoinCall(w http.ResponseWriter, r *http.Request) {
 
 	var req struct {
 		CallID string `json:"call_id"`
+		callSessionRequest
+		callSwitchRequest
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if !calls.ValidSessionID(req.SessionID) {
+		http.Error(w, calls.ErrInvalidSession.Error(), http.StatusBadRequest)
+		return
+	}
 
 	callID, err := uuid.Parse(req.CallID)
 	if err != nil {
@@ -212,12 +348,29 @@ func (h *CallsHandler) JoinCall(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.convRepo.CheckJoinCall(r.Context(), call.ConversationID, userID); err != nil {
+		respondJoinRefused(w, err)
+		return
+	}
+
+	otherCall, ok := h.checkOtherCall(r.Context(), w, userID, call.ConversationID, req.OtherCall)
+	if !ok {
+		return
+	}
+
 	// Join call
-	if err := h.callsRepo.JoinCall(r.Context(), callID, userID); err != nil {
+	if err := h.callsRepo.JoinCall(r.Context(), callID, userID, req.SessionID); err != nil {
+		if respondCallFull(w, err) {
+			return
+		}
 		log.Printf("JoinCall error: %v", err)
 		http.Error(w, "Failed to join call", http.StatusInternalServerError)
 		return
 	}
+	if req.Handoff {
+		h.handOff(r.Context(), call, userID, req.SessionID)
+	}
+	h.switchFromCall(r.Context(), otherCall, userID, req.OtherCall)
 
 	// Get username for LiveKit
 	user, err := h.usersRepo.GetUserByID(r.Context(), userID)
@@ -232,8 +385,15 @@ func (h *CallsHandler) JoinCall(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Generate voice token
+	perms, err := h.callsRepo.GetVoicePermissions(r.Context(), call.ID, userID)
+	if err != nil {
+		log.Printf("GetVoicePermissions error: %v", err)
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
 	roomName := "call-" + call.ID.String()
-	token, err := h.voice.GenerateToken(roomName, userID.String(), username)
+	backend := h.backendFor(r.Context(), call.ID)
+	token, err := backend.GenerateToken(roomName, calls.SessionIdentity(userID, req.SessionID), username, perms)
 	if err != nil {
 		log.Printf("GenerateToken error: %v", err)
 		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
@@ -242,13 +402,47 @@ func (h *CallsHandler) JoinCall(w http.ResponseWriter, r *http.Request) {
 
 	// Broadcast updated call state
 	h.broadcastCallState(r.Context(), call.ConversationID)
+	h.settleRing(r.Context(), call, userID, calls.RingAnswered)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(CallResponse{
-		CallID:     call.ID.String(),
-		Token:      token,
-		LiveKitURL: h.voice.GetWebSocketURL(),
+		CallID:       call.ID.String(),
+		Token:        token,
+		LiveKitURL:   backend.GetWebSocketURL(),
+		VoiceBackend: backend.Name(),
+	})
+}
+
+// respondJoinRefused writes the error response for a CheckJoinCall error
+func respondJoinRefused(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, messages.ErrNotParticipant):
+		http.Error(w, "Not a participant", http.StatusForbidden)
+	case errors.Is(err, messages.ErrUserBanned):
+		http.Error(w, "You are banned from this group", http.StatusForbidden)
+	case errors.Is(err, messages.ErrBlocked):
+		http.Error(w, "Cannot call this user", http.StatusForbidden)
+	default:
+		log.Printf("CheckJoinCall error: %v", err)
+		http.Error(w, "Failed to join call", http.StatusInternalServerError)
+	}
+}
+
+// respondCallFull writes a 409 with the limit and count if err is a call size error,
+// reporting whether it did
+func respondCallFull(w http.ResponseWriter, err error) bool {
+	var full *calls.CallFullError
+	if !errors.As(err, &full) {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":            "Call is full",
+		"max_participants": full.Max,
+		"current_count":    full.Current,
 	})
+	return true
 }
 
 // LeaveCall leaves a call
@@ -261,6 +455,8 @@ func (h *CallsHandler) LeaveCall(w http.ResponseWriter, r *http.Request) {
 
 	var req struct {
 		CallID string `json:"call_id"`
+		// Session to leave from; omitted leaves from every session
+		SessionID *string `json:"session_id,omitempty"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
@@ -280,30 +476,71 @@ func (h *CallsHandler) LeaveCall(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Leave call
-	if err := h.callsRepo.LeaveCall(r.Context(), callID, userID); err != nil {
+	// With webhook sync the voice server reports when the room empties
+	if err := h.leaveCall(r.Context(), call, userID, req.SessionID, !h.webhookSync); err != nil {
 		http.Error(w, "Failed to leave call", http.StatusInternalServerError)
 		return
 	}
 
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// leaveCall removes the user's session (nil for all of them) from the call, optionally
+// ends it once empty, and broadcasts the new state
+func (h *CallsHandler) leaveCall(ctx context.Context, call *calls.Call, userID uuid.UUID, sessionID *string, endIfEmpty bool) error {
+	var err error
+	if sessionID == nil {
+		err = h.callsRepo.LeaveCall(ctx, call.ID, userID)
+	} else {
+		err = h.callsRepo.LeaveSession(ctx, call.ID, userID, *sessionID)
+	}
+	if err != nil {
+		return err
+	}
+	h.revokeIfGone(ctx, call.ID, userID)
+
 	// Check if call is now empty
-	count, _ := h.callsRepo.GetActiveParticipantCount(r.Context(), callID)
-	if count == 0 {
-		// End the call and create call message
-		// EndCall returns nil if call was already ended (race condition)
-		callInfo, err := h.callsRepo.EndCall(r.Context(), callID)
-		if err != nil {
-			log.Printf("EndCall error: %v", err)
-		} else if callInfo != nil {
-			// Only create message if we actually ended the call (not already ended)
-			h.createCallMessage(r.Context(), callInfo)
+	if endIfEmpty {
+		count, _ := h.callsRepo.GetActiveParticipantCount(ctx, call.ID)
+		if count == 0 {
+			h.endCall(ctx, call.ID)
 		}
 	}
 
 	// Broadcast updated call state (will show no call if ended)
-	h.broadcastCallState(r.Context(), call.ConversationID)
+	h.broadcastCallState(ctx, call.ConversationID)
+	return nil
+}
 
-	w.WriteHeader(http.StatusNoContent)
+// endCall ends the call, posts its call message and transcript, and closes its voice room
+func (h *CallsHandler) endCall(ctx context.Context, callID uuid.UUID) {
+	// EndCall returns nil if call was already ended (race condition)
+	callInfo, err := h.callsRepo.EndCall(ctx, callID)
+	if err != nil {
+		log.Printf("EndCall error: %v", err)
+		return
+	}
+	if callInfo == nil {
+		return
+	}
+
+	if stopped, err := h.callsRepo.StopRinging(ctx, callID); err != nil {
+		log.Printf("StopRinging error: %v", err)
+	} else if stopped {
+		h.stopRinging(ctx, callID, callInfo.ConversationID, calls.RingEnded)
+	}
+
+	// Only create message if we actually ended the call (not already ended)
+	if participantIDs, err := h.convRepo.GetParticipantIDs(ctx, callInfo.ConversationID); err == nil {
+		h.notifier.NotifyUsers(participantIDs, "CALL_ENDED", callInfo.Event())
+	}
+	h.createCallMessage(ctx, callInfo)
+	h.notifyMissedCalls(ctx, callInfo)
+	h.saveChatTranscript(ctx, callInfo)
+
+	if err := h.backendFor(ctx, callID).CloseRoom(ctx, "call-"+callID.String()); err != nil {
+		log.Printf("CloseRoom error: %v", err)
+	}
 }
 
 // createCallMessage creates a system message for a completed call
@@ -318,18 +555,12 @@ func (h *CallsHandler) createCallMessage(ctx context.Context, info *calls.CallEn
 		participants[i] = p.String()
 	}
 
-	// Determine call status
-	status := "completed"
-	if info.Duration < 5 && len(info.Participants) == 1 {
-		status = "missed" // Only caller, very short = likely missed
-	}
-
 	// Create JSON content
 	content := models.CallMessageContent{
 		CallID:       info.CallID.String(),
 		Duration:     info.Duration,
 		Participants: participants,
-		Status:       status,
+		Status:       info.Status(),
 	}
 	contentJSON, err := json.Marshal(content)
 	if err != nil {
@@ -351,13 +582,29 @@ func (h *CallsHandler) createCallMessage(ctx context.Context, info *calls.CallEn
 		return
 	}
 
-	h.notifier.NotifyUsers(participantIDs, "MESSAGE_CREATE", map[string]interface{}{
+	h.notifier.NotifyConversation(info.ConversationID, participantIDs, "MESSAGE_CREATE", map[string]interface{}{
 		"message":         msg,
 		"conversation_id": info.ConversationID,
 	})
 
 	log.Printf("Created call message: duration=%ds, participants=%d, status=%s",
-		info.Duration, len(info.Participants), status)
+		info.Duration, len(info.Participants), content.Status)
+}
+
+// checkCallBlock refuses calls in a DM where either side has blocked the other, writing
+// the error response. It reports whether the call can go ahead.
+func (h *CallsHandler) checkCallBlock(ctx context.Context, w http.ResponseWriter, conversationID, userID uuid.UUID) bool {
+	err := h.convRepo.CheckDMBlock(ctx, conversationID, userID)
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, messages.ErrBlocked) {
+		http.Error(w, "Cannot call this user", http.StatusForbidden)
+		return false
+	}
+	log.Printf("CheckDMBlock error: %v", err)
+	http.Error(w, "Failed to check blocks", http.StatusInternalServerError)
+	return false
 }
 
 // GetActiveCall returns the active call for a conversation
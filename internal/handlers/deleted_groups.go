@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/messages"
+	"github.com/user/bla-back/internal/models"
+)
+
+// DeleteGroup soft-deletes a group. Only the owner can delete it, and they can restore
+// it until the restore window passes; after that the purge job removes it for good.
+func (h *MessagesHandler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	deletedAt, err := h.repo.DeleteGroup(r.Context(), convID, userID)
+	if err != nil {
+		respondDeletedGroupError(w, err, "Failed to delete group")
+		return
+	}
+
+	event := &models.ConversationDeleteEvent{
+		ConversationID:  convID,
+		RestorableUntil: deletedAt.Add(h.groupRestoreWindow),
+	}
+	participantIDs, _ := h.repo.GetConversationParticipantIDs(r.Context(), convID)
+	h.rt.PublishToUsers(participantIDs, "CONVERSATION_DELETE", event)
+	for _, pid := range participantIDs {
+		h.rt.RevokeCanvas(convID, pid)
+		h.rt.RevokeConversation(convID, pid)
+	}
+
+	respondJSON(w, http.StatusOK, event)
+}
+
+// RestoreGroup brings back a group its owner deleted, if the restore window is still open
+func (h *MessagesHandler) RestoreGroup(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	if err := h.repo.RestoreGroup(r.Context(), convID, userID, h.groupRestoreWindow); err != nil {
+		respondDeletedGroupError(w, err, "Failed to restore group")
+		return
+	}
+
+	conv, err := h.repo.GetConversation(r.Context(), convID, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get conversation")
+		return
+	}
+
+	// The group reappears in everyone's list
+	participantIDs, _ := h.repo.GetConversationParticipantIDs(r.Context(), convID)
+	h.rt.PublishToUsers(participantIDs, "CONVERSATION_CREATE", conv)
+	h.rt.SubscribeConversation(convID, participantIDs)
+
+	respondJSON(w, http.StatusOK, conv)
+}
+
+// GetDeletedGroups lists the user's deleted groups that can still be restored
+func (h *MessagesHandler) GetDeletedGroups(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	groups, err := h.repo.GetDeletedGroups(r.Context(), userID, h.groupRestoreWindow)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get deleted groups")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, groups)
+}
+
+func respondDeletedGroupError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, messages.ErrConversationNotFound):
+		respondError(w, http.StatusNotFound, "Conversation not found")
+	case errors.Is(err, messages.ErrNotParticipant):
+		respondError(w, http.StatusForbidden, "Not a participant")
+	case errors.Is(err, messages.ErrNotDeletionOwner):
+		respondError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, messages.ErrNotGroup), errors.Is(err, messages.ErrGroupNotDeleted):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, messages.ErrRestoreWindowExpired):
+		respondError(w, http.StatusGone, err.Error())
+	default:
+		respondError(w, http.StatusInternalServerError, fallback)
+	}
+}
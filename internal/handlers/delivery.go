@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/messages"
+	"github.com/user/bla-back/internal/models"
+)
+
+// MarkDelivered acknowledges that the device received messages up to message_id
+func (h *MessagesHandler) MarkDelivered(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	var req models.MarkDeliveredRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	messageID, _ := uuid.Parse(req.MessageID)
+
+	event, notify, err := h.repo.MarkDelivered(r.Context(), convID, userID, messageID)
+	if err != nil {
+		if errors.Is(err, messages.ErrNotParticipant) {
+			respondError(w, http.StatusForbidden, "Not a participant")
+			return
+		}
+		if errors.Is(err, messages.ErrMessageNotFound) {
+			respondError(w, http.StatusNotFound, "Message not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to mark as delivered")
+		return
+	}
+
+	if notify {
+		participantIDs, _ := h.repo.GetConversationParticipantIDs(r.Context(), convID)
+		h.rt.PublishToUsers(excludeID(participantIDs, userID), "MESSAGE_DELIVERED", event)
+	}
+
+	respondJSON(w, http.StatusOK, event)
+}
+
+// GetDeliveryStatus returns per-recipient sent/delivered/read status of a message
+func (h *MessagesHandler) GetDeliveryStatus(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	messageID, err := uuid.Parse(r.PathValue("messageId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	status, err := h.repo.GetDeliveryStatus(r.Context(), convID, messageID, userID)
+	if err != nil {
+		if errors.Is(err, messages.ErrNotParticipant) {
+			respondError(w, http.StatusForbidden, "Not a participant")
+			return
+		}
+		if errors.Is(err, messages.ErrMessageNotFound) {
+			respondError(w, http.StatusNotFound, "Message not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to get delivery status")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, status)
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/messages"
+)
+
+// DiscoverGroups lists public groups, optionally searched by name (?q=)
+func (h *MessagesHandler) DiscoverGroups(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if len(query) > 100 {
+		respondError(w, http.StatusBadRequest, "Search query too long")
+		return
+	}
+
+	limit := 20
+	offset := 0
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		}
+	}
+	if o := r.URL.Query().Get("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			offset = parsed
+		}
+	}
+
+	groups, err := h.repo.DiscoverGroups(r.Context(), userID, query, limit, offset)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get groups")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, groups)
+}
+
+// JoinPublicGroup adds the current user to a public group. Groups with join approval
+// answer 202 and notify their owners and admins instead.
+func (h *MessagesHandler) JoinPublicGroup(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	err = h.repo.JoinPublicGroup(r.Context(), convID, userID)
+	switch {
+	case errors.Is(err, messages.ErrJoinRequestPending):
+		request, err := h.repo.GetJoinRequest(r.Context(), convID, userID)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to get join request")
+			return
+		}
+		approverIDs, _ := h.repo.GetApproverIDs(r.Context(), convID)
+		h.rt.PublishToUsers(approverIDs, "JOIN_REQUEST_CREATE", request)
+
+		respondJSON(w, http.StatusAccepted, request)
+		return
+	case errors.Is(err, messages.ErrConversationNotFound):
+		respondError(w, http.StatusNotFound, "Group not found")
+		return
+	case errors.Is(err, messages.ErrUserBanned):
+		respondError(w, http.StatusForbidden, "You are banned from this group")
+		return
+	case respondGroupFull(w, err):
+		return
+	case err != nil && !errors.Is(err, messages.ErrAlreadyParticipant):
+		respondError(w, http.StatusInternalServerError, "Failed to join group")
+		return
+	}
+	joined := err == nil
+
+	conv, err := h.repo.GetConversation(r.Context(), convID, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get conversation")
+		return
+	}
+
+	if joined {
+		// Same events as being added directly
+		allParticipantIDs, _ := h.repo.GetConversationParticipantIDs(r.Context(), convID)
+		h.rt.PublishToUsers(allParticipantIDs, "CONVERSATION_UPDATE", conv)
+		h.rt.PublishToUser(userID, "CONVERSATION_CREATE", conv)
+		h.rt.SubscribeConversation(convID, []uuid.UUID{userID})
+		h.recordMembership(r.Context(), convID, "MEMBERS_ADD", []uuid.UUID{userID}, nil, "discovery")
+	}
+
+	respondJSON(w, http.StatusOK, conv)
+}
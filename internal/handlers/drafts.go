@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/messages"
+	"github.com/user/bla-back/internal/models"
+)
+
+// GetDraft returns the current user's draft for a conversation
+func (h *MessagesHandler) GetDraft(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	draft, err := h.repo.GetDraft(r.Context(), convID, userID)
+	if err != nil {
+		if errors.Is(err, messages.ErrNotParticipant) {
+			respondError(w, http.StatusForbidden, "Not a participant")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to get draft")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, draft)
+}
+
+// SaveDraft stores the current user's draft and syncs it to their other devices
+func (h *MessagesHandler) SaveDraft(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	var req models.SaveDraftRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, "Draft is too long")
+		return
+	}
+
+	draft, err := h.repo.SaveDraft(r.Context(), convID, userID, req.Content)
+	if err != nil {
+		if errors.Is(err, messages.ErrNotParticipant) {
+			respondError(w, http.StatusForbidden, "Not a participant")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to save draft")
+		return
+	}
+
+	h.rt.PublishToUser(userID, "DRAFT_UPDATE", draft)
+
+	respondJSON(w, http.StatusOK, draft)
+}
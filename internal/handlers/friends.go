@@ -7,6 +7,7 @@ import (
 
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/captcha"
 	"github.com/user/bla-back/internal/friends"
 	"github.com/user/bla-back/internal/models"
 	"github.com/user/bla-back/internal/realtime"
@@ -16,6 +17,7 @@ type FriendsHandler struct {
 	repo      *friends.Repository
 	rt        *realtime.Node
 	validator *validator.Validate
+	captcha   *captcha.Client // see SetCaptcha
 }
 
 func NewFriendsHandler(repo *friends.Repository, rt *realtime.Node) *FriendsHandler {
@@ -110,6 +112,10 @@ func (h *FriendsHandler) SendRequestByUsername(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	if !checkCaptcha(w, r, h.captcha) {
+		return
+	}
+
 	targetUser, err := h.repo.GetUserByUsername(r.Context(), req.Username)
 	if err != nil {
 		respondError(w, http.StatusNotFound, "User not found")
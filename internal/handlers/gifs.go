@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/cache"
+	"github.com/user/bla-back/internal/gifs"
+	"github.com/user/bla-back/internal/messages"
+	"github.com/user/bla-back/internal/models"
+)
+
+const (
+	gifSearchCacheTTL  = 10 * time.Minute
+	gifSearchRateLimit = 30 // searches per user per minute
+)
+
+type GIFsHandler struct {
+	client *gifs.Client
+	repo   *messages.Repository
+	cache  *cache.RedisCache
+}
+
+func NewGIFsHandler(client *gifs.Client, repo *messages.Repository, cache *cache.RedisCache) *GIFsHandler {
+	return &GIFsHandler{
+		client: client,
+		repo:   repo,
+		cache:  cache,
+	}
+}
+
+// Search proxies a GIF search to the configured provider
+func (h *GIFsHandler) Search(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if !h.client.Enabled() {
+		respondError(w, http.StatusServiceUnavailable, "GIF search is not available")
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" || len(query) > 100 {
+		respondError(w, http.StatusBadRequest, "Query must be 1-100 characters")
+		return
+	}
+
+	limit := 20
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= gifs.MaxLimit {
+			limit = parsed
+		}
+	}
+	pos := r.URL.Query().Get("pos")
+
+	cacheKey := "gifs:search:" + h.client.Provider() + ":" + strings.ToLower(query) + ":" + strconv.Itoa(limit) + ":" + pos
+	if h.cache != nil {
+		var cached models.GIFSearchResult
+		if err := h.cache.GetJSON(r.Context(), cacheKey, &cached); err == nil {
+			respondJSON(w, http.StatusOK, &cached)
+			return
+		}
+
+		allowed, err := h.cache.CheckRateLimit(r.Context(), "ratelimit:gifs:"+userID.String(), gifSearchRateLimit, time.Minute)
+		if err == nil && !allowed {
+			respondError(w, http.StatusTooManyRequests, "Too many searches")
+			return
+		}
+	}
+
+	result, err := h.client.Search(r.Context(), query, limit, pos)
+	if err != nil {
+		log.Printf("GIF search error: %v", err)
+		respondError(w, http.StatusBadGateway, "GIF search failed")
+		return
+	}
+
+	if h.cache != nil {
+		h.cache.SetJSON(r.Context(), cacheKey, result, gifSearchCacheTTL)
+	}
+
+	respondJSON(w, http.StatusOK, result)
+}
+
+// CreateAttachment turns a provider GIF into a "gif" attachment to send with attachment_ids.
+// The GIF is resolved server-side so clients can't attach arbitrary URLs.
+func (h *GIFsHandler) CreateAttachment(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if !h.client.Enabled() {
+		respondError(w, http.StatusServiceUnavailable, "GIFs are not available")
+		return
+	}
+
+	gif, err := h.client.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, gifs.ErrGIFNotFound) {
+			respondError(w, http.StatusNotFound, "GIF not found")
+			return
+		}
+		log.Printf("GIF lookup error: %v", err)
+		respondError(w, http.StatusBadGateway, "GIF lookup failed")
+		return
+	}
+	if gif.URL == "" {
+		respondError(w, http.StatusNotFound, "GIF not found")
+		return
+	}
+
+	attachment, err := h.repo.CreateGIFAttachment(r.Context(), userID, gif)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to create attachment")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, attachment)
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/messages"
+	"github.com/user/bla-back/internal/models"
+)
+
+// InviteToGroup invites a user to a group. It's the only way back in for users who left
+// and opted out of being re-added.
+func (h *MessagesHandler) InviteToGroup(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	var req models.InviteToGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	inviteeID, _ := uuid.Parse(req.UserID)
+
+	invite, err := h.repo.InviteToGroup(r.Context(), convID, userID, inviteeID)
+	if err != nil {
+		switch {
+		case errors.Is(err, messages.ErrConversationNotFound):
+			respondError(w, http.StatusNotFound, "Conversation not found")
+		case errors.Is(err, messages.ErrNotParticipant):
+			respondError(w, http.StatusForbidden, "Not a participant")
+		case errors.Is(err, messages.ErrPermissionDenied):
+			respondError(w, http.StatusForbidden, "Your role doesn't allow adding members")
+		case errors.Is(err, messages.ErrBlocked):
+			respondError(w, http.StatusForbidden, "Cannot invite this user")
+		case errors.Is(err, messages.ErrUserBanned):
+			respondError(w, http.StatusForbidden, "User is banned from this group")
+		case errors.Is(err, messages.ErrAlreadyParticipant):
+			respondError(w, http.StatusConflict, "User is already a participant")
+		case errors.Is(err, messages.ErrInviteeNotFound):
+			respondError(w, http.StatusNotFound, "User not found")
+		default:
+			respondError(w, http.StatusInternalServerError, "Failed to invite user")
+		}
+		return
+	}
+
+	h.rt.PublishToUser(inviteeID, "GROUP_INVITE_CREATE", invite)
+
+	respondJSON(w, http.StatusCreated, invite)
+}
+
+// GetGroupInvites lists the group invites waiting for the current user
+func (h *MessagesHandler) GetGroupInvites(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	invites, err := h.repo.GetGroupInvites(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get group invites")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, invites)
+}
+
+// AcceptGroupInvite joins the group the user was invited to
+func (h *MessagesHandler) AcceptGroupInvite(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	if err := h.repo.AcceptGroupInvite(r.Context(), convID, userID); err != nil {
+		if respondGroupFull(w, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, messages.ErrNoGroupInvite):
+			respondError(w, http.StatusNotFound, "Invite not found")
+		case errors.Is(err, messages.ErrConversationNotFound):
+			respondError(w, http.StatusNotFound, "Conversation not found")
+		default:
+			respondError(w, http.StatusInternalServerError, "Failed to accept invite")
+		}
+		return
+	}
+
+	conv, err := h.repo.GetConversation(r.Context(), convID, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get conversation")
+		return
+	}
+
+	// Same events as being added directly
+	allParticipantIDs, _ := h.repo.GetConversationParticipantIDs(r.Context(), convID)
+	h.rt.PublishToUsers(allParticipantIDs, "CONVERSATION_UPDATE", conv)
+	h.rt.PublishToUser(userID, "CONVERSATION_CREATE", conv)
+	h.rt.SubscribeConversation(convID, []uuid.UUID{userID})
+	h.recordMembership(r.Context(), convID, "MEMBERS_ADD", []uuid.UUID{userID}, nil, "invite")
+
+	respondJSON(w, http.StatusOK, conv)
+}
+
+// DeclineGroupInvite drops a group invite
+func (h *MessagesHandler) DeclineGroupInvite(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	if err := h.repo.DeclineGroupInvite(r.Context(), convID, userID); err != nil {
+		if errors.Is(err, messages.ErrNoGroupInvite) {
+			respondError(w, http.StatusNotFound, "Invite not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to decline invite")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Invite declined"})
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/messages"
+	"github.com/user/bla-back/internal/models"
+)
+
+// SetParticipantRole promotes or demotes a group participant (owner only). Setting the
+// role to "owner" transfers ownership and makes the caller an admin.
+func (h *MessagesHandler) SetParticipantRole(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	targetID, err := uuid.Parse(r.PathValue("userId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var req models.UpdateParticipantRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, "Role must be owner, admin or member")
+		return
+	}
+
+	err = h.repo.SetParticipantRole(r.Context(), convID, userID, targetID, req.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, messages.ErrConversationNotFound):
+			respondError(w, http.StatusNotFound, "Conversation not found")
+		case errors.Is(err, messages.ErrDMRoles), errors.Is(err, messages.ErrOwnRole):
+			respondError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, messages.ErrNotParticipant):
+			respondError(w, http.StatusForbidden, "Not a participant")
+		case errors.Is(err, messages.ErrPermissionDenied):
+			respondError(w, http.StatusForbidden, "Only the group owner can change roles")
+		case errors.Is(err, messages.ErrParticipantNotFound):
+			respondError(w, http.StatusNotFound, "User is not a participant")
+		default:
+			respondError(w, http.StatusInternalServerError, "Failed to update role")
+		}
+		return
+	}
+
+	h.recordAudit(r.Context(), convID, userID, messages.AuditRoleChange, &targetID, map[string]string{"role": req.Role})
+
+	// Participants carry their roles, so the update is a regular conversation update
+	conv, err := h.repo.GetConversation(r.Context(), convID, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get conversation")
+		return
+	}
+
+	allParticipantIDs, _ := h.repo.GetConversationParticipantIDs(r.Context(), convID)
+	h.rt.PublishToUsers(allParticipantIDs, "CONVERSATION_UPDATE", conv)
+
+	respondJSON(w, http.StatusOK, conv)
+}
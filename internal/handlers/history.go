@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/messages"
+	"github.com/user/bla-back/internal/models"
+)
+
+// ClearHistory deletes messages sent before ?before= (RFC 3339, default now).
+// Group owners clear the group for everyone; in DMs users clear their own copy.
+func (h *MessagesHandler) ClearHistory(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	before := time.Now()
+	if b := r.URL.Query().Get("before"); b != "" {
+		before, err = time.Parse(time.RFC3339, b)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid before timestamp")
+			return
+		}
+	}
+
+	forEveryone, err := h.repo.ClearHistory(r.Context(), convID, userID, before)
+	if err != nil {
+		if errors.Is(err, messages.ErrConversationNotFound) {
+			respondError(w, http.StatusNotFound, "Conversation not found")
+			return
+		}
+		if errors.Is(err, messages.ErrNotParticipant) {
+			respondError(w, http.StatusForbidden, "Not a participant")
+			return
+		}
+		if errors.Is(err, messages.ErrNotGroupOwner) {
+			respondError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to clear history")
+		return
+	}
+
+	event := &models.ConversationClearEvent{
+		ConversationID: convID,
+		Before:         before,
+		ForEveryone:    forEveryone,
+	}
+	if forEveryone {
+		participantIDs, _ := h.repo.GetConversationParticipantIDs(r.Context(), convID)
+		h.rt.PublishToUsers(participantIDs, "CONVERSATION_CLEAR", event)
+	} else {
+		h.rt.PublishToUser(userID, "CONVERSATION_CLEAR", event)
+	}
+
+	respondJSON(w, http.StatusOK, event)
+}
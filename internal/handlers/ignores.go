@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/messages"
+	"github.com/user/bla-back/internal/models"
+)
+
+// GetIgnoredUsers lists participants whose messages the current user hides in a conversation
+func (h *MessagesHandler) GetIgnoredUsers(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	users, err := h.repo.GetIgnoredUsers(r.Context(), convID, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get ignored users")
+		return
+	}
+
+	if users == nil {
+		users = []*models.User{}
+	}
+
+	respondJSON(w, http.StatusOK, users)
+}
+
+// IgnoreUser hides a participant's messages for the current user only. Unlike a block,
+// the other user is not affected and can still see the current user's messages.
+func (h *MessagesHandler) IgnoreUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	ignoredID, err := uuid.Parse(r.PathValue("userId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if err := h.repo.IgnoreUser(r.Context(), convID, userID, ignoredID); err != nil {
+		if errors.Is(err, messages.ErrNotParticipant) {
+			respondError(w, http.StatusForbidden, "Not a participant")
+			return
+		}
+		if errors.Is(err, messages.ErrCannotIgnoreSelf) {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to ignore user")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "User ignored"})
+}
+
+// UnignoreUser shows a participant's messages again
+func (h *MessagesHandler) UnignoreUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	ignoredID, err := uuid.Parse(r.PathValue("userId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if err := h.repo.UnignoreUser(r.Context(), convID, userID, ignoredID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to unignore user")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "User unignored"})
+}
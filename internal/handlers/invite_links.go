@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/messages"
+	"github.com/user/bla-back/internal/models"
+)
+
+// CreateInviteLink creates a shareable invite code for a group, optionally expiring or
+// limited in uses
+func (h *MessagesHandler) CreateInviteLink(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	var req models.CreateInviteLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+		return
+	}
+
+	var maxUses *int
+	if req.MaxUses > 0 {
+		maxUses = &req.MaxUses
+	}
+	var expiresAt *time.Time
+	if req.ExpiresInHours > 0 {
+		t := time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour)
+		expiresAt = &t
+	}
+
+	link, err := h.repo.CreateInviteLink(r.Context(), convID, userID, maxUses, expiresAt)
+	if err != nil {
+		respondInviteLinkError(w, err, "Failed to create invite link")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, link)
+}
+
+// GetInviteLinks lists a group's invite links that still work
+func (h *MessagesHandler) GetInviteLinks(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	links, err := h.repo.GetInviteLinks(r.Context(), convID, userID)
+	if err != nil {
+		respondInviteLinkError(w, err, "Failed to get invite links")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, links)
+}
+
+// RevokeInviteLink disables an invite link
+func (h *MessagesHandler) RevokeInviteLink(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	if err := h.repo.RevokeInviteLink(r.Context(), convID, userID, r.PathValue("code")); err != nil {
+		respondInviteLinkError(w, err, "Failed to revoke invite link")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Invite link revoked"})
+}
+
+// JoinByInviteLink adds the current user to the group behind an invite code. Anyone
+// holding the code can join; they don't need to know a participant. Groups with join
+// approval answer 202 and notify their owners and admins instead.
+func (h *MessagesHandler) JoinByInviteLink(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := h.repo.JoinByInviteLink(r.Context(), r.PathValue("code"), userID)
+	if errors.Is(err, messages.ErrJoinRequestPending) {
+		request, err := h.repo.GetJoinRequest(r.Context(), convID, userID)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to get join request")
+			return
+		}
+		approverIDs, _ := h.repo.GetApproverIDs(r.Context(), convID)
+		h.rt.PublishToUsers(approverIDs, "JOIN_REQUEST_CREATE", request)
+
+		respondJSON(w, http.StatusAccepted, request)
+		return
+	}
+	if err != nil && !errors.Is(err, messages.ErrAlreadyParticipant) {
+		respondInviteLinkError(w, err, "Failed to join group")
+		return
+	}
+	joined := err == nil
+
+	conv, err := h.repo.GetConversation(r.Context(), convID, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get conversation")
+		return
+	}
+
+	if joined {
+		// Same events as being added directly
+		allParticipantIDs, _ := h.repo.GetConversationParticipantIDs(r.Context(), convID)
+		h.rt.PublishToUsers(allParticipantIDs, "CONVERSATION_UPDATE", conv)
+		h.rt.PublishToUser(userID, "CONVERSATION_CREATE", conv)
+		h.rt.SubscribeConversation(convID, []uuid.UUID{userID})
+		h.recordMembership(r.Context(), convID, "MEMBERS_ADD", []uuid.UUID{userID}, nil, "link")
+	}
+
+	respondJSON(w, http.StatusOK, conv)
+}
+
+func respondInviteLinkError(w http.ResponseWriter, err error, fallback string) {
+	if respondGroupFull(w, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, messages.ErrConversationNotFound):
+		respondError(w, http.StatusNotFound, "Conversation not found")
+	case errors.Is(err, messages.ErrNotParticipant):
+		respondError(w, http.StatusForbidden, "Not a participant")
+	case errors.Is(err, messages.ErrPermissionDenied):
+		respondError(w, http.StatusForbidden, "Your role doesn't allow inviting members")
+	case errors.Is(err, messages.ErrInviteLinkNotFound):
+		respondError(w, http.StatusNotFound, "Invite link not found")
+	case errors.Is(err, messages.ErrInviteLinkExpired):
+		respondError(w, http.StatusGone, "Invite link expired or used up")
+	case errors.Is(err, messages.ErrUserBanned):
+		respondError(w, http.StatusForbidden, "You are banned from this group")
+	default:
+		respondError(w, http.StatusInternalServerError, fallback)
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/messages"
+	"github.com/user/bla-back/internal/models"
+)
+
+// GetJoinRequests lists users waiting to join a group with join approval (owners and admins)
+func (h *MessagesHandler) GetJoinRequests(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	requests, err := h.repo.GetJoinRequests(r.Context(), convID, userID)
+	if err != nil {
+		respondJoinRequestError(w, err, "Failed to get join requests")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, requests)
+}
+
+// ApproveJoinRequest lets a waiting user into the group
+func (h *MessagesHandler) ApproveJoinRequest(w http.ResponseWriter, r *http.Request) {
+	h.resolveJoinRequest(w, r, true)
+}
+
+// DenyJoinRequest turns a waiting user away
+func (h *MessagesHandler) DenyJoinRequest(w http.ResponseWriter, r *http.Request) {
+	h.resolveJoinRequest(w, r, false)
+}
+
+func (h *MessagesHandler) resolveJoinRequest(w http.ResponseWriter, r *http.Request, approve bool) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	targetID, err := uuid.Parse(r.PathValue("userId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if approve {
+		err = h.repo.ApproveJoinRequest(r.Context(), convID, userID, targetID)
+	} else {
+		err = h.repo.DenyJoinRequest(r.Context(), convID, userID, targetID)
+	}
+	if err != nil {
+		respondJoinRequestError(w, err, "Failed to resolve join request")
+		return
+	}
+
+	// The requester and every approver (so other admins drop it from their list)
+	approverIDs, _ := h.repo.GetApproverIDs(r.Context(), convID)
+	h.rt.PublishToUsers(append(approverIDs, targetID), "JOIN_REQUEST_RESOLVE", &models.JoinRequestResolveEvent{
+		ConversationID: convID,
+		UserID:         targetID,
+		Approved:       approve,
+		ResolvedBy:     userID,
+	})
+
+	if !approve {
+		respondJSON(w, http.StatusOK, map[string]string{"message": "Join request denied"})
+		return
+	}
+
+	conv, err := h.repo.GetConversation(r.Context(), convID, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get conversation")
+		return
+	}
+
+	// Same events as being added directly
+	allParticipantIDs, _ := h.repo.GetConversationParticipantIDs(r.Context(), convID)
+	h.rt.PublishToUsers(allParticipantIDs, "CONVERSATION_UPDATE", conv)
+	h.rt.PublishToUser(targetID, "CONVERSATION_CREATE", conv)
+	h.rt.SubscribeConversation(convID, []uuid.UUID{targetID})
+	h.recordMembership(r.Context(), convID, "MEMBERS_ADD", []uuid.UUID{targetID}, &userID, "approved")
+
+	respondJSON(w, http.StatusOK, conv)
+}
+
+func respondJoinRequestError(w http.ResponseWriter, err error, fallback string) {
+	if respondGroupFull(w, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, messages.ErrNotParticipant):
+		respondError(w, http.StatusForbidden, "Not a participant")
+	case errors.Is(err, messages.ErrPermissionDenied):
+		respondError(w, http.StatusForbidden, "Only group owners and admins can handle join requests")
+	case errors.Is(err, messages.ErrJoinRequestNotFound):
+		respondError(w, http.StatusNotFound, "Join request not found")
+	case errors.Is(err, messages.ErrUserBanned):
+		respondError(w, http.StatusForbidden, "User is banned from this group")
+	default:
+		respondError(w, http.StatusInternalServerError, fallback)
+	}
+}
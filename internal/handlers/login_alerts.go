@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/models"
+	"github.com/user/bla-back/internal/notifications"
+	"github.com/user/bla-back/internal/realtime"
+)
+
+const maxUserAgentLength = 512
+
+// SetLoginAlerts alerts users to sign-ins from new devices; mailer is nil without SMTP
+func (h *AuthHandler) SetLoginAlerts(rt *realtime.Node, mailer *notifications.EmailChannel) {
+	h.rt = rt
+	h.mailer = mailer
+}
+
+// deviceKey identifies the device a session is issued to: the ID apps send in
+// X-Device-ID, or else a hash of the browser's user agent. Many browsers share a user
+// agent, so those are only known from the IPs they signed in from (perIP).
+func deviceKey(r *http.Request) (key string, perIP bool) {
+	if id := r.Header.Get("X-Device-ID"); id != "" && len(id) <= 100 {
+		return "id:" + id, false
+	}
+	sum := sha256.Sum256([]byte(r.UserAgent()))
+	return "ua:" + hex.EncodeToString(sum[:16]), true
+}
+
+// checkLoginDevice records where the user signed in and, when that's a device (or for
+// browsers, a device and IP) they haven't signed in from before, sends a SECURITY_ALERT event and an email
+func (h *AuthHandler) checkLoginDevice(r *http.Request, userID uuid.UUID) {
+	if h.rt == nil {
+		return
+	}
+
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	ip := clientIP(r)
+
+	key, perIP := deviceKey(r)
+	isNew, err := h.repo.RecordLoginDevice(r.Context(), userID, key, ip, userAgent, perIP)
+	if err != nil {
+		log.Printf("Failed to record login device for user %s: %v", userID, err)
+		return
+	}
+	if !isNew {
+		return
+	}
+
+	alert := &models.SecurityAlertEvent{
+		Type:      "new_device_login",
+		IP:        ip,
+		UserAgent: userAgent,
+		CreatedAt: time.Now(),
+	}
+	h.rt.PublishToUser(userID, "SECURITY_ALERT", alert)
+
+	if h.mailer == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		user, err := h.repo.GetUserByID(ctx, userID)
+		if err != nil {
+			log.Printf("Failed to get user %s for login alert: %v", userID, err)
+			return
+		}
+		if err := h.mailer.SendLoginAlert(ctx, user.Email, alert); err != nil {
+			log.Printf("Failed to email login alert to user %s: %v", userID, err)
+		}
+	}()
+}
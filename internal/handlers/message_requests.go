@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/messages"
+	"github.com/user/bla-back/internal/models"
+)
+
+// GetMessageRequests lists DMs from non-friends waiting for the user to accept them
+func (h *MessagesHandler) GetMessageRequests(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	requests, err := h.repo.GetMessageRequests(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get message requests")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, requests)
+}
+
+// AcceptMessageRequest turns a message request into a regular DM: the user starts getting
+// the requester's messages and presence, and can reply
+func (h *MessagesHandler) AcceptMessageRequest(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	requesterID, err := h.repo.AcceptMessageRequest(r.Context(), convID, userID)
+	if err != nil {
+		if errors.Is(err, messages.ErrNoMessageRequest) {
+			respondError(w, http.StatusNotFound, "Message request not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to accept message request")
+		return
+	}
+
+	conv, err := h.repo.GetConversation(r.Context(), convID, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get conversation")
+		return
+	}
+
+	h.rt.SubscribeConversation(convID, []uuid.UUID{userID})
+	h.rt.PublishToUsers([]uuid.UUID{requesterID, userID}, "MESSAGE_REQUEST_ACCEPT", &models.MessageRequestEvent{
+		ConversationID: convID,
+		UserID:         userID,
+	})
+
+	respondJSON(w, http.StatusOK, conv)
+}
+
+// DeclineMessageRequest hides a message request; the requester can't write to the user
+// in it anymore. The user can still accept it later.
+func (h *MessagesHandler) DeclineMessageRequest(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	requesterID, err := h.repo.DeclineMessageRequest(r.Context(), convID, userID)
+	if err != nil {
+		if errors.Is(err, messages.ErrNoMessageRequest) {
+			respondError(w, http.StatusNotFound, "Message request not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to decline message request")
+		return
+	}
+
+	h.rt.PublishToUsers([]uuid.UUID{requesterID, userID}, "MESSAGE_REQUEST_DECLINE", &models.MessageRequestEvent{
+		ConversationID: convID,
+		UserID:         userID,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// announceMessageRequest tells the recipient of a message request about it when the
+// requester sends their first message; later messages wait for the request to be accepted
+func (h *MessagesHandler) announceMessageRequest(ctx context.Context, msg *models.Message) {
+	recipientID, first, err := h.repo.PendingRequestRecipient(ctx, msg.ConversationID, msg.ID)
+	if err != nil {
+		if !errors.Is(err, messages.ErrNoMessageRequest) {
+			log.Printf("Failed to check message request of conversation %s: %v", msg.ConversationID, err)
+		}
+		return
+	}
+	if !first {
+		return
+	}
+
+	req, err := h.repo.GetMessageRequest(ctx, msg.ConversationID, recipientID)
+	if err != nil {
+		log.Printf("Failed to load message request of conversation %s: %v", msg.ConversationID, err)
+		return
+	}
+	h.rt.PublishToUser(recipientID, "MESSAGE_REQUEST_CREATE", req)
+}
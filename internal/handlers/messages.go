@@ -1,32 +1,51 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"github.com/user/bla-back/internal/messages"
 	"github.com/user/bla-back/internal/models"
+	"github.com/user/bla-back/internal/notifications"
 	"github.com/user/bla-back/internal/realtime"
 	"github.com/user/bla-back/internal/storage"
+	"github.com/user/bla-back/internal/syncfeed"
+	"github.com/user/bla-back/internal/translate"
+	"github.com/user/bla-back/internal/unfurl"
 )
 
 type MessagesHandler struct {
-	repo      *messages.Repository
-	rt        *realtime.Node
-	storage   *storage.S3Storage
-	validator *validator.Validate
+	repo       *messages.Repository
+	rt         *realtime.Node
+	storage    *storage.S3Storage
+	notify     *notifications.Engine
+	unfurl     *unfurl.Worker
+	translator *translate.Client
+	sync       *syncfeed.Repository
+	validator  *validator.Validate
+
+	// how long an owner can restore a deleted group
+	groupRestoreWindow time.Duration
 }
 
-func NewMessagesHandler(repo *messages.Repository, rt *realtime.Node, storage *storage.S3Storage) *MessagesHandler {
+func NewMessagesHandler(repo *messages.Repository, rt *realtime.Node, storage *storage.S3Storage, notify *notifications.Engine, unfurler *unfurl.Worker, translator *translate.Client, sync *syncfeed.Repository, groupRestoreWindow time.Duration) *MessagesHandler {
 	return &MessagesHandler{
-		repo:      repo,
-		rt:        rt,
-		storage:   storage,
-		validator: validator.New(),
+		repo:               repo,
+		rt:                 rt,
+		storage:            storage,
+		notify:             notify,
+		unfurl:             unfurler,
+		translator:         translator,
+		sync:               sync,
+		validator:          validator.New(),
+		groupRestoreWindow: groupRestoreWindow,
 	}
 }
 
@@ -78,10 +97,25 @@ func (h *MessagesHandler) GetOrCreateDM(w http.ResponseWriter, r *http.Request)
 
 	conv, err := h.repo.GetOrCreateDM(r.Context(), userID, otherUserID)
 	if err != nil {
+		if errors.Is(err, messages.ErrBlocked) {
+			respondError(w, http.StatusForbidden, err.Error())
+			return
+		}
 		respondError(w, http.StatusInternalServerError, "Failed to create conversation")
 		return
 	}
 
+	// A new DM has no subscribers yet, so join both sides to its channel. A message
+	// request is only followed by the requester until the recipient accepts it.
+	subscribers := []uuid.UUID{userID, otherUserID}
+	if conv.RequestStatus != nil {
+		subscribers = nil
+		if awaiting, err := h.repo.IsAwaitingRequest(r.Context(), conv.ID, userID); err == nil && !awaiting {
+			subscribers = []uuid.UUID{userID}
+		}
+	}
+	h.rt.SubscribeConversation(conv.ID, subscribers)
+
 	respondJSON(w, http.StatusOK, conv)
 }
 
@@ -180,6 +214,16 @@ func (h *MessagesHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.Nonce != nil && (*req.Nonce == "" || len(*req.Nonce) > 64) {
+		respondError(w, http.StatusBadRequest, "Invalid nonce")
+		return
+	}
+
+	if req.StickerID != nil {
+		h.sendSticker(w, r, convID, userID, req)
+		return
+	}
+
 	// Must have content or attachments
 	if req.Content == "" && len(req.AttachmentIDs) == 0 {
 		respondError(w, http.StatusBadRequest, "Message must have content or attachments")
@@ -197,26 +241,100 @@ func (h *MessagesHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 		attachmentIDs = append(attachmentIDs, id)
 	}
 
-	msg, err := h.repo.SendMessageWithAttachments(r.Context(), convID, userID, req.Content, attachmentIDs)
+	msg, created, err := h.repo.SendMessageOnce(r.Context(), convID, userID, req.Content, attachmentIDs, req.Nonce)
 	if err != nil {
-		if errors.Is(err, messages.ErrNotParticipant) {
-			respondError(w, http.StatusForbidden, "Not a participant")
+		if respondSendError(w, err) {
+			return
+		}
+		if errors.Is(err, messages.ErrNonceReused) {
+			respondError(w, http.StatusConflict, err.Error())
 			return
 		}
 		respondError(w, http.StatusInternalServerError, "Failed to send message")
 		return
 	}
 
-	// Broadcast to all participants via Centrifuge
-	participantIDs, _ := h.repo.GetConversationParticipantIDs(r.Context(), convID)
-	h.rt.PublishToUsers(participantIDs, "MESSAGE_CREATE", &models.MessageCreateEvent{
-		Message:        msg,
-		ConversationID: convID,
-	})
+	// A retried send: participants already got this message
+	if !created {
+		respondJSON(w, http.StatusOK, msg)
+		return
+	}
+
+	mentioned := h.saveMentions(r.Context(), msg)
+
+	// Broadcast to all participants via Centrifuge, except those ignoring the sender
+	participantIDs := h.publishMessageCreate(r.Context(), msg)
+
+	h.publishMentions(msg, mentioned, participantIDs)
+	h.announceMessageRequest(r.Context(), msg)
+	h.unfurl.Enqueue(msg)
 
 	respondJSON(w, http.StatusCreated, msg)
 }
 
+// publishFromSender publishes a participant's event on the conversation channel and
+// returns the recipients. If anyone ignores the sender it falls back to their user
+// channels, since channel subscribers can't be filtered.
+func (h *MessagesHandler) publishFromSender(ctx context.Context, convID, senderID uuid.UUID, eventType string, data interface{}) []uuid.UUID {
+	recipientIDs, filtered, err := h.repo.GetRecipients(ctx, convID, senderID)
+	if err != nil {
+		log.Printf("Failed to get recipients for conversation %s: %v", convID, err)
+		return nil
+	}
+	if filtered {
+		h.rt.PublishToUsers(recipientIDs, eventType, data)
+	} else {
+		h.rt.PublishToConversation(convID, recipientIDs, eventType, data)
+	}
+	h.recordSync(ctx, convID, eventType, data)
+	return recipientIDs
+}
+
+// saveMentions stores @mentions in the message content and returns the mentioned users
+func (h *MessagesHandler) saveMentions(ctx context.Context, msg *models.Message) []*models.User {
+	usernames := messages.ParseMentions(msg.Content)
+	if len(usernames) == 0 {
+		return nil
+	}
+
+	mentioned, err := h.repo.SaveMentions(ctx, msg, usernames)
+	if err != nil {
+		log.Printf("Failed to save mentions for message %s: %v", msg.ID, err)
+		return nil
+	}
+	return mentioned
+}
+
+// publishMentions sends MENTION to each mentioned recipient and runs offline notification rules
+func (h *MessagesHandler) publishMentions(msg *models.Message, mentioned []*models.User, recipientIDs []uuid.UUID) {
+	// Users ignoring the sender don't get mentions either
+	var notified []*models.User
+	for _, user := range mentioned {
+		if containsID(recipientIDs, user.ID) {
+			notified = append(notified, user)
+		}
+	}
+	mentioned = notified
+	if len(mentioned) == 0 {
+		return
+	}
+
+	for _, user := range mentioned {
+		h.rt.PublishToUser(user.ID, "MENTION", &models.MentionEvent{
+			Message:        msg,
+			ConversationID: msg.ConversationID,
+		})
+	}
+
+	// Email/webhook notifications for mentioned users who are away
+	go func() {
+		ctx := context.Background()
+		for _, user := range mentioned {
+			h.notify.NotifyMention(ctx, user, msg)
+		}
+	}()
+}
+
 // UploadAttachment uploads a file attachment
 func (h *MessagesHandler) UploadAttachment(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value("userID").(uuid.UUID)
@@ -315,6 +433,9 @@ func (h *MessagesHandler) CreateGroup(w http.ResponseWriter, r *http.Request) {
 
 	conv, err := h.repo.CreateGroup(r.Context(), userID, name, participantIDs)
 	if err != nil {
+		if respondGroupFull(w, err) {
+			return
+		}
 		respondError(w, http.StatusInternalServerError, "Failed to create group")
 		return
 	}
@@ -322,10 +443,27 @@ func (h *MessagesHandler) CreateGroup(w http.ResponseWriter, r *http.Request) {
 	// Notify all participants about the new group
 	allParticipantIDs, _ := h.repo.GetConversationParticipantIDs(r.Context(), conv.ID)
 	h.rt.PublishToUsers(allParticipantIDs, "CONVERSATION_CREATE", conv)
+	h.rt.SubscribeConversation(conv.ID, allParticipantIDs)
 
 	respondJSON(w, http.StatusCreated, conv)
 }
 
+// respondGroupFull writes a 422 with the limit and counts if err is a group size
+// error, reporting whether it did
+func respondGroupFull(w http.ResponseWriter, err error) bool {
+	var full *messages.GroupFullError
+	if !errors.As(err, &full) {
+		return false
+	}
+	respondJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+		"error":          "Group is full",
+		"max_group_size": full.Max,
+		"current_size":   full.Current,
+		"requested":      full.Requested,
+	})
+	return true
+}
+
 // AddParticipants adds participants to a group conversation
 func (h *MessagesHandler) AddParticipants(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value("userID").(uuid.UUID)
@@ -368,6 +506,25 @@ func (h *MessagesHandler) AddParticipants(w http.ResponseWriter, r *http.Request
 			respondError(w, http.StatusForbidden, "Not a participant")
 			return
 		}
+		if errors.Is(err, messages.ErrPermissionDenied) {
+			respondError(w, http.StatusForbidden, "Your role doesn't allow adding members")
+			return
+		}
+		if errors.Is(err, messages.ErrUserBanned) {
+			respondError(w, http.StatusForbidden, "Some users are banned from this group")
+			return
+		}
+		var readdBlocked *messages.ReaddBlockedError
+		if errors.As(err, &readdBlocked) {
+			respondJSON(w, http.StatusConflict, map[string]interface{}{
+				"error":    "Some users left this group and opted out of being re-added; invite them instead",
+				"user_ids": readdBlocked.UserIDs,
+			})
+			return
+		}
+		if respondGroupFull(w, err) {
+			return
+		}
 		if errors.Is(err, messages.ErrConversationNotFound) {
 			respondError(w, http.StatusNotFound, "Conversation not found")
 			return
@@ -386,9 +543,11 @@ func (h *MessagesHandler) AddParticipants(w http.ResponseWriter, r *http.Request
 	// Notify all participants (including new ones) about the update
 	allParticipantIDs, _ := h.repo.GetConversationParticipantIDs(r.Context(), convID)
 	h.rt.PublishToUsers(allParticipantIDs, "CONVERSATION_UPDATE", conv)
+	h.recordMembership(r.Context(), convID, "MEMBERS_ADD", userIDs, &userID, "added")
 
 	// Also send CONVERSATION_CREATE to new participants so they see it in their list
 	h.rt.PublishToUsers(userIDs, "CONVERSATION_CREATE", conv)
+	h.rt.SubscribeConversation(convID, userIDs)
 
 	respondJSON(w, http.StatusOK, conv)
 }
@@ -439,8 +598,12 @@ func (h *MessagesHandler) UploadGroupAvatar(w http.ResponseWriter, r *http.Reque
 	// Update group avatar in database
 	err = h.repo.UpdateGroupAvatar(r.Context(), convID, userID, avatarURL)
 	if err != nil {
-		if err.Error() == "only the group owner can update the avatar" {
-			respondError(w, http.StatusForbidden, err.Error())
+		if errors.Is(err, messages.ErrPermissionDenied) {
+			respondError(w, http.StatusForbidden, "Only group owners and admins can update the avatar")
+			return
+		}
+		if errors.Is(err, messages.ErrNotParticipant) {
+			respondError(w, http.StatusForbidden, "Not a participant")
 			return
 		}
 		if errors.Is(err, messages.ErrConversationNotFound) {
@@ -460,11 +623,17 @@ func (h *MessagesHandler) UploadGroupAvatar(w http.ResponseWriter, r *http.Reque
 
 	allParticipantIDs, _ := h.repo.GetConversationParticipantIDs(r.Context(), convID)
 	h.rt.PublishToUsers(allParticipantIDs, "CONVERSATION_UPDATE", conv)
+	h.postGroupUpdateMessage(r.Context(), convID, &models.GroupUpdateMessageContent{
+		ActorID:   userID,
+		Changes:   []string{"avatar"},
+		AvatarURL: &avatarURL,
+	})
+	h.recordAudit(r.Context(), convID, userID, messages.AuditGroupAvatar, nil, map[string]string{"avatar_url": avatarURL})
 
 	respondJSON(w, http.StatusOK, conv)
 }
 
-// UpdateGroup updates group settings (name)
+// UpdateGroup updates group settings (name, description, join approval, visibility)
 func (h *MessagesHandler) UpdateGroup(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value("userID").(uuid.UUID)
 	if !ok {
@@ -484,10 +653,35 @@ func (h *MessagesHandler) UpdateGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.repo.UpdateGroupName(r.Context(), convID, userID, req.Name)
+	if err := h.validator.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, "Name must be at most 100 characters and description at most 500")
+		return
+	}
+	if req.CallPermission != nil && !messages.ValidCallPermission(*req.CallPermission) {
+		respondError(w, http.StatusBadRequest, "call_permission must be everyone or admins")
+		return
+	}
+	if req.Name == nil && req.Description == nil && req.JoinApproval == nil && req.Public == nil && req.AnnouncementOnly == nil && req.CallPermission == nil {
+		respondError(w, http.StatusBadRequest, "Nothing to update")
+		return
+	}
+
+	// Remember the old name so only an actual rename shows up in the timeline
+	var previousName *string
+	if req.Name != nil {
+		if before, err := h.repo.GetConversation(r.Context(), convID, userID); err == nil {
+			previousName = before.Name
+		}
+	}
+
+	err = h.repo.UpdateGroup(r.Context(), convID, userID, &req)
 	if err != nil {
-		if err.Error() == "only the group owner can update the name" {
-			respondError(w, http.StatusForbidden, err.Error())
+		if errors.Is(err, messages.ErrPermissionDenied) {
+			respondError(w, http.StatusForbidden, "Only group owners and admins can update the group")
+			return
+		}
+		if errors.Is(err, messages.ErrNotParticipant) {
+			respondError(w, http.StatusForbidden, "Not a participant")
 			return
 		}
 		if errors.Is(err, messages.ErrConversationNotFound) {
@@ -507,11 +701,47 @@ func (h *MessagesHandler) UpdateGroup(w http.ResponseWriter, r *http.Request) {
 
 	allParticipantIDs, _ := h.repo.GetConversationParticipantIDs(r.Context(), convID)
 	h.rt.PublishToUsers(allParticipantIDs, "CONVERSATION_UPDATE", conv)
+	if req.Name != nil && (previousName == nil || *previousName != *req.Name) {
+		h.postGroupUpdateMessage(r.Context(), convID, &models.GroupUpdateMessageContent{
+			ActorID: userID,
+			Changes: []string{"name"},
+			Name:    req.Name,
+		})
+		h.recordAudit(r.Context(), convID, userID, messages.AuditGroupRename, nil, map[string]interface{}{
+			"name":          *req.Name,
+			"previous_name": previousName,
+		})
+	}
+	if settings := groupSettingsChanges(&req); len(settings) > 0 {
+		h.recordAudit(r.Context(), convID, userID, messages.AuditGroupSettings, nil, settings)
+	}
 
 	respondJSON(w, http.StatusOK, conv)
 }
 
-// LeaveGroup removes the user from a group conversation
+// groupSettingsChanges returns the settings other than the name an update sets, for the audit log
+func groupSettingsChanges(req *models.UpdateGroupRequest) map[string]interface{} {
+	changes := map[string]interface{}{}
+	if req.Description != nil {
+		changes["description"] = *req.Description
+	}
+	if req.JoinApproval != nil {
+		changes["join_approval"] = *req.JoinApproval
+	}
+	if req.Public != nil {
+		changes["public"] = *req.Public
+	}
+	if req.AnnouncementOnly != nil {
+		changes["announcement_only"] = *req.AnnouncementOnly
+	}
+	if req.CallPermission != nil {
+		changes["call_permission"] = *req.CallPermission
+	}
+	return changes
+}
+
+// LeaveGroup removes the user from a group conversation. ?prevent_readd=true stops
+// others from adding them back without an invite they accept.
 func (h *MessagesHandler) LeaveGroup(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value("userID").(uuid.UUID)
 	if !ok {
@@ -528,7 +758,9 @@ func (h *MessagesHandler) LeaveGroup(w http.ResponseWriter, r *http.Request) {
 	// Get participant IDs before leaving (to notify them)
 	participantIDs, _ := h.repo.GetConversationParticipantIDs(r.Context(), convID)
 
-	err = h.repo.LeaveGroup(r.Context(), convID, userID)
+	preventReadd := r.URL.Query().Get("prevent_readd") == "true"
+
+	err = h.repo.LeaveGroup(r.Context(), convID, userID, preventReadd)
 	if err != nil {
 		if errors.Is(err, messages.ErrNotParticipant) {
 			respondError(w, http.StatusForbidden, "Not a participant")
@@ -546,6 +778,11 @@ func (h *MessagesHandler) LeaveGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Stop relaying the group's canvas and events to devices still subscribed
+	h.rt.RevokeCanvas(convID, userID)
+	h.rt.RevokeConversation(convID, userID)
+	h.recordMembership(r.Context(), convID, "MEMBERS_REMOVE", []uuid.UUID{userID}, &userID, "left")
+
 	// Notify remaining participants about the update
 	for _, pid := range participantIDs {
 		if pid != userID {
@@ -580,6 +817,9 @@ func (h *MessagesHandler) DeleteMessage(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Remember thread parent so its reply count can be refreshed
+	parentID, _ := h.repo.GetMessageParentID(r.Context(), messageID)
+
 	err = h.repo.DeleteMessage(r.Context(), convID, messageID, userID)
 	if err != nil {
 		if errors.Is(err, messages.ErrNotParticipant) {
@@ -590,20 +830,31 @@ func (h *MessagesHandler) DeleteMessage(w http.ResponseWriter, r *http.Request)
 			respondError(w, http.StatusNotFound, "Message not found")
 			return
 		}
-		if err.Error() == "you can only delete your own messages" {
-			respondError(w, http.StatusForbidden, err.Error())
+		if errors.Is(err, messages.ErrPermissionDenied) {
+			respondError(w, http.StatusForbidden, "You can only delete your own messages")
 			return
 		}
 		respondError(w, http.StatusInternalServerError, "Failed to delete message")
 		return
 	}
 
-	// Notify all participants about the deleted message
+	// Notify all participants; the message stays in history as a tombstone
 	participantIDs, _ := h.repo.GetConversationParticipantIDs(r.Context(), convID)
-	h.rt.PublishToUsers(participantIDs, "MESSAGE_DELETE", &models.MessageDeleteEvent{
+	deleteEvent := &models.MessageDeleteEvent{
 		MessageID:      messageID,
 		ConversationID: convID,
-	})
+	}
+	h.rt.PublishToConversation(convID, participantIDs, "MESSAGE_DELETE", deleteEvent)
+	h.recordSync(r.Context(), convID, "MESSAGE_DELETE", deleteEvent)
+
+	if parentID != nil {
+		replyCount, _ := h.repo.GetReplyCount(r.Context(), *parentID)
+		h.rt.PublishToConversation(convID, participantIDs, "THREAD_UPDATE", &models.ThreadUpdateEvent{
+			ParentID:       *parentID,
+			ConversationID: convID,
+			ReplyCount:     replyCount,
+		})
+	}
 
 	respondJSON(w, http.StatusOK, map[string]string{"message": "Message deleted"})
 }
@@ -655,7 +906,7 @@ func (h *MessagesHandler) AddReaction(w http.ResponseWriter, r *http.Request) {
 
 	// Notify all participants about the new reaction
 	participantIDs, _ := h.repo.GetConversationParticipantIDs(r.Context(), convID)
-	h.rt.PublishToUsers(participantIDs, "REACTION_ADD", &models.ReactionAddEvent{
+	h.rt.PublishToConversation(convID, participantIDs, "REACTION_ADD", &models.ReactionAddEvent{
 		Reaction:       reaction,
 		MessageID:      messageID,
 		ConversationID: convID,
@@ -706,7 +957,7 @@ func (h *MessagesHandler) RemoveReaction(w http.ResponseWriter, r *http.Request)
 
 	// Notify all participants about the removed reaction
 	participantIDs, _ := h.repo.GetConversationParticipantIDs(r.Context(), convID)
-	h.rt.PublishToUsers(participantIDs, "REACTION_REMOVE", &models.ReactionRemoveEvent{
+	h.rt.PublishToConversation(convID, participantIDs, "REACTION_REMOVE", &models.ReactionRemoveEvent{
 		MessageID:      messageID,
 		ConversationID: convID,
 		UserID:         userID,
@@ -715,3 +966,317 @@ func (h *MessagesHandler) RemoveReaction(w http.ResponseWriter, r *http.Request)
 
 	respondJSON(w, http.StatusOK, map[string]string{"message": "Reaction removed"})
 }
+
+// GetThread returns replies in a message thread
+func (h *MessagesHandler) GetThread(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	messageID, err := uuid.Parse(r.PathValue("messageId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	limit := 50
+	offset := 0
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		}
+	}
+	if o := r.URL.Query().Get("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			offset = parsed
+		}
+	}
+
+	msgs, err := h.repo.GetThread(r.Context(), convID, messageID, userID, limit, offset)
+	if err != nil {
+		if errors.Is(err, messages.ErrNotParticipant) {
+			respondError(w, http.StatusForbidden, "Not a participant")
+			return
+		}
+		if errors.Is(err, messages.ErrMessageNotFound) {
+			respondError(w, http.StatusNotFound, "Message not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to get thread")
+		return
+	}
+
+	if msgs == nil {
+		msgs = []*models.Message{}
+	}
+
+	respondJSON(w, http.StatusOK, msgs)
+}
+
+// SendThreadReply posts a reply in a message thread
+func (h *MessagesHandler) SendThreadReply(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	parentID, err := uuid.Parse(r.PathValue("messageId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	var req models.SendMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Must have content or attachments
+	if req.Content == "" && len(req.AttachmentIDs) == 0 {
+		respondError(w, http.StatusBadRequest, "Message must have content or attachments")
+		return
+	}
+
+	// Parse attachment IDs
+	var attachmentIDs []uuid.UUID
+	for _, idStr := range req.AttachmentIDs {
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid attachment ID")
+			return
+		}
+		attachmentIDs = append(attachmentIDs, id)
+	}
+
+	msg, err := h.repo.SendThreadReply(r.Context(), convID, parentID, userID, req.Content, attachmentIDs)
+	if err != nil {
+		if respondSendError(w, err) {
+			return
+		}
+		if errors.Is(err, messages.ErrMessageNotFound) {
+			respondError(w, http.StatusNotFound, "Message not found")
+			return
+		}
+		if errors.Is(err, messages.ErrNestedThread) {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to send reply")
+		return
+	}
+
+	mentioned := h.saveMentions(r.Context(), msg)
+
+	// Broadcast to all participants via Centrifuge, except those ignoring the sender
+	replyCount, _ := h.repo.GetReplyCount(r.Context(), parentID)
+	participantIDs := h.publishFromSender(r.Context(), convID, userID, "THREAD_MESSAGE_CREATE", &models.ThreadMessageCreateEvent{
+		Message:        msg,
+		ParentID:       parentID,
+		ConversationID: convID,
+		ReplyCount:     replyCount,
+	})
+
+	h.publishMentions(msg, mentioned, participantIDs)
+	h.unfurl.Enqueue(msg)
+
+	respondJSON(w, http.StatusCreated, msg)
+}
+
+// Typing broadcasts a typing indicator to conversation participants
+func (h *MessagesHandler) Typing(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	participantIDs, err := h.repo.GetConversationParticipantIDs(r.Context(), convID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get participants")
+		return
+	}
+	if !containsID(participantIDs, userID) {
+		respondError(w, http.StatusForbidden, "Not a participant")
+		return
+	}
+
+	// Leaves out the recipient of a message request that isn't accepted yet
+	recipientIDs, err := h.repo.GetRecipientIDs(r.Context(), convID, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get participants")
+		return
+	}
+
+	h.rt.PublishTyping(convID, userID, recipientIDs)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MarkRead records a read receipt and broadcasts it to conversation participants
+func (h *MessagesHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	var req models.MarkReadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	messageID, _ := uuid.Parse(req.MessageID)
+
+	receipt, err := h.repo.MarkRead(r.Context(), convID, userID, messageID)
+	if err != nil {
+		if errors.Is(err, messages.ErrNotParticipant) {
+			respondError(w, http.StatusForbidden, "Not a participant")
+			return
+		}
+		if errors.Is(err, messages.ErrMessageNotFound) {
+			respondError(w, http.StatusNotFound, "Message not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to mark as read")
+		return
+	}
+
+	// Reading a message request doesn't tell the requester it was seen
+	if awaiting, err := h.repo.IsAwaitingRequest(r.Context(), convID, userID); err == nil && !awaiting {
+		participantIDs, _ := h.repo.GetConversationParticipantIDs(r.Context(), convID)
+		h.rt.PublishReadReceipt(convID, receipt, participantIDs)
+	}
+
+	respondJSON(w, http.StatusOK, receipt)
+}
+
+func containsID(ids []uuid.UUID, id uuid.UUID) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+func excludeID(ids []uuid.UUID, id uuid.UUID) []uuid.UUID {
+	out := make([]uuid.UUID, 0, len(ids))
+	for _, v := range ids {
+		if v != id {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// SetMessageTTL turns disappearing messages on or off for a conversation
+func (h *MessagesHandler) SetMessageTTL(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	var req models.SetMessageTTLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, "Unsupported message TTL")
+		return
+	}
+
+	var ttl *int
+	if req.MessageTTL > 0 {
+		ttl = &req.MessageTTL
+	}
+
+	err = h.repo.SetMessageTTL(r.Context(), convID, userID, ttl)
+	if err != nil {
+		if errors.Is(err, messages.ErrNotParticipant) {
+			respondError(w, http.StatusForbidden, "Not a participant")
+			return
+		}
+		if errors.Is(err, messages.ErrConversationNotFound) {
+			respondError(w, http.StatusNotFound, "Conversation not found")
+			return
+		}
+		if err.Error() == "only the group owner can change disappearing messages" {
+			respondError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to update conversation")
+		return
+	}
+
+	// Get updated conversation and notify participants
+	conv, err := h.repo.GetConversation(r.Context(), convID, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get conversation")
+		return
+	}
+
+	allParticipantIDs, _ := h.repo.GetConversationParticipantIDs(r.Context(), convID)
+	h.rt.PublishToUsers(allParticipantIDs, "CONVERSATION_UPDATE", conv)
+
+	respondJSON(w, http.StatusOK, conv)
+}
+
+// respondSendError maps the errors that keep a participant from writing to a conversation.
+// Returns false if err is none of them.
+func respondSendError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, messages.ErrNotParticipant):
+		respondError(w, http.StatusForbidden, "Not a participant")
+	case errors.Is(err, messages.ErrBlocked),
+		errors.Is(err, messages.ErrRequestNotAccepted),
+		errors.Is(err, messages.ErrRequestDeclined),
+		errors.Is(err, messages.ErrAnnouncementOnly):
+		respondError(w, http.StatusForbidden, err.Error())
+	default:
+		return false
+	}
+	return true
+}
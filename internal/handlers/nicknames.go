@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/messages"
+	"github.com/user/bla-back/internal/models"
+)
+
+// SetNickname sets a participant's nickname in the conversation (their own or someone else's)
+func (h *MessagesHandler) SetNickname(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	targetID, err := uuid.Parse(r.PathValue("userId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var req models.SetNicknameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	req.Nickname = strings.TrimSpace(req.Nickname)
+	if err := h.validator.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, "Nickname must be at most 32 characters")
+		return
+	}
+
+	err = h.repo.SetNickname(r.Context(), convID, userID, targetID, req.Nickname)
+	if err != nil {
+		switch {
+		case errors.Is(err, messages.ErrNotParticipant):
+			respondError(w, http.StatusForbidden, "Not a participant")
+		case errors.Is(err, messages.ErrParticipantNotFound):
+			respondError(w, http.StatusNotFound, "User is not a participant")
+		default:
+			respondError(w, http.StatusInternalServerError, "Failed to set nickname")
+		}
+		return
+	}
+
+	// Participants carry their nicknames, so the update is a regular conversation update
+	conv, err := h.repo.GetConversation(r.Context(), convID, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get conversation")
+		return
+	}
+
+	allParticipantIDs, _ := h.repo.GetConversationParticipantIDs(r.Context(), convID)
+	h.rt.PublishToUsers(allParticipantIDs, "CONVERSATION_UPDATE", conv)
+
+	respondJSON(w, http.StatusOK, conv)
+}
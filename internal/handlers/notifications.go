@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/models"
+	"github.com/user/bla-back/internal/notifications"
+	"github.com/user/bla-back/internal/schedule"
+)
+
+type NotificationsHandler struct {
+	repo *notifications.Repository
+}
+
+func NewNotificationsHandler(repo *notifications.Repository) *NotificationsHandler {
+	return &NotificationsHandler{repo: repo}
+}
+
+// GetSettings returns the user's notification settings
+func (h *NotificationsHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	settings, err := h.repo.GetSettings(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get notification settings")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, settings)
+}
+
+// UpdateSettings updates the user's notification settings
+func (h *NotificationsHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.UpdateNotificationSettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.EmailEnabled == nil && req.WebhookEnabled == nil && req.QuietStart == nil && req.QuietEnd == nil {
+		respondError(w, http.StatusBadRequest, "Nothing to update")
+		return
+	}
+	if (req.QuietStart == nil) != (req.QuietEnd == nil) {
+		respondError(w, http.StatusBadRequest, "quiet_start and quiet_end must be set together")
+		return
+	}
+
+	// Validate quiet hours before changing anything
+	var quietStart, quietEnd *schedule.Clock
+	if req.QuietStart != nil && (*req.QuietStart != "" || *req.QuietEnd != "") {
+		start, err := schedule.ParseClock(*req.QuietStart)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "quiet_start: "+err.Error())
+			return
+		}
+		end, err := schedule.ParseClock(*req.QuietEnd)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "quiet_end: "+err.Error())
+			return
+		}
+		quietStart, quietEnd = &start, &end
+	}
+
+	var settings *models.NotificationSettings
+	var err error
+	if req.EmailEnabled != nil {
+		settings, err = h.repo.SetEmailEnabled(r.Context(), userID, *req.EmailEnabled)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to update notification settings")
+			return
+		}
+	}
+	if req.WebhookEnabled != nil {
+		settings, err = h.repo.SetWebhookEnabled(r.Context(), userID, *req.WebhookEnabled)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to update notification settings")
+			return
+		}
+	}
+	if req.QuietStart != nil {
+		settings, err = h.repo.SetQuietHours(r.Context(), userID, quietStart, quietEnd)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to update notification settings")
+			return
+		}
+	}
+
+	respondJSON(w, http.StatusOK, settings)
+}
+
+// SetDoNotDisturb turns Do Not Disturb on (optionally until a given time) or off.
+// Calls still show up as missed and unread counts still go up while it's on.
+func (h *NotificationsHandler) SetDoNotDisturb(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.SetDoNotDisturbRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Enabled && req.Until != nil && !req.Until.After(time.Now()) {
+		respondError(w, http.StatusBadRequest, "until must be in the future")
+		return
+	}
+
+	settings, err := h.repo.SetDoNotDisturb(r.Context(), userID, req.Enabled, req.Until)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to update notification settings")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, settings)
+}
@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/auth"
+	"github.com/user/bla-back/internal/models"
+	"github.com/user/bla-back/internal/oauth"
+)
+
+// oauthCompletePath is the web app page the callback hands tokens (or an error) to
+const oauthCompletePath = "/auth/callback"
+
+// oauthStateCookie binds a started sign-in to the browser that started it, so nobody can
+// hand a victim a callback URL that signs them in to (or links) the wrong account
+const oauthStateCookie = "oauth_state"
+
+func setOAuthStateCookie(w http.ResponseWriter, state string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     "/api/auth/oauth/",
+		MaxAge:   int(oauth.StateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// checkOAuthStateCookie reports whether the callback's state is the one this browser
+// started, clearing the cookie
+func checkOAuthStateCookie(w http.ResponseWriter, r *http.Request, state string) bool {
+	cookie, err := r.Cookie(oauthStateCookie)
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Path:     "/api/auth/oauth/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return err == nil && state != "" && subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) == 1
+}
+
+// SetOAuth enables signing in with external accounts; appURL is where the callback sends the browser
+func (h *AuthHandler) SetOAuth(providers *oauth.Registry, appURL string) {
+	h.oauth = providers
+	h.appURL = strings.TrimRight(appURL, "/")
+}
+
+// OAuthProviders lists the providers users can sign in with
+func (h *AuthHandler) OAuthProviders(w http.ResponseWriter, r *http.Request) {
+	providers := []string{}
+	if h.oauth != nil {
+		providers = h.oauth.Providers()
+	}
+	respondJSON(w, http.StatusOK, models.OAuthProvidersResponse{Providers: providers})
+}
+
+// OAuthStart redirects the browser to the provider's consent page
+func (h *AuthHandler) OAuthStart(w http.ResponseWriter, r *http.Request) {
+	if h.oauth == nil {
+		respondError(w, http.StatusNotFound, "Unknown sign-in provider")
+		return
+	}
+
+	target, state, err := h.oauth.AuthURL(r.Context(), r.PathValue("provider"), nil)
+	if err != nil {
+		if errors.Is(err, oauth.ErrUnknownProvider) {
+			respondError(w, http.StatusNotFound, "Unknown sign-in provider")
+			return
+		}
+		log.Printf("Failed to start %s sign-in: %v", r.PathValue("provider"), err)
+		respondError(w, http.StatusInternalServerError, "Failed to start sign-in")
+		return
+	}
+
+	setOAuthStateCookie(w, state)
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// OAuthCallback signs the user in with the external account, creating the user if the
+// email is new. For a link request it links the account to the signed-in user instead.
+// The browser is sent back to the web app with the same token pair as Login in the URL
+// fragment, or an error code.
+func (h *AuthHandler) OAuthCallback(w http.ResponseWriter, r *http.Request) {
+	if h.oauth == nil {
+		respondError(w, http.StatusNotFound, "Unknown sign-in provider")
+		return
+	}
+	provider := r.PathValue("provider")
+
+	q := r.URL.Query()
+	if q.Get("error") != "" {
+		// The user cancelled on the consent page
+		h.completeOAuth(w, r, url.Values{"error": {"access_denied"}})
+		return
+	}
+
+	if !checkOAuthStateCookie(w, r, q.Get("state")) {
+		h.completeOAuth(w, r, url.Values{"error": {"sign_in_failed"}})
+		return
+	}
+
+	identity, linkTo, err := h.oauth.Exchange(r.Context(), provider, q.Get("state"), q.Get("code"))
+	if err != nil {
+		if !errors.Is(err, oauth.ErrInvalidState) && !errors.Is(err, oauth.ErrUnknownProvider) {
+			log.Printf("%s sign-in failed: %v", provider, err)
+		}
+		h.completeOAuth(w, r, url.Values{"error": {"sign_in_failed"}})
+		return
+	}
+
+	if linkTo != nil {
+		err := h.repo.LinkIdentity(r.Context(), *linkTo, identity.Provider, identity.Subject, identity.Email)
+		switch {
+		case errors.Is(err, auth.ErrIdentityLinked):
+			h.completeOAuth(w, r, url.Values{"error": {"identity_in_use"}})
+		case errors.Is(err, auth.ErrProviderLinked):
+			h.completeOAuth(w, r, url.Values{"error": {"provider_already_linked"}})
+		case err != nil:
+			log.Printf("Failed to link %s account for user %s: %v", provider, *linkTo, err)
+			h.completeOAuth(w, r, url.Values{"error": {"link_failed"}})
+		default:
+			h.completeOAuth(w, r, url.Values{"linked": {provider}})
+		}
+		return
+	}
+
+	if !identity.EmailVerified || identity.Email == "" {
+		h.completeOAuth(w, r, url.Values{"error": {"email_not_verified"}})
+		return
+	}
+
+	user, err := h.oauthUser(r, identity)
+	if err != nil {
+		code := "sign_in_failed"
+		switch {
+		case errors.Is(err, auth.ErrInvalidInviteCode):
+			code = "invite_required"
+		case errors.Is(err, auth.ErrProviderLinked):
+			code = "provider_already_linked"
+		case errors.Is(err, auth.ErrUserExists):
+			code = "account_exists"
+		default:
+			log.Printf("Failed to sign in %s user %s: %v", identity.Provider, identity.Subject, err)
+		}
+		h.completeOAuth(w, r, url.Values{"error": {code}})
+		return
+	}
+
+	tokens, err := h.signIn(r, user.ID)
+	if err != nil {
+		h.completeOAuth(w, r, url.Values{"error": {"sign_in_failed"}})
+		return
+	}
+
+	h.completeOAuth(w, r, url.Values{
+		"access_token":  {tokens.AccessToken},
+		"refresh_token": {tokens.RefreshToken},
+	})
+}
+
+// oauthUser finds the user for an external account: the one already linked to it, else a
+// new user. An existing account with the same email is only linked if it has no password,
+// i.e. it was created through another verified external account. Local emails aren't
+// verified, so anyone could have registered one with a password they keep using; those
+// users sign in and link the account themselves (ErrUserExists).
+func (h *AuthHandler) oauthUser(r *http.Request, identity *oauth.Identity) (*models.User, error) {
+	user, err := h.repo.GetUserByIdentity(r.Context(), identity.Provider, identity.Subject)
+	if err == nil || !errors.Is(err, auth.ErrUserNotFound) {
+		return user, err
+	}
+
+	user, err = h.repo.GetUserByEmail(r.Context(), identity.Email)
+	if err == nil {
+		if user.PasswordHash != "" {
+			return nil, auth.ErrUserExists
+		}
+		if err := h.repo.LinkIdentity(r.Context(), user.ID, identity.Provider, identity.Subject, identity.Email); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+	if !errors.Is(err, auth.ErrUserNotFound) {
+		return nil, err
+	}
+
+	// New accounts need an invite code while registration is invite-only
+	if h.inviteOnly {
+		return nil, auth.ErrInvalidInviteCode
+	}
+	return h.repo.CreateUserWithIdentity(r.Context(), identity.Email, identity.Provider, identity.Subject)
+}
+
+// completeOAuth sends the browser back to the web app. The values go in the fragment so
+// tokens stay out of server logs and Referer headers.
+func (h *AuthHandler) completeOAuth(w http.ResponseWriter, r *http.Request, values url.Values) {
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, h.appURL+oauthCompletePath+"#"+values.Encode(), http.StatusFound)
+}
+
+// ListOAuthIdentities lists the external accounts linked to the user
+func (h *AuthHandler) ListOAuthIdentities(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	identities, err := h.repo.ListIdentities(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to list linked accounts")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, identities)
+}
+
+// LinkOAuthIdentity returns the consent page URL for linking an account from the
+// provider; the client navigates there since a redirect can't carry the access token. The
+// request must be made with credentials so the browser keeps the state cookie.
+func (h *AuthHandler) LinkOAuthIdentity(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if h.oauth == nil {
+		respondError(w, http.StatusNotFound, "Unknown sign-in provider")
+		return
+	}
+
+	target, state, err := h.oauth.AuthURL(r.Context(), r.PathValue("provider"), &userID)
+	if err != nil {
+		if errors.Is(err, oauth.ErrUnknownProvider) {
+			respondError(w, http.StatusNotFound, "Unknown sign-in provider")
+			return
+		}
+		log.Printf("Failed to start %s account link: %v", r.PathValue("provider"), err)
+		respondError(w, http.StatusInternalServerError, "Failed to start linking")
+		return
+	}
+
+	setOAuthStateCookie(w, state)
+	respondJSON(w, http.StatusOK, models.OAuthLinkResponse{URL: target})
+}
+
+// UnlinkOAuthIdentity removes the user's linked account from the provider
+func (h *AuthHandler) UnlinkOAuthIdentity(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.repo.UnlinkIdentity(r.Context(), userID, r.PathValue("provider")); err != nil {
+		if errors.Is(err, auth.ErrIdentityNotFound) {
+			respondError(w, http.StatusNotFound, "No linked account from this provider")
+			return
+		}
+		if errors.Is(err, auth.ErrLastSignInMethod) {
+			respondError(w, http.StatusConflict, "Add a passkey or link another account before unlinking your only sign-in method")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to unlink account")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
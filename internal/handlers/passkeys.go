@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/models"
+	"github.com/user/bla-back/internal/passkeys"
+)
+
+// SetPasskeys enables signing in with passkeys
+func (h *AuthHandler) SetPasskeys(service *passkeys.Service, repo *passkeys.Repository) {
+	h.passkeys = service
+	h.passkeyRepo = repo
+}
+
+// passkeysAvailable responds 503 when passkeys aren't configured (they need Redis)
+func (h *AuthHandler) passkeysAvailable(w http.ResponseWriter) bool {
+	if h.passkeys == nil {
+		respondError(w, http.StatusServiceUnavailable, "Passkeys are not available")
+		return false
+	}
+	return true
+}
+
+// respondPasskeyError maps ceremony errors to responses
+func respondPasskeyError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, passkeys.ErrInvalidCredential), errors.Is(err, passkeys.ErrUnsupportedKey):
+		respondError(w, http.StatusUnauthorized, err.Error())
+	case errors.Is(err, passkeys.ErrChallengeExpired):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, passkeys.ErrPasskeyRegistered), errors.Is(err, passkeys.ErrTooManyPasskeys):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		log.Printf("Passkey %s error: %v", action, err)
+		respondError(w, http.StatusInternalServerError, "Failed to "+action)
+	}
+}
+
+// BeginPasskeyRegistration returns the options for navigator.credentials.create()
+func (h *AuthHandler) BeginPasskeyRegistration(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if !h.passkeysAvailable(w) {
+		return
+	}
+
+	user, err := h.repo.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	options, err := h.passkeys.BeginRegistration(r.Context(), user)
+	if err != nil {
+		respondPasskeyError(w, err, "start passkey registration")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"publicKey": options})
+}
+
+// FinishPasskeyRegistration verifies the new credential and adds it to the account
+func (h *AuthHandler) FinishPasskeyRegistration(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if !h.passkeysAvailable(w) {
+		return
+	}
+
+	var req models.RegisterPasskeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+		return
+	}
+
+	passkey, err := h.passkeys.FinishRegistration(r.Context(), userID, req.Name, &req.Credential)
+	if err != nil {
+		respondPasskeyError(w, err, "register passkey")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, passkey)
+}
+
+// BeginPasskeyLogin returns the options for navigator.credentials.get()
+func (h *AuthHandler) BeginPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	if !h.passkeysAvailable(w) {
+		return
+	}
+
+	options, err := h.passkeys.BeginLogin(r.Context())
+	if err != nil {
+		respondPasskeyError(w, err, "start passkey sign-in")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"publicKey": options})
+}
+
+// FinishPasskeyLogin verifies a passkey assertion and signs the user in like Login
+func (h *AuthHandler) FinishPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	if !h.passkeysAvailable(w) {
+		return
+	}
+
+	var req models.PasskeyLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	userID, err := h.passkeys.FinishLogin(r.Context(), &req.Credential)
+	if err != nil {
+		respondPasskeyError(w, err, "sign in with passkey")
+		return
+	}
+
+	user, err := h.repo.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusUnauthorized, "Invalid credentials")
+		return
+	}
+
+	tokens, err := h.signIn(r, user.ID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to generate tokens")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.AuthResponse{
+		User:         user,
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+	})
+}
+
+// ListPasskeys lists the user's passkeys
+func (h *AuthHandler) ListPasskeys(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if !h.passkeysAvailable(w) {
+		return
+	}
+
+	list, err := h.passkeyRepo.List(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to list passkeys")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, list)
+}
+
+// DeletePasskey removes one of the user's passkeys
+func (h *AuthHandler) DeletePasskey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if !h.passkeysAvailable(w) {
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid passkey ID")
+		return
+	}
+
+	if err := h.passkeyRepo.Delete(r.Context(), userID, id); err != nil {
+		if errors.Is(err, passkeys.ErrPasskeyNotFound) {
+			respondError(w, http.StatusNotFound, "Passkey not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to delete passkey")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/auth"
+	"github.com/user/bla-back/internal/messages"
+	"github.com/user/bla-back/internal/models"
+)
+
+// PermalinkHandler serves canonical message links (/m/{conversationId}/{messageId})
+type PermalinkHandler struct {
+	repo   *messages.Repository
+	tokens *auth.TokenService
+	appURL string
+}
+
+func NewPermalinkHandler(repo *messages.Repository, tokens *auth.TokenService, appURL string) *PermalinkHandler {
+	return &PermalinkHandler{
+		repo:   repo,
+		tokens: tokens,
+		appURL: strings.TrimRight(appURL, "/"),
+	}
+}
+
+var permalinkPreview = template.Must(template.New("permalink").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>Message on Bla</title>
+<meta property="og:title" content="{{if .Message}}{{.Author}} on Bla{{else}}Message on Bla{{end}}">
+{{if .Message}}<meta property="og:description" content="{{.Message.Content}}">{{end}}
+</head>
+<body>
+{{if .Message}}<p><strong>{{.Author}}</strong></p>
+<p>{{.Message.Content}}</p>{{else}}<p>Sign in to view this message.</p>{{end}}
+<p><a href="{{.AppLink}}">Open in Bla</a></p>
+</body>
+</html>
+`))
+
+// Resolve redirects signed-in participants into the app at the message. Everyone else gets a
+// minimal preview: content is shown only for rooms published through the widget API
+func (h *PermalinkHandler) Resolve(w http.ResponseWriter, r *http.Request) {
+	convID, err := uuid.Parse(r.PathValue("conversationId"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	messageID, err := uuid.Parse(r.PathValue("messageId"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	appLink := fmt.Sprintf("%s/conversations/%s?message=%s", h.appURL, convID, messageID)
+
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		claims, err := h.tokens.ValidateAccessToken(r.Context(), token)
+		if err != nil {
+			RespondUnauthorized(w, "Invalid or expired token")
+			return
+		}
+		allowed, err := h.repo.CanAccessMessage(r.Context(), convID, messageID, claims.UserID)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to resolve message")
+			return
+		}
+		// Same response for missing and forbidden so links can't be probed
+		if !allowed {
+			respondError(w, http.StatusNotFound, "Message not found")
+			return
+		}
+		http.Redirect(w, r, appLink, http.StatusFound)
+		return
+	}
+
+	data := struct {
+		Message *models.WidgetMessage
+		Author  string
+		AppLink string
+	}{AppLink: appLink}
+
+	msg, err := h.repo.GetPublicMessagePreview(r.Context(), convID, messageID)
+	if err != nil && !errors.Is(err, messages.ErrMessageNotFound) {
+		http.Error(w, "Failed to resolve message", http.StatusInternalServerError)
+		return
+	}
+	if msg != nil && msg.Type == "text" {
+		data.Message = msg
+		data.Author = "Someone"
+		if msg.AuthorName != nil {
+			data.Author = *msg.AuthorName
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	permalinkPreview.Execute(w, data)
+}
+
+// GetMessageContext returns a page of history around a message, for opening permalinks,
+// search results and pins. ?limit= sets both sides; ?before= and ?after= override one.
+func (h *MessagesHandler) GetMessageContext(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	messageID, err := uuid.Parse(r.PathValue("messageId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	before := 25
+	after := 25
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed >= 0 && parsed <= 100 {
+			before, after = parsed, parsed
+		}
+	}
+	if b := r.URL.Query().Get("before"); b != "" {
+		if parsed, err := strconv.Atoi(b); err == nil && parsed >= 0 && parsed <= 100 {
+			before = parsed
+		}
+	}
+	if a := r.URL.Query().Get("after"); a != "" {
+		if parsed, err := strconv.Atoi(a); err == nil && parsed >= 0 && parsed <= 100 {
+			after = parsed
+		}
+	}
+
+	result, err := h.repo.GetMessageContext(r.Context(), convID, messageID, userID, before, after)
+	if err != nil {
+		if errors.Is(err, messages.ErrNotParticipant) {
+			respondError(w, http.StatusForbidden, "Not a participant")
+			return
+		}
+		if errors.Is(err, messages.ErrMessageNotFound) {
+			respondError(w, http.StatusNotFound, "Message not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to get message context")
+		return
+	}
+
+	if result.Messages == nil {
+		result.Messages = []*models.Message{}
+	}
+
+	respondJSON(w, http.StatusOK, result)
+}
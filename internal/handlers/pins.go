@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/messages"
+	"github.com/user/bla-back/internal/models"
+)
+
+// PinConversation pins a conversation to the top of the current user's list
+func (h *MessagesHandler) PinConversation(w http.ResponseWriter, r *http.Request) {
+	h.setPinned(w, r, true)
+}
+
+// UnpinConversation removes a pin
+func (h *MessagesHandler) UnpinConversation(w http.ResponseWriter, r *http.Request) {
+	h.setPinned(w, r, false)
+}
+
+func (h *MessagesHandler) setPinned(w http.ResponseWriter, r *http.Request, pinned bool) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	if err := h.repo.SetPinned(r.Context(), convID, userID, pinned); err != nil {
+		if errors.Is(err, messages.ErrNotParticipant) {
+			respondError(w, http.StatusForbidden, "Not a participant")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to update conversation")
+		return
+	}
+
+	// Sync the user's other devices
+	event := &models.ConversationPinEvent{ConversationID: convID, Pinned: pinned}
+	h.rt.PublishToUser(userID, "CONVERSATION_PIN_UPDATE", event)
+
+	respondJSON(w, http.StatusOK, event)
+}
+
+// ReorderConversations sets the current user's custom conversation order
+func (h *MessagesHandler) ReorderConversations(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.ReorderConversationsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation IDs")
+		return
+	}
+
+	convIDs := make([]uuid.UUID, 0, len(req.ConversationIDs))
+	for _, idStr := range req.ConversationIDs {
+		convIDs = append(convIDs, uuid.MustParse(idStr))
+	}
+
+	if err := h.repo.SetConversationOrder(r.Context(), userID, convIDs); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to reorder conversations")
+		return
+	}
+
+	// Sync the user's other devices
+	event := &models.ConversationOrderEvent{ConversationIDs: convIDs}
+	h.rt.PublishToUser(userID, "CONVERSATION_ORDER_UPDATE", event)
+
+	respondJSON(w, http.StatusOK, event)
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/messages"
+	"github.com/user/bla-back/internal/models"
+)
+
+// CreatePoll sends a poll message
+func (h *MessagesHandler) CreatePoll(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	var req models.CreatePollRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, "Poll needs a question and 2-10 options")
+		return
+	}
+
+	msg, err := h.repo.CreatePoll(r.Context(), convID, userID, req.Question, req.Options, req.MultipleChoice)
+	if err != nil {
+		if respondSendError(w, err) {
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to create poll")
+		return
+	}
+
+	// Broadcast to all participants via Centrifuge, except those ignoring the sender
+	h.publishFromSender(r.Context(), convID, userID, "MESSAGE_CREATE", &models.MessageCreateEvent{
+		Message:        msg,
+		ConversationID: convID,
+	})
+	h.announceMessageRequest(r.Context(), msg)
+
+	respondJSON(w, http.StatusCreated, msg)
+}
+
+// VotePoll votes for a poll option
+func (h *MessagesHandler) VotePoll(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	messageID, err := uuid.Parse(r.PathValue("messageId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	var req models.PollVoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, "Option ID is required")
+		return
+	}
+
+	poll, err := h.repo.Vote(r.Context(), convID, messageID, userID, uuid.MustParse(req.OptionID))
+	if err != nil {
+		respondPollError(w, err, "Failed to vote")
+		return
+	}
+
+	h.publishPoll(r, "POLL_VOTE", convID, poll)
+	respondJSON(w, http.StatusOK, poll)
+}
+
+// UnvotePoll removes a vote from a poll option
+func (h *MessagesHandler) UnvotePoll(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	messageID, err := uuid.Parse(r.PathValue("messageId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	optionID, err := uuid.Parse(r.PathValue("optionId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid option ID")
+		return
+	}
+
+	poll, err := h.repo.Unvote(r.Context(), convID, messageID, userID, optionID)
+	if err != nil {
+		respondPollError(w, err, "Failed to remove vote")
+		return
+	}
+
+	h.publishPoll(r, "POLL_VOTE", convID, poll)
+	respondJSON(w, http.StatusOK, poll)
+}
+
+// ClosePoll stops a poll from accepting votes (author only)
+func (h *MessagesHandler) ClosePoll(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	messageID, err := uuid.Parse(r.PathValue("messageId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	poll, err := h.repo.ClosePoll(r.Context(), convID, messageID, userID)
+	if err != nil {
+		respondPollError(w, err, "Failed to close poll")
+		return
+	}
+
+	h.publishPoll(r, "POLL_CLOSE", convID, poll)
+	respondJSON(w, http.StatusOK, poll)
+}
+
+// publishPoll sends updated poll results to all participants
+func (h *MessagesHandler) publishPoll(r *http.Request, eventType string, convID uuid.UUID, poll *models.Poll) {
+	participantIDs, _ := h.repo.GetConversationParticipantIDs(r.Context(), convID)
+	h.rt.PublishToConversation(convID, participantIDs, eventType, &models.PollEvent{
+		Poll:           poll,
+		MessageID:      poll.MessageID,
+		ConversationID: convID,
+	})
+}
+
+func respondPollError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, messages.ErrNotParticipant):
+		respondError(w, http.StatusForbidden, "Not a participant")
+	case errors.Is(err, messages.ErrNotPollAuthor):
+		respondError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, messages.ErrPollNotFound):
+		respondError(w, http.StatusNotFound, "Poll not found")
+	case errors.Is(err, messages.ErrPollClosed), errors.Is(err, messages.ErrInvalidPollOption):
+		respondError(w, http.StatusBadRequest, err.Error())
+	default:
+		respondError(w, http.StatusInternalServerError, fallback)
+	}
+}
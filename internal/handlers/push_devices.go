@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/models"
+	"github.com/user/bla-back/internal/push"
+)
+
+// Push tokens are 64 hex characters on APNs and around 160 characters on FCM
+const maxPushTokenLength = 4096
+
+type PushHandler struct {
+	repo *push.Repository
+}
+
+func NewPushHandler(repo *push.Repository) *PushHandler {
+	return &PushHandler{repo: repo}
+}
+
+// RegisterDevice registers a device's push token so it's woken for incoming calls
+func (h *PushHandler) RegisterDevice(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.RegisterPushDeviceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if !push.ValidPlatform(req.Platform) {
+		respondError(w, http.StatusBadRequest, "platform must be apns_voip or fcm")
+		return
+	}
+	if req.Token == "" || len(req.Token) > maxPushTokenLength {
+		respondError(w, http.StatusBadRequest, "Invalid token")
+		return
+	}
+
+	device, err := h.repo.RegisterDevice(r.Context(), userID, req.Platform, req.Token)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to register device")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, device)
+}
+
+// UnregisterDevice stops pushes to a device, e.g. when the user logs out on it
+func (h *PushHandler) UnregisterDevice(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.repo.UnregisterDevice(r.Context(), userID, r.PathValue("token")); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to unregister device")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
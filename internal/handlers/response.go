@@ -22,3 +22,11 @@ func respondError(w http.ResponseWriter, status int, message string) {
 func RespondUnauthorized(w http.ResponseWriter, message string) {
 	respondError(w, http.StatusUnauthorized, message)
 }
+
+func RespondForbidden(w http.ResponseWriter, message string) {
+	respondError(w, http.StatusForbidden, message)
+}
+
+func RespondBadRequest(w http.ResponseWriter, message string) {
+	respondError(w, http.StatusBadRequest, message)
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/calls"
+	"github.com/user/bla-back/internal/models"
+	"github.com/user/bla-back/internal/realtime"
+	"github.com/user/bla-back/internal/schedule"
+)
+
+const maxScheduleAhead = 365 * 24 * time.Hour
+
+type ScheduledCallsHandler struct {
+	callsRepo *calls.Repository
+	notifier  *realtime.Notifier
+	convRepo  ConversationRepository
+	zones     TimeZoneProvider
+	validator *validator.Validate
+	appURL    string
+}
+
+// TimeZoneProvider resolves the zone a user's local times are in
+type TimeZoneProvider interface {
+	GetTimeZone(ctx context.Context, userID uuid.UUID) (zone string, explicit bool, err error)
+}
+
+func NewScheduledCallsHandler(callsRepo *calls.Repository, notifier *realtime.Notifier, convRepo ConversationRepository, zones TimeZoneProvider, appURL string) *ScheduledCallsHandler {
+	return &ScheduledCallsHandler{
+		callsRepo: callsRepo,
+		notifier:  notifier,
+		convRepo:  convRepo,
+		zones:     zones,
+		validator: validator.New(),
+		appURL:    appURL,
+	}
+}
+
+// ScheduleCall plans a call in a conversation
+func (h *ScheduledCallsHandler) ScheduleCall(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	conversationID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid conversation_id", http.StatusBadRequest)
+		return
+	}
+
+	var req models.ScheduleCallRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		http.Error(w, "Invalid scheduled call", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	if req.LocalTime != "" {
+		at, err := schedule.ParseClock(req.LocalTime)
+		if err != nil {
+			http.Error(w, "local_time must be HH:MM", http.StatusBadRequest)
+			return
+		}
+		zone, _, err := h.zones.GetTimeZone(r.Context(), userID)
+		if err != nil {
+			log.Printf("GetTimeZone error: %v", err)
+			http.Error(w, "Failed to schedule call", http.StatusInternalServerError)
+			return
+		}
+		req.StartsAt = schedule.NextAt(now, schedule.Zone(zone), at)
+	}
+	if !req.StartsAt.After(now) || req.StartsAt.After(now.Add(maxScheduleAhead)) {
+		http.Error(w, "starts_at must be in the future (within a year)", http.StatusBadRequest)
+		return
+	}
+
+	sc, err := h.callsRepo.ScheduleCall(r.Context(), conversationID, userID, req.Title, req.StartsAt)
+	if err != nil {
+		if errors.Is(err, calls.ErrNotConversationParticipant) {
+			http.Error(w, "Not a participant", http.StatusForbidden)
+			return
+		}
+		log.Printf("ScheduleCall error: %v", err)
+		http.Error(w, "Failed to schedule call", http.StatusInternalServerError)
+		return
+	}
+	sc.JoinURL = calls.ScheduledCallJoinURL(h.appURL, sc)
+
+	participantIDs, _ := h.convRepo.GetParticipantIDs(r.Context(), conversationID)
+	h.notifier.NotifyUsers(participantIDs, "SCHEDULED_CALL_CREATE", sc)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(sc)
+}
+
+// GetScheduledCalls lists upcoming calls in a conversation
+func (h *ScheduledCallsHandler) GetScheduledCalls(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	conversationID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid conversation_id", http.StatusBadRequest)
+		return
+	}
+
+	scheduled, err := h.callsRepo.GetScheduledCalls(r.Context(), conversationID, userID)
+	if err != nil {
+		if errors.Is(err, calls.ErrNotConversationParticipant) {
+			http.Error(w, "Not a participant", http.StatusForbidden)
+			return
+		}
+		log.Printf("GetScheduledCalls error: %v", err)
+		http.Error(w, "Failed to get scheduled calls", http.StatusInternalServerError)
+		return
+	}
+	for _, sc := range scheduled {
+		sc.JoinURL = calls.ScheduledCallJoinURL(h.appURL, sc)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(scheduled)
+}
+
+// CancelScheduledCall cancels a call that hasn't started yet
+func (h *ScheduledCallsHandler) CancelScheduledCall(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid scheduled call id", http.StatusBadRequest)
+		return
+	}
+
+	sc, err := h.callsRepo.CancelScheduledCall(r.Context(), id, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, calls.ErrScheduledCallNotFound):
+			http.Error(w, "Scheduled call not found", http.StatusNotFound)
+		case errors.Is(err, calls.ErrCannotCancelScheduledCall):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			log.Printf("CancelScheduledCall error: %v", err)
+			http.Error(w, "Failed to cancel scheduled call", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	participantIDs, _ := h.convRepo.GetParticipantIDs(r.Context(), sc.ConversationID)
+	h.notifier.NotifyUsers(participantIDs, "SCHEDULED_CALL_DELETE", &models.ScheduledCallDeleteEvent{
+		ID:             sc.ID,
+		ConversationID: sc.ConversationID,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
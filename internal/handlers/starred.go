@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/messages"
+	"github.com/user/bla-back/internal/models"
+)
+
+// StarMessage bookmarks a message for the current user
+func (h *MessagesHandler) StarMessage(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	messageID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	if err := h.repo.StarMessage(r.Context(), messageID, userID); err != nil {
+		if errors.Is(err, messages.ErrMessageNotFound) {
+			respondError(w, http.StatusNotFound, "Message not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to star message")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Message starred"})
+}
+
+// UnstarMessage removes a bookmark
+func (h *MessagesHandler) UnstarMessage(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	messageID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	if err := h.repo.UnstarMessage(r.Context(), messageID, userID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to unstar message")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Message unstarred"})
+}
+
+// GetStarredMessages lists the current user's bookmarks across conversations
+func (h *MessagesHandler) GetStarredMessages(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	limit := 50
+	offset := 0
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		}
+	}
+	if o := r.URL.Query().Get("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			offset = parsed
+		}
+	}
+
+	starred, err := h.repo.GetStarredMessages(r.Context(), userID, limit, offset)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get starred messages")
+		return
+	}
+
+	if starred == nil {
+		starred = []*models.StarredMessage{}
+	}
+
+	respondJSON(w, http.StatusOK, starred)
+}
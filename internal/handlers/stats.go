@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/stats"
+)
+
+type StatsHandler struct {
+	repo *stats.Repository
+}
+
+func NewStatsHandler(repo *stats.Repository) *StatsHandler {
+	return &StatsHandler{repo: repo}
+}
+
+// GetAccountStats returns the user's activity for a calendar year (?year=2025),
+// or for the last 365 days by default
+func (h *StatsHandler) GetAccountStats(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	from := to.AddDate(0, 0, -364)
+	if y := r.URL.Query().Get("year"); y != "" {
+		year, err := strconv.Atoi(y)
+		if err != nil || year < 2000 || year > to.Year() {
+			respondError(w, http.StatusBadRequest, "Invalid year")
+			return
+		}
+		from = time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		to = time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC)
+	}
+
+	result, err := h.repo.GetUserStats(r.Context(), userID, from, to)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get stats")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, result)
+}
+
+// GetConversationStats returns a group's activity over the last ?days= days (default 30, max 365).
+// Restricted to the group owner.
+func (h *StatsHandler) GetConversationStats(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	days := 30
+	if d := r.URL.Query().Get("days"); d != "" {
+		days, err = strconv.Atoi(d)
+		if err != nil || days < 1 || days > 365 {
+			respondError(w, http.StatusBadRequest, "days must be between 1 and 365")
+			return
+		}
+	}
+
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	from := to.AddDate(0, 0, -(days - 1))
+
+	result, err := h.repo.GetConversationStats(r.Context(), convID, userID, from, to)
+	if err != nil {
+		if errors.Is(err, stats.ErrConversationNotFound) {
+			respondError(w, http.StatusNotFound, "Group not found")
+			return
+		}
+		if errors.Is(err, stats.ErrNotGroupOwner) {
+			respondError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to get stats")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, result)
+}
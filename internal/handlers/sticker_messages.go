@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/messages"
+	"github.com/user/bla-back/internal/models"
+)
+
+// sendSticker handles SendMessage requests carrying a sticker_id
+func (h *MessagesHandler) sendSticker(w http.ResponseWriter, r *http.Request, convID, userID uuid.UUID, req models.SendMessageRequest) {
+	if req.Content != "" || len(req.AttachmentIDs) > 0 {
+		respondError(w, http.StatusBadRequest, "Sticker messages can't have content or attachments")
+		return
+	}
+
+	stickerID, err := uuid.Parse(*req.StickerID)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid sticker ID")
+		return
+	}
+
+	msg, created, err := h.repo.SendSticker(r.Context(), convID, userID, stickerID, req.Nonce)
+	if err != nil {
+		if respondSendError(w, err) {
+			return
+		}
+		if errors.Is(err, messages.ErrNonceReused) {
+			respondError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if errors.Is(err, messages.ErrStickerUnavailable) {
+			respondError(w, http.StatusNotFound, "Sticker not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to send message")
+		return
+	}
+
+	if !created {
+		respondJSON(w, http.StatusOK, msg)
+		return
+	}
+
+	// Broadcast to all participants via Centrifuge, except those ignoring the sender
+	h.publishFromSender(r.Context(), convID, userID, "MESSAGE_CREATE", &models.MessageCreateEvent{
+		Message:        msg,
+		ConversationID: convID,
+	})
+	h.announceMessageRequest(r.Context(), msg)
+
+	respondJSON(w, http.StatusCreated, msg)
+}
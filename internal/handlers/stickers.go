@@ -6,31 +6,68 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/auth"
 	"github.com/user/bla-back/internal/cache"
 	"github.com/user/bla-back/internal/models"
+	"github.com/user/bla-back/internal/outbound"
 	"github.com/user/bla-back/internal/stickers"
 	"github.com/user/bla-back/internal/storage"
 )
 
 type StickersHandler struct {
 	repo      *stickers.Repository
+	authRepo  *auth.Repository
 	storage   *storage.S3Storage
 	cache     *cache.RedisCache
 	validator *validator.Validate
+	adultAge  int
+	fetcher   *http.Client // sticker proxy downloads
 }
 
-func NewStickersHandler(repo *stickers.Repository, storage *storage.S3Storage, cache *cache.RedisCache) *StickersHandler {
+func NewStickersHandler(repo *stickers.Repository, authRepo *auth.Repository, storage *storage.S3Storage, cache *cache.RedisCache, adultAge int, policy *outbound.Policy) *StickersHandler {
 	return &StickersHandler{
 		repo:      repo,
+		authRepo:  authRepo,
 		storage:   storage,
 		cache:     cache,
 		validator: validator.New(),
+		adultAge:  adultAge,
+		fetcher:   policy.Client(outbound.Limits{Timeout: 15 * time.Second, MaxBodyBytes: 10 << 20, MaxRedirects: 3}),
 	}
 }
 
+// canViewNSFW reports whether the user is old enough for NSFW sticker packs
+func (h *StickersHandler) canViewNSFW(r *http.Request, userID uuid.UUID) bool {
+	adult, err := h.authRepo.IsAdult(r.Context(), userID, h.adultAge)
+	return err == nil && adult
+}
+
+// filterNSFW drops NSFW packs for users who may not see them
+func (h *StickersHandler) filterNSFW(r *http.Request, userID uuid.UUID, packs []*models.StickerPack) []*models.StickerPack {
+	hasNSFW := false
+	for _, pack := range packs {
+		if pack.NSFW {
+			hasNSFW = true
+			break
+		}
+	}
+	if !hasNSFW || h.canViewNSFW(r, userID) {
+		return packs
+	}
+
+	filtered := packs[:0]
+	for _, pack := range packs {
+		if !pack.NSFW {
+			filtered = append(filtered, pack)
+		}
+	}
+	return filtered
+}
+
 // GetPacks returns all sticker packs available to user
 func (h *StickersHandler) GetPacks(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value("userID").(uuid.UUID)
@@ -61,6 +98,7 @@ func (h *StickersHandler) GetPacks(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	packs = h.filterNSFW(r, userID, packs)
 	if packs == nil {
 		packs = []*models.StickerPack{}
 	}
@@ -70,6 +108,12 @@ func (h *StickersHandler) GetPacks(w http.ResponseWriter, r *http.Request) {
 
 // GetPack returns a specific sticker pack with all stickers
 func (h *StickersHandler) GetPack(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
 	packID, err := uuid.Parse(r.PathValue("id"))
 	if err != nil {
 		respondError(w, http.StatusBadRequest, "Invalid pack ID")
@@ -86,6 +130,12 @@ func (h *StickersHandler) GetPack(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Hide NSFW packs from underage accounts as if they don't exist
+	if pack.NSFW && !h.canViewNSFW(r, userID) {
+		respondError(w, http.StatusNotFound, "Pack not found")
+		return
+	}
+
 	respondJSON(w, http.StatusOK, pack)
 }
 
@@ -108,7 +158,12 @@ func (h *StickersHandler) CreatePack(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pack, err := h.repo.CreatePack(r.Context(), userID, req.Name, req.Description, false)
+	if req.NSFW && !h.canViewNSFW(r, userID) {
+		respondError(w, http.StatusForbidden, "NSFW packs are restricted to adult accounts")
+		return
+	}
+
+	pack, err := h.repo.CreatePack(r.Context(), userID, req.Name, req.Description, false, req.NSFW)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to create pack")
 		return
@@ -234,6 +289,20 @@ func (h *StickersHandler) AddPackToCollection(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	pack, err := h.repo.GetPack(r.Context(), packID)
+	if err != nil {
+		if errors.Is(err, stickers.ErrPackNotFound) {
+			respondError(w, http.StatusNotFound, "Pack not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to get pack")
+		return
+	}
+	if pack.NSFW && !h.canViewNSFW(r, userID) {
+		respondError(w, http.StatusNotFound, "Pack not found")
+		return
+	}
+
 	err = h.repo.AddPackToUser(r.Context(), userID, packID)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to add pack")
@@ -330,12 +399,21 @@ func (h *StickersHandler) ProxySticker(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Fetch from S3
-	resp, err := http.Get(sticker.FileURL)
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, sticker.FileURL, nil)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch sticker")
+		return
+	}
+	resp, err := h.fetcher.Do(req)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to fetch sticker")
 		return
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respondError(w, http.StatusBadGateway, "Failed to fetch sticker")
+		return
+	}
 
 	// Set content type based on file type
 	switch sticker.FileType {
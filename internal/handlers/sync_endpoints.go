@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/messages"
+	"github.com/user/bla-back/internal/models"
+	"github.com/user/bla-back/internal/syncfeed"
+)
+
+// recordSync adds an event to the conversation's sync feed. The realtime event has
+// already gone out, so a failure is only logged.
+func (h *MessagesHandler) recordSync(ctx context.Context, convID uuid.UUID, eventType string, data interface{}) {
+	if err := h.sync.Record(ctx, convID, eventType, data); err != nil {
+		log.Printf("Failed to record %s for conversation %s sync: %v", eventType, convID, err)
+	}
+}
+
+// recordMembership records participants joining or leaving in the sync feed, as a
+// system message in the timeline and, when someone else made the change, in the audit log
+func (h *MessagesHandler) recordMembership(ctx context.Context, convID uuid.UUID, eventType string, userIDs []uuid.UUID, actorID *uuid.UUID, reason string) {
+	h.recordSync(ctx, convID, eventType, &models.SyncMembershipEvent{
+		ConversationID: convID,
+		UserIDs:        userIDs,
+		ActorID:        actorID,
+		Reason:         reason,
+	})
+	h.postMembershipMessage(ctx, convID, membershipAction(eventType, reason), userIDs, actorID)
+	h.auditMembership(ctx, convID, eventType, userIDs, actorID, reason)
+}
+
+// authorizeSync checks that the caller may manage the conversation's sync endpoints
+func (h *MessagesHandler) authorizeSync(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	if err := h.repo.CheckPermission(r.Context(), convID, userID, messages.PermManageSync); err != nil {
+		switch {
+		case errors.Is(err, messages.ErrNotParticipant):
+			respondError(w, http.StatusForbidden, "Not a participant")
+		case errors.Is(err, messages.ErrPermissionDenied):
+			respondError(w, http.StatusForbidden, "Only group owners and admins can manage sync endpoints")
+		default:
+			respondError(w, http.StatusInternalServerError, "Failed to check permissions")
+		}
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, convID, true
+}
+
+// GetSyncEndpoints lists the conversation's sync endpoints with their delivery state
+func (h *MessagesHandler) GetSyncEndpoints(w http.ResponseWriter, r *http.Request) {
+	_, convID, ok := h.authorizeSync(w, r)
+	if !ok {
+		return
+	}
+
+	endpoints, err := h.sync.ListEndpoints(r.Context(), convID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get sync endpoints")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, endpoints)
+}
+
+// CreateSyncEndpoint registers an endpoint that receives the conversation's events from
+// now on. The response carries the signing secret, which isn't shown again.
+func (h *MessagesHandler) CreateSyncEndpoint(w http.ResponseWriter, r *http.Request) {
+	userID, convID, ok := h.authorizeSync(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.CreateSyncEndpointRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, "A valid endpoint URL is required")
+		return
+	}
+
+	endpoint, err := h.sync.CreateEndpoint(r.Context(), convID, userID, req.URL)
+	if err != nil {
+		if errors.Is(err, syncfeed.ErrTooManyEndpoints) {
+			respondError(w, http.StatusConflict, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to create sync endpoint")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, endpoint)
+}
+
+// DeleteSyncEndpoint stops syncing the conversation to an endpoint
+func (h *MessagesHandler) DeleteSyncEndpoint(w http.ResponseWriter, r *http.Request) {
+	_, convID, ok := h.authorizeSync(w, r)
+	if !ok {
+		return
+	}
+
+	endpointID, err := uuid.Parse(r.PathValue("endpointId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid endpoint ID")
+		return
+	}
+
+	if err := h.sync.DeleteEndpoint(r.Context(), convID, endpointID); err != nil {
+		if errors.Is(err, syncfeed.ErrEndpointNotFound) {
+			respondError(w, http.StatusNotFound, "Sync endpoint not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to delete sync endpoint")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Sync endpoint deleted"})
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/models"
+)
+
+// membershipAction maps a sync membership event and its reason to the action shown
+// in the timeline
+func membershipAction(eventType, reason string) string {
+	if eventType == "MEMBERS_REMOVE" {
+		if reason == "left" {
+			return "left"
+		}
+		return "removed"
+	}
+	if reason == "added" {
+		return "added"
+	}
+	return "joined"
+}
+
+// postMembershipMessage adds a line like "Alice added Bob" to the group's timeline.
+// Users who joined on their own are the sender; otherwise the actor is.
+func (h *MessagesHandler) postMembershipMessage(ctx context.Context, convID uuid.UUID, action string, userIDs []uuid.UUID, actorID *uuid.UUID) {
+	if len(userIDs) == 0 {
+		return
+	}
+	senderID := userIDs[0]
+	if actorID != nil {
+		senderID = *actorID
+	}
+
+	content, err := json.Marshal(&models.MembershipMessageContent{
+		Action:  action,
+		UserIDs: userIDs,
+		ActorID: actorID,
+	})
+	if err != nil {
+		log.Printf("Failed to marshal membership message: %v", err)
+		return
+	}
+
+	msg, err := h.repo.CreateMembershipMessage(ctx, convID, senderID, string(content))
+	if err != nil {
+		log.Printf("Failed to create membership message in conversation %s: %v", convID, err)
+		return
+	}
+	h.publishSystemMessage(ctx, convID, msg)
+}
+
+// postGroupUpdateMessage adds a rename or avatar change to the group's timeline
+func (h *MessagesHandler) postGroupUpdateMessage(ctx context.Context, convID uuid.UUID, update *models.GroupUpdateMessageContent) {
+	content, err := json.Marshal(update)
+	if err != nil {
+		log.Printf("Failed to marshal group update message: %v", err)
+		return
+	}
+
+	msg, err := h.repo.CreateGroupUpdateMessage(ctx, convID, update.ActorID, string(content))
+	if err != nil {
+		log.Printf("Failed to create group update message in conversation %s: %v", convID, err)
+		return
+	}
+	h.publishSystemMessage(ctx, convID, msg)
+}
+
+// publishSystemMessage sends a server-generated message to every participant, the actor included
+func (h *MessagesHandler) publishSystemMessage(ctx context.Context, convID uuid.UUID, msg *models.Message) {
+	participantIDs, err := h.repo.GetConversationParticipantIDs(ctx, convID)
+	if err != nil {
+		log.Printf("Failed to get participants of conversation %s: %v", convID, err)
+		return
+	}
+
+	event := &models.MessageCreateEvent{
+		Message:        msg,
+		ConversationID: convID,
+	}
+	h.rt.PublishToConversation(convID, participantIDs, "MESSAGE_CREATE", event)
+	h.recordSync(ctx, convID, "MESSAGE_CREATE", event)
+}
@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/user/bla-back/internal/messages"
+)
+
+// GetSystemSchemas lists the content schemas of system message types, every version,
+// so clients can tell which types and versions they can render
+func (h *MessagesHandler) GetSystemSchemas(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"version_key": messages.SchemaVersionKey,
+		"schemas":     messages.SystemSchemas(),
+	})
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/messages"
+	"github.com/user/bla-back/internal/models"
+	"github.com/user/bla-back/internal/translate"
+)
+
+// translationTimeout bounds how long translated copies of a message can lag behind
+const translationTimeout = 15 * time.Second
+
+// GetTranslation returns the user's auto-translate setting for a conversation
+func (h *MessagesHandler) GetTranslation(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	settings, err := h.repo.GetTranslationSettings(r.Context(), convID, userID)
+	if err != nil {
+		if errors.Is(err, messages.ErrNotParticipant) {
+			respondError(w, http.StatusForbidden, "Not a participant")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to get translation settings")
+		return
+	}
+	settings.Available = h.translator.Enabled()
+
+	respondJSON(w, http.StatusOK, settings)
+}
+
+// SetTranslation turns auto-translation of incoming messages in a conversation on or off
+func (h *MessagesHandler) SetTranslation(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	var req models.SetTranslationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	var target *string
+	if req.Enabled {
+		if !h.translator.Enabled() {
+			respondError(w, http.StatusServiceUnavailable, "Translation is not available")
+			return
+		}
+		if !translate.ValidLanguage(req.TargetLanguage) {
+			respondError(w, http.StatusBadRequest, "target_language must be an ISO 639-1 code")
+			return
+		}
+		target = &req.TargetLanguage
+	}
+
+	if err := h.repo.SetAutoTranslate(r.Context(), convID, userID, target); err != nil {
+		if errors.Is(err, messages.ErrNotParticipant) {
+			respondError(w, http.StatusForbidden, "Not a participant")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to update translation settings")
+		return
+	}
+
+	settings, err := h.repo.GetTranslationSettings(r.Context(), convID, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get translation settings")
+		return
+	}
+	settings.Available = h.translator.Enabled()
+
+	respondJSON(w, http.StatusOK, settings)
+}
+
+// publishMessageCreate publishes MESSAGE_CREATE like publishFromSender, except that
+// recipients auto-translating the conversation get their copy with a translation once
+// the provider returns it, so they're addressed through their user channels.
+func (h *MessagesHandler) publishMessageCreate(ctx context.Context, msg *models.Message) []uuid.UUID {
+	event := &models.MessageCreateEvent{
+		Message:        msg,
+		ConversationID: msg.ConversationID,
+	}
+
+	var targets map[uuid.UUID]string
+	if h.translator.Enabled() && msg.Content != "" {
+		var err error
+		targets, err = h.repo.GetAutoTranslateTargets(ctx, msg.ConversationID)
+		if err != nil {
+			log.Printf("Failed to get auto-translate targets for conversation %s: %v", msg.ConversationID, err)
+		}
+		delete(targets, msg.SenderID)
+		for userID, lang := range targets {
+			if msg.Language != nil && *msg.Language == lang {
+				delete(targets, userID)
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return h.publishFromSender(ctx, msg.ConversationID, msg.SenderID, "MESSAGE_CREATE", event)
+	}
+
+	recipientIDs, _, err := h.repo.GetRecipients(ctx, msg.ConversationID, msg.SenderID)
+	if err != nil {
+		log.Printf("Failed to get recipients for conversation %s: %v", msg.ConversationID, err)
+		return nil
+	}
+
+	var plain []uuid.UUID
+	byLanguage := map[string][]uuid.UUID{}
+	for _, id := range recipientIDs {
+		if lang, ok := targets[id]; ok {
+			byLanguage[lang] = append(byLanguage[lang], id)
+		} else {
+			plain = append(plain, id)
+		}
+	}
+	h.rt.PublishToUsers(plain, "MESSAGE_CREATE", event)
+	h.recordSync(ctx, msg.ConversationID, "MESSAGE_CREATE", event)
+	go h.publishTranslated(msg, byLanguage)
+
+	return recipientIDs
+}
+
+// publishTranslated sends MESSAGE_CREATE with a translation to each group of recipients.
+// If translation fails they still get the message, untranslated.
+func (h *MessagesHandler) publishTranslated(msg *models.Message, byLanguage map[string][]uuid.UUID) {
+	ctx, cancel := context.WithTimeout(context.Background(), translationTimeout)
+	defer cancel()
+
+	for lang, userIDs := range byLanguage {
+		event := &models.MessageCreateEvent{
+			Message:        msg,
+			ConversationID: msg.ConversationID,
+		}
+		content, err := h.translator.Translate(ctx, msg.Content, lang)
+		if err != nil {
+			log.Printf("Failed to translate message %s to %s: %v", msg.ID, lang, err)
+		} else {
+			event.Translation = &models.MessageTranslation{Language: lang, Content: content}
+		}
+		h.rt.PublishToUsers(userIDs, "MESSAGE_CREATE", event)
+	}
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/calls"
+)
+
+// SetTURN configures the TURN servers handed out by GetTURNCredentials
+func (h *CallsHandler) SetTURN(cfg calls.TURNConfig) {
+	h.turn = cfg
+}
+
+// GetTURNCredentials returns short-lived TURN credentials so clients behind strict
+// NATs can still reach the voice server
+func (h *CallsHandler) GetTURNCredentials(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if !h.turn.Enabled() {
+		http.Error(w, "TURN is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(h.turn.Credentials(userID, time.Now()))
+}
@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/user/bla-back/internal/apiversion"
+)
+
+// Versions lists the supported API versions, the one the request negotiated and the
+// breaking changes of each version
+func Versions(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"oldest":  apiversion.Oldest,
+		"latest":  apiversion.Latest,
+		"current": apiversion.FromContext(r.Context()),
+		"changes": apiversion.Changes(),
+	})
+}
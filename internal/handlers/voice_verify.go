@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/user/bla-back/internal/calls"
+)
+
+type verifyVoiceTokenRequest struct {
+	Token  string `json:"token"`
+	RoomID string `json:"room_id"`
+}
+
+// VerifyVoiceToken lets the SFU redeem a participant token before admitting the connection.
+// The SFU authenticates with an admin control token signed with the shared voice secret.
+func (h *CallsHandler) VerifyVoiceToken(w http.ResponseWriter, r *http.Request) {
+	if err := h.sfu.VerifyControl(r.Header.Get("Authorization")); err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req verifyVoiceTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" || req.RoomID == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := h.sfu.VerifyToken(r.Context(), req.Token, req.RoomID)
+	if err != nil {
+		switch {
+		case errors.Is(err, calls.ErrInvalidVoiceToken):
+			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+		case errors.Is(err, calls.ErrVoiceTokenRoom), errors.Is(err, calls.ErrNotInCall):
+			http.Error(w, "Token not valid for this room", http.StatusForbidden)
+		case errors.Is(err, calls.ErrCallNotFound):
+			http.Error(w, "Call not found", http.StatusNotFound)
+		case errors.Is(err, calls.ErrVoiceTokenReplayed):
+			http.Error(w, "Token already used", http.StatusConflict)
+		default:
+			log.Printf("VerifyVoiceToken error: %v", err)
+			http.Error(w, "Failed to verify token", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(claims)
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/calls"
+	"github.com/user/bla-back/internal/models"
+)
+
+// SetWebhookSync makes voice backend webhooks the source of truth for who is in a call.
+// Leaving through the API then no longer ends a call; it ends when the room empties.
+func (h *CallsHandler) SetWebhookSync(enabled bool) {
+	h.webhookSync = enabled
+}
+
+// SetReconnectGrace keeps participants whose connection drops in their call for grace
+// before they count as having left. Zero makes drops leave immediately.
+func (h *CallsHandler) SetReconnectGrace(grace time.Duration) {
+	h.reconnectGrace = grace
+}
+
+// VoiceWebhook receives room events from a voice backend ({backend} is "sfu" or "livekit"),
+// so participants who drop off without leaving through the API don't linger in calls
+func (h *CallsHandler) VoiceWebhook(w http.ResponseWriter, r *http.Request) {
+	backend, ok := h.voice.Lookup(r.PathValue("backend"))
+	if !ok {
+		http.Error(w, "Unknown voice backend", http.StatusNotFound)
+		return
+	}
+	h.receiveVoiceWebhook(w, r, backend)
+}
+
+// SFUWebhook receives HMAC-signed participant and room events from the custom SFU
+func (h *CallsHandler) SFUWebhook(w http.ResponseWriter, r *http.Request) {
+	h.receiveVoiceWebhook(w, r, h.sfu)
+}
+
+func (h *CallsHandler) receiveVoiceWebhook(w http.ResponseWriter, r *http.Request, backend calls.VoiceBackend) {
+	event, err := backend.ReceiveWebhook(r)
+	if err != nil {
+		log.Printf("Rejected %s webhook: %v", backend.Name(), err)
+		http.Error(w, "Invalid webhook", http.StatusUnauthorized)
+		return
+	}
+
+	// Only call rooms are managed here
+	roomID, isCall := strings.CutPrefix(event.RoomName, "call-")
+	callID, err := uuid.Parse(roomID)
+	if !isCall || err != nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	call, err := h.callsRepo.GetCallWithParticipants(r.Context(), callID)
+	if err != nil || call.EndedAt != nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	switch event.Type {
+	case calls.VoiceEventParticipantJoined:
+		if userID, sessionID, err := calls.ParseIdentity(event.UserID); err == nil {
+			h.syncJoin(r.Context(), call, userID, sessionID, backend)
+		}
+
+	case calls.VoiceEventParticipantLeft:
+		if userID, sessionID, err := calls.ParseIdentity(event.UserID); err == nil {
+			h.syncLeave(r.Context(), call, userID, sessionID, backend.Name())
+		}
+
+	case calls.VoiceEventSpeakingChanged:
+		userID, _, err := calls.ParseIdentity(event.UserID)
+		inCall := slices.ContainsFunc(call.Participants, func(p calls.Participant) bool { return p.UserID == userID })
+		if err == nil && inCall {
+			// Speaking state is only interesting live, so it skips the offline queue
+			h.notifier.NotifyConversation(call.ConversationID, nil, "CALL_SPEAKING", &models.CallSpeakingEvent{
+				CallID:         call.ID,
+				ConversationID: call.ConversationID,
+				UserID:         userID,
+				Speaking:       event.Speaking,
+			})
+		}
+
+	case calls.VoiceEventRoomFinished:
+		h.endCall(r.Context(), callID)
+		h.broadcastCallState(r.Context(), call.ConversationID)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// syncJoin records a participant session the voice server saw connect, e.g. after
+// rejoining a dropped connection without going through the API. Sessions that couldn't
+// have joined through the API (see JoinCall) are removed from the room instead.
+func (h *CallsHandler) syncJoin(ctx context.Context, call *calls.Call, userID uuid.UUID, sessionID string, backend calls.VoiceBackend) {
+	inCall, err := h.callsRepo.IsActiveSession(ctx, call.ID, userID, sessionID)
+	if err != nil {
+		return
+	}
+	if inCall {
+		// Back within the reconnection grace period
+		if back, err := h.callsRepo.ClearReconnecting(ctx, call.ID, userID, sessionID); err != nil {
+			log.Printf("ClearReconnecting error: %v", err)
+		} else if back {
+			h.broadcastCallState(ctx, call.ConversationID)
+		}
+		return
+	}
+
+	// Whatever the token said, the same rules as joining through the API apply
+	if err := h.convRepo.CheckJoinCall(ctx, call.ConversationID, userID); err != nil {
+		log.Printf("Refusing %s join of %s to call %s: %v", backend.Name(), userID, call.ID, err)
+		h.removeFromRoom(ctx, call, userID, sessionID, backend)
+		return
+	}
+
+	if err := h.callsRepo.JoinCall(ctx, call.ID, userID, sessionID); err != nil {
+		log.Printf("Failed to join call %s after %s webhook: %v", call.ID, backend.Name(), err)
+		if errors.Is(err, calls.ErrCallFull) {
+			h.removeFromRoom(ctx, call, userID, sessionID, backend)
+		}
+		return
+	}
+	h.broadcastCallState(ctx, call.ConversationID)
+	h.settleRing(ctx, call, userID, calls.RingAnswered)
+}
+
+// removeFromRoom disconnects a session the call doesn't admit from its media room
+func (h *CallsHandler) removeFromRoom(ctx context.Context, call *calls.Call, userID uuid.UUID, sessionID string, backend calls.VoiceBackend) {
+	if err := backend.RemoveParticipant(ctx, "call-"+call.ID.String(), calls.SessionIdentity(userID, sessionID)); err != nil {
+		log.Printf("RemoveParticipant error: %v", err)
+	}
+}
+
+// syncLeave records a participant session the voice server saw disconnect and ends the
+// call once nobody is left. With a reconnection grace period it's only marked as
+// reconnecting, and HandleDrop finishes the leave if it doesn't come back in time.
+func (h *CallsHandler) syncLeave(ctx context.Context, call *calls.Call, userID uuid.UUID, sessionID, backend string) {
+	// Already gone if they left through the API
+	inCall, err := h.callsRepo.IsActiveSession(ctx, call.ID, userID, sessionID)
+	if err != nil {
+		return
+	}
+	if inCall && h.reconnectGrace > 0 {
+		if marked, err := h.callsRepo.MarkReconnecting(ctx, call.ID, userID, sessionID); err != nil {
+			log.Printf("MarkReconnecting error: %v", err)
+		} else if marked {
+			h.broadcastCallState(ctx, call.ConversationID)
+		}
+		return
+	}
+	if inCall {
+		if err := h.leaveCall(ctx, call, userID, &sessionID, true); err != nil {
+			log.Printf("Failed to leave call %s after %s webhook: %v", call.ID, backend, err)
+		}
+		return
+	}
+
+	// They left through the API first; with webhook sync that didn't end the call
+	count, err := h.callsRepo.GetActiveParticipantCount(ctx, call.ID)
+	if err == nil && count == 0 {
+		h.endCall(ctx, call.ID)
+		h.broadcastCallState(ctx, call.ConversationID)
+	}
+}
+
+// HandleDrop finishes the leave of a participant session that didn't reconnect in time,
+// ending the call if nobody is left
+func (h *CallsHandler) HandleDrop(ctx context.Context, drop *calls.Drop) {
+	h.revokeIfGone(ctx, drop.CallID, drop.UserID)
+
+	count, err := h.callsRepo.GetActiveParticipantCount(ctx, drop.CallID)
+	if err == nil && count == 0 {
+		h.endCall(ctx, drop.CallID)
+	}
+	h.broadcastCallState(ctx, drop.ConversationID)
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/calls"
+	"github.com/user/bla-back/internal/models"
+)
+
+// Voicemails are short: 2MB is about two minutes of Opus/AAC
+const maxVoicemailSize = 2 << 20
+
+// LeaveVoicemail uploads a voice message for the callee of an unanswered DM call
+func (h *CallsHandler) LeaveVoicemail(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	callID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid call_id", http.StatusBadRequest)
+		return
+	}
+
+	conversationID, err := h.callsRepo.VoicemailConversation(r.Context(), callID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, calls.ErrCallNotFound):
+			http.Error(w, "Call not found", http.StatusNotFound)
+		case errors.Is(err, calls.ErrVoicemailNotAllowed):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			log.Printf("VoicemailConversation error: %v", err)
+			http.Error(w, "Failed to check call", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxVoicemailSize+(64<<10))
+	if err := r.ParseMultipartForm(maxVoicemailSize); err != nil {
+		http.Error(w, "Voicemail too large (max 2MB)", http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "No file provided", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	contentType := header.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "audio/") {
+		http.Error(w, "Voicemail must be an audio file", http.StatusBadRequest)
+		return
+	}
+
+	fileURL, err := h.storage.Upload(r.Context(), "voicemail/"+userID.String(), header.Filename, contentType, file)
+	if err != nil {
+		log.Printf("Voicemail upload error: %v", err)
+		http.Error(w, "Failed to upload voicemail", http.StatusInternalServerError)
+		return
+	}
+
+	attachment, err := h.msgRepo.CreateAttachment(r.Context(), userID, "voice", fileURL, header.Filename, header.Size)
+	if err != nil {
+		http.Error(w, "Failed to create attachment", http.StatusInternalServerError)
+		return
+	}
+
+	msg, err := h.msgRepo.SendMessageWithAttachments(r.Context(), conversationID, userID, "", []uuid.UUID{attachment.ID})
+	if err != nil {
+		log.Printf("Voicemail message error: %v", err)
+		http.Error(w, "Failed to send voicemail", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.callsRepo.SetVoicemailMessage(r.Context(), callID, msg.ID); err != nil {
+		log.Printf("SetVoicemailMessage error: %v", err)
+	}
+
+	participantIDs, _ := h.convRepo.GetParticipantIDs(r.Context(), conversationID)
+	h.notifier.NotifyConversation(conversationID, participantIDs, "MESSAGE_CREATE", &models.MessageCreateEvent{
+		Message:        msg,
+		ConversationID: conversationID,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(msg)
+}
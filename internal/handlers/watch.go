@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/messages"
+	"github.com/user/bla-back/internal/models"
+)
+
+// StartWatchSession starts a watch-together session for a media URL
+func (h *MessagesHandler) StartWatchSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	var req models.StartWatchSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid media URL")
+		return
+	}
+
+	session, err := h.repo.StartWatchSession(r.Context(), convID, userID, req.MediaURL)
+	if err != nil {
+		h.respondWatchError(w, err)
+		return
+	}
+
+	participantIDs, _ := h.repo.GetConversationParticipantIDs(r.Context(), convID)
+	h.rt.PublishToConversation(convID, participantIDs, "WATCH_SESSION_START", session)
+
+	respondJSON(w, http.StatusCreated, session)
+}
+
+// GetWatchSession returns the current playback state (for late joiners)
+func (h *MessagesHandler) GetWatchSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	session, err := h.repo.GetWatchSession(r.Context(), convID, userID)
+	if err != nil {
+		h.respondWatchError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, session)
+}
+
+// ControlWatchSession plays, pauses or seeks and relays the new state with the server timestamp
+func (h *MessagesHandler) ControlWatchSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	var req models.WatchControlRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil || (req.Action == "seek" && req.PositionMs == nil) {
+		respondError(w, http.StatusBadRequest, "Action must be play, pause or seek (seek needs position_ms)")
+		return
+	}
+
+	session, err := h.repo.ControlWatchSession(r.Context(), convID, userID, req.Action, req.PositionMs)
+	if err != nil {
+		h.respondWatchError(w, err)
+		return
+	}
+
+	participantIDs, _ := h.repo.GetConversationParticipantIDs(r.Context(), convID)
+	h.rt.PublishToConversation(convID, participantIDs, "WATCH_SESSION_UPDATE", session)
+
+	respondJSON(w, http.StatusOK, session)
+}
+
+// EndWatchSession stops the session for everyone
+func (h *MessagesHandler) EndWatchSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	if err := h.repo.EndWatchSession(r.Context(), convID, userID); err != nil {
+		h.respondWatchError(w, err)
+		return
+	}
+
+	participantIDs, _ := h.repo.GetConversationParticipantIDs(r.Context(), convID)
+	h.rt.PublishToConversation(convID, participantIDs, "WATCH_SESSION_END", &models.WatchSessionEndEvent{
+		ConversationID: convID,
+		UserID:         userID,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *MessagesHandler) respondWatchError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, messages.ErrNotParticipant):
+		respondError(w, http.StatusForbidden, "Not a participant")
+	case errors.Is(err, messages.ErrNoWatchSession):
+		respondError(w, http.StatusNotFound, "No watch session")
+	default:
+		respondError(w, http.StatusInternalServerError, "Failed to update watch session")
+	}
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/models"
+	"github.com/user/bla-back/internal/widget"
+)
+
+type WidgetHandler struct {
+	repo    *widget.Repository
+	service *widget.Service
+}
+
+func NewWidgetHandler(repo *widget.Repository, service *widget.Service) *WidgetHandler {
+	return &WidgetHandler{
+		repo:    repo,
+		service: service,
+	}
+}
+
+// CreateKey publishes a group to the widget API (owner only)
+func (h *WidgetHandler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	key, err := h.repo.CreateKey(r.Context(), convID, userID)
+	if err != nil {
+		if errors.Is(err, widget.ErrNotOwner) {
+			respondError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to create widget key")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, key)
+}
+
+// ListKeys returns active widget keys of a group (owner only)
+func (h *WidgetHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	keys, err := h.repo.ListKeys(r.Context(), convID, userID)
+	if err != nil {
+		if errors.Is(err, widget.ErrNotOwner) {
+			respondError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to get widget keys")
+		return
+	}
+
+	if keys == nil {
+		keys = []*models.WidgetKey{}
+	}
+
+	respondJSON(w, http.StatusOK, keys)
+}
+
+// RevokeKey disables a widget key (owner only)
+func (h *WidgetHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(uuid.UUID)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	convID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	err = h.repo.RevokeKey(r.Context(), convID, userID, r.PathValue("key"))
+	if err != nil {
+		if errors.Is(err, widget.ErrNotOwner) {
+			respondError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		if errors.Is(err, widget.ErrInvalidKey) {
+			respondError(w, http.StatusNotFound, "Widget key not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to revoke widget key")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Widget key revoked"})
+}
+
+// Messages is the public feed of recent messages for a widget key
+func (h *WidgetHandler) Messages(w http.ResponseWriter, r *http.Request) {
+	body, err := h.service.Feed(r.Context(), r.PathValue("key"))
+	h.respondPublic(w, body, err)
+}
+
+// Room is the public room info (name, participant and online counts) for a widget key
+func (h *WidgetHandler) Room(w http.ResponseWriter, r *http.Request) {
+	body, err := h.service.Room(r.Context(), r.PathValue("key"))
+	h.respondPublic(w, body, err)
+}
+
+// respondPublic writes a cacheable response that any site may embed
+func (h *WidgetHandler) respondPublic(w http.ResponseWriter, body []byte, err error) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if err != nil {
+		if errors.Is(err, widget.ErrInvalidKey) {
+			respondError(w, http.StatusNotFound, "Widget not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Failed to load widget")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(widget.CacheTTL.Seconds())))
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
@@ -0,0 +1,69 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// Readiness tracks whether the dependencies the server needs are available
+type Readiness struct {
+	mu   sync.RWMutex
+	deps map[string]bool
+}
+
+// NewReadiness creates a tracker where every named dependency starts as not ready
+func NewReadiness(deps ...string) *Readiness {
+	r := &Readiness{deps: make(map[string]bool)}
+	for _, name := range deps {
+		r.deps[name] = false
+	}
+	return r
+}
+
+func (r *Readiness) SetReady(name string, ready bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deps[name] = ready
+}
+
+// Ready reports whether all dependencies are ready
+func (r *Readiness) Ready() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, ready := range r.deps {
+		if !ready {
+			return false
+		}
+	}
+	return true
+}
+
+// Handler serves 200 when ready and 503 otherwise, with per-dependency status
+func (r *Readiness) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		r.mu.RLock()
+		deps := make(map[string]bool, len(r.deps))
+		for name, ready := range r.deps {
+			deps[name] = ready
+		}
+		r.mu.RUnlock()
+
+		status, code := "ok", http.StatusOK
+		if !r.Ready() {
+			status, code = "unavailable", http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":       status,
+			"dependencies": deps,
+		})
+	})
+}
+
+// Live always responds 200 while the process is serving requests
+func Live(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
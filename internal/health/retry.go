@@ -0,0 +1,40 @@
+package health
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Backoff configures retries with exponentially growing delays
+type Backoff struct {
+	Initial  time.Duration
+	Max      time.Duration
+	Attempts int // 0 = retry until ctx is cancelled
+}
+
+// Retry calls fn until it succeeds, attempts run out or ctx is cancelled, returning the last error
+func Retry(ctx context.Context, name string, b Backoff, fn func(ctx context.Context) error) error {
+	delay := b.Initial
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		if b.Attempts > 0 && attempt >= b.Attempts {
+			return err
+		}
+
+		log.Printf("%s not available (attempt %d): %v, retrying in %s", name, attempt, err, delay)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > b.Max {
+			delay = b.Max
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package mediacheck
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/user/bla-back/internal/models"
+)
+
+// source is a table column holding URLs of files we stored in the bucket
+type source struct {
+	table  string
+	column string
+	where  string
+}
+
+// Kinds are checked in this order
+var kinds = []string{"attachment", "user_avatar", "group_avatar", "sticker", "sticker_pack_cover"}
+
+var sources = map[string]source{
+	"attachment":         {table: "attachments", column: "url", where: "type != 'gif'"}, // GIFs live on the provider's CDN
+	"user_avatar":        {table: "users", column: "avatar_url", where: "avatar_url IS NOT NULL"},
+	"group_avatar":       {table: "conversations", column: "avatar_url", where: "avatar_url IS NOT NULL"},
+	"sticker":            {table: "stickers", column: "file_url", where: "TRUE"},
+	"sticker_pack_cover": {table: "sticker_packs", column: "cover_url", where: "cover_url IS NOT NULL"},
+}
+
+// record is one stored URL; url is empty when a flagged record no longer has a file
+type record struct {
+	kind string
+	id   uuid.UUID
+	url  string
+}
+
+const reportLimit = 500
+
+type Repository struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{db: db}
+}
+
+// sample picks up to limit records of a kind. IDs are random v4 UUIDs, so reading on from
+// a random UUID (wrapping around to the start) gives a cheap sample that walks the primary
+// key index. The start is picked here: uuid_generate_v4() in the query would be evaluated
+// once per row and force a full scan.
+func (r *Repository) sample(ctx context.Context, kind string, limit int) ([]record, error) {
+	start := uuid.New()
+	records, err := r.sampleRange(ctx, kind, `id > $2`, start, limit)
+	if err != nil || len(records) == limit {
+		return records, err
+	}
+	rest, err := r.sampleRange(ctx, kind, `id <= $2`, start, limit-len(records))
+	return append(records, rest...), err
+}
+
+func (r *Repository) sampleRange(ctx context.Context, kind, idCond string, start uuid.UUID, limit int) ([]record, error) {
+	src := sources[kind]
+	rows, err := r.db.Query(ctx, `
+		SELECT id, `+src.column+` FROM `+src.table+`
+		WHERE `+idCond+` AND `+src.where+`
+		ORDER BY id
+		LIMIT $1
+	`, limit, start)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []record
+	for rows.Next() {
+		rec := record{kind: kind}
+		if err := rows.Scan(&rec.id, &rec.url); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+// flagged returns the current URLs of records of a kind flagged as broken, so every
+// run re-checks them and notices repairs
+func (r *Repository) flagged(ctx context.Context, kind string) ([]record, error) {
+	src := sources[kind]
+	rows, err := r.db.Query(ctx, `
+		SELECT b.record_id, COALESCE(t.`+src.column+`, '')
+		FROM broken_media b
+		LEFT JOIN `+src.table+` t ON t.id = b.record_id
+		WHERE b.kind = $1
+	`, kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []record
+	for rows.Next() {
+		rec := record{kind: kind}
+		if err := rows.Scan(&rec.id, &rec.url); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+// flag records (or re-confirms) a broken URL
+func (r *Repository) flag(ctx context.Context, rec record) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO broken_media (kind, record_id, url)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (kind, record_id) DO UPDATE SET url = EXCLUDED.url, last_checked_at = NOW()
+	`, rec.kind, rec.id, rec.url)
+	return err
+}
+
+// clear drops the flag of a record that resolves again or is gone
+func (r *Repository) clear(ctx context.Context, rec record) error {
+	_, err := r.db.Exec(ctx, `
+		DELETE FROM broken_media WHERE kind = $1 AND record_id = $2
+	`, rec.kind, rec.id)
+	return err
+}
+
+func (r *Repository) saveRun(ctx context.Context, run *models.MediaCheckRun) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO media_check_runs (started_at, finished_at, checked, broken, repaired)
+		VALUES ($1, $2, $3, $4, $5)
+	`, run.StartedAt, run.FinishedAt, run.Checked, run.Broken, run.Repaired)
+	return err
+}
+
+// GetRepairReport returns the last run and the currently broken records, oldest first
+func (r *Repository) GetRepairReport(ctx context.Context) (*models.MediaRepairReport, error) {
+	report := &models.MediaRepairReport{
+		ByKind: map[string]int{},
+		Broken: []models.BrokenMedia{},
+	}
+
+	run := &models.MediaCheckRun{}
+	err := r.db.QueryRow(ctx, `
+		SELECT started_at, finished_at, checked, broken, repaired
+		FROM media_check_runs
+		ORDER BY finished_at DESC
+		LIMIT 1
+	`).Scan(&run.StartedAt, &run.FinishedAt, &run.Checked, &run.Broken, &run.Repaired)
+	if err == nil {
+		report.LastRun = run
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, `SELECT kind, COUNT(*) FROM broken_media GROUP BY kind`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var kind string
+		var count int
+		if err := rows.Scan(&kind, &count); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		report.ByKind[kind] = count
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = r.db.Query(ctx, `
+		SELECT kind, record_id, url, first_seen_at, last_checked_at
+		FROM broken_media
+		ORDER BY first_seen_at
+		LIMIT $1
+	`, reportLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var b models.BrokenMedia
+		if err := rows.Scan(&b.Kind, &b.RecordID, &b.URL, &b.FirstSeenAt, &b.LastCheckedAt); err != nil {
+			return nil, err
+		}
+		report.Broken = append(report.Broken, b)
+	}
+	return report, rows.Err()
+}
@@ -0,0 +1,129 @@
+package mediacheck
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/user/bla-back/internal/models"
+)
+
+// checkerLock keeps a single instance checking at a time
+const checkerLock = 7342003
+
+// Storage resolves stored URLs (implemented by storage.S3Storage)
+type Storage interface {
+	Owns(fileURL string) bool
+	Exists(ctx context.Context, fileURL string) (bool, error)
+}
+
+// Checker periodically verifies that a sample of stored media URLs still resolve in the
+// bucket and flags the ones that don't, e.g. after a bucket migration
+type Checker struct {
+	db       *pgxpool.Pool
+	repo     *Repository
+	storage  Storage
+	sample   int
+	interval time.Duration
+}
+
+func NewChecker(db *pgxpool.Pool, repo *Repository, storage Storage, sample int, interval time.Duration) *Checker {
+	return &Checker{
+		db:       db,
+		repo:     repo,
+		storage:  storage,
+		sample:   sample,
+		interval: interval,
+	}
+}
+
+// Run checks every interval until ctx is cancelled
+func (c *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.check(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Failed to check stored media: %v", err)
+			}
+		}
+	}
+}
+
+func (c *Checker) check(ctx context.Context) error {
+	conn, err := c.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	var locked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, checkerLock).Scan(&locked); err != nil {
+		return err
+	}
+	if !locked {
+		return nil
+	}
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, checkerLock)
+
+	run := &models.MediaCheckRun{StartedAt: time.Now()}
+	for _, kind := range kinds {
+		// Previously flagged records first, so repairs are noticed
+		flagged, err := c.repo.flagged(ctx, kind)
+		if err != nil {
+			return err
+		}
+		for _, rec := range flagged {
+			ok := rec.url == "" || !c.storage.Owns(rec.url)
+			if !ok {
+				run.Checked++
+				// An unreachable bucket is an error, not a broken link; stop instead of flagging
+				if ok, err = c.storage.Exists(ctx, rec.url); err != nil {
+					return err
+				}
+			}
+			if ok {
+				run.Repaired++
+				err = c.repo.clear(ctx, rec)
+			} else {
+				run.Broken++
+				err = c.repo.flag(ctx, rec)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		sampled, err := c.repo.sample(ctx, kind, c.sample)
+		if err != nil {
+			return err
+		}
+		for _, rec := range sampled {
+			if !c.storage.Owns(rec.url) {
+				continue
+			}
+			run.Checked++
+			ok, err := c.storage.Exists(ctx, rec.url)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				run.Broken++
+				if err := c.repo.flag(ctx, rec); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	run.FinishedAt = time.Now()
+	if run.Broken > 0 {
+		log.Printf("Media check: %d of %d stored files missing from the bucket", run.Broken, run.Checked)
+	}
+	return c.repo.saveRun(ctx, run)
+}
@@ -0,0 +1,30 @@
+package messages
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+var ErrAnnouncementOnly = errors.New("only owners and admins can post in this announcement group")
+
+// checkAnnouncementOnly keeps participants without PermPostAnnouncements from writing
+// in a group that is in announcement mode
+func (r *Repository) checkAnnouncementOnly(ctx context.Context, convID, senderID uuid.UUID) error {
+	var announcementOnly bool
+	var role string
+	err := r.db.QueryRow(ctx, `
+		SELECT c.announcement_only, cp.role
+		FROM conversations c
+		JOIN conversation_participants cp ON cp.conversation_id = c.id AND cp.user_id = $2
+		WHERE c.id = $1
+	`, convID, senderID).Scan(&announcementOnly, &role)
+	if err != nil {
+		return err
+	}
+	if announcementOnly && !RoleCan(role, PermPostAnnouncements) {
+		return ErrAnnouncementOnly
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package messages
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/models"
+)
+
+// Audit log actions
+const (
+	AuditMemberAdd     = "member_add"
+	AuditMemberRemove  = "member_remove"
+	AuditMemberBan     = "member_ban"
+	AuditMemberUnban   = "member_unban"
+	AuditRoleChange    = "role_change"
+	AuditGroupRename   = "group_rename"
+	AuditGroupAvatar   = "group_avatar"
+	AuditGroupSettings = "group_settings"
+)
+
+// RecordAudit adds an administrative action to the group's audit log. targetID is the
+// user acted on, if any; details is stored as JSON.
+func (r *Repository) RecordAudit(ctx context.Context, convID, actorID uuid.UUID, action string, targetID *uuid.UUID, details interface{}) error {
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	encoded, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(ctx, `
+		INSERT INTO conversation_audit (conversation_id, actor_id, action, target_id, details)
+		VALUES ($1, $2, $3, $4, $5)
+	`, convID, actorID, action, targetID, encoded)
+	return err
+}
+
+// GetAuditLog lists a group's audit entries created before the cursor, newest first.
+// An empty action matches every action.
+func (r *Repository) GetAuditLog(ctx context.Context, convID, userID uuid.UUID, action string, before time.Time, limit int) ([]*models.AuditEntry, error) {
+	if err := r.CheckPermission(ctx, convID, userID, PermViewAudit); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT a.id, a.conversation_id, a.action, a.actor_id, actor.username, actor.avatar_url,
+			   a.target_id, target.username, target.avatar_url, a.details, a.created_at
+		FROM conversation_audit a
+		LEFT JOIN users actor ON actor.id = a.actor_id
+		LEFT JOIN users target ON target.id = a.target_id
+		WHERE a.conversation_id = $1 AND a.created_at < $2 AND ($3 = '' OR a.action = $3)
+		ORDER BY a.created_at DESC
+		LIMIT $4
+	`, convID, before, action, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*models.AuditEntry{}
+	for rows.Next() {
+		e := &models.AuditEntry{}
+		var actorName, targetName *string
+		var actorAvatar, targetAvatar *string
+		err := rows.Scan(&e.ID, &e.ConversationID, &e.Action, &e.ActorID, &actorName, &actorAvatar,
+			&e.TargetID, &targetName, &targetAvatar, &e.Details, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		e.Actor = auditUser(e.ActorID, actorName, actorAvatar)
+		e.Target = auditUser(e.TargetID, targetName, targetAvatar)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// auditUser builds the public part of a user joined into an audit entry
+func auditUser(id *uuid.UUID, username, avatarURL *string) *models.User {
+	if id == nil || username == nil {
+		return nil
+	}
+	return &models.User{ID: *id, Username: username, AvatarURL: avatarURL}
+}
@@ -0,0 +1,153 @@
+package messages
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/user/bla-back/internal/models"
+)
+
+var (
+	ErrUserBanned  = errors.New("user is banned from this group")
+	ErrRemoveSelf  = errors.New("leave the group instead of removing yourself")
+	ErrBanNotFound = errors.New("user is not banned from this group")
+)
+
+// checkBans returns ErrUserBanned if any of the users is banned from the conversation
+func (r *Repository) checkBans(ctx context.Context, convID uuid.UUID, userIDs []uuid.UUID) error {
+	var banned bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM conversation_bans WHERE conversation_id = $1 AND user_id = ANY($2))
+	`, convID, userIDs).Scan(&banned)
+	if err != nil {
+		return err
+	}
+	if banned {
+		return ErrUserBanned
+	}
+	return nil
+}
+
+// RemoveParticipant removes someone ranked below the actor from a group, optionally
+// banning them so they can't be added back or rejoin
+func (r *Repository) RemoveParticipant(ctx context.Context, convID, actorID, targetID uuid.UUID, ban bool) error {
+	var convType string
+	err := r.db.QueryRow(ctx, `SELECT type FROM conversations WHERE id = $1 AND deleted_at IS NULL`, convID).Scan(&convType)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrConversationNotFound
+		}
+		return err
+	}
+	if convType != "group" {
+		return ErrConversationNotFound
+	}
+
+	actorRole, err := r.participantRole(ctx, convID, actorID)
+	if err != nil {
+		return err
+	}
+	if !RoleCan(actorRole, PermRemoveMembers) {
+		return ErrPermissionDenied
+	}
+	if targetID == actorID {
+		return ErrRemoveSelf
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var targetRole string
+	err = tx.QueryRow(ctx, `
+		SELECT role FROM conversation_participants WHERE conversation_id = $1 AND user_id = $2 FOR UPDATE
+	`, convID, targetID).Scan(&targetRole)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrParticipantNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if roleRank[targetRole] >= roleRank[actorRole] {
+		return ErrPermissionDenied
+	}
+
+	_, err = tx.Exec(ctx, `
+		DELETE FROM conversation_participants WHERE conversation_id = $1 AND user_id = $2
+	`, convID, targetID)
+	if err != nil {
+		return err
+	}
+
+	if ban {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO conversation_bans (conversation_id, user_id, banned_by) VALUES ($1, $2, $3)
+			ON CONFLICT (conversation_id, user_id) DO UPDATE SET banned_by = $3, created_at = NOW()
+		`, convID, targetID, actorID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			DELETE FROM group_invites WHERE conversation_id = $1 AND user_id = $2
+		`, convID, targetID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// GetBans lists a group's bans, newest first
+func (r *Repository) GetBans(ctx context.Context, convID, userID uuid.UUID) ([]*models.ConversationBan, error) {
+	if err := r.CheckPermission(ctx, convID, userID, PermManageBans); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT u.id, u.email, u.username, u.avatar_url, u.status, u.created_at, u.updated_at,
+			   b.banned_by, b.created_at
+		FROM conversation_bans b
+		JOIN users u ON u.id = b.user_id
+		WHERE b.conversation_id = $1
+		ORDER BY b.created_at DESC
+	`, convID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bans := []*models.ConversationBan{}
+	for rows.Next() {
+		ban := &models.ConversationBan{User: &models.User{}}
+		err := rows.Scan(&ban.User.ID, &ban.User.Email, &ban.User.Username, &ban.User.AvatarURL, &ban.User.Status,
+			&ban.User.CreatedAt, &ban.User.UpdatedAt, &ban.BannedBy, &ban.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		bans = append(bans, ban)
+	}
+	return bans, rows.Err()
+}
+
+// Unban lifts a ban; the user still has to be added or invited again
+func (r *Repository) Unban(ctx context.Context, convID, actorID, targetID uuid.UUID) error {
+	if err := r.CheckPermission(ctx, convID, actorID, PermManageBans); err != nil {
+		return err
+	}
+
+	result, err := r.db.Exec(ctx, `
+		DELETE FROM conversation_bans WHERE conversation_id = $1 AND user_id = $2
+	`, convID, targetID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrBanNotFound
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package messages
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// ErrBlocked is returned when one side of a DM has blocked the other
+var ErrBlocked = errors.New("cannot message this user")
+
+// blockedPair matches a block between $1 and $2 in either direction
+const blockedPair = `SELECT 1 FROM blocks WHERE (blocker_id = $1 AND blocked_id = $2) OR (blocker_id = $2 AND blocked_id = $1)`
+
+// CheckDMBlock is checkDMBlock for other packages, e.g. so a blocked user can't call
+// into a DM they still share
+func (r *Repository) CheckDMBlock(ctx context.Context, convID, userID uuid.UUID) error {
+	return r.checkDMBlock(ctx, convID, userID)
+}
+
+// checkDMBlock returns ErrBlocked if convID is a DM whose other participant blocked the
+// sender or was blocked by them. Groups aren't affected.
+func (r *Repository) checkDMBlock(ctx context.Context, convID, senderID uuid.UUID) error {
+	var blocked bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM conversations c
+			JOIN conversation_participants cp ON cp.conversation_id = c.id AND cp.user_id != $2
+			JOIN blocks b ON (b.blocker_id = $2 AND b.blocked_id = cp.user_id) OR (b.blocker_id = cp.user_id AND b.blocked_id = $2)
+			WHERE c.id = $1 AND c.type = 'dm'
+		)
+	`, convID, senderID).Scan(&blocked)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return ErrBlocked
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package messages
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Group settings for who can start calls
+const (
+	CallPermissionEveryone = "everyone"
+	CallPermissionAdmins   = "admins"
+)
+
+var ErrCallsAdminsOnly = errors.New("only owners and admins can start calls in this group")
+
+// ValidCallPermission reports whether a group's call permission can be set to p
+func ValidCallPermission(p string) bool {
+	return p == CallPermissionEveryone || p == CallPermissionAdmins
+}
+
+// CheckStartCall returns ErrNotParticipant or ErrPermissionDenied unless the user's role
+// allows starting calls, and ErrCallsAdminsOnly if the group limits calls to roles with
+// PermStartAdminCalls
+func (r *Repository) CheckStartCall(ctx context.Context, convID, userID uuid.UUID) error {
+	var callPermission, role string
+	err := r.db.QueryRow(ctx, `
+		SELECT c.call_permission, cp.role
+		FROM conversations c
+		JOIN conversation_participants cp ON cp.conversation_id = c.id AND cp.user_id = $2
+		WHERE c.id = $1 AND c.deleted_at IS NULL
+	`, convID, userID).Scan(&callPermission, &role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotParticipant
+	}
+	if err != nil {
+		return err
+	}
+	if !RoleCan(role, PermStartCalls) {
+		return ErrPermissionDenied
+	}
+	if callPermission == CallPermissionAdmins && !RoleCan(role, PermStartAdminCalls) {
+		return ErrCallsAdminsOnly
+	}
+	return nil
+}
+
+// CheckJoinCall returns ErrNotParticipant unless the user is a participant of the
+// conversation, ErrUserBanned if they're banned from it, and ErrBlocked if it's a DM where
+// either side blocked the other
+func (r *Repository) CheckJoinCall(ctx context.Context, convID, userID uuid.UUID) error {
+	var isParticipant bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM conversation_participants cp
+			JOIN conversations c ON c.id = cp.conversation_id
+			WHERE cp.conversation_id = $1 AND cp.user_id = $2 AND c.deleted_at IS NULL
+		)
+	`, convID, userID).Scan(&isParticipant)
+	if err != nil {
+		return err
+	}
+	if !isParticipant {
+		return ErrNotParticipant
+	}
+	if err := r.checkBans(ctx, convID, []uuid.UUID{userID}); err != nil {
+		return err
+	}
+	return r.checkDMBlock(ctx, convID, userID)
+}
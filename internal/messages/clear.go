@@ -0,0 +1,100 @@
+package messages
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/user/bla-back/internal/purge"
+)
+
+var ErrNotGroupOwner = errors.New("only the group owner can clear group history")
+
+const clearBatchSize = 1000
+
+// SetFileDeleter makes clearing group history delete the attachment files of removed
+// messages from storage
+func (r *Repository) SetFileDeleter(files purge.FileDeleter) {
+	r.files = files
+}
+
+// notClearedFor hides messages a participant cleared from their own copy
+// (convID and userID are the given query parameters, e.g. "$1", "$2")
+func notClearedFor(convID, userID string) string {
+	return `m.created_at > COALESCE((SELECT cleared_before FROM conversation_participants WHERE conversation_id = ` + convID + ` AND user_id = ` + userID + `), '-infinity')`
+}
+
+// ClearHistory removes messages sent before the cutoff. In DMs only the user's own copy is
+// hidden; in groups the owner deletes history for everyone, in batches to keep locks short.
+// Threads started before the cutoff are removed together with their replies, and the
+// attachment files of removed messages are deleted from storage (see SetFileDeleter).
+func (r *Repository) ClearHistory(ctx context.Context, convID, userID uuid.UUID, before time.Time) (forEveryone bool, err error) {
+	var convType string
+	var ownerID *uuid.UUID
+	err = r.db.QueryRow(ctx, `
+		SELECT type, owner_id FROM conversations WHERE id = $1
+	`, convID).Scan(&convType, &ownerID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, ErrConversationNotFound
+		}
+		return false, err
+	}
+
+	var isParticipant bool
+	err = r.db.QueryRow(ctx, `
+		SELECT EXISTS(`+activeParticipant+`)
+	`, convID, userID).Scan(&isParticipant)
+	if err != nil {
+		return false, err
+	}
+	if !isParticipant {
+		return false, ErrNotParticipant
+	}
+
+	if convType != "group" {
+		_, err = r.db.Exec(ctx, `
+			UPDATE conversation_participants
+			SET cleared_before = GREATEST(COALESCE(cleared_before, '-infinity'), $3)
+			WHERE conversation_id = $1 AND user_id = $2
+		`, convID, userID, before)
+		return false, err
+	}
+
+	// Allow if owner_id is null (legacy) or user is the owner
+	if ownerID != nil && *ownerID != userID {
+		return false, ErrNotGroupOwner
+	}
+
+	// Attachment files go once their rows are deleted, even if the request is cancelled
+	filesCtx := context.WithoutCancel(ctx)
+	for {
+		var deleted int
+		var fileURLs []string
+		err := r.db.QueryRow(ctx, `
+			WITH batch AS (
+				SELECT id FROM messages
+				WHERE conversation_id = $1 AND created_at < $2
+				LIMIT $3
+			), files AS (
+				SELECT a.url FROM attachments a
+				WHERE a.message_id IN (SELECT id FROM batch) AND a.type != 'gif'
+			), deleted AS (
+				DELETE FROM messages WHERE id IN (SELECT id FROM batch)
+				RETURNING id
+			)
+			SELECT (SELECT COUNT(*) FROM deleted), COALESCE((SELECT array_agg(url) FROM files), '{}')
+		`, convID, before, clearBatchSize).Scan(&deleted, &fileURLs)
+		if err != nil {
+			return true, err
+		}
+		if r.files != nil {
+			purge.DeleteFiles(filesCtx, r.files, "cleared messages", fileURLs)
+		}
+		if deleted < clearBatchSize {
+			return true, nil
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package messages
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/user/bla-back/internal/models"
+)
+
+// GetMessageContext returns up to before/after messages around a target message at the same
+// level (top-level history, or the same thread for replies), in chronological order
+func (r *Repository) GetMessageContext(ctx context.Context, convID, messageID, userID uuid.UUID, before, after int) (*models.MessageContext, error) {
+	var exists bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS(`+activeParticipant+`)
+	`, convID, userID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotParticipant
+	}
+
+	var createdAt time.Time
+	var parentID *uuid.UUID
+	var parentCreatedAt *time.Time
+	err = r.db.QueryRow(ctx, `
+		SELECT m.created_at, m.parent_id, p.created_at
+		FROM messages m
+		LEFT JOIN messages p ON p.id = m.parent_id
+		WHERE m.id = $1 AND m.conversation_id = $2
+	`, messageID, convID).Scan(&createdAt, &parentID, &parentCreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// One extra row on each side tells whether more history exists
+	older, err := r.contextPage(ctx, convID, userID, parentID, createdAt, messageID, "<", "DESC", before+1)
+	if err != nil {
+		return nil, err
+	}
+	newer, err := r.contextPage(ctx, convID, userID, parentID, createdAt, messageID, ">", "ASC", after+1)
+	if err != nil {
+		return nil, err
+	}
+	target, err := r.contextPage(ctx, convID, userID, parentID, createdAt, messageID, "=", "ASC", 1)
+	if err != nil {
+		return nil, err
+	}
+	// Cleared or from an ignored sender: the user can't see it, so there's nothing to jump to
+	if len(target) == 0 {
+		return nil, ErrMessageNotFound
+	}
+
+	result := &models.MessageContext{
+		TargetID:      messageID,
+		ParentID:      parentID,
+		HasMoreBefore: len(older) > before,
+		HasMoreAfter:  len(newer) > after,
+	}
+	if result.HasMoreBefore {
+		older = older[:before]
+	}
+	if result.HasMoreAfter {
+		newer = newer[:after]
+	}
+
+	// Older page was fetched newest first
+	for i := len(older) - 1; i >= 0; i-- {
+		result.Messages = append(result.Messages, older[i])
+	}
+	result.Messages = append(result.Messages, target...)
+	result.Messages = append(result.Messages, newer...)
+
+	loaded := result.Messages
+	if parentID != nil && parentCreatedAt != nil {
+		parent, err := r.contextPage(ctx, convID, userID, nil, *parentCreatedAt, *parentID, "=", "ASC", 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(parent) > 0 {
+			result.Parent = parent[0]
+			loaded = append(loaded, result.Parent)
+		}
+	}
+
+	for _, msg := range loaded {
+		msg.Attachments = r.loadAttachments(ctx, msg.ID)
+		msg.Reactions = r.loadReactions(ctx, msg.ID)
+		msg.Mentions = r.loadMentions(ctx, msg.ID)
+		msg.Embeds = r.loadEmbeds(ctx, msg.ID)
+		msg.Poll = r.loadPoll(ctx, msg)
+		msg.Sticker = r.loadSticker(ctx, msg)
+	}
+
+	return result, nil
+}
+
+// contextPage loads messages ordered by (created_at, id) relative to a pivot message
+func (r *Repository) contextPage(ctx context.Context, convID, userID uuid.UUID, parentID *uuid.UUID, pivotAt time.Time, pivotID uuid.UUID, op, order string, limit int) ([]*models.Message, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT m.id, m.conversation_id, m.sender_id, COALESCE(m.type, 'text'), m.content, m.parent_id, m.expires_at, m.deleted_at, m.created_at, m.updated_at,
+			   (SELECT COUNT(*) FROM messages r WHERE r.parent_id = m.id AND r.deleted_at IS NULL),
+			   u.id, u.email, u.username, u.avatar_url, u.status, u.created_at, u.updated_at
+		FROM messages m
+		JOIN users u ON m.sender_id = u.id
+		WHERE m.conversation_id = $1
+		  AND m.parent_id IS NOT DISTINCT FROM $3
+		  AND (m.created_at, m.id) `+op+` ($4, $5)
+		  AND NOT EXISTS(SELECT 1 FROM conversation_ignores WHERE conversation_id = $1 AND user_id = $2 AND ignored_user_id = m.sender_id)
+		  AND `+notClearedFor("$1", "$2")+`
+		ORDER BY m.created_at `+order+`, m.id `+order+`
+		LIMIT $6
+	`, convID, userID, parentID, pivotAt, pivotID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []*models.Message
+	for rows.Next() {
+		msg := &models.Message{Sender: &models.User{}}
+		err := rows.Scan(
+			&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.Type, &msg.Content, &msg.ParentID, &msg.ExpiresAt, &msg.DeletedAt, &msg.CreatedAt, &msg.UpdatedAt,
+			&msg.ReplyCount,
+			&msg.Sender.ID, &msg.Sender.Email, &msg.Sender.Username, &msg.Sender.AvatarURL, &msg.Sender.Status, &msg.Sender.CreatedAt, &msg.Sender.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
+
+// GetPublicMessagePreview returns a message only if its conversation is published through a
+// widget key (and not deleted), so permalinks can show content to visitors without an account.
+// Like the widget, it leaves out thread replies and expired messages.
+func (r *Repository) GetPublicMessagePreview(ctx context.Context, convID, messageID uuid.UUID) (*models.WidgetMessage, error) {
+	m := &models.WidgetMessage{}
+	err := r.db.QueryRow(ctx, `
+		SELECT m.id, COALESCE(m.type, 'text'), m.content, m.created_at, u.username, u.avatar_url
+		FROM messages m
+		JOIN users u ON m.sender_id = u.id
+		JOIN conversations c ON c.id = m.conversation_id
+		WHERE m.id = $1 AND m.conversation_id = $2 AND m.deleted_at IS NULL AND c.deleted_at IS NULL
+		  AND m.parent_id IS NULL AND (m.expires_at IS NULL OR m.expires_at > NOW())
+		  AND EXISTS(SELECT 1 FROM widget_keys WHERE conversation_id = $2 AND revoked_at IS NULL)
+	`, messageID, convID).Scan(&m.ID, &m.Type, &m.Content, &m.CreatedAt, &m.AuthorName, &m.AuthorAvatarURL)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// CanAccessMessage reports whether the user can see a message
+func (r *Repository) CanAccessMessage(ctx context.Context, convID, messageID, userID uuid.UUID) (bool, error) {
+	var ok bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM messages m
+			JOIN conversation_participants cp ON cp.conversation_id = m.conversation_id AND cp.user_id = $3
+			WHERE m.id = $2 AND m.conversation_id = $1
+		)
+	`, convID, messageID, userID).Scan(&ok)
+	return ok, err
+}
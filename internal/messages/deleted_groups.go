@@ -0,0 +1,214 @@
+package messages
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/user/bla-back/internal/models"
+	"github.com/user/bla-back/internal/purge"
+)
+
+var (
+	ErrNotGroup             = errors.New("only group conversations can be deleted")
+	ErrNotDeletionOwner     = errors.New("only the group owner can delete or restore the group")
+	ErrGroupNotDeleted      = errors.New("group is not deleted")
+	ErrRestoreWindowExpired = errors.New("group can no longer be restored")
+)
+
+// activeParticipant matches the participant row of a conversation that isn't soft-deleted
+// (the conversation and user are always the $1 and $2 query parameters)
+const activeParticipant = `SELECT 1 FROM conversation_participants cp JOIN conversations c ON c.id = cp.conversation_id WHERE cp.conversation_id = $1 AND cp.user_id = $2 AND c.deleted_at IS NULL`
+
+const groupPurgeBatchSize = 20
+
+// DeleteGroup soft-deletes a group on behalf of its owner. The group disappears for every
+// participant but can be restored until the window passes. Returns when it was deleted.
+func (r *Repository) DeleteGroup(ctx context.Context, convID, userID uuid.UUID) (time.Time, error) {
+	var convType string
+	var ownerID *uuid.UUID
+	err := r.db.QueryRow(ctx, `
+		SELECT type, owner_id FROM conversations WHERE id = $1 AND deleted_at IS NULL
+	`, convID).Scan(&convType, &ownerID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return time.Time{}, ErrConversationNotFound
+		}
+		return time.Time{}, err
+	}
+
+	var isParticipant bool
+	err = r.db.QueryRow(ctx, `
+		SELECT EXISTS(`+activeParticipant+`)
+	`, convID, userID).Scan(&isParticipant)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !isParticipant {
+		return time.Time{}, ErrNotParticipant
+	}
+
+	if convType != "group" {
+		return time.Time{}, ErrNotGroup
+	}
+	// Allow if owner_id is null (legacy) or user is the owner
+	if ownerID != nil && *ownerID != userID {
+		return time.Time{}, ErrNotDeletionOwner
+	}
+
+	var deletedAt time.Time
+	err = r.db.QueryRow(ctx, `
+		UPDATE conversations SET deleted_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING deleted_at
+	`, convID).Scan(&deletedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return time.Time{}, ErrConversationNotFound
+	}
+	return deletedAt, err
+}
+
+// RestoreGroup undoes DeleteGroup if the group was deleted less than window ago
+func (r *Repository) RestoreGroup(ctx context.Context, convID, userID uuid.UUID, window time.Duration) error {
+	var ownerID *uuid.UUID
+	var deletedAt *time.Time
+	var isParticipant bool
+	err := r.db.QueryRow(ctx, `
+		SELECT c.owner_id, c.deleted_at,
+			   EXISTS(SELECT 1 FROM conversation_participants WHERE conversation_id = c.id AND user_id = $2)
+		FROM conversations c
+		WHERE c.id = $1 AND c.type = 'group'
+	`, convID, userID).Scan(&ownerID, &deletedAt, &isParticipant)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrConversationNotFound
+		}
+		return err
+	}
+	if !isParticipant {
+		return ErrNotParticipant
+	}
+	if ownerID != nil && *ownerID != userID {
+		return ErrNotDeletionOwner
+	}
+	if deletedAt == nil {
+		return ErrGroupNotDeleted
+	}
+
+	tag, err := r.db.Exec(ctx, `
+		UPDATE conversations SET deleted_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND deleted_at > $2
+	`, convID, time.Now().Add(-window))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrRestoreWindowExpired
+	}
+	return nil
+}
+
+// GetDeletedGroups lists the groups a user deleted that can still be restored
+func (r *Repository) GetDeletedGroups(ctx context.Context, userID uuid.UUID, window time.Duration) ([]*models.DeletedGroup, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, name, avatar_url, deleted_at
+		FROM conversations
+		WHERE owner_id = $1 AND type = 'group' AND deleted_at > $2
+		ORDER BY deleted_at DESC
+	`, userID, time.Now().Add(-window))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []*models.DeletedGroup{}
+	for rows.Next() {
+		g := &models.DeletedGroup{}
+		if err := rows.Scan(&g.ID, &g.Name, &g.AvatarURL, &g.DeletedAt); err != nil {
+			return nil, err
+		}
+		g.RestorableUntil = g.DeletedAt.Add(window)
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// PurgeDeletedGroups permanently removes up to limit groups deleted before the cutoff,
+// together with their messages. Returns the stored files (uploaded attachments and the
+// group avatar) that the caller should remove from storage.
+func (r *Repository) PurgeDeletedGroups(ctx context.Context, before time.Time, limit int) (purged int, fileURLs []string, err error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT id, avatar_url FROM conversations
+		WHERE deleted_at IS NOT NULL AND deleted_at < $1
+		ORDER BY deleted_at
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	`, before, limit)
+	if err != nil {
+		return 0, nil, err
+	}
+	var convIDs []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		var avatarURL *string
+		if err := rows.Scan(&id, &avatarURL); err != nil {
+			rows.Close()
+			return 0, nil, err
+		}
+		convIDs = append(convIDs, id)
+		if avatarURL != nil {
+			fileURLs = append(fileURLs, *avatarURL)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+	if len(convIDs) == 0 {
+		return 0, nil, nil
+	}
+
+	// GIFs point at the provider's CDN, not our bucket
+	rows, err = tx.Query(ctx, `
+		SELECT a.url FROM attachments a
+		JOIN messages m ON m.id = a.message_id
+		WHERE m.conversation_id = ANY($1) AND a.type != 'gif'
+	`, convIDs)
+	if err != nil {
+		return 0, nil, err
+	}
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			rows.Close()
+			return 0, nil, err
+		}
+		fileURLs = append(fileURLs, url)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	// Messages, attachments and everything else hanging off the conversation cascade
+	if _, err := tx.Exec(ctx, `DELETE FROM conversations WHERE id = ANY($1)`, convIDs); err != nil {
+		return 0, nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, nil, err
+	}
+	return len(convIDs), fileURLs, nil
+}
+
+// NewGroupPurger periodically hard-deletes groups whose restore window has passed
+func NewGroupPurger(repo *Repository, files purge.FileDeleter, window, interval time.Duration) *purge.Runner {
+	return purge.NewRunner("deleted groups", repo.PurgeDeletedGroups, groupPurgeBatchSize, files, window, interval)
+}
@@ -0,0 +1,157 @@
+package messages
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/user/bla-back/internal/models"
+)
+
+// MarkDelivered records that the user's device received messageID (and everything before it).
+// The watermark only moves forward. notify is true when it advanced in a DM, so the sender
+// should get MESSAGE_DELIVERED.
+func (r *Repository) MarkDelivered(ctx context.Context, convID, userID, messageID uuid.UUID) (event *models.MessageDeliveredEvent, notify bool, err error) {
+	event = &models.MessageDeliveredEvent{ConversationID: convID, UserID: userID, MessageID: messageID}
+
+	var convType string
+	err = r.db.QueryRow(ctx, `
+		UPDATE conversation_participants cp
+		SET last_delivered_message_id = m.id, last_delivered_at = NOW()
+		FROM messages m, conversations c
+		WHERE cp.conversation_id = $1 AND cp.user_id = $2
+		  AND m.id = $3 AND m.conversation_id = $1 AND c.id = $1
+		  AND (cp.last_delivered_message_id IS NULL
+		       OR m.created_at > (SELECT created_at FROM messages WHERE id = cp.last_delivered_message_id))
+		RETURNING cp.last_delivered_at, c.type
+	`, convID, userID, messageID).Scan(&event.DeliveredAt, &convType)
+	if err == nil {
+		return event, convType == "dm", nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, err
+	}
+
+	// Nothing advanced: either an older ack or an invalid message/participant
+	var isParticipant, exists bool
+	err = r.db.QueryRow(ctx, `
+		SELECT EXISTS(`+activeParticipant+`),
+			   EXISTS(SELECT 1 FROM messages WHERE id = $3 AND conversation_id = $1)
+	`, convID, userID, messageID).Scan(&isParticipant, &exists)
+	if err != nil {
+		return nil, false, err
+	}
+	if !isParticipant {
+		return nil, false, ErrNotParticipant
+	}
+	if !exists {
+		return nil, false, ErrMessageNotFound
+	}
+
+	err = r.db.QueryRow(ctx, `
+		SELECT last_delivered_message_id, last_delivered_at FROM conversation_participants
+		WHERE conversation_id = $1 AND user_id = $2
+	`, convID, userID).Scan(&event.MessageID, &event.DeliveredAt)
+	if err != nil {
+		return nil, false, err
+	}
+	return event, false, nil
+}
+
+// MarkDeliveredOnReady advances the user's delivery watermark in every DM to the latest
+// message from the other side, as READY hands the device all conversations. Returns the
+// DMs where it advanced.
+func (r *Repository) MarkDeliveredOnReady(ctx context.Context, userID uuid.UUID) ([]*models.MessageDeliveredEvent, error) {
+	rows, err := r.db.Query(ctx, `
+		WITH latest AS (
+			SELECT DISTINCT ON (m.conversation_id) m.conversation_id, m.id, m.created_at
+			FROM messages m
+			JOIN conversation_participants mine ON mine.conversation_id = m.conversation_id AND mine.user_id = $1
+			JOIN conversations c ON c.id = m.conversation_id AND c.type = 'dm'
+			WHERE m.sender_id != $1 AND m.parent_id IS NULL AND m.deleted_at IS NULL
+			  AND NOT `+awaitingRequest("c.id", "$1")+`
+			ORDER BY m.conversation_id, m.created_at DESC
+		)
+		UPDATE conversation_participants cp
+		SET last_delivered_message_id = latest.id, last_delivered_at = NOW()
+		FROM latest
+		WHERE cp.conversation_id = latest.conversation_id AND cp.user_id = $1
+		  AND (cp.last_delivered_message_id IS NULL
+		       OR latest.created_at > (SELECT created_at FROM messages WHERE id = cp.last_delivered_message_id))
+		RETURNING cp.conversation_id, latest.id, cp.last_delivered_at
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*models.MessageDeliveredEvent
+	for rows.Next() {
+		e := &models.MessageDeliveredEvent{UserID: userID}
+		if err := rows.Scan(&e.ConversationID, &e.MessageID, &e.DeliveredAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// GetDeliveryStatus returns, for each recipient of a message, whether it was sent, delivered or read
+func (r *Repository) GetDeliveryStatus(ctx context.Context, convID, messageID, userID uuid.UUID) (*models.MessageDeliveryStatus, error) {
+	var isParticipant bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS(`+activeParticipant+`)
+	`, convID, userID).Scan(&isParticipant)
+	if err != nil {
+		return nil, err
+	}
+	if !isParticipant {
+		return nil, ErrNotParticipant
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT cp.user_id,
+			   CASE
+				   WHEN rm.created_at >= m.created_at THEN 'read'
+				   WHEN dm.created_at >= m.created_at THEN 'delivered'
+				   ELSE 'sent'
+			   END
+		FROM messages m
+		JOIN conversation_participants cp ON cp.conversation_id = m.conversation_id AND cp.user_id != m.sender_id
+		LEFT JOIN messages rm ON rm.id = cp.last_read_message_id
+		LEFT JOIN messages dm ON dm.id = cp.last_delivered_message_id
+		WHERE m.id = $2 AND m.conversation_id = $1
+	`, convID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	status := &models.MessageDeliveryStatus{MessageID: messageID, Recipients: []*models.RecipientStatus{}}
+	for rows.Next() {
+		s := &models.RecipientStatus{}
+		if err := rows.Scan(&s.UserID, &s.Status); err != nil {
+			return nil, err
+		}
+		status.Recipients = append(status.Recipients, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Recipients are only missing if the message doesn't exist here (or has no recipients)
+	if len(status.Recipients) == 0 {
+		var exists bool
+		if err := r.db.QueryRow(ctx, `
+			SELECT EXISTS(SELECT 1 FROM messages WHERE id = $1 AND conversation_id = $2)
+		`, messageID, convID).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, ErrMessageNotFound
+		}
+	}
+
+	return status, nil
+}
@@ -0,0 +1,90 @@
+package messages
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/user/bla-back/internal/models"
+)
+
+// likeEscaper makes user input match literally in a LIKE pattern
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// DiscoverGroups lists public groups whose name contains query (all of them if it's
+// empty), largest first. Groups the user is banned from are left out.
+func (r *Repository) DiscoverGroups(ctx context.Context, userID uuid.UUID, query string, limit, offset int) ([]*models.DiscoverableGroup, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT c.id, c.name, c.description, c.avatar_url, c.join_approval, m.count,
+			   EXISTS(SELECT 1 FROM conversation_participants cp WHERE cp.conversation_id = c.id AND cp.user_id = $1),
+			   EXISTS(SELECT 1 FROM group_join_requests jr WHERE jr.conversation_id = c.id AND jr.user_id = $1)
+		FROM conversations c
+		CROSS JOIN LATERAL (
+			SELECT COUNT(*) AS count FROM conversation_participants cp WHERE cp.conversation_id = c.id
+		) m
+		WHERE c.type = 'group' AND c.is_public AND c.deleted_at IS NULL
+		  AND ($2 = '' OR c.name ILIKE '%' || $2 || '%')
+		  AND NOT EXISTS (SELECT 1 FROM conversation_bans b WHERE b.conversation_id = c.id AND b.user_id = $1)
+		ORDER BY m.count DESC, c.created_at DESC
+		LIMIT $3 OFFSET $4
+	`, userID, likeEscaper.Replace(query), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []*models.DiscoverableGroup{}
+	for rows.Next() {
+		g := &models.DiscoverableGroup{}
+		if err := rows.Scan(&g.ID, &g.Name, &g.Description, &g.AvatarURL, &g.JoinApproval, &g.MemberCount,
+			&g.Joined, &g.RequestPending); err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// JoinPublicGroup adds the user to a public group (see admit). Groups with join approval
+// get a join request and ErrJoinRequestPending.
+func (r *Repository) JoinPublicGroup(ctx context.Context, convID, userID uuid.UUID) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Locked for update from the start: admit locks the group again to reserve a seat,
+	// and concurrent joins upgrading a share lock would deadlock
+	var public bool
+	err = tx.QueryRow(ctx, `
+		SELECT is_public FROM conversations
+		WHERE id = $1 AND type = 'group' AND deleted_at IS NULL
+		FOR UPDATE
+	`, convID).Scan(&public)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !public) {
+		return ErrConversationNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	var isParticipant bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS(`+activeParticipant+`)`, convID, userID).Scan(&isParticipant); err != nil {
+		return err
+	}
+	if isParticipant {
+		return ErrAlreadyParticipant
+	}
+
+	err = r.admit(ctx, tx, convID, userID, nil)
+	if err != nil && !errors.Is(err, ErrJoinRequestPending) {
+		return err
+	}
+	if cerr := tx.Commit(ctx); cerr != nil {
+		return cerr
+	}
+	return err
+}
@@ -0,0 +1,95 @@
+package messages
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/user/bla-back/internal/models"
+)
+
+// SaveDraft stores the user's unsent text for a conversation. Empty content clears it.
+func (r *Repository) SaveDraft(ctx context.Context, convID, userID uuid.UUID, content string) (*models.Draft, error) {
+	var exists bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS(`+activeParticipant+`)
+	`, convID, userID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotParticipant
+	}
+
+	draft := &models.Draft{ConversationID: convID}
+	if content == "" {
+		err = r.db.QueryRow(ctx, `
+			DELETE FROM message_drafts WHERE user_id = $1 AND conversation_id = $2
+			RETURNING NOW()
+		`, userID, convID).Scan(&draft.UpdatedAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return draft, nil
+		}
+		return draft, err
+	}
+
+	err = r.db.QueryRow(ctx, `
+		INSERT INTO message_drafts (user_id, conversation_id, content)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, conversation_id) DO UPDATE SET content = EXCLUDED.content, updated_at = NOW()
+		RETURNING content, updated_at
+	`, userID, convID, content).Scan(&draft.Content, &draft.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return draft, nil
+}
+
+// GetDraft returns the user's draft for a conversation (empty content if none)
+func (r *Repository) GetDraft(ctx context.Context, convID, userID uuid.UUID) (*models.Draft, error) {
+	var exists bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS(`+activeParticipant+`)
+	`, convID, userID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotParticipant
+	}
+
+	draft := &models.Draft{ConversationID: convID}
+	err = r.db.QueryRow(ctx, `
+		SELECT content, updated_at FROM message_drafts
+		WHERE user_id = $1 AND conversation_id = $2
+	`, userID, convID).Scan(&draft.Content, &draft.UpdatedAt)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	return draft, nil
+}
+
+// GetUserDrafts returns all drafts of a user in conversations they still belong to
+func (r *Repository) GetUserDrafts(ctx context.Context, userID uuid.UUID) ([]*models.Draft, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT d.conversation_id, d.content, d.updated_at
+		FROM message_drafts d
+		JOIN conversation_participants cp ON cp.conversation_id = d.conversation_id AND cp.user_id = d.user_id
+		WHERE d.user_id = $1
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var drafts []*models.Draft
+	for rows.Next() {
+		d := &models.Draft{}
+		if err := rows.Scan(&d.ConversationID, &d.Content, &d.UpdatedAt); err != nil {
+			return nil, err
+		}
+		drafts = append(drafts, d)
+	}
+	return drafts, rows.Err()
+}
@@ -0,0 +1,76 @@
+package messages
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/models"
+)
+
+const expiryBatchSize = 500
+
+// Publisher sends realtime events to users and conversation channels
+type Publisher interface {
+	PublishToUsers(userIDs []uuid.UUID, eventType string, data interface{})
+	PublishToConversation(conversationID uuid.UUID, participantIDs []uuid.UUID, eventType string, data interface{})
+}
+
+// ExpiryWorker periodically deletes disappearing messages and tells clients to purge them
+type ExpiryWorker struct {
+	repo      *Repository
+	publisher Publisher
+	interval  time.Duration
+}
+
+func NewExpiryWorker(repo *Repository, publisher Publisher, interval time.Duration) *ExpiryWorker {
+	return &ExpiryWorker{
+		repo:      repo,
+		publisher: publisher,
+		interval:  interval,
+	}
+}
+
+// Run deletes expired messages every interval until ctx is cancelled
+func (w *ExpiryWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.sweep(ctx)
+		}
+	}
+}
+
+func (w *ExpiryWorker) sweep(ctx context.Context) {
+	for {
+		expired, err := w.repo.DeleteExpiredMessages(ctx, expiryBatchSize)
+		if err != nil {
+			log.Printf("Failed to delete expired messages: %v", err)
+			return
+		}
+
+		// Cache participants per conversation within a batch
+		participants := make(map[uuid.UUID][]uuid.UUID)
+		for _, m := range expired {
+			ids, ok := participants[m.ConversationID]
+			if !ok {
+				ids, _ = w.repo.GetConversationParticipantIDs(ctx, m.ConversationID)
+				participants[m.ConversationID] = ids
+			}
+			w.publisher.PublishToConversation(m.ConversationID, ids, "MESSAGE_DELETE", &models.MessageDeleteEvent{
+				MessageID:      m.MessageID,
+				ConversationID: m.ConversationID,
+			})
+		}
+
+		if len(expired) < expiryBatchSize {
+			return
+		}
+	}
+}
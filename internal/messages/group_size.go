@@ -0,0 +1,59 @@
+package messages
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrGroupFull = errors.New("group is full")
+
+// GroupFullError reports a join or add that would take a group past the size limit.
+// It matches ErrGroupFull.
+type GroupFullError struct {
+	Max       int
+	Current   int
+	Requested int // users that would be added
+}
+
+func (e *GroupFullError) Error() string {
+	return fmt.Sprintf("group has %d of %d participants, can't add %d more", e.Current, e.Max, e.Requested)
+}
+
+func (e *GroupFullError) Is(target error) bool {
+	return target == ErrGroupFull
+}
+
+// reserveGroupSeats locks the group and returns a *GroupFullError if adding the users
+// would take it past the limit. Users who are already participants don't count. Callers
+// that lock the group earlier in the transaction must lock it FOR UPDATE too.
+func (r *Repository) reserveGroupSeats(ctx context.Context, tx pgx.Tx, convID uuid.UUID, userIDs []uuid.UUID) error {
+	if r.maxGroupSize <= 0 {
+		return nil
+	}
+
+	// Concurrent joins wait here, so they can't both take the last seat
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM conversations WHERE id = $1 FOR UPDATE`, convID); err != nil {
+		return err
+	}
+
+	var current, adding int
+	err := tx.QueryRow(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM conversation_participants WHERE conversation_id = $1),
+			(SELECT COUNT(*) FROM (SELECT DISTINCT unnest($2::uuid[]) AS id) u
+			 WHERE NOT EXISTS (
+				SELECT 1 FROM conversation_participants cp WHERE cp.conversation_id = $1 AND cp.user_id = u.id
+			 ))
+	`, convID, userIDs).Scan(&current, &adding)
+	if err != nil {
+		return err
+	}
+	if current+adding > r.maxGroupSize {
+		return &GroupFullError{Max: r.maxGroupSize, Current: current, Requested: adding}
+	}
+	return nil
+}
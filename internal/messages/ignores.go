@@ -0,0 +1,112 @@
+package messages
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/models"
+)
+
+var ErrCannotIgnoreSelf = errors.New("cannot ignore yourself")
+
+// IgnoreUser hides another participant's messages from the user in one conversation
+func (r *Repository) IgnoreUser(ctx context.Context, convID, userID, ignoredUserID uuid.UUID) error {
+	if userID == ignoredUserID {
+		return ErrCannotIgnoreSelf
+	}
+
+	var bothParticipants bool
+	err := r.db.QueryRow(ctx, `
+		SELECT COUNT(*) = 2 FROM conversation_participants
+		WHERE conversation_id = $1 AND user_id IN ($2, $3)
+	`, convID, userID, ignoredUserID).Scan(&bothParticipants)
+	if err != nil {
+		return err
+	}
+	if !bothParticipants {
+		return ErrNotParticipant
+	}
+
+	_, err = r.db.Exec(ctx, `
+		INSERT INTO conversation_ignores (conversation_id, user_id, ignored_user_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+	`, convID, userID, ignoredUserID)
+	return err
+}
+
+// UnignoreUser shows a participant's messages again
+func (r *Repository) UnignoreUser(ctx context.Context, convID, userID, ignoredUserID uuid.UUID) error {
+	_, err := r.db.Exec(ctx, `
+		DELETE FROM conversation_ignores
+		WHERE conversation_id = $1 AND user_id = $2 AND ignored_user_id = $3
+	`, convID, userID, ignoredUserID)
+	return err
+}
+
+// GetIgnoredUsers returns the participants the user ignores in a conversation
+func (r *Repository) GetIgnoredUsers(ctx context.Context, convID, userID uuid.UUID) ([]*models.User, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT u.id, u.email, u.username, u.avatar_url, u.status, u.created_at, u.updated_at
+		FROM conversation_ignores ci
+		JOIN users u ON u.id = ci.ignored_user_id
+		WHERE ci.conversation_id = $1 AND ci.user_id = $2
+		ORDER BY ci.created_at
+	`, convID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		user := &models.User{}
+		if err := rows.Scan(&user.ID, &user.Email, &user.Username, &user.AvatarURL, &user.Status, &user.CreatedAt, &user.UpdatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// GetRecipientIDs returns participant IDs that should receive senderID's messages,
+// i.e. everyone except users who ignore the sender in this conversation and the
+// recipient of a message request that isn't accepted yet
+func (r *Repository) GetRecipientIDs(ctx context.Context, convID, senderID uuid.UUID) ([]uuid.UUID, error) {
+	ids, _, err := r.GetRecipients(ctx, convID, senderID)
+	return ids, err
+}
+
+// GetRecipients is GetRecipientIDs that also reports whether anyone was left out,
+// in which case the conversation channel can't be used
+func (r *Repository) GetRecipients(ctx context.Context, convID, senderID uuid.UUID) ([]uuid.UUID, bool, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT cp.user_id, EXISTS(
+			SELECT 1 FROM conversation_ignores ci
+			WHERE ci.conversation_id = $1 AND ci.user_id = cp.user_id AND ci.ignored_user_id = $2
+		) OR `+awaitingRequest("$1", "cp.user_id")+`
+		FROM conversation_participants cp
+		WHERE cp.conversation_id = $1
+	`, convID, senderID)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	filtered := false
+	for rows.Next() {
+		var id uuid.UUID
+		var excluded bool
+		if err := rows.Scan(&id, &excluded); err != nil {
+			return nil, false, err
+		}
+		if excluded {
+			filtered = true
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, filtered, rows.Err()
+}
@@ -0,0 +1,222 @@
+package messages
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/user/bla-back/internal/models"
+)
+
+var (
+	ErrInviteLinkNotFound = errors.New("invite link not found")
+	ErrInviteLinkExpired  = errors.New("invite link expired or used up")
+)
+
+const inviteLinkColumns = `code, conversation_id, created_by, max_uses, uses, expires_at, created_at`
+
+func scanInviteLink(row pgx.Row) (*models.GroupInviteLink, error) {
+	l := &models.GroupInviteLink{}
+	err := row.Scan(&l.Code, &l.ConversationID, &l.CreatedBy, &l.MaxUses, &l.Uses, &l.ExpiresAt, &l.CreatedAt)
+	return l, err
+}
+
+func generateInviteLinkCode() (string, error) {
+	bytes := make([]byte, 9)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// CreateInviteLink creates a shareable invite code for a group; needs the same permission
+// as adding members directly
+func (r *Repository) CreateInviteLink(ctx context.Context, convID, userID uuid.UUID, maxUses *int, expiresAt *time.Time) (*models.GroupInviteLink, error) {
+	var convType string
+	err := r.db.QueryRow(ctx, `SELECT type FROM conversations WHERE id = $1 AND deleted_at IS NULL`, convID).Scan(&convType)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrConversationNotFound
+		}
+		return nil, err
+	}
+	if convType != "group" {
+		return nil, ErrConversationNotFound
+	}
+
+	if err := r.CheckPermission(ctx, convID, userID, PermAddMembers); err != nil {
+		return nil, err
+	}
+
+	code, err := generateInviteLinkCode()
+	if err != nil {
+		return nil, err
+	}
+
+	return scanInviteLink(r.db.QueryRow(ctx, `
+		INSERT INTO group_invite_links (code, conversation_id, created_by, max_uses, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+inviteLinkColumns,
+		code, convID, userID, maxUses, expiresAt))
+}
+
+// GetInviteLinks lists a group's usable invite links, newest first
+func (r *Repository) GetInviteLinks(ctx context.Context, convID, userID uuid.UUID) ([]*models.GroupInviteLink, error) {
+	if err := r.CheckPermission(ctx, convID, userID, PermAddMembers); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT `+inviteLinkColumns+` FROM group_invite_links
+		WHERE conversation_id = $1 AND revoked_at IS NULL
+		  AND (expires_at IS NULL OR expires_at > NOW())
+		  AND (max_uses IS NULL OR uses < max_uses)
+		ORDER BY created_at DESC
+	`, convID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []*models.GroupInviteLink{}
+	for rows.Next() {
+		l, err := scanInviteLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
+
+// RevokeInviteLink stops an invite link from working
+func (r *Repository) RevokeInviteLink(ctx context.Context, convID, userID uuid.UUID, code string) error {
+	if err := r.CheckPermission(ctx, convID, userID, PermAddMembers); err != nil {
+		return err
+	}
+
+	result, err := r.db.Exec(ctx, `
+		UPDATE group_invite_links SET revoked_at = NOW()
+		WHERE code = $1 AND conversation_id = $2 AND revoked_at IS NULL
+	`, code, convID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrInviteLinkNotFound
+	}
+	return nil
+}
+
+// JoinByInviteLink adds the user to the link's group (see admit) and returns the group's ID.
+// In groups with join approval it files a join request and returns ErrJoinRequestPending.
+func (r *Repository) JoinByInviteLink(ctx context.Context, code string, userID uuid.UUID) (uuid.UUID, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var convID uuid.UUID
+	var usable bool
+	err = tx.QueryRow(ctx, `
+		SELECT l.conversation_id,
+			   l.revoked_at IS NULL AND (l.expires_at IS NULL OR l.expires_at > NOW()) AND (l.max_uses IS NULL OR l.uses < l.max_uses)
+		FROM group_invite_links l
+		JOIN conversations c ON c.id = l.conversation_id
+		WHERE l.code = $1 AND c.deleted_at IS NULL
+		FOR UPDATE OF l
+	`, code).Scan(&convID, &usable)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, ErrInviteLinkNotFound
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	var isParticipant bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS(`+activeParticipant+`)`, convID, userID).Scan(&isParticipant); err != nil {
+		return uuid.Nil, err
+	}
+	if isParticipant {
+		return convID, ErrAlreadyParticipant
+	}
+	if !usable {
+		return uuid.Nil, ErrInviteLinkExpired
+	}
+	err = r.admit(ctx, tx, convID, userID, &code)
+	if err != nil && !errors.Is(err, ErrJoinRequestPending) {
+		return uuid.Nil, err
+	}
+	if cerr := tx.Commit(ctx); cerr != nil {
+		return uuid.Nil, cerr
+	}
+	return convID, err
+}
+
+// admit adds a user joining a group on their own, through an invite link (code) or
+// discovery. It lifts their opt-out from being re-added, since they're joining on
+// purpose; bans still apply. Groups with join approval get a join request instead and
+// ErrJoinRequestPending, which the caller still commits.
+func (r *Repository) admit(ctx context.Context, tx pgx.Tx, convID, userID uuid.UUID, code *string) error {
+	if err := r.checkBans(ctx, convID, []uuid.UUID{userID}); err != nil {
+		return err
+	}
+
+	var needsApproval bool
+	if err := tx.QueryRow(ctx, `SELECT join_approval FROM conversations WHERE id = $1`, convID).Scan(&needsApproval); err != nil {
+		return err
+	}
+	if needsApproval {
+		// The request takes up one of the link's uses; asking again doesn't
+		result, err := tx.Exec(ctx, `
+			INSERT INTO group_join_requests (conversation_id, user_id, invite_code) VALUES ($1, $2, $3)
+			ON CONFLICT (conversation_id, user_id) DO NOTHING
+		`, convID, userID, code)
+		if err != nil {
+			return err
+		}
+		if code != nil && result.RowsAffected() > 0 {
+			if _, err := tx.Exec(ctx, `UPDATE group_invite_links SET uses = uses + 1 WHERE code = $1`, *code); err != nil {
+				return err
+			}
+		}
+		return ErrJoinRequestPending
+	}
+
+	if err := r.reserveGroupSeats(ctx, tx, convID, []uuid.UUID{userID}); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(ctx, `
+		INSERT INTO conversation_participants (conversation_id, user_id) VALUES ($1, $2)
+	`, convID, userID)
+	if err != nil {
+		return err
+	}
+
+	if code != nil {
+		_, err = tx.Exec(ctx, `UPDATE group_invite_links SET uses = uses + 1 WHERE code = $1`, *code)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO group_invite_link_uses (code, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING
+		`, *code, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, table := range []string{"group_readd_blocks", "group_invites"} {
+		_, err = tx.Exec(ctx, `DELETE FROM `+table+` WHERE conversation_id = $1 AND user_id = $2`, convID, userID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
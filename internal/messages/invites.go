@@ -0,0 +1,218 @@
+package messages
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/user/bla-back/internal/models"
+)
+
+var (
+	ErrReaddBlocked       = errors.New("user opted out of being re-added to this group")
+	ErrAlreadyParticipant = errors.New("user is already a participant")
+	ErrInviteeNotFound    = errors.New("user not found")
+	ErrNoGroupInvite      = errors.New("no pending invite to this group")
+)
+
+// ReaddBlockedError lists the users an AddParticipants call can't add because they left
+// the group and opted out of being re-added. It matches ErrReaddBlocked.
+type ReaddBlockedError struct {
+	UserIDs []uuid.UUID
+}
+
+func (e *ReaddBlockedError) Error() string {
+	return fmt.Sprintf("%d user(s) opted out of being re-added to this group", len(e.UserIDs))
+}
+
+func (e *ReaddBlockedError) Is(target error) bool {
+	return target == ErrReaddBlocked
+}
+
+// checkReaddBlocks returns a *ReaddBlockedError if any of the users opted out of the group
+func (r *Repository) checkReaddBlocks(ctx context.Context, convID uuid.UUID, userIDs []uuid.UUID) error {
+	rows, err := r.db.Query(ctx, `
+		SELECT user_id FROM group_readd_blocks WHERE conversation_id = $1 AND user_id = ANY($2)
+	`, convID, userIDs)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var blocked []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		blocked = append(blocked, id)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(blocked) > 0 {
+		return &ReaddBlockedError{UserIDs: blocked}
+	}
+	return nil
+}
+
+// InviteToGroup invites a user to a group; needs the same permission as adding them directly
+func (r *Repository) InviteToGroup(ctx context.Context, convID, inviterID, userID uuid.UUID) (*models.GroupInvite, error) {
+	var convType string
+	err := r.db.QueryRow(ctx, `SELECT type FROM conversations WHERE id = $1 AND deleted_at IS NULL`, convID).Scan(&convType)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrConversationNotFound
+		}
+		return nil, err
+	}
+	if convType != "group" {
+		return nil, ErrConversationNotFound
+	}
+
+	if err := r.CheckPermission(ctx, convID, inviterID, PermAddMembers); err != nil {
+		return nil, err
+	}
+
+	var isParticipant bool
+	err = r.db.QueryRow(ctx, `SELECT EXISTS(`+activeParticipant+`)`, convID, userID).Scan(&isParticipant)
+	if err != nil {
+		return nil, err
+	}
+	if isParticipant {
+		return nil, ErrAlreadyParticipant
+	}
+	if err := r.checkBans(ctx, convID, []uuid.UUID{userID}); err != nil {
+		return nil, err
+	}
+
+	var blocked bool
+	err = r.db.QueryRow(ctx, `SELECT EXISTS(`+blockedPair+`)`, inviterID, userID).Scan(&blocked)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, ErrBlocked
+	}
+
+	// Inviting again refreshes the invite
+	result, err := r.db.Exec(ctx, `
+		INSERT INTO group_invites (conversation_id, user_id, invited_by)
+		SELECT $1, $2, $3 WHERE EXISTS(SELECT 1 FROM users WHERE id = $2)
+		ON CONFLICT (conversation_id, user_id) DO UPDATE SET invited_by = $3, created_at = NOW()
+	`, convID, userID, inviterID)
+	if err != nil {
+		return nil, err
+	}
+	if result.RowsAffected() == 0 {
+		return nil, ErrInviteeNotFound
+	}
+
+	return r.getGroupInvite(ctx, convID, userID)
+}
+
+func (r *Repository) getGroupInvite(ctx context.Context, convID, userID uuid.UUID) (*models.GroupInvite, error) {
+	invites, err := r.getGroupInvites(ctx, userID, &convID)
+	if err != nil {
+		return nil, err
+	}
+	if len(invites) == 0 {
+		return nil, ErrNoGroupInvite
+	}
+	return invites[0], nil
+}
+
+// GetGroupInvites lists the pending group invites a user received, newest first
+func (r *Repository) GetGroupInvites(ctx context.Context, userID uuid.UUID) ([]*models.GroupInvite, error) {
+	return r.getGroupInvites(ctx, userID, nil)
+}
+
+func (r *Repository) getGroupInvites(ctx context.Context, userID uuid.UUID, convID *uuid.UUID) ([]*models.GroupInvite, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT c.id, c.name, c.avatar_url, gi.created_at,
+			   u.id, u.email, u.username, u.avatar_url, u.status, u.created_at, u.updated_at
+		FROM group_invites gi
+		JOIN conversations c ON c.id = gi.conversation_id
+		JOIN users u ON u.id = gi.invited_by
+		WHERE gi.user_id = $1 AND c.deleted_at IS NULL AND ($2::uuid IS NULL OR gi.conversation_id = $2)
+		ORDER BY gi.created_at DESC
+	`, userID, convID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invites := []*models.GroupInvite{}
+	for rows.Next() {
+		invite := &models.GroupInvite{InvitedBy: &models.User{}}
+		err := rows.Scan(&invite.ConversationID, &invite.Name, &invite.AvatarURL, &invite.CreatedAt,
+			&invite.InvitedBy.ID, &invite.InvitedBy.Email, &invite.InvitedBy.Username, &invite.InvitedBy.AvatarURL,
+			&invite.InvitedBy.Status, &invite.InvitedBy.CreatedAt, &invite.InvitedBy.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		invites = append(invites, invite)
+	}
+	return invites, rows.Err()
+}
+
+// AcceptGroupInvite joins the group and lifts the user's opt-out from being re-added
+func (r *Repository) AcceptGroupInvite(ctx context.Context, convID, userID uuid.UUID) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var deleted bool
+	err = tx.QueryRow(ctx, `
+		DELETE FROM group_invites gi USING conversations c
+		WHERE gi.conversation_id = $1 AND gi.user_id = $2 AND c.id = gi.conversation_id
+		RETURNING c.deleted_at IS NOT NULL
+	`, convID, userID).Scan(&deleted)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNoGroupInvite
+	}
+	if err != nil {
+		return err
+	}
+	if deleted {
+		return ErrConversationNotFound
+	}
+	if err := r.reserveGroupSeats(ctx, tx, convID, []uuid.UUID{userID}); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO conversation_participants (conversation_id, user_id) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, convID, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		DELETE FROM group_readd_blocks WHERE conversation_id = $1 AND user_id = $2
+	`, convID, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// DeclineGroupInvite drops a pending invite; an opt-out from being re-added stays in place
+func (r *Repository) DeclineGroupInvite(ctx context.Context, convID, userID uuid.UUID) error {
+	result, err := r.db.Exec(ctx, `
+		DELETE FROM group_invites WHERE conversation_id = $1 AND user_id = $2
+	`, convID, userID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNoGroupInvite
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package messages
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/user/bla-back/internal/models"
+)
+
+var (
+	ErrJoinRequestPending  = errors.New("join request is waiting for approval")
+	ErrJoinRequestNotFound = errors.New("join request not found")
+)
+
+// GetJoinRequests lists a group's pending join requests, oldest first
+func (r *Repository) GetJoinRequests(ctx context.Context, convID, userID uuid.UUID) ([]*models.GroupJoinRequest, error) {
+	if err := r.CheckPermission(ctx, convID, userID, PermApproveJoins); err != nil {
+		return nil, err
+	}
+	return r.joinRequests(ctx, convID, nil)
+}
+
+// GetJoinRequest returns one user's pending join request to a group
+func (r *Repository) GetJoinRequest(ctx context.Context, convID, userID uuid.UUID) (*models.GroupJoinRequest, error) {
+	requests, err := r.joinRequests(ctx, convID, &userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(requests) == 0 {
+		return nil, ErrJoinRequestNotFound
+	}
+	return requests[0], nil
+}
+
+func (r *Repository) joinRequests(ctx context.Context, convID uuid.UUID, userID *uuid.UUID) ([]*models.GroupJoinRequest, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT jr.conversation_id, jr.invite_code, jr.created_at,
+			   u.id, u.email, u.username, u.avatar_url, u.status, u.created_at, u.updated_at
+		FROM group_join_requests jr
+		JOIN users u ON u.id = jr.user_id
+		WHERE jr.conversation_id = $1 AND ($2::uuid IS NULL OR jr.user_id = $2)
+		ORDER BY jr.created_at
+	`, convID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []*models.GroupJoinRequest{}
+	for rows.Next() {
+		req := &models.GroupJoinRequest{User: &models.User{}}
+		err := rows.Scan(&req.ConversationID, &req.InviteCode, &req.CreatedAt,
+			&req.User.ID, &req.User.Email, &req.User.Username, &req.User.AvatarURL,
+			&req.User.Status, &req.User.CreatedAt, &req.User.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, req)
+	}
+	return requests, rows.Err()
+}
+
+// GetApproverIDs returns the participants who can approve join requests to a group
+func (r *Repository) GetApproverIDs(ctx context.Context, convID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT user_id, role FROM conversation_participants WHERE conversation_id = $1
+	`, convID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		var role string
+		if err := rows.Scan(&id, &role); err != nil {
+			return nil, err
+		}
+		if RoleCan(role, PermApproveJoins) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, rows.Err()
+}
+
+// ApproveJoinRequest adds the requesting user to the group. Like joining through a link
+// directly, it lifts their opt-out from being re-added; bans still apply.
+func (r *Repository) ApproveJoinRequest(ctx context.Context, convID, actorID, targetID uuid.UUID) error {
+	if err := r.CheckPermission(ctx, convID, actorID, PermApproveJoins); err != nil {
+		return err
+	}
+	if err := r.checkBans(ctx, convID, []uuid.UUID{targetID}); err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var code *string
+	err = tx.QueryRow(ctx, `
+		DELETE FROM group_join_requests WHERE conversation_id = $1 AND user_id = $2
+		RETURNING invite_code
+	`, convID, targetID).Scan(&code)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrJoinRequestNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := r.reserveGroupSeats(ctx, tx, convID, []uuid.UUID{targetID}); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO conversation_participants (conversation_id, user_id) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, convID, targetID)
+	if err != nil {
+		return err
+	}
+
+	if code != nil {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO group_invite_link_uses (code, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING
+		`, *code, targetID)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, table := range []string{"group_readd_blocks", "group_invites"} {
+		_, err = tx.Exec(ctx, `DELETE FROM `+table+` WHERE conversation_id = $1 AND user_id = $2`, convID, targetID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// DenyJoinRequest drops a pending join request
+func (r *Repository) DenyJoinRequest(ctx context.Context, convID, actorID, targetID uuid.UUID) error {
+	if err := r.CheckPermission(ctx, convID, actorID, PermApproveJoins); err != nil {
+		return err
+	}
+
+	result, err := r.db.Exec(ctx, `
+		DELETE FROM group_join_requests WHERE conversation_id = $1 AND user_id = $2
+	`, convID, targetID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrJoinRequestNotFound
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package messages
+
+import (
+	"regexp"
+	"strings"
+)
+
+// mentionPattern matches @username (usernames are 3-32 alphanumeric chars)
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([A-Za-z0-9]{3,32})\b`)
+
+// ParseMentions extracts unique usernames mentioned in message content
+func ParseMentions(content string) []string {
+	matches := mentionPattern.FindAllStringSubmatch(content, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var usernames []string
+	for _, m := range matches {
+		name := strings.ToLower(m[1])
+		if !seen[name] {
+			seen[name] = true
+			usernames = append(usernames, m[1])
+		}
+	}
+	return usernames
+}
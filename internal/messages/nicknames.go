@@ -0,0 +1,27 @@
+package messages
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// SetNickname sets how a participant is shown in the conversation. Any participant can
+// name themselves or others; an empty nickname clears it.
+func (r *Repository) SetNickname(ctx context.Context, convID, actorID, targetID uuid.UUID, nickname string) error {
+	if _, err := r.participantRole(ctx, convID, actorID); err != nil {
+		return err
+	}
+
+	result, err := r.db.Exec(ctx, `
+		UPDATE conversation_participants SET nickname = NULLIF($3, '')
+		WHERE conversation_id = $1 AND user_id = $2
+	`, convID, targetID, nickname)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrParticipantNotFound
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package messages
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/user/bla-back/internal/models"
+)
+
+// ErrNonceReused is returned when a nonce was already used for a message in another conversation
+var ErrNonceReused = errors.New("nonce was already used in another conversation")
+
+// SendMessageOnce is SendMessageWithAttachments keyed by a client nonce, so flaky networks
+// don't produce duplicates: a retry returns the message the first attempt created
+// (created = false). A nil nonce always sends.
+func (r *Repository) SendMessageOnce(ctx context.Context, convID, senderID uuid.UUID, content string, attachmentIDs []uuid.UUID, nonce *string) (msg *models.Message, created bool, err error) {
+	return r.sendOnce(ctx, convID, senderID, nonce, func() (*models.Message, error) {
+		return r.sendMessage(ctx, convID, senderID, content, attachmentIDs, nonce)
+	})
+}
+
+// sendOnce returns the sender's message with this nonce if there is one, and calls send otherwise.
+// Two concurrent attempts are settled by the unique (sender_id, client_nonce) index.
+func (r *Repository) sendOnce(ctx context.Context, convID, senderID uuid.UUID, nonce *string, send func() (*models.Message, error)) (*models.Message, bool, error) {
+	if nonce == nil {
+		msg, err := send()
+		return msg, err == nil, err
+	}
+
+	msg, err := r.messageByNonce(ctx, convID, senderID, *nonce)
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return msg, false, err
+	}
+
+	msg, err = send()
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "idx_messages_sender_nonce" {
+		msg, err = r.messageByNonce(ctx, convID, senderID, *nonce)
+		return msg, false, err
+	}
+	return msg, err == nil, err
+}
+
+// messageByNonce loads the message a sender created with a nonce (pgx.ErrNoRows if none)
+func (r *Repository) messageByNonce(ctx context.Context, convID, senderID uuid.UUID, nonce string) (*models.Message, error) {
+	msg := &models.Message{Sender: &models.User{}}
+	err := r.db.QueryRow(ctx, `
+		SELECT m.id, m.conversation_id, m.sender_id, COALESCE(m.type, 'text'), m.content, m.client_nonce, m.language, m.expires_at, m.deleted_at, m.created_at, m.updated_at,
+			   u.id, u.email, u.username, u.avatar_url, u.status, u.created_at, u.updated_at, sp.nickname
+		FROM messages m
+		JOIN users u ON m.sender_id = u.id
+		LEFT JOIN conversation_participants sp ON sp.conversation_id = m.conversation_id AND sp.user_id = m.sender_id
+		WHERE m.sender_id = $1 AND m.client_nonce = $2
+	`, senderID, nonce).Scan(
+		&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.Type, &msg.Content, &msg.Nonce, &msg.Language, &msg.ExpiresAt, &msg.DeletedAt, &msg.CreatedAt, &msg.UpdatedAt,
+		&msg.Sender.ID, &msg.Sender.Email, &msg.Sender.Username, &msg.Sender.AvatarURL, &msg.Sender.Status, &msg.Sender.CreatedAt, &msg.Sender.UpdatedAt, &msg.Sender.Nickname,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if msg.ConversationID != convID {
+		return nil, ErrNonceReused
+	}
+
+	msg.Attachments = r.loadAttachments(ctx, msg.ID)
+	msg.Reactions = r.loadReactions(ctx, msg.ID)
+	msg.Mentions = r.loadMentions(ctx, msg.ID)
+	msg.Embeds = r.loadEmbeds(ctx, msg.ID)
+	msg.Sticker = r.loadSticker(ctx, msg)
+	return msg, nil
+}
@@ -0,0 +1,52 @@
+package messages
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// SetPinned pins or unpins a conversation in the user's list
+func (r *Repository) SetPinned(ctx context.Context, convID, userID uuid.UUID, pinned bool) error {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE conversation_participants
+		SET pinned_at = CASE WHEN $3 THEN COALESCE(pinned_at, NOW()) ELSE NULL END
+		WHERE conversation_id = $1 AND user_id = $2
+	`, convID, userID, pinned)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotParticipant
+	}
+	return nil
+}
+
+// SetConversationOrder stores the user's custom order. Conversations not in convIDs lose their position.
+func (r *Repository) SetConversationOrder(ctx context.Context, userID uuid.UUID, convIDs []uuid.UUID) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		UPDATE conversation_participants SET sort_order = NULL
+		WHERE user_id = $1 AND sort_order IS NOT NULL
+	`, userID)
+	if err != nil {
+		return err
+	}
+
+	// Positions follow the array order; IDs the user isn't part of are ignored
+	_, err = tx.Exec(ctx, `
+		UPDATE conversation_participants cp SET sort_order = o.position
+		FROM UNNEST($2::uuid[]) WITH ORDINALITY AS o(conversation_id, position)
+		WHERE cp.user_id = $1 AND cp.conversation_id = o.conversation_id
+	`, userID, convIDs)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
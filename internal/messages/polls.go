@@ -0,0 +1,275 @@
+package messages
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/user/bla-back/internal/models"
+)
+
+var (
+	ErrPollNotFound      = errors.New("poll not found")
+	ErrPollClosed        = errors.New("poll is closed")
+	ErrInvalidPollOption = errors.New("invalid poll option")
+	ErrNotPollAuthor     = errors.New("only the poll author can close it")
+)
+
+// CreatePoll sends a poll message with its options
+func (r *Repository) CreatePoll(ctx context.Context, convID, senderID uuid.UUID, question string, options []string, multipleChoice bool) (*models.Message, error) {
+	// Verify participant
+	var exists bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS(`+activeParticipant+`)
+	`, convID, senderID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotParticipant
+	}
+	if err := r.checkDMBlock(ctx, convID, senderID); err != nil {
+		return nil, err
+	}
+	if err := r.checkMessageRequest(ctx, convID, senderID); err != nil {
+		return nil, err
+	}
+	if err := r.checkAnnouncementOnly(ctx, convID, senderID); err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// The question doubles as message content so previews and search keep working
+	msg := &models.Message{}
+	var nickname *string
+	err = tx.QueryRow(ctx, `
+		INSERT INTO messages (conversation_id, sender_id, type, content, expires_at)
+		VALUES ($1, $2, 'poll', $3, `+messageExpiresAt+`)
+		RETURNING id, conversation_id, sender_id, type, content, expires_at, created_at, updated_at, `+senderNickname+`
+	`, convID, senderID, question).Scan(
+		&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.Type, &msg.Content, &msg.ExpiresAt, &msg.CreatedAt, &msg.UpdatedAt, &nickname,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO polls (message_id, question, multiple_choice) VALUES ($1, $2, $3)
+	`, msg.ID, question, multipleChoice)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, text := range options {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO poll_options (message_id, position, text) VALUES ($1, $2, $3)
+		`, msg.ID, i, text)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE conversations SET updated_at = NOW() WHERE id = $1`, convID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	// Get sender info
+	msg.Sender = r.loadSender(ctx, senderID, nickname)
+
+	msg.Attachments = []*models.Attachment{}
+	msg.Reactions = []*models.Reaction{}
+	msg.Poll, err = r.GetPoll(ctx, msg.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// GetPoll loads a poll with tallied results
+func (r *Repository) GetPoll(ctx context.Context, messageID uuid.UUID) (*models.Poll, error) {
+	poll := &models.Poll{MessageID: messageID}
+	err := r.db.QueryRow(ctx, `
+		SELECT p.question, p.multiple_choice, p.closed_at, m.sender_id
+		FROM polls p
+		JOIN messages m ON m.id = p.message_id
+		WHERE p.message_id = $1
+	`, messageID).Scan(&poll.Question, &poll.MultipleChoice, &poll.ClosedAt, &poll.AuthorID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrPollNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT o.id, o.text,
+			   COALESCE(ARRAY_AGG(v.user_id ORDER BY v.created_at) FILTER (WHERE v.user_id IS NOT NULL), '{}')
+		FROM poll_options o
+		LEFT JOIN poll_votes v ON v.option_id = o.id
+		WHERE o.message_id = $1
+		GROUP BY o.id, o.text, o.position
+		ORDER BY o.position
+	`, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	voters := make(map[uuid.UUID]bool)
+	for rows.Next() {
+		opt := &models.PollOption{}
+		if err := rows.Scan(&opt.ID, &opt.Text, &opt.VoterIDs); err != nil {
+			return nil, err
+		}
+		opt.Votes = len(opt.VoterIDs)
+		for _, id := range opt.VoterIDs {
+			voters[id] = true
+		}
+		poll.Options = append(poll.Options, opt)
+	}
+	poll.TotalVoters = len(voters)
+
+	return poll, rows.Err()
+}
+
+// loadPoll loads the poll for poll messages (nil otherwise)
+func (r *Repository) loadPoll(ctx context.Context, msg *models.Message) *models.Poll {
+	if msg.Type != "poll" {
+		return nil
+	}
+	poll, err := r.GetPoll(ctx, msg.ID)
+	if err != nil {
+		return nil
+	}
+	return poll
+}
+
+// pollForVoting checks the user can vote on a poll and returns whether it is multiple choice
+func (r *Repository) pollForVoting(ctx context.Context, convID, messageID, userID uuid.UUID) (bool, error) {
+	var isParticipant bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS(`+activeParticipant+`)
+	`, convID, userID).Scan(&isParticipant)
+	if err != nil {
+		return false, err
+	}
+	if !isParticipant {
+		return false, ErrNotParticipant
+	}
+
+	var multipleChoice, closed bool
+	err = r.db.QueryRow(ctx, `
+		SELECT p.multiple_choice, p.closed_at IS NOT NULL
+		FROM polls p
+		JOIN messages m ON m.id = p.message_id
+		WHERE p.message_id = $1 AND m.conversation_id = $2
+	`, messageID, convID).Scan(&multipleChoice, &closed)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, ErrPollNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+	if closed {
+		return false, ErrPollClosed
+	}
+	return multipleChoice, nil
+}
+
+// Vote records a vote for an option. In single-choice polls it replaces the user's previous vote.
+func (r *Repository) Vote(ctx context.Context, convID, messageID, userID, optionID uuid.UUID) (*models.Poll, error) {
+	multipleChoice, err := r.pollForVoting(ctx, convID, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var validOption bool
+	err = r.db.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM poll_options WHERE id = $1 AND message_id = $2)
+	`, optionID, messageID).Scan(&validOption)
+	if err != nil {
+		return nil, err
+	}
+	if !validOption {
+		return nil, ErrInvalidPollOption
+	}
+
+	if multipleChoice {
+		_, err = r.db.Exec(ctx, `
+			INSERT INTO poll_votes (message_id, option_id, user_id)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (option_id, user_id) DO NOTHING
+		`, messageID, optionID, userID)
+	} else {
+		// A single upsert on the one-vote-per-user index, so concurrent votes can't both land
+		_, err = r.db.Exec(ctx, `
+			INSERT INTO poll_votes (message_id, option_id, user_id, single_choice)
+			VALUES ($1, $2, $3, TRUE)
+			ON CONFLICT (message_id, user_id) WHERE single_choice
+			DO UPDATE SET option_id = EXCLUDED.option_id, created_at = NOW()
+			WHERE poll_votes.option_id <> EXCLUDED.option_id
+		`, messageID, optionID, userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetPoll(ctx, messageID)
+}
+
+// Unvote removes the user's vote for an option
+func (r *Repository) Unvote(ctx context.Context, convID, messageID, userID, optionID uuid.UUID) (*models.Poll, error) {
+	if _, err := r.pollForVoting(ctx, convID, messageID, userID); err != nil {
+		return nil, err
+	}
+
+	_, err := r.db.Exec(ctx, `
+		DELETE FROM poll_votes WHERE message_id = $1 AND option_id = $2 AND user_id = $3
+	`, messageID, optionID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetPoll(ctx, messageID)
+}
+
+// ClosePoll stops accepting votes. Only the poll author can close it.
+func (r *Repository) ClosePoll(ctx context.Context, convID, messageID, userID uuid.UUID) (*models.Poll, error) {
+	var authorID uuid.UUID
+	err := r.db.QueryRow(ctx, `
+		SELECT m.sender_id
+		FROM polls p
+		JOIN messages m ON m.id = p.message_id
+		WHERE p.message_id = $1 AND m.conversation_id = $2
+	`, messageID, convID).Scan(&authorID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrPollNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if authorID != userID {
+		return nil, ErrNotPollAuthor
+	}
+
+	_, err = r.db.Exec(ctx, `
+		UPDATE polls SET closed_at = COALESCE(closed_at, NOW()) WHERE message_id = $1
+	`, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetPoll(ctx, messageID)
+}
@@ -3,25 +3,38 @@ package messages
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/user/bla-back/internal/models"
+	"github.com/user/bla-back/internal/purge"
 )
 
+// messageExpiresAt computes expires_at for a new message from its conversation's TTL ($1 = conversation_id)
+const messageExpiresAt = `(SELECT NOW() + c.message_ttl_seconds * INTERVAL '1 second' FROM conversations c WHERE c.id = $1)`
+
 var (
 	ErrConversationNotFound = errors.New("conversation not found")
 	ErrNotParticipant       = errors.New("not a participant of this conversation")
 	ErrMessageNotFound      = errors.New("message not found")
+	ErrNestedThread         = errors.New("cannot start a thread on a thread reply")
 )
 
 type Repository struct {
 	db *pgxpool.Pool
+
+	// most participants a group can have (0 = unlimited)
+	maxGroupSize int
+
+	users UserLookup // nil = users are queried directly, see SetUserLookup
+
+	files purge.FileDeleter // nil = files of cleared messages are kept, see SetFileDeleter
 }
 
-func NewRepository(db *pgxpool.Pool) *Repository {
-	return &Repository{db: db}
+func NewRepository(db *pgxpool.Pool, maxGroupSize int) *Repository {
+	return &Repository{db: db, maxGroupSize: maxGroupSize}
 }
 
 // GetOrCreateDM gets existing DM or creates a new one
@@ -36,6 +49,14 @@ func (r *Repository) GetOrCreateDM(ctx context.Context, userA, userB uuid.UUID)
 	`, userA, userB).Scan(&convID)
 
 	if err == nil {
+		// A message request between users who became friends since is settled
+		_, err = r.db.Exec(ctx, `
+			UPDATE conversations SET request_status = NULL
+			WHERE id = $3 AND request_status IS NOT NULL AND `+areFriends("$1", "$2")+`
+		`, userA, userB, convID)
+		if err != nil {
+			return nil, err
+		}
 		return r.GetConversation(ctx, convID, userA)
 	}
 
@@ -43,6 +64,25 @@ func (r *Repository) GetOrCreateDM(ctx context.Context, userA, userB uuid.UUID)
 		return nil, err
 	}
 
+	var blocked bool
+	if err := r.db.QueryRow(ctx, `SELECT EXISTS(`+blockedPair+`)`, userA, userB).Scan(&blocked); err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, ErrBlocked
+	}
+
+	// A DM with someone who isn't a friend starts as a message request
+	var friends bool
+	if err := r.db.QueryRow(ctx, `SELECT `+areFriends("$1", "$2"), userA, userB).Scan(&friends); err != nil {
+		return nil, err
+	}
+	var requestStatus *string
+	if !friends {
+		pending := RequestPending
+		requestStatus = &pending
+	}
+
 	// Create new DM
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
@@ -51,8 +91,8 @@ func (r *Repository) GetOrCreateDM(ctx context.Context, userA, userB uuid.UUID)
 	defer tx.Rollback(ctx)
 
 	err = tx.QueryRow(ctx, `
-		INSERT INTO conversations (type) VALUES ('dm') RETURNING id
-	`).Scan(&convID)
+		INSERT INTO conversations (type, request_status, requested_by) VALUES ('dm', $1, $2) RETURNING id
+	`, requestStatus, userA).Scan(&convID)
 	if err != nil {
 		return nil, err
 	}
@@ -76,7 +116,7 @@ func (r *Repository) GetConversation(ctx context.Context, convID, userID uuid.UU
 	// Verify user is participant
 	var exists bool
 	err := r.db.QueryRow(ctx, `
-		SELECT EXISTS(SELECT 1 FROM conversation_participants WHERE conversation_id = $1 AND user_id = $2)
+		SELECT EXISTS(`+activeParticipant+`)
 	`, convID, userID).Scan(&exists)
 	if err != nil {
 		return nil, err
@@ -87,8 +127,8 @@ func (r *Repository) GetConversation(ctx context.Context, convID, userID uuid.UU
 
 	conv := &models.Conversation{}
 	err = r.db.QueryRow(ctx, `
-		SELECT id, type, name, avatar_url, owner_id, created_at, updated_at FROM conversations WHERE id = $1
-	`, convID).Scan(&conv.ID, &conv.Type, &conv.Name, &conv.AvatarURL, &conv.OwnerID, &conv.CreatedAt, &conv.UpdatedAt)
+		SELECT id, type, name, description, join_approval, is_public, announcement_only, call_permission, avatar_url, owner_id, message_ttl_seconds, request_status, created_at, updated_at FROM conversations WHERE id = $1
+	`, convID).Scan(&conv.ID, &conv.Type, &conv.Name, &conv.Description, &conv.JoinApproval, &conv.Public, &conv.AnnouncementOnly, &conv.CallPermission, &conv.AvatarURL, &conv.OwnerID, &conv.MessageTTL, &conv.RequestStatus, &conv.CreatedAt, &conv.UpdatedAt)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, ErrConversationNotFound
 	}
@@ -98,7 +138,7 @@ func (r *Repository) GetConversation(ctx context.Context, convID, userID uuid.UU
 
 	// Get participants
 	rows, err := r.db.Query(ctx, `
-		SELECT u.id, u.email, u.username, u.avatar_url, u.status, u.created_at, u.updated_at
+		SELECT u.id, u.email, u.username, u.avatar_url, u.status, u.created_at, u.updated_at, cp.role, cp.nickname
 		FROM users u
 		JOIN conversation_participants cp ON u.id = cp.user_id
 		WHERE cp.conversation_id = $1
@@ -110,21 +150,25 @@ func (r *Repository) GetConversation(ctx context.Context, convID, userID uuid.UU
 
 	for rows.Next() {
 		user := &models.User{}
-		err := rows.Scan(&user.ID, &user.Email, &user.Username, &user.AvatarURL, &user.Status, &user.CreatedAt, &user.UpdatedAt)
+		err := rows.Scan(&user.ID, &user.Email, &user.Username, &user.AvatarURL, &user.Status, &user.CreatedAt, &user.UpdatedAt, &user.Role, &user.Nickname)
 		if err != nil {
 			return nil, err
 		}
+		// Presence isn't shared until a message request is accepted
+		if conv.RequestStatus != nil && user.ID != userID {
+			user.Status = "offline"
+		}
 		conv.Participants = append(conv.Participants, user)
 	}
 
 	// Get last message
 	lastMsg := &models.Message{}
 	err = r.db.QueryRow(ctx, `
-		SELECT m.id, m.conversation_id, m.sender_id, m.content, m.created_at, m.updated_at
+		SELECT m.id, m.conversation_id, m.sender_id, m.content, m.deleted_at, m.created_at, m.updated_at
 		FROM messages m
-		WHERE m.conversation_id = $1
+		WHERE m.conversation_id = $1 AND m.parent_id IS NULL AND `+notClearedFor("$1", "$2")+`
 		ORDER BY m.created_at DESC LIMIT 1
-	`, convID).Scan(&lastMsg.ID, &lastMsg.ConversationID, &lastMsg.SenderID, &lastMsg.Content, &lastMsg.CreatedAt, &lastMsg.UpdatedAt)
+	`, convID, userID).Scan(&lastMsg.ID, &lastMsg.ConversationID, &lastMsg.SenderID, &lastMsg.Content, &lastMsg.DeletedAt, &lastMsg.CreatedAt, &lastMsg.UpdatedAt)
 	if err == nil {
 		conv.LastMessage = lastMsg
 	}
@@ -132,14 +176,38 @@ func (r *Repository) GetConversation(ctx context.Context, convID, userID uuid.UU
 	return conv, nil
 }
 
-// GetUserConversations gets all conversations for a user
+// GetUserConversations gets all conversations for a user, with participants and the
+// last message, in a single round-trip
 func (r *Repository) GetUserConversations(ctx context.Context, userID uuid.UUID) ([]*models.ConversationWithDetails, error) {
+	// cp is the user's own participant row, so cp.cleared_before hides what they cleared
 	rows, err := r.db.Query(ctx, `
-		SELECT DISTINCT c.id, c.type, c.name, c.avatar_url, c.owner_id, c.updated_at
-		FROM conversations c
-		JOIN conversation_participants cp ON c.id = cp.conversation_id
-		WHERE cp.user_id = $1
-		ORDER BY c.updated_at DESC
+		SELECT c.id, c.type, c.name, c.description, c.join_approval, c.is_public, c.announcement_only, c.call_permission, c.avatar_url, c.owner_id, c.message_ttl_seconds, c.request_status, c.updated_at,
+			   cp.pinned_at IS NOT NULL, cp.sort_order,
+			   p.participants,
+			   lm.id, lm.conversation_id, lm.sender_id, lm.content, lm.deleted_at, lm.created_at, lm.updated_at
+		FROM conversation_participants cp
+		JOIN conversations c ON c.id = cp.conversation_id
+		CROSS JOIN LATERAL (
+			SELECT COALESCE(json_agg(json_build_object(
+				'id', u.id, 'email', u.email, 'username', u.username, 'avatar_url', u.avatar_url,
+				'status', CASE WHEN c.request_status IS NOT NULL AND u.id != $1 THEN 'offline' ELSE u.status END,
+				'created_at', u.created_at, 'updated_at', u.updated_at, 'role', pp.role,
+				'nickname', pp.nickname
+			)), '[]'::json) AS participants
+			FROM conversation_participants pp
+			JOIN users u ON u.id = pp.user_id
+			WHERE pp.conversation_id = c.id
+		) p
+		LEFT JOIN LATERAL (
+			SELECT m.id, m.conversation_id, m.sender_id, m.content, m.deleted_at, m.created_at, m.updated_at
+			FROM messages m
+			WHERE m.conversation_id = c.id AND m.parent_id IS NULL
+			  AND m.created_at > COALESCE(cp.cleared_before, '-infinity')
+			ORDER BY m.created_at DESC
+			LIMIT 1
+		) lm ON TRUE
+		WHERE cp.user_id = $1 AND c.deleted_at IS NULL AND NOT `+awaitingRequest("c.id", "$1")+`
+		ORDER BY cp.pinned_at IS NULL, cp.sort_order NULLS LAST, cp.pinned_at, c.updated_at DESC
 	`, userID)
 	if err != nil {
 		return nil, err
@@ -149,50 +217,34 @@ func (r *Repository) GetUserConversations(ctx context.Context, userID uuid.UUID)
 	var conversations []*models.ConversationWithDetails
 	for rows.Next() {
 		conv := &models.ConversationWithDetails{}
-		err := rows.Scan(&conv.ID, &conv.Type, &conv.Name, &conv.AvatarURL, &conv.OwnerID, &conv.UpdatedAt)
-		if err != nil {
-			return nil, err
-		}
-		conversations = append(conversations, conv)
-	}
-
-	// Load participants and last message for each
-	for _, conv := range conversations {
-		// Participants
-		pRows, err := r.db.Query(ctx, `
-			SELECT u.id, u.email, u.username, u.avatar_url, u.status, u.created_at, u.updated_at
-			FROM users u
-			JOIN conversation_participants cp ON u.id = cp.user_id
-			WHERE cp.conversation_id = $1
-		`, conv.ID)
+		var (
+			lastID, lastConvID, lastSenderID *uuid.UUID
+			lastContent                      *string
+			lastDeletedAt                    *time.Time
+			lastCreatedAt, lastUpdatedAt     *time.Time
+		)
+		err := rows.Scan(&conv.ID, &conv.Type, &conv.Name, &conv.Description, &conv.JoinApproval, &conv.Public, &conv.AnnouncementOnly, &conv.CallPermission, &conv.AvatarURL, &conv.OwnerID, &conv.MessageTTL, &conv.RequestStatus, &conv.UpdatedAt,
+			&conv.Pinned, &conv.SortOrder,
+			&conv.Participants,
+			&lastID, &lastConvID, &lastSenderID, &lastContent, &lastDeletedAt, &lastCreatedAt, &lastUpdatedAt)
 		if err != nil {
 			return nil, err
 		}
-
-		for pRows.Next() {
-			user := &models.User{}
-			err := pRows.Scan(&user.ID, &user.Email, &user.Username, &user.AvatarURL, &user.Status, &user.CreatedAt, &user.UpdatedAt)
-			if err != nil {
-				pRows.Close()
-				return nil, err
+		if lastID != nil {
+			conv.LastMessage = &models.Message{
+				ID:             *lastID,
+				ConversationID: *lastConvID,
+				SenderID:       *lastSenderID,
+				Content:        *lastContent,
+				DeletedAt:      lastDeletedAt,
+				CreatedAt:      *lastCreatedAt,
+				UpdatedAt:      *lastUpdatedAt,
 			}
-			conv.Participants = append(conv.Participants, user)
-		}
-		pRows.Close()
-
-		// Last message
-		lastMsg := &models.Message{}
-		err = r.db.QueryRow(ctx, `
-			SELECT m.id, m.conversation_id, m.sender_id, m.content, m.created_at, m.updated_at
-			FROM messages m WHERE m.conversation_id = $1
-			ORDER BY m.created_at DESC LIMIT 1
-		`, conv.ID).Scan(&lastMsg.ID, &lastMsg.ConversationID, &lastMsg.SenderID, &lastMsg.Content, &lastMsg.CreatedAt, &lastMsg.UpdatedAt)
-		if err == nil {
-			conv.LastMessage = lastMsg
 		}
+		conversations = append(conversations, conv)
 	}
 
-	return conversations, nil
+	return conversations, rows.Err()
 }
 
 // GetMessages gets messages for a conversation
@@ -200,12 +252,16 @@ func (r *Repository) GetMessages(ctx context.Context, convID, userID uuid.UUID,
 	// Single query: verify participant and get messages at once
 	// If user is not a participant, this returns 0 rows
 	rows, err := r.db.Query(ctx, `
-		SELECT m.id, m.conversation_id, m.sender_id, COALESCE(m.type, 'text'), m.content, m.created_at, m.updated_at,
-			   u.id, u.email, u.username, u.avatar_url, u.status, u.created_at, u.updated_at
+		SELECT m.id, m.conversation_id, m.sender_id, COALESCE(m.type, 'text'), m.content, m.language, m.expires_at, m.deleted_at, m.created_at, m.updated_at,
+			   (SELECT COUNT(*) FROM messages r WHERE r.parent_id = m.id AND r.deleted_at IS NULL),
+			   u.id, u.email, u.username, u.avatar_url, u.status, u.created_at, u.updated_at, sp.nickname
 		FROM messages m
 		JOIN users u ON m.sender_id = u.id
-		WHERE m.conversation_id = $1
-		  AND EXISTS(SELECT 1 FROM conversation_participants WHERE conversation_id = $1 AND user_id = $2)
+		LEFT JOIN conversation_participants sp ON sp.conversation_id = m.conversation_id AND sp.user_id = m.sender_id
+		WHERE m.conversation_id = $1 AND m.parent_id IS NULL
+		  AND EXISTS(`+activeParticipant+`)
+		  AND NOT EXISTS(SELECT 1 FROM conversation_ignores WHERE conversation_id = $1 AND user_id = $2 AND ignored_user_id = m.sender_id)
+		  AND `+notClearedFor("$1", "$2")+`
 		ORDER BY m.created_at DESC
 		LIMIT $3 OFFSET $4
 	`, convID, userID, limit, offset)
@@ -218,8 +274,9 @@ func (r *Repository) GetMessages(ctx context.Context, convID, userID uuid.UUID,
 	for rows.Next() {
 		msg := &models.Message{Sender: &models.User{}}
 		err := rows.Scan(
-			&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.Type, &msg.Content, &msg.CreatedAt, &msg.UpdatedAt,
-			&msg.Sender.ID, &msg.Sender.Email, &msg.Sender.Username, &msg.Sender.AvatarURL, &msg.Sender.Status, &msg.Sender.CreatedAt, &msg.Sender.UpdatedAt,
+			&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.Type, &msg.Content, &msg.Language, &msg.ExpiresAt, &msg.DeletedAt, &msg.CreatedAt, &msg.UpdatedAt,
+			&msg.ReplyCount,
+			&msg.Sender.ID, &msg.Sender.Email, &msg.Sender.Username, &msg.Sender.AvatarURL, &msg.Sender.Status, &msg.Sender.CreatedAt, &msg.Sender.UpdatedAt, &msg.Sender.Nickname,
 		)
 		if err != nil {
 			return nil, err
@@ -231,6 +288,10 @@ func (r *Repository) GetMessages(ctx context.Context, convID, userID uuid.UUID,
 	for _, msg := range messages {
 		msg.Attachments = r.loadAttachments(ctx, msg.ID)
 		msg.Reactions = r.loadReactions(ctx, msg.ID)
+		msg.Mentions = r.loadMentions(ctx, msg.ID)
+		msg.Embeds = r.loadEmbeds(ctx, msg.ID)
+		msg.Poll = r.loadPoll(ctx, msg)
+		msg.Sticker = r.loadSticker(ctx, msg)
 	}
 
 	// Reverse to get chronological order
@@ -246,7 +307,7 @@ func (r *Repository) SendMessage(ctx context.Context, convID, senderID uuid.UUID
 	// Verify participant
 	var exists bool
 	err := r.db.QueryRow(ctx, `
-		SELECT EXISTS(SELECT 1 FROM conversation_participants WHERE conversation_id = $1 AND user_id = $2)
+		SELECT EXISTS(`+activeParticipant+`)
 	`, convID, senderID).Scan(&exists)
 	if err != nil {
 		return nil, err
@@ -254,14 +315,24 @@ func (r *Repository) SendMessage(ctx context.Context, convID, senderID uuid.UUID
 	if !exists {
 		return nil, ErrNotParticipant
 	}
+	if err := r.checkDMBlock(ctx, convID, senderID); err != nil {
+		return nil, err
+	}
+	if err := r.checkMessageRequest(ctx, convID, senderID); err != nil {
+		return nil, err
+	}
+	if err := r.checkAnnouncementOnly(ctx, convID, senderID); err != nil {
+		return nil, err
+	}
 
 	msg := &models.Message{}
+	var nickname *string
 	err = r.db.QueryRow(ctx, `
-		INSERT INTO messages (conversation_id, sender_id, content)
-		VALUES ($1, $2, $3)
-		RETURNING id, conversation_id, sender_id, content, created_at, updated_at
+		INSERT INTO messages (conversation_id, sender_id, content, expires_at)
+		VALUES ($1, $2, $3, `+messageExpiresAt+`)
+		RETURNING id, conversation_id, sender_id, content, expires_at, created_at, updated_at, `+senderNickname+`
 	`, convID, senderID, content).Scan(
-		&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.Content, &msg.CreatedAt, &msg.UpdatedAt,
+		&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.Content, &msg.ExpiresAt, &msg.CreatedAt, &msg.UpdatedAt, &nickname,
 	)
 	if err != nil {
 		return nil, err
@@ -271,13 +342,7 @@ func (r *Repository) SendMessage(ctx context.Context, convID, senderID uuid.UUID
 	_, _ = r.db.Exec(ctx, `UPDATE conversations SET updated_at = NOW() WHERE id = $1`, convID)
 
 	// Get sender info
-	msg.Sender = &models.User{}
-	_ = r.db.QueryRow(ctx, `
-		SELECT id, email, username, avatar_url, status, created_at, updated_at
-		FROM users WHERE id = $1
-	`, senderID).Scan(
-		&msg.Sender.ID, &msg.Sender.Email, &msg.Sender.Username, &msg.Sender.AvatarURL, &msg.Sender.Status, &msg.Sender.CreatedAt, &msg.Sender.UpdatedAt,
-	)
+	msg.Sender = r.loadSender(ctx, senderID, nickname)
 
 	return msg, nil
 }
@@ -320,12 +385,32 @@ func (r *Repository) CreateAttachment(ctx context.Context, uploaderID uuid.UUID,
 	return attachment, nil
 }
 
+// CreateGIFAttachment creates a "gif" attachment pointing at the GIF provider's CDN
+func (r *Repository) CreateGIFAttachment(ctx context.Context, uploaderID uuid.UUID, gif *models.GIF) (*models.Attachment, error) {
+	attachment := &models.Attachment{}
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO attachments (uploader_id, type, url, filename, size, width, height)
+		VALUES ($1, 'gif', $2, $3, $4, $5, $6)
+		RETURNING id, type, url, filename, size, width, height, created_at
+	`, uploaderID, gif.URL, gif.ID+".gif", gif.Size, gif.Width, gif.Height).Scan(
+		&attachment.ID, &attachment.Type, &attachment.URL, &attachment.Filename, &attachment.Size, &attachment.Width, &attachment.Height, &attachment.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return attachment, nil
+}
+
 // SendMessageWithAttachments creates a message and links attachments to it
 func (r *Repository) SendMessageWithAttachments(ctx context.Context, convID, senderID uuid.UUID, content string, attachmentIDs []uuid.UUID) (*models.Message, error) {
+	return r.sendMessage(ctx, convID, senderID, content, attachmentIDs, nil)
+}
+
+func (r *Repository) sendMessage(ctx context.Context, convID, senderID uuid.UUID, content string, attachmentIDs []uuid.UUID, nonce *string) (*models.Message, error) {
 	// Verify participant
 	var exists bool
 	err := r.db.QueryRow(ctx, `
-		SELECT EXISTS(SELECT 1 FROM conversation_participants WHERE conversation_id = $1 AND user_id = $2)
+		SELECT EXISTS(`+activeParticipant+`)
 	`, convID, senderID).Scan(&exists)
 	if err != nil {
 		return nil, err
@@ -333,6 +418,15 @@ func (r *Repository) SendMessageWithAttachments(ctx context.Context, convID, sen
 	if !exists {
 		return nil, ErrNotParticipant
 	}
+	if err := r.checkDMBlock(ctx, convID, senderID); err != nil {
+		return nil, err
+	}
+	if err := r.checkMessageRequest(ctx, convID, senderID); err != nil {
+		return nil, err
+	}
+	if err := r.checkAnnouncementOnly(ctx, convID, senderID); err != nil {
+		return nil, err
+	}
 
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
@@ -342,12 +436,13 @@ func (r *Repository) SendMessageWithAttachments(ctx context.Context, convID, sen
 
 	// Create message
 	msg := &models.Message{}
+	var nickname *string
 	err = tx.QueryRow(ctx, `
-		INSERT INTO messages (conversation_id, sender_id, content)
-		VALUES ($1, $2, $3)
-		RETURNING id, conversation_id, sender_id, content, created_at, updated_at
-	`, convID, senderID, content).Scan(
-		&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.Content, &msg.CreatedAt, &msg.UpdatedAt,
+		INSERT INTO messages (conversation_id, sender_id, content, client_nonce, language, expires_at)
+		VALUES ($1, $2, $3, $4, $5, `+messageExpiresAt+`)
+		RETURNING id, conversation_id, sender_id, content, client_nonce, language, expires_at, created_at, updated_at, `+senderNickname+`
+	`, convID, senderID, content, nonce, detectLanguage(content)).Scan(
+		&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.Content, &msg.Nonce, &msg.Language, &msg.ExpiresAt, &msg.CreatedAt, &msg.UpdatedAt, &nickname,
 	)
 	if err != nil {
 		return nil, err
@@ -373,13 +468,7 @@ func (r *Repository) SendMessageWithAttachments(ctx context.Context, convID, sen
 	}
 
 	// Get sender info
-	msg.Sender = &models.User{}
-	_ = r.db.QueryRow(ctx, `
-		SELECT id, email, username, avatar_url, status, created_at, updated_at
-		FROM users WHERE id = $1
-	`, senderID).Scan(
-		&msg.Sender.ID, &msg.Sender.Email, &msg.Sender.Username, &msg.Sender.AvatarURL, &msg.Sender.Status, &msg.Sender.CreatedAt, &msg.Sender.UpdatedAt,
-	)
+	msg.Sender = r.loadSender(ctx, senderID, nickname)
 
 	// Load attachments
 	msg.Attachments = r.loadAttachments(ctx, msg.ID)
@@ -439,12 +528,19 @@ func (r *Repository) CreateGroup(ctx context.Context, creatorID uuid.UUID, name
 			unique = append(unique, id)
 		}
 	}
+	if r.maxGroupSize > 0 && len(unique) > r.maxGroupSize {
+		return nil, &GroupFullError{Max: r.maxGroupSize, Requested: len(unique)}
+	}
 
 	// Add all participants
 	for _, userID := range unique {
+		role := RoleMember
+		if userID == creatorID {
+			role = RoleOwner
+		}
 		_, err = tx.Exec(ctx, `
-			INSERT INTO conversation_participants (conversation_id, user_id) VALUES ($1, $2)
-		`, convID, userID)
+			INSERT INTO conversation_participants (conversation_id, user_id, role) VALUES ($1, $2, $3)
+		`, convID, userID, role)
 		if err != nil {
 			return nil, err
 		}
@@ -459,21 +555,14 @@ func (r *Repository) CreateGroup(ctx context.Context, creatorID uuid.UUID, name
 
 // AddParticipants adds participants to a group conversation
 func (r *Repository) AddParticipants(ctx context.Context, convID, requestingUserID uuid.UUID, userIDs []uuid.UUID) error {
-	// Verify requesting user is a participant
-	var exists bool
-	err := r.db.QueryRow(ctx, `
-		SELECT EXISTS(SELECT 1 FROM conversation_participants WHERE conversation_id = $1 AND user_id = $2)
-	`, convID, requestingUserID).Scan(&exists)
-	if err != nil {
+	// Verify requesting user is a participant allowed to add members
+	if err := r.CheckPermission(ctx, convID, requestingUserID, PermAddMembers); err != nil {
 		return err
 	}
-	if !exists {
-		return ErrNotParticipant
-	}
 
 	// Verify it's a group conversation
 	var convType string
-	err = r.db.QueryRow(ctx, `SELECT type FROM conversations WHERE id = $1`, convID).Scan(&convType)
+	err := r.db.QueryRow(ctx, `SELECT type FROM conversations WHERE id = $1`, convID).Scan(&convType)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return ErrConversationNotFound
@@ -484,9 +573,28 @@ func (r *Repository) AddParticipants(ctx context.Context, convID, requestingUser
 		return errors.New("can only add participants to group conversations")
 	}
 
+	if err := r.checkBans(ctx, convID, userIDs); err != nil {
+		return err
+	}
+
+	// Users who left and opted out can only come back through an invite
+	if err := r.checkReaddBlocks(ctx, convID, userIDs); err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := r.reserveGroupSeats(ctx, tx, convID, userIDs); err != nil {
+		return err
+	}
+
 	// Add each user (ignore if already participant)
 	for _, userID := range userIDs {
-		_, err = r.db.Exec(ctx, `
+		_, err = tx.Exec(ctx, `
 			INSERT INTO conversation_participants (conversation_id, user_id)
 			VALUES ($1, $2)
 			ON CONFLICT DO NOTHING
@@ -496,7 +604,7 @@ func (r *Repository) AddParticipants(ctx context.Context, convID, requestingUser
 		}
 	}
 
-	return nil
+	return tx.Commit(ctx)
 }
 
 // GetGroupParticipants returns all participants of a conversation
@@ -527,30 +635,21 @@ func (r *Repository) GetGroupParticipants(ctx context.Context, convID uuid.UUID)
 
 // UpdateGroupAvatar updates the avatar URL for a group conversation
 func (r *Repository) UpdateGroupAvatar(ctx context.Context, convID, userID uuid.UUID, avatarURL string) error {
-	// Verify user is the owner (or owner is not set for legacy groups)
-	var ownerID *uuid.UUID
-	err := r.db.QueryRow(ctx, `
-		SELECT owner_id FROM conversations WHERE id = $1 AND type = 'group'
-	`, convID).Scan(&ownerID)
+	var convType string
+	err := r.db.QueryRow(ctx, `SELECT type FROM conversations WHERE id = $1`, convID).Scan(&convType)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return ErrConversationNotFound
 		}
 		return err
 	}
-
-	// Allow if owner_id is null (legacy) or user is the owner
-	if ownerID != nil && *ownerID != userID {
-		return errors.New("only the group owner can update the avatar")
+	if convType != "group" {
+		return ErrConversationNotFound
 	}
 
-	// Also verify user is a participant
-	var isParticipant bool
-	_ = r.db.QueryRow(ctx, `
-		SELECT EXISTS(SELECT 1 FROM conversation_participants WHERE conversation_id = $1 AND user_id = $2)
-	`, convID, userID).Scan(&isParticipant)
-	if !isParticipant {
-		return ErrNotParticipant
+	// Owners and admins only
+	if err := r.CheckPermission(ctx, convID, userID, PermEditGroup); err != nil {
+		return err
 	}
 
 	_, err = r.db.Exec(ctx, `
@@ -559,42 +658,43 @@ func (r *Repository) UpdateGroupAvatar(ctx context.Context, convID, userID uuid.
 	return err
 }
 
-// UpdateGroupName updates the name of a group conversation
-func (r *Repository) UpdateGroupName(ctx context.Context, convID, userID uuid.UUID, name string) error {
-	// Verify user is the owner (or owner is not set for legacy groups)
-	var ownerID *uuid.UUID
-	err := r.db.QueryRow(ctx, `
-		SELECT owner_id FROM conversations WHERE id = $1 AND type = 'group'
-	`, convID).Scan(&ownerID)
+// UpdateGroup updates the settings of a group conversation; fields left nil stay unchanged
+// and an empty description clears it
+func (r *Repository) UpdateGroup(ctx context.Context, convID, userID uuid.UUID, update *models.UpdateGroupRequest) error {
+	var convType string
+	err := r.db.QueryRow(ctx, `SELECT type FROM conversations WHERE id = $1`, convID).Scan(&convType)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return ErrConversationNotFound
 		}
 		return err
 	}
-
-	// Allow if owner_id is null (legacy) or user is the owner
-	if ownerID != nil && *ownerID != userID {
-		return errors.New("only the group owner can update the name")
+	if convType != "group" {
+		return ErrConversationNotFound
 	}
 
-	// Also verify user is a participant
-	var isParticipant bool
-	_ = r.db.QueryRow(ctx, `
-		SELECT EXISTS(SELECT 1 FROM conversation_participants WHERE conversation_id = $1 AND user_id = $2)
-	`, convID, userID).Scan(&isParticipant)
-	if !isParticipant {
-		return ErrNotParticipant
+	// Owners and admins only
+	if err := r.CheckPermission(ctx, convID, userID, PermEditGroup); err != nil {
+		return err
 	}
 
 	_, err = r.db.Exec(ctx, `
-		UPDATE conversations SET name = $1, updated_at = NOW() WHERE id = $2
-	`, name, convID)
+		UPDATE conversations SET
+			name = COALESCE($1, name),
+			description = CASE WHEN $2::text IS NULL THEN description ELSE NULLIF($2, '') END,
+			join_approval = COALESCE($3, join_approval),
+			is_public = COALESCE($4, is_public),
+			announcement_only = COALESCE($5, announcement_only),
+			call_permission = COALESCE($6, call_permission),
+			updated_at = NOW()
+		WHERE id = $7
+	`, update.Name, update.Description, update.JoinApproval, update.Public, update.AnnouncementOnly, update.CallPermission, convID)
 	return err
 }
 
-// LeaveGroup removes a user from a group conversation
-func (r *Repository) LeaveGroup(ctx context.Context, convID, userID uuid.UUID) error {
+// LeaveGroup removes a user from a group conversation. With preventReadd, nobody can add
+// them back directly; they have to accept an invite.
+func (r *Repository) LeaveGroup(ctx context.Context, convID, userID uuid.UUID, preventReadd bool) error {
 	// Verify it's a group conversation
 	var convType string
 	err := r.db.QueryRow(ctx, `SELECT type FROM conversations WHERE id = $1`, convID).Scan(&convType)
@@ -608,19 +708,42 @@ func (r *Repository) LeaveGroup(ctx context.Context, convID, userID uuid.UUID) e
 		return errors.New("can only leave group conversations")
 	}
 
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
 	// Remove user from participants
-	result, err := r.db.Exec(ctx, `
+	var role string
+	err = tx.QueryRow(ctx, `
 		DELETE FROM conversation_participants WHERE conversation_id = $1 AND user_id = $2
-	`, convID, userID)
+		RETURNING role
+	`, convID, userID).Scan(&role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotParticipant
+	}
 	if err != nil {
 		return err
 	}
 
-	if result.RowsAffected() == 0 {
-		return ErrNotParticipant
+	if role == RoleOwner {
+		if err := passOwnership(ctx, tx, convID, userID); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	if preventReadd {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO group_readd_blocks (conversation_id, user_id) VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`, convID, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
 }
 
 // GetParticipantIDs returns all participant user IDs for a conversation
@@ -644,29 +767,28 @@ func (r *Repository) GetParticipantIDs(ctx context.Context, conversationID uuid.
 	return ids, nil
 }
 
-// DeleteMessage deletes a message if user is sender or group owner
+// IsParticipant reports whether the user is a member of the conversation
+func (r *Repository) IsParticipant(ctx context.Context, conversationID, userID uuid.UUID) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS(`+activeParticipant+`)
+	`, conversationID, userID).Scan(&exists)
+	return exists, err
+}
+
+// DeleteMessage soft-deletes a message (leaving a tombstone) if user is the sender or
+// their role allows deleting others' messages
 func (r *Repository) DeleteMessage(ctx context.Context, convID, messageID, userID uuid.UUID) error {
 	// Check if user is participant
-	var isParticipant bool
-	err := r.db.QueryRow(ctx, `
-		SELECT EXISTS(SELECT 1 FROM conversation_participants WHERE conversation_id = $1 AND user_id = $2)
-	`, convID, userID).Scan(&isParticipant)
+	role, err := r.participantRole(ctx, convID, userID)
 	if err != nil {
 		return err
 	}
-	if !isParticipant {
-		return ErrNotParticipant
-	}
 
-	// Get message sender and conversation owner
 	var senderID uuid.UUID
-	var ownerID *uuid.UUID
 	err = r.db.QueryRow(ctx, `
-		SELECT m.sender_id, c.owner_id
-		FROM messages m
-		JOIN conversations c ON m.conversation_id = c.id
-		WHERE m.id = $1 AND m.conversation_id = $2
-	`, messageID, convID).Scan(&senderID, &ownerID)
+		SELECT sender_id FROM messages WHERE id = $1 AND conversation_id = $2
+	`, messageID, convID).Scan(&senderID)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return ErrMessageNotFound
@@ -674,17 +796,21 @@ func (r *Repository) DeleteMessage(ctx context.Context, convID, messageID, userI
 		return err
 	}
 
-	// User can delete if they're the sender OR if they're the group owner
-	canDelete := senderID == userID || (ownerID != nil && *ownerID == userID)
-	if !canDelete {
-		return errors.New("you can only delete your own messages")
+	if senderID != userID && !RoleCan(role, PermDeleteMessages) {
+		return ErrPermissionDenied
 	}
 
-	// Delete attachments first (if any)
-	_, _ = r.db.Exec(ctx, `DELETE FROM attachments WHERE message_id = $1`, messageID)
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
 
-	// Delete the message
-	result, err := r.db.Exec(ctx, `DELETE FROM messages WHERE id = $1`, messageID)
+	// Keep the row as a tombstone so ordering and thread replies stay intact
+	result, err := tx.Exec(ctx, `
+		UPDATE messages SET content = '', sticker_id = NULL, deleted_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+	`, messageID)
 	if err != nil {
 		return err
 	}
@@ -693,7 +819,14 @@ func (r *Repository) DeleteMessage(ctx context.Context, convID, messageID, userI
 		return ErrMessageNotFound
 	}
 
-	return nil
+	// Drop everything that carried the message's content
+	for _, table := range []string{"attachments", "reactions", "message_mentions", "message_embeds", "polls", "starred_messages"} {
+		if _, err := tx.Exec(ctx, `DELETE FROM `+table+` WHERE message_id = $1`, messageID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
 }
 
 // AddReaction adds a reaction to a message
@@ -701,7 +834,7 @@ func (r *Repository) AddReaction(ctx context.Context, convID, messageID, userID
 	// Check if user is participant
 	var isParticipant bool
 	err := r.db.QueryRow(ctx, `
-		SELECT EXISTS(SELECT 1 FROM conversation_participants WHERE conversation_id = $1 AND user_id = $2)
+		SELECT EXISTS(`+activeParticipant+`)
 	`, convID, userID).Scan(&isParticipant)
 	if err != nil {
 		return nil, err
@@ -713,7 +846,7 @@ func (r *Repository) AddReaction(ctx context.Context, convID, messageID, userID
 	// Verify message exists in this conversation
 	var exists bool
 	err = r.db.QueryRow(ctx, `
-		SELECT EXISTS(SELECT 1 FROM messages WHERE id = $1 AND conversation_id = $2)
+		SELECT EXISTS(SELECT 1 FROM messages WHERE id = $1 AND conversation_id = $2 AND deleted_at IS NULL)
 	`, messageID, convID).Scan(&exists)
 	if err != nil {
 		return nil, err
@@ -735,14 +868,7 @@ func (r *Repository) AddReaction(ctx context.Context, convID, messageID, userID
 	}
 
 	// Load user info
-	reaction.User = &models.User{}
-	_ = r.db.QueryRow(ctx, `
-		SELECT id, email, username, avatar_url, status, created_at, updated_at
-		FROM users WHERE id = $1
-	`, userID).Scan(
-		&reaction.User.ID, &reaction.User.Email, &reaction.User.Username,
-		&reaction.User.AvatarURL, &reaction.User.Status, &reaction.User.CreatedAt, &reaction.User.UpdatedAt,
-	)
+	reaction.User = r.loadUser(ctx, userID)
 
 	return reaction, nil
 }
@@ -752,7 +878,7 @@ func (r *Repository) RemoveReaction(ctx context.Context, convID, messageID, user
 	// Check if user is participant
 	var isParticipant bool
 	err := r.db.QueryRow(ctx, `
-		SELECT EXISTS(SELECT 1 FROM conversation_participants WHERE conversation_id = $1 AND user_id = $2)
+		SELECT EXISTS(`+activeParticipant+`)
 	`, convID, userID).Scan(&isParticipant)
 	if err != nil {
 		return err
@@ -815,13 +941,47 @@ func (r *Repository) loadReactions(ctx context.Context, messageID uuid.UUID) []*
 
 // CreateCallMessage creates a call system message in a conversation
 func (r *Repository) CreateCallMessage(ctx context.Context, convID, senderID uuid.UUID, content string) (*models.Message, error) {
+	return r.createSystemMessage(ctx, convID, senderID, "call", content)
+}
+
+// CreateCallTranscriptMessage stores the chat of an ended call as a single message
+func (r *Repository) CreateCallTranscriptMessage(ctx context.Context, convID, senderID uuid.UUID, content string) (*models.Message, error) {
+	return r.createSystemMessage(ctx, convID, senderID, "call_transcript", content)
+}
+
+// CreateCallReminderMessage posts a scheduled call reminder (content is CallReminderContent JSON)
+func (r *Repository) CreateCallReminderMessage(ctx context.Context, convID, senderID uuid.UUID, content string) (*models.Message, error) {
+	return r.createSystemMessage(ctx, convID, senderID, "call_reminder", content)
+}
+
+// CreateMembershipMessage records members joining or leaving in the conversation timeline
+// (content is MembershipMessageContent JSON)
+func (r *Repository) CreateMembershipMessage(ctx context.Context, convID, senderID uuid.UUID, content string) (*models.Message, error) {
+	return r.createSystemMessage(ctx, convID, senderID, "membership", content)
+}
+
+// CreateGroupUpdateMessage records a group rename or new avatar in the conversation timeline
+// (content is GroupUpdateMessageContent JSON)
+func (r *Repository) CreateGroupUpdateMessage(ctx context.Context, convID, senderID uuid.UUID, content string) (*models.Message, error) {
+	return r.createSystemMessage(ctx, convID, senderID, "group_update", content)
+}
+
+// createSystemMessage stores a system message after checking its content against the
+// type's schema (see system_schemas.go)
+func (r *Repository) createSystemMessage(ctx context.Context, convID, senderID uuid.UUID, msgType, content string) (*models.Message, error) {
+	content, err := stampSystemContent(msgType, content)
+	if err != nil {
+		return nil, err
+	}
+
 	msg := &models.Message{}
-	err := r.db.QueryRow(ctx, `
-		INSERT INTO messages (conversation_id, sender_id, type, content)
-		VALUES ($1, $2, 'call', $3)
-		RETURNING id, conversation_id, sender_id, type, content, created_at, updated_at
-	`, convID, senderID, content).Scan(
-		&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.Type, &msg.Content, &msg.CreatedAt, &msg.UpdatedAt,
+	var nickname *string
+	err = r.db.QueryRow(ctx, `
+		INSERT INTO messages (conversation_id, sender_id, type, content, expires_at)
+		VALUES ($1, $2, $3, $4, `+messageExpiresAt+`)
+		RETURNING id, conversation_id, sender_id, type, content, expires_at, created_at, updated_at, `+senderNickname+`
+	`, convID, senderID, msgType, content).Scan(
+		&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.Type, &msg.Content, &msg.ExpiresAt, &msg.CreatedAt, &msg.UpdatedAt, &nickname,
 	)
 	if err != nil {
 		return nil, err
@@ -831,16 +991,423 @@ func (r *Repository) CreateCallMessage(ctx context.Context, convID, senderID uui
 	_, _ = r.db.Exec(ctx, `UPDATE conversations SET updated_at = NOW() WHERE id = $1`, convID)
 
 	// Get sender info
-	msg.Sender = &models.User{}
-	_ = r.db.QueryRow(ctx, `
-		SELECT id, email, username, avatar_url, status, created_at, updated_at
-		FROM users WHERE id = $1
-	`, senderID).Scan(
-		&msg.Sender.ID, &msg.Sender.Email, &msg.Sender.Username, &msg.Sender.AvatarURL, &msg.Sender.Status, &msg.Sender.CreatedAt, &msg.Sender.UpdatedAt,
-	)
+	msg.Sender = r.loadSender(ctx, senderID, nickname)
 
 	msg.Attachments = []*models.Attachment{}
 	msg.Reactions = []*models.Reaction{}
 
 	return msg, nil
 }
+
+// GetParticipantsByUsernames returns conversation participants matching the given usernames
+func (r *Repository) GetParticipantsByUsernames(ctx context.Context, convID uuid.UUID, usernames []string) ([]*models.User, error) {
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT u.id, u.email, u.username, u.avatar_url, u.status, u.created_at, u.updated_at
+		FROM users u
+		JOIN conversation_participants cp ON u.id = cp.user_id
+		WHERE cp.conversation_id = $1 AND LOWER(u.username) = ANY(
+			SELECT LOWER(name) FROM UNNEST($2::text[]) AS name
+		)
+	`, convID, usernames)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		user := &models.User{}
+		err := rows.Scan(&user.ID, &user.Email, &user.Username, &user.AvatarURL, &user.Status, &user.CreatedAt, &user.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// SaveMentions stores mentions of conversation participants and attaches them to msg.
+// Returns the mentioned users, excluding the sender.
+func (r *Repository) SaveMentions(ctx context.Context, msg *models.Message, usernames []string) ([]*models.User, error) {
+	users, err := r.GetParticipantsByUsernames(ctx, msg.ConversationID, usernames)
+	if err != nil {
+		return nil, err
+	}
+
+	var mentioned []*models.User
+	for _, user := range users {
+		if user.ID == msg.SenderID {
+			continue
+		}
+		_, err := r.db.Exec(ctx, `
+			INSERT INTO message_mentions (message_id, user_id)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`, msg.ID, user.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		mention := &models.Mention{UserID: user.ID}
+		if user.Username != nil {
+			mention.Username = *user.Username
+		}
+		msg.Mentions = append(msg.Mentions, mention)
+		mentioned = append(mentioned, user)
+	}
+
+	return mentioned, nil
+}
+
+// loadMentions loads mentioned users for a message
+func (r *Repository) loadMentions(ctx context.Context, messageID uuid.UUID) []*models.Mention {
+	rows, err := r.db.Query(ctx, `
+		SELECT u.id, COALESCE(u.username, '')
+		FROM message_mentions mm
+		JOIN users u ON mm.user_id = u.id
+		WHERE mm.message_id = $1
+	`, messageID)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var mentions []*models.Mention
+	for rows.Next() {
+		m := &models.Mention{}
+		if err := rows.Scan(&m.UserID, &m.Username); err != nil {
+			continue
+		}
+		mentions = append(mentions, m)
+	}
+	return mentions
+}
+
+// SaveEmbeds stores link previews for a message
+func (r *Repository) SaveEmbeds(ctx context.Context, messageID uuid.UUID, embeds []*models.Embed) error {
+	for _, e := range embeds {
+		_, err := r.db.Exec(ctx, `
+			INSERT INTO message_embeds (message_id, url, title, description, site_name, image_url)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, messageID, e.URL, e.Title, e.Description, e.SiteName, e.ImageURL)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadEmbeds loads link previews for a message
+func (r *Repository) loadEmbeds(ctx context.Context, messageID uuid.UUID) []*models.Embed {
+	rows, err := r.db.Query(ctx, `
+		SELECT url, title, description, site_name, image_url
+		FROM message_embeds WHERE message_id = $1
+		ORDER BY created_at
+	`, messageID)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var embeds []*models.Embed
+	for rows.Next() {
+		e := &models.Embed{}
+		if err := rows.Scan(&e.URL, &e.Title, &e.Description, &e.SiteName, &e.ImageURL); err != nil {
+			continue
+		}
+		embeds = append(embeds, e)
+	}
+	return embeds
+}
+
+// GetThread gets replies to a message in chronological order
+func (r *Repository) GetThread(ctx context.Context, convID, parentID, userID uuid.UUID, limit, offset int) ([]*models.Message, error) {
+	// Verify participant
+	var exists bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS(`+activeParticipant+`)
+	`, convID, userID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotParticipant
+	}
+
+	// Verify parent message exists in this conversation
+	err = r.db.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM messages WHERE id = $1 AND conversation_id = $2)
+	`, parentID, convID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrMessageNotFound
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT m.id, m.conversation_id, m.sender_id, COALESCE(m.type, 'text'), m.content, m.parent_id, m.expires_at, m.deleted_at, m.created_at, m.updated_at,
+			   u.id, u.email, u.username, u.avatar_url, u.status, u.created_at, u.updated_at, sp.nickname
+		FROM messages m
+		JOIN users u ON m.sender_id = u.id
+		LEFT JOIN conversation_participants sp ON sp.conversation_id = m.conversation_id AND sp.user_id = m.sender_id
+		WHERE m.parent_id = $1
+		  AND NOT EXISTS(SELECT 1 FROM conversation_ignores ci WHERE ci.conversation_id = m.conversation_id AND ci.user_id = $4 AND ci.ignored_user_id = m.sender_id)
+		  AND `+notClearedFor("m.conversation_id", "$4")+`
+		ORDER BY m.created_at ASC
+		LIMIT $2 OFFSET $3
+	`, parentID, limit, offset, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []*models.Message
+	for rows.Next() {
+		msg := &models.Message{Sender: &models.User{}}
+		err := rows.Scan(
+			&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.Type, &msg.Content, &msg.ParentID, &msg.ExpiresAt, &msg.DeletedAt, &msg.CreatedAt, &msg.UpdatedAt,
+			&msg.Sender.ID, &msg.Sender.Email, &msg.Sender.Username, &msg.Sender.AvatarURL, &msg.Sender.Status, &msg.Sender.CreatedAt, &msg.Sender.UpdatedAt, &msg.Sender.Nickname,
+		)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+
+	// Load attachments and reactions for each message
+	for _, msg := range messages {
+		msg.Attachments = r.loadAttachments(ctx, msg.ID)
+		msg.Reactions = r.loadReactions(ctx, msg.ID)
+		msg.Mentions = r.loadMentions(ctx, msg.ID)
+		msg.Embeds = r.loadEmbeds(ctx, msg.ID)
+		msg.Poll = r.loadPoll(ctx, msg)
+		msg.Sticker = r.loadSticker(ctx, msg)
+	}
+
+	return messages, nil
+}
+
+// SendThreadReply creates a reply in the thread anchored on parentID
+func (r *Repository) SendThreadReply(ctx context.Context, convID, parentID, senderID uuid.UUID, content string, attachmentIDs []uuid.UUID) (*models.Message, error) {
+	// Verify participant
+	var exists bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS(`+activeParticipant+`)
+	`, convID, senderID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotParticipant
+	}
+	if err := r.checkDMBlock(ctx, convID, senderID); err != nil {
+		return nil, err
+	}
+	if err := r.checkMessageRequest(ctx, convID, senderID); err != nil {
+		return nil, err
+	}
+	if err := r.checkAnnouncementOnly(ctx, convID, senderID); err != nil {
+		return nil, err
+	}
+
+	// Parent must be a top-level message in this conversation
+	var grandparentID *uuid.UUID
+	err = r.db.QueryRow(ctx, `
+		SELECT parent_id FROM messages WHERE id = $1 AND conversation_id = $2
+	`, parentID, convID).Scan(&grandparentID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrMessageNotFound
+		}
+		return nil, err
+	}
+	if grandparentID != nil {
+		return nil, ErrNestedThread
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	msg := &models.Message{}
+	var nickname *string
+	err = tx.QueryRow(ctx, `
+		INSERT INTO messages (conversation_id, sender_id, content, parent_id, language, expires_at)
+		VALUES ($1, $2, $3, $4, $5, `+messageExpiresAt+`)
+		RETURNING id, conversation_id, sender_id, COALESCE(type, 'text'), content, parent_id, language, expires_at, created_at, updated_at, `+senderNickname+`
+	`, convID, senderID, content, parentID, detectLanguage(content)).Scan(
+		&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.Type, &msg.Content, &msg.ParentID, &msg.Language, &msg.ExpiresAt, &msg.CreatedAt, &msg.UpdatedAt, &nickname,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Link attachments to message (only if user owns them and they're not already linked)
+	if len(attachmentIDs) > 0 {
+		_, err = tx.Exec(ctx, `
+			UPDATE attachments
+			SET message_id = $1
+			WHERE id = ANY($2) AND uploader_id = $3 AND message_id IS NULL
+		`, msg.ID, attachmentIDs, senderID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	// Get sender info
+	msg.Sender = r.loadSender(ctx, senderID, nickname)
+
+	msg.Attachments = r.loadAttachments(ctx, msg.ID)
+	msg.Reactions = []*models.Reaction{}
+
+	return msg, nil
+}
+
+// GetReplyCount returns how many replies a thread has
+func (r *Repository) GetReplyCount(ctx context.Context, parentID uuid.UUID) (int, error) {
+	var count int
+	err := r.db.QueryRow(ctx, `
+		SELECT COUNT(*) FROM messages WHERE parent_id = $1 AND deleted_at IS NULL
+	`, parentID).Scan(&count)
+	return count, err
+}
+
+// GetMessageParentID returns the thread parent of a message (nil for top-level messages)
+func (r *Repository) GetMessageParentID(ctx context.Context, messageID uuid.UUID) (*uuid.UUID, error) {
+	var parentID *uuid.UUID
+	err := r.db.QueryRow(ctx, `
+		SELECT parent_id FROM messages WHERE id = $1
+	`, messageID).Scan(&parentID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrMessageNotFound
+	}
+	return parentID, err
+}
+
+// MarkRead records the last message a participant has read
+func (r *Repository) MarkRead(ctx context.Context, convID, userID, messageID uuid.UUID) (*models.ReadReceipt, error) {
+	var isParticipant, exists bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS(`+activeParticipant+`),
+			   EXISTS(SELECT 1 FROM messages WHERE id = $3 AND conversation_id = $1)
+	`, convID, userID, messageID).Scan(&isParticipant, &exists)
+	if err != nil {
+		return nil, err
+	}
+	if !isParticipant {
+		return nil, ErrNotParticipant
+	}
+	if !exists {
+		return nil, ErrMessageNotFound
+	}
+
+	// The watermark only moves forward, so a late receipt doesn't bring back unread messages
+	receipt := &models.ReadReceipt{UserID: userID, MessageID: messageID}
+	err = r.db.QueryRow(ctx, `
+		UPDATE conversation_participants cp
+		SET last_read_message_id = m.id, last_read_at = NOW()
+		FROM messages m
+		WHERE cp.conversation_id = $1 AND cp.user_id = $2
+		  AND m.id = $3 AND m.conversation_id = $1
+		  AND (cp.last_read_message_id IS NULL
+		       OR m.created_at > (SELECT created_at FROM messages WHERE id = cp.last_read_message_id))
+		RETURNING cp.last_read_at
+	`, convID, userID, messageID).Scan(&receipt.ReadAt)
+	if err == nil {
+		return receipt, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	// Nothing advanced: report where the watermark already is
+	err = r.db.QueryRow(ctx, `
+		SELECT last_read_message_id, last_read_at FROM conversation_participants
+		WHERE conversation_id = $1 AND user_id = $2
+	`, convID, userID).Scan(&receipt.MessageID, &receipt.ReadAt)
+	if err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+// SetMessageTTL sets the disappearing messages timer (nil = off).
+// In groups only the owner may change it; in DMs any participant can.
+func (r *Repository) SetMessageTTL(ctx context.Context, convID, userID uuid.UUID, ttlSeconds *int) error {
+	var convType string
+	var ownerID *uuid.UUID
+	err := r.db.QueryRow(ctx, `
+		SELECT type, owner_id FROM conversations WHERE id = $1
+	`, convID).Scan(&convType, &ownerID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrConversationNotFound
+		}
+		return err
+	}
+
+	var isParticipant bool
+	_ = r.db.QueryRow(ctx, `
+		SELECT EXISTS(`+activeParticipant+`)
+	`, convID, userID).Scan(&isParticipant)
+	if !isParticipant {
+		return ErrNotParticipant
+	}
+
+	// Allow if owner_id is null (legacy) or user is the owner
+	if convType == "group" && ownerID != nil && *ownerID != userID {
+		return errors.New("only the group owner can change disappearing messages")
+	}
+
+	_, err = r.db.Exec(ctx, `
+		UPDATE conversations SET message_ttl_seconds = $1, updated_at = NOW() WHERE id = $2
+	`, ttlSeconds, convID)
+	return err
+}
+
+// ExpiredMessage identifies a message removed by the disappearing messages job
+type ExpiredMessage struct {
+	MessageID      uuid.UUID
+	ConversationID uuid.UUID
+}
+
+// DeleteExpiredMessages deletes up to limit messages past their expires_at
+func (r *Repository) DeleteExpiredMessages(ctx context.Context, limit int) ([]ExpiredMessage, error) {
+	rows, err := r.db.Query(ctx, `
+		DELETE FROM messages
+		WHERE id IN (
+			SELECT id FROM messages
+			WHERE expires_at IS NOT NULL AND expires_at <= NOW()
+			ORDER BY expires_at
+			LIMIT $1
+		)
+		RETURNING id, conversation_id
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var expired []ExpiredMessage
+	for rows.Next() {
+		var m ExpiredMessage
+		if err := rows.Scan(&m.MessageID, &m.ConversationID); err != nil {
+			return nil, err
+		}
+		expired = append(expired, m)
+	}
+
+	return expired, rows.Err()
+}
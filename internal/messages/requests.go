@@ -0,0 +1,175 @@
+package messages
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/user/bla-back/internal/models"
+)
+
+const (
+	RequestPending  = "pending"
+	RequestDeclined = "declined"
+)
+
+var (
+	ErrRequestNotAccepted = errors.New("accept the message request before replying")
+	ErrRequestDeclined    = errors.New("message request was declined")
+	ErrNoMessageRequest   = errors.New("no message request in this conversation")
+)
+
+// awaitingRequest matches a recipient of a message request that isn't accepted yet, who
+// gets neither the requester's messages nor their presence
+// (convID and userID are the given query parameters or columns, e.g. "$1", "cp.user_id")
+func awaitingRequest(convID, userID string) string {
+	return `EXISTS(SELECT 1 FROM conversations rc WHERE rc.id = ` + convID + ` AND rc.request_status IS NOT NULL AND rc.requested_by IS DISTINCT FROM ` + userID + `)`
+}
+
+// areFriends matches an accepted friendship between two users (query parameters, e.g. "$1", "$2")
+func areFriends(userA, userB string) string {
+	return `EXISTS(SELECT 1 FROM friend_requests WHERE status = 'accepted' AND ((from_user_id = ` + userA + ` AND to_user_id = ` + userB + `) OR (from_user_id = ` + userB + ` AND to_user_id = ` + userA + `)))`
+}
+
+// checkMessageRequest lets only the requester write in a DM that is still a message
+// request, and nobody once it was declined
+func (r *Repository) checkMessageRequest(ctx context.Context, convID, senderID uuid.UUID) error {
+	var status *string
+	var requestedBy *uuid.UUID
+	err := r.db.QueryRow(ctx, `
+		SELECT request_status, requested_by FROM conversations WHERE id = $1
+	`, convID).Scan(&status, &requestedBy)
+	if err != nil {
+		return err
+	}
+	switch {
+	case status == nil:
+		return nil
+	case *status == RequestDeclined:
+		return ErrRequestDeclined
+	case requestedBy == nil || *requestedBy != senderID:
+		return ErrRequestNotAccepted
+	}
+	return nil
+}
+
+// IsAwaitingRequest reports whether the user received a message request in the
+// conversation that they haven't accepted
+func (r *Repository) IsAwaitingRequest(ctx context.Context, convID, userID uuid.UUID) (bool, error) {
+	var awaiting bool
+	err := r.db.QueryRow(ctx, `SELECT `+awaitingRequest("$1", "$2"), convID, userID).Scan(&awaiting)
+	return awaiting, err
+}
+
+// GetMessageRequests lists the pending message requests a user received, newest first
+func (r *Repository) GetMessageRequests(ctx context.Context, userID uuid.UUID) ([]*models.MessageRequest, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT c.id, c.created_at,
+			   u.id, u.email, u.username, u.avatar_url, u.created_at, u.updated_at,
+			   lm.id, lm.content, lm.created_at
+		FROM conversations c
+		JOIN conversation_participants cp ON cp.conversation_id = c.id AND cp.user_id = $1
+		JOIN users u ON u.id = c.requested_by
+		LEFT JOIN LATERAL (
+			SELECT m.id, m.content, m.created_at FROM messages m
+			WHERE m.conversation_id = c.id AND m.parent_id IS NULL AND m.deleted_at IS NULL
+			ORDER BY m.created_at DESC
+			LIMIT 1
+		) lm ON TRUE
+		WHERE c.request_status = 'pending' AND c.requested_by != $1 AND c.deleted_at IS NULL
+		ORDER BY COALESCE(lm.created_at, c.created_at) DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []*models.MessageRequest{}
+	for rows.Next() {
+		req := &models.MessageRequest{From: &models.User{}}
+		var lastID *uuid.UUID
+		var lastContent *string
+		var lastCreatedAt *time.Time
+		err := rows.Scan(&req.ConversationID, &req.CreatedAt,
+			&req.From.ID, &req.From.Email, &req.From.Username, &req.From.AvatarURL, &req.From.CreatedAt, &req.From.UpdatedAt,
+			&lastID, &lastContent, &lastCreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		// Presence isn't shared with the recipient before they accept
+		req.From.Status = "offline"
+		if lastID != nil {
+			req.LastMessage = &models.Message{
+				ID:             *lastID,
+				ConversationID: req.ConversationID,
+				SenderID:       req.From.ID,
+				Content:        *lastContent,
+				CreatedAt:      *lastCreatedAt,
+				UpdatedAt:      *lastCreatedAt,
+			}
+		}
+		requests = append(requests, req)
+	}
+	return requests, rows.Err()
+}
+
+// GetMessageRequest returns the pending request a user received in a conversation
+func (r *Repository) GetMessageRequest(ctx context.Context, convID, userID uuid.UUID) (*models.MessageRequest, error) {
+	requests, err := r.GetMessageRequests(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, req := range requests {
+		if req.ConversationID == convID {
+			return req, nil
+		}
+	}
+	return nil, ErrNoMessageRequest
+}
+
+// PendingRequestRecipient returns who a message request in the conversation is waiting on,
+// and whether messageID is the requester's first message
+func (r *Repository) PendingRequestRecipient(ctx context.Context, convID, messageID uuid.UUID) (recipientID uuid.UUID, first bool, err error) {
+	err = r.db.QueryRow(ctx, `
+		SELECT cp.user_id, NOT EXISTS(
+			SELECT 1 FROM messages m WHERE m.conversation_id = c.id AND m.id != $2
+			  AND m.created_at <= (SELECT created_at FROM messages WHERE id = $2)
+		)
+		FROM conversations c
+		JOIN conversation_participants cp ON cp.conversation_id = c.id AND cp.user_id != c.requested_by
+		WHERE c.id = $1 AND c.request_status = 'pending'
+	`, convID, messageID).Scan(&recipientID, &first)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, false, ErrNoMessageRequest
+	}
+	return recipientID, first, err
+}
+
+// AcceptMessageRequest turns a message request the user received (pending or declined)
+// into a regular DM. Returns the requester.
+func (r *Repository) AcceptMessageRequest(ctx context.Context, convID, userID uuid.UUID) (uuid.UUID, error) {
+	return r.answerMessageRequest(ctx, convID, userID, nil, RequestPending, RequestDeclined)
+}
+
+// DeclineMessageRequest hides a pending message request from the user and stops the
+// requester from writing. Returns the requester.
+func (r *Repository) DeclineMessageRequest(ctx context.Context, convID, userID uuid.UUID) (uuid.UUID, error) {
+	declined := RequestDeclined
+	return r.answerMessageRequest(ctx, convID, userID, &declined, RequestPending)
+}
+
+func (r *Repository) answerMessageRequest(ctx context.Context, convID, userID uuid.UUID, status *string, from ...string) (uuid.UUID, error) {
+	var requestedBy uuid.UUID
+	err := r.db.QueryRow(ctx, `
+		UPDATE conversations c SET request_status = $3, updated_at = NOW()
+		WHERE c.id = $1 AND c.request_status = ANY($4) AND c.requested_by != $2 AND c.deleted_at IS NULL
+		  AND EXISTS(SELECT 1 FROM conversation_participants WHERE conversation_id = c.id AND user_id = $2)
+		RETURNING c.requested_by
+	`, convID, userID, status, from).Scan(&requestedBy)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, ErrNoMessageRequest
+	}
+	return requestedBy, err
+}
@@ -0,0 +1,167 @@
+package messages
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Participant roles. DM participants are always members.
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+// Permission is an action in a conversation that depends on the participant's role
+type Permission string
+
+const (
+	PermAddMembers        Permission = "add_members"
+	PermEditGroup         Permission = "edit_group" // name and avatar
+	PermDeleteMessages    Permission = "delete_messages"
+	PermStartCalls        Permission = "start_calls"
+	PermRemoveMembers     Permission = "remove_members" // only members ranked below oneself
+	PermManageRoles       Permission = "manage_roles"
+	PermManageBans        Permission = "manage_bans" // list and lift bans
+	PermManageSync        Permission = "manage_sync" // sync endpoints for external read models
+	PermApproveJoins      Permission = "approve_joins"
+	PermPostAnnouncements Permission = "post_announcements" // post in announcement-only groups
+	PermViewAudit         Permission = "view_audit"         // read the group's audit log
+	PermStartAdminCalls   Permission = "start_admin_calls"  // start calls in groups limited to admins
+)
+
+// rolePermissions is the permission matrix. Deleting one's own messages needs no permission.
+var rolePermissions = map[string][]Permission{
+	RoleOwner:  {PermAddMembers, PermEditGroup, PermDeleteMessages, PermStartCalls, PermRemoveMembers, PermManageRoles, PermManageBans, PermManageSync, PermApproveJoins, PermPostAnnouncements, PermViewAudit, PermStartAdminCalls},
+	RoleAdmin:  {PermAddMembers, PermEditGroup, PermDeleteMessages, PermStartCalls, PermRemoveMembers, PermManageSync, PermApproveJoins, PermPostAnnouncements, PermViewAudit, PermStartAdminCalls},
+	RoleMember: {PermAddMembers, PermStartCalls},
+}
+
+// roleRank orders roles for actions on other participants
+var roleRank = map[string]int{RoleOwner: 2, RoleAdmin: 1, RoleMember: 0}
+
+var (
+	ErrPermissionDenied    = errors.New("your role in this conversation doesn't allow that")
+	ErrDMRoles             = errors.New("roles only apply to group conversations")
+	ErrParticipantNotFound = errors.New("user is not a participant of this conversation")
+	ErrOwnRole             = errors.New("the owner can't change their own role, transfer ownership instead")
+)
+
+// RoleCan reports whether a role has a permission
+func RoleCan(role string, perm Permission) bool {
+	return slices.Contains(rolePermissions[role], perm)
+}
+
+// participantRole returns the user's role in a conversation that isn't deleted
+func (r *Repository) participantRole(ctx context.Context, convID, userID uuid.UUID) (string, error) {
+	var role string
+	err := r.db.QueryRow(ctx, `
+		SELECT cp.role FROM conversation_participants cp
+		JOIN conversations c ON c.id = cp.conversation_id
+		WHERE cp.conversation_id = $1 AND cp.user_id = $2 AND c.deleted_at IS NULL
+	`, convID, userID).Scan(&role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrNotParticipant
+	}
+	return role, err
+}
+
+// CheckPermission returns ErrNotParticipant or ErrPermissionDenied unless the user's role
+// in the conversation grants the permission
+func (r *Repository) CheckPermission(ctx context.Context, convID, userID uuid.UUID, perm Permission) error {
+	role, err := r.participantRole(ctx, convID, userID)
+	if err != nil {
+		return err
+	}
+	if !RoleCan(role, perm) {
+		return ErrPermissionDenied
+	}
+	return nil
+}
+
+// SetParticipantRole promotes or demotes a group participant. Making someone the owner
+// transfers ownership: the previous owner becomes an admin.
+func (r *Repository) SetParticipantRole(ctx context.Context, convID, actorID, targetID uuid.UUID, role string) error {
+	var convType string
+	err := r.db.QueryRow(ctx, `SELECT type FROM conversations WHERE id = $1 AND deleted_at IS NULL`, convID).Scan(&convType)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrConversationNotFound
+		}
+		return err
+	}
+	if convType != "group" {
+		return ErrDMRoles
+	}
+
+	if err := r.CheckPermission(ctx, convID, actorID, PermManageRoles); err != nil {
+		return err
+	}
+	if targetID == actorID {
+		return ErrOwnRole
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `
+		UPDATE conversation_participants SET role = $3 WHERE conversation_id = $1 AND user_id = $2
+	`, convID, targetID, role)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrParticipantNotFound
+	}
+
+	if role == RoleOwner {
+		_, err = tx.Exec(ctx, `
+			UPDATE conversation_participants SET role = $3 WHERE conversation_id = $1 AND user_id = $2
+		`, convID, actorID, RoleAdmin)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE conversations SET owner_id = $2, updated_at = NOW() WHERE id = $1
+		`, convID, targetID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// passOwnership makes the longest-standing admin, or else member, the owner of a group
+// its owner is leaving
+func passOwnership(ctx context.Context, tx pgx.Tx, convID, leavingID uuid.UUID) error {
+	var nextID uuid.UUID
+	err := tx.QueryRow(ctx, `
+		SELECT user_id FROM conversation_participants
+		WHERE conversation_id = $1 AND user_id != $2
+		ORDER BY role = 'admin' DESC, joined_at
+		LIMIT 1
+	`, convID, leavingID).Scan(&nextID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil // last one out
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE conversation_participants SET role = 'owner' WHERE conversation_id = $1 AND user_id = $2
+	`, convID, nextID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `UPDATE conversations SET owner_id = $2 WHERE id = $1`, convID, nextID)
+	return err
+}
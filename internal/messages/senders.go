@@ -0,0 +1,54 @@
+package messages
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/models"
+)
+
+// senderNickname reads the sender's nickname in a RETURNING clause of a new message
+// ($1 = conversation_id, $2 = sender_id)
+const senderNickname = `(SELECT nickname FROM conversation_participants WHERE conversation_id = $1 AND user_id = $2)`
+
+// UserLookup gets users by ID, from a cache where it can (implemented by auth.Repository)
+type UserLookup interface {
+	GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error)
+}
+
+// SetUserLookup makes sender and reaction enrichment read users through the lookup
+// instead of querying them for every message
+func (r *Repository) SetUserLookup(users UserLookup) {
+	r.users = users
+}
+
+// loadUser gets the user shown as a message's sender or a reaction's author. A failed
+// lookup leaves only the ID set, as the message was already stored.
+func (r *Repository) loadUser(ctx context.Context, userID uuid.UUID) *models.User {
+	if r.users != nil {
+		if user, err := r.users.GetUserByID(ctx, userID); err == nil {
+			user.PasswordHash = ""
+			return user
+		}
+		return &models.User{ID: userID}
+	}
+
+	user := &models.User{}
+	err := r.db.QueryRow(ctx, `
+		SELECT id, email, username, avatar_url, status, created_at, updated_at
+		FROM users WHERE id = $1
+	`, userID).Scan(
+		&user.ID, &user.Email, &user.Username, &user.AvatarURL, &user.Status, &user.CreatedAt, &user.UpdatedAt,
+	)
+	if err != nil {
+		return &models.User{ID: userID}
+	}
+	return user
+}
+
+// loadSender gets a new message's sender, with the nickname its insert returned
+func (r *Repository) loadSender(ctx context.Context, senderID uuid.UUID, nickname *string) *models.User {
+	sender := r.loadUser(ctx, senderID)
+	sender.Nickname = nickname
+	return sender
+}
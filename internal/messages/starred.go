@@ -0,0 +1,96 @@
+package messages
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/models"
+)
+
+// StarMessage bookmarks a message for the user. The message must be in one of their conversations.
+func (r *Repository) StarMessage(ctx context.Context, messageID, userID uuid.UUID) error {
+	tag, err := r.db.Exec(ctx, `
+		INSERT INTO starred_messages (user_id, message_id)
+		SELECT $2, m.id FROM messages m
+		JOIN conversation_participants cp ON cp.conversation_id = m.conversation_id AND cp.user_id = $2
+		WHERE m.id = $1 AND m.deleted_at IS NULL
+		ON CONFLICT DO NOTHING
+	`, messageID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		// Either already starred or not visible to the user
+		var visible bool
+		err = r.db.QueryRow(ctx, `
+			SELECT EXISTS(
+				SELECT 1 FROM messages m
+				JOIN conversation_participants cp ON cp.conversation_id = m.conversation_id AND cp.user_id = $2
+				WHERE m.id = $1 AND m.deleted_at IS NULL
+			)
+		`, messageID, userID).Scan(&visible)
+		if err != nil {
+			return err
+		}
+		if !visible {
+			return ErrMessageNotFound
+		}
+	}
+	return nil
+}
+
+// UnstarMessage removes a bookmark
+func (r *Repository) UnstarMessage(ctx context.Context, messageID, userID uuid.UUID) error {
+	_, err := r.db.Exec(ctx, `
+		DELETE FROM starred_messages WHERE user_id = $1 AND message_id = $2
+	`, userID, messageID)
+	return err
+}
+
+// GetStarredMessages lists the user's bookmarks across conversations they still belong to, newest first
+func (r *Repository) GetStarredMessages(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*models.StarredMessage, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT s.created_at,
+			   m.id, m.conversation_id, m.sender_id, COALESCE(m.type, 'text'), m.content, m.parent_id, m.expires_at, m.created_at, m.updated_at,
+			   u.id, u.email, u.username, u.avatar_url, u.status, u.created_at, u.updated_at
+		FROM starred_messages s
+		JOIN messages m ON m.id = s.message_id
+		JOIN users u ON m.sender_id = u.id
+		JOIN conversation_participants cp ON cp.conversation_id = m.conversation_id AND cp.user_id = s.user_id
+		WHERE s.user_id = $1
+		ORDER BY s.created_at DESC
+		LIMIT $2 OFFSET $3
+	`, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var starred []*models.StarredMessage
+	for rows.Next() {
+		msg := &models.Message{Sender: &models.User{}}
+		item := &models.StarredMessage{Message: msg}
+		err := rows.Scan(
+			&item.StarredAt,
+			&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.Type, &msg.Content, &msg.ParentID, &msg.ExpiresAt, &msg.CreatedAt, &msg.UpdatedAt,
+			&msg.Sender.ID, &msg.Sender.Email, &msg.Sender.Username, &msg.Sender.AvatarURL, &msg.Sender.Status, &msg.Sender.CreatedAt, &msg.Sender.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		starred = append(starred, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Load attachments and reactions for each message
+	for _, item := range starred {
+		item.Message.Attachments = r.loadAttachments(ctx, item.Message.ID)
+		item.Message.Reactions = r.loadReactions(ctx, item.Message.ID)
+		item.Message.Poll = r.loadPoll(ctx, item.Message)
+		item.Message.Sticker = r.loadSticker(ctx, item.Message)
+	}
+
+	return starred, nil
+}
@@ -0,0 +1,115 @@
+package messages
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/user/bla-back/internal/models"
+)
+
+// ErrStickerUnavailable is returned for stickers outside official packs and the sender's collection
+var ErrStickerUnavailable = errors.New("sticker not available")
+
+// SendSticker sends a sticker message. Like SendMessageOnce, a retry with the same nonce
+// returns the message the first attempt created (created = false).
+func (r *Repository) SendSticker(ctx context.Context, convID, senderID, stickerID uuid.UUID, nonce *string) (msg *models.Message, created bool, err error) {
+	return r.sendOnce(ctx, convID, senderID, nonce, func() (*models.Message, error) {
+		return r.sendSticker(ctx, convID, senderID, stickerID, nonce)
+	})
+}
+
+func (r *Repository) sendSticker(ctx context.Context, convID, senderID, stickerID uuid.UUID, nonce *string) (*models.Message, error) {
+	// Verify participant
+	var exists bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS(`+activeParticipant+`)
+	`, convID, senderID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotParticipant
+	}
+	if err := r.checkDMBlock(ctx, convID, senderID); err != nil {
+		return nil, err
+	}
+	if err := r.checkMessageRequest(ctx, convID, senderID); err != nil {
+		return nil, err
+	}
+	if err := r.checkAnnouncementOnly(ctx, convID, senderID); err != nil {
+		return nil, err
+	}
+
+	sticker, err := r.availableSticker(ctx, senderID, stickerID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The emoji doubles as message content so previews and search keep working
+	msg := &models.Message{}
+	var nickname *string
+	err = r.db.QueryRow(ctx, `
+		INSERT INTO messages (conversation_id, sender_id, type, content, sticker_id, client_nonce, expires_at)
+		VALUES ($1, $2, 'sticker', $3, $4, $5, `+messageExpiresAt+`)
+		RETURNING id, conversation_id, sender_id, type, content, client_nonce, expires_at, created_at, updated_at, `+senderNickname+`
+	`, convID, senderID, sticker.Emoji, stickerID, nonce).Scan(
+		&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.Type, &msg.Content, &msg.Nonce, &msg.ExpiresAt, &msg.CreatedAt, &msg.UpdatedAt, &nickname,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	_, _ = r.db.Exec(ctx, `UPDATE conversations SET updated_at = NOW() WHERE id = $1`, convID)
+
+	// Get sender info
+	msg.Sender = r.loadSender(ctx, senderID, nickname)
+
+	msg.Attachments = []*models.Attachment{}
+	msg.Reactions = []*models.Reaction{}
+	msg.Sticker = sticker
+
+	return msg, nil
+}
+
+// availableSticker loads a sticker from an official pack or one the user has saved
+func (r *Repository) availableSticker(ctx context.Context, userID, stickerID uuid.UUID) (*models.Sticker, error) {
+	sticker := &models.Sticker{}
+	err := r.db.QueryRow(ctx, `
+		SELECT s.id, s.pack_id, s.emoji, s.file_url, s.file_type, s.width, s.height, s.created_at
+		FROM stickers s
+		JOIN sticker_packs sp ON sp.id = s.pack_id
+		WHERE s.id = $1
+		  AND (sp.is_official OR EXISTS(SELECT 1 FROM user_sticker_packs WHERE user_id = $2 AND pack_id = sp.id))
+	`, stickerID, userID).Scan(
+		&sticker.ID, &sticker.PackID, &sticker.Emoji, &sticker.FileURL, &sticker.FileType, &sticker.Width, &sticker.Height, &sticker.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrStickerUnavailable
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sticker, nil
+}
+
+// loadSticker loads the sticker for sticker messages (nil otherwise, or if the sticker was removed)
+func (r *Repository) loadSticker(ctx context.Context, msg *models.Message) *models.Sticker {
+	if msg.Type != "sticker" {
+		return nil
+	}
+	sticker := &models.Sticker{}
+	err := r.db.QueryRow(ctx, `
+		SELECT s.id, s.pack_id, s.emoji, s.file_url, s.file_type, s.width, s.height, s.created_at
+		FROM messages m
+		JOIN stickers s ON s.id = m.sticker_id
+		WHERE m.id = $1
+	`, msg.ID).Scan(
+		&sticker.ID, &sticker.PackID, &sticker.Emoji, &sticker.FileURL, &sticker.FileType, &sticker.Width, &sticker.Height, &sticker.CreatedAt,
+	)
+	if err != nil {
+		return nil
+	}
+	return sticker
+}
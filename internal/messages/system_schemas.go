@@ -0,0 +1,177 @@
+package messages
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/user/bla-back/internal/models"
+)
+
+// SchemaVersionKey is added to every system message's content with the version of the
+// schema it was written against
+const SchemaVersionKey = "v"
+
+var ErrInvalidSystemContent = errors.New("system message content doesn't match its schema")
+
+// Field kinds, named after JSON types
+const (
+	KindString  = "string"
+	KindNumber  = "number"
+	KindBoolean = "boolean"
+	KindArray   = "array"
+	KindObject  = "object"
+)
+
+func required(name, kind string) models.SystemMessageField {
+	return models.SystemMessageField{Name: name, Kind: kind, Required: true}
+}
+
+func optional(name, kind string) models.SystemMessageField {
+	return models.SystemMessageField{Name: name, Kind: kind}
+}
+
+// systemSchemas holds every version of each system message type, oldest first; writes
+// use the newest. Add a version instead of changing one clients may already rely on.
+// poll_results has a schema but no writer yet.
+var systemSchemas = map[string][]*models.SystemMessageSchema{
+	"call": {{
+		Type:    "call",
+		Version: 1,
+		Fields: []models.SystemMessageField{
+			required("call_id", KindString),
+			required("duration", KindNumber),
+			required("participants", KindArray),
+			required("status", KindString),
+		},
+		Fallback: "Call",
+	}},
+	"call_transcript": {{
+		Type:    "call_transcript",
+		Version: 1,
+		Fields: []models.SystemMessageField{
+			required("call_id", KindString),
+			required("lines", KindArray),
+		},
+		Fallback: "Call chat transcript",
+	}},
+	"call_reminder": {{
+		Type:    "call_reminder",
+		Version: 1,
+		Fields: []models.SystemMessageField{
+			required("scheduled_call_id", KindString),
+			required("title", KindString),
+			required("starts_at", KindString),
+			required("join_url", KindString),
+		},
+		Fallback: "Scheduled call reminder",
+	}},
+	"membership": {{
+		Type:    "membership",
+		Version: 1,
+		Fields: []models.SystemMessageField{
+			required("action", KindString), // joined, left, added, removed
+			required("user_ids", KindArray),
+			optional("actor_id", KindString),
+		},
+		Fallback: "Group members changed",
+	}},
+	"group_update": {{
+		Type:    "group_update",
+		Version: 1,
+		Fields: []models.SystemMessageField{
+			required("actor_id", KindString),
+			required("changes", KindArray), // name, avatar
+			optional("name", KindString),
+			optional("avatar_url", KindString),
+		},
+		Fallback: "Group settings changed",
+	}},
+	"poll_results": {{
+		Type:    "poll_results",
+		Version: 1,
+		Fields: []models.SystemMessageField{
+			required("poll_message_id", KindString),
+			required("question", KindString),
+			required("options", KindArray),
+			required("total_voters", KindNumber),
+		},
+		Fallback: "Poll results",
+	}},
+}
+
+// SystemSchemas returns every registered schema version, by type and version
+func SystemSchemas() []*models.SystemMessageSchema {
+	var schemas []*models.SystemMessageSchema
+	for _, versions := range systemSchemas {
+		schemas = append(schemas, versions...)
+	}
+	sort.Slice(schemas, func(i, j int) bool {
+		if schemas[i].Type != schemas[j].Type {
+			return schemas[i].Type < schemas[j].Type
+		}
+		return schemas[i].Version < schemas[j].Version
+	})
+	return schemas
+}
+
+// stampSystemContent validates content against the newest schema of msgType and returns
+// it with the schema version added
+func stampSystemContent(msgType, content string) (string, error) {
+	versions := systemSchemas[msgType]
+	if len(versions) == 0 {
+		return "", fmt.Errorf("%w: unknown type %q", ErrInvalidSystemContent, msgType)
+	}
+	schema := versions[len(versions)-1]
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(content), &fields); err != nil || fields == nil {
+		return "", fmt.Errorf("%w: %s content must be a JSON object", ErrInvalidSystemContent, msgType)
+	}
+	delete(fields, SchemaVersionKey)
+
+	known := make(map[string]bool, len(schema.Fields))
+	for _, f := range schema.Fields {
+		known[f.Name] = true
+		value, ok := fields[f.Name]
+		if !ok || string(value) == "null" {
+			if f.Required {
+				return "", fmt.Errorf("%w: %s v%d requires %q", ErrInvalidSystemContent, msgType, schema.Version, f.Name)
+			}
+			continue
+		}
+		if kind := jsonKind(value); kind != f.Kind {
+			return "", fmt.Errorf("%w: %s v%d field %q must be a %s, got %s",
+				ErrInvalidSystemContent, msgType, schema.Version, f.Name, f.Kind, kind)
+		}
+	}
+	for name := range fields {
+		if !known[name] {
+			return "", fmt.Errorf("%w: %s v%d has no field %q", ErrInvalidSystemContent, msgType, schema.Version, name)
+		}
+	}
+
+	fields[SchemaVersionKey] = json.RawMessage(fmt.Sprint(schema.Version))
+	stamped, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+	return string(stamped), nil
+}
+
+// jsonKind returns the JSON type of a raw value
+func jsonKind(value json.RawMessage) string {
+	switch value[0] {
+	case '"':
+		return KindString
+	case '[':
+		return KindArray
+	case '{':
+		return KindObject
+	case 't', 'f':
+		return KindBoolean
+	default:
+		return KindNumber
+	}
+}
@@ -0,0 +1,72 @@
+package messages
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const purgeBatchSize = 500
+
+// PurgeDeletedMessages permanently removes up to limit tombstones deleted before the cutoff.
+// Tombstones that still anchor thread replies are kept until those replies are gone.
+func (r *Repository) PurgeDeletedMessages(ctx context.Context, before time.Time, limit int) (int64, error) {
+	tag, err := r.db.Exec(ctx, `
+		DELETE FROM messages
+		WHERE id IN (
+			SELECT m.id FROM messages m
+			WHERE m.deleted_at IS NOT NULL AND m.deleted_at < $1
+			  AND NOT EXISTS(SELECT 1 FROM messages r WHERE r.parent_id = m.id)
+			ORDER BY m.deleted_at
+			LIMIT $2
+		)
+	`, before, limit)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// TombstonePurger periodically hard-deletes soft-deleted messages past the retention period
+type TombstonePurger struct {
+	repo      *Repository
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewTombstonePurger(repo *Repository, retention, interval time.Duration) *TombstonePurger {
+	return &TombstonePurger{
+		repo:      repo,
+		retention: retention,
+		interval:  interval,
+	}
+}
+
+// Run purges old tombstones every interval until ctx is cancelled
+func (p *TombstonePurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.purge(ctx)
+		}
+	}
+}
+
+func (p *TombstonePurger) purge(ctx context.Context) {
+	cutoff := time.Now().Add(-p.retention)
+	for {
+		n, err := p.repo.PurgeDeletedMessages(ctx, cutoff, purgeBatchSize)
+		if err != nil {
+			log.Printf("Failed to purge deleted messages: %v", err)
+			return
+		}
+		if n < purgeBatchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package messages
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/user/bla-back/internal/models"
+	"github.com/user/bla-back/internal/translate"
+)
+
+// languageSampleSize is how many recent messages decide a conversation's language
+const languageSampleSize = 100
+
+// SetAutoTranslate turns auto-translation of a conversation on for a user (target set) or off (nil)
+func (r *Repository) SetAutoTranslate(ctx context.Context, convID, userID uuid.UUID, target *string) error {
+	var isParticipant bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS(`+activeParticipant+`)
+	`, convID, userID).Scan(&isParticipant)
+	if err != nil {
+		return err
+	}
+	if !isParticipant {
+		return ErrNotParticipant
+	}
+
+	if target == nil {
+		_, err = r.db.Exec(ctx, `
+			DELETE FROM conversation_translations WHERE conversation_id = $1 AND user_id = $2
+		`, convID, userID)
+		return err
+	}
+
+	_, err = r.db.Exec(ctx, `
+		INSERT INTO conversation_translations (conversation_id, user_id, target_language)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (conversation_id, user_id) DO UPDATE SET target_language = EXCLUDED.target_language
+	`, convID, userID, *target)
+	return err
+}
+
+// GetTranslationSettings returns a user's auto-translate setting and the conversation's dominant language
+func (r *Repository) GetTranslationSettings(ctx context.Context, convID, userID uuid.UUID) (*models.TranslationSettings, error) {
+	var isParticipant bool
+	settings := &models.TranslationSettings{}
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS(`+activeParticipant+`),
+			   (SELECT target_language FROM conversation_translations WHERE conversation_id = $1 AND user_id = $2)
+	`, convID, userID).Scan(&isParticipant, &settings.TargetLanguage)
+	if err != nil {
+		return nil, err
+	}
+	if !isParticipant {
+		return nil, ErrNotParticipant
+	}
+	settings.Enabled = settings.TargetLanguage != nil
+
+	err = r.db.QueryRow(ctx, `
+		SELECT language FROM (
+			SELECT language FROM messages
+			WHERE conversation_id = $1 AND deleted_at IS NULL
+			ORDER BY created_at DESC
+			LIMIT $2
+		) recent
+		WHERE language IS NOT NULL
+		GROUP BY language
+		ORDER BY COUNT(*) DESC, language
+		LIMIT 1
+	`, convID, languageSampleSize).Scan(&settings.ConversationLanguage)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// GetAutoTranslateTargets returns the target language of each participant auto-translating a conversation
+func (r *Repository) GetAutoTranslateTargets(ctx context.Context, convID uuid.UUID) (map[uuid.UUID]string, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT user_id, target_language FROM conversation_translations WHERE conversation_id = $1
+	`, convID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	targets := map[uuid.UUID]string{}
+	for rows.Next() {
+		var userID uuid.UUID
+		var lang string
+		if err := rows.Scan(&userID, &lang); err != nil {
+			return nil, err
+		}
+		targets[userID] = lang
+	}
+	return targets, rows.Err()
+}
+
+// detectLanguage is translate.Detect as a nullable column value
+func detectLanguage(content string) *string {
+	if lang := translate.Detect(content); lang != "" {
+		return &lang
+	}
+	return nil
+}
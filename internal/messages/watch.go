@@ -0,0 +1,109 @@
+package messages
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/user/bla-back/internal/models"
+)
+
+var ErrNoWatchSession = errors.New("no watch-together session in this conversation")
+
+const watchSessionColumns = `conversation_id, media_url, started_by, playing, position_ms, updated_at, updated_by, NOW()`
+
+// watchPosition is the current playback position of a session row
+const watchPosition = `CASE WHEN playing
+	THEN position_ms + (EXTRACT(EPOCH FROM (NOW() - updated_at)) * 1000)::BIGINT
+	ELSE position_ms END`
+
+func scanWatchSession(row pgx.Row) (*models.WatchSession, error) {
+	s := &models.WatchSession{}
+	err := row.Scan(&s.ConversationID, &s.MediaURL, &s.StartedBy, &s.Playing, &s.PositionMs, &s.UpdatedAt, &s.UpdatedBy, &s.ServerTime)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoWatchSession
+	}
+	return s, err
+}
+
+func (r *Repository) requireParticipant(ctx context.Context, convID, userID uuid.UUID) error {
+	isParticipant, err := r.IsParticipant(ctx, convID, userID)
+	if err != nil {
+		return err
+	}
+	if !isParticipant {
+		return ErrNotParticipant
+	}
+	return nil
+}
+
+// StartWatchSession starts (or replaces) the conversation's session, paused at the beginning
+func (r *Repository) StartWatchSession(ctx context.Context, convID, userID uuid.UUID, mediaURL string) (*models.WatchSession, error) {
+	if err := r.requireParticipant(ctx, convID, userID); err != nil {
+		return nil, err
+	}
+
+	return scanWatchSession(r.db.QueryRow(ctx, `
+		INSERT INTO watch_sessions (conversation_id, media_url, started_by, updated_by)
+		VALUES ($1, $2, $3, $3)
+		ON CONFLICT (conversation_id) DO UPDATE SET
+			media_url = EXCLUDED.media_url, started_by = EXCLUDED.started_by,
+			playing = FALSE, position_ms = 0, updated_at = NOW(), updated_by = EXCLUDED.updated_by
+		RETURNING `+watchSessionColumns,
+		convID, mediaURL, userID))
+}
+
+// GetWatchSession returns the session with its position brought up to date
+func (r *Repository) GetWatchSession(ctx context.Context, convID, userID uuid.UUID) (*models.WatchSession, error) {
+	if err := r.requireParticipant(ctx, convID, userID); err != nil {
+		return nil, err
+	}
+
+	return scanWatchSession(r.db.QueryRow(ctx, `
+		SELECT conversation_id, media_url, started_by, playing, `+watchPosition+`, NOW(), updated_by, NOW()
+		FROM watch_sessions WHERE conversation_id = $1
+	`, convID))
+}
+
+// ControlWatchSession applies play/pause/seek. Without an explicit position, play and pause
+// keep the current one so rapid toggles from different clients don't drift.
+func (r *Repository) ControlWatchSession(ctx context.Context, convID, userID uuid.UUID, action string, positionMs *int64) (*models.WatchSession, error) {
+	if err := r.requireParticipant(ctx, convID, userID); err != nil {
+		return nil, err
+	}
+
+	playing := `playing`
+	switch action {
+	case "play":
+		playing = `TRUE`
+	case "pause":
+		playing = `FALSE`
+	}
+
+	return scanWatchSession(r.db.QueryRow(ctx, `
+		UPDATE watch_sessions SET
+			position_ms = COALESCE($3, `+watchPosition+`),
+			playing = `+playing+`,
+			updated_at = NOW(),
+			updated_by = $2
+		WHERE conversation_id = $1
+		RETURNING `+watchSessionColumns,
+		convID, userID, positionMs))
+}
+
+// EndWatchSession stops the conversation's session
+func (r *Repository) EndWatchSession(ctx context.Context, convID, userID uuid.UUID) error {
+	if err := r.requireParticipant(ctx, convID, userID); err != nil {
+		return err
+	}
+
+	tag, err := r.db.Exec(ctx, `DELETE FROM watch_sessions WHERE conversation_id = $1`, convID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNoWatchSession
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "bla"
+
+// Realtime
+var (
+	RealtimeDroppedEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "realtime",
+		Name:      "dropped_events_total",
+		Help:      "Events not published to lagging clients, by event type.",
+	}, []string{"event_type"})
+
+	RealtimeSlowClients = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "realtime",
+		Name:      "slow_clients",
+		Help:      "Connections whose pending publications exceed the slow client threshold.",
+	})
+)
+
+// Database
+var (
+	DBSlowQueries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "slow_queries_total",
+		Help:      "Queries exceeding the slow query threshold, by calling repository method.",
+	}, []string{"caller"})
+)
+
+// Handler serves all registered metrics in Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
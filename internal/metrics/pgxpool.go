@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// pgxPoolCollector reads pgxpool.Stat on every scrape
+type pgxPoolCollector struct {
+	pool *pgxpool.Pool
+
+	acquiredConns        *prometheus.Desc
+	idleConns            *prometheus.Desc
+	constructingConns    *prometheus.Desc
+	totalConns           *prometheus.Desc
+	maxConns             *prometheus.Desc
+	acquireTotal         *prometheus.Desc
+	acquireWaitTotal     *prometheus.Desc
+	acquireWaitSeconds   *prometheus.Desc
+	canceledAcquireTotal *prometheus.Desc
+}
+
+// RegisterPgxPool exports connection pool saturation and acquire wait times for pool
+func RegisterPgxPool(pool *pgxpool.Pool) {
+	prometheus.MustRegister(newPgxPoolCollector(pool))
+}
+
+func newPgxPoolCollector(pool *pgxpool.Pool) *pgxPoolCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, nil, nil)
+	}
+	return &pgxPoolCollector{
+		pool:                 pool,
+		acquiredConns:        desc("acquired_connections", "Connections currently checked out of the pool."),
+		idleConns:            desc("idle_connections", "Idle connections in the pool."),
+		constructingConns:    desc("constructing_connections", "Connections currently being established."),
+		totalConns:           desc("total_connections", "Total connections in the pool."),
+		maxConns:             desc("max_connections", "Maximum size of the pool."),
+		acquireTotal:         desc("acquires_total", "Successful connection acquires."),
+		acquireWaitTotal:     desc("acquire_waits_total", "Acquires that had to wait because the pool was empty."),
+		acquireWaitSeconds:   desc("acquire_wait_seconds_total", "Total time spent waiting to acquire a connection."),
+		canceledAcquireTotal: desc("canceled_acquires_total", "Acquires cancelled by context before a connection was available."),
+	}
+}
+
+func (c *pgxPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acquiredConns
+	ch <- c.idleConns
+	ch <- c.constructingConns
+	ch <- c.totalConns
+	ch <- c.maxConns
+	ch <- c.acquireTotal
+	ch <- c.acquireWaitTotal
+	ch <- c.acquireWaitSeconds
+	ch <- c.canceledAcquireTotal
+}
+
+func (c *pgxPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(c.acquiredConns, prometheus.GaugeValue, float64(s.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(s.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.constructingConns, prometheus.GaugeValue, float64(s.ConstructingConns()))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(s.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.maxConns, prometheus.GaugeValue, float64(s.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquireTotal, prometheus.CounterValue, float64(s.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireWaitTotal, prometheus.CounterValue, float64(s.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireWaitSeconds, prometheus.CounterValue, s.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(c.canceledAcquireTotal, prometheus.CounterValue, float64(s.CanceledAcquireCount()))
+}
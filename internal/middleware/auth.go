@@ -24,7 +24,7 @@ func Auth(tokenService *auth.TokenService) func(http.Handler) http.Handler {
 				return
 			}
 
-			claims, err := tokenService.ValidateAccessToken(parts[1])
+			claims, err := tokenService.ValidateAccessToken(r.Context(), parts[1])
 			if err != nil {
 				handlers.RespondUnauthorized(w, "Invalid or expired token")
 				return
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/auth"
+	"github.com/user/bla-back/internal/handlers"
+)
+
+// AccessTokens resolves personal access tokens
+type AccessTokens interface {
+	UseAccessToken(ctx context.Context, token string) (uuid.UUID, []string, error)
+}
+
+// AuthWithScope is Auth that also accepts personal access tokens carrying the scope.
+// Routes behind plain Auth stay closed to personal access tokens.
+func AuthWithScope(tokenService *auth.TokenService, tokens AccessTokens, scope string) func(http.Handler) http.Handler {
+	session := Auth(tokenService)
+	return func(next http.Handler) http.Handler {
+		sessionNext := session(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || !auth.IsAccessToken(token) {
+				sessionNext.ServeHTTP(w, r)
+				return
+			}
+
+			userID, scopes, err := tokens.UseAccessToken(r.Context(), token)
+			if err != nil {
+				handlers.RespondUnauthorized(w, "Invalid or expired token")
+				return
+			}
+			if !slices.Contains(scopes, scope) {
+				handlers.RespondForbidden(w, "Token is missing the "+scope+" scope")
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), "userID", userID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/user/bla-back/internal/apiversion"
+	"github.com/user/bla-back/internal/handlers"
+)
+
+// APIVersion negotiates the API version of a request (see apiversion.Negotiate), serves
+// /api/vN/... from the unversioned routes and converts JSON responses from the version 1
+// shape the handlers produce to the negotiated version.
+func APIVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version, path, err := apiversion.Negotiate(r)
+		if err != nil {
+			handlers.RespondBadRequest(w, "Unsupported API version, supported versions are "+
+				strconv.Itoa(apiversion.Oldest)+" to "+strconv.Itoa(apiversion.Latest))
+			return
+		}
+
+		if path != r.URL.Path {
+			r = r.Clone(r.Context())
+			r.URL.Path = path
+			r.URL.RawPath = ""
+		}
+		r = r.WithContext(apiversion.WithVersion(r.Context(), version))
+		w.Header().Set(apiversion.Header, strconv.Itoa(version))
+
+		// Version 1 is what the handlers write; WebSocket upgrades negotiate events separately
+		if version == apiversion.Oldest || strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		vw := &versionWriter{ResponseWriter: w, version: version}
+		next.ServeHTTP(vw, r)
+		vw.finish()
+	})
+}
+
+// versionWriter buffers JSON responses so they can be converted before they are sent.
+// Other content types pass straight through.
+type versionWriter struct {
+	http.ResponseWriter
+	version     int
+	status      int
+	decided     bool
+	passthrough bool
+	buf         bytes.Buffer
+}
+
+func (vw *versionWriter) decide() {
+	if vw.decided {
+		return
+	}
+	vw.decided = true
+	if vw.status == 0 {
+		vw.status = http.StatusOK
+	}
+	if !strings.HasPrefix(vw.Header().Get("Content-Type"), "application/json") {
+		vw.passthrough = true
+		vw.ResponseWriter.WriteHeader(vw.status)
+	}
+}
+
+func (vw *versionWriter) WriteHeader(status int) {
+	if vw.decided || vw.status != 0 {
+		return
+	}
+	vw.status = status
+	vw.decide()
+}
+
+func (vw *versionWriter) Write(p []byte) (int, error) {
+	vw.decide()
+	if vw.passthrough {
+		return vw.ResponseWriter.Write(p)
+	}
+	return vw.buf.Write(p)
+}
+
+// Flush sends buffered output early; streamed responses aren't converted
+func (vw *versionWriter) Flush() {
+	vw.decide()
+	if !vw.passthrough {
+		vw.passthrough = true
+		vw.ResponseWriter.WriteHeader(vw.status)
+		vw.ResponseWriter.Write(vw.buf.Bytes())
+		vw.buf.Reset()
+	}
+	if f, ok := vw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (vw *versionWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := vw.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+func (vw *versionWriter) Unwrap() http.ResponseWriter {
+	return vw.ResponseWriter
+}
+
+// finish converts and sends a buffered JSON response
+func (vw *versionWriter) finish() {
+	if !vw.decided {
+		vw.decide()
+	}
+	if vw.passthrough {
+		return
+	}
+
+	body := vw.buf.Bytes()
+	if len(body) > 0 {
+		if converted, err := apiversion.Transform(body, vw.version); err == nil {
+			body = converted
+		}
+	}
+	vw.Header().Del("Content-Length")
+	vw.ResponseWriter.WriteHeader(vw.status)
+	vw.ResponseWriter.Write(body)
+}
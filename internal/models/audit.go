@@ -0,0 +1,21 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditEntry is an administrative action taken in a group
+type AuditEntry struct {
+	ID             uuid.UUID       `json:"id"`
+	ConversationID uuid.UUID       `json:"conversation_id"`
+	Action         string          `json:"action"`
+	ActorID        *uuid.UUID      `json:"actor_id"` // nil once the account is deleted
+	Actor          *User           `json:"actor,omitempty"`
+	TargetID       *uuid.UUID      `json:"target_id,omitempty"`
+	Target         *User           `json:"target,omitempty"`
+	Details        json.RawMessage `json:"details"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
@@ -0,0 +1,211 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CallChatMessage is a line of in-call chat, relayed on the call:{id} channel
+type CallChatMessage struct {
+	ID        uuid.UUID `json:"id"`
+	CallID    uuid.UUID `json:"call_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CallChatRequest struct {
+	Content string `json:"content" validate:"required,max=2000"`
+}
+
+type PushToTalkRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+type CallChatTranscriptRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// CallChatTranscriptEvent tells call participants whether the chat will be saved
+type CallChatTranscriptEvent struct {
+	CallID  uuid.UUID `json:"call_id"`
+	Enabled bool      `json:"enabled"`
+	UserID  uuid.UUID `json:"user_id"` // who changed it
+}
+
+// CallTranscriptContent is the content of a "call_transcript" message (stored as JSON)
+type CallTranscriptContent struct {
+	CallID string            `json:"call_id"`
+	Lines  []CallChatMessage `json:"lines"`
+}
+
+// CallCaption is a transcribed line of speech, relayed as CALL_CAPTION on the call:{id}
+// channel and kept as the call's transcript
+type CallCaption struct {
+	ID        uuid.UUID `json:"id"`
+	CallID    uuid.UUID `json:"call_id"`
+	UserID    uuid.UUID `json:"user_id"` // who spoke
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CallStats are client-side WebRTC stats averaged over the call (nil = not measured)
+type CallStats struct {
+	RTTMs         *float64 `json:"rtt_ms,omitempty" validate:"omitempty,min=0,max=60000"`
+	PacketLossPct *float64 `json:"packet_loss_pct,omitempty" validate:"omitempty,min=0,max=100"`
+	JitterMs      *float64 `json:"jitter_ms,omitempty" validate:"omitempty,min=0,max=60000"`
+}
+
+type CallFeedbackRequest struct {
+	Rating   int       `json:"rating" validate:"required,min=1,max=5"`
+	Issues   []string  `json:"issues" validate:"max=10"`
+	Comment  *string   `json:"comment" validate:"omitempty,max=1000"`
+	Platform *string   `json:"platform" validate:"omitempty,max=32"` // "web", "ios", "android", "desktop"
+	Stats    CallStats `json:"stats"`
+}
+
+type CallFeedback struct {
+	CallID    uuid.UUID `json:"call_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Rating    int       `json:"rating"`
+	Issues    []string  `json:"issues"`
+	Comment   *string   `json:"comment,omitempty"`
+	Platform  *string   `json:"platform,omitempty"`
+	Stats     CallStats `json:"stats"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CallFeedbackSummary aggregates feedback for the admin dashboard
+type CallFeedbackSummary struct {
+	Since            time.Time          `json:"since"`
+	Responses        int                `json:"responses"`
+	AvgRating        float64            `json:"avg_rating"`
+	AvgRTTMs         *float64           `json:"avg_rtt_ms"`
+	P95RTTMs         *float64           `json:"p95_rtt_ms"`
+	AvgPacketLossPct *float64           `json:"avg_packet_loss_pct"`
+	P95PacketLossPct *float64           `json:"p95_packet_loss_pct"`
+	Issues           map[string]int     `json:"issues"`
+	Platform         map[string]float64 `json:"avg_rating_by_platform"`
+	Days             []CallFeedbackDay  `json:"days"`
+}
+
+type CallFeedbackDay struct {
+	Date             string   `json:"date"`
+	Responses        int      `json:"responses"`
+	AvgRating        float64  `json:"avg_rating"`
+	AvgRTTMs         *float64 `json:"avg_rtt_ms"`
+	AvgPacketLossPct *float64 `json:"avg_packet_loss_pct"`
+}
+
+// ScheduledCall is a call planned for a future time in a conversation
+type ScheduledCall struct {
+	ID             uuid.UUID  `json:"id"`
+	ConversationID uuid.UUID  `json:"conversation_id"`
+	CreatedBy      uuid.UUID  `json:"created_by"`
+	Title          string     `json:"title"`
+	StartsAt       time.Time  `json:"starts_at"`
+	CallID         *uuid.UUID `json:"call_id,omitempty"` // set once the scheduler opens the call
+	JoinURL        string     `json:"join_url"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type ScheduleCallRequest struct {
+	StartsAt time.Time `json:"starts_at" validate:"required_without=LocalTime"`
+	// LocalTime ("HH:MM") schedules the next occurrence of that time in the creator's zone
+	LocalTime string `json:"local_time,omitempty"`
+	Title     string `json:"title" validate:"max=100"`
+}
+
+type ScheduledCallDeleteEvent struct {
+	ID             uuid.UUID `json:"id"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+}
+
+// CallReminderContent is the content of a "call_reminder" message (stored as JSON)
+type CallReminderContent struct {
+	ScheduledCallID string    `json:"scheduled_call_id"`
+	Title           string    `json:"title"`
+	StartsAt        time.Time `json:"starts_at"`
+	JoinURL         string    `json:"join_url"`
+}
+
+// CallRingEvent rings a conversation's participants when a call starts
+type CallRingEvent struct {
+	CallID         uuid.UUID `json:"call_id"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	Caller         *User     `json:"caller"`
+	ExpiresAt      time.Time `json:"expires_at"` // ringing stops on its own after this
+	// CallWaiting is set for users already in a call, who get a call-waiting tone
+	// and can take it by holding or leaving their call
+	CallWaiting bool `json:"call_waiting,omitempty"`
+}
+
+// CallRingStopEvent tells devices to stop ringing for a call
+type CallRingStopEvent struct {
+	CallID         uuid.UUID `json:"call_id"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	Reason         string    `json:"reason"` // "answered", "declined", "timeout", "ended"
+}
+
+// CallDeclineEvent is sent to the people in a call when someone declines it
+type CallDeclineEvent struct {
+	CallID         uuid.UUID `json:"call_id"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	UserID         uuid.UUID `json:"user_id"`
+}
+
+// CallMediaState is a participant's self-reported audio and video state, for call tiles
+type CallMediaState struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Muted    bool      `json:"muted"`
+	Deafened bool      `json:"deafened"`
+	Camera   bool      `json:"camera"`
+}
+
+// UpdateCallMediaRequest changes the caller's media state; omitted fields are unchanged
+type UpdateCallMediaRequest struct {
+	Muted    *bool `json:"muted"`
+	Deafened *bool `json:"deafened"`
+	Camera   *bool `json:"camera"`
+}
+
+// CallMissedEvent is sent to a rung user who never answered once the call ends
+type CallMissedEvent struct {
+	CallID         uuid.UUID `json:"call_id"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	CallerID       uuid.UUID `json:"caller_id"`
+	StartedAt      time.Time `json:"started_at"`
+	// MissedCalls is the user's updated missed-calls badge count
+	MissedCalls int `json:"missed_calls"`
+}
+
+// CallEndedEvent summarizes a call once it ends
+type CallEndedEvent struct {
+	CallID         uuid.UUID   `json:"call_id"`
+	ConversationID uuid.UUID   `json:"conversation_id"`
+	StartedBy      uuid.UUID   `json:"started_by"`
+	StartedAt      time.Time   `json:"started_at"`
+	EndedAt        time.Time   `json:"ended_at"`
+	Duration       int         `json:"duration"`     // seconds
+	Participants   []uuid.UUID `json:"participants"` // everyone who joined
+	Status         string      `json:"status"`       // "completed" or "missed"
+}
+
+// CallSpeakingEvent tells the conversation a call participant started or stopped speaking,
+// so clients can highlight active speakers
+type CallSpeakingEvent struct {
+	CallID         uuid.UUID `json:"call_id"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	Speaking       bool      `json:"speaking"`
+}
+
+// CallSessionEndEvent tells a user's device its session was dropped from the call,
+// because the call was handed off to another device or a moderator removed them
+type CallSessionEndEvent struct {
+	CallID         uuid.UUID `json:"call_id"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	SessionID      string    `json:"session_id"`
+	Reason         string    `json:"reason"` // "handoff" or "kicked"
+}
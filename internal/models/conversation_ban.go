@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ConversationBan is a user removed from a group who can't be added back or rejoin
+type ConversationBan struct {
+	User      *User      `json:"user"`
+	BannedBy  *uuid.UUID `json:"banned_by"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// ParticipantRemoveEvent is sent to a group's participants, including the removed user,
+// when an owner or admin removes someone
+type ParticipantRemoveEvent struct {
+	ConversationID uuid.UUID `json:"conversation_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	RemovedBy      uuid.UUID `json:"removed_by"`
+	Banned         bool      `json:"banned"`
+}
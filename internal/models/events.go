@@ -1,6 +1,10 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 // ActiveCallInfo represents an active call in a conversation
 type ActiveCallInfo struct {
@@ -17,7 +21,10 @@ type ReadyEvent struct {
 	IncomingRequests []*FriendRequestWithUser   `json:"incoming_requests"`
 	OutgoingRequests []*FriendRequestWithUser   `json:"outgoing_requests"`
 	Conversations    []*ConversationWithDetails `json:"conversations"`
+	MessageRequests  []*MessageRequest          `json:"message_requests"`
 	ActiveCalls      []*ActiveCallInfo          `json:"active_calls"`
+	Drafts           []*Draft                   `json:"drafts"`
+	MissedCalls      int                        `json:"missed_calls"` // unseen missed calls
 }
 
 // Friend events
@@ -40,6 +47,12 @@ type RelationshipRemoveEvent struct {
 
 // Message events
 type MessageCreateEvent struct {
+	Message        *Message            `json:"message"`
+	ConversationID uuid.UUID           `json:"conversation_id"`
+	Translation    *MessageTranslation `json:"translation,omitempty"` // only for recipients auto-translating the conversation
+}
+
+type MessageUpdateEvent struct {
 	Message        *Message  `json:"message"`
 	ConversationID uuid.UUID `json:"conversation_id"`
 }
@@ -49,6 +62,70 @@ type MessageDeleteEvent struct {
 	ConversationID uuid.UUID `json:"conversation_id"`
 }
 
+// ConversationClearEvent is sent when history before a cutoff was cleared: to every participant
+// when a group owner cleared it, or only to the user's own devices for a DM copy
+type ConversationClearEvent struct {
+	ConversationID uuid.UUID `json:"conversation_id"`
+	Before         time.Time `json:"before"`
+	ForEveryone    bool      `json:"for_everyone"`
+}
+
+// MentionEvent is sent only to a mentioned user, so clients can highlight it even in muted chats
+type MentionEvent struct {
+	Message        *Message  `json:"message"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+}
+
+// Poll events (POLL_VOTE, POLL_CLOSE)
+type PollEvent struct {
+	Poll           *Poll     `json:"poll"`
+	MessageID      uuid.UUID `json:"message_id"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+}
+
+// Conversation list preferences, sent to the user's own devices
+type ConversationPinEvent struct {
+	ConversationID uuid.UUID `json:"conversation_id"`
+	Pinned         bool      `json:"pinned"`
+}
+
+type ConversationOrderEvent struct {
+	ConversationIDs []uuid.UUID `json:"conversation_ids"`
+}
+
+// Thread events
+type ThreadMessageCreateEvent struct {
+	Message        *Message  `json:"message"`
+	ParentID       uuid.UUID `json:"parent_id"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	ReplyCount     int       `json:"reply_count"`
+}
+
+type ThreadUpdateEvent struct {
+	ParentID       uuid.UUID `json:"parent_id"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	ReplyCount     int       `json:"reply_count"`
+}
+
+// Typing and read receipt events (batched per conversation by the realtime layer)
+type TypingEvent struct {
+	ConversationID uuid.UUID   `json:"conversation_id"`
+	UserIDs        []uuid.UUID `json:"user_ids"`
+}
+
+type ReadReceiptEvent struct {
+	ConversationID uuid.UUID      `json:"conversation_id"`
+	Receipts       []*ReadReceipt `json:"receipts"`
+}
+
+// MessageDeliveredEvent tells DM senders a recipient's device received messages up to MessageID
+type MessageDeliveredEvent struct {
+	ConversationID uuid.UUID `json:"conversation_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	MessageID      uuid.UUID `json:"message_id"`
+	DeliveredAt    time.Time `json:"delivered_at"`
+}
+
 // Reaction events
 type ReactionAddEvent struct {
 	Reaction       *Reaction `json:"reaction"`
@@ -74,4 +151,41 @@ type CallStateEvent struct {
 	ConversationID uuid.UUID   `json:"conversation_id"`
 	CallID         *uuid.UUID  `json:"call_id"`         // nil = no active call
 	Participants   []uuid.UUID `json:"participants"`    // who is currently in the call
+	ServerMuted    []uuid.UUID `json:"server_muted"`    // participants muted by a moderator
+	PushToTalk     bool        `json:"push_to_talk"`
+	// ScheduledCallID is set when the scheduler opens a scheduled call
+	ScheduledCallID *uuid.UUID `json:"scheduled_call_id,omitempty"`
+	// Media is the mute, deafen and camera state of each participant
+	Media []CallMediaState `json:"media"`
+	// Reconnecting lists participants whose connection dropped and who are still
+	// within the reconnection grace period
+	Reconnecting []uuid.UUID `json:"reconnecting"`
+	// Devices is how many sessions (devices) each participant is in the call from
+	Devices map[uuid.UUID]int `json:"devices"`
+	// OnHold lists participants who put the call on hold to take another one
+	OnHold []uuid.UUID `json:"on_hold"`
+}
+
+// CallParticipantUpdateEvent is sent when a moderator mutes or unmutes a participant
+type CallParticipantUpdateEvent struct {
+	CallID         uuid.UUID `json:"call_id"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	ServerMuted    bool      `json:"server_muted"`
+}
+
+// ConversationDeleteEvent is sent to participants when the owner deletes a group.
+// Clients drop it from the list; the owner can still restore it until RestorableUntil.
+type ConversationDeleteEvent struct {
+	ConversationID  uuid.UUID `json:"conversation_id"`
+	RestorableUntil time.Time `json:"restorable_until"`
+}
+
+// SecurityAlertEvent is sent to a user when their account is signed in to from a device
+// and IP it hasn't been used from before
+type SecurityAlertEvent struct {
+	Type      string    `json:"type"` // "new_device_login"
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
 }
@@ -0,0 +1,17 @@
+package models
+
+// GIF is a search result from the configured GIF provider
+type GIF struct {
+	ID         string `json:"id"`
+	Title      string `json:"title"`
+	URL        string `json:"url"`
+	PreviewURL string `json:"preview_url"`
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	Size       int64  `json:"size"`
+}
+
+type GIFSearchResult struct {
+	Results []*GIF `json:"results"`
+	Next    string `json:"next,omitempty"` // cursor for the next page
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GraphSnapshot is an anonymized export of the friendship or conversation graph. Node IDs
+// are assigned per snapshot and can't be mapped back to users or across snapshots.
+type GraphSnapshot struct {
+	ID         uuid.UUID   `json:"id"`
+	Kind       string      `json:"kind"` // "friendship" or "conversation"
+	ComputedAt time.Time   `json:"computed_at"`
+	Stats      GraphStats  `json:"stats"`
+	Nodes      []GraphNode `json:"nodes,omitempty"`
+	Edges      []GraphEdge `json:"edges,omitempty"`
+}
+
+type GraphNode struct {
+	ID     int `json:"id"`
+	Degree int `json:"degree"`
+}
+
+// GraphEdge links two nodes; Weight is the number of shared conversations (1 for friendships)
+type GraphEdge struct {
+	Source int `json:"source"`
+	Target int `json:"target"`
+	Weight int `json:"weight"`
+}
+
+type GraphStats struct {
+	Nodes            int           `json:"nodes"`
+	Edges            int           `json:"edges"`
+	Isolated         int           `json:"isolated"` // nodes without edges
+	Components       int           `json:"components"`
+	LargestComponent int           `json:"largest_component"`
+	Degree           DegreeStats   `json:"degree"`
+	DegreeHistogram  []DegreeCount `json:"degree_histogram"`
+}
+
+type DegreeStats struct {
+	Min    int     `json:"min"`
+	Max    int     `json:"max"`
+	Mean   float64 `json:"mean"`
+	Median int     `json:"median"`
+	P90    int     `json:"p90"`
+}
+
+// DegreeCount is how many nodes have a degree (degrees above 100 are bucketed into 100)
+type DegreeCount struct {
+	Degree int `json:"degree"`
+	Nodes  int `json:"nodes"`
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GroupInvite asks a user to join a group. Users who opted out of being re-added after
+// leaving can only come back through an invite they accept.
+type GroupInvite struct {
+	ConversationID uuid.UUID `json:"conversation_id"`
+	Name           *string   `json:"name"`
+	AvatarURL      *string   `json:"avatar_url"`
+	InvitedBy      *User     `json:"invited_by"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type InviteToGroupRequest struct {
+	UserID string `json:"user_id" validate:"required,uuid"`
+}
+
+// GroupInviteLink is a shareable code anyone can use to join a group while it's valid
+type GroupInviteLink struct {
+	Code           string     `json:"code"`
+	ConversationID uuid.UUID  `json:"conversation_id"`
+	CreatedBy      *uuid.UUID `json:"created_by"`
+	MaxUses        *int       `json:"max_uses"` // nil = unlimited
+	Uses           int        `json:"uses"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type CreateInviteLinkRequest struct {
+	MaxUses        int `json:"max_uses,omitempty" validate:"omitempty,min=1,max=10000"`        // 0 = unlimited
+	ExpiresInHours int `json:"expires_in_hours,omitempty" validate:"omitempty,min=1,max=8760"` // 0 = never
+}
+
+// GroupJoinRequest is a user who used an invite link to a group with join approval and
+// waits for an owner or admin to let them in
+type GroupJoinRequest struct {
+	ConversationID uuid.UUID `json:"conversation_id"`
+	User           *User     `json:"user"`
+	InviteCode     *string   `json:"invite_code"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// JoinRequestResolveEvent tells the requester and the group's approvers that a join
+// request was approved or denied
+type JoinRequestResolveEvent struct {
+	ConversationID uuid.UUID `json:"conversation_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	Approved       bool      `json:"approved"`
+	ResolvedBy     uuid.UUID `json:"resolved_by"`
+}
+
+// DiscoverableGroup is a public group as listed in discovery
+type DiscoverableGroup struct {
+	ID             uuid.UUID `json:"id"`
+	Name           *string   `json:"name"`
+	Description    *string   `json:"description"`
+	AvatarURL      *string   `json:"avatar_url"`
+	MemberCount    int       `json:"member_count"`
+	JoinApproval   bool      `json:"join_approval"`
+	Joined         bool      `json:"joined"`
+	RequestPending bool      `json:"request_pending"`
+}
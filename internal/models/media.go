@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BrokenMedia is a stored file URL the link checker could not find in the bucket
+type BrokenMedia struct {
+	Kind          string    `json:"kind"` // "attachment", "user_avatar", "group_avatar", "sticker", "sticker_pack_cover"
+	RecordID      uuid.UUID `json:"record_id"`
+	URL           string    `json:"url"`
+	FirstSeenAt   time.Time `json:"first_seen_at"`
+	LastCheckedAt time.Time `json:"last_checked_at"`
+}
+
+type MediaCheckRun struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Checked    int       `json:"checked"`
+	Broken     int       `json:"broken"`
+	Repaired   int       `json:"repaired"` // previously broken records that resolve again
+}
+
+// MediaRepairReport lists broken media for admins, e.g. after a bucket migration
+type MediaRepairReport struct {
+	LastRun *MediaCheckRun `json:"last_run"` // nil before the first run
+	ByKind  map[string]int `json:"by_kind"`
+	Broken  []BrokenMedia  `json:"broken"`
+}
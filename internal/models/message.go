@@ -10,8 +10,14 @@ type Message struct {
 	ID             uuid.UUID  `json:"id" db:"id"`
 	ConversationID uuid.UUID  `json:"conversation_id" db:"conversation_id"`
 	SenderID       uuid.UUID  `json:"sender_id" db:"sender_id"`
-	Type           string     `json:"type" db:"type"` // "text" (default), "call"
+	Type           string     `json:"type" db:"type"` // "text" (default), "call", "call_transcript", "call_reminder", "poll", "sticker"
 	Content        string     `json:"content" db:"content"`
+	ParentID       *uuid.UUID `json:"parent_id,omitempty" db:"parent_id"` // set for thread replies
+	ReplyCount     int        `json:"reply_count" db:"reply_count"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty" db:"expires_at"` // set in disappearing-message conversations
+	DeletedAt      *time.Time `json:"deleted_at,omitempty" db:"deleted_at"` // tombstone: content and attachments are gone
+	Nonce          *string    `json:"nonce,omitempty" db:"client_nonce"`    // echoed from the send request so clients can match optimistic copies
+	Language       *string    `json:"language,omitempty" db:"language"`     // detected ISO 639-1 code, if it could be told
 	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
 
@@ -19,6 +25,16 @@ type Message struct {
 	Sender      *User         `json:"sender,omitempty"`
 	Attachments []*Attachment `json:"attachments,omitempty"`
 	Reactions   []*Reaction   `json:"reactions,omitempty"`
+	Mentions    []*Mention    `json:"mentions,omitempty"`
+	Embeds      []*Embed      `json:"embeds,omitempty"`
+	Poll        *Poll         `json:"poll,omitempty"`
+	Sticker     *Sticker      `json:"sticker,omitempty"`
+}
+
+// Mention is a user @mentioned in a message
+type Mention struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Username string    `json:"username"`
 }
 
 // Call message content structure (stored as JSON in Content field)
@@ -29,6 +45,47 @@ type CallMessageContent struct {
 	Status       string   `json:"status"`        // "completed", "missed", "cancelled"
 }
 
+// MembershipMessageContent is the content of a "membership" system message
+type MembershipMessageContent struct {
+	Action  string      `json:"action"` // "joined", "left", "added", "removed"
+	UserIDs []uuid.UUID `json:"user_ids"`
+	ActorID *uuid.UUID  `json:"actor_id,omitempty"` // who added or removed them
+}
+
+// GroupUpdateMessageContent is the content of a "group_update" system message
+type GroupUpdateMessageContent struct {
+	ActorID   uuid.UUID `json:"actor_id"`
+	Changes   []string  `json:"changes"` // "name", "avatar"
+	Name      *string   `json:"name,omitempty"`
+	AvatarURL *string   `json:"avatar_url,omitempty"`
+}
+
+// Embed is a link preview built from a page's OpenGraph / Twitter card metadata
+type Embed struct {
+	URL         string  `json:"url" db:"url"`
+	Title       string  `json:"title" db:"title"`
+	Description string  `json:"description" db:"description"`
+	SiteName    string  `json:"site_name" db:"site_name"`
+	ImageURL    *string `json:"image_url,omitempty" db:"image_url"`
+}
+
+type Poll struct {
+	MessageID      uuid.UUID     `json:"message_id"`
+	AuthorID       uuid.UUID     `json:"author_id"`
+	Question       string        `json:"question"`
+	MultipleChoice bool          `json:"multiple_choice"`
+	ClosedAt       *time.Time    `json:"closed_at,omitempty"`
+	Options        []*PollOption `json:"options"`
+	TotalVoters    int           `json:"total_voters"`
+}
+
+type PollOption struct {
+	ID       uuid.UUID   `json:"id"`
+	Text     string      `json:"text"`
+	Votes    int         `json:"votes"`
+	VoterIDs []uuid.UUID `json:"voter_ids"`
+}
+
 type Reaction struct {
 	ID        uuid.UUID `json:"id" db:"id"`
 	MessageID uuid.UUID `json:"message_id" db:"message_id"`
@@ -40,16 +97,18 @@ type Reaction struct {
 	User *User `json:"user,omitempty"`
 }
 
+// ReactionGroup is the API version 2 shape of a message's reactions (see apiversion)
 type ReactionGroup struct {
-	Emoji string  `json:"emoji"`
-	Count int     `json:"count"`
-	Users []*User `json:"users"`
+	Emoji   string      `json:"emoji"`
+	Count   int         `json:"count"`
+	UserIDs []uuid.UUID `json:"user_ids"`
+	Users   []*User     `json:"users"`
 }
 
 type Attachment struct {
 	ID        uuid.UUID `json:"id" db:"id"`
 	MessageID uuid.UUID `json:"message_id" db:"message_id"`
-	Type      string    `json:"type" db:"type"`           // "image", "file", etc.
+	Type      string    `json:"type" db:"type"`           // "image", "file", "gif", "voice", etc.
 	URL       string    `json:"url" db:"url"`
 	Filename  string    `json:"filename" db:"filename"`
 	Size      int64     `json:"size" db:"size"`
@@ -62,8 +121,22 @@ type Conversation struct {
 	ID        uuid.UUID  `json:"id" db:"id"`
 	Type      string     `json:"type" db:"type"` // "dm" or "group"
 	Name      *string    `json:"name" db:"name"` // for groups
+	// Description is the group's topic line
+	Description *string `json:"description" db:"description"`
+	// JoinApproval makes joins through invite links wait for an owner or admin
+	JoinApproval bool `json:"join_approval" db:"join_approval"`
+	// Public groups are listed in discovery and anyone can join them
+	Public bool `json:"public" db:"is_public"`
+	// AnnouncementOnly lets only owners and admins post
+	AnnouncementOnly bool `json:"announcement_only" db:"announcement_only"`
+	// CallPermission is who can start calls: "everyone" or "admins"
+	CallPermission string `json:"call_permission" db:"call_permission"`
 	AvatarURL *string    `json:"avatar_url" db:"avatar_url"`
 	OwnerID   *uuid.UUID `json:"owner_id" db:"owner_id"`
+	// MessageTTL is the disappearing messages timer in seconds (nil = off)
+	MessageTTL *int      `json:"message_ttl" db:"message_ttl_seconds"`
+	// RequestStatus is "pending" or "declined" while a DM is a message request (nil = accepted)
+	RequestStatus *string `json:"request_status,omitempty" db:"request_status"`
 	CreatedAt time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
 
@@ -82,6 +155,11 @@ type ConversationParticipant struct {
 type SendMessageRequest struct {
 	Content       string   `json:"content" validate:"max=4000"`
 	AttachmentIDs []string `json:"attachment_ids,omitempty"`
+	// StickerID sends a sticker message; content and attachments must be empty
+	StickerID *string `json:"sticker_id,omitempty"`
+	// Nonce is generated by the client per message; a retry with the same nonce returns
+	// the message created by the first attempt instead of sending a duplicate
+	Nonce *string `json:"nonce,omitempty"`
 }
 
 type CreateDMRequest struct {
@@ -97,8 +175,97 @@ type AddParticipantsRequest struct {
 	UserIDs []string `json:"user_ids" validate:"required,min=1"`
 }
 
+// UpdateGroupRequest changes the fields that are present; an empty description clears it
 type UpdateGroupRequest struct {
-	Name string `json:"name" validate:"max=100"`
+	Name         *string `json:"name" validate:"omitempty,max=100"`
+	Description  *string `json:"description" validate:"omitempty,max=500"`
+	JoinApproval *bool   `json:"join_approval"`
+	Public       *bool   `json:"public"`
+	// AnnouncementOnly toggles whether only owners and admins can post
+	AnnouncementOnly *bool `json:"announcement_only"`
+	// CallPermission sets who can start calls: "everyone" or "admins"
+	CallPermission *string `json:"call_permission"`
+}
+
+type UpdateParticipantRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=owner admin member"`
+}
+
+// SetNicknameRequest sets a participant's nickname; an empty one clears it
+type SetNicknameRequest struct {
+	Nickname string `json:"nickname" validate:"max=32"`
+}
+
+type MarkReadRequest struct {
+	MessageID string `json:"message_id" validate:"required,uuid"`
+}
+
+type ReadReceipt struct {
+	UserID    uuid.UUID `json:"user_id"`
+	MessageID uuid.UUID `json:"message_id"`
+	ReadAt    time.Time `json:"read_at"`
+}
+
+type MarkDeliveredRequest struct {
+	MessageID string `json:"message_id" validate:"required,uuid"`
+}
+
+// RecipientStatus is how far a message got for one recipient: "sent", "delivered" or "read"
+type RecipientStatus struct {
+	UserID uuid.UUID `json:"user_id"`
+	Status string    `json:"status"`
+}
+
+type MessageDeliveryStatus struct {
+	MessageID  uuid.UUID          `json:"message_id"`
+	Recipients []*RecipientStatus `json:"recipients"`
+}
+
+type SetMessageTTLRequest struct {
+	// Seconds until messages disappear; 0 turns disappearing messages off
+	MessageTTL int `json:"message_ttl" validate:"oneof=0 3600 86400 604800 2592000"`
+}
+
+type CreatePollRequest struct {
+	Question       string   `json:"question" validate:"required,max=300"`
+	Options        []string `json:"options" validate:"required,min=2,max=10,dive,required,max=100"`
+	MultipleChoice bool     `json:"multiple_choice"`
+}
+
+type PollVoteRequest struct {
+	OptionID string `json:"option_id" validate:"required,uuid"`
+}
+
+type ReorderConversationsRequest struct {
+	// Conversation IDs in the desired order; conversations not listed fall back to activity order
+	ConversationIDs []string `json:"conversation_ids" validate:"max=500,dive,uuid"`
+}
+
+// MessageContext is a page of history around a target message (permalinks, jump-to-message)
+type MessageContext struct {
+	TargetID      uuid.UUID  `json:"target_id"`
+	ParentID      *uuid.UUID `json:"parent_id,omitempty"` // set when the target is a thread reply
+	Parent        *Message   `json:"parent,omitempty"`    // the thread's root message, for the thread header
+	Messages      []*Message `json:"messages"`
+	HasMoreBefore bool       `json:"has_more_before"`
+	HasMoreAfter  bool       `json:"has_more_after"`
+}
+
+// StarredMessage is a message the user bookmarked
+type StarredMessage struct {
+	Message   *Message  `json:"message"`
+	StarredAt time.Time `json:"starred_at"`
+}
+
+// Draft is a user's unsent message text in a conversation
+type Draft struct {
+	ConversationID uuid.UUID `json:"conversation_id" db:"conversation_id"`
+	Content        string    `json:"content" db:"content"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type SaveDraftRequest struct {
+	Content string `json:"content" validate:"max=4000"`
 }
 
 type AddReactionRequest struct {
@@ -106,14 +273,23 @@ type AddReactionRequest struct {
 }
 
 type ConversationWithDetails struct {
-	ID           uuid.UUID  `json:"id"`
-	Type         string     `json:"type"`
-	Name         *string    `json:"name"`
-	AvatarURL    *string    `json:"avatar_url"`
-	OwnerID      *uuid.UUID `json:"owner_id"`
-	Participants []*User    `json:"participants"`
-	LastMessage  *Message   `json:"last_message"`
-	UpdatedAt    time.Time  `json:"updated_at"`
+	ID               uuid.UUID  `json:"id"`
+	Type             string     `json:"type"`
+	Name             *string    `json:"name"`
+	Description      *string    `json:"description"`
+	JoinApproval     bool       `json:"join_approval"`
+	Public           bool       `json:"public"`
+	AnnouncementOnly bool       `json:"announcement_only"`
+	CallPermission   string     `json:"call_permission"`
+	AvatarURL        *string    `json:"avatar_url"`
+	OwnerID          *uuid.UUID `json:"owner_id"`
+	MessageTTL       *int       `json:"message_ttl"`
+	RequestStatus    *string    `json:"request_status,omitempty"` // "pending"/"declined" while a DM is a message request
+	Pinned           bool       `json:"pinned"`                   // per-user: pinned to the top of the list
+	SortOrder        *int       `json:"sort_order"`               // per-user custom position (nil = by activity)
+	Participants     []*User    `json:"participants"`
+	LastMessage      *Message   `json:"last_message"`
+	UpdatedAt        time.Time  `json:"updated_at"`
 }
 
 // SSE Event types
@@ -126,3 +302,12 @@ type NewMessageEvent struct {
 	Message      *Message  `json:"message"`
 	Conversation uuid.UUID `json:"conversation_id"`
 }
+
+// DeletedGroup is a group its owner deleted that can still be restored
+type DeletedGroup struct {
+	ID              uuid.UUID `json:"id"`
+	Name            *string   `json:"name"`
+	AvatarURL       *string   `json:"avatar_url"`
+	DeletedAt       time.Time `json:"deleted_at"`
+	RestorableUntil time.Time `json:"restorable_until"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MessageRequest is a DM from a non-friend waiting for the recipient to accept it
+type MessageRequest struct {
+	ConversationID uuid.UUID `json:"conversation_id"`
+	From           *User     `json:"from"`
+	LastMessage    *Message  `json:"last_message,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// MessageRequestEvent tells both sides that a message request was accepted or declined
+type MessageRequestEvent struct {
+	ConversationID uuid.UUID `json:"conversation_id"`
+	UserID         uuid.UUID `json:"user_id"` // who answered the request
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type NotificationSettings struct {
+	UserID       uuid.UUID `json:"user_id" db:"user_id"`
+	EmailEnabled bool      `json:"email_enabled" db:"email_enabled"`
+	// Mentions are posted to the server's notification webhook unless turned off
+	WebhookEnabled bool `json:"webhook_enabled" db:"webhook_enabled"`
+	// Quiet hours ("HH:MM" local time, nil = off); notifications are held back in between
+	QuietStart *string   `json:"quiet_start" db:"quiet_start"`
+	QuietEnd   *string   `json:"quiet_end" db:"quiet_end"`
+	TimeZone   string    `json:"time_zone"` // the user's zone, read-only here
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+	// Do Not Disturb: calls don't ring and notifications aren't sent while it's on.
+	// DNDUntil is when it turns off by itself (nil = when the user turns it off).
+	DND      bool       `json:"dnd" db:"dnd"`
+	DNDUntil *time.Time `json:"dnd_until" db:"dnd_until"`
+}
+
+// Request DTOs
+type UpdateNotificationSettingsRequest struct {
+	EmailEnabled   *bool `json:"email_enabled"`
+	WebhookEnabled *bool `json:"webhook_enabled"`
+	// Both set to "HH:MM" to enable quiet hours, both empty to turn them off
+	QuietStart *string `json:"quiet_start"`
+	QuietEnd   *string `json:"quiet_end"`
+}
+
+type SetDoNotDisturbRequest struct {
+	Enabled bool `json:"enabled"`
+	// Optional time DND turns off by itself
+	Until *time.Time `json:"until"`
+}
+
+// PushDevice is a mobile device registered for VoIP pushes that wake it for incoming calls
+type PushDevice struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Platform  string    `json:"platform"` // "apns_voip" (iOS PushKit) or "fcm" (Android)
+	Token     string    `json:"token"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type RegisterPushDeviceRequest struct {
+	Platform string `json:"platform"`
+	Token    string `json:"token"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// OAuthIdentity is an external account (Google, GitHub, Discord) linked for signing in
+type OAuthIdentity struct {
+	Provider  string    `json:"provider"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type OAuthProvidersResponse struct {
+	Providers []string `json:"providers"`
+}
+
+type OAuthLinkResponse struct {
+	URL string `json:"url"` // provider consent page to send the browser to
+}
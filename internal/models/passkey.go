@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Passkey is a WebAuthn credential a user can sign in with instead of their password
+type Passkey struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// PasskeyCredential is the PublicKeyCredential a browser returns from
+// navigator.credentials.create() or .get(), with binary fields base64url-encoded
+type PasskeyCredential struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON string `json:"clientDataJSON"`
+		// Registration
+		AttestationObject string `json:"attestationObject,omitempty"`
+		// Sign-in
+		AuthenticatorData string `json:"authenticatorData,omitempty"`
+		Signature         string `json:"signature,omitempty"`
+		UserHandle        string `json:"userHandle,omitempty"`
+	} `json:"response"`
+}
+
+type RegisterPasskeyRequest struct {
+	Name       string            `json:"name" validate:"max=100"`
+	Credential PasskeyCredential `json:"credential"`
+}
+
+type PasskeyLoginRequest struct {
+	Credential PasskeyCredential `json:"credential"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AccountStats is a user's activity summary ("year in review")
+type AccountStats struct {
+	From             string                 `json:"from"`
+	To               string                 `json:"to"`
+	TotalMessages    int                    `json:"total_messages"`
+	TotalCallMinutes int                    `json:"total_call_minutes"`
+	Days             []DailyActivity        `json:"days"`
+	TopConversations []ConversationActivity `json:"top_conversations"`
+	ComputedThrough  *time.Time             `json:"computed_through"` // last aggregated day, nil before the first run
+}
+
+type DailyActivity struct {
+	Date        string `json:"date"`
+	Messages    int    `json:"messages"`
+	CallMinutes int    `json:"call_minutes"`
+}
+
+type ConversationActivity struct {
+	ConversationID uuid.UUID `json:"conversation_id"`
+	Type           string    `json:"type"`
+	Name           *string   `json:"name"`
+	Messages       int       `json:"messages"`
+	CallMinutes    int       `json:"call_minutes"`
+}
+
+// ConversationStats summarizes a group's activity for its owner
+type ConversationStats struct {
+	ConversationID  uuid.UUID        `json:"conversation_id"`
+	From            string           `json:"from"`
+	To              string           `json:"to"`
+	TotalMessages   int              `json:"total_messages"`
+	Members         []MemberActivity `json:"members"`
+	MessagesByHour  [24]int          `json:"messages_by_hour"` // UTC hours
+	PeakHours       []int            `json:"peak_hours"`       // busiest UTC hours, busiest first
+	TopReactions    []ReactionCount  `json:"top_reactions"`
+	ComputedThrough *time.Time       `json:"computed_through"`
+}
+
+type MemberActivity struct {
+	UserID      uuid.UUID `json:"user_id"`
+	Username    *string   `json:"username"`
+	Messages    int       `json:"messages"`
+	CallMinutes int       `json:"call_minutes"`
+}
+
+type ReactionCount struct {
+	Emoji string `json:"emoji"`
+	Count int    `json:"count"`
+}
@@ -12,6 +12,7 @@ type StickerPack struct {
 	Description string     `json:"description" db:"description"`
 	CoverURL    string     `json:"cover_url" db:"cover_url"`
 	IsOfficial  bool       `json:"is_official" db:"is_official"`
+	NSFW        bool       `json:"nsfw" db:"nsfw"` // hidden from users under the adult age
 	CreatorID   *uuid.UUID `json:"creator_id" db:"creator_id"`
 	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
@@ -44,6 +45,7 @@ type UserStickerPack struct {
 type CreateStickerPackRequest struct {
 	Name        string `json:"name" validate:"required,max=64"`
 	Description string `json:"description" validate:"max=256"`
+	NSFW        bool   `json:"nsfw"`
 }
 
 type AddStickerRequest struct {
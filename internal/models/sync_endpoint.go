@@ -0,0 +1,51 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SyncEndpoint is an external read model (support desk, CRM) mirroring a conversation.
+// It receives signed, ordered event batches and acknowledges them with a cursor.
+type SyncEndpoint struct {
+	ID              uuid.UUID  `json:"id"`
+	ConversationID  uuid.UUID  `json:"conversation_id"`
+	CreatedBy       *uuid.UUID `json:"created_by"`
+	URL             string     `json:"url"`
+	Secret          string     `json:"secret,omitempty"` // only returned on creation
+	AckedSeq        int64      `json:"acked_seq"`
+	Failures        int        `json:"failures"`
+	LastError       *string    `json:"last_error"`
+	LastDeliveredAt *time.Time `json:"last_delivered_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+type CreateSyncEndpointRequest struct {
+	URL string `json:"url" validate:"required,url,max=2048"`
+}
+
+// SyncEvent is one entry of a conversation's sync feed, numbered in recording order
+type SyncEvent struct {
+	Seq       int64           `json:"seq"`
+	Type      string          `json:"type"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// SyncBatch is the body POSTed to a sync endpoint. The endpoint answers 2xx with
+// {"ack": seq} to acknowledge every event up to and including seq.
+type SyncBatch struct {
+	EndpointID     uuid.UUID    `json:"endpoint_id"`
+	ConversationID uuid.UUID    `json:"conversation_id"`
+	Events         []*SyncEvent `json:"events"`
+}
+
+// SyncMembershipEvent records participants joining or leaving a conversation in its sync feed
+type SyncMembershipEvent struct {
+	ConversationID uuid.UUID   `json:"conversation_id"`
+	UserIDs        []uuid.UUID `json:"user_ids"`
+	ActorID        *uuid.UUID  `json:"actor_id"`
+	Reason         string      `json:"reason"` // added, invite, link, discovery, approved, left, removed, banned
+}
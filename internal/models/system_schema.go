@@ -0,0 +1,17 @@
+package models
+
+// SystemMessageSchema describes the JSON content of one version of a system message
+// type. Clients that don't know a type (or a newer version of it) show Fallback.
+type SystemMessageSchema struct {
+	Type     string               `json:"type"`
+	Version  int                  `json:"version"`
+	Fields   []SystemMessageField `json:"fields"`
+	Fallback string               `json:"fallback"`
+}
+
+// SystemMessageField is one key of a system message's content
+type SystemMessageField struct {
+	Name     string `json:"name"`
+	Kind     string `json:"kind"` // "string", "number", "boolean", "array" or "object"
+	Required bool   `json:"required"`
+}
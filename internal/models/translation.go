@@ -0,0 +1,22 @@
+package models
+
+// MessageTranslation is a message's content in a recipient's auto-translate language
+type MessageTranslation struct {
+	Language string `json:"language"`
+	Content  string `json:"content"`
+}
+
+// TranslationSettings is a user's auto-translate setting for one conversation
+type TranslationSettings struct {
+	Enabled        bool    `json:"enabled"`
+	TargetLanguage *string `json:"target_language,omitempty"`
+	// Most common language among the conversation's recent messages
+	ConversationLanguage *string `json:"conversation_language,omitempty"`
+	// Whether the server has a translation provider configured
+	Available bool `json:"available"`
+}
+
+type SetTranslationRequest struct {
+	Enabled        bool   `json:"enabled"`
+	TargetLanguage string `json:"target_language"`
+}
@@ -15,6 +15,11 @@ type User struct {
 	Status       string     `json:"status" db:"status"`
 	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
+
+	// Role in the conversation, set only in participant lists
+	Role string `json:"role,omitempty" db:"-"`
+	// Nickname in the conversation, set in participant lists and on message senders
+	Nickname *string `json:"nickname,omitempty" db:"-"`
 }
 
 type RefreshToken struct {
@@ -27,8 +32,43 @@ type RefreshToken struct {
 
 // Auth requests/responses
 type RegisterRequest struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=8"`
+	Email      string `json:"email" validate:"required,email"`
+	Password   string `json:"password" validate:"required,min=8"`
+	InviteCode string `json:"invite_code,omitempty"` // required when registration is invite-only
+	Birthdate  string `json:"birthdate,omitempty" validate:"omitempty,datetime=2006-01-02"`
+	Region     string `json:"region,omitempty" validate:"omitempty,iso3166_1_alpha2"` // selects regional minimum age
+}
+
+type SetBirthdateRequest struct {
+	Birthdate string `json:"birthdate" validate:"required,datetime=2006-01-02"`
+	Region    string `json:"region,omitempty" validate:"omitempty,iso3166_1_alpha2"`
+}
+
+type WaitlistRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type RegistrationInfo struct {
+	InviteOnly bool `json:"invite_only"`
+	// CAPTCHA widget to solve on register, login and friend requests by username (empty = none)
+	CaptchaProvider string `json:"captcha_provider,omitempty"`
+	CaptchaSiteKey  string `json:"captcha_site_key,omitempty"`
+}
+
+// Invite codes for invite-only registration
+type InviteCode struct {
+	Code      string     `json:"code" db:"code"`
+	MaxUses   int        `json:"max_uses" db:"max_uses"`
+	Uses      int        `json:"uses" db:"uses"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	Note      string     `json:"note" db:"note"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+type CreateInviteCodeRequest struct {
+	MaxUses        int    `json:"max_uses" validate:"required,min=1,max=10000"`
+	ExpiresInHours int    `json:"expires_in_hours" validate:"min=0"`
+	Note           string `json:"note" validate:"max=200"`
 }
 
 type LoginRequest struct {
@@ -36,6 +76,14 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
+type MagicLinkRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type MagicLinkLoginRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
 type SetUsernameRequest struct {
 	Username string `json:"username" validate:"required,min=3,max=32,alphanum"`
 }
@@ -54,3 +102,18 @@ type TokenResponse struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
 }
+
+// TimeZoneSettings is the zone scheduling features use for the user ("" = unknown, UTC)
+type TimeZoneSettings struct {
+	TimeZone string `json:"time_zone"`
+	Explicit bool   `json:"explicit"` // false when inferred from the client
+}
+
+type SetTimeZoneRequest struct {
+	TimeZone string `json:"time_zone" validate:"max=64"` // IANA name; empty = infer from clients again
+}
+
+// AccountDeletionResponse tells when a deleted account is anonymized; signing in before then recovers it
+type AccountDeletionResponse struct {
+	DeleteAfter time.Time `json:"delete_after"`
+}
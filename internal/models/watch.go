@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WatchSession is a conversation's synchronized playback state.
+// PositionMs was the position at UpdatedAt; while playing, clients add the time elapsed since then,
+// using ServerTime to correct for their clock offset.
+type WatchSession struct {
+	ConversationID uuid.UUID `json:"conversation_id"`
+	MediaURL       string    `json:"media_url"`
+	StartedBy      uuid.UUID `json:"started_by"`
+	Playing        bool      `json:"playing"`
+	PositionMs     int64     `json:"position_ms"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	UpdatedBy      uuid.UUID `json:"updated_by"`
+	ServerTime     time.Time `json:"server_time"`
+}
+
+type StartWatchSessionRequest struct {
+	MediaURL string `json:"media_url" validate:"required,http_url,max=2048"`
+}
+
+type WatchControlRequest struct {
+	Action     string `json:"action" validate:"required,oneof=play pause seek"`
+	PositionMs *int64 `json:"position_ms" validate:"omitempty,min=0"` // required for seek
+}
+
+type WatchSessionEndEvent struct {
+	ConversationID uuid.UUID `json:"conversation_id"`
+	UserID         uuid.UUID `json:"user_id"` // who ended it
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WidgetKey grants read-only public access to one group (for embeddable chat widgets)
+type WidgetKey struct {
+	Key            string    `json:"key" db:"key"`
+	ConversationID uuid.UUID `json:"conversation_id" db:"conversation_id"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+// WidgetRoom is the public view of a group
+type WidgetRoom struct {
+	ID               uuid.UUID `json:"id"`
+	Name             *string   `json:"name"`
+	AvatarURL        *string   `json:"avatar_url"`
+	ParticipantCount int       `json:"participant_count"`
+	OnlineCount      int       `json:"online_count"`
+}
+
+// WidgetMessage is the public view of a message, without private user fields
+type WidgetMessage struct {
+	ID              uuid.UUID `json:"id"`
+	Type            string    `json:"type"`
+	Content         string    `json:"content"`
+	AuthorName      *string   `json:"author_name"`
+	AuthorAvatarURL *string   `json:"author_avatar_url"`
+	CreatedAt       time.Time `json:"created_at"`
+}
@@ -0,0 +1,140 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/user/bla-back/internal/models"
+	"github.com/user/bla-back/internal/outbound"
+)
+
+// EmailConfig configures SMTP delivery
+type EmailConfig struct {
+	Addr     string // host:port
+	Username string // empty = no auth
+	Password string
+	From     string
+}
+
+// EmailChannel sends notifications by email over SMTP
+type EmailChannel struct {
+	config EmailConfig
+}
+
+func NewEmailChannel(config EmailConfig) *EmailChannel {
+	return &EmailChannel{config: config}
+}
+
+func (c *EmailChannel) Name() string {
+	return "email"
+}
+
+// Enabled matches recipients who opted in to email notifications
+func (c *EmailChannel) Enabled(settings *models.NotificationSettings) bool {
+	return settings != nil && settings.EmailEnabled
+}
+
+func (c *EmailChannel) Send(ctx context.Context, n *Notification) error {
+	if n.Recipient == nil || n.Recipient.Email == "" {
+		return nil
+	}
+
+	subject := fmt.Sprintf("%s mentioned you", n.SenderName)
+	body := fmt.Sprintf("%s mentioned you:\r\n\r\n%s\r\n\r\nOpen the conversation: %s\r\n", n.SenderName, n.Excerpt, n.Link)
+	return c.sendMail(n.Recipient.Email, subject, body)
+}
+
+// SendLoginAlert tells a user their account was signed in to from a new device. It's a
+// security notice, so it goes out regardless of notification settings.
+func (c *EmailChannel) SendLoginAlert(ctx context.Context, to string, alert *models.SecurityAlertEvent) error {
+	body := fmt.Sprintf("Your account was just signed in to from a new device.\r\n\r\n"+
+		"Time: %s\r\nIP address: %s\r\nDevice: %s\r\n\r\n"+
+		"If this was you, you can ignore this email. If not, log out on all devices from your account settings right away.\r\n",
+		alert.CreatedAt.UTC().Format("2006-01-02 15:04 MST"), alert.IP, alert.UserAgent)
+	return c.sendMail(to, "New sign-in to your account", body)
+}
+
+// SendMagicLink emails a single-use sign-in link
+func (c *EmailChannel) SendMagicLink(ctx context.Context, to, link string, validFor time.Duration) error {
+	body := fmt.Sprintf("Use this link to sign in. It works once and expires in %d minutes:\r\n\r\n%s\r\n\r\n"+
+		"If you didn't ask for it, you can ignore this email.\r\n", int(validFor.Minutes()), link)
+	return c.sendMail(to, "Your sign-in link", body)
+}
+
+func (c *EmailChannel) sendMail(to, subject, body string) error {
+	var auth smtp.Auth
+	if c.config.Username != "" {
+		host, _, err := net.SplitHostPort(c.config.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", c.config.Username, c.config.Password, host)
+	}
+
+	var msg strings.Builder
+	msg.WriteString("From: " + c.config.From + "\r\n")
+	msg.WriteString("To: " + to + "\r\n")
+	msg.WriteString("Subject: " + subject + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(body)
+
+	return smtp.SendMail(c.config.Addr, auth, c.config.From, []string{to}, []byte(msg.String()))
+}
+
+// WebhookChannel posts notifications as JSON to an HTTP endpoint
+type WebhookChannel struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookChannel(url string, policy *outbound.Policy) *WebhookChannel {
+	return &WebhookChannel{
+		url:    url,
+		client: policy.Client(outbound.Limits{Timeout: 10 * time.Second, MaxBodyBytes: 64 << 10}),
+	}
+}
+
+func (c *WebhookChannel) Name() string {
+	return "webhook"
+}
+
+// Enabled matches recipients who haven't turned webhook notifications off
+func (c *WebhookChannel) Enabled(settings *models.NotificationSettings) bool {
+	return settings != nil && settings.WebhookEnabled
+}
+
+func (c *WebhookChannel) Send(ctx context.Context, n *Notification) error {
+	payload, err := json.Marshal(map[string]interface{}{
+		"type": "MENTION",
+		"data": n,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
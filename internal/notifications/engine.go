@@ -0,0 +1,146 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/models"
+	"github.com/user/bla-back/internal/schedule"
+)
+
+const excerptLength = 140
+
+// PresenceTracker reports realtime connection state of users
+type PresenceTracker interface {
+	IsOnline(userID uuid.UUID) bool
+	LastSeen(userID uuid.UUID) time.Time
+}
+
+// Notification is a single out-of-band notification for one recipient
+type Notification struct {
+	Recipient      *models.User                 `json:"-"`
+	Settings       *models.NotificationSettings `json:"-"`
+	RecipientID    uuid.UUID                    `json:"recipient_id"`
+	SenderName     string                       `json:"sender_name"`
+	ConversationID uuid.UUID                    `json:"conversation_id"`
+	MessageID      uuid.UUID                    `json:"message_id"`
+	Excerpt        string                       `json:"excerpt"`
+	Link           string                       `json:"link"`
+}
+
+// Channel delivers notifications outside of the realtime connection (email, webhook)
+type Channel interface {
+	Name() string
+	// Enabled reports whether the recipient wants notifications over this channel
+	Enabled(settings *models.NotificationSettings) bool
+	Send(ctx context.Context, n *Notification) error
+}
+
+// Rule decides whether a notification should be delivered at all
+type Rule func(n *Notification) bool
+
+// OfflineLongerThan matches recipients that have been disconnected for more than d
+func OfflineLongerThan(presence PresenceTracker, d time.Duration) Rule {
+	return func(n *Notification) bool {
+		if presence.IsOnline(n.RecipientID) {
+			return false
+		}
+		return time.Since(presence.LastSeen(n.RecipientID)) > d
+	}
+}
+
+// OutsideQuietHours matches recipients who are not in their quiet hours, evaluated in their time zone
+func OutsideQuietHours(n *Notification) bool {
+	if n.Settings == nil || n.Settings.QuietStart == nil || n.Settings.QuietEnd == nil {
+		return true
+	}
+	start, err := schedule.ParseClock(*n.Settings.QuietStart)
+	if err != nil {
+		return true
+	}
+	end, err := schedule.ParseClock(*n.Settings.QuietEnd)
+	if err != nil {
+		return true
+	}
+	return !schedule.InWindow(time.Now(), schedule.Zone(n.Settings.TimeZone), start, end)
+}
+
+// NotDoNotDisturb matches recipients who don't have Do Not Disturb on
+func NotDoNotDisturb(n *Notification) bool {
+	return n.Settings == nil || !n.Settings.DND
+}
+
+// Engine evaluates rules for mentions and fans out to delivery channels
+type Engine struct {
+	repo     *Repository
+	appURL   string
+	channels []Channel
+	rules    []Rule
+}
+
+func NewEngine(repo *Repository, appURL string, channels []Channel, rules ...Rule) *Engine {
+	return &Engine{
+		repo:     repo,
+		appURL:   strings.TrimSuffix(appURL, "/"),
+		channels: channels,
+		rules:    rules,
+	}
+}
+
+// NotifyMention delivers a mention notification to the recipient if all rules match,
+// over each channel the recipient has enabled
+func (e *Engine) NotifyMention(ctx context.Context, recipient *models.User, msg *models.Message) {
+	if len(e.channels) == 0 {
+		return
+	}
+
+	settings, err := e.repo.GetSettings(ctx, recipient.ID)
+	if err != nil {
+		log.Printf("Failed to load notification settings for user %s: %v", recipient.ID, err)
+		return
+	}
+
+	senderName := "Someone"
+	if msg.Sender != nil && msg.Sender.Username != nil {
+		senderName = *msg.Sender.Username
+	}
+
+	n := &Notification{
+		Recipient:      recipient,
+		Settings:       settings,
+		RecipientID:    recipient.ID,
+		SenderName:     senderName,
+		ConversationID: msg.ConversationID,
+		MessageID:      msg.ID,
+		Excerpt:        excerpt(msg.Content),
+		Link:           fmt.Sprintf("%s/conversations/%s?message=%s", e.appURL, msg.ConversationID, msg.ID),
+	}
+
+	for _, rule := range e.rules {
+		if !rule(n) {
+			return
+		}
+	}
+
+	for _, ch := range e.channels {
+		if !ch.Enabled(settings) {
+			continue
+		}
+		if err := ch.Send(ctx, n); err != nil {
+			log.Printf("Failed to send %s notification to user %s: %v", ch.Name(), recipient.ID, err)
+		}
+	}
+}
+
+// excerpt shortens message content for notification bodies
+func excerpt(content string) string {
+	runes := []rune(strings.TrimSpace(content))
+	if len(runes) <= excerptLength {
+		return string(runes)
+	}
+	return string(runes[:excerptLength]) + "…"
+}
@@ -0,0 +1,114 @@
+package notifications
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/user/bla-back/internal/models"
+	"github.com/user/bla-back/internal/schedule"
+)
+
+// dndActive matches notification_settings rows (ns) with Do Not Disturb in effect
+const dndActive = `COALESCE(ns.dnd AND (ns.dnd_until IS NULL OR ns.dnd_until > NOW()), FALSE)`
+
+type Repository struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{db: db}
+}
+
+// GetSettings returns notification settings for a user (defaults if never saved)
+func (r *Repository) GetSettings(ctx context.Context, userID uuid.UUID) (*models.NotificationSettings, error) {
+	settings := &models.NotificationSettings{UserID: userID}
+	var quietStart, quietEnd *int
+	var updatedAt *time.Time
+	err := r.db.QueryRow(ctx, `
+		SELECT COALESCE(ns.email_enabled, FALSE), COALESCE(ns.webhook_enabled, TRUE), ns.quiet_start, ns.quiet_end, ns.updated_at, COALESCE(u.time_zone, ''),
+			   `+dndActive+`, CASE WHEN `+dndActive+` THEN ns.dnd_until END
+		FROM users u
+		LEFT JOIN notification_settings ns ON ns.user_id = u.id
+		WHERE u.id = $1
+	`, userID).Scan(&settings.EmailEnabled, &settings.WebhookEnabled, &quietStart, &quietEnd, &updatedAt, &settings.TimeZone, &settings.DND, &settings.DNDUntil)
+	if err != nil {
+		return nil, err
+	}
+	if updatedAt != nil {
+		settings.UpdatedAt = *updatedAt
+	}
+	if quietStart != nil && quietEnd != nil {
+		start, end := schedule.Clock(*quietStart).String(), schedule.Clock(*quietEnd).String()
+		settings.QuietStart, settings.QuietEnd = &start, &end
+	}
+	return settings, nil
+}
+
+// SetEmailEnabled turns email notifications on or off for a user
+func (r *Repository) SetEmailEnabled(ctx context.Context, userID uuid.UUID, enabled bool) (*models.NotificationSettings, error) {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO notification_settings (user_id, email_enabled)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET email_enabled = EXCLUDED.email_enabled, updated_at = NOW()
+	`, userID, enabled)
+	if err != nil {
+		return nil, err
+	}
+	return r.GetSettings(ctx, userID)
+}
+
+// SetWebhookEnabled turns webhook notifications on or off for a user
+func (r *Repository) SetWebhookEnabled(ctx context.Context, userID uuid.UUID, enabled bool) (*models.NotificationSettings, error) {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO notification_settings (user_id, webhook_enabled)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET webhook_enabled = EXCLUDED.webhook_enabled, updated_at = NOW()
+	`, userID, enabled)
+	if err != nil {
+		return nil, err
+	}
+	return r.GetSettings(ctx, userID)
+}
+
+// SetQuietHours sets the local-time window notifications are held back in (nil turns it off)
+func (r *Repository) SetQuietHours(ctx context.Context, userID uuid.UUID, start, end *schedule.Clock) (*models.NotificationSettings, error) {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO notification_settings (user_id, quiet_start, quiet_end)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET quiet_start = EXCLUDED.quiet_start, quiet_end = EXCLUDED.quiet_end, updated_at = NOW()
+	`, userID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return r.GetSettings(ctx, userID)
+}
+
+// SetDoNotDisturb turns Do Not Disturb on until the given time (nil = until turned off), or off
+func (r *Repository) SetDoNotDisturb(ctx context.Context, userID uuid.UUID, enabled bool, until *time.Time) (*models.NotificationSettings, error) {
+	if !enabled {
+		until = nil
+	}
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO notification_settings (user_id, dnd, dnd_until)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET dnd = EXCLUDED.dnd, dnd_until = EXCLUDED.dnd_until, updated_at = NOW()
+	`, userID, enabled, until)
+	if err != nil {
+		return nil, err
+	}
+	return r.GetSettings(ctx, userID)
+}
+
+// FilterDoNotDisturb returns the users who have Do Not Disturb on
+func (r *Repository) FilterDoNotDisturb(ctx context.Context, userIDs []uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT ns.user_id FROM notification_settings ns
+		WHERE ns.user_id = ANY($1) AND `+dndActive, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+}
@@ -0,0 +1,99 @@
+package oauth
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+const (
+	ProviderGoogle  = "google"
+	ProviderGitHub  = "github"
+	ProviderDiscord = "discord"
+)
+
+// provider describes a provider's endpoints and how to read the signed-in account
+type provider struct {
+	authURL    string
+	tokenURL   string
+	scope      string
+	authParams map[string]string
+	identity   func(ctx context.Context, client *http.Client, accessToken string) (*Identity, error)
+}
+
+var providers = map[string]provider{
+	ProviderGoogle: {
+		authURL:    "https://accounts.google.com/o/oauth2/v2/auth",
+		tokenURL:   "https://oauth2.googleapis.com/token",
+		scope:      "openid email",
+		authParams: map[string]string{"prompt": "select_account"},
+		identity:   googleIdentity,
+	},
+	ProviderGitHub: {
+		authURL:  "https://github.com/login/oauth/authorize",
+		tokenURL: "https://github.com/login/oauth/access_token",
+		scope:    "read:user user:email",
+		identity: githubIdentity,
+	},
+	ProviderDiscord: {
+		authURL:    "https://discord.com/oauth2/authorize",
+		tokenURL:   "https://discord.com/api/oauth2/token",
+		scope:      "identify email",
+		authParams: map[string]string{"prompt": "none"},
+		identity:   discordIdentity,
+	},
+}
+
+func googleIdentity(ctx context.Context, client *http.Client, accessToken string) (*Identity, error) {
+	var info struct {
+		Sub           string `json:"sub"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}
+	if err := getJSON(ctx, client, "https://openidconnect.googleapis.com/v1/userinfo", accessToken, &info); err != nil {
+		return nil, err
+	}
+	return &Identity{Subject: info.Sub, Email: info.Email, EmailVerified: info.EmailVerified}, nil
+}
+
+// githubIdentity reads the account and its primary email, which /user omits when it's private
+func githubIdentity(ctx context.Context, client *http.Client, accessToken string) (*Identity, error) {
+	var user struct {
+		ID int64 `json:"id"`
+	}
+	if err := getJSON(ctx, client, "https://api.github.com/user", accessToken, &user); err != nil {
+		return nil, err
+	}
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(ctx, client, "https://api.github.com/user/emails", accessToken, &emails); err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{}
+	if user.ID != 0 {
+		identity.Subject = strconv.FormatInt(user.ID, 10)
+	}
+	for _, e := range emails {
+		if e.Primary {
+			identity.Email = e.Email
+			identity.EmailVerified = e.Verified
+		}
+	}
+	return identity, nil
+}
+
+func discordIdentity(ctx context.Context, client *http.Client, accessToken string) (*Identity, error) {
+	var user struct {
+		ID       string `json:"id"`
+		Email    string `json:"email"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(ctx, client, "https://discord.com/api/users/@me", accessToken, &user); err != nil {
+		return nil, err
+	}
+	return &Identity{Subject: user.ID, Email: user.Email, EmailVerified: user.Verified}, nil
+}
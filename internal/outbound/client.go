@@ -0,0 +1,202 @@
+// Package outbound builds the HTTP clients used for every server-initiated fetch of a URL
+// that users or remote servers can influence (sticker proxy, link unfurling, webhooks).
+// They refuse to connect to private and reserved addresses unless allowlisted, cap
+// response sizes and durations, and only follow a few http(s) redirects.
+package outbound
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrBlockedAddress    = errors.New("destination address is not allowed")
+	ErrUnsupportedScheme = errors.New("only http and https URLs can be fetched")
+	ErrTooManyRedirects  = errors.New("too many redirects")
+	ErrBodyTooLarge      = errors.New("response body exceeds the size limit")
+)
+
+const (
+	dialTimeout         = 5 * time.Second
+	tlsHandshakeTimeout = 5 * time.Second
+)
+
+// reserved are special-purpose ranges netip's Is* helpers don't cover
+var reserved = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64 can reach IPv4 private ranges
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// Policy decides which destinations outbound clients may connect to
+type Policy struct {
+	nets  []netip.Prefix
+	hosts map[string]bool
+}
+
+// NewPolicy builds a policy from comma-separated allowlists of CIDRs, IPs and host names
+// that may be reached even though they resolve to private addresses (e.g. a MinIO
+// bucket on the internal network)
+func NewPolicy(allowlists ...string) *Policy {
+	p := &Policy{hosts: make(map[string]bool)}
+	for _, list := range allowlists {
+		for _, entry := range strings.Split(list, ",") {
+			entry = strings.ToLower(strings.TrimSpace(entry))
+			if entry == "" {
+				continue
+			}
+			if prefix, err := netip.ParsePrefix(entry); err == nil {
+				p.nets = append(p.nets, prefix)
+			} else if addr, err := netip.ParseAddr(entry); err == nil {
+				p.nets = append(p.nets, netip.PrefixFrom(addr, addr.BitLen()))
+			} else {
+				p.hosts[entry] = true
+			}
+		}
+	}
+	return p
+}
+
+// Allowed reports whether an address may be connected to
+func (p *Policy) Allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range p.nets {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range reserved {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// control runs after DNS resolution, right before connecting, so a host name can't
+// resolve to a public address when checked and a private one when dialed
+func (p *Policy) control(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, address)
+	}
+	if !p.Allowed(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, addrPort.Addr())
+	}
+	return nil
+}
+
+// Limits bound a client's requests
+type Limits struct {
+	Timeout      time.Duration // whole request, including reading the body
+	MaxBodyBytes int64
+	MaxRedirects int
+}
+
+// Client returns an HTTP client that enforces the policy and limits
+func (p *Policy) Client(limits Limits) *http.Client {
+	guarded := &net.Dialer{Timeout: dialTimeout, Control: p.control}
+	plain := &net.Dialer{Timeout: dialTimeout}
+
+	transport := &http.Transport{
+		Proxy: nil, // a proxy would make the connection on our behalf, past the address check
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			host, _, err := net.SplitHostPort(addr)
+			if err == nil && p.hosts[strings.ToLower(host)] {
+				return plain.DialContext(ctx, network, addr)
+			}
+			return guarded.DialContext(ctx, network, addr)
+		},
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          50,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   tlsHandshakeTimeout,
+		ResponseHeaderTimeout: limits.Timeout,
+	}
+
+	return &http.Client{
+		Timeout:   limits.Timeout,
+		Transport: &limitTransport{next: transport, maxBody: limits.MaxBodyBytes},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > limits.MaxRedirects {
+				return ErrTooManyRedirects
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrUnsupportedScheme
+			}
+			return nil
+		},
+	}
+}
+
+// limitTransport rejects non-http(s) URLs and caps response bodies
+type limitTransport struct {
+	next    http.RoundTripper
+	maxBody int64
+}
+
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return nil, ErrUnsupportedScheme
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || t.maxBody <= 0 {
+		return resp, err
+	}
+	if resp.ContentLength > t.maxBody {
+		resp.Body.Close()
+		return nil, ErrBodyTooLarge
+	}
+	resp.Body = &limitedBody{body: resp.Body, remaining: t.maxBody}
+	return resp, nil
+}
+
+// limitedBody fails with ErrBodyTooLarge once more than the limit was read, instead of
+// silently truncating like io.LimitReader
+type limitedBody struct {
+	body      io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, ErrBodyTooLarge
+	}
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.body.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.remaining = -1
+		return n, ErrBodyTooLarge
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	return b.body.Close()
+}
@@ -0,0 +1,133 @@
+package passkeys
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatalf("bad hex %q: %v", s, err)
+	}
+	return b
+}
+
+// Vectors from RFC 8949 Appendix A, limited to what decodeCBOR supports
+func TestDecodeCBOR(t *testing.T) {
+	tests := []struct {
+		name string
+		hex  string
+		want interface{}
+	}{
+		{"zero", "00", int64(0)},
+		{"small uint", "17", int64(23)},
+		{"uint8", "1818", int64(24)},
+		{"uint16", "1903e8", int64(1000)},
+		{"uint32", "1a000f4240", int64(1000000)},
+		{"uint64", "1b000000e8d4a51000", int64(1000000000000)},
+		{"negative", "20", int64(-1)},
+		{"negative uint8", "3863", int64(-100)},
+		{"negative uint16", "3903e7", int64(-1000)},
+		{"COSE RS256", "390100", int64(-257)},
+		{"false", "f4", false},
+		{"true", "f5", true},
+		{"null", "f6", nil},
+		{"undefined", "f7", nil},
+		{"empty bytes", "40", []byte{}},
+		{"bytes", "4401020304", []byte{1, 2, 3, 4}},
+		{"empty text", "60", ""},
+		{"text", "6449455446", "IETF"},
+		{"utf-8 text", "62c3bc", "ü"},
+		{"empty array", "80", []interface{}{}},
+		{"array", "83010203", []interface{}{int64(1), int64(2), int64(3)}},
+		{"nested array", "8301820203820405", []interface{}{int64(1), []interface{}{int64(2), int64(3)}, []interface{}{int64(4), int64(5)}}},
+		{"empty map", "a0", map[interface{}]interface{}{}},
+		{"int map", "a201020304", map[interface{}]interface{}{int64(1): int64(2), int64(3): int64(4)}},
+		{"text map", "a26161016162820203", map[interface{}]interface{}{"a": int64(1), "b": []interface{}{int64(2), int64(3)}}},
+		{"attestation shape", "a363666d74646e6f6e656761747453746d74a068617574684461746142aabb", map[interface{}]interface{}{
+			"fmt":      "none",
+			"attStmt":  map[interface{}]interface{}{},
+			"authData": []byte{0xaa, 0xbb},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rest, err := decodeCBOR(mustHex(t, tt.hex))
+			if err != nil {
+				t.Fatalf("decodeCBOR: %v", err)
+			}
+			if len(rest) != 0 {
+				t.Errorf("rest = %x, want empty", rest)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORReturnsRest(t *testing.T) {
+	got, rest, err := decodeCBOR(mustHex(t, "0102ff"))
+	if err != nil {
+		t.Fatalf("decodeCBOR: %v", err)
+	}
+	if got != int64(1) || !bytes.Equal(rest, []byte{0x02, 0xff}) {
+		t.Errorf("got %v, rest %x", got, rest)
+	}
+}
+
+func TestDecodeCBORMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		hex  string
+	}{
+		{"empty", ""},
+		{"truncated uint8", "18"},
+		{"truncated uint16", "1901"},
+		{"truncated uint32", "1a000f42"},
+		{"truncated uint64", "1b000000e8d4a510"},
+		{"reserved additional info", "1c"},
+		{"uint64 past int64", "1bffffffffffffffff"},
+		{"negative past int64", "3bffffffffffffffff"},
+		{"truncated bytes", "440102"},
+		{"truncated text", "644945"},
+		{"huge byte length", "5affffffff00"},
+		{"indefinite bytes", "5f4101ff"},
+		{"indefinite array", "9f01ff"},
+		{"indefinite map", "bf616101ff"},
+		{"truncated array", "830102"},
+		{"huge array length", "9affffffff01"},
+		{"truncated map", "a2010203"},
+		{"huge map length", "baffffffff0102"},
+		{"map missing value", "a101"},
+		{"array map key", "a1800102"},
+		{"bytes map key", "a1410102"},
+		{"tag", "c11a514b67b0"},
+		{"float16", "f93c00"},
+		{"float64", "fb3ff199999999999a"},
+		{"unassigned simple", "f0"},
+		{"simple uint8", "f818"},
+		{"too deep", strings.Repeat("81", maxCBORDepth+1) + "00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := decodeCBOR(mustHex(t, tt.hex)); err == nil {
+				t.Errorf("decodeCBOR(%s) succeeded, want error", tt.hex)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORMaxDepth(t *testing.T) {
+	data := mustHex(t, strings.Repeat("81", maxCBORDepth)+"00")
+	if _, _, err := decodeCBOR(data); err != nil {
+		t.Errorf("nesting of %d: %v", maxCBORDepth, err)
+	}
+}
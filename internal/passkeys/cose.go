@@ -0,0 +1,97 @@
+package passkeys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"math/big"
+)
+
+// COSE algorithms offered when registering, in order of preference
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+var SupportedAlgorithms = []int{AlgES256, AlgEdDSA, AlgRS256}
+
+// COSE key parameters (RFC 9053)
+const (
+	coseKty    = 1
+	coseAlg    = 3
+	coseCrv    = -1 // EC2 and OKP
+	coseX      = -2 // EC2 and OKP
+	coseY      = -3 // EC2
+	coseRSAN   = -1
+	coseRSAE   = -2
+	ktyOKP     = 1
+	ktyEC2     = 2
+	ktyRSA     = 3
+	crvP256    = 1
+	crvEd25519 = 6
+)
+
+var ErrUnsupportedKey = errors.New("unsupported passkey algorithm")
+
+// parseCOSEKey decodes a credential public key into a crypto key and its algorithm
+func parseCOSEKey(m map[interface{}]interface{}) (crypto.PublicKey, int, error) {
+	kty, _ := m[int64(coseKty)].(int64)
+	alg, _ := m[int64(coseAlg)].(int64)
+
+	switch {
+	case kty == ktyEC2 && alg == AlgES256:
+		crv, _ := m[int64(coseCrv)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		y, _ := m[int64(coseY)].([]byte)
+		if crv != crvP256 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, ErrUnsupportedKey
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, 0, ErrUnsupportedKey
+		}
+		return key, AlgES256, nil
+
+	case kty == ktyOKP && alg == AlgEdDSA:
+		crv, _ := m[int64(coseCrv)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		if crv != crvEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, ErrUnsupportedKey
+		}
+		return ed25519.PublicKey(x), AlgEdDSA, nil
+
+	case kty == ktyRSA && alg == AlgRS256:
+		n, _ := m[int64(coseRSAN)].([]byte)
+		e, _ := m[int64(coseRSAE)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, ErrUnsupportedKey
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, AlgRS256, nil
+	}
+	return nil, 0, ErrUnsupportedKey
+}
+
+// verifySignature checks an assertion signature made with a stored (PKIX DER) public key
+func verifySignature(publicKey []byte, alg int, signed, sig []byte) bool {
+	parsed, err := x509.ParsePKIXPublicKey(publicKey)
+	if err != nil {
+		return false
+	}
+	digest := sha256.Sum256(signed)
+
+	switch key := parsed.(type) {
+	case *ecdsa.PublicKey:
+		return alg == AlgES256 && ecdsa.VerifyASN1(key, digest[:], sig)
+	case ed25519.PublicKey:
+		return alg == AlgEdDSA && ed25519.Verify(key, signed, sig)
+	case *rsa.PublicKey:
+		return alg == AlgRS256 && rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil
+	}
+	return false
+}
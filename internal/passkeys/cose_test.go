@@ -0,0 +1,237 @@
+package passkeys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"testing"
+)
+
+// The P-256 base point, a known point on the curve
+const (
+	p256GX = "6b17d1f2e12c4247f8bce6e563a440f277037d812deb33a0f4a13945d898c296"
+	p256GY = "4fe342e2fe1a7f9b8ee7eb4a7c0f9e162bce33576b315ececbb6406837bf51f5"
+)
+
+// RFC 8032 section 7.1, test 1: the signature of an empty message
+const (
+	ed25519TestKey = "d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a"
+	ed25519TestSig = "e5564300c360ac729086e2cc806e828a84877f1eb8e5d974d873e065" +
+		"224901555fb8821590a33bacc61e39701cf9b46bd25bf5f0595bbe24655141438e7a100b"
+)
+
+// es256COSEKey is the CTAP2 canonical encoding of an EC2 P-256 key at the base point
+func es256COSEKey(t *testing.T) []byte {
+	return mustHex(t, "a5 01 02 03 26 20 01 21 5820"+p256GX+" 22 5820"+p256GY)
+}
+
+func decodeCOSEMap(t *testing.T, data []byte) map[interface{}]interface{} {
+	t.Helper()
+	v, rest, err := decodeCBOR(data)
+	if err != nil || len(rest) != 0 {
+		t.Fatalf("decodeCBOR: %v (rest %x)", err, rest)
+	}
+	m, ok := v.(map[interface{}]interface{})
+	if !ok {
+		t.Fatalf("COSE key decoded to %T", v)
+	}
+	return m
+}
+
+func TestParseCOSEKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaN := rsaKey.N.FillBytes(make([]byte, 256))
+
+	tests := []struct {
+		name    string
+		key     func(t *testing.T) map[interface{}]interface{}
+		wantAlg int
+	}{
+		{"ES256", func(t *testing.T) map[interface{}]interface{} {
+			return decodeCOSEMap(t, es256COSEKey(t))
+		}, AlgES256},
+		{"EdDSA", func(t *testing.T) map[interface{}]interface{} {
+			return decodeCOSEMap(t, mustHex(t, "a4 01 01 03 27 20 06 21 5820"+ed25519TestKey))
+		}, AlgEdDSA},
+		{"RS256", func(t *testing.T) map[interface{}]interface{} {
+			return map[interface{}]interface{}{
+				int64(coseKty): int64(ktyRSA), int64(coseAlg): int64(AlgRS256),
+				int64(coseRSAN): rsaN, int64(coseRSAE): []byte{0x01, 0x00, 0x01},
+			}
+		}, AlgRS256},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, alg, err := parseCOSEKey(tt.key(t))
+			if err != nil {
+				t.Fatalf("parseCOSEKey: %v", err)
+			}
+			if alg != tt.wantAlg {
+				t.Errorf("alg = %d, want %d", alg, tt.wantAlg)
+			}
+			if _, err := x509.MarshalPKIXPublicKey(key); err != nil {
+				t.Errorf("MarshalPKIXPublicKey: %v", err)
+			}
+		})
+	}
+}
+
+func TestParseCOSEKeyRejects(t *testing.T) {
+	es256 := func(t *testing.T) map[interface{}]interface{} {
+		return decodeCOSEMap(t, es256COSEKey(t))
+	}
+	x := mustHex(t, p256GX)
+	y := mustHex(t, p256GY)
+
+	tests := []struct {
+		name   string
+		mutate func(m map[interface{}]interface{})
+	}{
+		{"missing kty", func(m map[interface{}]interface{}) { delete(m, int64(coseKty)) }},
+		{"missing alg", func(m map[interface{}]interface{}) { delete(m, int64(coseAlg)) }},
+		{"kty as text", func(m map[interface{}]interface{}) { m[int64(coseKty)] = "EC2" }},
+		{"unsupported alg", func(m map[interface{}]interface{}) { m[int64(coseAlg)] = int64(-35) }},
+		{"alg for another kty", func(m map[interface{}]interface{}) { m[int64(coseAlg)] = int64(AlgEdDSA) }},
+		{"P-384 curve", func(m map[interface{}]interface{}) { m[int64(coseCrv)] = int64(2) }},
+		{"missing x", func(m map[interface{}]interface{}) { delete(m, int64(coseX)) }},
+		{"missing y", func(m map[interface{}]interface{}) { delete(m, int64(coseY)) }},
+		{"short x", func(m map[interface{}]interface{}) { m[int64(coseX)] = x[1:] }},
+		{"long y", func(m map[interface{}]interface{}) { m[int64(coseY)] = append([]byte{0}, y...) }},
+		{"y as int", func(m map[interface{}]interface{}) { m[int64(coseY)] = int64(1) }},
+		{"point off the curve", func(m map[interface{}]interface{}) {
+			off := append([]byte(nil), y...)
+			off[31] ^= 1
+			m[int64(coseY)] = off
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := es256(t)
+			tt.mutate(m)
+			if _, _, err := parseCOSEKey(m); err != ErrUnsupportedKey {
+				t.Errorf("err = %v, want ErrUnsupportedKey", err)
+			}
+		})
+	}
+
+	others := []struct {
+		name string
+		key  map[interface{}]interface{}
+	}{
+		{"empty", map[interface{}]interface{}{}},
+		{"Ed448 curve", map[interface{}]interface{}{
+			int64(coseKty): int64(ktyOKP), int64(coseAlg): int64(AlgEdDSA),
+			int64(coseCrv): int64(7), int64(coseX): make([]byte, 57),
+		}},
+		{"short Ed25519 key", map[interface{}]interface{}{
+			int64(coseKty): int64(ktyOKP), int64(coseAlg): int64(AlgEdDSA),
+			int64(coseCrv): int64(crvEd25519), int64(coseX): make([]byte, 31),
+		}},
+		{"RSA 1024", map[interface{}]interface{}{
+			int64(coseKty): int64(ktyRSA), int64(coseAlg): int64(AlgRS256),
+			int64(coseRSAN): make([]byte, 128), int64(coseRSAE): []byte{0x01, 0x00, 0x01},
+		}},
+		{"RSA missing exponent", map[interface{}]interface{}{
+			int64(coseKty): int64(ktyRSA), int64(coseAlg): int64(AlgRS256),
+			int64(coseRSAN): make([]byte, 256),
+		}},
+		{"RSA long exponent", map[interface{}]interface{}{
+			int64(coseKty): int64(ktyRSA), int64(coseAlg): int64(AlgRS256),
+			int64(coseRSAN): make([]byte, 256), int64(coseRSAE): make([]byte, 5),
+		}},
+	}
+	for _, tt := range others {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := parseCOSEKey(tt.key); err != ErrUnsupportedKey {
+				t.Errorf("err = %v, want ErrUnsupportedKey", err)
+			}
+		})
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	signed := []byte("authenticator data || client data hash")
+	digest := sha256.Sum256(signed)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecSig, err := ecdsa.SignASN1(rand.Reader, ecKey, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaSig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edSig := ed25519.Sign(edPrivate, signed)
+
+	der := func(key interface{}) []byte {
+		b, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	ecDER, rsaDER, edDER := der(&ecKey.PublicKey), der(&rsaKey.PublicKey), der(edPublic)
+	rfcDER := der(ed25519.PublicKey(mustHex(t, ed25519TestKey)))
+
+	tampered := append([]byte(nil), signed...)
+	tampered[0] ^= 1
+
+	tests := []struct {
+		name   string
+		key    []byte
+		alg    int
+		signed []byte
+		sig    []byte
+		want   bool
+	}{
+		{"ES256", ecDER, AlgES256, signed, ecSig, true},
+		{"RS256", rsaDER, AlgRS256, signed, rsaSig, true},
+		{"EdDSA", edDER, AlgEdDSA, signed, edSig, true},
+		{"EdDSA RFC 8032 vector", rfcDER, AlgEdDSA, []byte{}, mustHex(t, ed25519TestSig), true},
+
+		{"ES256 tampered data", ecDER, AlgES256, tampered, ecSig, false},
+		{"RS256 tampered data", rsaDER, AlgRS256, tampered, rsaSig, false},
+		{"EdDSA tampered data", edDER, AlgEdDSA, tampered, edSig, false},
+		{"ES256 truncated signature", ecDER, AlgES256, signed, ecSig[:len(ecSig)-1], false},
+		{"EdDSA truncated signature", edDER, AlgEdDSA, signed, edSig[:63], false},
+		{"empty signature", ecDER, AlgES256, signed, nil, false},
+		{"signature from another key", edDER, AlgEdDSA, []byte{}, mustHex(t, ed25519TestSig), false},
+		{"ES256 key with RS256 alg", ecDER, AlgRS256, signed, ecSig, false},
+		{"RSA key with ES256 alg", rsaDER, AlgES256, signed, rsaSig, false},
+		{"Ed25519 key with ES256 alg", edDER, AlgES256, signed, edSig, false},
+		{"truncated key", ecDER[:len(ecDER)-1], AlgES256, signed, ecSig, false},
+		{"garbage key", []byte{0x30, 0x00}, AlgES256, signed, ecSig, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifySignature(tt.key, tt.alg, tt.signed, tt.sig); got != tt.want {
+				t.Errorf("verifySignature = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package passkeys
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/user/bla-back/internal/models"
+)
+
+const maxPasskeys = 20
+
+var (
+	ErrTooManyPasskeys   = errors.New("too many passkeys")
+	ErrPasskeyNotFound   = errors.New("passkey not found")
+	ErrPasskeyRegistered = errors.New("passkey is already registered")
+)
+
+// credential is a stored passkey with what's needed to verify sign-ins
+type credential struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	PublicKey []byte // PKIX DER
+	Algorithm int
+	SignCount uint32
+}
+
+type Repository struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{db: db}
+}
+
+// create stores a verified credential for the user
+func (r *Repository) create(ctx context.Context, userID uuid.UUID, name string, credentialID, publicKey []byte, alg int, signCount uint32) (*models.Passkey, error) {
+	var count int
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM passkeys WHERE user_id = $1`, userID).Scan(&count); err != nil {
+		return nil, err
+	}
+	if count >= maxPasskeys {
+		return nil, ErrTooManyPasskeys
+	}
+
+	p := &models.Passkey{}
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO passkeys (user_id, name, credential_id, public_key, algorithm, sign_count)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, name, last_used_at, created_at
+	`, userID, name, credentialID, publicKey, alg, int64(signCount)).Scan(&p.ID, &p.Name, &p.LastUsedAt, &p.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, ErrPasskeyRegistered
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+// credentialIDs returns the IDs of the user's passkeys, so the browser doesn't register
+// an authenticator twice
+func (r *Repository) credentialIDs(ctx context.Context, userID uuid.UUID) ([][]byte, error) {
+	rows, err := r.db.Query(ctx, `SELECT credential_id FROM passkeys WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[[]byte])
+}
+
+func (r *Repository) byCredentialID(ctx context.Context, credentialID []byte) (*credential, error) {
+	c := &credential{}
+	var signCount int64
+	err := r.db.QueryRow(ctx, `
+		SELECT id, user_id, public_key, algorithm, sign_count FROM passkeys WHERE credential_id = $1
+	`, credentialID).Scan(&c.ID, &c.UserID, &c.PublicKey, &c.Algorithm, &signCount)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrPasskeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.SignCount = uint32(signCount)
+	return c, nil
+}
+
+// markUsed records a sign-in with the passkey and the authenticator's new signature counter
+func (r *Repository) markUsed(ctx context.Context, id uuid.UUID, signCount uint32) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE passkeys SET sign_count = $2, last_used_at = NOW() WHERE id = $1
+	`, id, int64(signCount))
+	return err
+}
+
+// List returns the user's passkeys, newest first
+func (r *Repository) List(ctx context.Context, userID uuid.UUID) ([]models.Passkey, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, name, last_used_at, created_at FROM passkeys
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Passkey, error) {
+		var p models.Passkey
+		err := row.Scan(&p.ID, &p.Name, &p.LastUsedAt, &p.CreatedAt)
+		return p, err
+	})
+}
+
+// Delete removes one of the user's passkeys
+func (r *Repository) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM passkeys WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrPasskeyNotFound
+	}
+	return nil
+}
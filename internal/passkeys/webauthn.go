@@ -0,0 +1,398 @@
+// Package passkeys lets users register WebAuthn passkeys and sign in with them instead of
+// a password.
+package passkeys
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/models"
+)
+
+const (
+	challengeKeyPrefix = "webauthn:challenge:"
+	// ceremonyTimeout is how long the browser prompt may take, and the challenge lives
+	ceremonyTimeout = 5 * time.Minute
+
+	typeCreate = "webauthn.create"
+	typeGet    = "webauthn.get"
+
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+)
+
+var (
+	ErrInvalidCredential = errors.New("passkey could not be verified")
+	ErrChallengeExpired  = errors.New("passkey challenge expired, start again")
+)
+
+// Config names the relying party: the site passkeys are bound to
+type Config struct {
+	RPID    string   // registrable domain, e.g. "joinbla.ru"
+	RPName  string   // shown by the browser when creating a passkey
+	Origins []string // origins the browser may report, e.g. "https://web.joinbla.ru"
+}
+
+// ChallengeStore keeps ceremony challenges until they're redeemed (implemented by cache.RedisCache)
+type ChallengeStore interface {
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Take(ctx context.Context, key string) ([]byte, error)
+}
+
+// Service runs the WebAuthn registration and sign-in ceremonies. Attestation isn't
+// requested, so any authenticator the browser offers is accepted.
+type Service struct {
+	config     Config
+	repo       *Repository
+	challenges ChallengeStore
+}
+
+func NewService(config Config, repo *Repository, challenges ChallengeStore) *Service {
+	return &Service{config: config, repo: repo, challenges: challenges}
+}
+
+// challengeSession is stored under a challenge until the browser's response redeems it
+type challengeSession struct {
+	Type   string    `json:"type"`
+	UserID uuid.UUID `json:"user_id"` // registration only
+}
+
+type relyingParty struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type userEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type credentialParam struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+type credentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type authenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CreationOptions are the publicKey options for navigator.credentials.create(),
+// with binary fields base64url-encoded
+type CreationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	RP                     relyingParty           `json:"rp"`
+	User                   userEntity             `json:"user"`
+	PubKeyCredParams       []credentialParam      `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	Attestation            string                 `json:"attestation"`
+	AuthenticatorSelection authenticatorSelection `json:"authenticatorSelection"`
+	ExcludeCredentials     []credentialDescriptor `json:"excludeCredentials"`
+}
+
+// RequestOptions are the publicKey options for navigator.credentials.get(). No credentials
+// are listed, so the browser offers the passkeys it has for the site.
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	RPID             string                 `json:"rpId"`
+	Timeout          int64                  `json:"timeout"`
+	UserVerification string                 `json:"userVerification"`
+	AllowCredentials []credentialDescriptor `json:"allowCredentials"`
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decode accepts base64url with or without padding, as browsers and libraries differ
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// newChallenge stores a fresh challenge for the ceremony and returns it encoded
+func (s *Service) newChallenge(ctx context.Context, session challengeSession) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	challenge := encode(raw)
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+	if err := s.challenges.Set(ctx, challengeKeyPrefix+challenge, data, ceremonyTimeout); err != nil {
+		return "", err
+	}
+	return challenge, nil
+}
+
+// BeginRegistration starts adding a passkey to the user's account
+func (s *Service) BeginRegistration(ctx context.Context, user *models.User) (*CreationOptions, error) {
+	challenge, err := s.newChallenge(ctx, challengeSession{Type: typeCreate, UserID: user.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.repo.credentialIDs(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	exclude := make([]credentialDescriptor, 0, len(existing))
+	for _, id := range existing {
+		exclude = append(exclude, credentialDescriptor{Type: "public-key", ID: encode(id)})
+	}
+
+	params := make([]credentialParam, 0, len(SupportedAlgorithms))
+	for _, alg := range SupportedAlgorithms {
+		params = append(params, credentialParam{Type: "public-key", Alg: alg})
+	}
+
+	displayName := user.Email
+	if user.Username != nil {
+		displayName = *user.Username
+	}
+
+	return &CreationOptions{
+		Challenge: challenge,
+		RP:        relyingParty{ID: s.config.RPID, Name: s.config.RPName},
+		User: userEntity{
+			ID:          encode(user.ID[:]),
+			Name:        user.Email,
+			DisplayName: displayName,
+		},
+		PubKeyCredParams: params,
+		Timeout:          ceremonyTimeout.Milliseconds(),
+		Attestation:      "none",
+		AuthenticatorSelection: authenticatorSelection{
+			ResidentKey:      "required",
+			UserVerification: "required",
+		},
+		ExcludeCredentials: exclude,
+	}, nil
+}
+
+// FinishRegistration verifies the browser's response to BeginRegistration and stores the
+// new passkey
+func (s *Service) FinishRegistration(ctx context.Context, userID uuid.UUID, name string, cred *models.PasskeyCredential) (*models.Passkey, error) {
+	clientDataJSON, err := decode(cred.Response.ClientDataJSON)
+	if err != nil {
+		return nil, ErrInvalidCredential
+	}
+	session, err := s.redeemChallenge(ctx, clientDataJSON, typeCreate)
+	if err != nil {
+		return nil, err
+	}
+	if session.UserID != userID {
+		return nil, ErrInvalidCredential
+	}
+
+	attestation, err := decode(cred.Response.AttestationObject)
+	if err != nil {
+		return nil, ErrInvalidCredential
+	}
+	obj, _, err := decodeCBOR(attestation)
+	if err != nil {
+		return nil, ErrInvalidCredential
+	}
+	objMap, _ := obj.(map[interface{}]interface{})
+	rawAuthData, _ := objMap["authData"].([]byte)
+
+	authData, err := s.parseAuthenticatorData(rawAuthData, true)
+	if err != nil {
+		return nil, err
+	}
+	if rawID, err := decode(cred.RawID); err != nil || !bytes.Equal(rawID, authData.credentialID) {
+		return nil, ErrInvalidCredential
+	}
+
+	publicKey, alg, err := parseCOSEKey(authData.publicKey)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, ErrUnsupportedKey
+	}
+
+	if name == "" {
+		name = "Passkey"
+	}
+	return s.repo.create(ctx, userID, name, authData.credentialID, der, alg, authData.signCount)
+}
+
+// BeginLogin starts a passkey sign-in
+func (s *Service) BeginLogin(ctx context.Context) (*RequestOptions, error) {
+	challenge, err := s.newChallenge(ctx, challengeSession{Type: typeGet})
+	if err != nil {
+		return nil, err
+	}
+	return &RequestOptions{
+		Challenge:        challenge,
+		RPID:             s.config.RPID,
+		Timeout:          ceremonyTimeout.Milliseconds(),
+		UserVerification: "required",
+		AllowCredentials: []credentialDescriptor{},
+	}, nil
+}
+
+// FinishLogin verifies the browser's response to BeginLogin and returns the user it
+// signs in
+func (s *Service) FinishLogin(ctx context.Context, cred *models.PasskeyCredential) (uuid.UUID, error) {
+	clientDataJSON, err := decode(cred.Response.ClientDataJSON)
+	if err != nil {
+		return uuid.Nil, ErrInvalidCredential
+	}
+	if _, err := s.redeemChallenge(ctx, clientDataJSON, typeGet); err != nil {
+		return uuid.Nil, err
+	}
+
+	rawID, err := decode(cred.RawID)
+	if err != nil {
+		return uuid.Nil, ErrInvalidCredential
+	}
+	stored, err := s.repo.byCredentialID(ctx, rawID)
+	if errors.Is(err, ErrPasskeyNotFound) {
+		return uuid.Nil, ErrInvalidCredential
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	// The user handle is the user ID the passkey was created for
+	if cred.Response.UserHandle != "" {
+		handle, err := decode(cred.Response.UserHandle)
+		if err != nil || !bytes.Equal(handle, stored.UserID[:]) {
+			return uuid.Nil, ErrInvalidCredential
+		}
+	}
+
+	rawAuthData, err := decode(cred.Response.AuthenticatorData)
+	if err != nil {
+		return uuid.Nil, ErrInvalidCredential
+	}
+	authData, err := s.parseAuthenticatorData(rawAuthData, false)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	sig, err := decode(cred.Response.Signature)
+	if err != nil {
+		return uuid.Nil, ErrInvalidCredential
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	if !verifySignature(stored.PublicKey, stored.Algorithm, slices.Concat(rawAuthData, clientDataHash[:]), sig) {
+		return uuid.Nil, ErrInvalidCredential
+	}
+
+	// A counter that doesn't move forward means the key was cloned. Synced passkeys
+	// always report zero, so only authenticators that count are checked.
+	if (authData.signCount != 0 || stored.SignCount != 0) && authData.signCount <= stored.SignCount {
+		return uuid.Nil, ErrInvalidCredential
+	}
+
+	if err := s.repo.markUsed(ctx, stored.ID, authData.signCount); err != nil {
+		return uuid.Nil, err
+	}
+	return stored.UserID, nil
+}
+
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// redeemChallenge checks the client data of a ceremony and consumes its challenge, so a
+// response can't be replayed
+func (s *Service) redeemChallenge(ctx context.Context, clientDataJSON []byte, ceremony string) (*challengeSession, error) {
+	var cd clientData
+	if err := json.Unmarshal(clientDataJSON, &cd); err != nil {
+		return nil, ErrInvalidCredential
+	}
+	if cd.Type != ceremony || cd.CrossOrigin || !slices.Contains(s.config.Origins, cd.Origin) || cd.Challenge == "" {
+		return nil, ErrInvalidCredential
+	}
+
+	data, err := s.challenges.Take(ctx, challengeKeyPrefix+strings.TrimRight(cd.Challenge, "="))
+	if err != nil {
+		return nil, ErrChallengeExpired
+	}
+	var session challengeSession
+	if err := json.Unmarshal(data, &session); err != nil || session.Type != ceremony {
+		return nil, ErrInvalidCredential
+	}
+	return &session, nil
+}
+
+type authenticatorData struct {
+	flags        byte
+	signCount    uint32
+	credentialID []byte                      // registration only
+	publicKey    map[interface{}]interface{} // registration only, COSE key
+}
+
+// parseAuthenticatorData checks authenticator data is for this site with a verified user.
+// Registrations also carry the new credential (attested).
+func (s *Service) parseAuthenticatorData(data []byte, attested bool) (*authenticatorData, error) {
+	if len(data) < 37 {
+		return nil, ErrInvalidCredential
+	}
+	rpIDHash := sha256.Sum256([]byte(s.config.RPID))
+	if !bytes.Equal(data[:32], rpIDHash[:]) {
+		return nil, ErrInvalidCredential
+	}
+
+	ad := &authenticatorData{
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	if ad.flags&flagUserPresent == 0 || ad.flags&flagUserVerified == 0 {
+		return nil, ErrInvalidCredential
+	}
+	if !attested {
+		return ad, nil
+	}
+
+	// AAGUID (16 bytes), credential ID length (2 bytes), credential ID, COSE key
+	rest := data[37:]
+	if ad.flags&flagAttestedData == 0 || len(rest) < 18 {
+		return nil, ErrInvalidCredential
+	}
+	idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if idLen == 0 || idLen > 1023 || len(rest) < idLen {
+		return nil, ErrInvalidCredential
+	}
+	ad.credentialID = rest[:idLen]
+
+	key, _, err := decodeCBOR(rest[idLen:])
+	if err != nil {
+		return nil, ErrInvalidCredential
+	}
+	keyMap, ok := key.(map[interface{}]interface{})
+	if !ok {
+		return nil, ErrInvalidCredential
+	}
+	ad.publicKey = keyMap
+	return ad, nil
+}
@@ -0,0 +1,244 @@
+package passkeys
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const testRPID = "joinbla.ru"
+
+var testCredentialID = []byte("credential-0001!")
+
+func testService(store ChallengeStore) *Service {
+	return NewService(Config{RPID: testRPID, RPName: "Bla", Origins: []string{"https://web.joinbla.ru"}}, nil, store)
+}
+
+// memoryStore is a ChallengeStore in memory
+type memoryStore map[string][]byte
+
+func (m memoryStore) Set(_ context.Context, key string, value []byte, _ time.Duration) error {
+	m[key] = value
+	return nil
+}
+
+func (m memoryStore) Take(_ context.Context, key string) ([]byte, error) {
+	value, ok := m[key]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	delete(m, key)
+	return value, nil
+}
+
+// buildAuthData lays out authenticator data: rpIdHash, flags, sign count and, when a
+// COSE key is given, the attested credential (zero AAGUID)
+func buildAuthData(rpID string, flags byte, signCount uint32, credentialID, coseKey []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, signCount)
+	if coseKey == nil {
+		return data
+	}
+	data = append(data, make([]byte, 16)...)
+	data = binary.BigEndian.AppendUint16(data, uint16(len(credentialID)))
+	data = append(data, credentialID...)
+	return append(data, coseKey...)
+}
+
+// noneAttestation wraps authenticator data in a "none" attestation object
+func noneAttestation(authData []byte) []byte {
+	obj := []byte{0xa3}
+	obj = append(obj, 0x63, 'f', 'm', 't', 0x64, 'n', 'o', 'n', 'e')
+	obj = append(obj, 0x67, 'a', 't', 't', 'S', 't', 'm', 't', 0xa0)
+	obj = append(obj, 0x68, 'a', 'u', 't', 'h', 'D', 'a', 't', 'a', 0x59)
+	obj = binary.BigEndian.AppendUint16(obj, uint16(len(authData)))
+	return append(obj, authData...)
+}
+
+// The registration path: attestation object -> authenticator data -> COSE key -> PKIX
+func TestAttestation(t *testing.T) {
+	s := testService(nil)
+	authData := buildAuthData(testRPID, 0x45, 0, testCredentialID, es256COSEKey(t))
+
+	obj, rest, err := decodeCBOR(noneAttestation(authData))
+	if err != nil || len(rest) != 0 {
+		t.Fatalf("decodeCBOR: %v (rest %x)", err, rest)
+	}
+	objMap, _ := obj.(map[interface{}]interface{})
+	if objMap["fmt"] != "none" {
+		t.Errorf("fmt = %v, want none", objMap["fmt"])
+	}
+	rawAuthData, _ := objMap["authData"].([]byte)
+
+	ad, err := s.parseAuthenticatorData(rawAuthData, true)
+	if err != nil {
+		t.Fatalf("parseAuthenticatorData: %v", err)
+	}
+	if string(ad.credentialID) != string(testCredentialID) {
+		t.Errorf("credential ID = %q, want %q", ad.credentialID, testCredentialID)
+	}
+	key, alg, err := parseCOSEKey(ad.publicKey)
+	if err != nil || alg != AlgES256 {
+		t.Fatalf("parseCOSEKey = %d, %v", alg, err)
+	}
+	if _, err := x509.MarshalPKIXPublicKey(key); err != nil {
+		t.Errorf("MarshalPKIXPublicKey: %v", err)
+	}
+}
+
+func TestParseAuthenticatorData(t *testing.T) {
+	s := testService(nil)
+	coseKey := es256COSEKey(t)
+	valid := buildAuthData(testRPID, 0x45, 7, testCredentialID, coseKey)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		attested bool
+		wantErr  bool
+	}{
+		{"assertion", buildAuthData(testRPID, 0x05, 42, nil, nil), false, false},
+		{"assertion with backup flags", buildAuthData(testRPID, 0x1d, 0, nil, nil), false, false},
+		{"attestation", valid, true, false},
+
+		{"empty", nil, false, true},
+		{"truncated sign count", valid[:36], false, true},
+		{"other RP", buildAuthData("evil.example", 0x05, 1, nil, nil), false, true},
+		{"user not present", buildAuthData(testRPID, 0x04, 1, nil, nil), false, true},
+		{"user not verified", buildAuthData(testRPID, 0x01, 1, nil, nil), false, true},
+		{"attestation without AT flag", buildAuthData(testRPID, 0x05, 0, testCredentialID, coseKey), true, true},
+		{"attestation without credential", buildAuthData(testRPID, 0x45, 0, nil, nil), true, true},
+		{"truncated AAGUID", valid[:37+10], true, true},
+		{"truncated credential ID length", valid[:37+17], true, true},
+		{"truncated credential ID", valid[:37+18+len(testCredentialID)-1], true, true},
+		{"missing COSE key", valid[:37+18+len(testCredentialID)], true, true},
+		{"truncated COSE key", valid[:len(valid)-1], true, true},
+		{"empty credential ID", buildAuthData(testRPID, 0x45, 0, []byte{}, coseKey), true, true},
+		{"oversized credential ID", buildAuthData(testRPID, 0x45, 0, make([]byte, 1024), coseKey), true, true},
+		{"COSE key not a map", buildAuthData(testRPID, 0x45, 0, testCredentialID, []byte{0x83, 0x01, 0x02, 0x03}), true, true},
+		{"COSE key malformed", buildAuthData(testRPID, 0x45, 0, testCredentialID, []byte{0xa1, 0x80, 0x01}), true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ad, err := s.parseAuthenticatorData(tt.data, tt.attested)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseAuthenticatorData succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseAuthenticatorData: %v", err)
+			}
+			if want := binary.BigEndian.Uint32(tt.data[33:37]); ad.signCount != want {
+				t.Errorf("signCount = %d, want %d", ad.signCount, want)
+			}
+		})
+	}
+}
+
+// The sign-in path: the signature covers the authenticator data and the client data hash
+func TestAssertion(t *testing.T) {
+	s := testService(nil)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authData := buildAuthData(testRPID, 0x05, 3, nil, nil)
+	clientDataJSON := []byte(`{"type":"webauthn.get","challenge":"abc","origin":"https://web.joinbla.ru"}`)
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(slices.Concat(authData, clientDataHash[:]))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.parseAuthenticatorData(authData, false); err != nil {
+		t.Fatalf("parseAuthenticatorData: %v", err)
+	}
+	if !verifySignature(der, AlgES256, slices.Concat(authData, clientDataHash[:]), sig) {
+		t.Error("valid assertion rejected")
+	}
+
+	otherHash := sha256.Sum256([]byte(`{"type":"webauthn.get","challenge":"abd","origin":"https://web.joinbla.ru"}`))
+	if verifySignature(der, AlgES256, slices.Concat(authData, otherHash[:]), sig) {
+		t.Error("assertion accepted for other client data")
+	}
+	if verifySignature(der, AlgES256, authData, sig) {
+		t.Error("assertion accepted without client data hash")
+	}
+}
+
+func TestRedeemChallenge(t *testing.T) {
+	userID := uuid.New()
+	session := func(ceremony string) []byte {
+		data, _ := json.Marshal(challengeSession{Type: ceremony, UserID: userID})
+		return data
+	}
+	clientData := func(ceremony, challenge, origin string, crossOrigin bool) []byte {
+		data, _ := json.Marshal(clientData{Type: ceremony, Challenge: challenge, Origin: origin, CrossOrigin: crossOrigin})
+		return data
+	}
+	const origin = "https://web.joinbla.ru"
+
+	tests := []struct {
+		name       string
+		stored     []byte
+		clientData []byte
+		ceremony   string
+		wantErr    error
+	}{
+		{"registration", session(typeCreate), clientData(typeCreate, "c1", origin, false), typeCreate, nil},
+		{"sign-in", session(typeGet), clientData(typeGet, "c1", origin, false), typeGet, nil},
+		{"padded challenge", session(typeGet), clientData(typeGet, "c1==", origin, false), typeGet, nil},
+
+		{"malformed client data", session(typeGet), []byte(`{"type":`), typeGet, ErrInvalidCredential},
+		{"wrong ceremony type", session(typeGet), clientData(typeCreate, "c1", origin, false), typeGet, ErrInvalidCredential},
+		{"cross origin", session(typeGet), clientData(typeGet, "c1", origin, true), typeGet, ErrInvalidCredential},
+		{"other origin", session(typeGet), clientData(typeGet, "c1", "https://evil.example", false), typeGet, ErrInvalidCredential},
+		{"empty challenge", session(typeGet), clientData(typeGet, "", origin, false), typeGet, ErrInvalidCredential},
+		{"unknown challenge", session(typeGet), clientData(typeGet, "c2", origin, false), typeGet, ErrChallengeExpired},
+		{"challenge for other ceremony", session(typeCreate), clientData(typeGet, "c1", origin, false), typeGet, ErrInvalidCredential},
+		{"corrupt session", []byte("{"), clientData(typeGet, "c1", origin, false), typeGet, ErrInvalidCredential},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := memoryStore{challengeKeyPrefix + "c1": tt.stored}
+			s := testService(store)
+
+			got, err := s.redeemChallenge(context.Background(), tt.clientData, tt.ceremony)
+			if err != tt.wantErr {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.UserID != userID {
+				t.Errorf("UserID = %s, want %s", got.UserID, userID)
+			}
+			// The challenge is consumed, so the same response can't be replayed
+			if _, err := s.redeemChallenge(context.Background(), tt.clientData, tt.ceremony); err != ErrChallengeExpired {
+				t.Errorf("replay err = %v, want ErrChallengeExpired", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+// Package purge runs the jobs that permanently remove soft-deleted records once their
+// grace period has passed, along with the stored files they referenced.
+package purge
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// FileDeleter removes stored files by URL (implemented by storage.S3Storage)
+type FileDeleter interface {
+	Delete(ctx context.Context, fileURL string) error
+}
+
+// BatchFunc removes up to limit records marked before the cutoff. It returns how many it
+// removed and the stored files they referenced.
+type BatchFunc func(ctx context.Context, before time.Time, limit int) (int, []string, error)
+
+// Runner periodically removes records whose grace period has passed, batch by batch
+type Runner struct {
+	what      string // what is purged, for logs (e.g. "deleted groups")
+	batch     BatchFunc
+	batchSize int
+	files     FileDeleter
+	grace     time.Duration
+	interval  time.Duration
+}
+
+func NewRunner(what string, batch BatchFunc, batchSize int, files FileDeleter, grace, interval time.Duration) *Runner {
+	return &Runner{
+		what:      what,
+		batch:     batch,
+		batchSize: batchSize,
+		files:     files,
+		grace:     grace,
+		interval:  interval,
+	}
+}
+
+// Run purges expired records every interval until ctx is cancelled
+func (r *Runner) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.purge(ctx)
+		}
+	}
+}
+
+func (r *Runner) purge(ctx context.Context) {
+	cutoff := time.Now().Add(-r.grace)
+	for {
+		n, fileURLs, err := r.batch(ctx, cutoff, r.batchSize)
+		if err != nil {
+			log.Printf("Failed to purge %s: %v", r.what, err)
+			return
+		}
+		DeleteFiles(ctx, r.files, r.what, fileURLs)
+
+		if n < r.batchSize {
+			return
+		}
+	}
+}
+
+// DeleteFiles removes the stored files of purged records. The rows are gone, so a failed
+// delete only leaves an orphaned object behind and is just logged.
+func DeleteFiles(ctx context.Context, files FileDeleter, what string, fileURLs []string) {
+	for _, url := range fileURLs {
+		if err := files.Delete(ctx, url); err != nil {
+			log.Printf("Failed to delete file %s of %s: %v", url, what, err)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	apnsProductionURL = "https://api.push.apple.com"
+	apnsSandboxURL    = "https://api.sandbox.push.apple.com"
+
+	// Apple rejects provider tokens older than an hour and throttles refreshing them
+	// more often than every 20 minutes
+	apnsTokenLifetime = 40 * time.Minute
+)
+
+// APNsConfig configures token-based (.p8 key) authentication with APNs
+type APNsConfig struct {
+	KeyFile    string // path to the AuthKey_XXXX.p8 file
+	KeyID      string
+	TeamID     string
+	BundleID   string // the app's bundle ID; VoIP pushes go to the "<bundle>.voip" topic
+	Production bool   // false = sandbox, for development builds
+}
+
+// APNsClient sends PushKit VoIP pushes over HTTP/2
+type APNsClient struct {
+	config APNsConfig
+	key    *ecdsa.PrivateKey
+	url    string
+	http   *http.Client
+
+	mu       sync.Mutex
+	token    string
+	issuedAt time.Time
+}
+
+// NewAPNsClient loads the signing key named in config
+func NewAPNsClient(config APNsConfig) (*APNsClient, error) {
+	pem, err := os.ReadFile(config.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	key, err := jwt.ParseECPrivateKeyFromPEM(pem)
+	if err != nil {
+		return nil, fmt.Errorf("apns: invalid key: %w", err)
+	}
+
+	url := apnsSandboxURL
+	if config.Production {
+		url = apnsProductionURL
+	}
+	return &APNsClient{
+		config: config,
+		key:    key,
+		url:    url,
+		http:   &http.Client{Timeout: requestTimeout},
+	}, nil
+}
+
+// providerToken returns the cached JWT APNs authenticates us with, signing a new one
+// when it gets old
+func (c *APNsClient) providerToken(now time.Time) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && now.Sub(c.issuedAt) < apnsTokenLifetime {
+		return c.token, nil
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss": c.config.TeamID,
+		"iat": now.Unix(),
+	})
+	token.Header["kid"] = c.config.KeyID
+	signed, err := token.SignedString(c.key)
+	if err != nil {
+		return "", err
+	}
+	c.token = signed
+	c.issuedAt = now
+	return signed, nil
+}
+
+type apnsError struct {
+	Reason string `json:"reason"`
+}
+
+// Send delivers a VoIP push to a device. APNs drops it if it can't be delivered before
+// expiration. ErrInvalidToken means the token should be forgotten.
+func (c *APNsClient) Send(ctx context.Context, deviceToken string, payload map[string]string, expiration time.Time) error {
+	authToken, err := c.providerToken(time.Now())
+	if err != nil {
+		return err
+	}
+
+	body := map[string]interface{}{"aps": map[string]interface{}{}}
+	for k, v := range payload {
+		body[k] = v
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+"/3/device/"+deviceToken, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "bearer "+authToken)
+	req.Header.Set("apns-topic", c.config.BundleID+".voip")
+	req.Header.Set("apns-push-type", "voip")
+	req.Header.Set("apns-priority", "10")
+	req.Header.Set("apns-expiration", strconv.FormatInt(expiration.Unix(), 10))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	var apnsErr apnsError
+	_ = json.NewDecoder(resp.Body).Decode(&apnsErr)
+	if resp.StatusCode == http.StatusGone || apnsErr.Reason == "BadDeviceToken" || apnsErr.Reason == "DeviceTokenNotForTopic" {
+		return ErrInvalidToken
+	}
+	return fmt.Errorf("apns: unexpected status %d: %s", resp.StatusCode, apnsErr.Reason)
+}
@@ -0,0 +1,180 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	fcmScope   = "https://www.googleapis.com/auth/firebase.messaging"
+	fcmSendURL = "https://fcm.googleapis.com/v1/projects/%s/messages:send"
+)
+
+// serviceAccount is the part of a Google service account key file FCM needs
+type serviceAccount struct {
+	ProjectID   string `json:"project_id"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// FCMClient sends high-priority data messages through the FCM HTTP v1 API
+type FCMClient struct {
+	account serviceAccount
+	key     *rsa.PrivateKey
+	http    *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+// NewFCMClient loads a service account key file downloaded from the Firebase console
+func NewFCMClient(credentialsFile string) (*FCMClient, error) {
+	data, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	var account serviceAccount
+	if err := json.Unmarshal(data, &account); err != nil {
+		return nil, fmt.Errorf("fcm: invalid credentials: %w", err)
+	}
+	if account.ProjectID == "" || account.ClientEmail == "" || account.TokenURI == "" {
+		return nil, fmt.Errorf("fcm: credentials are missing project_id, client_email or token_uri")
+	}
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(account.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("fcm: invalid private key: %w", err)
+	}
+
+	return &FCMClient{
+		account: account,
+		key:     key,
+		http:    &http.Client{Timeout: requestTimeout},
+	}, nil
+}
+
+type oauthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// token returns a cached OAuth2 access token, exchanging a signed assertion for a new
+// one shortly before it expires
+func (c *FCMClient) token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if c.accessToken != "" && now.Before(c.expiresAt.Add(-time.Minute)) {
+		return c.accessToken, nil
+	}
+
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   c.account.ClientEmail,
+		"scope": fcmScope,
+		"aud":   c.account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(c.key)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	form.Set("assertion", assertion)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.account.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fcm: token exchange failed with status %d", resp.StatusCode)
+	}
+
+	var tok oauthTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", err
+	}
+	c.accessToken = tok.AccessToken
+	c.expiresAt = now.Add(time.Duration(tok.ExpiresIn) * time.Second)
+	return c.accessToken, nil
+}
+
+type fcmMessage struct {
+	Message struct {
+		Token   string            `json:"token"`
+		Data    map[string]string `json:"data"`
+		Android struct {
+			Priority string `json:"priority"`
+			TTL      string `json:"ttl"`
+		} `json:"android"`
+	} `json:"message"`
+}
+
+type fcmError struct {
+	Error struct {
+		Status string `json:"status"`
+	} `json:"error"`
+}
+
+// Send delivers a high-priority data message to a device. FCM drops it if it can't be
+// delivered within ttl. ErrInvalidToken means the token should be forgotten.
+func (c *FCMClient) Send(ctx context.Context, deviceToken string, payload map[string]string, ttl time.Duration) error {
+	accessToken, err := c.token(ctx)
+	if err != nil {
+		return err
+	}
+
+	var msg fcmMessage
+	msg.Message.Token = deviceToken
+	msg.Message.Data = payload
+	msg.Message.Android.Priority = "HIGH"
+	msg.Message.Android.TTL = strconv.Itoa(int(ttl.Seconds())) + "s"
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf(fcmSendURL, c.account.ProjectID), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	var fcmErr fcmError
+	_ = json.NewDecoder(resp.Body).Decode(&fcmErr)
+	if resp.StatusCode == http.StatusNotFound || fcmErr.Error.Status == "UNREGISTERED" {
+		return ErrInvalidToken
+	}
+	return fmt.Errorf("fcm: unexpected status %d: %s", resp.StatusCode, fcmErr.Error.Status)
+}
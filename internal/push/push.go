@@ -0,0 +1,110 @@
+// Package push wakes mobile devices for incoming calls with APNs PushKit and FCM pushes.
+package push
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/models"
+)
+
+const (
+	PlatformAPNsVoIP = "apns_voip"
+	PlatformFCM      = "fcm"
+
+	requestTimeout = 10 * time.Second
+)
+
+var ErrInvalidToken = errors.New("push token is no longer valid")
+
+// ValidPlatform reports whether devices can register for the platform
+func ValidPlatform(platform string) bool {
+	return platform == PlatformAPNsVoIP || platform == PlatformFCM
+}
+
+// CallPusher sends incoming call pushes to the registered devices of rung users
+type CallPusher struct {
+	repo *Repository
+	apns *APNsClient // nil = APNs not configured
+	fcm  *FCMClient  // nil = FCM not configured
+}
+
+func NewCallPusher(repo *Repository, apns *APNsClient, fcm *FCMClient) *CallPusher {
+	return &CallPusher{repo: repo, apns: apns, fcm: fcm}
+}
+
+func (p *CallPusher) platforms() []string {
+	var platforms []string
+	if p.apns != nil {
+		platforms = append(platforms, PlatformAPNsVoIP)
+	}
+	if p.fcm != nil {
+		platforms = append(platforms, PlatformFCM)
+	}
+	return platforms
+}
+
+// PushCall wakes the users' devices for a ringing call. The pushes expire when the call
+// stops ringing, so a device that comes online later doesn't ring for a stale call.
+func (p *CallPusher) PushCall(ctx context.Context, userIDs []uuid.UUID, ring *models.CallRingEvent) {
+	platforms := p.platforms()
+	if len(platforms) == 0 || len(userIDs) == 0 {
+		return
+	}
+	ttl := time.Until(ring.ExpiresAt)
+	if ttl <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	devices, err := p.repo.GetDevices(ctx, userIDs, platforms)
+	if err != nil {
+		log.Printf("Failed to get push devices: %v", err)
+		return
+	}
+
+	payload := map[string]string{
+		"type":            "CALL_RING",
+		"call_id":         ring.CallID.String(),
+		"conversation_id": ring.ConversationID.String(),
+		"expires_at":      ring.ExpiresAt.UTC().Format(time.RFC3339),
+	}
+	if ring.Caller != nil {
+		payload["caller_id"] = ring.Caller.ID.String()
+		if ring.Caller.Username != nil {
+			payload["caller_name"] = *ring.Caller.Username
+		}
+		if ring.Caller.AvatarURL != nil {
+			payload["caller_avatar_url"] = *ring.Caller.AvatarURL
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, d := range devices {
+		wg.Add(1)
+		go func(d models.PushDevice) {
+			defer wg.Done()
+
+			var err error
+			if d.Platform == PlatformAPNsVoIP {
+				err = p.apns.Send(ctx, d.Token, payload, ring.ExpiresAt)
+			} else {
+				err = p.fcm.Send(ctx, d.Token, payload, ttl)
+			}
+			if errors.Is(err, ErrInvalidToken) {
+				if err := p.repo.DeleteToken(ctx, d.Platform, d.Token); err != nil {
+					log.Printf("Failed to delete push token: %v", err)
+				}
+			} else if err != nil {
+				log.Printf("Failed to send %s call push to %s: %v", d.Platform, d.UserID, err)
+			}
+		}(d)
+	}
+	wg.Wait()
+}
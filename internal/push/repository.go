@@ -0,0 +1,63 @@
+package push
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/user/bla-back/internal/models"
+)
+
+type Repository struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{db: db}
+}
+
+// RegisterDevice stores a device's push token for the user. A token already registered
+// (e.g. by another account that used the device) moves to this user.
+func (r *Repository) RegisterDevice(ctx context.Context, userID uuid.UUID, platform, token string) (*models.PushDevice, error) {
+	d := &models.PushDevice{}
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO push_devices (user_id, platform, token)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (platform, token) DO UPDATE SET user_id = EXCLUDED.user_id, updated_at = NOW()
+		RETURNING user_id, platform, token, created_at, updated_at
+	`, userID, platform, token).Scan(&d.UserID, &d.Platform, &d.Token, &d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// UnregisterDevice removes one of the user's push tokens (e.g. on logout)
+func (r *Repository) UnregisterDevice(ctx context.Context, userID uuid.UUID, token string) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM push_devices WHERE user_id = $1 AND token = $2`, userID, token)
+	return err
+}
+
+// GetDevices returns the registered devices of the users on the given platforms
+func (r *Repository) GetDevices(ctx context.Context, userIDs []uuid.UUID, platforms []string) ([]models.PushDevice, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT user_id, platform, token, created_at, updated_at
+		FROM push_devices
+		WHERE user_id = ANY($1) AND platform = ANY($2)
+	`, userIDs, platforms)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.PushDevice, error) {
+		var d models.PushDevice
+		err := row.Scan(&d.UserID, &d.Platform, &d.Token, &d.CreatedAt, &d.UpdatedAt)
+		return d, err
+	})
+}
+
+// DeleteToken drops a token the push service reported as no longer valid
+func (r *Repository) DeleteToken(ctx context.Context, platform, token string) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM push_devices WHERE platform = $1 AND token = $2`, platform, token)
+	return err
+}
@@ -0,0 +1,104 @@
+package realtime
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/user/bla-back/internal/metrics"
+)
+
+const defaultSlowClientThreshold = 64
+
+// droppableEvents may be skipped for lagging clients because a later event of the
+// same type supersedes them. Everything else (MESSAGE_CREATE etc.) is always queued.
+var droppableEvents = map[string]bool{
+	"PRESENCE_UPDATE": true,
+	EventTypingStart:  true,
+}
+
+// backpressure tracks publications queued per connection but not yet written
+type backpressure struct {
+	mu        sync.Mutex
+	pending   map[string]int // clientID -> queued publications
+	slow      map[string]bool
+	threshold int
+}
+
+func newBackpressure(threshold int) *backpressure {
+	return &backpressure{
+		pending:   make(map[string]int),
+		slow:      make(map[string]bool),
+		threshold: threshold,
+	}
+}
+
+// queued records one publication on the channel for each of the given connections that
+// is subscribed to it (in its event version); the others never see it written
+func (b *backpressure) queued(clients map[string]*centrifuge.Client, channel string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for clientID, client := range clients {
+		if !client.IsSubscribed(versionedChannel(channel, clientVersion(client))) {
+			continue
+		}
+		b.pending[clientID]++
+		b.updateSlow(clientID)
+	}
+}
+
+// written records that a publication was written to the connection
+func (b *backpressure) written(clientID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pending[clientID] > 0 {
+		b.pending[clientID]--
+	}
+	b.updateSlow(clientID)
+}
+
+// lagging reports whether any of the connections is over the slow client threshold
+func (b *backpressure) lagging(clients map[string]*centrifuge.Client) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for clientID := range clients {
+		if b.pending[clientID] > b.threshold {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *backpressure) remove(clientID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.pending, clientID)
+	if b.slow[clientID] {
+		delete(b.slow, clientID)
+		metrics.RealtimeSlowClients.Dec()
+	}
+}
+
+// updateSlow keeps the slow clients gauge in sync (must hold mu)
+func (b *backpressure) updateSlow(clientID string) {
+	isSlow := b.pending[clientID] > b.threshold
+	if isSlow == b.slow[clientID] {
+		return
+	}
+	if isSlow {
+		b.slow[clientID] = true
+		metrics.RealtimeSlowClients.Inc()
+	} else {
+		delete(b.slow, clientID)
+		metrics.RealtimeSlowClients.Dec()
+	}
+}
+
+func isUserChannel(channel string) bool {
+	return strings.HasPrefix(channel, "user:")
+}
+
+// isTrackedChannel reports whether publications on the channel count toward backpressure
+func isTrackedChannel(channel string) bool {
+	return isUserChannel(channel) || isConversationChannel(channel)
+}
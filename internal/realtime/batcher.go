@@ -0,0 +1,103 @@
+package realtime
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/models"
+)
+
+const (
+	defaultFlushWindow = 300 * time.Millisecond
+
+	EventTypingStart = "TYPING_START"
+	EventReadReceipt = "READ_RECEIPT"
+)
+
+type batchKey struct {
+	conversationID uuid.UUID
+	eventType      string
+}
+
+// batch collects per-user items for one conversation until the window flushes
+type batch struct {
+	recipients []uuid.UUID
+	order      []uuid.UUID
+	items      map[uuid.UUID]interface{} // userID -> latest item
+}
+
+// batcher aggregates high-frequency events (typing, read receipts) per conversation
+// and publishes them once per flush window instead of once per client action
+type batcher struct {
+	node    *Node
+	window  time.Duration
+	mu      sync.Mutex
+	pending map[batchKey]*batch
+}
+
+func newBatcher(node *Node, window time.Duration) *batcher {
+	return &batcher{
+		node:    node,
+		window:  window,
+		pending: make(map[batchKey]*batch),
+	}
+}
+
+// add queues an item; later items from the same user in the same window replace earlier ones
+func (b *batcher) add(conversationID uuid.UUID, eventType string, userID uuid.UUID, item interface{}, recipients []uuid.UUID) {
+	key := batchKey{conversationID: conversationID, eventType: eventType}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	bt, ok := b.pending[key]
+	if !ok {
+		bt = &batch{items: make(map[uuid.UUID]interface{})}
+		b.pending[key] = bt
+		time.AfterFunc(b.window, func() { b.flush(key) })
+	}
+
+	if _, seen := bt.items[userID]; !seen {
+		bt.order = append(bt.order, userID)
+	}
+	bt.items[userID] = item
+	bt.recipients = recipients
+}
+
+func (b *batcher) flush(key batchKey) {
+	b.mu.Lock()
+	bt, ok := b.pending[key]
+	delete(b.pending, key)
+	b.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	var event interface{}
+	switch key.eventType {
+	case EventTypingStart:
+		event = &models.TypingEvent{
+			ConversationID: key.conversationID,
+			UserIDs:        bt.order,
+		}
+	case EventReadReceipt:
+		receipts := make([]*models.ReadReceipt, 0, len(bt.order))
+		for _, userID := range bt.order {
+			if receipt, ok := bt.items[userID].(*models.ReadReceipt); ok {
+				receipts = append(receipts, receipt)
+			}
+		}
+		event = &models.ReadReceiptEvent{
+			ConversationID: key.conversationID,
+			Receipts:       receipts,
+		}
+	default:
+		log.Printf("Unknown batched event type: %s", key.eventType)
+		return
+	}
+
+	b.node.PublishToUsers(bt.recipients, key.eventType, event)
+}
@@ -0,0 +1,70 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/apiversion"
+)
+
+// Call channels ("call:<callID>") carry the in-call chat overlay. Only the server publishes
+// into them, after the HTTP API has checked the sender is in the call.
+const callChannelPrefix = "call:"
+
+// CallChannel returns the chat channel of a call
+func CallChannel(callID uuid.UUID) string {
+	return callChannelPrefix + callID.String()
+}
+
+func isCallChannel(channel string) bool {
+	return strings.HasPrefix(channel, callChannelPrefix)
+}
+
+// subscribeCall lets users currently in the call join its chat channel
+func (n *Node) subscribeCall(userID uuid.UUID, e centrifuge.SubscribeEvent, cb centrifuge.SubscribeCallback) {
+	callID, err := uuid.Parse(strings.TrimPrefix(e.Channel, callChannelPrefix))
+	if err != nil {
+		cb(centrifuge.SubscribeReply{}, centrifuge.ErrorUnknownChannel)
+		return
+	}
+
+	ok, err := n.membership.IsCallParticipant(context.Background(), callID, userID)
+	if err != nil {
+		log.Printf("Failed to authorize call channel for user %s: %v", userID, err)
+		cb(centrifuge.SubscribeReply{}, centrifuge.ErrorInternal)
+		return
+	}
+	if !ok {
+		cb(centrifuge.SubscribeReply{}, centrifuge.ErrorPermissionDenied)
+		return
+	}
+
+	cb(centrifuge.SubscribeReply{}, nil)
+}
+
+// PublishToCall sends an event to everyone subscribed to the call's chat channel
+func (n *Node) PublishToCall(callID uuid.UUID, eventType string, data interface{}) error {
+	// Call chat messages have no versioned fields, so the channel isn't versioned
+	payload, err := json.Marshal(map[string]interface{}{
+		"type": eventType,
+		"v":    apiversion.Oldest,
+		"data": data,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = n.node.Publish(CallChannel(callID), payload)
+	return err
+}
+
+// RevokeCall removes a user who left the call from its chat channel
+func (n *Node) RevokeCall(callID, userID uuid.UUID) {
+	if err := n.node.Unsubscribe(userID.String(), CallChannel(callID)); err != nil {
+		log.Printf("Failed to revoke call subscription for user %s: %v", userID, err)
+	}
+}
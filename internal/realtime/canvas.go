@@ -0,0 +1,85 @@
+package realtime
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/google/uuid"
+)
+
+// Canvas channels ("canvas:<conversationID>") carry ephemeral collaborative events such as
+// whiteboard strokes and pointer positions. Clients publish into them directly; publications
+// are fanned out to subscribers and never stored (no history, nothing reaches the database).
+const (
+	canvasChannelPrefix = "canvas:"
+
+	// maxCanvasPayload bounds a single stroke/pointer publication
+	maxCanvasPayload = 8 << 10
+)
+
+// MembershipProvider authorizes conversation- and call-scoped channels
+type MembershipProvider interface {
+	IsParticipant(ctx context.Context, conversationID, userID uuid.UUID) (bool, error)
+	IsCallParticipant(ctx context.Context, callID, userID uuid.UUID) (bool, error)
+}
+
+// CanvasChannel returns the ephemeral channel of a conversation
+func CanvasChannel(conversationID uuid.UUID) string {
+	return canvasChannelPrefix + conversationID.String()
+}
+
+func isCanvasChannel(channel string) bool {
+	return strings.HasPrefix(channel, canvasChannelPrefix)
+}
+
+// subscribeCanvas lets participants of the conversation join its canvas channel.
+// Join/leave is emitted so clients can drop remote cursors when someone leaves.
+func (n *Node) subscribeCanvas(userID uuid.UUID, e centrifuge.SubscribeEvent, cb centrifuge.SubscribeCallback) {
+	conversationID, err := uuid.Parse(strings.TrimPrefix(e.Channel, canvasChannelPrefix))
+	if err != nil {
+		cb(centrifuge.SubscribeReply{}, centrifuge.ErrorUnknownChannel)
+		return
+	}
+
+	ok, err := n.membership.IsParticipant(context.Background(), conversationID, userID)
+	if err != nil {
+		log.Printf("Failed to authorize canvas channel for user %s: %v", userID, err)
+		cb(centrifuge.SubscribeReply{}, centrifuge.ErrorInternal)
+		return
+	}
+	if !ok {
+		cb(centrifuge.SubscribeReply{}, centrifuge.ErrorPermissionDenied)
+		return
+	}
+
+	cb(centrifuge.SubscribeReply{
+		Options: centrifuge.SubscribeOptions{
+			EmitJoinLeave: true,
+			PushJoinLeave: true,
+		},
+	}, nil)
+}
+
+// publishCanvas relays a client publication to the other subscribers. Only subscribed
+// clients may publish; the sender is attached by Centrifuge from the connection's
+// credentials, so it can't be spoofed in the payload.
+func (n *Node) publishCanvas(client *centrifuge.Client, e centrifuge.PublishEvent, cb centrifuge.PublishCallback) {
+	if !isCanvasChannel(e.Channel) || !client.IsSubscribed(e.Channel) {
+		cb(centrifuge.PublishReply{}, centrifuge.ErrorPermissionDenied)
+		return
+	}
+	if len(e.Data) > maxCanvasPayload {
+		cb(centrifuge.PublishReply{}, centrifuge.ErrorBadRequest)
+		return
+	}
+	cb(centrifuge.PublishReply{}, nil)
+}
+
+// RevokeCanvas removes a user who left a conversation from its canvas channel
+func (n *Node) RevokeCanvas(conversationID, userID uuid.UUID) {
+	if err := n.node.Unsubscribe(userID.String(), CanvasChannel(conversationID)); err != nil {
+		log.Printf("Failed to revoke canvas subscription for user %s: %v", userID, err)
+	}
+}
@@ -2,7 +2,6 @@ package realtime
 
 import (
 	"context"
-	"encoding/json"
 	"log"
 	"net/http"
 	"sync"
@@ -10,13 +9,25 @@ import (
 
 	"github.com/centrifugal/centrifuge"
 	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/apiversion"
 	"github.com/user/bla-back/internal/auth"
+	"github.com/user/bla-back/internal/cache"
+	"github.com/user/bla-back/internal/metrics"
 	"github.com/user/bla-back/internal/models"
 )
 
 // DataProvider loads initial state for a user
 type DataProvider interface {
 	GetReadyState(ctx context.Context, userID uuid.UUID) (*models.ReadyEvent, error)
+	// MarkDeliveredOnReady acknowledges DM messages handed to the device with READY.
+	// Each event comes with the users to notify.
+	MarkDeliveredOnReady(ctx context.Context, userID uuid.UUID) ([]DeliveryNotice, error)
+}
+
+// DeliveryNotice is a MESSAGE_DELIVERED event and who should receive it
+type DeliveryNotice struct {
+	Event      *models.MessageDeliveredEvent
+	Recipients []uuid.UUID
 }
 
 // FriendsProvider provides friend relationships
@@ -24,33 +35,108 @@ type FriendsProvider interface {
 	GetFriendIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error)
 }
 
+// NodeConfig tunes realtime delivery
+type NodeConfig struct {
+	// FlushWindow is how long typing/read receipt events are aggregated before publishing
+	FlushWindow time.Duration
+	// ClientQueueMaxSize is the per-connection queue limit in bytes; exceeding it disconnects the client
+	ClientQueueMaxSize int
+	// SlowClientThreshold is how many unwritten publications make a connection "slow",
+	// after which droppable events (presence, typing) are skipped for it
+	SlowClientThreshold int
+	// RedisAddr makes nodes share a Redis broker, so publications and disconnects reach
+	// connections on every node (empty = in-memory broker, this node only)
+	RedisAddr string
+}
+
+// Disconnect times are only needed to tell how long a user has been offline, which
+// matters for minutes (see notifications.OfflineLongerThan), so old ones are dropped
+const (
+	lastSeenCacheSize = 100000
+	lastSeenTTL       = 24 * time.Hour
+)
+
 type Node struct {
 	node            *centrifuge.Node
 	tokenService    *auth.TokenService
 	dataProvider    DataProvider
 	friendsProvider FriendsProvider
+	membership      MembershipProvider
 
 	// Track online users
-	onlineUsers   map[uuid.UUID]int // userID -> connection count
+	onlineUsers   map[uuid.UUID]int       // userID -> connection count
+	lastSeen      *cache.LRU[uuid.UUID, time.Time] // userID -> when last connection closed
 	onlineUsersMu sync.RWMutex
+	startedAt     time.Time
+
+	// Events for users with no connections on any node are also queued here
+	offlineQueue OfflineQueue
+
+	// Whether publications reach other nodes through the Redis broker
+	sharedBroker bool
+
+	// Closed on shutdown to stop background loops
+	done chan struct{}
+
+	// Aggregates typing/read receipt events per conversation
+	batcher *batcher
+
+	// Tracks per-connection queue depth for the slow client policy
+	backpressure *backpressure
 }
 
-func NewNode(tokenService *auth.TokenService, dataProvider DataProvider, friendsProvider FriendsProvider) (*Node, error) {
+func NewNode(tokenService *auth.TokenService, dataProvider DataProvider, friendsProvider FriendsProvider, membership MembershipProvider, cfg NodeConfig) (*Node, error) {
+	if cfg.FlushWindow <= 0 {
+		cfg.FlushWindow = defaultFlushWindow
+	}
+	if cfg.SlowClientThreshold <= 0 {
+		cfg.SlowClientThreshold = defaultSlowClientThreshold
+	}
+
 	node, err := centrifuge.New(centrifuge.Config{
-		LogLevel:   centrifuge.LogLevelInfo,
-		LogHandler: func(e centrifuge.LogEntry) { log.Printf("[centrifuge] %s: %v", e.Message, e.Fields) },
+		LogLevel:           centrifuge.LogLevelInfo,
+		LogHandler:         func(e centrifuge.LogEntry) { log.Printf("[centrifuge] %s: %v", e.Message, e.Fields) },
+		ClientQueueMaxSize: cfg.ClientQueueMaxSize,
 	})
 	if err != nil {
 		return nil, err
 	}
+	if cfg.RedisAddr != "" {
+		shard, err := centrifuge.NewRedisShard(node, centrifuge.RedisShardConfig{Address: cfg.RedisAddr})
+		if err != nil {
+			return nil, err
+		}
+		broker, err := centrifuge.NewRedisBroker(node, centrifuge.RedisBrokerConfig{
+			Shards: []*centrifuge.RedisShard{shard},
+		})
+		if err != nil {
+			return nil, err
+		}
+		node.SetBroker(broker)
+	}
 
 	n := &Node{
 		node:            node,
 		tokenService:    tokenService,
 		dataProvider:    dataProvider,
 		friendsProvider: friendsProvider,
+		membership:      membership,
 		onlineUsers:     make(map[uuid.UUID]int),
+		lastSeen:        cache.NewLRU[uuid.UUID, time.Time](lastSeenCacheSize, lastSeenTTL),
+		startedAt:       time.Now(),
+		done:            make(chan struct{}),
+		sharedBroker:    cfg.RedisAddr != "",
 	}
+	n.batcher = newBatcher(n, cfg.FlushWindow)
+	n.backpressure = newBackpressure(cfg.SlowClientThreshold)
+
+	// Count written publications so queued-but-unsent depth is known per connection
+	node.OnTransportWrite(func(client *centrifuge.Client, e centrifuge.TransportWriteEvent) bool {
+		if isTrackedChannel(e.Channel) {
+			n.backpressure.written(client.ID())
+		}
+		return true
+	})
 
 	// Auth via JWT in connect request
 	node.OnConnecting(func(ctx context.Context, e centrifuge.ConnectEvent) (centrifuge.ConnectReply, error) {
@@ -59,15 +145,21 @@ func NewNode(tokenService *auth.TokenService, dataProvider DataProvider, friends
 			return centrifuge.ConnectReply{}, centrifuge.DisconnectInvalidToken
 		}
 
-		claims, err := tokenService.ValidateAccessToken(token)
+		claims, err := tokenService.ValidateAccessToken(ctx, token)
 		if err != nil {
 			return centrifuge.ConnectReply{}, centrifuge.DisconnectInvalidToken
 		}
 
+		version, err := connectVersion(ctx, e.Data)
+		if err != nil {
+			return centrifuge.ConnectReply{}, centrifuge.DisconnectBadRequest
+		}
+
 		return centrifuge.ConnectReply{
 			Credentials: &centrifuge.Credentials{
 				UserID: claims.UserID.String(),
 			},
+			Context: apiversion.WithVersion(ctx, version),
 		}, nil
 	})
 
@@ -80,12 +172,38 @@ func NewNode(tokenService *auth.TokenService, dataProvider DataProvider, friends
 		}
 
 		// Track connection and notify friends if first connection
+		if n.offlineQueue != nil {
+			n.offlineQueue.Connected(userID, client.ID())
+		}
 		wasOffline := n.addOnlineUser(userID)
 		if wasOffline {
 			go n.notifyPresenceChange(userID, "online")
 		}
 
 		client.OnSubscribe(func(e centrifuge.SubscribeEvent, cb centrifuge.SubscribeCallback) {
+			// User and conversation channels must be the ones of the connection's version;
+			// other channels aren't versioned
+			base, version, ok := splitChannelVersion(e.Channel)
+			versioned := isUserChannel(base) || isConversationChannel(base)
+			if !ok || versioned && version != clientVersion(client) || !versioned && version != apiversion.Oldest {
+				cb(centrifuge.SubscribeReply{}, centrifuge.ErrorPermissionDenied)
+				return
+			}
+			e.Channel = base
+
+			if isConversationChannel(e.Channel) {
+				n.subscribeConversation(userID, e, cb)
+				return
+			}
+			if isCanvasChannel(e.Channel) {
+				n.subscribeCanvas(userID, e, cb)
+				return
+			}
+			if isCallChannel(e.Channel) {
+				n.subscribeCall(userID, e, cb)
+				return
+			}
+
 			expectedChannel := "user:" + client.UserID()
 			if e.Channel != expectedChannel {
 				cb(centrifuge.SubscribeReply{}, centrifuge.ErrorPermissionDenied)
@@ -111,6 +229,9 @@ func NewNode(tokenService *auth.TokenService, dataProvider DataProvider, friends
 
 			// Enrich conversation participants with current online status
 			for _, conv := range readyState.Conversations {
+				if conv.RequestStatus != nil {
+					continue // not shared until the message request is accepted
+				}
 				for _, participant := range conv.Participants {
 					if n.IsOnline(participant.ID) {
 						participant.Status = "online"
@@ -120,19 +241,39 @@ func NewNode(tokenService *auth.TokenService, dataProvider DataProvider, friends
 				}
 			}
 
-			// Send READY event after subscription
+			// Send READY event after subscription, then what was queued while offline
 			go func() {
 				time.Sleep(10 * time.Millisecond) // Small delay to ensure subscription is complete
 				if err := n.PublishToUser(userID, "READY", readyState); err != nil {
 					log.Printf("Failed to send READY to user %s: %v", userID, err)
+					return
+				}
+				n.replayOffline(userID)
+
+				notices, err := n.dataProvider.MarkDeliveredOnReady(context.Background(), userID)
+				if err != nil {
+					log.Printf("Failed to mark messages delivered for user %s: %v", userID, err)
+					return
+				}
+				for _, notice := range notices {
+					n.PublishToUsers(notice.Recipients, "MESSAGE_DELIVERED", notice.Event)
 				}
 			}()
 
 			cb(centrifuge.SubscribeReply{}, nil)
 		})
 
+		// Only ephemeral canvas channels accept client publications
+		client.OnPublish(func(e centrifuge.PublishEvent, cb centrifuge.PublishCallback) {
+			n.publishCanvas(client, e, cb)
+		})
+
 		client.OnDisconnect(func(e centrifuge.DisconnectEvent) {
 			log.Printf("Client disconnected: %s (reason: %s)", client.ID(), e.Reason)
+			n.backpressure.remove(client.ID())
+			if n.offlineQueue != nil {
+				n.offlineQueue.Disconnected(userID, client.ID())
+			}
 
 			// Remove connection and notify friends if last connection
 			wentOffline := n.removeOnlineUser(userID)
@@ -167,6 +308,7 @@ func (n *Node) removeOnlineUser(userID uuid.UUID) bool {
 	n.onlineUsers[userID]--
 	if n.onlineUsers[userID] <= 0 {
 		delete(n.onlineUsers, userID)
+		n.lastSeen.Set(userID, time.Now())
 		return true
 	}
 	return false
@@ -179,6 +321,15 @@ func (n *Node) IsOnline(userID uuid.UUID) bool {
 	return n.onlineUsers[userID] > 0
 }
 
+// LastSeen returns when the user's last connection closed. Users not seen since this
+// node started, or not within lastSeenTTL, are reported as last seen at startup.
+func (n *Node) LastSeen(userID uuid.UUID) time.Time {
+	if t, ok := n.lastSeen.Get(userID); ok {
+		return t
+	}
+	return n.startedAt
+}
+
 // notifyPresenceChange notifies all friends about a user's status change
 func (n *Node) notifyPresenceChange(userID uuid.UUID, status string) {
 	friendIDs, err := n.friendsProvider.GetFriendIDs(context.Background(), userID)
@@ -195,7 +346,17 @@ func (n *Node) notifyPresenceChange(userID uuid.UUID, status string) {
 	n.PublishToUsers(friendIDs, "PRESENCE_UPDATE", event)
 }
 
+// DisconnectUser closes the user's connections, for when their sessions were revoked.
+// Other nodes only get the disconnect through the shared Redis broker; without one,
+// their connections stay open, but reconnecting needs a valid access token again.
+func (n *Node) DisconnectUser(userID uuid.UUID) {
+	if err := n.node.Disconnect(userID.String(), centrifuge.WithCustomDisconnect(centrifuge.DisconnectForceNoReconnect)); err != nil {
+		log.Printf("Failed to disconnect user %s: %v", userID, err)
+	}
+}
+
 func (n *Node) Shutdown(ctx context.Context) error {
+	close(n.done)
 	return n.node.Shutdown(ctx)
 }
 
@@ -210,25 +371,77 @@ func (n *Node) WebsocketHandler() http.Handler {
 	return wsHandler
 }
 
-func (n *Node) PublishToUser(userID uuid.UUID, eventType string, data interface{}) error {
-	channel := "user:" + userID.String()
+// SetOfflineQueue sets where events for offline users are queued until they reconnect
+func (n *Node) SetOfflineQueue(q OfflineQueue) {
+	n.offlineQueue = q
+	go n.refreshConnections()
+}
 
-	payload, err := json.Marshal(map[string]interface{}{
-		"type": eventType,
-		"data": data,
-	})
+// PublishTyping queues a typing indicator, published with others from the same window
+func (n *Node) PublishTyping(conversationID, userID uuid.UUID, recipients []uuid.UUID) {
+	n.batcher.add(conversationID, EventTypingStart, userID, nil, recipients)
+}
+
+// PublishReadReceipt queues a read receipt, published with others from the same window
+func (n *Node) PublishReadReceipt(conversationID uuid.UUID, receipt *models.ReadReceipt, recipients []uuid.UUID) {
+	n.batcher.add(conversationID, EventReadReceipt, receipt.UserID, receipt, recipients)
+}
+
+func (n *Node) PublishToUser(userID uuid.UUID, eventType string, data interface{}) error {
+	payloads, err := encodeEvent(eventType, data)
 	if err != nil {
 		return err
 	}
 
-	_, err = n.node.Publish(channel, payload)
-	return err
+	// A user without connections here gets the event queued (oldest shape) unless they're
+	// connected to another node. Only a shared broker can reach those connections.
+	if !n.IsOnline(userID) {
+		n.queueOffline([]uuid.UUID{userID}, eventType, payloads[0])
+		if !n.sharedBroker {
+			return nil
+		}
+	}
+	return n.publishUser(userID, eventType, payloads)
 }
 
 func (n *Node) PublishToUsers(userIDs []uuid.UUID, eventType string, data interface{}) {
+	payloads, err := encodeEvent(eventType, data)
+	if err != nil {
+		log.Printf("Failed to encode %s: %v", eventType, err)
+		return
+	}
+
+	// Users without connections here are queued together, in one connection check
+	var offline []uuid.UUID
 	for _, userID := range userIDs {
-		if err := n.PublishToUser(userID, eventType, data); err != nil {
+		if !n.IsOnline(userID) {
+			offline = append(offline, userID)
+			if !n.sharedBroker {
+				continue
+			}
+		}
+		if err := n.publishUser(userID, eventType, payloads); err != nil {
 			log.Printf("Failed to publish to user %s: %v", userID, err)
 		}
 	}
+	n.queueOffline(offline, eventType, payloads[0])
+}
+
+// publishUser publishes an encoded event to a user's channel. The slow client policy
+// applies to the user's connections on this node.
+func (n *Node) publishUser(userID uuid.UUID, eventType string, payloads [][]byte) error {
+	channel := "user:" + userID.String()
+
+	// Slow consumers skip superseded events instead of growing their queue
+	clients := n.node.Hub().UserConnections(userID.String())
+	if droppableEvents[eventType] && n.backpressure.lagging(clients) {
+		metrics.RealtimeDroppedEvents.WithLabelValues(eventType).Inc()
+		return nil
+	}
+
+	err := n.publishVersions(channel, payloads)
+	if err == nil {
+		n.backpressure.queued(clients, channel)
+	}
+	return err
 }
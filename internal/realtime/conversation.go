@@ -0,0 +1,101 @@
+package realtime
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/apiversion"
+)
+
+// Conversation channels ("conv:<conversationID>") carry message and reaction events.
+// An event is published once per conversation instead of once per participant's user
+// channel; participants subscribe to the channels of the conversations listed in READY,
+// suffixed with their event version (see versionedChannel).
+const conversationChannelPrefix = "conv:"
+
+// ConversationChannel returns the event channel of a conversation
+func ConversationChannel(conversationID uuid.UUID) string {
+	return conversationChannelPrefix + conversationID.String()
+}
+
+func isConversationChannel(channel string) bool {
+	return strings.HasPrefix(channel, conversationChannelPrefix)
+}
+
+// subscribeConversation lets participants of the conversation join its event channel
+func (n *Node) subscribeConversation(userID uuid.UUID, e centrifuge.SubscribeEvent, cb centrifuge.SubscribeCallback) {
+	conversationID, err := uuid.Parse(strings.TrimPrefix(e.Channel, conversationChannelPrefix))
+	if err != nil {
+		cb(centrifuge.SubscribeReply{}, centrifuge.ErrorUnknownChannel)
+		return
+	}
+
+	ok, err := n.membership.IsParticipant(context.Background(), conversationID, userID)
+	if err != nil {
+		log.Printf("Failed to authorize conversation channel for user %s: %v", userID, err)
+		cb(centrifuge.SubscribeReply{}, centrifuge.ErrorInternal)
+		return
+	}
+	if !ok {
+		cb(centrifuge.SubscribeReply{}, centrifuge.ErrorPermissionDenied)
+		return
+	}
+
+	cb(centrifuge.SubscribeReply{}, nil)
+}
+
+// PublishToConversation sends an event once on the conversation channel. Participants
+// without connections get it through the offline queue, as with PublishToUser.
+func (n *Node) PublishToConversation(conversationID uuid.UUID, participantIDs []uuid.UUID, eventType string, data interface{}) {
+	payloads, err := encodeEvent(eventType, data)
+	if err != nil {
+		log.Printf("Failed to encode %s for conversation %s: %v", eventType, conversationID, err)
+		return
+	}
+
+	channel := ConversationChannel(conversationID)
+	if err := n.publishVersions(channel, payloads); err != nil {
+		log.Printf("Failed to publish to conversation %s: %v", conversationID, err)
+	} else {
+		clients := make(map[string]*centrifuge.Client)
+		for _, userID := range participantIDs {
+			for clientID, client := range n.node.Hub().UserConnections(userID.String()) {
+				clients[clientID] = client
+			}
+		}
+		n.backpressure.queued(clients, channel)
+	}
+
+	n.queueOffline(participantIDs, eventType, payloads[0])
+}
+
+// SubscribeConversation subscribes the connected devices of users who just joined a
+// conversation, so they don't miss events published before their client subscribes.
+// Each device joins the channel of the event version it negotiated.
+func (n *Node) SubscribeConversation(conversationID uuid.UUID, userIDs []uuid.UUID) {
+	channel := ConversationChannel(conversationID)
+	for _, userID := range userIDs {
+		if !n.IsOnline(userID) {
+			continue
+		}
+		for clientID, client := range n.node.Hub().UserConnections(userID.String()) {
+			versioned := versionedChannel(channel, clientVersion(client))
+			if err := n.node.Subscribe(userID.String(), versioned, centrifuge.WithSubscribeClient(clientID)); err != nil {
+				log.Printf("Failed to subscribe user %s to conversation %s: %v", userID, conversationID, err)
+			}
+		}
+	}
+}
+
+// RevokeConversation removes a user who left a conversation from its event channels
+func (n *Node) RevokeConversation(conversationID, userID uuid.UUID) {
+	channel := ConversationChannel(conversationID)
+	for version := apiversion.Oldest; version <= apiversion.Latest; version++ {
+		if err := n.node.Unsubscribe(userID.String(), versionedChannel(channel, version)); err != nil {
+			log.Printf("Failed to revoke conversation subscription for user %s: %v", userID, err)
+		}
+	}
+}
@@ -18,3 +18,15 @@ func (n *Notifier) NotifyUser(userID uuid.UUID, eventType string, data interface
 func (n *Notifier) NotifyUsers(userIDs []uuid.UUID, eventType string, data interface{}) {
 	n.node.PublishToUsers(userIDs, eventType, data)
 }
+
+func (n *Notifier) NotifyConversation(conversationID uuid.UUID, participantIDs []uuid.UUID, eventType string, data interface{}) {
+	n.node.PublishToConversation(conversationID, participantIDs, eventType, data)
+}
+
+func (n *Notifier) NotifyCall(callID uuid.UUID, eventType string, data interface{}) error {
+	return n.node.PublishToCall(callID, eventType, data)
+}
+
+func (n *Notifier) RevokeCall(callID, userID uuid.UUID) {
+	n.node.RevokeCall(callID, userID)
+}
@@ -0,0 +1,166 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/cache"
+)
+
+// OfflineQueue keeps events for users without realtime connections on any node until
+// they connect again. It tracks connections across nodes, since a user connected to
+// another node isn't offline.
+type OfflineQueue interface {
+	Connected(userID uuid.UUID, clientID string)
+	Disconnected(userID uuid.UUID, clientID string)
+	// Refresh renews the connections still open on this node (userID -> clientIDs)
+	Refresh(connections map[uuid.UUID][]string)
+	// Enqueue queues the event for those of the users that have no connections
+	Enqueue(userIDs []uuid.UUID, eventType string, payload []byte)
+	// Drain returns and clears all queued events for a user (oldest first)
+	Drain(ctx context.Context, userID uuid.UUID) ([][]byte, error)
+}
+
+// offlineRefreshInterval is how often connection leases are renewed, well within the lease
+const offlineRefreshInterval = cache.RealtimeConnectionLease / 3
+
+// RedisOfflineQueue stores offline events in a bounded per-user Redis list
+type RedisOfflineQueue struct {
+	cache *cache.RedisCache
+}
+
+func NewRedisOfflineQueue(cache *cache.RedisCache) *RedisOfflineQueue {
+	return &RedisOfflineQueue{cache: cache}
+}
+
+func (q *RedisOfflineQueue) Connected(userID uuid.UUID, clientID string) {
+	q.Refresh(map[uuid.UUID][]string{userID: {clientID}})
+}
+
+func (q *RedisOfflineQueue) Disconnected(userID uuid.UUID, clientID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if err := q.cache.RemoveConnection(ctx, userID.String(), clientID); err != nil {
+		log.Printf("Failed to remove connection of user %s: %v", userID, err)
+	}
+}
+
+func (q *RedisOfflineQueue) Refresh(connections map[uuid.UUID][]string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	byUser := make(map[string][]string, len(connections))
+	for userID, clientIDs := range connections {
+		byUser[userID.String()] = clientIDs
+	}
+	if err := q.cache.AddConnections(ctx, byUser); err != nil {
+		log.Printf("Failed to record realtime connections: %v", err)
+	}
+}
+
+func (q *RedisOfflineQueue) Enqueue(userIDs []uuid.UUID, eventType string, payload []byte) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	ids := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		ids[i] = userID.String()
+	}
+	connected, err := q.cache.ConnectedUsers(ctx, ids)
+	if err != nil {
+		log.Printf("Failed to check connections before queuing offline %s: %v", eventType, err)
+		return
+	}
+
+	var offline []string
+	for _, id := range ids {
+		if !connected[id] {
+			offline = append(offline, id)
+		}
+	}
+	if len(offline) == 0 {
+		return
+	}
+	if err := q.cache.PushOfflineEvent(ctx, offline, payload); err != nil {
+		log.Printf("Failed to queue offline %s for %d user(s): %v", eventType, len(offline), err)
+	}
+}
+
+func (q *RedisOfflineQueue) Drain(ctx context.Context, userID uuid.UUID) ([][]byte, error) {
+	return q.cache.PopOfflineEvents(ctx, userID.String())
+}
+
+// queueOffline hands an event to the offline queue for those of the users without
+// connections on this node. Droppable events are stale by the time anyone reconnects.
+func (n *Node) queueOffline(userIDs []uuid.UUID, eventType string, payload []byte) {
+	if n.offlineQueue == nil || droppableEvents[eventType] {
+		return
+	}
+
+	var offline []uuid.UUID
+	for _, userID := range userIDs {
+		if !n.IsOnline(userID) {
+			offline = append(offline, userID)
+		}
+	}
+	if len(offline) > 0 {
+		n.offlineQueue.Enqueue(offline, eventType, payload)
+	}
+}
+
+// replayOffline delivers the events queued while the user had no connections. They were
+// queued in the oldest shape and are re-encoded for every version.
+func (n *Node) replayOffline(userID uuid.UUID) {
+	if n.offlineQueue == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	payloads, err := n.offlineQueue.Drain(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to drain offline events for user %s: %v", userID, err)
+		return
+	}
+	for _, payload := range payloads {
+		var event struct {
+			Type string          `json:"type"`
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(payload, &event); err != nil {
+			log.Printf("Skipping malformed offline event for user %s: %v", userID, err)
+			continue
+		}
+		if err := n.PublishToUser(userID, event.Type, event.Data); err != nil {
+			log.Printf("Failed to replay offline %s to user %s: %v", event.Type, userID, err)
+		}
+	}
+}
+
+// refreshConnections renews the leases of this node's connections until the node shuts down
+func (n *Node) refreshConnections() {
+	ticker := time.NewTicker(offlineRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.done:
+			return
+		case <-ticker.C:
+			connections := make(map[uuid.UUID][]string)
+			for clientID, client := range n.node.Hub().Connections() {
+				if userID, err := uuid.Parse(client.UserID()); err == nil {
+					connections[userID] = append(connections[userID], clientID)
+				}
+			}
+			if len(connections) > 0 {
+				n.offlineQueue.Refresh(connections)
+			}
+		}
+	}
+}
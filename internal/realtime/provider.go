@@ -28,6 +28,46 @@ func NewProvider(authRepo *auth.Repository, friendsRepo *friends.Repository, mes
 	}
 }
 
+// IsParticipant reports whether the user is a member of the conversation. Recipients of
+// a message request only count once they accept it.
+func (p *Provider) IsParticipant(ctx context.Context, conversationID, userID uuid.UUID) (bool, error) {
+	ok, err := p.messagesRepo.IsParticipant(ctx, conversationID, userID)
+	if err != nil || !ok {
+		return false, err
+	}
+	awaiting, err := p.messagesRepo.IsAwaitingRequest(ctx, conversationID, userID)
+	return !awaiting, err
+}
+
+// IsCallParticipant reports whether the user is currently in the call
+func (p *Provider) IsCallParticipant(ctx context.Context, callID, userID uuid.UUID) (bool, error) {
+	return p.callsRepo.IsActiveParticipant(ctx, callID, userID)
+}
+
+// MarkDeliveredOnReady acknowledges the latest DM messages and resolves who to notify
+func (p *Provider) MarkDeliveredOnReady(ctx context.Context, userID uuid.UUID) ([]DeliveryNotice, error) {
+	events, err := p.messagesRepo.MarkDeliveredOnReady(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	notices := make([]DeliveryNotice, 0, len(events))
+	for _, e := range events {
+		participantIDs, err := p.messagesRepo.GetParticipantIDs(ctx, e.ConversationID)
+		if err != nil {
+			return nil, err
+		}
+		var recipients []uuid.UUID
+		for _, id := range participantIDs {
+			if id != userID {
+				recipients = append(recipients, id)
+			}
+		}
+		notices = append(notices, DeliveryNotice{Event: e, Recipients: recipients})
+	}
+	return notices, nil
+}
+
 func (p *Provider) GetReadyState(ctx context.Context, userID uuid.UUID) (*models.ReadyEvent, error) {
 	// Load all data in parallel
 	type result struct {
@@ -36,7 +76,10 @@ func (p *Provider) GetReadyState(ctx context.Context, userID uuid.UUID) (*models
 		incoming      []*models.FriendRequestWithUser
 		outgoing      []*models.FriendRequestWithUser
 		conversations []*models.ConversationWithDetails
+		requests      []*models.MessageRequest
 		activeCalls   []*models.ActiveCallInfo
+		drafts        []*models.Draft
+		missedCalls   int
 		err           error
 	}
 
@@ -76,6 +119,12 @@ func (p *Provider) GetReadyState(ctx context.Context, userID uuid.UUID) (*models
 			r.conversations = []*models.ConversationWithDetails{}
 		}
 
+		// Get message requests from non-friends
+		r.requests, _ = p.messagesRepo.GetMessageRequests(ctx, userID)
+		if r.requests == nil {
+			r.requests = []*models.MessageRequest{}
+		}
+
 		// Get active calls for user's conversations
 		if len(r.conversations) > 0 {
 			conversationIDs := make([]uuid.UUID, len(r.conversations))
@@ -101,6 +150,15 @@ func (p *Provider) GetReadyState(ctx context.Context, userID uuid.UUID) (*models
 			r.activeCalls = []*models.ActiveCallInfo{}
 		}
 
+		// Get drafts
+		r.drafts, _ = p.messagesRepo.GetUserDrafts(ctx, userID)
+		if r.drafts == nil {
+			r.drafts = []*models.Draft{}
+		}
+
+		// Get missed-calls badge
+		r.missedCalls, _ = p.callsRepo.GetMissedCallCount(ctx, userID)
+
 		ch <- r
 	}()
 
@@ -115,6 +173,9 @@ func (p *Provider) GetReadyState(ctx context.Context, userID uuid.UUID) (*models
 		IncomingRequests: r.incoming,
 		OutgoingRequests: r.outgoing,
 		Conversations:    r.conversations,
+		MessageRequests:  r.requests,
 		ActiveCalls:      r.activeCalls,
+		Drafts:           r.drafts,
+		MissedCalls:      r.missedCalls,
 	}, nil
 }
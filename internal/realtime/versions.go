@@ -0,0 +1,103 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/user/bla-back/internal/apiversion"
+)
+
+// User and conversation channels exist once per event version. Version 1 keeps the
+// original names; later versions add a suffix ("user:<id>@v2"), so a client subscribes
+// to the channels of the version it negotiated on connect and publications are encoded
+// once per version instead of once per connection.
+const channelVersionSep = "@v"
+
+// versionedChannel returns the name of a channel for an event version
+func versionedChannel(channel string, version int) string {
+	if version == apiversion.Oldest {
+		return channel
+	}
+	return channel + channelVersionSep + strconv.Itoa(version)
+}
+
+// splitChannelVersion returns the base channel name and the event version it carries
+func splitChannelVersion(channel string) (string, int, bool) {
+	base, suffix, found := strings.Cut(channel, channelVersionSep)
+	if !found {
+		return channel, apiversion.Oldest, true
+	}
+	version, err := strconv.Atoi(suffix)
+	if err != nil || version == apiversion.Oldest || !apiversion.Supported(version) {
+		return "", 0, false
+	}
+	return base, version, true
+}
+
+// connectVersion picks the event version of a connection: the connect data
+// ({"version": N}) wins over the version negotiated for the WebSocket request
+func connectVersion(ctx context.Context, data []byte) (int, error) {
+	version := apiversion.FromContext(ctx)
+	if len(data) == 0 {
+		return version, nil
+	}
+
+	var req struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return 0, err
+	}
+	if req.Version == 0 {
+		return version, nil
+	}
+	if !apiversion.Supported(req.Version) {
+		return 0, apiversion.ErrUnsupported
+	}
+	return req.Version, nil
+}
+
+// clientVersion returns the event version a connection negotiated
+func clientVersion(client *centrifuge.Client) int {
+	return apiversion.FromContext(client.Context())
+}
+
+// encodeEvent builds the event envelope for every supported version, indexed from Oldest
+func encodeEvent(eventType string, data interface{}) ([][]byte, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	payloads := make([][]byte, 0, apiversion.Latest-apiversion.Oldest+1)
+	for version := apiversion.Oldest; version <= apiversion.Latest; version++ {
+		versioned, err := apiversion.Transform(raw, version)
+		if err != nil {
+			return nil, err
+		}
+		payload, err := json.Marshal(map[string]interface{}{
+			"type": eventType,
+			"v":    version,
+			"data": json.RawMessage(versioned),
+		})
+		if err != nil {
+			return nil, err
+		}
+		payloads = append(payloads, payload)
+	}
+	return payloads, nil
+}
+
+// publishVersions publishes each payload from encodeEvent on the channel of its version
+func (n *Node) publishVersions(channel string, payloads [][]byte) error {
+	var firstErr error
+	for i, payload := range payloads {
+		if _, err := n.node.Publish(versionedChannel(channel, apiversion.Oldest+i), payload); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
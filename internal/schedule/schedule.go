@@ -0,0 +1,74 @@
+// Package schedule evaluates user-facing schedules (quiet hours, "9am my time") in the
+// user's own time zone, so every scheduling feature agrees on what local time means.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidClock = errors.New("time of day must be HH:MM")
+
+// Zone resolves an IANA time zone name, falling back to UTC for empty or unknown names
+func Zone(name string) *time.Location {
+	if name == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// ValidZone reports whether name is a known IANA time zone
+func ValidZone(name string) bool {
+	if name == "" || name == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
+
+// Clock is a local time of day, in minutes since midnight
+type Clock int
+
+// ParseClock parses "HH:MM" (24-hour)
+func ParseClock(s string) (Clock, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, ErrInvalidClock
+	}
+	return Clock(t.Hour()*60 + t.Minute()), nil
+}
+
+func (c Clock) String() string {
+	return fmt.Sprintf("%02d:%02d", int(c)/60, int(c)%60)
+}
+
+// on returns the instant the clock reads c on the given local day
+func (c Clock) on(year int, month time.Month, day int, loc *time.Location) time.Time {
+	return time.Date(year, month, day, int(c)/60, int(c)%60, 0, 0, loc)
+}
+
+// NextAt returns the first time after now when the clock in loc reads c
+func NextAt(now time.Time, loc *time.Location, c Clock) time.Time {
+	local := now.In(loc)
+	next := c.on(local.Year(), local.Month(), local.Day(), loc)
+	if !next.After(now) {
+		next = c.on(local.Year(), local.Month(), local.Day()+1, loc)
+	}
+	return next
+}
+
+// InWindow reports whether now is within [start, end) local time in loc. Windows that
+// wrap midnight (e.g. 22:00–07:00) are supported; start == end is an empty window.
+func InWindow(now time.Time, loc *time.Location, start, end Clock) bool {
+	local := now.In(loc)
+	c := Clock(local.Hour()*60 + local.Minute())
+	if start <= end {
+		return c >= start && c < end
+	}
+	return c >= start || c < end
+}
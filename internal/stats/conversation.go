@@ -0,0 +1,151 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/user/bla-back/internal/models"
+)
+
+const (
+	peakHoursLimit    = 3
+	topReactionsLimit = 10
+)
+
+var (
+	ErrConversationNotFound = errors.New("conversation not found")
+	ErrNotGroupOwner        = errors.New("only the group owner can view conversation stats")
+)
+
+// GetConversationStats returns a group's activity between from and to (inclusive UTC days).
+// Only the group owner may see it.
+func (r *Repository) GetConversationStats(ctx context.Context, convID, userID uuid.UUID, from, to time.Time) (*models.ConversationStats, error) {
+	var ownerID *uuid.UUID
+	err := r.db.QueryRow(ctx, `
+		SELECT owner_id FROM conversations WHERE id = $1 AND type = 'group'
+	`, convID).Scan(&ownerID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrConversationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ownerID == nil || *ownerID != userID {
+		return nil, ErrNotGroupOwner
+	}
+
+	stats := &models.ConversationStats{
+		ConversationID: convID,
+		From:           from.Format(time.DateOnly),
+		To:             to.Format(time.DateOnly),
+	}
+
+	err = r.db.QueryRow(ctx, `SELECT aggregated_through FROM stats_aggregation_state WHERE id = 1`).Scan(&stats.ComputedThrough)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	// Current members, including those who haven't said anything
+	rows, err := r.db.Query(ctx, `
+		SELECT u.id, u.username, COALESCE(SUM(a.messages_sent), 0) AS messages, COALESCE(SUM(a.call_seconds), 0)
+		FROM conversation_participants cp
+		JOIN users u ON u.id = cp.user_id
+		LEFT JOIN user_activity_daily a
+			ON a.user_id = cp.user_id AND a.conversation_id = cp.conversation_id AND a.day >= $2 AND a.day <= $3
+		WHERE cp.conversation_id = $1
+		GROUP BY u.id, u.username
+		ORDER BY messages DESC, u.id
+	`, convID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats.Members = []models.MemberActivity{}
+	for rows.Next() {
+		var m models.MemberActivity
+		var messages, seconds int64
+		if err := rows.Scan(&m.UserID, &m.Username, &messages, &seconds); err != nil {
+			return nil, err
+		}
+		m.Messages = int(messages)
+		m.CallMinutes = int(seconds / 60)
+		stats.Members = append(stats.Members, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Totals include members who have since left
+	rows, err = r.db.Query(ctx, `
+		SELECT hour, SUM(messages)
+		FROM conversation_activity_hourly
+		WHERE conversation_id = $1 AND day >= $2 AND day <= $3
+		GROUP BY hour
+	`, convID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hour int16
+		var messages int64
+		if err := rows.Scan(&hour, &messages); err != nil {
+			return nil, err
+		}
+		if hour >= 0 && hour < 24 {
+			stats.MessagesByHour[hour] = int(messages)
+			stats.TotalMessages += int(messages)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	stats.PeakHours = peakHours(stats.MessagesByHour, peakHoursLimit)
+
+	rows, err = r.db.Query(ctx, `
+		SELECT emoji, SUM(count) AS total
+		FROM conversation_reactions_daily
+		WHERE conversation_id = $1 AND day >= $2 AND day <= $3
+		GROUP BY emoji
+		ORDER BY total DESC, emoji
+		LIMIT $4
+	`, convID, from, to, topReactionsLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats.TopReactions = []models.ReactionCount{}
+	for rows.Next() {
+		var rc models.ReactionCount
+		var count int64
+		if err := rows.Scan(&rc.Emoji, &count); err != nil {
+			return nil, err
+		}
+		rc.Count = int(count)
+		stats.TopReactions = append(stats.TopReactions, rc)
+	}
+
+	return stats, rows.Err()
+}
+
+// peakHours returns up to limit hours with messages, busiest first
+func peakHours(byHour [24]int, limit int) []int {
+	hours := make([]int, 0, 24)
+	for h, n := range byHour {
+		if n > 0 {
+			hours = append(hours, h)
+		}
+	}
+	sort.SliceStable(hours, func(i, j int) bool { return byHour[hours[i]] > byHour[hours[j]] })
+	if len(hours) > limit {
+		hours = hours[:limit]
+	}
+	return hours
+}
@@ -0,0 +1,202 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/user/bla-back/internal/models"
+)
+
+const topConversationsLimit = 5
+
+type Repository struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{db: db}
+}
+
+// AggregateDay recomputes user_activity_daily for one UTC day from messages and call participation
+func (r *Repository) AggregateDay(ctx context.Context, day time.Time) error {
+	start := day.UTC().Truncate(24 * time.Hour)
+	end := start.Add(24 * time.Hour)
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, table := range []string{"user_activity_daily", "conversation_activity_hourly", "conversation_reactions_daily"} {
+		if _, err := tx.Exec(ctx, `DELETE FROM `+table+` WHERE day = $1`, start); err != nil {
+			return err
+		}
+	}
+
+	// Call log and membership entries are generated by the server, not sent by the user
+	_, err = tx.Exec(ctx, `
+		INSERT INTO user_activity_daily (user_id, day, conversation_id, messages_sent)
+		SELECT sender_id, $1, conversation_id, COUNT(*)
+		FROM messages
+		WHERE created_at >= $1 AND created_at < $2 AND COALESCE(type, 'text') NOT IN ('call', 'call_transcript', 'call_reminder', 'membership', 'group_update')
+		GROUP BY sender_id, conversation_id
+	`, start, end)
+	if err != nil {
+		return err
+	}
+
+	// Calls count toward the day the user joined
+	_, err = tx.Exec(ctx, `
+		INSERT INTO user_activity_daily (user_id, day, conversation_id, call_seconds)
+		SELECT cp.user_id, $1, c.conversation_id, SUM(EXTRACT(EPOCH FROM cp.left_at - cp.joined_at))::INT
+		FROM call_participants cp
+		JOIN calls c ON c.id = cp.call_id
+		WHERE cp.joined_at >= $1 AND cp.joined_at < $2 AND cp.left_at IS NOT NULL
+		GROUP BY cp.user_id, c.conversation_id
+		ON CONFLICT (user_id, day, conversation_id) DO UPDATE SET call_seconds = EXCLUDED.call_seconds
+	`, start, end)
+	if err != nil {
+		return err
+	}
+
+	// Per-conversation aggregates for owner stats: activity by UTC hour and reactions used
+	_, err = tx.Exec(ctx, `
+		INSERT INTO conversation_activity_hourly (conversation_id, day, hour, messages)
+		SELECT conversation_id, $1, EXTRACT(HOUR FROM created_at AT TIME ZONE 'UTC')::SMALLINT, COUNT(*)
+		FROM messages
+		WHERE created_at >= $1 AND created_at < $2 AND COALESCE(type, 'text') NOT IN ('call', 'call_transcript', 'call_reminder', 'membership', 'group_update')
+		GROUP BY 1, 3
+	`, start, end)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO conversation_reactions_daily (conversation_id, day, emoji, count)
+		SELECT m.conversation_id, $1, r.emoji, COUNT(*)
+		FROM reactions r
+		JOIN messages m ON m.id = r.message_id
+		WHERE r.created_at >= $1 AND r.created_at < $2
+		GROUP BY m.conversation_id, r.emoji
+	`, start, end)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO stats_aggregation_state (id, aggregated_through, updated_at)
+		VALUES (1, $1, NOW())
+		ON CONFLICT (id) DO UPDATE SET
+			aggregated_through = GREATEST(stats_aggregation_state.aggregated_through, EXCLUDED.aggregated_through),
+			updated_at = NOW()
+	`, start)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ResumeDay returns the first day the next aggregation pass should recompute. The last
+// aggregated day is redone to pick up calls that ended after midnight. ok is false when
+// there is no activity to aggregate yet
+func (r *Repository) ResumeDay(ctx context.Context) (time.Time, bool, error) {
+	var day time.Time
+	err := r.db.QueryRow(ctx, `SELECT aggregated_through FROM stats_aggregation_state WHERE id = 1`).Scan(&day)
+	if err == nil {
+		return day, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return time.Time{}, false, err
+	}
+
+	var first *time.Time
+	if err := r.db.QueryRow(ctx, `SELECT MIN(created_at) FROM messages`).Scan(&first); err != nil {
+		return time.Time{}, false, err
+	}
+	if first == nil {
+		return time.Time{}, false, nil
+	}
+	return first.UTC().Truncate(24 * time.Hour), true, nil
+}
+
+// GetUserStats returns a user's activity between from and to (inclusive UTC days)
+func (r *Repository) GetUserStats(ctx context.Context, userID uuid.UUID, from, to time.Time) (*models.AccountStats, error) {
+	stats := &models.AccountStats{
+		From: from.Format(time.DateOnly),
+		To:   to.Format(time.DateOnly),
+	}
+
+	err := r.db.QueryRow(ctx, `SELECT aggregated_through FROM stats_aggregation_state WHERE id = 1`).Scan(&stats.ComputedThrough)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT day, SUM(messages_sent), SUM(call_seconds)
+		FROM user_activity_daily
+		WHERE user_id = $1 AND day >= $2 AND day <= $3
+		GROUP BY day
+		ORDER BY day
+	`, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats.Days = []models.DailyActivity{}
+	var callSeconds int64
+	for rows.Next() {
+		var day time.Time
+		var messages, seconds int64
+		if err := rows.Scan(&day, &messages, &seconds); err != nil {
+			return nil, err
+		}
+		stats.Days = append(stats.Days, models.DailyActivity{
+			Date:        day.Format(time.DateOnly),
+			Messages:    int(messages),
+			CallMinutes: int(seconds / 60),
+		})
+		stats.TotalMessages += int(messages)
+		callSeconds += seconds
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	stats.TotalCallMinutes = int(callSeconds / 60)
+
+	// Only conversations the user is still in are listed, so left groups aren't exposed
+	rows, err = r.db.Query(ctx, `
+		SELECT a.conversation_id, c.type, c.name, SUM(a.messages_sent) AS messages, SUM(a.call_seconds)
+		FROM user_activity_daily a
+		JOIN conversations c ON c.id = a.conversation_id
+		WHERE a.user_id = $1 AND a.day >= $2 AND a.day <= $3
+		  AND EXISTS(SELECT 1 FROM conversation_participants WHERE conversation_id = a.conversation_id AND user_id = $1)
+		GROUP BY a.conversation_id, c.type, c.name
+		ORDER BY messages DESC, a.conversation_id
+		LIMIT $4
+	`, userID, from, to, topConversationsLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats.TopConversations = []models.ConversationActivity{}
+	for rows.Next() {
+		var c models.ConversationActivity
+		var messages, seconds int64
+		if err := rows.Scan(&c.ConversationID, &c.Type, &c.Name, &messages, &seconds); err != nil {
+			return nil, err
+		}
+		c.Messages = int(messages)
+		c.CallMinutes = int(seconds / 60)
+		stats.TopConversations = append(stats.TopConversations, c)
+	}
+
+	return stats, rows.Err()
+}
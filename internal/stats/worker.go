@@ -0,0 +1,75 @@
+package stats
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// aggregatorLock keeps a single instance aggregating at a time
+const aggregatorLock = 7342002
+
+// Aggregator periodically rolls messages and calls up into per-user daily activity
+type Aggregator struct {
+	db       *pgxpool.Pool
+	repo     *Repository
+	interval time.Duration
+}
+
+func NewAggregator(db *pgxpool.Pool, repo *Repository, interval time.Duration) *Aggregator {
+	return &Aggregator{
+		db:       db,
+		repo:     repo,
+		interval: interval,
+	}
+}
+
+// Run aggregates once at startup and then every interval until ctx is cancelled
+func (a *Aggregator) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := a.aggregate(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to aggregate activity stats: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *Aggregator) aggregate(ctx context.Context) error {
+	conn, err := a.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	var locked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, aggregatorLock).Scan(&locked); err != nil {
+		return err
+	}
+	if !locked {
+		return nil
+	}
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, aggregatorLock)
+
+	day, ok, err := a.repo.ResumeDay(ctx)
+	if err != nil || !ok {
+		return err
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	for ; !day.After(today); day = day.Add(24 * time.Hour) {
+		if err := a.repo.AggregateDay(ctx, day); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -27,7 +27,7 @@ func NewRepository(db *pgxpool.Pool) *Repository {
 // GetAllPacks returns all available sticker packs (official + user's saved)
 func (r *Repository) GetAllPacks(ctx context.Context, userID uuid.UUID) ([]*models.StickerPack, error) {
 	rows, err := r.db.Query(ctx, `
-		SELECT DISTINCT sp.id, sp.name, sp.description, sp.cover_url, sp.is_official, sp.creator_id, sp.created_at, sp.updated_at
+		SELECT DISTINCT sp.id, sp.name, sp.description, sp.cover_url, sp.is_official, sp.nsfw, sp.creator_id, sp.created_at, sp.updated_at
 		FROM sticker_packs sp
 		LEFT JOIN user_sticker_packs usp ON sp.id = usp.pack_id AND usp.user_id = $1
 		WHERE sp.is_official = true OR usp.user_id IS NOT NULL
@@ -41,7 +41,7 @@ func (r *Repository) GetAllPacks(ctx context.Context, userID uuid.UUID) ([]*mode
 	var packs []*models.StickerPack
 	for rows.Next() {
 		pack := &models.StickerPack{}
-		err := rows.Scan(&pack.ID, &pack.Name, &pack.Description, &pack.CoverURL, &pack.IsOfficial, &pack.CreatorID, &pack.CreatedAt, &pack.UpdatedAt)
+		err := rows.Scan(&pack.ID, &pack.Name, &pack.Description, &pack.CoverURL, &pack.IsOfficial, &pack.NSFW, &pack.CreatorID, &pack.CreatedAt, &pack.UpdatedAt)
 		if err != nil {
 			continue
 		}
@@ -55,9 +55,9 @@ func (r *Repository) GetAllPacks(ctx context.Context, userID uuid.UUID) ([]*mode
 func (r *Repository) GetPack(ctx context.Context, packID uuid.UUID) (*models.StickerPack, error) {
 	pack := &models.StickerPack{}
 	err := r.db.QueryRow(ctx, `
-		SELECT id, name, description, cover_url, is_official, creator_id, created_at, updated_at
+		SELECT id, name, description, cover_url, is_official, nsfw, creator_id, created_at, updated_at
 		FROM sticker_packs WHERE id = $1
-	`, packID).Scan(&pack.ID, &pack.Name, &pack.Description, &pack.CoverURL, &pack.IsOfficial, &pack.CreatorID, &pack.CreatedAt, &pack.UpdatedAt)
+	`, packID).Scan(&pack.ID, &pack.Name, &pack.Description, &pack.CoverURL, &pack.IsOfficial, &pack.NSFW, &pack.CreatorID, &pack.CreatedAt, &pack.UpdatedAt)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrPackNotFound
@@ -90,7 +90,7 @@ func (r *Repository) GetPack(ctx context.Context, packID uuid.UUID) (*models.Sti
 // GetOfficialPacks returns all official sticker packs
 func (r *Repository) GetOfficialPacks(ctx context.Context) ([]*models.StickerPack, error) {
 	rows, err := r.db.Query(ctx, `
-		SELECT id, name, description, cover_url, is_official, creator_id, created_at, updated_at
+		SELECT id, name, description, cover_url, is_official, nsfw, creator_id, created_at, updated_at
 		FROM sticker_packs WHERE is_official = true ORDER BY created_at
 	`)
 	if err != nil {
@@ -101,7 +101,7 @@ func (r *Repository) GetOfficialPacks(ctx context.Context) ([]*models.StickerPac
 	var packs []*models.StickerPack
 	for rows.Next() {
 		pack := &models.StickerPack{}
-		err := rows.Scan(&pack.ID, &pack.Name, &pack.Description, &pack.CoverURL, &pack.IsOfficial, &pack.CreatorID, &pack.CreatedAt, &pack.UpdatedAt)
+		err := rows.Scan(&pack.ID, &pack.Name, &pack.Description, &pack.CoverURL, &pack.IsOfficial, &pack.NSFW, &pack.CreatorID, &pack.CreatedAt, &pack.UpdatedAt)
 		if err != nil {
 			continue
 		}
@@ -112,14 +112,14 @@ func (r *Repository) GetOfficialPacks(ctx context.Context) ([]*models.StickerPac
 }
 
 // CreatePack creates a new sticker pack
-func (r *Repository) CreatePack(ctx context.Context, creatorID uuid.UUID, name, description string, isOfficial bool) (*models.StickerPack, error) {
+func (r *Repository) CreatePack(ctx context.Context, creatorID uuid.UUID, name, description string, isOfficial, nsfw bool) (*models.StickerPack, error) {
 	pack := &models.StickerPack{}
 	err := r.db.QueryRow(ctx, `
-		INSERT INTO sticker_packs (name, description, is_official, creator_id)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, name, description, cover_url, is_official, creator_id, created_at, updated_at
-	`, name, description, isOfficial, creatorID).Scan(
-		&pack.ID, &pack.Name, &pack.Description, &pack.CoverURL, &pack.IsOfficial, &pack.CreatorID, &pack.CreatedAt, &pack.UpdatedAt,
+		INSERT INTO sticker_packs (name, description, is_official, nsfw, creator_id)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, name, description, cover_url, is_official, nsfw, creator_id, created_at, updated_at
+	`, name, description, isOfficial, nsfw, creatorID).Scan(
+		&pack.ID, &pack.Name, &pack.Description, &pack.CoverURL, &pack.IsOfficial, &pack.NSFW, &pack.CreatorID, &pack.CreatedAt, &pack.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -174,7 +174,7 @@ func (r *Repository) RemovePackFromUser(ctx context.Context, userID, packID uuid
 // GetUserPacks returns user's saved sticker packs with stickers
 func (r *Repository) GetUserPacks(ctx context.Context, userID uuid.UUID) ([]*models.StickerPack, error) {
 	rows, err := r.db.Query(ctx, `
-		SELECT sp.id, sp.name, sp.description, sp.cover_url, sp.is_official, sp.creator_id, sp.created_at, sp.updated_at
+		SELECT sp.id, sp.name, sp.description, sp.cover_url, sp.is_official, sp.nsfw, sp.creator_id, sp.created_at, sp.updated_at
 		FROM sticker_packs sp
 		JOIN user_sticker_packs usp ON sp.id = usp.pack_id
 		WHERE usp.user_id = $1
@@ -188,7 +188,7 @@ func (r *Repository) GetUserPacks(ctx context.Context, userID uuid.UUID) ([]*mod
 	var packs []*models.StickerPack
 	for rows.Next() {
 		pack := &models.StickerPack{}
-		err := rows.Scan(&pack.ID, &pack.Name, &pack.Description, &pack.CoverURL, &pack.IsOfficial, &pack.CreatorID, &pack.CreatedAt, &pack.UpdatedAt)
+		err := rows.Scan(&pack.ID, &pack.Name, &pack.Description, &pack.CoverURL, &pack.IsOfficial, &pack.NSFW, &pack.CreatorID, &pack.CreatedAt, &pack.UpdatedAt)
 		if err != nil {
 			continue
 		}
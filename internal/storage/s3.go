@@ -1,7 +1,9 @@
 package storage
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"path"
@@ -15,6 +17,9 @@ import (
 	"github.com/google/uuid"
 )
 
+// ErrObjectNotFound is returned when a stored URL has no object behind it
+var ErrObjectNotFound = errors.New("object not found")
+
 type S3Storage struct {
 	client   *s3.Client
 	bucket   string
@@ -69,9 +74,12 @@ func (s *S3Storage) Upload(ctx context.Context, folder string, filename string,
 		return "", fmt.Errorf("failed to upload file: %w", err)
 	}
 
-	// Return public URL
-	publicURL := fmt.Sprintf("%s/%s", strings.TrimSuffix(s.cdnURL, "/"), uniqueName)
-	return publicURL, nil
+	return s.publicURL(uniqueName), nil
+}
+
+// publicURL returns the URL a stored object is served from
+func (s *S3Storage) publicURL(key string) string {
+	return fmt.Sprintf("%s/%s", strings.TrimSuffix(s.cdnURL, "/"), key)
 }
 
 // UploadAvatar uploads an avatar image
@@ -85,13 +93,24 @@ func (s *S3Storage) UploadAvatar(ctx context.Context, userID uuid.UUID, filename
 	return s.Upload(ctx, folder, filename, contentType, reader)
 }
 
+// keyFromURL extracts the object key from a public URL; ok is false for URLs outside the bucket
+func (s *S3Storage) keyFromURL(fileURL string) (key string, ok bool) {
+	for _, prefix := range []string{
+		strings.TrimSuffix(s.cdnURL, "/") + "/",
+		fmt.Sprintf("%s/%s/", s.endpoint, s.bucket),
+	} {
+		if key := strings.TrimPrefix(fileURL, prefix); key != fileURL {
+			return key, true
+		}
+	}
+	return "", false
+}
+
 // Delete deletes a file by its URL
 func (s *S3Storage) Delete(ctx context.Context, fileURL string) error {
-	// Extract key from URL
-	key := strings.TrimPrefix(fileURL, s.cdnURL+"/")
-	if key == fileURL {
-		// Try alternative format
-		key = strings.TrimPrefix(fileURL, fmt.Sprintf("%s/%s/", s.endpoint, s.bucket))
+	key, ok := s.keyFromURL(fileURL)
+	if !ok {
+		key = fileURL
 	}
 
 	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
@@ -101,6 +120,74 @@ func (s *S3Storage) Delete(ctx context.Context, fileURL string) error {
 	return err
 }
 
+// Owns reports whether a URL points into this bucket (GIFs and legacy avatars may not)
+func (s *S3Storage) Owns(fileURL string) bool {
+	_, ok := s.keyFromURL(fileURL)
+	return ok
+}
+
+// Exists checks that the object behind a public URL is still in the bucket
+func (s *S3Storage) Exists(ctx context.Context, fileURL string) (bool, error) {
+	key, ok := s.keyFromURL(fileURL)
+	if !ok {
+		return false, fmt.Errorf("url is outside the bucket: %s", fileURL)
+	}
+
+	_, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Copy copies the object behind fileURL into dst under the same key and returns its URL there.
+// Returns ErrObjectNotFound if the object is missing from this bucket.
+func (s *S3Storage) Copy(ctx context.Context, dst *S3Storage, fileURL string) (string, error) {
+	key, ok := s.keyFromURL(fileURL)
+	if !ok {
+		return "", fmt.Errorf("url is outside the bucket: %s", fileURL)
+	}
+
+	obj, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return "", ErrObjectNotFound
+		}
+		return "", fmt.Errorf("failed to get object: %w", err)
+	}
+	defer obj.Body.Close()
+
+	// Buffered so the upload body is seekable and can be signed and retried
+	data, err := io.ReadAll(obj.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read object: %w", err)
+	}
+
+	_, err = dst.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(dst.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: obj.ContentType,
+		ACL:         types.ObjectCannedACLPublicRead,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload file: %w", err)
+	}
+
+	return dst.publicURL(key), nil
+}
+
 // GetPresignedURL generates a presigned URL for direct upload (optional, for client-side uploads)
 func (s *S3Storage) GetPresignedURL(ctx context.Context, key string, contentType string, expiresIn time.Duration) (string, error) {
 	presignClient := s3.NewPresignClient(s.client)
@@ -0,0 +1,202 @@
+package syncfeed
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/user/bla-back/internal/models"
+	"github.com/user/bla-back/internal/outbound"
+)
+
+// delivererLock keeps a single instance pushing batches, so each endpoint sees one
+// batch at a time and in order
+const delivererLock = 7342005
+
+const (
+	batchSize = 100
+	// batchesPerRun lets an endpoint that fell behind catch up without starving the others
+	batchesPerRun = 10
+	settleDelay   = 2 * time.Second
+	maxBackoff    = time.Hour
+)
+
+// Signature headers: X-Bla-Signature is "sha256=" + hex HMAC-SHA256, keyed with the
+// endpoint's secret, of X-Bla-Timestamp + "." + body
+const (
+	signatureHeader = "X-Bla-Signature"
+	timestampHeader = "X-Bla-Timestamp"
+)
+
+// Deliverer pushes sync feeds to their endpoints
+type Deliverer struct {
+	db       *pgxpool.Pool
+	repo     *Repository
+	client   *http.Client
+	interval time.Duration
+}
+
+func NewDeliverer(db *pgxpool.Pool, repo *Repository, policy *outbound.Policy, interval time.Duration) *Deliverer {
+	return &Deliverer{
+		db:       db,
+		repo:     repo,
+		client:   policy.Client(outbound.Limits{Timeout: 15 * time.Second, MaxBodyBytes: 4 << 10}),
+		interval: interval,
+	}
+}
+
+// Run delivers pending events every interval until ctx is cancelled
+func (d *Deliverer) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.deliver(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Failed to deliver sync events: %v", err)
+			}
+		}
+	}
+}
+
+func (d *Deliverer) deliver(ctx context.Context) error {
+	conn, err := d.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	var locked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, delivererLock).Scan(&locked); err != nil {
+		return err
+	}
+	if !locked {
+		return nil
+	}
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, delivererLock)
+
+	endpoints, err := d.repo.dueEndpoints(ctx)
+	if err != nil {
+		return err
+	}
+	for _, e := range endpoints {
+		if err := d.deliverEndpoint(ctx, e); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			e.failures++
+			if err := d.repo.fail(ctx, e.id, err.Error(), time.Now().Add(backoff(d.interval, e.failures))); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := d.repo.prune(ctx); err != nil {
+		return err
+	}
+	return nil
+}
+
+// deliverEndpoint sends batches until the endpoint is caught up
+func (d *Deliverer) deliverEndpoint(ctx context.Context, e *endpointState) error {
+	for range batchesPerRun {
+		events, err := d.repo.pendingEvents(ctx, e, batchSize, settleDelay)
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		ack, err := d.send(ctx, e, events)
+		if err != nil {
+			return err
+		}
+		if ack == e.ackedSeq {
+			return errors.New("endpoint acknowledged none of the batch")
+		}
+		if err := d.repo.acknowledge(ctx, e.id, ack); err != nil {
+			return err
+		}
+		if ack < events[len(events)-1].Seq {
+			// Partially acknowledged: resend the rest on the next run
+			return nil
+		}
+		e.ackedSeq = ack
+	}
+	return nil
+}
+
+// send posts a batch and returns the sequence number the endpoint acknowledged
+func (d *Deliverer) send(ctx context.Context, e *endpointState, events []*models.SyncEvent) (int64, error) {
+	body, err := json.Marshal(&models.SyncBatch{
+		EndpointID:     e.id,
+		ConversationID: e.convID,
+		Events:         events,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(timestampHeader, timestamp)
+	req.Header.Set(signatureHeader, "sha256="+sign(e.secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return 0, fmt.Errorf("endpoint returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Ack *int64 `json:"ack"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil || result.Ack == nil {
+		return 0, errors.New(`endpoint did not acknowledge the batch with {"ack": seq}`)
+	}
+	if *result.Ack < e.ackedSeq || *result.Ack > events[len(events)-1].Seq {
+		return 0, fmt.Errorf("endpoint acknowledged seq %d outside the batch (%d, %d]",
+			*result.Ack, e.ackedSeq, events[len(events)-1].Seq)
+	}
+	return *result.Ack, nil
+}
+
+// sign computes the batch signature
+func sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoff doubles the retry delay with each consecutive failure, up to maxBackoff
+func backoff(interval time.Duration, failures int) time.Duration {
+	delay := interval
+	for i := 1; i < failures && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
@@ -0,0 +1,238 @@
+// Package syncfeed lets external read models (support desks, CRMs) mirror a conversation.
+// Events are recorded per conversation while it has sync endpoints, and the Deliverer
+// pushes them to each endpoint in order until the endpoint acknowledges them.
+package syncfeed
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/user/bla-back/internal/models"
+)
+
+// maxEndpoints bounds the sync endpoints of one conversation
+const maxEndpoints = 5
+
+var (
+	ErrEndpointNotFound = errors.New("sync endpoint not found")
+	ErrTooManyEndpoints = errors.New("too many sync endpoints for this conversation")
+)
+
+type Repository struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{db: db}
+}
+
+// CreateEndpoint registers a sync endpoint. It receives events recorded from now on;
+// the secret for verifying batch signatures is only returned here.
+func (r *Repository) CreateEndpoint(ctx context.Context, convID, createdBy uuid.UUID, url string) (*models.SyncEndpoint, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Serializes concurrent registrations for the conversation
+	if _, err := tx.Exec(ctx, `SELECT id FROM conversations WHERE id = $1 FOR UPDATE`, convID); err != nil {
+		return nil, err
+	}
+
+	var count int
+	if err := tx.QueryRow(ctx, `
+		SELECT COUNT(*) FROM conversation_sync_endpoints WHERE conversation_id = $1
+	`, convID).Scan(&count); err != nil {
+		return nil, err
+	}
+	if count >= maxEndpoints {
+		return nil, ErrTooManyEndpoints
+	}
+
+	e := &models.SyncEndpoint{
+		ConversationID: convID,
+		CreatedBy:      &createdBy,
+		URL:            url,
+		Secret:         hex.EncodeToString(secret),
+	}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO conversation_sync_endpoints (conversation_id, created_by, url, secret, acked_seq)
+		VALUES ($1, $2, $3, $4,
+			COALESCE((SELECT MAX(seq) FROM conversation_sync_events WHERE conversation_id = $1), 0))
+		RETURNING id, acked_seq, created_at
+	`, convID, createdBy, url, e.Secret).Scan(&e.ID, &e.AckedSeq, &e.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return e, tx.Commit(ctx)
+}
+
+// ListEndpoints returns a conversation's sync endpoints with their delivery state
+func (r *Repository) ListEndpoints(ctx context.Context, convID uuid.UUID) ([]*models.SyncEndpoint, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, conversation_id, created_by, url, acked_seq, failures, last_error, last_delivered_at, created_at
+		FROM conversation_sync_endpoints
+		WHERE conversation_id = $1
+		ORDER BY created_at
+	`, convID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	endpoints := []*models.SyncEndpoint{}
+	for rows.Next() {
+		e := &models.SyncEndpoint{}
+		if err := rows.Scan(&e.ID, &e.ConversationID, &e.CreatedBy, &e.URL, &e.AckedSeq, &e.Failures,
+			&e.LastError, &e.LastDeliveredAt, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, e)
+	}
+	return endpoints, rows.Err()
+}
+
+// DeleteEndpoint stops syncing a conversation to an endpoint
+func (r *Repository) DeleteEndpoint(ctx context.Context, convID, endpointID uuid.UUID) error {
+	result, err := r.db.Exec(ctx, `
+		DELETE FROM conversation_sync_endpoints WHERE id = $1 AND conversation_id = $2
+	`, endpointID, convID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrEndpointNotFound
+	}
+	return nil
+}
+
+// Record appends an event to the conversation's sync feed. Nothing is stored for
+// conversations without sync endpoints.
+func (r *Repository) Record(ctx context.Context, convID uuid.UUID, eventType string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(ctx, `
+		INSERT INTO conversation_sync_events (conversation_id, type, payload)
+		SELECT $1, $2, $3
+		WHERE EXISTS (SELECT 1 FROM conversation_sync_endpoints WHERE conversation_id = $1)
+	`, convID, eventType, payload)
+	return err
+}
+
+// endpointState is what the Deliverer needs to push to an endpoint
+type endpointState struct {
+	id       uuid.UUID
+	convID   uuid.UUID
+	url      string
+	secret   string
+	ackedSeq int64
+	failures int
+}
+
+// dueEndpoints returns endpoints that aren't backing off and have unacknowledged events
+func (r *Repository) dueEndpoints(ctx context.Context) ([]*endpointState, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT e.id, e.conversation_id, e.url, e.secret, e.acked_seq, e.failures
+		FROM conversation_sync_endpoints e
+		WHERE e.next_attempt_at <= NOW()
+		  AND EXISTS (
+			SELECT 1 FROM conversation_sync_events ev
+			WHERE ev.conversation_id = e.conversation_id AND ev.seq > e.acked_seq
+		  )
+		ORDER BY e.next_attempt_at
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var endpoints []*endpointState
+	for rows.Next() {
+		e := &endpointState{}
+		if err := rows.Scan(&e.id, &e.convID, &e.url, &e.secret, &e.ackedSeq, &e.failures); err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, e)
+	}
+	return endpoints, rows.Err()
+}
+
+// pendingEvents returns the next unacknowledged events for an endpoint, oldest first.
+// Events younger than settle are held back: sequence numbers are handed out before
+// commit, so a concurrent insert could still land below the newest visible one.
+func (r *Repository) pendingEvents(ctx context.Context, e *endpointState, limit int, settle time.Duration) ([]*models.SyncEvent, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT seq, type, payload, created_at
+		FROM conversation_sync_events
+		WHERE conversation_id = $1 AND seq > $2 AND created_at < NOW() - $3 * INTERVAL '1 millisecond'
+		ORDER BY seq
+		LIMIT $4
+	`, e.convID, e.ackedSeq, settle.Milliseconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*models.SyncEvent
+	for rows.Next() {
+		ev := &models.SyncEvent{}
+		if err := rows.Scan(&ev.Seq, &ev.Type, &ev.Data, &ev.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, ev)
+	}
+	return events, rows.Err()
+}
+
+// acknowledge moves an endpoint's cursor forward and clears its failure state
+func (r *Repository) acknowledge(ctx context.Context, endpointID uuid.UUID, seq int64) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE conversation_sync_endpoints
+		SET acked_seq = GREATEST(acked_seq, $2), failures = 0, last_error = NULL,
+			last_delivered_at = NOW(), next_attempt_at = NOW()
+		WHERE id = $1
+	`, endpointID, seq)
+	return err
+}
+
+// fail records a failed delivery and when to try again
+func (r *Repository) fail(ctx context.Context, endpointID uuid.UUID, reason string, retryAt time.Time) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE conversation_sync_endpoints
+		SET failures = failures + 1, last_error = $2, next_attempt_at = $3
+		WHERE id = $1
+	`, endpointID, reason, retryAt)
+	return err
+}
+
+// prune deletes events every endpoint of their conversation has acknowledged, and
+// events of conversations whose endpoints were all removed
+func (r *Repository) prune(ctx context.Context) (int64, error) {
+	result, err := r.db.Exec(ctx, `
+		DELETE FROM conversation_sync_events ev
+		WHERE ev.seq <= COALESCE((
+			SELECT MIN(e.acked_seq) FROM conversation_sync_endpoints e
+			WHERE e.conversation_id = ev.conversation_id
+		), ev.seq)
+	`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
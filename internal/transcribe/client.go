@@ -0,0 +1,182 @@
+// Package transcribe turns call audio into caption text through a speech-to-text provider.
+package transcribe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	ProviderWhisper  = "whisper"
+	ProviderDeepgram = "deepgram"
+
+	requestTimeout = 20 * time.Second
+	whisperModel   = "whisper-1"
+)
+
+var ErrDisabled = errors.New("transcription is not configured")
+
+// Client transcribes audio chunks with an OpenAI-compatible Whisper endpoint or Deepgram,
+// so the API key stays on the server
+type Client struct {
+	provider string
+	apiKey   string
+	baseURL  string
+	http     *http.Client
+}
+
+// NewClient returns a client for provider. Both providers need apiKey; baseURL points
+// Whisper at a self-hosted OpenAI-compatible server. Without a key transcription is disabled.
+func NewClient(provider, apiKey, baseURL string) *Client {
+	return &Client{
+		provider: provider,
+		apiKey:   apiKey,
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		http:     &http.Client{Timeout: requestTimeout},
+	}
+}
+
+func (c *Client) Enabled() bool {
+	switch c.provider {
+	case ProviderWhisper, ProviderDeepgram:
+		return c.apiKey != ""
+	}
+	return false
+}
+
+// Transcribe returns the text spoken in an audio chunk of the given content type.
+// Silence yields an empty string.
+func (c *Client) Transcribe(ctx context.Context, audio []byte, contentType string) (string, error) {
+	if !c.Enabled() {
+		return "", ErrDisabled
+	}
+
+	var text string
+	var err error
+	if c.provider == ProviderDeepgram {
+		text, err = c.transcribeDeepgram(ctx, audio, contentType)
+	} else {
+		text, err = c.transcribeWhisper(ctx, audio, contentType)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(text), nil
+}
+
+func (c *Client) do(req *http.Request, dest interface{}) error {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected status %d", c.provider, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(dest)
+}
+
+// OpenAI-compatible /v1/audio/transcriptions
+
+type whisperResponse struct {
+	Text string `json:"text"`
+}
+
+func (c *Client) transcribeWhisper(ctx context.Context, audio []byte, contentType string) (string, error) {
+	endpoint := "https://api.openai.com/v1/audio/transcriptions"
+	if c.baseURL != "" {
+		endpoint = c.baseURL + "/v1/audio/transcriptions"
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if err := form.WriteField("model", whisperModel); err != nil {
+		return "", err
+	}
+	part, err := form.CreateFormFile("file", "chunk"+audioExtension(contentType))
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(part, bytes.NewReader(audio)); err != nil {
+		return "", err
+	}
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+
+	var resp whisperResponse
+	if err := c.do(req, &resp); err != nil {
+		return "", err
+	}
+	return resp.Text, nil
+}
+
+// audioExtension names the uploaded chunk so Whisper can tell its format
+func audioExtension(contentType string) string {
+	switch strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]) {
+	case "audio/wav", "audio/x-wav":
+		return ".wav"
+	case "audio/mpeg":
+		return ".mp3"
+	case "audio/mp4", "audio/aac":
+		return ".m4a"
+	case "audio/webm":
+		return ".webm"
+	}
+	return ".ogg"
+}
+
+// Deepgram pre-recorded /v1/listen
+
+type deepgramResponse struct {
+	Results struct {
+		Channels []struct {
+			Alternatives []struct {
+				Transcript string `json:"transcript"`
+			} `json:"alternatives"`
+		} `json:"channels"`
+	} `json:"results"`
+}
+
+func (c *Client) transcribeDeepgram(ctx context.Context, audio []byte, contentType string) (string, error) {
+	endpoint := "https://api.deepgram.com"
+	if c.baseURL != "" {
+		endpoint = c.baseURL
+	}
+	query := url.Values{}
+	query.Set("punctuate", "true")
+	query.Set("smart_format", "true")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/v1/listen?"+query.Encode(), bytes.NewReader(audio))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Token "+c.apiKey)
+
+	var resp deepgramResponse
+	if err := c.do(req, &resp); err != nil {
+		return "", err
+	}
+	if len(resp.Results.Channels) == 0 || len(resp.Results.Channels[0].Alternatives) == 0 {
+		return "", nil
+	}
+	return resp.Results.Channels[0].Alternatives[0].Transcript, nil
+}
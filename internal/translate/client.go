@@ -0,0 +1,190 @@
+// Package translate detects message languages and translates text through a provider.
+package translate
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	ProviderDeepL          = "deepl"
+	ProviderLibreTranslate = "libretranslate"
+
+	requestTimeout = 10 * time.Second
+	cacheKeyPrefix = "translation:"
+	cacheTTL       = 7 * 24 * time.Hour
+)
+
+var ErrDisabled = errors.New("translation is not configured")
+
+var languagePattern = regexp.MustCompile(`^[a-z]{2}$`)
+
+// ValidLanguage reports whether lang is a lowercase ISO 639-1 code
+func ValidLanguage(lang string) bool {
+	return languagePattern.MatchString(lang)
+}
+
+// Cache stores translated texts (implemented by cache.RedisCache)
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// Client translates text with DeepL or a LibreTranslate instance so the API key stays on the server
+type Client struct {
+	provider string
+	apiKey   string
+	baseURL  string
+	http     *http.Client
+	cache    Cache
+}
+
+// NewClient returns a client for provider. DeepL needs apiKey; LibreTranslate needs baseURL
+// (apiKey is optional there). Without them translation is disabled.
+func NewClient(provider, apiKey, baseURL string) *Client {
+	return &Client{
+		provider: provider,
+		apiKey:   apiKey,
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		http:     &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// SetCache enables caching of translations
+func (c *Client) SetCache(cache Cache) {
+	c.cache = cache
+}
+
+func (c *Client) Enabled() bool {
+	switch c.provider {
+	case ProviderDeepL:
+		return c.apiKey != ""
+	case ProviderLibreTranslate:
+		return c.baseURL != ""
+	}
+	return false
+}
+
+// Translate translates text into the target language, letting the provider detect the source
+func (c *Client) Translate(ctx context.Context, text, target string) (string, error) {
+	if !c.Enabled() {
+		return "", ErrDisabled
+	}
+
+	sum := sha256.Sum256([]byte(text))
+	key := cacheKeyPrefix + target + ":" + hex.EncodeToString(sum[:])
+	if c.cache != nil {
+		if cached, err := c.cache.Get(ctx, key); err == nil {
+			return string(cached), nil
+		}
+	}
+
+	var translated string
+	var err error
+	if c.provider == ProviderDeepL {
+		translated, err = c.translateDeepL(ctx, text, target)
+	} else {
+		translated, err = c.translateLibre(ctx, text, target)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if c.cache != nil {
+		_ = c.cache.Set(ctx, key, []byte(translated), cacheTTL)
+	}
+	return translated, nil
+}
+
+func (c *Client) do(req *http.Request, dest interface{}) error {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected status %d", c.provider, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(dest)
+}
+
+// DeepL v2
+
+type deeplResponse struct {
+	Translations []struct {
+		Text string `json:"text"`
+	} `json:"translations"`
+}
+
+func (c *Client) translateDeepL(ctx context.Context, text, target string) (string, error) {
+	// Free-plan keys end in ":fx" and use a separate host
+	endpoint := "https://api.deepl.com/v2/translate"
+	if strings.HasSuffix(c.apiKey, ":fx") {
+		endpoint = "https://api-free.deepl.com/v2/translate"
+	}
+	if c.baseURL != "" {
+		endpoint = c.baseURL + "/v2/translate"
+	}
+
+	form := url.Values{}
+	form.Set("text", text)
+	form.Set("target_lang", strings.ToUpper(target))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "DeepL-Auth-Key "+c.apiKey)
+
+	var resp deeplResponse
+	if err := c.do(req, &resp); err != nil {
+		return "", err
+	}
+	if len(resp.Translations) == 0 {
+		return "", fmt.Errorf("%s: empty response", c.provider)
+	}
+	return resp.Translations[0].Text, nil
+}
+
+// LibreTranslate
+
+type libreRequest struct {
+	Q      string `json:"q"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Format string `json:"format"`
+	APIKey string `json:"api_key,omitempty"`
+}
+
+type libreResponse struct {
+	TranslatedText string `json:"translatedText"`
+}
+
+func (c *Client) translateLibre(ctx context.Context, text, target string) (string, error) {
+	body, err := json.Marshal(libreRequest{Q: text, Source: "auto", Target: target, Format: "text", APIKey: c.apiKey})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/translate", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var resp libreResponse
+	if err := c.do(req, &resp); err != nil {
+		return "", err
+	}
+	return resp.TranslatedText, nil
+}
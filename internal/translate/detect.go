@@ -0,0 +1,116 @@
+package translate
+
+import (
+	"strings"
+	"unicode"
+)
+
+// minDetectLetters is the shortest text whose language is guessed at all
+const minDetectLetters = 8
+
+// stopwords are frequent short words that tell apart languages sharing the Latin script
+var stopwords = map[string][]string{
+	"en": {"the", "and", "is", "are", "you", "that", "it", "of", "to", "in", "for", "with", "this", "have", "was", "what", "not"},
+	"es": {"el", "la", "los", "las", "que", "es", "y", "de", "en", "por", "para", "con", "una", "pero", "como", "muy", "está"},
+	"fr": {"le", "la", "les", "est", "et", "de", "des", "une", "que", "pour", "pas", "avec", "je", "vous", "nous", "c'est", "très"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ich", "du", "mit", "ein", "eine", "zu", "auf", "wir", "sie", "auch", "sehr"},
+	"it": {"il", "lo", "gli", "che", "è", "e", "di", "per", "non", "una", "sono", "con", "ma", "come", "molto", "anche", "questo"},
+	"pt": {"o", "os", "as", "que", "é", "e", "de", "não", "um", "uma", "para", "com", "você", "mas", "muito", "isso", "está"},
+	"nl": {"de", "het", "een", "en", "is", "niet", "ik", "je", "van", "dat", "met", "op", "zijn", "ook", "wat", "maar", "heel"},
+}
+
+// Detect guesses the ISO 639-1 language of text from its script and, for Latin text,
+// common words. Returns "" when it can't tell (too short, or no clear winner).
+func Detect(text string) string {
+	scripts := map[string]int{}
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.Is(unicode.Hangul, r):
+			scripts["ko"]++
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+			scripts["ja"]++
+		case unicode.Is(unicode.Han, r):
+			scripts["han"]++
+		case unicode.Is(unicode.Cyrillic, r):
+			scripts["cyrillic"]++
+		case unicode.Is(unicode.Greek, r):
+			scripts["el"]++
+		case unicode.Is(unicode.Arabic, r):
+			scripts["ar"]++
+		case unicode.Is(unicode.Hebrew, r):
+			scripts["he"]++
+		case unicode.Is(unicode.Thai, r):
+			scripts["th"]++
+		case unicode.Is(unicode.Devanagari, r):
+			scripts["hi"]++
+		case unicode.Is(unicode.Latin, r):
+			scripts["latin"]++
+		}
+	}
+	if letters < minDetectLetters {
+		return ""
+	}
+
+	// Japanese mixes kana with kanji, so any kana decides it
+	if scripts["ja"] > 0 && scripts["ja"]+scripts["han"] > letters/2 {
+		return "ja"
+	}
+
+	script, count := "", 0
+	for s, n := range scripts {
+		if n > count {
+			script, count = s, n
+		}
+	}
+	if count <= letters/2 {
+		return ""
+	}
+
+	switch script {
+	case "han":
+		return "zh"
+	case "cyrillic":
+		if strings.ContainsAny(strings.ToLower(text), "іїєґ") {
+			return "uk"
+		}
+		return "ru"
+	case "latin":
+		return detectLatin(text)
+	}
+	return script
+}
+
+// detectLatin picks the language whose stopwords occur most often, if there is a single winner
+func detectLatin(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+
+	best, bestScore, tie := "", 0, false
+	for lang, list := range stopwords {
+		score := 0
+		for _, w := range words {
+			for _, s := range list {
+				if w == s {
+					score++
+					break
+				}
+			}
+		}
+		switch {
+		case score > bestScore:
+			best, bestScore, tie = lang, score, false
+		case score == bestScore:
+			tie = true
+		}
+	}
+	if bestScore == 0 || tie {
+		return ""
+	}
+	return best
+}
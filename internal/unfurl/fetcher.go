@@ -0,0 +1,169 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/user/bla-back/internal/models"
+	"github.com/user/bla-back/internal/outbound"
+	"golang.org/x/net/html"
+)
+
+const (
+	maxURLsPerMessage = 3
+	maxBodyBytes      = 1 << 20
+	maxRedirects      = 5
+	fetchTimeout      = 8 * time.Second
+	userAgent         = "Mozilla/5.0 (compatible; BlaBot/1.0; +https://joinbla.ru)"
+)
+
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"']+`)
+
+var ErrNoMetadata = errors.New("page has no preview metadata")
+
+// ExtractURLs returns unique http(s) links found in message content
+func ExtractURLs(content string) []string {
+	seen := make(map[string]bool)
+	var urls []string
+	for _, raw := range urlPattern.FindAllString(content, -1) {
+		// Drop trailing punctuation that is usually part of the sentence
+		raw = strings.TrimRight(raw, ".,;:!?)]}")
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		if !seen[raw] {
+			seen[raw] = true
+			urls = append(urls, raw)
+		}
+		if len(urls) == maxURLsPerMessage {
+			break
+		}
+	}
+	return urls
+}
+
+// Fetcher downloads pages and reads their OpenGraph / Twitter card metadata
+type Fetcher struct {
+	client *http.Client
+}
+
+func NewFetcher(policy *outbound.Policy) *Fetcher {
+	return &Fetcher{
+		client: policy.Client(outbound.Limits{
+			Timeout:      fetchTimeout,
+			MaxBodyBytes: maxBodyBytes,
+			MaxRedirects: maxRedirects,
+		}),
+	}
+}
+
+// Fetch returns an embed for pageURL, or ErrNoMetadata if the page has nothing to show
+func (f *Fetcher) Fetch(ctx context.Context, pageURL string) (*models.Embed, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, ErrNoMetadata
+	}
+
+	// Pages over the size limit are cut off, which only matters if <head> is that long
+	meta := parseMeta(resp.Body)
+
+	embed := &models.Embed{
+		URL:         pageURL,
+		Title:       firstNonEmpty(meta["og:title"], meta["twitter:title"], meta["title"]),
+		Description: firstNonEmpty(meta["og:description"], meta["twitter:description"], meta["description"]),
+		SiteName:    firstNonEmpty(meta["og:site_name"], resp.Request.URL.Hostname()),
+	}
+	if image := firstNonEmpty(meta["og:image"], meta["og:image:url"], meta["twitter:image"]); image != "" {
+		// Relative image paths are resolved against the final page URL
+		if ref, err := resp.Request.URL.Parse(image); err == nil {
+			image = ref.String()
+		}
+		embed.ImageURL = &image
+	}
+
+	if embed.Title == "" && embed.Description == "" && embed.ImageURL == nil {
+		return nil, ErrNoMetadata
+	}
+	return embed, nil
+}
+
+// parseMeta collects <meta> properties and the <title> from the document head
+func parseMeta(r io.Reader) map[string]string {
+	meta := make(map[string]string)
+	z := html.NewTokenizer(r)
+	inTitle := false
+
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return meta
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			switch tok.Data {
+			case "title":
+				inTitle = true
+			case "meta":
+				var key, content string
+				for _, a := range tok.Attr {
+					switch a.Key {
+					case "property", "name":
+						key = strings.ToLower(a.Val)
+					case "content":
+						content = strings.TrimSpace(a.Val)
+					}
+				}
+				if key != "" && content != "" && meta[key] == "" {
+					meta[key] = content
+				}
+			case "body":
+				// Metadata lives in <head>; no need to read the rest
+				return meta
+			}
+		case html.TextToken:
+			if inTitle && meta["title"] == "" {
+				meta["title"] = strings.TrimSpace(string(z.Text()))
+			}
+		case html.EndTagToken:
+			if tok := z.Token(); tok.Data == "title" {
+				inTitle = false
+			} else if tok.Data == "head" {
+				return meta
+			}
+		}
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,99 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/user/bla-back/internal/messages"
+	"github.com/user/bla-back/internal/models"
+)
+
+const (
+	queueSize   = 256
+	workerCount = 4
+	jobTimeout  = 20 * time.Second
+)
+
+// Worker fetches link previews for new messages in the background
+type Worker struct {
+	repo      *messages.Repository
+	publisher messages.Publisher
+	fetcher   *Fetcher
+	jobs      chan *models.Message
+}
+
+func NewWorker(repo *messages.Repository, publisher messages.Publisher, fetcher *Fetcher) *Worker {
+	return &Worker{
+		repo:      repo,
+		publisher: publisher,
+		fetcher:   fetcher,
+		jobs:      make(chan *models.Message, queueSize),
+	}
+}
+
+// Enqueue schedules msg for unfurling if it contains links. Never blocks; drops when the queue is full.
+func (w *Worker) Enqueue(msg *models.Message) {
+	if len(ExtractURLs(msg.Content)) == 0 {
+		return
+	}
+
+	select {
+	case w.jobs <- msg:
+	default:
+		log.Printf("Unfurl queue full, skipping message %s", msg.ID)
+	}
+}
+
+// Run processes queued messages until ctx is cancelled
+func (w *Worker) Run(ctx context.Context) {
+	for i := 0; i < workerCount; i++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case msg := <-w.jobs:
+					w.process(ctx, msg)
+				}
+			}
+		}()
+	}
+}
+
+func (w *Worker) process(ctx context.Context, msg *models.Message) {
+	ctx, cancel := context.WithTimeout(ctx, jobTimeout)
+	defer cancel()
+
+	var embeds []*models.Embed
+	for _, u := range ExtractURLs(msg.Content) {
+		embed, err := w.fetcher.Fetch(ctx, u)
+		if err != nil {
+			if !errors.Is(err, ErrNoMetadata) {
+				log.Printf("Failed to unfurl %s: %v", u, err)
+			}
+			continue
+		}
+		embeds = append(embeds, embed)
+	}
+
+	if len(embeds) == 0 {
+		return
+	}
+
+	if err := w.repo.SaveEmbeds(ctx, msg.ID, embeds); err != nil {
+		log.Printf("Failed to save embeds for message %s: %v", msg.ID, err)
+		return
+	}
+
+	// Copy so the handler's message is never mutated concurrently
+	updated := *msg
+	updated.Embeds = embeds
+
+	participantIDs, _ := w.repo.GetConversationParticipantIDs(ctx, msg.ConversationID)
+	w.publisher.PublishToConversation(msg.ConversationID, participantIDs, "MESSAGE_UPDATE", &models.MessageUpdateEvent{
+		Message:        &updated,
+		ConversationID: msg.ConversationID,
+	})
+}
@@ -0,0 +1,198 @@
+package widget
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/user/bla-back/internal/models"
+)
+
+var (
+	ErrInvalidKey = errors.New("invalid widget key")
+	ErrNotOwner   = errors.New("only the group owner can manage widget keys")
+)
+
+type Repository struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{db: db}
+}
+
+// CreateKey makes a group readable through the public widget API
+func (r *Repository) CreateKey(ctx context.Context, convID, userID uuid.UUID) (*models.WidgetKey, error) {
+	if err := r.checkOwner(ctx, convID, userID); err != nil {
+		return nil, err
+	}
+
+	bytes := make([]byte, 24)
+	if _, err := rand.Read(bytes); err != nil {
+		return nil, err
+	}
+
+	key := &models.WidgetKey{}
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO widget_keys (key, conversation_id, created_by)
+		VALUES ($1, $2, $3)
+		RETURNING key, conversation_id, created_at
+	`, "wk_"+hex.EncodeToString(bytes), convID, userID).Scan(&key.Key, &key.ConversationID, &key.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// ListKeys returns active widget keys of a group
+func (r *Repository) ListKeys(ctx context.Context, convID, userID uuid.UUID) ([]*models.WidgetKey, error) {
+	if err := r.checkOwner(ctx, convID, userID); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT key, conversation_id, created_at FROM widget_keys
+		WHERE conversation_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC
+	`, convID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*models.WidgetKey
+	for rows.Next() {
+		k := &models.WidgetKey{}
+		if err := rows.Scan(&k.Key, &k.ConversationID, &k.CreatedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeKey disables a widget key
+func (r *Repository) RevokeKey(ctx context.Context, convID, userID uuid.UUID, key string) error {
+	if err := r.checkOwner(ctx, convID, userID); err != nil {
+		return err
+	}
+
+	tag, err := r.db.Exec(ctx, `
+		UPDATE widget_keys SET revoked_at = NOW()
+		WHERE key = $1 AND conversation_id = $2 AND revoked_at IS NULL
+	`, key, convID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrInvalidKey
+	}
+	return nil
+}
+
+// ResolveKey returns the conversation a key grants read access to. Keys of deleted groups
+// stop resolving, even while the group can still be restored.
+func (r *Repository) ResolveKey(ctx context.Context, key string) (uuid.UUID, error) {
+	var convID uuid.UUID
+	err := r.db.QueryRow(ctx, `
+		SELECT wk.conversation_id FROM widget_keys wk
+		JOIN conversations c ON c.id = wk.conversation_id
+		WHERE wk.key = $1 AND wk.revoked_at IS NULL AND c.deleted_at IS NULL
+	`, key).Scan(&convID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, ErrInvalidKey
+	}
+	return convID, err
+}
+
+// GetRoom returns public info about the room
+func (r *Repository) GetRoom(ctx context.Context, convID uuid.UUID) (*models.WidgetRoom, error) {
+	room := &models.WidgetRoom{ID: convID}
+	err := r.db.QueryRow(ctx, `
+		SELECT c.name, c.avatar_url,
+			   (SELECT COUNT(*) FROM conversation_participants WHERE conversation_id = c.id)
+		FROM conversations c WHERE c.id = $1
+	`, convID).Scan(&room.Name, &room.AvatarURL, &room.ParticipantCount)
+	if err != nil {
+		return nil, err
+	}
+	return room, nil
+}
+
+// GetParticipantIDs returns member IDs (used for the online count)
+func (r *Repository) GetParticipantIDs(ctx context.Context, convID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT user_id FROM conversation_participants WHERE conversation_id = $1
+	`, convID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetRecentMessages returns the latest top-level text messages, oldest first, without private
+// fields. Expired messages are left out even before the sweeper deletes them.
+func (r *Repository) GetRecentMessages(ctx context.Context, convID uuid.UUID, limit int) ([]*models.WidgetMessage, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT m.id, COALESCE(m.type, 'text'), m.content, m.created_at, u.username, u.avatar_url
+		FROM messages m
+		JOIN users u ON m.sender_id = u.id
+		WHERE m.conversation_id = $1 AND m.parent_id IS NULL AND m.deleted_at IS NULL
+		  AND (m.expires_at IS NULL OR m.expires_at > NOW())
+		ORDER BY m.created_at DESC
+		LIMIT $2
+	`, convID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var msgs []*models.WidgetMessage
+	for rows.Next() {
+		m := &models.WidgetMessage{}
+		if err := rows.Scan(&m.ID, &m.Type, &m.Content, &m.CreatedAt, &m.AuthorName, &m.AuthorAvatarURL); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Reverse to get chronological order
+	for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
+		msgs[i], msgs[j] = msgs[j], msgs[i]
+	}
+	return msgs, nil
+}
+
+func (r *Repository) checkOwner(ctx context.Context, convID, userID uuid.UUID) error {
+	var ownerID *uuid.UUID
+	err := r.db.QueryRow(ctx, `
+		SELECT owner_id FROM conversations WHERE id = $1 AND type = 'group'
+	`, convID).Scan(&ownerID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotOwner
+	}
+	if err != nil {
+		return err
+	}
+	if ownerID == nil || *ownerID != userID {
+		return ErrNotOwner
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package widget
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/bla-back/internal/models"
+)
+
+const (
+	CacheTTL        = 10 * time.Second
+	maxFeedMessages = 50
+)
+
+// PresenceTracker reports whether a user is connected
+type PresenceTracker interface {
+	IsOnline(userID uuid.UUID) bool
+}
+
+// Service builds cached, read-only widget responses
+type Service struct {
+	repo     *Repository
+	presence PresenceTracker
+	cache    *responseCache
+}
+
+func NewService(repo *Repository, presence PresenceTracker) *Service {
+	return &Service{
+		repo:     repo,
+		presence: presence,
+		cache:    newResponseCache(CacheTTL),
+	}
+}
+
+// Feed returns the JSON-encoded recent messages of the room behind key
+func (s *Service) Feed(ctx context.Context, key string) ([]byte, error) {
+	return s.cached("feed:"+key, func() (interface{}, error) {
+		convID, err := s.repo.ResolveKey(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		msgs, err := s.repo.GetRecentMessages(ctx, convID, maxFeedMessages)
+		if err != nil {
+			return nil, err
+		}
+		if msgs == nil {
+			msgs = []*models.WidgetMessage{}
+		}
+		return msgs, nil
+	})
+}
+
+// Room returns the JSON-encoded room info with participant and online counts
+func (s *Service) Room(ctx context.Context, key string) ([]byte, error) {
+	return s.cached("room:"+key, func() (interface{}, error) {
+		convID, err := s.repo.ResolveKey(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		room, err := s.repo.GetRoom(ctx, convID)
+		if err != nil {
+			return nil, err
+		}
+		ids, err := s.repo.GetParticipantIDs(ctx, convID)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if s.presence.IsOnline(id) {
+				room.OnlineCount++
+			}
+		}
+		return room, nil
+	})
+}
+
+func (s *Service) cached(key string, load func() (interface{}, error)) ([]byte, error) {
+	if body, ok := s.cache.get(key); ok {
+		return body, nil
+	}
+
+	value, err := load()
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.set(key, body)
+	return body, nil
+}